@@ -26,6 +26,9 @@ func (c *CreateAclsRequest) decode(pd packetDecoder, version int16) (err error)
 	if err != nil {
 		return err
 	}
+	if n == -1 {
+		n = 0
+	}
 
 	c.AclCreations = make([]*AclCreation, n)
 
@@ -35,6 +35,9 @@ func (c *CreateAclsResponse) decode(pd packetDecoder, version int16) (err error)
 	if err != nil {
 		return err
 	}
+	if n == -1 {
+		n = 0
+	}
 
 	c.AclCreationResponses = make([]*AclCreationResponse, n)
 	for i := 0; i < n; i++ {
@@ -63,6 +66,10 @@ func (c *CreateAclsResponse) requiredVersion() KafkaVersion {
 	return V0_11_0_0
 }
 
+func (c *CreateAclsResponse) throttleTime() time.Duration {
+	return c.ThrottleTime
+}
+
 // AclCreationResponse is an acl creation response type
 type AclCreationResponse struct {
 	Err    KError
@@ -27,6 +27,9 @@ func (d *DeleteAclsRequest) decode(pd packetDecoder, version int16) (err error)
 	if err != nil {
 		return err
 	}
+	if n == -1 {
+		n = 0
+	}
 
 	d.Filters = make([]*AclFilter, n)
 	for i := 0; i < n; i++ {
@@ -36,6 +36,9 @@ func (d *DeleteAclsResponse) decode(pd packetDecoder, version int16) (err error)
 	if err != nil {
 		return err
 	}
+	if n == -1 {
+		n = 0
+	}
 	d.FilterResponses = make([]*FilterResponse, n)
 
 	for i := 0; i < n; i++ {
@@ -64,6 +67,10 @@ func (d *DeleteAclsResponse) requiredVersion() KafkaVersion {
 	return V0_11_0_0
 }
 
+func (d *DeleteAclsResponse) throttleTime() time.Duration {
+	return d.ThrottleTime
+}
+
 // FilterResponse is a filter response type
 type FilterResponse struct {
 	Err          KError
@@ -104,6 +111,9 @@ func (f *FilterResponse) decode(pd packetDecoder, version int16) (err error) {
 	if err != nil {
 		return err
 	}
+	if n == -1 {
+		n = 0
+	}
 	f.MatchingAcls = make([]*MatchingAcl, n)
 	for i := 0; i < n; i++ {
 		f.MatchingAcls[i] = new(MatchingAcl)
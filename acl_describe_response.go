@@ -57,6 +57,9 @@ func (d *DescribeAclsResponse) decode(pd packetDecoder, version int16) (err erro
 	if err != nil {
 		return err
 	}
+	if n == -1 {
+		n = 0
+	}
 	d.ResourceAcls = make([]*ResourceAcls, n)
 
 	for i := 0; i < n; i++ {
@@ -89,3 +92,7 @@ func (d *DescribeAclsResponse) requiredVersion() KafkaVersion {
 		return V0_11_0_0
 	}
 }
+
+func (d *DescribeAclsResponse) throttleTime() time.Duration {
+	return d.ThrottleTime
+}
@@ -1,5 +1,7 @@
 package sarama
 
+import "strings"
+
 type AclFilter struct {
 	Version                   int
 	ResourceType              AclResourceType
@@ -75,3 +77,54 @@ func (a *AclFilter) decode(pd packetDecoder, version int16) (err error) {
 
 	return nil
 }
+
+// aclWildcardResource is the resource name of the literal ACLs matching every
+// resource of their type
+const aclWildcardResource = "*"
+
+// Matches reports whether the ACL bound to the given resource matches the
+// filter, with the semantics of the brokers: nil names, principals and hosts
+// as well as the Any types match everything, and a filter with the Match
+// pattern type also matches the wildcard and prefixed ACLs applying to its
+// resource name.
+func (a *AclFilter) Matches(resource Resource, acl Acl) bool {
+	return a.matchesResource(resource) && a.matchesAcl(acl)
+}
+
+func (a *AclFilter) matchesResource(resource Resource) bool {
+	if a.ResourceType != AclResourceAny && a.ResourceType != resource.ResourceType {
+		return false
+	}
+	if a.ResourcePatternTypeFilter != AclPatternAny && a.ResourcePatternTypeFilter != AclPatternMatch &&
+		a.ResourcePatternTypeFilter != resource.ResourcePatternType {
+		return false
+	}
+	if a.ResourceName == nil {
+		return true
+	}
+	if a.ResourcePatternTypeFilter == AclPatternAny || a.ResourcePatternTypeFilter == resource.ResourcePatternType {
+		return *a.ResourceName == resource.ResourceName
+	}
+
+	switch resource.ResourcePatternType {
+	case AclPatternLiteral:
+		return *a.ResourceName == resource.ResourceName || resource.ResourceName == aclWildcardResource
+	case AclPatternPrefixed:
+		return strings.HasPrefix(*a.ResourceName, resource.ResourceName)
+	default:
+		return false
+	}
+}
+
+func (a *AclFilter) matchesAcl(acl Acl) bool {
+	if a.Principal != nil && *a.Principal != acl.Principal {
+		return false
+	}
+	if a.Host != nil && *a.Host != acl.Host {
+		return false
+	}
+	if a.Operation != AclOperationAny && a.Operation != acl.Operation {
+		return false
+	}
+	return a.PermissionType == AclPermissionAny || a.PermissionType == acl.PermissionType
+}
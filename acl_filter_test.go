@@ -0,0 +1,45 @@
+package sarama
+
+import "testing"
+
+func TestAclFilterMatches(t *testing.T) {
+	name := "orders"
+	principal := "User:alice"
+	acl := Acl{Principal: "User:alice", Host: "*", Operation: AclOperationRead, PermissionType: AclPermissionAllow}
+
+	literal := Resource{AclResourceTopic, "orders", AclPatternLiteral}
+	wildcard := Resource{AclResourceTopic, "*", AclPatternLiteral}
+	prefixed := Resource{AclResourceTopic, "ord", AclPatternPrefixed}
+	otherPrefix := Resource{AclResourceTopic, "pay", AclPatternPrefixed}
+	group := Resource{AclResourceGroup, "orders", AclPatternLiteral}
+
+	anyAcl := AclFilter{Operation: AclOperationAny, PermissionType: AclPermissionAny}
+
+	tests := []struct {
+		name     string
+		filter   AclFilter
+		resource Resource
+		acl      Acl
+		expected bool
+	}{
+		{"any matches everything", AclFilter{ResourceType: AclResourceAny, ResourcePatternTypeFilter: AclPatternAny, Operation: AclOperationAny, PermissionType: AclPermissionAny}, prefixed, acl, true},
+		{"resource type", AclFilter{ResourceType: AclResourceTopic, ResourcePatternTypeFilter: AclPatternAny, Operation: AclOperationAny, PermissionType: AclPermissionAny}, group, acl, false},
+		{"literal exact name", AclFilter{ResourceType: AclResourceTopic, ResourceName: &name, ResourcePatternTypeFilter: AclPatternLiteral, Operation: AclOperationAny, PermissionType: AclPermissionAny}, literal, acl, true},
+		{"literal skips wildcard", AclFilter{ResourceType: AclResourceTopic, ResourceName: &name, ResourcePatternTypeFilter: AclPatternLiteral, Operation: AclOperationAny, PermissionType: AclPermissionAny}, wildcard, acl, false},
+		{"literal skips prefixed", AclFilter{ResourceType: AclResourceTopic, ResourceName: &name, ResourcePatternTypeFilter: AclPatternLiteral, Operation: AclOperationAny, PermissionType: AclPermissionAny}, prefixed, acl, false},
+		{"any pattern exact name", AclFilter{ResourceType: AclResourceTopic, ResourceName: &name, ResourcePatternTypeFilter: AclPatternAny, Operation: AclOperationAny, PermissionType: AclPermissionAny}, prefixed, acl, false},
+		{"match wildcard", AclFilter{ResourceType: AclResourceTopic, ResourceName: &name, ResourcePatternTypeFilter: AclPatternMatch, Operation: AclOperationAny, PermissionType: AclPermissionAny}, wildcard, acl, true},
+		{"match prefix", AclFilter{ResourceType: AclResourceTopic, ResourceName: &name, ResourcePatternTypeFilter: AclPatternMatch, Operation: AclOperationAny, PermissionType: AclPermissionAny}, prefixed, acl, true},
+		{"match other prefix", AclFilter{ResourceType: AclResourceTopic, ResourceName: &name, ResourcePatternTypeFilter: AclPatternMatch, Operation: AclOperationAny, PermissionType: AclPermissionAny}, otherPrefix, acl, false},
+		{"principal", AclFilter{ResourceType: AclResourceAny, ResourcePatternTypeFilter: AclPatternAny, Principal: &principal, Operation: AclOperationAny, PermissionType: AclPermissionAny}, literal, Acl{Principal: "User:bob", Host: "*", Operation: AclOperationRead, PermissionType: AclPermissionAllow}, false},
+		{"operation", AclFilter{ResourceType: AclResourceAny, ResourcePatternTypeFilter: AclPatternAny, Operation: AclOperationWrite, PermissionType: AclPermissionAny}, literal, acl, false},
+		{"permission type", AclFilter{ResourceType: AclResourceAny, ResourcePatternTypeFilter: AclPatternAny, Operation: AclOperationRead, PermissionType: AclPermissionDeny}, literal, acl, false},
+		{"unknown types match nothing", anyAcl, literal, acl, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.filter.Matches(tt.resource, tt.acl); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
@@ -45,6 +45,9 @@ func (a *AddPartitionsToTxnRequest) decode(pd packetDecoder, version int16) (err
 	if err != nil {
 		return err
 	}
+	if n == -1 {
+		n = 0
+	}
 
 	a.TopicPartitions = make(map[string][]int32)
 	for i := 0; i < n; i++ {
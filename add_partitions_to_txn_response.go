@@ -44,6 +44,9 @@ func (a *AddPartitionsToTxnResponse) decode(pd packetDecoder, version int16) (er
 	if err != nil {
 		return err
 	}
+	if n == -1 {
+		n = 0
+	}
 
 	a.Errors = make(map[string][]*PartitionError)
 
@@ -57,6 +60,9 @@ func (a *AddPartitionsToTxnResponse) decode(pd packetDecoder, version int16) (er
 		if err != nil {
 			return err
 		}
+		if m == -1 {
+			m = 0
+		}
 
 		a.Errors[topic] = make([]*PartitionError, m)
 
@@ -87,6 +93,10 @@ func (a *AddPartitionsToTxnResponse) requiredVersion() KafkaVersion {
 	return V0_11_0_0
 }
 
+func (a *AddPartitionsToTxnResponse) throttleTime() time.Duration {
+	return a.ThrottleTime
+}
+
 // PartitionError is a partition error type
 type PartitionError struct {
 	Partition int32
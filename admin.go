@@ -1,9 +1,12 @@
 package sarama
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -12,6 +15,9 @@ import (
 // ClusterAdmin is the administrative client for Kafka, which supports managing and inspecting topics,
 // brokers, configurations and ACLs. The minimum broker version required is 0.10.0.0.
 // Methods with stricter requirements will specify the minimum broker version required.
+// Requests failing because the controller or the coordinator of a group moved, or
+// because the coordinator is still loading the group, are retried as configured
+// by Admin.Retry after refreshing the controller or coordinator.
 // You MUST call Close() on a client to avoid leaks
 type ClusterAdmin interface {
 	// Creates a new topic. This operation is supported by brokers with version 0.10.1.0 or higher.
@@ -20,6 +26,13 @@ type ClusterAdmin interface {
 	// may not return information about the new topic.The validateOnly option is supported from version 0.10.2.0.
 	CreateTopic(topic string, detail *TopicDetail, validateOnly bool) error
 
+	// Creates a new topic as CreateTopic does, then waits until every broker of
+	// the cluster returns metadata with a leader for each of its partitions and
+	// the client metadata is refreshed, so the topic can be produced to right
+	// away. Metadata is polled every Admin.Retry.Backoff until ctx is done.
+	// This operation is supported by brokers with version 0.10.1.0 or higher.
+	CreateTopicAndWait(ctx context.Context, topic string, detail *TopicDetail) error
+
 	// List the topics available in the cluster with the default options.
 	ListTopics() (map[string]TopicDetail, error)
 
@@ -42,6 +55,24 @@ type ClusterAdmin interface {
 	// new partitions. This operation is supported by brokers with version 1.0.0 or higher.
 	CreatePartitions(topic string, count int32, assignment [][]int32, validateOnly bool) error
 
+	// Makes the given topic match spec: the topic is created when it does not
+	// exist, otherwise partitions are added when spec.NumPartitions is higher
+	// than the current count and the config overrides of the topic are set or
+	// deleted so they are exactly spec.ConfigEntries. The changes made, or that
+	// would be made when validateOnly is true, are returned in a report. The
+	// partition count cannot be decreased and replication factor changes, which
+	// require a reassignment, are only reported.
+	// This operation is supported by brokers with version 2.3.0.0 or higher.
+	ApplyTopic(topic string, spec *TopicDetail, validateOnly bool) (*TopicChangeReport, error)
+
+	// Creates the destination topic with the partition count, replication
+	// factor and config overrides of the source topic, for instance to migrate
+	// a topic to a new name. A numPartitions larger than 0 replaces the
+	// partition count of the source topic. Sensitive configs cannot be read so
+	// they are not copied. The detail of the created topic is returned.
+	// This operation is supported by brokers with version 0.11.0.0 or higher.
+	CloneTopic(source, destination string, numPartitions int32, validateOnly bool) (*TopicDetail, error)
+
 	// Alter the replica assignment for partitions.
 	// This operation is supported by brokers with version 2.4.0.0 or higher.
 	AlterPartitionReassignments(topic string, assignment [][]int32) error
@@ -50,16 +81,87 @@ type ClusterAdmin interface {
 	// This operation is supported by brokers with version 2.4.0.0 or higher.
 	ListPartitionReassignments(topics string, partitions []int32) (topicStatus map[string]map[int32]*PartitionReplicaReassignmentsStatus, err error)
 
+	// Provides info on the ongoing replica reassignments of the given partitions,
+	// or of all the partitions of the cluster when partitions is nil. Partitions
+	// that are not being reassigned are omitted from the result.
+	// This operation is supported by brokers with version 2.4.0.0 or higher.
+	ListOngoingPartitionReassignments(partitions map[string][]int32) (map[string]map[int32]*PartitionReplicaReassignmentsStatus, error)
+
+	// Wait for the replica reassignments of the given partitions, or of all the
+	// ongoing reassignments when partitions is nil, to complete. The progress is
+	// polled every Admin.Reassignment.PollInterval and reported to the optional
+	// progress func until all the reassignments completed or ctx is done.
+	// This operation is supported by brokers with version 2.4.0.0 or higher.
+	WaitForReassignment(ctx context.Context, partitions map[string][]int32, progress func(*ReassignmentProgress)) error
+
+	// Elect the leaders of the given partitions, or of all the partitions when
+	// partitions is nil, and return the result of the election of each partition.
+	// This operation is supported by brokers with version 2.2.0.0 or higher, the
+	// unclean election type requires version 2.4.0.0 or higher.
+	ElectLeaders(electionType ElectionType, partitions map[string][]int32) (map[string]map[int32]*PartitionResult, error)
+
 	// Delete records whose offset is smaller than the given offset of the corresponding partition.
 	// This operation is supported by brokers with version 0.11.0.0 or higher.
 	DeleteRecords(topic string, partitionOffsets map[int32]int64) error
 
+	// Delete the records whose offset is smaller than the given offset of the
+	// corresponding topic partitions, an offset of -1 deleting all the records
+	// up to the high watermark. The requests are grouped by partition leader and
+	// sent concurrently. The result of each partition is returned, along with an
+	// ErrDeleteRecords when the deletion failed for any of them.
+	// This operation is supported by brokers with version 0.11.0.0 or higher.
+	DeleteRecordsBatch(offsets map[string]map[int32]int64) (map[string]map[int32]*DeleteRecordsResult, error)
+
+	// ListOffsets looks up the offsets of the given topic partitions, mapped
+	// to the time to query: OffsetOldest, OffsetNewest, OffsetMaxTimestamp or a
+	// timestamp in milliseconds, in which case the earliest offset whose
+	// timestamp is greater than or equal to it is returned. The isolation level
+	// controls whether OffsetNewest returns the high watermark or the last
+	// stable offset. The requests are grouped by partition leader and sent
+	// concurrently. The result of each partition is returned, along with an
+	// ErrListOffsets when the lookup failed for any of them.
+	// OffsetMaxTimestamp requires brokers with version 3.0.0 or higher.
+	ListOffsets(times map[string]map[int32]int64, isolationLevel IsolationLevel) (map[string]map[int32]*ListOffsetsResult, error)
+
+	// DescribeProducers lists the idempotent and transactional producers that
+	// are active on the given topic partitions, which helps finding the
+	// producers of hanging transactions. The requests are grouped by partition
+	// leader and sent concurrently. The result of each partition is returned,
+	// along with an ErrDescribeProducers when it failed for any of them.
+	// This operation is supported by brokers with version 2.8.0.0 or higher.
+	DescribeProducers(partitions map[string][]int32) (map[string]map[int32]*DescribeProducersResult, error)
+
+	// ListTransactions lists the transactions known by the transaction
+	// coordinators of the cluster, optionally filtered by state (for instance
+	// TransactionStateOngoing) and producer ID. Empty filters match all the
+	// transactions.
+	// This operation is supported by brokers with version 3.0.0.0 or higher.
+	ListTransactions(stateFilters []string, producerIDFilters []int64) ([]*TransactionListing, error)
+
+	// DescribeTransactions describes the given transactions, including the
+	// partitions of the ongoing ones. The transactions that could not be described
+	// have their Err set.
+	// This operation is supported by brokers with version 3.0.0.0 or higher.
+	DescribeTransactions(transactionalIDs []string) ([]*TransactionDescription, error)
+
+	// AbortTransaction forcefully aborts the transaction of a producer on a
+	// partition by writing an abort marker to it, in order to clear a hanging
+	// transaction that blocks the last stable offset of the partition. The
+	// producer and coordinator epochs are found using DescribeProducers.
+	// This requires the ClusterAction permission on the cluster and is supported
+	// by brokers with version 0.11.0.0 or higher.
+	AbortTransaction(spec AbortTransactionSpec) error
+
 	// Get the configuration for the specified resources.
 	// The returned configuration includes default values and the Default is true
 	// can be used to distinguish them from user supplied values.
 	// Config entries where ReadOnly is true cannot be updated.
 	// The value of config entries where Sensitive is true is always nil so
 	// sensitive information is not disclosed.
+	// From version 1.1.0.0 the Source of each entry tells where its value comes
+	// from and its Synonyms list the values it overrides or inherits, in order of
+	// precedence. From version 2.6.0.0 the entries also carry their Type and
+	// Documentation.
 	// This operation is supported by brokers with version 0.11.0.0 or higher.
 	DescribeConfig(resource ConfigResource) ([]ConfigEntry, error)
 
@@ -70,6 +172,13 @@ type ClusterAdmin interface {
 	// for some resources while fail for others. The configs for a particular resource are updated automatically.
 	AlterConfig(resourceType ConfigResourceType, name string, entries map[string]*string, validateOnly bool) error
 
+	// IncrementalAlterConfig incrementally updates the configuration for the specified resources with the default options.
+	// Unlike AlterConfig, only the given config entries are altered: each of them is set, deleted (reverted
+	// to its default), or has values appended to or subtracted from a list config, leaving the other
+	// configs of the resource untouched.
+	// This operation is supported by brokers with version 2.3.0.0 or higher.
+	IncrementalAlterConfig(resourceType ConfigResourceType, name string, entries map[string]IncrementalAlterConfigsEntry, validateOnly bool) error
+
 	// Creates access control lists (ACLs) which are bound to specific resources.
 	// This operation is not transactional so it may succeed for some ACLs while fail for others.
 	// If you attempt to add an ACL that duplicates an existing ACL, no error will be raised, but
@@ -86,9 +195,29 @@ type ClusterAdmin interface {
 	// This operation is supported by brokers with version 0.11.0.0 or higher.
 	DeleteACL(filter AclFilter, validateOnly bool) ([]MatchingAcl, error)
 
+	// Creates the given ACL bindings in a single request and returns the result
+	// of each of them, in the same order. Creating an ACL that already exists
+	// succeeds without changes.
+	// This operation is supported by brokers with version 0.11.0.0 or higher.
+	CreateACLs(bindings []AclCreation) ([]AclCreationResult, error)
+
+	// Deletes the ACLs matching each of the given filters in a single request
+	// and returns the result of each filter, in the same order, along with the
+	// ACLs it deleted. See AclFilter.Matches for the filter semantics.
+	// This operation is supported by brokers with version 0.11.0.0 or higher.
+	DeleteACLs(filters []AclFilter) ([]AclDeletionResult, error)
+
 	// List the consumer groups available in the cluster.
 	ListConsumerGroups() (map[string]string, error)
 
+	// List the consumer groups available in the cluster that are in one of the
+	// given states (GroupStateStable, GroupStateEmpty...) and of one of the
+	// given types (GroupTypeClassic, GroupTypeConsumer), along with their state
+	// and type. An empty filter matches all the groups.
+	// Filtering by state is supported by brokers with version 2.6.0.0 or higher
+	// and by type with version 3.8.0.0 or higher.
+	ListConsumerGroupsWithFilters(states []string, types []string) (map[string]*ConsumerGroupListing, error)
+
 	// Describe the given consumer groups.
 	DescribeConsumerGroups(groups []string) ([]*GroupDescription, error)
 
@@ -98,12 +227,78 @@ type ClusterAdmin interface {
 	// Deletes a consumer group offset
 	DeleteConsumerGroupOffset(group string, topic string, partition int32) error
 
+	// Deletes the committed offsets of a consumer group for the given
+	// topic-partitions, returning the error of each partition. The offsets of
+	// topics the group is still subscribed to cannot be deleted. ErrIncompleteResponse
+	// is returned along with the partition errors when some are missing.
+	// This operation is supported by brokers with version 2.4.0.0 or higher.
+	DeleteConsumerGroupOffsets(group string, partitions map[string][]int32) (map[string]map[int32]KError, error)
+
+	// Sets the committed offsets of a consumer group for the given
+	// topic-partitions, returning the error of each partition. The group must
+	// have no active members, ErrUnknownMemberId being returned for the
+	// partitions otherwise. ErrIncompleteResponse is returned along with the
+	// partition errors when some are missing.
+	// This operation is supported by brokers with version 0.9.0.0 or higher.
+	AlterConsumerGroupOffsets(group string, offsets map[string]map[int32]int64) (map[string]map[int32]KError, error)
+
+	// Resets the committed offsets of a consumer group for the given
+	// topic-partitions to the offsets found by ListOffsets for the given times:
+	// OffsetOldest, OffsetNewest or a timestamp in milliseconds. The group must
+	// have no active members, as for AlterConsumerGroupOffsets.
+	ResetConsumerGroupOffsets(group string, times map[string]map[int32]int64) (map[string]map[int32]KError, error)
+
 	// Delete a consumer group.
 	DeleteConsumerGroup(group string) error
 
+	// Removes the static members with the given group instance IDs from a
+	// consumer group, for instance to kick stuck members before scaling. The
+	// error of each member is returned keyed by its group instance ID.
+	// ErrIncompleteResponse is returned along with the member errors when some
+	// are missing.
+	// This operation is supported by brokers with version 2.4.0.0 or higher.
+	RemoveMembersFromConsumerGroup(group string, groupInstanceIDs []string) (map[string]KError, error)
+
+	// Removes all the members of a consumer group, static or dynamic. The error
+	// of each member is returned keyed by its member ID.
+	// This operation is supported by brokers with version 2.4.0.0 or higher.
+	RemoveAllMembersFromConsumerGroup(group string) (map[string]KError, error)
+
 	// Get information about the nodes in the cluster
 	DescribeCluster() (brokers []*Broker, controllerID int32, err error)
 
+	// Get the ID, controller and nodes of the cluster, along with the operations
+	// the client is authorized to perform on the cluster when
+	// includeAuthorizedOperations is true. Authorized operations are supported by
+	// brokers with version 2.3.0.0 or higher.
+	DescribeClusterDetails(includeAuthorizedOperations bool) (*ClusterDescription, error)
+
+	// Get the range of versions of the features supported by a broker and the
+	// versions finalized for the whole cluster (KIP-584).
+	// This operation is supported by brokers with version 2.7.0.0 or higher.
+	DescribeFeatures() (*FeatureMetadata, error)
+
+	// Update the finalized versions of the given features, for instance to
+	// bump the metadata.version of a KRaft cluster once all of its nodes are
+	// upgraded. A MaxVersionLevel lower than 1 deletes the feature. The error of
+	// each feature is returned, a nil error meaning the update succeeded.
+	// This operation is supported by brokers with version 2.7.0.0 or higher,
+	// downgrade types other than FeatureUnsafeDowngrade and validateOnly
+	// requiring version 3.3.0.0 or higher.
+	UpdateFeatures(updates map[string]FeatureUpdate, validateOnly bool) (map[string]error, error)
+
+	// Get the state of the controller quorum of a KRaft cluster: its leader and
+	// epoch, and the replication state of its voters and observers.
+	// This operation is supported by KRaft clusters with version 3.3.0.0 or
+	// higher.
+	DescribeQuorum() (*QuorumInfo, error)
+
+	// Remove the registration of a broker from a KRaft cluster, once it has been
+	// decommissioned and is no longer running.
+	// This operation is supported by KRaft clusters with version 3.0.0.0 or
+	// higher.
+	UnregisterBroker(brokerID int32) error
+
 	// Get information about all log directories on the given set of brokers
 	DescribeLogDirs(brokers []int32) (map[int32][]DescribeLogDirsResponseDirMetadata, error)
 
@@ -116,6 +311,34 @@ type ClusterAdmin interface {
 	// Upsert SCRAM users
 	UpsertUserScramCredentials(upsert []AlterUserScramCredentialsUpsert) ([]*AlterUserScramCredentialsResult, error)
 
+	// Upsert and delete SCRAM users in a single request. Upsertions without a
+	// Salt get a random one and upsertions without Iterations use the minimum
+	// of 4096 accepted by the brokers.
+	// This operation is supported by brokers with version 2.7.0.0 or higher.
+	AlterUserScramCredentials(upsert []AlterUserScramCredentialsUpsert, delete []AlterUserScramCredentialsDelete) ([]*AlterUserScramCredentialsResult, error)
+
+	// Create a delegation token owned by the authenticated principal, which can
+	// be renewed by its owner and the given renewers. A negative maxLifetime uses
+	// the maximum lifetime configured on the brokers.
+	// This operation is supported by brokers with version 1.1.0.0 or higher.
+	CreateDelegationToken(renewers []KafkaPrincipal, maxLifetime time.Duration) (*DelegationToken, error)
+
+	// Renew the delegation token with the given HMAC for renewPeriod, a negative
+	// renewPeriod using the renew period configured on the brokers, and return
+	// its new expiry time.
+	// This operation is supported by brokers with version 1.1.0.0 or higher.
+	RenewDelegationToken(hmac []byte, renewPeriod time.Duration) (time.Time, error)
+
+	// Expire the delegation token with the given HMAC after expiryPeriod, a
+	// negative expiryPeriod expiring it immediately, and return its new expiry time.
+	// This operation is supported by brokers with version 1.1.0.0 or higher.
+	ExpireDelegationToken(hmac []byte, expiryPeriod time.Duration) (time.Time, error)
+
+	// Describe the delegation tokens of the given owners, or all the tokens the
+	// authenticated principal is allowed to describe when owners is nil.
+	// This operation is supported by brokers with version 1.1.0.0 or higher.
+	DescribeDelegationToken(owners []KafkaPrincipal) ([]*DelegationToken, error)
+
 	// Get client quota configurations corresponding to the specified filter.
 	// This operation is supported by brokers with version 2.6.0.0 or higher.
 	DescribeClientQuotas(components []QuotaFilterComponent, strict bool) ([]DescribeClientQuotasEntry, error)
@@ -124,10 +347,31 @@ type ClusterAdmin interface {
 	// This operation is supported by brokers with version 2.6.0.0 or higher.
 	AlterClientQuotas(entity []QuotaEntityComponent, op ClientQuotasOp, validateOnly bool) error
 
+	// Alters the client quota configurations of several entities at once, each
+	// entry holding any number of ops. The alterations are not transactional,
+	// the error of the first entry that failed is returned.
+	// This operation is supported by brokers with version 2.6.0.0 or higher.
+	AlterClientQuotasEntries(entries []AlterClientQuotasEntry, validateOnly bool) error
+
 	// Controller returns the cluster controller broker. It will return a
 	// locally cached value if it's available.
 	Controller() (*Broker, error)
 
+	// Returns a ClusterAdmin sharing the client of this one whose methods
+	// honour ctx: once ctx is done they stop retrying and return ctx.Err()
+	// without waiting for the responses of their requests in flight. Those are
+	// still read off the connections shared with the other users of the
+	// client, which are not affected. A request the broker already received
+	// may still take effect, and connection attempts are only bounded by
+	// Net.DialTimeout. CreateTopicAndWait and WaitForReassignment stop once
+	// either ctx or their own context is done.
+	WithContext(ctx context.Context) ClusterAdmin
+
+	// Returns a ClusterAdmin sharing the client of this one whose calls use the
+	// given timeout and retry policy instead of the Admin ones of the config,
+	// so that operations with very different time budgets can share a client.
+	WithOptions(options AdminOptions) ClusterAdmin
+
 	// Close shuts down the admin and closes underlying client.
 	Close() error
 }
@@ -135,6 +379,8 @@ type ClusterAdmin interface {
 type clusterAdmin struct {
 	client Client
 	conf   *Config
+	// ctx is the context set by WithContext, nil meaning context.Background()
+	ctx context.Context
 }
 
 // NewClusterAdmin creates a new ClusterAdmin using the given broker addresses and configuration.
@@ -166,6 +412,55 @@ func (ca *clusterAdmin) Close() error {
 	return ca.client.Close()
 }
 
+func (ca *clusterAdmin) WithContext(ctx context.Context) ClusterAdmin {
+	if ctx == nil {
+		panic("nil context")
+	}
+	withContext := *ca
+	withContext.ctx = ctx
+	return &contextClusterAdmin{ca: &withContext}
+}
+
+// AdminOptions overrides the timeout and retry policy of the calls of the
+// ClusterAdmin returned by WithOptions. Zero values keep the current ones.
+type AdminOptions struct {
+	// Timeout replaces Admin.Timeout, the time the brokers are given to
+	// complete operations such as creating or deleting topics.
+	Timeout time.Duration
+	// CallTimeout is the maximum duration of each call, including retries,
+	// after which it returns context.DeadlineExceeded. Calls are not limited by
+	// default.
+	CallTimeout time.Duration
+	// RetryMax replaces Admin.Retry.Max.
+	RetryMax int
+	// RetryBackoff replaces Admin.Retry.Backoff.
+	RetryBackoff time.Duration
+}
+
+func (ca *clusterAdmin) WithOptions(options AdminOptions) ClusterAdmin {
+	conf := *ca.conf
+	if options.Timeout > 0 {
+		conf.Admin.Timeout = options.Timeout
+	}
+	if options.RetryMax > 0 {
+		conf.Admin.Retry.Max = options.RetryMax
+	}
+	if options.RetryBackoff > 0 {
+		conf.Admin.Retry.Backoff = options.RetryBackoff
+	}
+
+	withOptions := *ca
+	withOptions.conf = &conf
+	return &contextClusterAdmin{ca: &withOptions, callTimeout: options.CallTimeout}
+}
+
+func (ca *clusterAdmin) context() context.Context {
+	if ca.ctx == nil {
+		return context.Background()
+	}
+	return ca.ctx
+}
+
 func (ca *clusterAdmin) Controller() (*Broker, error) {
 	return ca.client.Controller()
 }
@@ -194,20 +489,58 @@ func isErrNoController(err error) bool {
 // the admin client configuration
 func (ca *clusterAdmin) retryOnError(retryable func(error) bool, fn func() error) error {
 	var err error
+	ctx := ca.context()
 	for attempt := 0; attempt < ca.conf.Admin.Retry.Max; attempt++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		err = fn()
 		if err == nil || !retryable(err) {
 			return err
 		}
-		Logger.Printf(
-			"admin/request retrying after %dms... (%d attempts remaining)\n",
-			ca.conf.Admin.Retry.Backoff/time.Millisecond, ca.conf.Admin.Retry.Max-attempt)
-		time.Sleep(ca.conf.Admin.Retry.Backoff)
-		continue
+		logEntry(LogComponentAdmin, LogLevelWarn, "admin/request retrying", errorField(err),
+			LogField{Key: "backoff", Value: ca.conf.Admin.Retry.Backoff}, LogField{Key: "attempts_remaining", Value: ca.conf.Admin.Retry.Max - attempt})
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(ca.conf.Admin.Retry.Backoff):
+		}
 	}
 	return err
 }
 
+// isErrCoordinatorRetriable returns `true` if the given error unwraps to a
+// Kafka error meaning the coordinator of a group moved or is still loading it
+func isErrCoordinatorRetriable(err error) bool {
+	var kerr KError
+	if !errors.As(err, &kerr) {
+		return false
+	}
+	switch kerr {
+	case ErrNotCoordinatorForConsumer, ErrConsumerCoordinatorNotAvailable, ErrOffsetsLoadInProgress:
+		return true
+	}
+	return false
+}
+
+// retryOnCoordinatorError calls fn with the coordinator of the group until it
+// returns an error that is not retriable as per isErrCoordinatorRetriable,
+// refreshing the coordinator when it moved or is unavailable
+func (ca *clusterAdmin) retryOnCoordinatorError(group string, fn func(coordinator *Broker) error) error {
+	return ca.retryOnError(isErrCoordinatorRetriable, func() error {
+		coordinator, err := ca.client.Coordinator(group)
+		if err != nil {
+			return err
+		}
+
+		err = fn(coordinator)
+		if errors.Is(err, ErrNotCoordinatorForConsumer) || errors.Is(err, ErrConsumerCoordinatorNotAvailable) {
+			_ = ca.client.RefreshCoordinator(group)
+		}
+		return err
+	})
+}
+
 func (ca *clusterAdmin) CreateTopic(topic string, detail *TopicDetail, validateOnly bool) error {
 	if topic == "" {
 		return ErrInvalidTopic
@@ -217,6 +550,29 @@ func (ca *clusterAdmin) CreateTopic(topic string, detail *TopicDetail, validateO
 		return errors.New("you must specify topic details")
 	}
 
+	if ca.conf.Admin.RackAwareAssignment && len(detail.ReplicaAssignment) == 0 &&
+		detail.NumPartitions > 0 && detail.ReplicationFactor > 0 {
+		brokers := ca.replicaAssignmentBrokers()
+		startIndex := 0
+		if len(brokers) > 0 {
+			startIndex = rand.Intn(len(brokers))
+		}
+		assignment, err := AssignReplicas(brokers, detail.NumPartitions, detail.ReplicationFactor, 0, startIndex)
+		if err != nil {
+			return err
+		}
+		withAssignment := *detail
+		// the partition count and replication factor must not be set along
+		// with an explicit assignment
+		withAssignment.NumPartitions = -1
+		withAssignment.ReplicationFactor = -1
+		withAssignment.ReplicaAssignment = make(map[int32][]int32, len(assignment))
+		for partition, replicas := range assignment {
+			withAssignment.ReplicaAssignment[int32(partition)] = replicas
+		}
+		detail = &withAssignment
+	}
+
 	topicDetails := make(map[string]*TopicDetail)
 	topicDetails[topic] = detail
 
@@ -260,22 +616,82 @@ func (ca *clusterAdmin) CreateTopic(topic string, detail *TopicDetail, validateO
 	})
 }
 
+func (ca *clusterAdmin) CreateTopicAndWait(ctx context.Context, topic string, detail *TopicDetail) error {
+	return (&contextClusterAdmin{ca: ca}).CreateTopicAndWait(ctx, topic, detail)
+}
+
+// createTopicAndWait implements CreateTopicAndWait, polling the metadata until
+// the context of the admin is done
+func (ca *clusterAdmin) createTopicAndWait(topic string, detail *TopicDetail) error {
+	if err := ca.CreateTopic(topic, detail, false); err != nil {
+		return err
+	}
+
+	numPartitions := int(detail.NumPartitions)
+	if len(detail.ReplicaAssignment) > 0 {
+		numPartitions = len(detail.ReplicaAssignment)
+	}
+
+	for {
+		ready, err := ca.topicReady(topic, numPartitions)
+		if err != nil {
+			return err
+		}
+		if ready {
+			return ca.client.RefreshMetadata(topic)
+		}
+
+		select {
+		case <-ca.context().Done():
+			return ca.context().Err()
+		case <-time.After(ca.conf.Admin.Retry.Backoff):
+		}
+	}
+}
+
+// topicReady reports whether every broker returns metadata with a leader for
+// each partition of the topic. numPartitions is the expected number of
+// partitions, or -1 when the broker default is used
+func (ca *clusterAdmin) topicReady(topic string, numPartitions int) (bool, error) {
+	request := NewMetadataRequest(ca.conf.Version, []string{topic})
+
+	for _, b := range ca.client.Brokers() {
+		_ = b.Open(ca.client.Config())
+		response, err := b.GetMetadata(request)
+		if err != nil {
+			return false, err
+		}
+		if len(response.Topics) != 1 {
+			return false, nil
+		}
+
+		metadata := response.Topics[0]
+		switch metadata.Err {
+		case ErrNoError:
+		case ErrUnknownTopicOrPartition, ErrLeaderNotAvailable:
+			return false, nil
+		default:
+			return false, metadata.Err
+		}
+		if len(metadata.Partitions) == 0 || (numPartitions > 0 && len(metadata.Partitions) != numPartitions) {
+			return false, nil
+		}
+		for _, partition := range metadata.Partitions {
+			if partition.Err == ErrLeaderNotAvailable || partition.Leader < 0 {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
 func (ca *clusterAdmin) DescribeTopics(topics []string) (metadata []*TopicMetadata, err error) {
 	controller, err := ca.Controller()
 	if err != nil {
 		return nil, err
 	}
 
-	request := &MetadataRequest{
-		Topics:                 topics,
-		AllowAutoTopicCreation: false,
-	}
-
-	if ca.conf.Version.IsAtLeast(V1_0_0_0) {
-		request.Version = 5
-	} else if ca.conf.Version.IsAtLeast(V0_11_0_0) {
-		request.Version = 4
-	}
+	request := NewMetadataRequest(ca.conf.Version, topics)
 
 	response, err := controller.GetMetadata(request)
 	if err != nil {
@@ -303,9 +719,307 @@ func (ca *clusterAdmin) DescribeCluster() (brokers []*Broker, controllerID int32
 		return nil, int32(0), err
 	}
 
+	ca.conf.resolveBrokers(response.Brokers...)
 	return response.Brokers, response.ControllerID, nil
 }
 
+// ClusterDescription is the description of a cluster returned by
+// DescribeClusterDetails.
+type ClusterDescription struct {
+	// ClusterID is empty for brokers older than 0.10.1.0
+	ClusterID    string
+	ControllerID int32
+	Brokers      []*Broker
+	// AuthorizedOperations is nil unless requested and returned by the broker
+	AuthorizedOperations []AclOperation
+}
+
+func (ca *clusterAdmin) DescribeClusterDetails(includeAuthorizedOperations bool) (*ClusterDescription, error) {
+	request := &MetadataRequest{
+		Topics: []string{},
+	}
+
+	switch {
+	case ca.conf.Version.IsAtLeast(V2_3_0_0):
+		request.Version = 8
+		request.IncludeClusterAuthorizedOperations = includeAuthorizedOperations
+	case includeAuthorizedOperations:
+		return nil, ConfigurationError("authorized operations require Version >= V2_3_0_0")
+	case ca.conf.Version.IsAtLeast(V0_10_1_0):
+		request.Version = 2
+	case ca.conf.Version.IsAtLeast(V0_10_0_0):
+		request.Version = 1
+	}
+
+	controller, err := ca.Controller()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := controller.GetMetadata(request)
+	if err != nil {
+		return nil, err
+	}
+
+	ca.conf.resolveBrokers(response.Brokers...)
+	description := &ClusterDescription{
+		ControllerID: response.ControllerID,
+		Brokers:      response.Brokers,
+	}
+	if response.ClusterID != nil {
+		description.ClusterID = *response.ClusterID
+	}
+	if includeAuthorizedOperations {
+		description.AuthorizedOperations = authorizedOperations(response.ClusterAuthorizedOperations)
+	}
+	return description, nil
+}
+
+// authorizedOperations decodes the bit field of authorized operations returned
+// by the brokers, where bit N is set when AclOperation(N) is authorized.
+// math.MinInt32 means the operations were not returned.
+func authorizedOperations(bits int32) []AclOperation {
+	if bits == math.MinInt32 {
+		return nil
+	}
+	operations := make([]AclOperation, 0)
+	for op := AclOperationUnknown; op < 32; op++ {
+		if bits&(1<<uint(op)) != 0 {
+			operations = append(operations, op)
+		}
+	}
+	return operations
+}
+
+// FeatureMetadata holds the features supported by a broker and the ones
+// finalized for the whole cluster, by name.
+type FeatureMetadata struct {
+	SupportedFeatures map[string]SupportedFeatureKey
+	FinalizedFeatures map[string]FinalizedFeatureKey
+	// FinalizedFeaturesEpoch is -1 when unknown
+	FinalizedFeaturesEpoch int64
+}
+
+func (ca *clusterAdmin) DescribeFeatures() (*FeatureMetadata, error) {
+	if !ca.conf.Version.IsAtLeast(V2_7_0_0) {
+		return nil, ConfigurationError("DescribeFeatures requires Version >= V2_7_0_0")
+	}
+
+	b, err := ca.findAnyBroker()
+	if err != nil {
+		return nil, err
+	}
+	_ = b.Open(ca.client.Config())
+
+	rsp, err := b.ApiVersions(&ApiVersionsRequest{
+		Version:               3,
+		ClientSoftwareName:    ca.conf.ClientSoftwareName,
+		ClientSoftwareVersion: ca.conf.ClientSoftwareVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rsp.Err != ErrNoError {
+		return nil, rsp.Err
+	}
+
+	metadata := &FeatureMetadata{
+		SupportedFeatures:      make(map[string]SupportedFeatureKey, len(rsp.SupportedFeatures)),
+		FinalizedFeatures:      make(map[string]FinalizedFeatureKey, len(rsp.FinalizedFeatures)),
+		FinalizedFeaturesEpoch: rsp.FinalizedFeaturesEpoch,
+	}
+	for _, feature := range rsp.SupportedFeatures {
+		metadata.SupportedFeatures[feature.Name] = feature
+	}
+	for _, feature := range rsp.FinalizedFeatures {
+		metadata.FinalizedFeatures[feature.Name] = feature
+	}
+	return metadata, nil
+}
+
+// FeatureUpdate is the update of the finalized version of a feature by
+// UpdateFeatures, the default upgrade type being FeatureUpgrade.
+type FeatureUpdate struct {
+	MaxVersionLevel int16
+	UpgradeType     FeatureUpgradeType
+}
+
+func (ca *clusterAdmin) UpdateFeatures(updates map[string]FeatureUpdate, validateOnly bool) (map[string]error, error) {
+	request := &UpdateFeaturesRequest{
+		Timeout:      ca.conf.Admin.Timeout,
+		ValidateOnly: validateOnly,
+	}
+	switch {
+	case ca.conf.Version.IsAtLeast(V3_3_0_0):
+		request.Version = 1
+	case !ca.conf.Version.IsAtLeast(V2_7_0_0):
+		return nil, ConfigurationError("UpdateFeatures requires Version >= V2_7_0_0")
+	case validateOnly:
+		return nil, ConfigurationError("UpdateFeatures with validateOnly requires Version >= V3_3_0_0")
+	}
+
+	for feature, update := range updates {
+		upgradeType := update.UpgradeType
+		if upgradeType == FeatureUpgradeUnknown {
+			upgradeType = FeatureUpgrade
+		}
+		if request.Version == 0 && upgradeType == FeatureSafeDowngrade {
+			return nil, ConfigurationError("FeatureSafeDowngrade requires Version >= V3_3_0_0")
+		}
+		request.FeatureUpdates = append(request.FeatureUpdates, FeatureUpdateKey{
+			Feature:         feature,
+			MaxVersionLevel: update.MaxVersionLevel,
+			AllowDowngrade:  upgradeType == FeatureUnsafeDowngrade,
+			UpgradeType:     upgradeType,
+		})
+	}
+
+	var results map[string]error
+	return results, ca.retryOnError(isErrNoController, func() error {
+		b, err := ca.Controller()
+		if err != nil {
+			return err
+		}
+		_ = b.Open(ca.client.Config())
+
+		rsp, err := b.UpdateFeatures(request)
+		if err != nil {
+			return err
+		}
+
+		if rsp.ErrorCode != ErrNoError {
+			if rsp.ErrorCode == ErrNotController {
+				_, _ = ca.refreshController()
+				return rsp.ErrorCode
+			}
+			if rsp.ErrorMessage != nil {
+				return fmt.Errorf("%w: %s", rsp.ErrorCode, *rsp.ErrorMessage)
+			}
+			return rsp.ErrorCode
+		}
+
+		results = make(map[string]error, len(updates))
+		for feature := range updates {
+			results[feature] = ErrIncompleteResponse
+		}
+		for _, result := range rsp.Results {
+			switch {
+			case result.ErrorCode == ErrNoError:
+				results[result.Feature] = nil
+			case result.ErrorMessage != nil:
+				results[result.Feature] = fmt.Errorf("%w: %s", result.ErrorCode, *result.ErrorMessage)
+			default:
+				results[result.Feature] = result.ErrorCode
+			}
+		}
+		return nil
+	})
+}
+
+// QuorumInfo describes the controller quorum of a KRaft cluster.
+type QuorumInfo struct {
+	LeaderID      int32
+	LeaderEpoch   int32
+	HighWatermark int64
+	Voters        []QuorumReplicaInfo
+	Observers     []QuorumReplicaInfo
+}
+
+// QuorumReplicaInfo is the replication state of a member of the quorum.
+type QuorumReplicaInfo struct {
+	ReplicaState
+	// Lag is the number of records of the metadata log the replica is
+	// missing, compared to the high watermark of the leader
+	Lag int64
+}
+
+func quorumReplicaInfos(states []ReplicaState, highWatermark int64) []QuorumReplicaInfo {
+	infos := make([]QuorumReplicaInfo, 0, len(states))
+	for _, state := range states {
+		lag := highWatermark - state.LogEndOffset
+		if lag < 0 {
+			lag = 0
+		}
+		infos = append(infos, QuorumReplicaInfo{ReplicaState: state, Lag: lag})
+	}
+	return infos
+}
+
+func (ca *clusterAdmin) DescribeQuorum() (*QuorumInfo, error) {
+	request := &DescribeQuorumRequest{
+		Topics: []DescribeQuorumRequestTopic{
+			{TopicName: kraftMetadataTopic, Partitions: []int32{0}},
+		},
+	}
+	if ca.conf.Version.IsAtLeast(V3_3_0_0) {
+		request.Version = 1
+	} else if !ca.conf.Version.IsAtLeast(V2_7_0_0) {
+		return nil, ConfigurationError("DescribeQuorum requires Version >= V2_7_0_0")
+	}
+
+	// brokers forward the request to the active controller
+	b, err := ca.findAnyBroker()
+	if err != nil {
+		return nil, err
+	}
+	_ = b.Open(ca.client.Config())
+
+	rsp, err := b.DescribeQuorum(request)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.ErrorCode != ErrNoError {
+		return nil, rsp.ErrorCode
+	}
+
+	for _, topic := range rsp.Topics {
+		if topic.TopicName != kraftMetadataTopic {
+			continue
+		}
+		for _, partition := range topic.Partitions {
+			if partition.PartitionIndex != 0 {
+				continue
+			}
+			if partition.ErrorCode != ErrNoError {
+				return nil, partition.ErrorCode
+			}
+			return &QuorumInfo{
+				LeaderID:      partition.LeaderID,
+				LeaderEpoch:   partition.LeaderEpoch,
+				HighWatermark: partition.HighWatermark,
+				Voters:        quorumReplicaInfos(partition.CurrentVoters, partition.HighWatermark),
+				Observers:     quorumReplicaInfos(partition.Observers, partition.HighWatermark),
+			}, nil
+		}
+	}
+	return nil, ErrIncompleteResponse
+}
+
+func (ca *clusterAdmin) UnregisterBroker(brokerID int32) error {
+	if !ca.conf.Version.IsAtLeast(V3_0_0_0) {
+		return ConfigurationError("UnregisterBroker requires Version >= V3_0_0_0")
+	}
+
+	// brokers forward the request to the active controller
+	b, err := ca.findAnyBroker()
+	if err != nil {
+		return err
+	}
+	_ = b.Open(ca.client.Config())
+
+	rsp, err := b.UnregisterBroker(&UnregisterBrokerRequest{BrokerID: brokerID})
+	if err != nil {
+		return err
+	}
+	if rsp.ErrorCode != ErrNoError {
+		if rsp.ErrorMessage != nil {
+			return fmt.Errorf("%w: %s", rsp.ErrorCode, *rsp.ErrorMessage)
+		}
+		return rsp.ErrorCode
+	}
+	return nil
+}
+
 func (ca *clusterAdmin) findBroker(id int32) (*Broker, error) {
 	brokers := ca.client.Brokers()
 	for _, b := range brokers {
@@ -452,6 +1166,13 @@ func (ca *clusterAdmin) CreatePartitions(topic string, count int32, assignment [
 		return ErrInvalidTopic
 	}
 
+	if ca.conf.Admin.RackAwareAssignment && assignment == nil {
+		var err error
+		if assignment, err = ca.newPartitionsAssignment(topic, count); err != nil {
+			return err
+		}
+	}
+
 	topicPartitions := make(map[string]*TopicPartition)
 	topicPartitions[topic] = &TopicPartition{Count: count, Assignment: assignment}
 
@@ -488,129 +1209,1082 @@ func (ca *clusterAdmin) CreatePartitions(topic string, count int32, assignment [
 	})
 }
 
-func (ca *clusterAdmin) AlterPartitionReassignments(topic string, assignment [][]int32) error {
+// TopicChangeReport is the set of changes ApplyTopic made to a topic.
+type TopicChangeReport struct {
+	Topic        string
+	ValidateOnly bool
+	// Created is true when the topic did not exist and was created from the
+	// spec, no other change being reported
+	Created bool
+	// PartitionsFrom and PartitionsTo are the partition count before and after
+	// ApplyTopic, equal when no partitions were added
+	PartitionsFrom int32
+	PartitionsTo   int32
+	// ReplicationFactor is the current replication factor of the topic, which
+	// is left unchanged when it differs from the spec
+	ReplicationFactor        int16
+	ReplicationFactorDiffers bool
+	// ConfigChanges are the config overrides set or deleted, sorted by name
+	ConfigChanges []TopicConfigChange
+}
+
+// HasChanges reports whether ApplyTopic created or altered the topic.
+func (r *TopicChangeReport) HasChanges() bool {
+	return r.Created || r.PartitionsTo != r.PartitionsFrom || len(r.ConfigChanges) > 0
+}
+
+// TopicConfigChange is a config override of a topic set or deleted by
+// ApplyTopic. OldValue is nil when the config was not overridden and NewValue
+// is nil when the override was deleted.
+type TopicConfigChange struct {
+	Name      string
+	Operation IncrementalAlterConfigsOperation
+	OldValue  *string
+	NewValue  *string
+}
+
+func (ca *clusterAdmin) ApplyTopic(topic string, spec *TopicDetail, validateOnly bool) (*TopicChangeReport, error) {
 	if topic == "" {
-		return ErrInvalidTopic
+		return nil, ErrInvalidTopic
 	}
-
-	request := &AlterPartitionReassignmentsRequest{
-		TimeoutMs: int32(60000),
-		Version:   int16(0),
+	if spec == nil {
+		return nil, errors.New("you must specify topic details")
 	}
 
-	for i := 0; i < len(assignment); i++ {
-		request.AddBlock(topic, int32(i), assignment[i])
+	report := &TopicChangeReport{Topic: topic, ValidateOnly: validateOnly}
+
+	metadata, err := ca.DescribeTopics([]string{topic})
+	if err != nil {
+		return nil, err
+	}
+	if len(metadata) != 1 {
+		return nil, ErrIncompleteResponse
+	}
+	switch metadata[0].Err {
+	case ErrNoError:
+	case ErrUnknownTopicOrPartition:
+		if err := ca.CreateTopic(topic, spec, validateOnly); err != nil {
+			return nil, err
+		}
+		report.Created = true
+		report.PartitionsTo = spec.NumPartitions
+		report.ReplicationFactor = spec.ReplicationFactor
+		return report, nil
+	default:
+		return nil, metadata[0].Err
+	}
+
+	report.PartitionsFrom = int32(len(metadata[0].Partitions))
+	report.PartitionsTo = report.PartitionsFrom
+	if len(metadata[0].Partitions) > 0 {
+		report.ReplicationFactor = int16(len(metadata[0].Partitions[0].Replicas))
+	}
+	report.ReplicationFactorDiffers = spec.ReplicationFactor > 0 && spec.ReplicationFactor != report.ReplicationFactor
+
+	if spec.NumPartitions > 0 && spec.NumPartitions < report.PartitionsFrom {
+		return nil, fmt.Errorf("%w: cannot decrease the partitions of %s from %d to %d",
+			ErrInvalidPartitions, topic, report.PartitionsFrom, spec.NumPartitions)
+	}
+
+	if report.ConfigChanges, err = ca.topicConfigChanges(topic, spec.ConfigEntries); err != nil {
+		return nil, err
+	}
+
+	if spec.NumPartitions > report.PartitionsFrom {
+		if err := ca.CreatePartitions(topic, spec.NumPartitions, nil, validateOnly); err != nil {
+			return nil, err
+		}
+		report.PartitionsTo = spec.NumPartitions
+	}
+
+	if len(report.ConfigChanges) > 0 {
+		entries := make(map[string]IncrementalAlterConfigsEntry, len(report.ConfigChanges))
+		for _, change := range report.ConfigChanges {
+			entries[change.Name] = IncrementalAlterConfigsEntry{
+				Operation: change.Operation,
+				Value:     change.NewValue,
+			}
+		}
+		if err := ca.IncrementalAlterConfig(TopicResource, topic, entries, validateOnly); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// topicConfigChanges diffs the config overrides of a topic against the
+// desired ones, a nil desired value meaning the config must not be overridden
+func (ca *clusterAdmin) topicConfigChanges(topic string, desired map[string]*string) ([]TopicConfigChange, error) {
+	current, err := ca.topicConfigOverrides(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []TopicConfigChange
+	for name, value := range desired {
+		if value == nil {
+			continue
+		}
+		entry, ok := current[name]
+		// the value of sensitive configs is never returned, so they are always set
+		if ok && !entry.Sensitive && entry.Value == *value {
+			continue
+		}
+		change := TopicConfigChange{
+			Name:      name,
+			Operation: IncrementalAlterConfigsOperationSet,
+			NewValue:  value,
+		}
+		if ok {
+			oldValue := entry.Value
+			change.OldValue = &oldValue
+		}
+		changes = append(changes, change)
+	}
+	for name, entry := range current {
+		if value, ok := desired[name]; ok && value != nil {
+			continue
+		}
+		oldValue := entry.Value
+		changes = append(changes, TopicConfigChange{
+			Name:      name,
+			Operation: IncrementalAlterConfigsOperationDelete,
+			OldValue:  &oldValue,
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes, nil
+}
+
+// replicaAssignmentBrokers returns the brokers of the cluster along with
+// their rack for AssignReplicas
+func (ca *clusterAdmin) replicaAssignmentBrokers() []ReplicaAssignmentBroker {
+	brokers := ca.client.Brokers()
+	assignmentBrokers := make([]ReplicaAssignmentBroker, 0, len(brokers))
+	for _, b := range brokers {
+		assignmentBrokers = append(assignmentBrokers, ReplicaAssignmentBroker{ID: b.ID(), Rack: b.Rack()})
+	}
+	return assignmentBrokers
+}
+
+// newPartitionsAssignment computes the assignment of the partitions added to
+// a topic to reach count partitions, keeping its replication factor and
+// continuing the assignment of its existing partitions. A nil assignment is
+// returned when no partitions are added, letting the controller reject it
+func (ca *clusterAdmin) newPartitionsAssignment(topic string, count int32) ([][]int32, error) {
+	if err := ca.client.RefreshMetadata(topic); err != nil {
+		return nil, err
+	}
+	partitions, err := ca.client.Partitions(topic)
+	if err != nil {
+		return nil, err
+	}
+	if count <= int32(len(partitions)) {
+		return nil, nil
+	}
+	replicas, err := ca.client.Replicas(topic, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(replicas) == 0 {
+		return nil, ErrReplicaNotAvailable
+	}
+
+	brokers := ca.replicaAssignmentBrokers()
+	ids := make([]int32, 0, len(brokers))
+	for _, broker := range brokers {
+		ids = append(ids, broker.ID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	// start from the leader of the first partition, like the controller does
+	startIndex := sort.Search(len(ids), func(i int) bool { return ids[i] >= replicas[0] })
+	if startIndex == len(ids) || ids[startIndex] != replicas[0] {
+		startIndex = 0
+	}
+
+	return AssignReplicas(brokers, count-int32(len(partitions)), int16(len(replicas)), int32(len(partitions)), startIndex)
+}
+
+// topicConfigOverrides returns the configs set on the topic itself, rather
+// than inherited from the broker defaults
+func (ca *clusterAdmin) topicConfigOverrides(topic string) (map[string]ConfigEntry, error) {
+	entries, err := ca.DescribeConfig(ConfigResource{Type: TopicResource, Name: topic})
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[string]ConfigEntry)
+	for _, entry := range entries {
+		if entry.Source == SourceTopic || (entry.Source == SourceUnknown && !entry.Default) {
+			overrides[entry.Name] = entry
+		}
+	}
+	return overrides, nil
+}
+
+func (ca *clusterAdmin) CloneTopic(source, destination string, numPartitions int32, validateOnly bool) (*TopicDetail, error) {
+	if source == "" || destination == "" {
+		return nil, ErrInvalidTopic
+	}
+
+	metadata, err := ca.DescribeTopics([]string{source})
+	if err != nil {
+		return nil, err
+	}
+	if len(metadata) != 1 {
+		return nil, ErrIncompleteResponse
+	}
+	if metadata[0].Err != ErrNoError {
+		return nil, metadata[0].Err
+	}
+	if len(metadata[0].Partitions) == 0 {
+		return nil, ErrIncompleteResponse
+	}
+
+	overrides, err := ca.topicConfigOverrides(source)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &TopicDetail{
+		NumPartitions:     int32(len(metadata[0].Partitions)),
+		ReplicationFactor: int16(len(metadata[0].Partitions[0].Replicas)),
+		ConfigEntries:     make(map[string]*string, len(overrides)),
+	}
+	if numPartitions > 0 {
+		detail.NumPartitions = numPartitions
+	}
+	for name, entry := range overrides {
+		// the value of sensitive configs is never returned so it cannot be copied
+		if entry.Sensitive || entry.ReadOnly {
+			continue
+		}
+		value := entry.Value
+		detail.ConfigEntries[name] = &value
+	}
+
+	if err := ca.CreateTopic(destination, detail, validateOnly); err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+func (ca *clusterAdmin) AlterPartitionReassignments(topic string, assignment [][]int32) error {
+	if topic == "" {
+		return ErrInvalidTopic
+	}
+
+	request := &AlterPartitionReassignmentsRequest{
+		TimeoutMs: int32(60000),
+		Version:   int16(0),
+	}
+
+	for i := 0; i < len(assignment); i++ {
+		request.AddBlock(topic, int32(i), assignment[i])
+	}
+
+	return ca.retryOnError(isErrNoController, func() error {
+		b, err := ca.Controller()
+		if err != nil {
+			return err
+		}
+
+		errs := make([]error, 0)
+
+		rsp, err := b.AlterPartitionReassignments(request)
+
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			if rsp.ErrorCode == ErrNotController {
+				_, _ = ca.refreshController()
+				return rsp.ErrorCode
+			}
+			if rsp.ErrorCode > 0 {
+				errs = append(errs, errors.New(rsp.ErrorCode.Error()))
+			}
+
+			for topic, topicErrors := range rsp.Errors {
+				for partition, partitionError := range topicErrors {
+					if partitionError.errorCode != ErrNoError {
+						errStr := fmt.Sprintf("[%s-%d]: %s", topic, partition, partitionError.errorCode.Error())
+						errs = append(errs, errors.New(errStr))
+					}
+				}
+			}
+		}
+
+		if len(errs) > 0 {
+			return ErrReassignPartitions{MultiError{&errs}}
+		}
+
+		return nil
+	})
+}
+
+func (ca *clusterAdmin) ListPartitionReassignments(topic string, partitions []int32) (topicStatus map[string]map[int32]*PartitionReplicaReassignmentsStatus, err error) {
+	if topic == "" {
+		return nil, ErrInvalidTopic
+	}
+
+	request := &ListPartitionReassignmentsRequest{
+		TimeoutMs: int32(60000),
+		Version:   int16(0),
+	}
+
+	request.AddBlock(topic, partitions)
+
+	err = ca.retryOnError(isErrNoController, func() error {
+		b, err := ca.Controller()
+		if err != nil {
+			return err
+		}
+		_ = b.Open(ca.client.Config())
+
+		rsp, err := b.ListPartitionReassignments(request)
+		if err != nil {
+			return err
+		}
+
+		if rsp.ErrorCode == ErrNotController {
+			_, _ = ca.refreshController()
+			return rsp.ErrorCode
+		}
+
+		topicStatus = rsp.TopicStatus
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return topicStatus, nil
+}
+
+func (ca *clusterAdmin) ListOngoingPartitionReassignments(partitions map[string][]int32) (map[string]map[int32]*PartitionReplicaReassignmentsStatus, error) {
+	request := &ListPartitionReassignmentsRequest{
+		TimeoutMs: int32(ca.conf.Admin.Timeout / time.Millisecond),
+		Version:   int16(0),
+	}
+
+	if partitions != nil {
+		request.blocks = make(map[string][]int32, len(partitions))
+		for topic, topicPartitions := range partitions {
+			request.AddBlock(topic, topicPartitions)
+		}
+	}
+
+	var topicStatus map[string]map[int32]*PartitionReplicaReassignmentsStatus
+	return topicStatus, ca.retryOnError(isErrNoController, func() error {
+		b, err := ca.Controller()
+		if err != nil {
+			return err
+		}
+		_ = b.Open(ca.client.Config())
+
+		rsp, err := b.ListPartitionReassignments(request)
+		if err != nil {
+			return err
+		}
+
+		if rsp.ErrorCode != ErrNoError {
+			if rsp.ErrorCode == ErrNotController {
+				_, _ = ca.refreshController()
+			}
+			return rsp.ErrorCode
+		}
+
+		topicStatus = rsp.TopicStatus
+		return nil
+	})
+}
+
+// ReassignmentProgress is the progress of the partition reassignments waited
+// for by WaitForReassignment.
+type ReassignmentProgress struct {
+	// Pending holds the status of the reassignments that are still ongoing.
+	Pending map[string]map[int32]*PartitionReplicaReassignmentsStatus
+	// Completed is the number of partitions whose reassignment completed.
+	Completed int
+	// Total is the number of partitions being waited for. When waiting for all
+	// the ongoing reassignments it grows as new reassignments are observed.
+	Total int
+	// ThrottledTopics holds the pending topics that have a leader or follower
+	// replication throttle configured.
+	ThrottledTopics []string
+}
+
+func (ca *clusterAdmin) WaitForReassignment(ctx context.Context, partitions map[string][]int32, progress func(*ReassignmentProgress)) error {
+	return (&contextClusterAdmin{ca: ca}).WaitForReassignment(ctx, partitions, progress)
+}
+
+// waitForReassignment implements WaitForReassignment, polling the progress
+// until the context of the admin is done
+func (ca *clusterAdmin) waitForReassignment(partitions map[string][]int32, progress func(*ReassignmentProgress)) error {
+	ctx := ca.context()
+	ticker := time.NewTicker(ca.conf.Admin.Reassignment.PollInterval)
+	defer ticker.Stop()
+
+	total := 0
+	for _, topicPartitions := range partitions {
+		total += len(topicPartitions)
+	}
+	observed := make(map[string]map[int32]none)
+
+	for {
+		pending, err := ca.ListOngoingPartitionReassignments(partitions)
+		if err != nil {
+			return err
+		}
+
+		numPending := 0
+		for topic, topicStatus := range pending {
+			numPending += len(topicStatus)
+			if partitions != nil {
+				continue
+			}
+			if observed[topic] == nil {
+				observed[topic] = make(map[int32]none)
+			}
+			for partition := range topicStatus {
+				if _, ok := observed[topic][partition]; !ok {
+					observed[topic][partition] = none{}
+					total++
+				}
+			}
+		}
+
+		if progress != nil {
+			p := &ReassignmentProgress{
+				Pending:   pending,
+				Completed: total - numPending,
+				Total:     total,
+			}
+			if p.ThrottledTopics, err = ca.throttledTopics(pending); err != nil {
+				return err
+			}
+			progress(p)
+		}
+
+		if numPending == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// throttledTopics returns the sorted topics of the given reassignments that
+// have a leader or follower replication throttle configured
+func (ca *clusterAdmin) throttledTopics(reassignments map[string]map[int32]*PartitionReplicaReassignmentsStatus) ([]string, error) {
+	var throttled []string
+	for topic := range reassignments {
+		entries, err := ca.DescribeConfig(ConfigResource{
+			Type:        TopicResource,
+			Name:        topic,
+			ConfigNames: []string{"leader.replication.throttled.replicas", "follower.replication.throttled.replicas"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Value != "" {
+				throttled = append(throttled, topic)
+				break
+			}
+		}
+	}
+	sort.Strings(throttled)
+	return throttled, nil
+}
+
+func (ca *clusterAdmin) ElectLeaders(electionType ElectionType, partitions map[string][]int32) (map[string]map[int32]*PartitionResult, error) {
+	request := &ElectLeadersRequest{
+		Type:            electionType,
+		TopicPartitions: partitions,
+		TimeoutMs:       int32(ca.conf.Admin.Timeout / time.Millisecond),
+	}
+
+	if ca.conf.Version.IsAtLeast(V2_4_0_0) {
+		request.Version = 2
+	} else if electionType != PreferredElection {
+		return nil, ConfigurationError("unclean leader election requires Version >= V2_4_0_0")
+	}
+
+	var results map[string]map[int32]*PartitionResult
+	return results, ca.retryOnError(isErrNoController, func() error {
+		b, err := ca.Controller()
+		if err != nil {
+			return err
+		}
+		_ = b.Open(ca.client.Config())
+
+		rsp, err := b.ElectLeaders(request)
+		if err != nil {
+			return err
+		}
+
+		if rsp.ErrorCode != ErrNoError {
+			if rsp.ErrorCode == ErrNotController {
+				_, _ = ca.refreshController()
+			}
+			return rsp.ErrorCode
+		}
+
+		results = rsp.ReplicaElectionResults
+		return nil
+	})
+}
+
+func (ca *clusterAdmin) DeleteRecords(topic string, partitionOffsets map[int32]int64) error {
+	if topic == "" {
+		return ErrInvalidTopic
+	}
+	partitionPerBroker := make(map[*Broker][]int32)
+	for partition := range partitionOffsets {
+		broker, err := ca.client.Leader(topic, partition)
+		if err != nil {
+			return err
+		}
+		partitionPerBroker[broker] = append(partitionPerBroker[broker], partition)
+	}
+	errs := make([]error, 0)
+	for broker, partitions := range partitionPerBroker {
+		topics := make(map[string]*DeleteRecordsRequestTopic)
+		recordsToDelete := make(map[int32]int64)
+		for _, p := range partitions {
+			recordsToDelete[p] = partitionOffsets[p]
+		}
+		topics[topic] = &DeleteRecordsRequestTopic{PartitionOffsets: recordsToDelete}
+		request := &DeleteRecordsRequest{
+			Topics:  topics,
+			Timeout: ca.conf.Admin.Timeout,
+		}
+
+		rsp, err := broker.DeleteRecords(request)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			deleteRecordsResponseTopic, ok := rsp.Topics[topic]
+			if !ok {
+				errs = append(errs, ErrIncompleteResponse)
+			} else {
+				for _, deleteRecordsResponsePartition := range deleteRecordsResponseTopic.Partitions {
+					if deleteRecordsResponsePartition.Err != ErrNoError {
+						errs = append(errs, errors.New(deleteRecordsResponsePartition.Err.Error()))
+					}
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return ErrDeleteRecords{MultiError{&errs}}
+	}
+	// todo since we are dealing with couple of partitions it would be good if we return slice of errors
+	// for each partition instead of one error
+	return nil
+}
+
+// DeleteRecordsResult is the outcome of the deletion of the records of a
+// partition by DeleteRecordsBatch.
+type DeleteRecordsResult struct {
+	// LowWatermark is the new log start offset of the partition
+	LowWatermark int64
+	// Err is nil when the records have been deleted
+	Err error
+}
+
+func (ca *clusterAdmin) DeleteRecordsBatch(offsets map[string]map[int32]int64) (map[string]map[int32]*DeleteRecordsResult, error) {
+	results := make(map[string]map[int32]*DeleteRecordsResult, len(offsets))
+	requests := make(map[*Broker]*DeleteRecordsRequest)
+	for topic, partitionOffsets := range offsets {
+		if topic == "" {
+			return nil, ErrInvalidTopic
+		}
+		results[topic] = make(map[int32]*DeleteRecordsResult, len(partitionOffsets))
+		for partition, offset := range partitionOffsets {
+			broker, err := ca.client.Leader(topic, partition)
+			if err != nil {
+				results[topic][partition] = &DeleteRecordsResult{Err: err}
+				continue
+			}
+
+			request := requests[broker]
+			if request == nil {
+				request = &DeleteRecordsRequest{
+					Topics:  make(map[string]*DeleteRecordsRequestTopic),
+					Timeout: ca.conf.Admin.Timeout,
+				}
+				requests[broker] = request
+			}
+			if request.Topics[topic] == nil {
+				request.Topics[topic] = &DeleteRecordsRequestTopic{PartitionOffsets: make(map[int32]int64)}
+			}
+			request.Topics[topic].PartitionOffsets[partition] = offset
+		}
+	}
+
+	// Send the requests in parallel, one per partition leader
+	wg := sync.WaitGroup{}
+	lock := sync.Mutex{}
+	for broker, request := range requests {
+		wg.Add(1)
+		go func(b *Broker, request *DeleteRecordsRequest) {
+			defer wg.Done()
+			rsp, err := b.DeleteRecords(request)
+
+			lock.Lock()
+			defer lock.Unlock()
+			for topic, requestTopic := range request.Topics {
+				for partition := range requestTopic.PartitionOffsets {
+					results[topic][partition] = deleteRecordsResult(rsp, err, topic, partition)
+				}
+			}
+		}(broker, request)
+	}
+	wg.Wait()
+
+	errs := make([]error, 0)
+	for topic, partitions := range results {
+		for partition, result := range partitions {
+			if result.Err != nil {
+				errs = append(errs, fmt.Errorf("[%s-%d]: %w", topic, partition, result.Err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return results, ErrDeleteRecords{MultiError{&errs}}
+	}
+	return results, nil
+}
+
+// deleteRecordsResult extracts the result of the given partition from the
+// response to a DeleteRecordsRequest
+func deleteRecordsResult(rsp *DeleteRecordsResponse, err error, topic string, partition int32) *DeleteRecordsResult {
+	if err != nil {
+		return &DeleteRecordsResult{Err: err}
+	}
+	rspTopic, ok := rsp.Topics[topic]
+	if !ok {
+		return &DeleteRecordsResult{Err: ErrIncompleteResponse}
+	}
+	rspPartition, ok := rspTopic.Partitions[partition]
+	if !ok {
+		return &DeleteRecordsResult{Err: ErrIncompleteResponse}
+	}
+	result := &DeleteRecordsResult{LowWatermark: rspPartition.LowWatermark}
+	if rspPartition.Err != ErrNoError {
+		result.Err = rspPartition.Err
+	}
+	return result
+}
+
+// ListOffsetsResult is the offset of a partition found by ListOffsets.
+type ListOffsetsResult struct {
+	Offset    int64
+	Timestamp int64
+	// LeaderEpoch is -1 if unknown or not supported by the broker
+	LeaderEpoch int32
+	Err         error
+}
+
+func (ca *clusterAdmin) listOffsetsVersion() int16 {
+	switch {
+	case ca.conf.Version.IsAtLeast(V3_0_0_0):
+		return 7
+	case ca.conf.Version.IsAtLeast(V2_5_0_0):
+		return 6
+	case ca.conf.Version.IsAtLeast(V2_2_0_0):
+		return 5
+	case ca.conf.Version.IsAtLeast(V2_1_0_0):
+		return 4
+	case ca.conf.Version.IsAtLeast(V2_0_0_0):
+		return 3
+	case ca.conf.Version.IsAtLeast(V0_11_0_0):
+		return 2
+	case ca.conf.Version.IsAtLeast(V0_10_1_0):
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (ca *clusterAdmin) ListOffsets(times map[string]map[int32]int64, isolationLevel IsolationLevel) (map[string]map[int32]*ListOffsetsResult, error) {
+	version := ca.listOffsetsVersion()
+	if isolationLevel == ReadCommitted && version < 2 {
+		return nil, ConfigurationError("ReadCommitted isolation level requires Version >= V0_11_0_0")
+	}
+
+	results := make(map[string]map[int32]*ListOffsetsResult, len(times))
+	requests := make(map[*Broker]*OffsetRequest)
+	for topic, partitionTimes := range times {
+		if topic == "" {
+			return nil, ErrInvalidTopic
+		}
+		results[topic] = make(map[int32]*ListOffsetsResult, len(partitionTimes))
+		for partition, time := range partitionTimes {
+			if time == OffsetMaxTimestamp && version < 7 {
+				return nil, ConfigurationError("OffsetMaxTimestamp requires Version >= V3_0_0_0")
+			}
+
+			broker, err := ca.client.Leader(topic, partition)
+			if err != nil {
+				results[topic][partition] = &ListOffsetsResult{Err: err}
+				continue
+			}
+
+			request := requests[broker]
+			if request == nil {
+				request = &OffsetRequest{Version: version, IsolationLevel: isolationLevel}
+				requests[broker] = request
+			}
+			request.AddBlock(topic, partition, time, 1)
+		}
+	}
+
+	// Send the requests in parallel, one per partition leader
+	wg := sync.WaitGroup{}
+	lock := sync.Mutex{}
+	for broker, request := range requests {
+		wg.Add(1)
+		go func(b *Broker, request *OffsetRequest) {
+			defer wg.Done()
+			rsp, err := b.GetAvailableOffsets(request)
+
+			lock.Lock()
+			defer lock.Unlock()
+			for topic, partitions := range request.blocks {
+				for partition := range partitions {
+					results[topic][partition] = listOffsetsResult(rsp, err, topic, partition)
+				}
+			}
+		}(broker, request)
+	}
+	wg.Wait()
+
+	errs := make([]error, 0)
+	for topic, partitions := range results {
+		for partition, result := range partitions {
+			if result.Err != nil {
+				errs = append(errs, fmt.Errorf("[%s-%d]: %w", topic, partition, result.Err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return results, ErrListOffsets{MultiError{&errs}}
+	}
+	return results, nil
+}
+
+// listOffsetsResult extracts the result of the given partition from the
+// response to an OffsetRequest
+func listOffsetsResult(rsp *OffsetResponse, err error, topic string, partition int32) *ListOffsetsResult {
+	if err != nil {
+		return &ListOffsetsResult{Err: err}
+	}
+	block := rsp.GetBlock(topic, partition)
+	if block == nil {
+		return &ListOffsetsResult{Err: ErrIncompleteResponse}
+	}
+	if block.Err != ErrNoError {
+		return &ListOffsetsResult{Err: block.Err}
+	}
+	result := &ListOffsetsResult{
+		Offset:      block.Offset,
+		Timestamp:   block.Timestamp,
+		LeaderEpoch: -1,
+	}
+	if rsp.Version == 0 {
+		if len(block.Offsets) == 0 {
+			return &ListOffsetsResult{Err: ErrIncompleteResponse}
+		}
+		result.Offset = block.Offsets[0]
+		result.Timestamp = -1
+	}
+	if rsp.Version >= 4 {
+		result.LeaderEpoch = block.LeaderEpoch
+	}
+	return result
+}
+
+// DescribeProducersResult holds the active producers of a partition found by
+// DescribeProducers.
+type DescribeProducersResult struct {
+	ActiveProducers []ProducerState
+	Err             error
+}
+
+func (ca *clusterAdmin) DescribeProducers(partitions map[string][]int32) (map[string]map[int32]*DescribeProducersResult, error) {
+	if !ca.conf.Version.IsAtLeast(V2_8_0_0) {
+		return nil, ConfigurationError("DescribeProducers requires Version >= V2_8_0_0")
+	}
+
+	results := make(map[string]map[int32]*DescribeProducersResult, len(partitions))
+	requests := make(map[*Broker]map[string][]int32)
+	for topic, topicPartitions := range partitions {
+		if topic == "" {
+			return nil, ErrInvalidTopic
+		}
+		results[topic] = make(map[int32]*DescribeProducersResult, len(topicPartitions))
+		for _, partition := range topicPartitions {
+			broker, err := ca.client.Leader(topic, partition)
+			if err != nil {
+				results[topic][partition] = &DescribeProducersResult{Err: err}
+				continue
+			}
+			if requests[broker] == nil {
+				requests[broker] = make(map[string][]int32)
+			}
+			requests[broker][topic] = append(requests[broker][topic], partition)
+		}
 	}
 
-	return ca.retryOnError(isErrNoController, func() error {
-		b, err := ca.Controller()
-		if err != nil {
-			return err
+	// Send the requests in parallel, one per partition leader
+	wg := sync.WaitGroup{}
+	lock := sync.Mutex{}
+	for broker, brokerPartitions := range requests {
+		request := &DescribeProducersRequest{}
+		for topic, topicPartitions := range brokerPartitions {
+			request.Topics = append(request.Topics, DescribeProducersRequestTopic{
+				Name:             topic,
+				PartitionIndexes: topicPartitions,
+			})
 		}
 
-		errs := make([]error, 0)
-
-		rsp, err := b.AlterPartitionReassignments(request)
+		wg.Add(1)
+		go func(b *Broker, request *DescribeProducersRequest) {
+			defer wg.Done()
+			rsp, err := b.DescribeProducers(request)
 
-		if err != nil {
-			errs = append(errs, err)
-		} else {
-			if rsp.ErrorCode > 0 {
-				errs = append(errs, errors.New(rsp.ErrorCode.Error()))
+			lock.Lock()
+			defer lock.Unlock()
+			for _, topic := range request.Topics {
+				for _, partition := range topic.PartitionIndexes {
+					results[topic.Name][partition] = describeProducersResult(rsp, err, topic.Name, partition)
+				}
 			}
+		}(broker, request)
+	}
+	wg.Wait()
 
-			for topic, topicErrors := range rsp.Errors {
-				for partition, partitionError := range topicErrors {
-					if partitionError.errorCode != ErrNoError {
-						errStr := fmt.Sprintf("[%s-%d]: %s", topic, partition, partitionError.errorCode.Error())
-						errs = append(errs, errors.New(errStr))
-					}
-				}
+	errs := make([]error, 0)
+	for topic, partitions := range results {
+		for partition, result := range partitions {
+			if result.Err != nil {
+				errs = append(errs, fmt.Errorf("[%s-%d]: %w", topic, partition, result.Err))
 			}
 		}
+	}
+	if len(errs) > 0 {
+		return results, ErrDescribeProducers{MultiError{&errs}}
+	}
+	return results, nil
+}
 
-		if len(errs) > 0 {
-			return ErrReassignPartitions{MultiError{&errs}}
+// describeProducersResult extracts the result of the given partition from the
+// response to a DescribeProducersRequest
+func describeProducersResult(rsp *DescribeProducersResponse, err error, topic string, partition int32) *DescribeProducersResult {
+	if err != nil {
+		return &DescribeProducersResult{Err: err}
+	}
+	for _, rspTopic := range rsp.Topics {
+		if rspTopic.Name != topic {
+			continue
+		}
+		for _, rspPartition := range rspTopic.Partitions {
+			if rspPartition.PartitionIndex != partition {
+				continue
+			}
+			if rspPartition.ErrorCode != ErrNoError {
+				return &DescribeProducersResult{Err: rspPartition.ErrorCode}
+			}
+			return &DescribeProducersResult{ActiveProducers: rspPartition.ActiveProducers}
 		}
+	}
+	return &DescribeProducersResult{Err: ErrIncompleteResponse}
+}
 
-		return nil
-	})
+// TransactionListing is a transaction found by ListTransactions.
+type TransactionListing struct {
+	TransactionalID string
+	ProducerID      int64
+	State           string
+	// CoordinatorID is the ID of the broker coordinating the transaction
+	CoordinatorID int32
 }
 
-func (ca *clusterAdmin) ListPartitionReassignments(topic string, partitions []int32) (topicStatus map[string]map[int32]*PartitionReplicaReassignmentsStatus, err error) {
-	if topic == "" {
-		return nil, ErrInvalidTopic
+func (ca *clusterAdmin) ListTransactions(stateFilters []string, producerIDFilters []int64) ([]*TransactionListing, error) {
+	if !ca.conf.Version.IsAtLeast(V3_0_0_0) {
+		return nil, ConfigurationError("ListTransactions requires Version >= V3_0_0_0")
 	}
 
-	request := &ListPartitionReassignmentsRequest{
-		TimeoutMs: int32(60000),
-		Version:   int16(0),
+	// Query brokers in parallel, since every broker coordinates some transactions
+	brokers := ca.client.Brokers()
+	listings := make(chan []*TransactionListing, len(brokers))
+	errChan := make(chan error, len(brokers))
+	wg := sync.WaitGroup{}
+
+	for _, b := range brokers {
+		wg.Add(1)
+		go func(b *Broker, conf *Config) {
+			defer wg.Done()
+			_ = b.Open(conf) // Ensure that broker is opened
+
+			response, err := b.ListTransactions(&ListTransactionsRequest{
+				StateFilters:      stateFilters,
+				ProducerIDFilters: producerIDFilters,
+			})
+			if err != nil {
+				errChan <- err
+				return
+			}
+			if response.ErrorCode != ErrNoError {
+				errChan <- response.ErrorCode
+				return
+			}
+
+			brokerListings := make([]*TransactionListing, 0, len(response.TransactionStates))
+			for _, state := range response.TransactionStates {
+				brokerListings = append(brokerListings, &TransactionListing{
+					TransactionalID: state.TransactionalID,
+					ProducerID:      state.ProducerID,
+					State:           state.TransactionState,
+					CoordinatorID:   b.ID(),
+				})
+			}
+			listings <- brokerListings
+		}(b, ca.conf)
 	}
 
-	request.AddBlock(topic, partitions)
+	wg.Wait()
+	close(listings)
+	close(errChan)
 
-	b, err := ca.Controller()
+	var result []*TransactionListing
+	for brokerListings := range listings {
+		result = append(result, brokerListings...)
+	}
+
+	// Intentionally return only the first error for simplicity
+	return result, <-errChan
+}
+
+// transactionCoordinator returns the coordinator of the given transactional ID
+func (ca *clusterAdmin) transactionCoordinator(transactionalID string) (*Broker, error) {
+	b, err := ca.findAnyBroker()
 	if err != nil {
 		return nil, err
 	}
 	_ = b.Open(ca.client.Config())
 
-	rsp, err := b.ListPartitionReassignments(request)
-
-	if err == nil && rsp != nil {
-		return rsp.TopicStatus, nil
-	} else {
+	rsp, err := b.FindCoordinator(&FindCoordinatorRequest{
+		Version:         1,
+		CoordinatorKey:  transactionalID,
+		CoordinatorType: CoordinatorTransaction,
+	})
+	if err != nil {
 		return nil, err
 	}
+	if rsp.Err != ErrNoError {
+		return nil, rsp.Err
+	}
+	return ca.client.Broker(rsp.Coordinator.ID())
 }
 
-func (ca *clusterAdmin) DeleteRecords(topic string, partitionOffsets map[int32]int64) error {
-	if topic == "" {
-		return ErrInvalidTopic
+func (ca *clusterAdmin) DescribeTransactions(transactionalIDs []string) ([]*TransactionDescription, error) {
+	if !ca.conf.Version.IsAtLeast(V3_0_0_0) {
+		return nil, ConfigurationError("DescribeTransactions requires Version >= V3_0_0_0")
 	}
-	partitionPerBroker := make(map[*Broker][]int32)
-	for partition := range partitionOffsets {
-		broker, err := ca.client.Leader(topic, partition)
+
+	idsPerBroker := make(map[*Broker][]string)
+	for _, transactionalID := range transactionalIDs {
+		coordinator, err := ca.transactionCoordinator(transactionalID)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		partitionPerBroker[broker] = append(partitionPerBroker[broker], partition)
+		idsPerBroker[coordinator] = append(idsPerBroker[coordinator], transactionalID)
 	}
-	errs := make([]error, 0)
-	for broker, partitions := range partitionPerBroker {
-		topics := make(map[string]*DeleteRecordsRequestTopic)
-		recordsToDelete := make(map[int32]int64)
-		for _, p := range partitions {
-			recordsToDelete[p] = partitionOffsets[p]
-		}
-		topics[topic] = &DeleteRecordsRequestTopic{PartitionOffsets: recordsToDelete}
-		request := &DeleteRecordsRequest{
-			Topics:  topics,
-			Timeout: ca.conf.Admin.Timeout,
-		}
 
-		rsp, err := broker.DeleteRecords(request)
+	var result []*TransactionDescription
+	for broker, brokerIDs := range idsPerBroker {
+		response, err := broker.DescribeTransactions(&DescribeTransactionsRequest{
+			TransactionalIDs: brokerIDs,
+		})
 		if err != nil {
-			errs = append(errs, err)
-		} else {
-			deleteRecordsResponseTopic, ok := rsp.Topics[topic]
-			if !ok {
-				errs = append(errs, ErrIncompleteResponse)
-			} else {
-				for _, deleteRecordsResponsePartition := range deleteRecordsResponseTopic.Partitions {
-					if deleteRecordsResponsePartition.Err != ErrNoError {
-						errs = append(errs, errors.New(deleteRecordsResponsePartition.Err.Error()))
-					}
+			return nil, err
+		}
+
+		result = append(result, response.TransactionStates...)
+	}
+	return result, nil
+}
+
+// AbortTransactionSpec identifies the transaction of a producer to abort on a
+// partition.
+type AbortTransactionSpec struct {
+	Topic            string
+	Partition        int32
+	ProducerID       int64
+	ProducerEpoch    int16
+	CoordinatorEpoch int32
+}
+
+func (ca *clusterAdmin) AbortTransaction(spec AbortTransactionSpec) error {
+	if !ca.conf.Version.IsAtLeast(V0_11_0_0) {
+		return ConfigurationError("AbortTransaction requires Version >= V0_11_0_0")
+	}
+	if spec.Topic == "" {
+		return ErrInvalidTopic
+	}
+
+	broker, err := ca.client.Leader(spec.Topic, spec.Partition)
+	if err != nil {
+		return err
+	}
+
+	rsp, err := broker.WriteTxnMarkers(&WriteTxnMarkersRequest{
+		Markers: []WritableTxnMarker{{
+			ProducerID:        spec.ProducerID,
+			ProducerEpoch:     spec.ProducerEpoch,
+			TransactionResult: false,
+			Topics: []WritableTxnMarkerTopic{
+				{Name: spec.Topic, PartitionIndexes: []int32{spec.Partition}},
+			},
+			CoordinatorEpoch: spec.CoordinatorEpoch,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, marker := range rsp.Markers {
+		if marker.ProducerID != spec.ProducerID {
+			continue
+		}
+		for _, topic := range marker.Topics {
+			if topic.Name != spec.Topic {
+				continue
+			}
+			for _, partition := range topic.Partitions {
+				if partition.PartitionIndex != spec.Partition {
+					continue
+				}
+				if partition.ErrorCode != ErrNoError {
+					return partition.ErrorCode
 				}
+				return nil
 			}
 		}
 	}
-	if len(errs) > 0 {
-		return ErrDeleteRecords{MultiError{&errs}}
-	}
-	// todo since we are dealing with couple of partitions it would be good if we return slice of errors
-	// for each partition instead of one error
-	return nil
+	return ErrIncompleteResponse
 }
 
 // Returns a bool indicating whether the resource request needs to go to a
@@ -626,7 +2300,9 @@ func (ca *clusterAdmin) DescribeConfig(resource ConfigResource) ([]ConfigEntry,
 	resources = append(resources, &resource)
 
 	request := &DescribeConfigsRequest{
-		Resources: resources,
+		Resources:            resources,
+		IncludeSynonyms:      true,
+		IncludeDocumentation: true,
 	}
 
 	if ca.conf.Version.IsAtLeast(V1_1_0_0) {
@@ -637,6 +2313,14 @@ func (ca *clusterAdmin) DescribeConfig(resource ConfigResource) ([]ConfigEntry,
 		request.Version = 2
 	}
 
+	if ca.conf.Version.IsAtLeast(V2_6_0_0) {
+		request.Version = 3
+	}
+
+	if ca.conf.Version.IsAtLeast(V2_8_0_0) {
+		request.Version = 4
+	}
+
 	var (
 		b   *Broker
 		err error
@@ -697,44 +2381,168 @@ func (ca *clusterAdmin) AlterConfig(resourceType ConfigResourceType, name string
 		err error
 	)
 
-	// AlterConfig of broker/broker logger must be sent to the broker in question
-	if dependsOnSpecificNode(ConfigResource{Name: name, Type: resourceType}) {
-		var id int64
-		id, err = strconv.ParseInt(name, 10, 32)
-		if err != nil {
-			return err
-		}
-		b, err = ca.findBroker(int32(id))
-	} else {
-		b, err = ca.findAnyBroker()
+	// AlterConfig of broker/broker logger must be sent to the broker in question
+	if dependsOnSpecificNode(ConfigResource{Name: name, Type: resourceType}) {
+		var id int64
+		id, err = strconv.ParseInt(name, 10, 32)
+		if err != nil {
+			return err
+		}
+		b, err = ca.findBroker(int32(id))
+	} else {
+		b, err = ca.findAnyBroker()
+	}
+	if err != nil {
+		return err
+	}
+
+	_ = b.Open(ca.client.Config())
+	rsp, err := b.AlterConfigs(request)
+	if err != nil {
+		return err
+	}
+
+	for _, rspResource := range rsp.Resources {
+		if rspResource.Name == name {
+			if rspResource.ErrorMsg != "" {
+				return errors.New(rspResource.ErrorMsg)
+			}
+			if rspResource.ErrorCode != 0 {
+				return KError(rspResource.ErrorCode)
+			}
+		}
+	}
+	return nil
+}
+
+func (ca *clusterAdmin) IncrementalAlterConfig(resourceType ConfigResourceType, name string, entries map[string]IncrementalAlterConfigsEntry, validateOnly bool) error {
+	var resources []*IncrementalAlterConfigsResource
+	resources = append(resources, &IncrementalAlterConfigsResource{
+		Type:          resourceType,
+		Name:          name,
+		ConfigEntries: entries,
+	})
+
+	request := &IncrementalAlterConfigsRequest{
+		Resources:    resources,
+		ValidateOnly: validateOnly,
+	}
+
+	var (
+		b   *Broker
+		err error
+	)
+
+	// IncrementalAlterConfig of broker/broker logger must be sent to the broker in question
+	if dependsOnSpecificNode(ConfigResource{Name: name, Type: resourceType}) {
+		var id int64
+		id, err = strconv.ParseInt(name, 10, 32)
+		if err != nil {
+			return err
+		}
+		b, err = ca.findBroker(int32(id))
+	} else {
+		b, err = ca.findAnyBroker()
+	}
+	if err != nil {
+		return err
+	}
+
+	_ = b.Open(ca.client.Config())
+	rsp, err := b.IncrementalAlterConfigs(request)
+	if err != nil {
+		return err
+	}
+
+	for _, rspResource := range rsp.Resources {
+		if rspResource.Name == name {
+			if rspResource.ErrorMsg != "" {
+				return errors.New(rspResource.ErrorMsg)
+			}
+			if rspResource.ErrorCode != 0 {
+				return KError(rspResource.ErrorCode)
+			}
+		}
+	}
+	return nil
+}
+
+func (ca *clusterAdmin) CreateACL(resource Resource, acl Acl) error {
+	results, err := ca.CreateACLs([]AclCreation{{resource, acl}})
+	if err != nil {
+		return err
+	}
+	return results[0].Err
+}
+
+// AclCreationResult is the result of the creation of an ACL binding by
+// CreateACLs, Err being nil when it succeeded.
+type AclCreationResult struct {
+	AclCreation
+	Err error
+}
+
+// AclDeletionResult is the result of the deletion of the ACLs matching a
+// filter by DeleteACLs, Err being nil when it succeeded. The deletion of each
+// matching ACL may still have failed, as reported by its own Err.
+type AclDeletionResult struct {
+	Filter       AclFilter
+	Err          error
+	MatchingAcls []MatchingAcl
+}
+
+// aclError returns the error of an ACL operation along with its message, nil
+// when it succeeded
+func aclError(kerr KError, msg *string) error {
+	if kerr == ErrNoError {
+		return nil
+	}
+	if msg != nil && *msg != "" {
+		return fmt.Errorf("%w: %s", kerr, *msg)
+	}
+	return kerr
+}
+
+func (ca *clusterAdmin) CreateACLs(bindings []AclCreation) ([]AclCreationResult, error) {
+	request := &CreateAclsRequest{AclCreations: make([]*AclCreation, len(bindings))}
+	for i := range bindings {
+		binding := bindings[i]
+		request.AclCreations[i] = &binding
+	}
+
+	if ca.conf.Version.IsAtLeast(V2_0_0_0) {
+		request.Version = 1
 	}
+
+	b, err := ca.Controller()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_ = b.Open(ca.client.Config())
-	rsp, err := b.AlterConfigs(request)
+	rsp, err := b.CreateAcls(request)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if len(rsp.AclCreationResponses) != len(bindings) {
+		return nil, ErrIncompleteResponse
 	}
 
-	for _, rspResource := range rsp.Resources {
-		if rspResource.Name == name {
-			if rspResource.ErrorMsg != "" {
-				return errors.New(rspResource.ErrorMsg)
-			}
-			if rspResource.ErrorCode != 0 {
-				return KError(rspResource.ErrorCode)
-			}
+	results := make([]AclCreationResult, len(bindings))
+	for i, creation := range rsp.AclCreationResponses {
+		results[i] = AclCreationResult{
+			AclCreation: bindings[i],
+			Err:         aclError(creation.Err, creation.ErrMsg),
 		}
 	}
-	return nil
+	return results, nil
 }
 
-func (ca *clusterAdmin) CreateACL(resource Resource, acl Acl) error {
-	var acls []*AclCreation
-	acls = append(acls, &AclCreation{resource, acl})
-	request := &CreateAclsRequest{AclCreations: acls}
+func (ca *clusterAdmin) DeleteACLs(filters []AclFilter) ([]AclDeletionResult, error) {
+	request := &DeleteAclsRequest{Filters: make([]*AclFilter, len(filters))}
+	for i := range filters {
+		filter := filters[i]
+		request.Filters[i] = &filter
+	}
 
 	if ca.conf.Version.IsAtLeast(V2_0_0_0) {
 		request.Version = 1
@@ -742,11 +2550,28 @@ func (ca *clusterAdmin) CreateACL(resource Resource, acl Acl) error {
 
 	b, err := ca.Controller()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = b.CreateAcls(request)
-	return err
+	rsp, err := b.DeleteAcls(request)
+	if err != nil {
+		return nil, err
+	}
+	if len(rsp.FilterResponses) != len(filters) {
+		return nil, ErrIncompleteResponse
+	}
+
+	results := make([]AclDeletionResult, len(filters))
+	for i, fr := range rsp.FilterResponses {
+		results[i] = AclDeletionResult{
+			Filter: filters[i],
+			Err:    aclError(fr.Err, fr.ErrMsg),
+		}
+		for _, matching := range fr.MatchingAcls {
+			results[i].MatchingAcls = append(results[i].MatchingAcls, *matching)
+		}
+	}
+	return results, nil
 }
 
 func (ca *clusterAdmin) ListAcls(filter AclFilter) ([]ResourceAcls, error) {
@@ -802,6 +2627,46 @@ func (ca *clusterAdmin) DeleteACL(filter AclFilter, validateOnly bool) ([]Matchi
 }
 
 func (ca *clusterAdmin) DescribeConsumerGroups(groups []string) (result []*GroupDescription, err error) {
+	// groups whose coordinator moved or is loading them are described again
+	// until Admin.Retry.Max is reached, after which their error is returned in
+	// their description
+	descriptions := make(map[string]*GroupDescription, len(groups))
+	pending := groups
+	described := false
+	err = ca.retryOnError(isErrCoordinatorRetriable, func() error {
+		described = false
+		response, err := ca.describeConsumerGroups(pending)
+		if err != nil {
+			return err
+		}
+		described = true
+
+		pending = nil
+		var retriableErr error
+		for _, description := range response {
+			descriptions[description.GroupId] = description
+			if isErrCoordinatorRetriable(description.Err) {
+				_ = ca.client.RefreshCoordinator(description.GroupId)
+				pending = append(pending, description.GroupId)
+				retriableErr = description.Err
+			}
+		}
+		return retriableErr
+	})
+	if err != nil && !described {
+		return nil, err
+	}
+
+	for _, group := range groups {
+		if description, ok := descriptions[group]; ok {
+			result = append(result, description)
+			delete(descriptions, group)
+		}
+	}
+	return result, nil
+}
+
+func (ca *clusterAdmin) describeConsumerGroups(groups []string) (result []*GroupDescription, err error) {
 	groupsPerBroker := make(map[*Broker][]string)
 
 	for _, group := range groups {
@@ -825,12 +2690,61 @@ func (ca *clusterAdmin) DescribeConsumerGroups(groups []string) (result []*Group
 	return result, nil
 }
 
+// ConsumerGroupListing is a group listed by ListConsumerGroupsWithFilters.
+type ConsumerGroupListing struct {
+	GroupID      string
+	ProtocolType string
+	// State is empty for brokers older than 2.6.0.0
+	State string
+	// Type is empty for brokers older than 3.8.0.0
+	Type string
+}
+
+func (ca *clusterAdmin) listGroupsVersion() int16 {
+	switch {
+	case ca.conf.Version.IsAtLeast(V3_8_0_0):
+		return 5
+	case ca.conf.Version.IsAtLeast(V2_6_0_0):
+		return 4
+	case ca.conf.Version.IsAtLeast(V2_4_0_0):
+		return 3
+	case ca.conf.Version.IsAtLeast(V2_0_0_0):
+		return 2
+	case ca.conf.Version.IsAtLeast(V0_11_0_0):
+		return 1
+	default:
+		return 0
+	}
+}
+
 func (ca *clusterAdmin) ListConsumerGroups() (allGroups map[string]string, err error) {
-	allGroups = make(map[string]string)
+	listings, err := ca.ListConsumerGroupsWithFilters(nil, nil)
+
+	allGroups = make(map[string]string, len(listings))
+	for group, listing := range listings {
+		allGroups[group] = listing.ProtocolType
+	}
+	return allGroups, err
+}
+
+func (ca *clusterAdmin) ListConsumerGroupsWithFilters(states []string, types []string) (allGroups map[string]*ConsumerGroupListing, err error) {
+	request := &ListGroupsRequest{
+		Version:      ca.listGroupsVersion(),
+		StatesFilter: states,
+		TypesFilter:  types,
+	}
+	if len(states) > 0 && request.Version < 4 {
+		return nil, ConfigurationError("filtering consumer groups by state requires Version >= V2_6_0_0")
+	}
+	if len(types) > 0 && request.Version < 5 {
+		return nil, ConfigurationError("filtering consumer groups by type requires Version >= V3_8_0_0")
+	}
+
+	allGroups = make(map[string]*ConsumerGroupListing)
 
 	// Query brokers in parallel, since we have to query *all* brokers
 	brokers := ca.client.Brokers()
-	groupMaps := make(chan map[string]string, len(brokers))
+	groupMaps := make(chan map[string]*ConsumerGroupListing, len(brokers))
 	errChan := make(chan error, len(brokers))
 	wg := sync.WaitGroup{}
 
@@ -840,15 +2754,21 @@ func (ca *clusterAdmin) ListConsumerGroups() (allGroups map[string]string, err e
 			defer wg.Done()
 			_ = b.Open(conf) // Ensure that broker is opened
 
-			response, err := b.ListGroups(&ListGroupsRequest{})
+			response, err := b.ListGroups(request)
 			if err != nil {
 				errChan <- err
 				return
 			}
 
-			groups := make(map[string]string)
+			groups := make(map[string]*ConsumerGroupListing)
 			for group, typ := range response.Groups {
-				groups[group] = typ
+				data := response.GroupsData[group]
+				groups[group] = &ConsumerGroupListing{
+					GroupID:      group,
+					ProtocolType: typ,
+					State:        data.GroupState,
+					Type:         data.GroupType,
+				}
 			}
 
 			groupMaps <- groups
@@ -860,8 +2780,8 @@ func (ca *clusterAdmin) ListConsumerGroups() (allGroups map[string]string, err e
 	close(errChan)
 
 	for groupMap := range groupMaps {
-		for group, protocolType := range groupMap {
-			allGroups[group] = protocolType
+		for group, listing := range groupMap {
+			allGroups[group] = listing
 		}
 	}
 
@@ -871,11 +2791,6 @@ func (ca *clusterAdmin) ListConsumerGroups() (allGroups map[string]string, err e
 }
 
 func (ca *clusterAdmin) ListConsumerGroupOffsets(group string, topicPartitions map[string][]int32) (*OffsetFetchResponse, error) {
-	coordinator, err := ca.client.Coordinator(group)
-	if err != nil {
-		return nil, err
-	}
-
 	request := &OffsetFetchRequest{
 		ConsumerGroup: group,
 		partitions:    topicPartitions,
@@ -887,62 +2802,285 @@ func (ca *clusterAdmin) ListConsumerGroupOffsets(group string, topicPartitions m
 		request.Version = 1
 	}
 
-	return coordinator.FetchOffset(request)
+	var response *OffsetFetchResponse
+	err := ca.retryOnCoordinatorError(group, func(coordinator *Broker) (err error) {
+		response, err = coordinator.FetchOffset(request)
+		if err != nil {
+			response = nil
+			return err
+		}
+		if response.Err != ErrNoError {
+			return response.Err
+		}
+		// versions older than 2 only have errors on the partitions
+		for _, partitions := range response.Blocks {
+			for _, block := range partitions {
+				if isErrCoordinatorRetriable(block.Err) {
+					return block.Err
+				}
+			}
+		}
+		return nil
+	})
+	if response == nil {
+		return nil, err
+	}
+	if isErrCoordinatorRetriable(err) {
+		// the retries ran out before the coordinator answered for the group
+		return response, err
+	}
+	// other errors of the response itself are left for the caller to inspect
+	return response, nil
 }
 
 func (ca *clusterAdmin) DeleteConsumerGroupOffset(group string, topic string, partition int32) error {
-	coordinator, err := ca.client.Coordinator(group)
+	errs, err := ca.DeleteConsumerGroupOffsets(group, map[string][]int32{
+		topic: {partition},
+	})
 	if err != nil {
 		return err
 	}
 
+	if errs[topic][partition] != ErrNoError {
+		return errs[topic][partition]
+	}
+	return nil
+}
+
+func (ca *clusterAdmin) DeleteConsumerGroupOffsets(group string, partitions map[string][]int32) (map[string]map[int32]KError, error) {
 	request := &DeleteOffsetsRequest{
 		Group: group,
-		partitions: map[string][]int32{
-			topic: {partition},
-		},
+	}
+	for topic, topicPartitions := range partitions {
+		for _, partition := range topicPartitions {
+			request.AddPartition(topic, partition)
+		}
 	}
 
-	resp, err := coordinator.DeleteOffsets(request)
+	var resp *DeleteOffsetsResponse
+	err := ca.retryOnCoordinatorError(group, func(coordinator *Broker) (err error) {
+		if resp, err = coordinator.DeleteOffsets(request); err != nil {
+			return err
+		}
+		if resp.ErrorCode != ErrNoError {
+			return resp.ErrorCode
+		}
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if resp.ErrorCode != ErrNoError {
-		return resp.ErrorCode
+	for topic, topicPartitions := range partitions {
+		for _, partition := range topicPartitions {
+			if _, ok := resp.Errors[topic][partition]; !ok {
+				return resp.Errors, ErrIncompleteResponse
+			}
+		}
 	}
+	return resp.Errors, nil
+}
 
-	if resp.Errors[topic][partition] != ErrNoError {
-		return resp.Errors[topic][partition]
+func (ca *clusterAdmin) offsetCommitVersion() int16 {
+	switch {
+	case ca.conf.Version.IsAtLeast(V2_4_0_0):
+		return 8
+	case ca.conf.Version.IsAtLeast(V2_3_0_0):
+		return 7
+	case ca.conf.Version.IsAtLeast(V2_1_0_0):
+		return 6
+	case ca.conf.Version.IsAtLeast(V2_0_0_0):
+		return 4
+	case ca.conf.Version.IsAtLeast(V0_11_0_0):
+		return 3
+	default:
+		return 2
 	}
-	return nil
 }
 
-func (ca *clusterAdmin) DeleteConsumerGroup(group string) error {
-	coordinator, err := ca.client.Coordinator(group)
+func (ca *clusterAdmin) AlterConsumerGroupOffsets(group string, offsets map[string]map[int32]int64) (map[string]map[int32]KError, error) {
+	return ca.alterConsumerGroupOffsets(group, offsets, nil)
+}
+
+// alterConsumerGroupOffsets commits the given offsets on behalf of a group
+// without members, along with their leader epoch when known
+func (ca *clusterAdmin) alterConsumerGroupOffsets(group string, offsets map[string]map[int32]int64, leaderEpochs map[string]map[int32]int32) (map[string]map[int32]KError, error) {
+	if !ca.conf.Version.IsAtLeast(V0_9_0_0) {
+		return nil, ConfigurationError("AlterConsumerGroupOffsets requires Version >= V0_9_0_0")
+	}
+
+	request := &OffsetCommitRequest{
+		Version:                 ca.offsetCommitVersion(),
+		ConsumerGroup:           group,
+		ConsumerGroupGeneration: GroupGenerationUndefined,
+	}
+	if request.Version <= 4 {
+		// use the retention configured on the brokers
+		request.RetentionTime = -1
+	}
+	for topic, partitionOffsets := range offsets {
+		for partition, offset := range partitionOffsets {
+			leaderEpoch, ok := leaderEpochs[topic][partition]
+			if !ok {
+				leaderEpoch = -1
+			}
+			request.AddBlockWithLeaderEpoch(topic, partition, offset, leaderEpoch, 0, "")
+		}
+	}
+
+	// committing the same offsets again is harmless, so the whole request is
+	// retried when any partition reports a coordinator error
+	var resp *OffsetCommitResponse
+	err := ca.retryOnCoordinatorError(group, func(coordinator *Broker) (err error) {
+		resp, err = coordinator.CommitOffset(request)
+		if err != nil {
+			resp = nil
+			return err
+		}
+		for topic, partitionOffsets := range offsets {
+			for partition := range partitionOffsets {
+				kerr, ok := resp.Errors[topic][partition]
+				if !ok {
+					return ErrIncompleteResponse
+				}
+				if isErrCoordinatorRetriable(kerr) {
+					return kerr
+				}
+			}
+		}
+		return nil
+	})
+	if resp == nil {
+		return nil, err
+	}
+	// err is either ErrIncompleteResponse or the coordinator error the
+	// retries ran out on
+	return resp.Errors, err
+}
+
+func (ca *clusterAdmin) ResetConsumerGroupOffsets(group string, times map[string]map[int32]int64) (map[string]map[int32]KError, error) {
+	results, err := ca.ListOffsets(times, ReadUncommitted)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	offsets := make(map[string]map[int32]int64, len(results))
+	leaderEpochs := make(map[string]map[int32]int32, len(results))
+	for topic, partitions := range results {
+		offsets[topic] = make(map[int32]int64, len(partitions))
+		leaderEpochs[topic] = make(map[int32]int32, len(partitions))
+		for partition, result := range partitions {
+			offsets[topic][partition] = result.Offset
+			leaderEpochs[topic][partition] = result.LeaderEpoch
+		}
 	}
+	return ca.alterConsumerGroupOffsets(group, offsets, leaderEpochs)
+}
 
+func (ca *clusterAdmin) DeleteConsumerGroup(group string) error {
 	request := &DeleteGroupsRequest{
 		Groups: []string{group},
 	}
 
-	resp, err := coordinator.DeleteGroups(request)
+	return ca.retryOnCoordinatorError(group, func(coordinator *Broker) error {
+		resp, err := coordinator.DeleteGroups(request)
+		if err != nil {
+			return err
+		}
+
+		groupErr, ok := resp.GroupErrorCodes[group]
+		if !ok {
+			return ErrIncompleteResponse
+		}
+
+		if groupErr != ErrNoError {
+			return groupErr
+		}
+
+		return nil
+	})
+}
+
+func (ca *clusterAdmin) RemoveMembersFromConsumerGroup(group string, groupInstanceIDs []string) (map[string]KError, error) {
+	members := make([]MemberIdentity, 0, len(groupInstanceIDs))
+	for i := range groupInstanceIDs {
+		members = append(members, MemberIdentity{GroupInstanceId: &groupInstanceIDs[i]})
+	}
+	return ca.removeMembersFromConsumerGroup(group, members)
+}
+
+func (ca *clusterAdmin) RemoveAllMembersFromConsumerGroup(group string) (map[string]KError, error) {
+	if !ca.conf.Version.IsAtLeast(V2_4_0_0) {
+		return nil, ConfigurationError("RemoveAllMembersFromConsumerGroup requires Version >= V2_4_0_0")
+	}
+
+	groups, err := ca.DescribeConsumerGroups([]string{group})
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if len(groups) != 1 {
+		return nil, ErrIncompleteResponse
+	}
+	if groups[0].Err != ErrNoError {
+		return nil, groups[0].Err
 	}
 
-	groupErr, ok := resp.GroupErrorCodes[group]
-	if !ok {
-		return ErrIncompleteResponse
+	members := make([]MemberIdentity, 0, len(groups[0].Members))
+	for memberID := range groups[0].Members {
+		members = append(members, MemberIdentity{MemberId: memberID})
 	}
+	if len(members) == 0 {
+		return map[string]KError{}, nil
+	}
+	return ca.removeMembersFromConsumerGroup(group, members)
+}
 
-	if groupErr != ErrNoError {
-		return groupErr
+// removeMembersFromConsumerGroup sends a single LeaveGroup request for the
+// given members, returning their errors keyed by group instance ID, or member
+// ID for the members without one
+func (ca *clusterAdmin) removeMembersFromConsumerGroup(group string, members []MemberIdentity) (map[string]KError, error) {
+	if !ca.conf.Version.IsAtLeast(V2_4_0_0) {
+		return nil, ConfigurationError("RemoveMembersFromConsumerGroup requires Version >= V2_4_0_0")
 	}
 
-	return nil
+	var resp *LeaveGroupResponse
+	err := ca.retryOnCoordinatorError(group, func(coordinator *Broker) (err error) {
+		resp, err = coordinator.LeaveGroup(&LeaveGroupRequest{
+			Version: 4,
+			GroupId: group,
+			Members: members,
+		})
+		if err != nil {
+			return err
+		}
+		if resp.Err != ErrNoError {
+			return resp.Err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	errs := make(map[string]KError, len(resp.Members))
+	for _, member := range resp.Members {
+		id := member.MemberId
+		if member.GroupInstanceId != nil {
+			id = *member.GroupInstanceId
+		}
+		errs[id] = member.Err
+	}
+
+	for _, member := range members {
+		id := member.MemberId
+		if member.GroupInstanceId != nil {
+			id = *member.GroupInstanceId
+		}
+		if _, ok := errs[id]; !ok {
+			return errs, ErrIncompleteResponse
+		}
+	}
+	return errs, nil
 }
 
 func (ca *clusterAdmin) DescribeLogDirs(brokerIds []int32) (allLogDirs map[int32][]DescribeLogDirsResponseDirMetadata, err error) {
@@ -957,7 +3095,7 @@ func (ca *clusterAdmin) DescribeLogDirs(brokerIds []int32) (allLogDirs map[int32
 		wg.Add(1)
 		broker, err := ca.findBroker(b)
 		if err != nil {
-			Logger.Printf("Unable to find broker with ID = %v\n", b)
+			logEntry(LogComponentAdmin, LogLevelWarn, "admin/brokers unable to find broker", brokerField(b))
 			continue
 		}
 		go func(b *Broker, conf *Config) {
@@ -1008,6 +3146,13 @@ func (ca *clusterAdmin) DescribeUserScramCredentials(users []string) ([]*Describ
 		return nil, err
 	}
 
+	if rsp.ErrorMessage != nil {
+		return nil, errors.New(*rsp.ErrorMessage)
+	}
+	if rsp.ErrorCode != ErrNoError {
+		return nil, rsp.ErrorCode
+	}
+
 	return rsp.Results, nil
 }
 
@@ -1031,8 +3176,26 @@ func (ca *clusterAdmin) DeleteUserScramCredentials(delete []AlterUserScramCreden
 
 func (ca *clusterAdmin) AlterUserScramCredentials(u []AlterUserScramCredentialsUpsert, d []AlterUserScramCredentialsDelete) ([]*AlterUserScramCredentialsResult, error) {
 	req := &AlterUserScramCredentialsRequest{
-		Deletions:  d,
-		Upsertions: u,
+		Deletions: d,
+	}
+
+	// fill in the defaults on a copy to leave the given upsertions untouched
+	if u != nil {
+		req.Upsertions = make([]AlterUserScramCredentialsUpsert, len(u))
+		copy(req.Upsertions, u)
+	}
+	for i := range req.Upsertions {
+		upsert := &req.Upsertions[i]
+		if upsert.Iterations == 0 {
+			upsert.Iterations = scramMinIterations
+		}
+		if len(upsert.Salt) == 0 {
+			salt, err := scramFormatter{mechanism: upsert.Mechanism}.salt()
+			if err != nil {
+				return nil, err
+			}
+			upsert.Salt = salt
+		}
 	}
 
 	b, err := ca.Controller()
@@ -1048,6 +3211,113 @@ func (ca *clusterAdmin) AlterUserScramCredentials(u []AlterUserScramCredentialsU
 	return rsp.Results, nil
 }
 
+func (ca *clusterAdmin) delegationTokenVersion() int16 {
+	if ca.conf.Version.IsAtLeast(V2_0_0_0) {
+		return 1
+	}
+	return 0
+}
+
+func (ca *clusterAdmin) CreateDelegationToken(renewers []KafkaPrincipal, maxLifetime time.Duration) (*DelegationToken, error) {
+	request := &CreateDelegationTokenRequest{
+		Version:     ca.delegationTokenVersion(),
+		Renewers:    renewers,
+		MaxLifetime: maxLifetime,
+	}
+
+	b, err := ca.findAnyBroker()
+	if err != nil {
+		return nil, err
+	}
+	_ = b.Open(ca.client.Config())
+
+	rsp, err := b.CreateDelegationToken(request)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.Err != ErrNoError {
+		return nil, rsp.Err
+	}
+
+	return &DelegationToken{
+		Owner:      rsp.Owner,
+		Renewers:   renewers,
+		IssueTime:  rsp.IssueTime,
+		ExpiryTime: rsp.ExpiryTime,
+		MaxTime:    rsp.MaxTime,
+		TokenID:    rsp.TokenID,
+		HMAC:       rsp.HMAC,
+	}, nil
+}
+
+func (ca *clusterAdmin) RenewDelegationToken(hmac []byte, renewPeriod time.Duration) (time.Time, error) {
+	request := &RenewDelegationTokenRequest{
+		Version:     ca.delegationTokenVersion(),
+		HMAC:        hmac,
+		RenewPeriod: renewPeriod,
+	}
+
+	b, err := ca.findAnyBroker()
+	if err != nil {
+		return time.Time{}, err
+	}
+	_ = b.Open(ca.client.Config())
+
+	rsp, err := b.RenewDelegationToken(request)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if rsp.Err != ErrNoError {
+		return time.Time{}, rsp.Err
+	}
+	return rsp.ExpiryTime, nil
+}
+
+func (ca *clusterAdmin) ExpireDelegationToken(hmac []byte, expiryPeriod time.Duration) (time.Time, error) {
+	request := &ExpireDelegationTokenRequest{
+		Version:      ca.delegationTokenVersion(),
+		HMAC:         hmac,
+		ExpiryPeriod: expiryPeriod,
+	}
+
+	b, err := ca.findAnyBroker()
+	if err != nil {
+		return time.Time{}, err
+	}
+	_ = b.Open(ca.client.Config())
+
+	rsp, err := b.ExpireDelegationToken(request)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if rsp.Err != ErrNoError {
+		return time.Time{}, rsp.Err
+	}
+	return rsp.ExpiryTime, nil
+}
+
+func (ca *clusterAdmin) DescribeDelegationToken(owners []KafkaPrincipal) ([]*DelegationToken, error) {
+	request := &DescribeDelegationTokenRequest{
+		Version: ca.delegationTokenVersion(),
+		Owners:  owners,
+	}
+
+	b, err := ca.findAnyBroker()
+	if err != nil {
+		return nil, err
+	}
+	_ = b.Open(ca.client.Config())
+
+	rsp, err := b.DescribeDelegationToken(request)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.Err != ErrNoError {
+		return nil, rsp.Err
+	}
+	return rsp.Tokens, nil
+}
+
 // Describe All : use an empty/nil components slice + strict = false
 // Contains components: strict = false
 // Contains only components: strict = true
@@ -1083,8 +3353,12 @@ func (ca *clusterAdmin) AlterClientQuotas(entity []QuotaEntityComponent, op Clie
 		Ops:    []ClientQuotasOp{op},
 	}
 
+	return ca.AlterClientQuotasEntries([]AlterClientQuotasEntry{entry}, validateOnly)
+}
+
+func (ca *clusterAdmin) AlterClientQuotasEntries(entries []AlterClientQuotasEntry, validateOnly bool) error {
 	request := &AlterClientQuotasRequest{
-		Entries:      []AlterClientQuotasEntry{entry},
+		Entries:      entries,
 		ValidateOnly: validateOnly,
 	}
 
@@ -1099,6 +3373,9 @@ func (ca *clusterAdmin) AlterClientQuotas(entity []QuotaEntityComponent, op Clie
 	}
 
 	for _, entry := range rsp.Entries {
+		if entry.ErrorMsg != nil {
+			return errors.New(*entry.ErrorMsg)
+		}
 		if entry.ErrorCode != ErrNoError {
 			return entry.ErrorCode
 		}
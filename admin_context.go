@@ -0,0 +1,504 @@
+package sarama
+
+import (
+	"context"
+	"time"
+)
+
+// contextClusterAdmin is the ClusterAdmin returned by WithContext and
+// WithOptions. Each call is given a clusterAdmin bound to the context of the
+// call, which stops retrying once it is done, and a client handing out views
+// of the shared brokers that stop waiting for the responses of the call then,
+// see Broker.withContext.
+type contextClusterAdmin struct {
+	ca          *clusterAdmin
+	callTimeout time.Duration
+}
+
+// call returns a clusterAdmin bound to the context of the admin, also done
+// with callCtx when it is not nil, and to the call timeout, and the function
+// to pass the error of the call to once it returned. That function releases
+// the context and returns its error instead when it is done, in which case
+// the other results of the call must not be used.
+func (c *contextClusterAdmin) call(callCtx context.Context) (*clusterAdmin, func(error) error) {
+	ctx := c.ca.context()
+	var cancels []context.CancelFunc
+	if callCtx != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = mergeContext(ctx, callCtx)
+		cancels = append(cancels, cancel)
+	}
+	if c.callTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.callTimeout)
+		cancels = append(cancels, cancel)
+	}
+
+	ca := *c.ca
+	ca.ctx = ctx
+	ca.client = &contextClient{Client: c.ca.client, ctx: ctx}
+	return &ca, func(err error) error {
+		defer func() {
+			for _, cancel := range cancels {
+				cancel()
+			}
+		}()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+}
+
+// contextClient is the client of the clusterAdmin of a contextClusterAdmin
+// call. It refuses to hand out brokers once the context of the call is done
+// and hands out views of the shared brokers bound to that context otherwise.
+type contextClient struct {
+	Client
+	ctx context.Context
+}
+
+// use returns the view of broker bound to the context of the call, unless err
+// is set or that context is done
+func (c *contextClient) use(broker *Broker, err error) (*Broker, error) {
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return broker.withContext(c.ctx), nil
+}
+
+func (c *contextClient) Controller() (*Broker, error) {
+	return c.use(c.Client.Controller())
+}
+
+func (c *contextClient) RefreshController() (*Broker, error) {
+	return c.use(c.Client.RefreshControllerContext(c.ctx))
+}
+
+func (c *contextClient) Brokers() []*Broker {
+	if c.ctx.Err() != nil {
+		return nil
+	}
+	brokers := c.Client.Brokers()
+	for i, broker := range brokers {
+		brokers[i] = broker.withContext(c.ctx)
+	}
+	return brokers
+}
+
+func (c *contextClient) Broker(brokerID int32) (*Broker, error) {
+	return c.use(c.Client.Broker(brokerID))
+}
+
+func (c *contextClient) LeastLoadedBroker() (*Broker, error) {
+	return c.use(c.Client.LeastLoadedBroker())
+}
+
+func (c *contextClient) Leader(topic string, partitionID int32) (*Broker, error) {
+	return c.use(c.Client.Leader(topic, partitionID))
+}
+
+func (c *contextClient) Coordinator(consumerGroup string) (*Broker, error) {
+	return c.use(c.Client.Coordinator(consumerGroup))
+}
+
+func (c *contextClient) RefreshMetadata(topics ...string) error {
+	return c.Client.RefreshMetadataContext(c.ctx, topics...)
+}
+
+// mergedContext is a context done as soon as either of its parents is, see
+// mergeContext
+type mergedContext struct {
+	context.Context
+	parent context.Context
+	other  context.Context
+}
+
+// mergeContext returns a context with the values of parent that is done as
+// soon as either parent or other is, with the error of the one done
+func mergeContext(parent, other context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	go withRecover(func() {
+		select {
+		case <-other.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	})
+	return &mergedContext{Context: ctx, parent: parent, other: other}, cancel
+}
+
+func (c *mergedContext) Deadline() (time.Time, bool) {
+	deadline, ok := c.parent.Deadline()
+	if otherDeadline, otherOk := c.other.Deadline(); otherOk && (!ok || otherDeadline.Before(deadline)) {
+		return otherDeadline, true
+	}
+	return deadline, ok
+}
+
+func (c *mergedContext) Err() error {
+	if err := c.parent.Err(); err != nil {
+		return err
+	}
+	if err := c.other.Err(); err != nil {
+		return err
+	}
+	return c.Context.Err()
+}
+
+func (c *contextClusterAdmin) WithContext(ctx context.Context) ClusterAdmin {
+	withContext := c.ca.WithContext(ctx).(*contextClusterAdmin)
+	withContext.callTimeout = c.callTimeout
+	return withContext
+}
+
+func (c *contextClusterAdmin) WithOptions(options AdminOptions) ClusterAdmin {
+	withOptions := c.ca.WithOptions(options).(*contextClusterAdmin)
+	if options.CallTimeout <= 0 {
+		withOptions.callTimeout = c.callTimeout
+	}
+	return withOptions
+}
+
+func (c *contextClusterAdmin) Close() error {
+	return c.ca.Close()
+}
+
+func (c *contextClusterAdmin) CreateTopic(topic string, detail *TopicDetail, validateOnly bool) error {
+	ca, done := c.call(nil)
+	return done(ca.CreateTopic(topic, detail, validateOnly))
+}
+
+func (c *contextClusterAdmin) CreateTopicAndWait(ctx context.Context, topic string, detail *TopicDetail) error {
+	ca, done := c.call(ctx)
+	return done(ca.createTopicAndWait(topic, detail))
+}
+
+func (c *contextClusterAdmin) ListTopics() (map[string]TopicDetail, error) {
+	ca, done := c.call(nil)
+	result, err := ca.ListTopics()
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) DescribeTopics(topics []string) ([]*TopicMetadata, error) {
+	ca, done := c.call(nil)
+	metadata, err := ca.DescribeTopics(topics)
+	return metadata, done(err)
+}
+
+func (c *contextClusterAdmin) DeleteTopic(topic string) error {
+	ca, done := c.call(nil)
+	return done(ca.DeleteTopic(topic))
+}
+
+func (c *contextClusterAdmin) CreatePartitions(topic string, count int32, assignment [][]int32, validateOnly bool) error {
+	ca, done := c.call(nil)
+	return done(ca.CreatePartitions(topic, count, assignment, validateOnly))
+}
+
+func (c *contextClusterAdmin) ApplyTopic(topic string, spec *TopicDetail, validateOnly bool) (*TopicChangeReport, error) {
+	ca, done := c.call(nil)
+	result, err := ca.ApplyTopic(topic, spec, validateOnly)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) CloneTopic(source, destination string, numPartitions int32, validateOnly bool) (*TopicDetail, error) {
+	ca, done := c.call(nil)
+	result, err := ca.CloneTopic(source, destination, numPartitions, validateOnly)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) AlterPartitionReassignments(topic string, assignment [][]int32) error {
+	ca, done := c.call(nil)
+	return done(ca.AlterPartitionReassignments(topic, assignment))
+}
+
+func (c *contextClusterAdmin) ListPartitionReassignments(topics string, partitions []int32) (map[string]map[int32]*PartitionReplicaReassignmentsStatus, error) {
+	ca, done := c.call(nil)
+	topicStatus, err := ca.ListPartitionReassignments(topics, partitions)
+	return topicStatus, done(err)
+}
+
+func (c *contextClusterAdmin) ListOngoingPartitionReassignments(partitions map[string][]int32) (map[string]map[int32]*PartitionReplicaReassignmentsStatus, error) {
+	ca, done := c.call(nil)
+	result, err := ca.ListOngoingPartitionReassignments(partitions)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) WaitForReassignment(ctx context.Context, partitions map[string][]int32, progress func(*ReassignmentProgress)) error {
+	ca, done := c.call(ctx)
+	return done(ca.waitForReassignment(partitions, progress))
+}
+
+func (c *contextClusterAdmin) ElectLeaders(electionType ElectionType, partitions map[string][]int32) (map[string]map[int32]*PartitionResult, error) {
+	ca, done := c.call(nil)
+	result, err := ca.ElectLeaders(electionType, partitions)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) DeleteRecords(topic string, partitionOffsets map[int32]int64) error {
+	ca, done := c.call(nil)
+	return done(ca.DeleteRecords(topic, partitionOffsets))
+}
+
+func (c *contextClusterAdmin) DeleteRecordsBatch(offsets map[string]map[int32]int64) (map[string]map[int32]*DeleteRecordsResult, error) {
+	ca, done := c.call(nil)
+	result, err := ca.DeleteRecordsBatch(offsets)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) ListOffsets(times map[string]map[int32]int64, isolationLevel IsolationLevel) (map[string]map[int32]*ListOffsetsResult, error) {
+	ca, done := c.call(nil)
+	result, err := ca.ListOffsets(times, isolationLevel)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) DescribeProducers(partitions map[string][]int32) (map[string]map[int32]*DescribeProducersResult, error) {
+	ca, done := c.call(nil)
+	result, err := ca.DescribeProducers(partitions)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) ListTransactions(stateFilters []string, producerIDFilters []int64) ([]*TransactionListing, error) {
+	ca, done := c.call(nil)
+	result, err := ca.ListTransactions(stateFilters, producerIDFilters)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) DescribeTransactions(transactionalIDs []string) ([]*TransactionDescription, error) {
+	ca, done := c.call(nil)
+	result, err := ca.DescribeTransactions(transactionalIDs)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) AbortTransaction(spec AbortTransactionSpec) error {
+	ca, done := c.call(nil)
+	return done(ca.AbortTransaction(spec))
+}
+
+func (c *contextClusterAdmin) DescribeConfig(resource ConfigResource) ([]ConfigEntry, error) {
+	ca, done := c.call(nil)
+	result, err := ca.DescribeConfig(resource)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) AlterConfig(resourceType ConfigResourceType, name string, entries map[string]*string, validateOnly bool) error {
+	ca, done := c.call(nil)
+	return done(ca.AlterConfig(resourceType, name, entries, validateOnly))
+}
+
+func (c *contextClusterAdmin) IncrementalAlterConfig(resourceType ConfigResourceType, name string, entries map[string]IncrementalAlterConfigsEntry, validateOnly bool) error {
+	ca, done := c.call(nil)
+	return done(ca.IncrementalAlterConfig(resourceType, name, entries, validateOnly))
+}
+
+func (c *contextClusterAdmin) CreateACL(resource Resource, acl Acl) error {
+	ca, done := c.call(nil)
+	return done(ca.CreateACL(resource, acl))
+}
+
+func (c *contextClusterAdmin) ListAcls(filter AclFilter) ([]ResourceAcls, error) {
+	ca, done := c.call(nil)
+	result, err := ca.ListAcls(filter)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) DeleteACL(filter AclFilter, validateOnly bool) ([]MatchingAcl, error) {
+	ca, done := c.call(nil)
+	result, err := ca.DeleteACL(filter, validateOnly)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) CreateACLs(bindings []AclCreation) ([]AclCreationResult, error) {
+	ca, done := c.call(nil)
+	result, err := ca.CreateACLs(bindings)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) DeleteACLs(filters []AclFilter) ([]AclDeletionResult, error) {
+	ca, done := c.call(nil)
+	result, err := ca.DeleteACLs(filters)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) ListConsumerGroups() (map[string]string, error) {
+	ca, done := c.call(nil)
+	result, err := ca.ListConsumerGroups()
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) ListConsumerGroupsWithFilters(states []string, types []string) (map[string]*ConsumerGroupListing, error) {
+	ca, done := c.call(nil)
+	result, err := ca.ListConsumerGroupsWithFilters(states, types)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) DescribeConsumerGroups(groups []string) ([]*GroupDescription, error) {
+	ca, done := c.call(nil)
+	result, err := ca.DescribeConsumerGroups(groups)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) ListConsumerGroupOffsets(group string, topicPartitions map[string][]int32) (*OffsetFetchResponse, error) {
+	ca, done := c.call(nil)
+	result, err := ca.ListConsumerGroupOffsets(group, topicPartitions)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) DeleteConsumerGroupOffset(group string, topic string, partition int32) error {
+	ca, done := c.call(nil)
+	return done(ca.DeleteConsumerGroupOffset(group, topic, partition))
+}
+
+func (c *contextClusterAdmin) DeleteConsumerGroupOffsets(group string, partitions map[string][]int32) (map[string]map[int32]KError, error) {
+	ca, done := c.call(nil)
+	result, err := ca.DeleteConsumerGroupOffsets(group, partitions)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) AlterConsumerGroupOffsets(group string, offsets map[string]map[int32]int64) (map[string]map[int32]KError, error) {
+	ca, done := c.call(nil)
+	result, err := ca.AlterConsumerGroupOffsets(group, offsets)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) ResetConsumerGroupOffsets(group string, times map[string]map[int32]int64) (map[string]map[int32]KError, error) {
+	ca, done := c.call(nil)
+	result, err := ca.ResetConsumerGroupOffsets(group, times)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) DeleteConsumerGroup(group string) error {
+	ca, done := c.call(nil)
+	return done(ca.DeleteConsumerGroup(group))
+}
+
+func (c *contextClusterAdmin) RemoveMembersFromConsumerGroup(group string, groupInstanceIDs []string) (map[string]KError, error) {
+	ca, done := c.call(nil)
+	result, err := ca.RemoveMembersFromConsumerGroup(group, groupInstanceIDs)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) RemoveAllMembersFromConsumerGroup(group string) (map[string]KError, error) {
+	ca, done := c.call(nil)
+	result, err := ca.RemoveAllMembersFromConsumerGroup(group)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) DescribeCluster() ([]*Broker, int32, error) {
+	ca, done := c.call(nil)
+	brokers, controllerID, err := ca.DescribeCluster()
+	return brokers, controllerID, done(err)
+}
+
+func (c *contextClusterAdmin) DescribeClusterDetails(includeAuthorizedOperations bool) (*ClusterDescription, error) {
+	ca, done := c.call(nil)
+	result, err := ca.DescribeClusterDetails(includeAuthorizedOperations)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) DescribeFeatures() (*FeatureMetadata, error) {
+	ca, done := c.call(nil)
+	result, err := ca.DescribeFeatures()
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) UpdateFeatures(updates map[string]FeatureUpdate, validateOnly bool) (map[string]error, error) {
+	ca, done := c.call(nil)
+	result, err := ca.UpdateFeatures(updates, validateOnly)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) DescribeQuorum() (*QuorumInfo, error) {
+	ca, done := c.call(nil)
+	result, err := ca.DescribeQuorum()
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) UnregisterBroker(brokerID int32) error {
+	ca, done := c.call(nil)
+	return done(ca.UnregisterBroker(brokerID))
+}
+
+func (c *contextClusterAdmin) DescribeLogDirs(brokers []int32) (map[int32][]DescribeLogDirsResponseDirMetadata, error) {
+	ca, done := c.call(nil)
+	result, err := ca.DescribeLogDirs(brokers)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) DescribeUserScramCredentials(users []string) ([]*DescribeUserScramCredentialsResult, error) {
+	ca, done := c.call(nil)
+	result, err := ca.DescribeUserScramCredentials(users)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) DeleteUserScramCredentials(delete []AlterUserScramCredentialsDelete) ([]*AlterUserScramCredentialsResult, error) {
+	ca, done := c.call(nil)
+	result, err := ca.DeleteUserScramCredentials(delete)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) UpsertUserScramCredentials(upsert []AlterUserScramCredentialsUpsert) ([]*AlterUserScramCredentialsResult, error) {
+	ca, done := c.call(nil)
+	result, err := ca.UpsertUserScramCredentials(upsert)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) AlterUserScramCredentials(upsert []AlterUserScramCredentialsUpsert, delete []AlterUserScramCredentialsDelete) ([]*AlterUserScramCredentialsResult, error) {
+	ca, done := c.call(nil)
+	result, err := ca.AlterUserScramCredentials(upsert, delete)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) CreateDelegationToken(renewers []KafkaPrincipal, maxLifetime time.Duration) (*DelegationToken, error) {
+	ca, done := c.call(nil)
+	result, err := ca.CreateDelegationToken(renewers, maxLifetime)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) RenewDelegationToken(hmac []byte, renewPeriod time.Duration) (time.Time, error) {
+	ca, done := c.call(nil)
+	result, err := ca.RenewDelegationToken(hmac, renewPeriod)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) ExpireDelegationToken(hmac []byte, expiryPeriod time.Duration) (time.Time, error) {
+	ca, done := c.call(nil)
+	result, err := ca.ExpireDelegationToken(hmac, expiryPeriod)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) DescribeDelegationToken(owners []KafkaPrincipal) ([]*DelegationToken, error) {
+	ca, done := c.call(nil)
+	result, err := ca.DescribeDelegationToken(owners)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) DescribeClientQuotas(components []QuotaFilterComponent, strict bool) ([]DescribeClientQuotasEntry, error) {
+	ca, done := c.call(nil)
+	result, err := ca.DescribeClientQuotas(components, strict)
+	return result, done(err)
+}
+
+func (c *contextClusterAdmin) AlterClientQuotas(entity []QuotaEntityComponent, op ClientQuotasOp, validateOnly bool) error {
+	ca, done := c.call(nil)
+	return done(ca.AlterClientQuotas(entity, op, validateOnly))
+}
+
+func (c *contextClusterAdmin) AlterClientQuotasEntries(entries []AlterClientQuotasEntry, validateOnly bool) error {
+	ca, done := c.call(nil)
+	return done(ca.AlterClientQuotasEntries(entries, validateOnly))
+}
+
+func (c *contextClusterAdmin) Controller() (*Broker, error) {
+	ca, done := c.call(nil)
+	controller, err := ca.Controller()
+	if err = done(err); err != nil {
+		return nil, err
+	}
+	// the view of the call is of no use to the caller
+	return controller.shared, nil
+}
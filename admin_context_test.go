@@ -0,0 +1,372 @@
+package sarama
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClusterAdminWithContextDeadline(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+	// every response is delayed, like those of an overloaded controller
+	seedBroker.SetLatency(500 * time.Millisecond)
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	metadata, err := admin.WithContext(ctx).DescribeTopics([]string{"my_topic"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if metadata != nil {
+		t.Errorf("expected no metadata, got %v", metadata)
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("DescribeTopics returned after %v instead of at the deadline", elapsed)
+	}
+}
+
+func TestClusterAdminWithContextCanceled(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"CreateTopicsRequest": NewMockCreateTopicsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	withContext := admin.WithContext(ctx)
+	if err := withContext.CreateTopic("my_topic", &TopicDetail{NumPartitions: 1, ReplicationFactor: 1}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	if err := withContext.CreateTopic("other_topic", &TopicDetail{NumPartitions: 1, ReplicationFactor: 1}, false); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*CreateTopicsRequest); ok {
+			if _, ok := req.TopicDetails["other_topic"]; ok {
+				t.Error("no request must be sent once the context is canceled")
+			}
+		}
+	}
+
+	// the admin the context was bound from is not affected
+	if err := admin.CreateTopic("other_topic", &TopicDetail{NumPartitions: 1, ReplicationFactor: 1}, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminWithContextSharedConnections(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	// the metadata responses are delayed, like those of an overloaded
+	// controller, while hold is set
+	var hold int32
+	metadata := NewMockMetadataResponse(t).
+		SetController(seedBroker.BrokerID()).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID())
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": mockResponseFunc(func(reqBody versionedDecoder) encoderWithHeader {
+			if atomic.LoadInt32(&hold) == 1 {
+				time.Sleep(300 * time.Millisecond)
+			}
+			return metadata.For(reqBody)
+		}),
+	})
+
+	var dials int32
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	config.Net.DialFn = func(ctx context.Context, network, address string) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, address)
+	}
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	controller, err := admin.Controller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.WithContext(context.Background()).DescribeTopics([]string{"my_topic"}); err != nil {
+		t.Fatal(err)
+	}
+	dialed := atomic.LoadInt32(&dials)
+	atomic.StoreInt32(&hold, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := admin.WithContext(ctx).DescribeTopics([]string{"my_topic"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("DescribeTopics returned after %v instead of at the deadline", elapsed)
+	}
+	// the call stopped waiting for its response only, the shared connection
+	// to the controller being left untouched
+	if connected, err := controller.Connected(); !connected {
+		t.Fatalf("expected the connection to the controller to stay open, got %v", err)
+	}
+
+	atomic.StoreInt32(&hold, 0)
+	if _, err := controller.GetMetadata(&MetadataRequest{Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.DescribeTopics([]string{"my_topic"}); err != nil {
+		t.Fatal(err)
+	}
+	if dials := atomic.LoadInt32(&dials); dials != dialed {
+		t.Errorf("expected the calls to share the connections of the client, %d more were dialed", dials-dialed)
+	}
+}
+
+func TestClusterAdminWithOptionsCreateTopicAndWait(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"CreateTopicsRequest": NewMockCreateTopicsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	config.Admin.Retry.Backoff = 10 * time.Millisecond
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the topic never gets ready, the call timeout ends the wait
+	start := time.Now()
+	withOptions := admin.WithOptions(AdminOptions{CallTimeout: 50 * time.Millisecond})
+	err = withOptions.CreateTopicAndWait(ctx, "my_topic", &TopicDetail{NumPartitions: 1, ReplicationFactor: 1})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("CreateTopicAndWait returned after %v instead of at the call timeout", elapsed)
+	}
+}
+
+func TestClusterAdminWithContextWaitForReassignment(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"ListPartitionReassignmentsRequest": NewMockListPartitionReassignmentsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	config.Admin.Reassignment.PollInterval = 10 * time.Millisecond
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	withContext := admin.WithContext(ctx)
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	// the bound context ends the wait, not just the one of the call
+	err = withContext.WaitForReassignment(context.Background(), map[string][]int32{"my_topic": {0}}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestMergeContext(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	defer cancelParent()
+	other, cancelOther := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelOther()
+
+	ctx, cancel := mergeContext(parent, other)
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("expected the deadline of the other context, got %v", deadline)
+	}
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	cancelOther()
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the merged context is not done with the other context")
+	}
+	if err := ctx.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestClusterAdminRetryOnErrorContext(t *testing.T) {
+	config := NewTestConfig()
+	config.Admin.Retry.Backoff = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ca := &clusterAdmin{conf: config, ctx: ctx}
+
+	attempts := 0
+	err := ca.retryOnError(isErrNoController, func() error {
+		attempts++
+		cancel()
+		return ErrNotController
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestClusterAdminWithOptions(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"CreateTopicsRequest": NewMockCreateTopicsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	withOptions := admin.WithOptions(AdminOptions{Timeout: 42 * time.Second})
+	if err := withOptions.CreateTopic("my_topic", &TopicDetail{NumPartitions: 1, ReplicationFactor: 1}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.CreateTopic("other_topic", &TopicDetail{NumPartitions: 1, ReplicationFactor: 1}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	timeouts := make(map[string]time.Duration)
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*CreateTopicsRequest); ok {
+			for topic := range req.TopicDetails {
+				timeouts[topic] = req.Timeout
+			}
+		}
+	}
+	if timeouts["my_topic"] != 42*time.Second {
+		t.Errorf("expected the overridden timeout of 42s, got %v", timeouts["my_topic"])
+	}
+	// the admin the options were applied to is not affected
+	if timeouts["other_topic"] != config.Admin.Timeout {
+		t.Errorf("expected the configured timeout of %v, got %v", config.Admin.Timeout, timeouts["other_topic"])
+	}
+}
+
+func TestClusterAdminWithOptionsCallTimeout(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+	seedBroker.SetLatency(500 * time.Millisecond)
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	// the call timeout survives binding a context
+	withOptions := admin.WithOptions(AdminOptions{CallTimeout: 50 * time.Millisecond}).
+		WithContext(context.Background())
+
+	start := time.Now()
+	if _, err := withOptions.DescribeTopics([]string{"my_topic"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("DescribeTopics returned after %v instead of at the call timeout", elapsed)
+	}
+}
+
+func TestClusterAdminWithOptionsRetry(t *testing.T) {
+	config := NewTestConfig()
+	config.Admin.Retry.Max = 5
+	config.Admin.Retry.Backoff = time.Minute
+	ca := &clusterAdmin{conf: config}
+
+	withOptions := ca.WithOptions(AdminOptions{RetryMax: 2, RetryBackoff: time.Millisecond}).(*contextClusterAdmin)
+
+	attempts := 0
+	err := withOptions.ca.retryOnError(isErrNoController, func() error {
+		attempts++
+		return ErrNotController
+	})
+	if !errors.Is(err, ErrNotController) {
+		t.Fatalf("expected ErrNotController, got %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+	if config.Admin.Retry.Max != 5 || config.Admin.Retry.Backoff != time.Minute {
+		t.Error("the options must not modify the config of the admin")
+	}
+}
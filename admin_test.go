@@ -1,9 +1,13 @@
 package sarama
 
 import (
+	"context"
 	"errors"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestClusterAdmin(t *testing.T) {
@@ -78,6 +82,83 @@ func TestClusterAdminCreateTopic(t *testing.T) {
 	}
 }
 
+func TestClusterAdminCreateTopicAndWait(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	noTopic := NewMockMetadataResponse(t).
+		SetController(seedBroker.BrokerID()).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID())
+	partialTopic := NewMockMetadataResponse(t).
+		SetController(seedBroker.BrokerID()).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetLeader("my_topic", 0, seedBroker.BrokerID())
+	readyTopic := NewMockMetadataResponse(t).
+		SetController(seedBroker.BrokerID()).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetLeader("my_topic", 0, seedBroker.BrokerID()).
+		SetLeader("my_topic", 1, seedBroker.BrokerID())
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest":     NewMockSequence(noTopic, noTopic, partialTopic, readyTopic),
+		"CreateTopicsRequest": NewMockCreateTopicsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	config.Admin.Retry.Backoff = 10 * time.Millisecond
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = admin.CreateTopicAndWait(ctx, "my_topic", &TopicDetail{NumPartitions: 2, ReplicationFactor: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	partitions, err := admin.(*clusterAdmin).client.WritablePartitions("my_topic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(partitions) != 2 {
+		t.Errorf("expected 2 writable partitions, got %v", partitions)
+	}
+}
+
+func TestClusterAdminCreateTopicAndWaitTimeout(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"CreateTopicsRequest": NewMockCreateTopicsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	config.Admin.Retry.Backoff = 10 * time.Millisecond
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err = admin.CreateTopicAndWait(ctx, "my_topic", &TopicDetail{NumPartitions: 1, ReplicationFactor: 1})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
 func TestClusterAdminCreateTopicWithInvalidTopicDetail(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
@@ -274,6 +355,222 @@ func TestClusterAdminCreatePartitions(t *testing.T) {
 	}
 }
 
+func TestClusterAdminCloneTopic(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()).
+			SetLeader("my_topic", 1, seedBroker.BrokerID()),
+		"DescribeConfigsRequest": NewMockDescribeConfigsResponse(t),
+		"CreateTopicsRequest":    NewMockCreateTopicsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	detail, err := admin.CloneTopic("my_topic", "my_topic_v2", 4, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retentionMs := "5000"
+	expected := &TopicDetail{
+		NumPartitions:     4,
+		ReplicationFactor: 1,
+		ConfigEntries:     map[string]*string{"retention.ms": &retentionMs},
+	}
+	if !reflect.DeepEqual(detail, expected) {
+		t.Errorf("expected %+v, got %+v", expected, detail)
+	}
+
+	var created *TopicDetail
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*CreateTopicsRequest); ok {
+			created = req.TopicDetails["my_topic_v2"]
+		}
+	}
+	if !reflect.DeepEqual(created, expected) {
+		t.Errorf("expected the topic to be created with %+v, got %+v", expected, created)
+	}
+
+	detail, err = admin.CloneTopic("my_topic", "my_topic_v3", 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detail.NumPartitions != 2 {
+		t.Errorf("expected the 2 partitions of the source topic, got %d", detail.NumPartitions)
+	}
+}
+
+func TestClusterAdminRackAwareAssignment(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetBroker("localhost:29092", 2).
+			SetBroker("localhost:39092", 3).
+			SetRack(seedBroker.BrokerID(), "rack1").
+			SetRack(2, "rack2").
+			SetRack(3, "rack3").
+			SetLeader("my_topic", 0, seedBroker.BrokerID()),
+		"CreateTopicsRequest":     NewMockCreateTopicsResponse(t),
+		"CreatePartitionsRequest": NewMockCreatePartitionsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	config.Admin.RackAwareAssignment = true
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	detail := &TopicDetail{NumPartitions: 3, ReplicationFactor: 3}
+	if err := admin.CreateTopic("new_topic", detail, false); err != nil {
+		t.Fatal(err)
+	}
+	if detail.ReplicaAssignment != nil {
+		t.Error("the given topic detail must not be modified")
+	}
+	if err := admin.CreatePartitions("my_topic", 3, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// each broker is on its own rack
+	checkReplicas := func(replicas []int32) {
+		brokers := make(map[int32]none)
+		for _, replica := range replicas {
+			brokers[replica] = none{}
+		}
+		if len(replicas) != 3 || len(brokers) != 3 {
+			t.Errorf("replicas %v are not spread over the 3 racks", replicas)
+		}
+	}
+	for _, rr := range seedBroker.History() {
+		switch req := rr.Request.(type) {
+		case *CreateTopicsRequest:
+			created := req.TopicDetails["new_topic"]
+			if created.NumPartitions != -1 || created.ReplicationFactor != -1 || len(created.ReplicaAssignment) != 3 {
+				t.Errorf("unexpected topic detail %+v", created)
+			}
+			for _, replicas := range created.ReplicaAssignment {
+				checkReplicas(replicas)
+			}
+		case *CreatePartitionsRequest:
+			added := req.TopicPartitions["my_topic"]
+			if added.Count != 3 || len(added.Assignment) != 2 {
+				t.Errorf("unexpected partitions %+v", added)
+			}
+			for _, replicas := range added.Assignment {
+				checkReplicas(replicas)
+			}
+		}
+	}
+}
+
+func TestClusterAdminApplyTopic(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()),
+		"DescribeConfigsRequest":         NewMockDescribeConfigsResponse(t),
+		"CreatePartitionsRequest":        NewMockCreatePartitionsResponse(t),
+		"IncrementalAlterConfigsRequest": NewMockIncrementalAlterConfigsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_3_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	retentionMs := "5000"
+	cleanupPolicy := "compact"
+	report, err := admin.ApplyTopic("my_topic", &TopicDetail{
+		NumPartitions:     3,
+		ReplicationFactor: 2,
+		ConfigEntries: map[string]*string{
+			"retention.ms":   &retentionMs,
+			"cleanup.policy": &cleanupPolicy,
+		},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Created || report.PartitionsFrom != 1 || report.PartitionsTo != 3 {
+		t.Errorf("unexpected partition changes %+v", report)
+	}
+	if report.ReplicationFactor != 1 || !report.ReplicationFactorDiffers {
+		t.Errorf("unexpected replication factor %+v", report)
+	}
+	if !report.HasChanges() {
+		t.Error("expected the report to have changes")
+	}
+
+	password := "12345"
+	expected := []TopicConfigChange{
+		{Name: "cleanup.policy", Operation: IncrementalAlterConfigsOperationSet, NewValue: &cleanupPolicy},
+		{Name: "password", Operation: IncrementalAlterConfigsOperationDelete, OldValue: &password},
+	}
+	if !reflect.DeepEqual(report.ConfigChanges, expected) {
+		t.Errorf("expected config changes %+v, got %+v", expected, report.ConfigChanges)
+	}
+
+	var alter *IncrementalAlterConfigsRequest
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*IncrementalAlterConfigsRequest); ok {
+			alter = req
+		}
+	}
+	if alter == nil {
+		t.Fatal("no incremental alter configs request was sent")
+	}
+	if entries := alter.Resources[0].ConfigEntries; len(entries) != 2 ||
+		entries["password"].Operation != IncrementalAlterConfigsOperationDelete {
+		t.Errorf("unexpected config entries %+v", entries)
+	}
+
+	report, err = admin.ApplyTopic("my_topic", &TopicDetail{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.ValidateOnly || report.PartitionsTo != 1 || len(report.ConfigChanges) != 2 {
+		t.Errorf("unexpected validate only report %+v", report)
+	}
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()).
+			SetLeader("my_topic", 1, seedBroker.BrokerID()),
+	})
+	_, err = admin.ApplyTopic("my_topic", &TopicDetail{NumPartitions: 1}, false)
+	if !errors.Is(err, ErrInvalidPartitions) {
+		t.Fatalf("expected ErrInvalidPartitions, got %v", err)
+	}
+}
+
 func TestClusterAdminCreatePartitionsWithDiffVersion(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
@@ -370,7 +667,7 @@ func TestClusterAdminAlterPartitionReassignments(t *testing.T) {
 	}
 }
 
-func TestClusterAdminAlterPartitionReassignmentsWithDiffVersion(t *testing.T) {
+func TestClusterAdminElectLeaders(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
@@ -385,67 +682,56 @@ func TestClusterAdminAlterPartitionReassignmentsWithDiffVersion(t *testing.T) {
 	})
 
 	secondBroker.SetHandlerByMap(map[string]MockResponse{
-		"AlterPartitionReassignmentsRequest": NewMockAlterPartitionReassignmentsResponse(t),
+		"ElectLeadersRequest": NewMockElectLeadersResponse(t),
 	})
 
 	config := NewTestConfig()
-	config.Version = V2_3_0_0
+	config.Version = V2_4_0_0
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	topicAssignment := make([][]int32, 0, 3)
-
-	err = admin.AlterPartitionReassignments("my_topic", topicAssignment)
-
-	if !strings.ContainsAny(err.Error(), ErrUnsupportedVersion.Error()) {
+	results, err := admin.ElectLeaders(UncleanElection, map[string][]int32{"my_topic": {0, 1}})
+	if err != nil {
 		t.Fatal(err)
 	}
 
+	if len(results["my_topic"]) != 2 {
+		t.Fatalf("expected 2 partition results, got %d", len(results["my_topic"]))
+	}
+	for partition, result := range results["my_topic"] {
+		if result.ErrorCode != ErrNoError {
+			t.Errorf("partition %d: unexpected error %v", partition, result.ErrorCode)
+		}
+	}
+
 	err = admin.Close()
 	if err != nil {
 		t.Fatal(err)
 	}
 }
 
-func TestClusterAdminListPartitionReassignments(t *testing.T) {
+func TestClusterAdminElectLeadersUncleanWithDiffVersion(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
-	secondBroker := NewMockBroker(t, 2)
-	defer secondBroker.Close()
-
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
-			SetController(secondBroker.BrokerID()).
-			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
-			SetBroker(secondBroker.Addr(), secondBroker.BrokerID()),
-	})
-
-	secondBroker.SetHandlerByMap(map[string]MockResponse{
-		"ListPartitionReassignmentsRequest": NewMockListPartitionReassignmentsResponse(t),
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
 	})
 
 	config := NewTestConfig()
-	config.Version = V2_4_0_0
+	config.Version = V2_3_0_0
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	response, err := admin.ListPartitionReassignments("my_topic", []int32{0, 1})
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	partitionStatus, ok := response["my_topic"]
-	if !ok {
-		t.Fatalf("topic missing in response")
-	} else {
-		if len(partitionStatus) != 2 {
-			t.Fatalf("partition missing in response")
-		}
+	_, err = admin.ElectLeaders(UncleanElection, map[string][]int32{"my_topic": {0}})
+	if err == nil {
+		t.Fatal("expected an error for an unclean election with Version < V2_4_0_0")
 	}
 
 	err = admin.Close()
@@ -454,7 +740,7 @@ func TestClusterAdminListPartitionReassignments(t *testing.T) {
 	}
 }
 
-func TestClusterAdminListPartitionReassignmentsWithDiffVersion(t *testing.T) {
+func TestClusterAdminAlterPartitionReassignmentsWithDiffVersion(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
@@ -469,7 +755,7 @@ func TestClusterAdminListPartitionReassignmentsWithDiffVersion(t *testing.T) {
 	})
 
 	secondBroker.SetHandlerByMap(map[string]MockResponse{
-		"ListPartitionReassignmentsRequest": NewMockListPartitionReassignmentsResponse(t),
+		"AlterPartitionReassignmentsRequest": NewMockAlterPartitionReassignmentsResponse(t),
 	})
 
 	config := NewTestConfig()
@@ -479,9 +765,9 @@ func TestClusterAdminListPartitionReassignmentsWithDiffVersion(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	partitions := make([]int32, 0)
+	topicAssignment := make([][]int32, 0, 3)
 
-	_, err = admin.ListPartitionReassignments("my_topic", partitions)
+	err = admin.AlterPartitionReassignments("my_topic", topicAssignment)
 
 	if !strings.ContainsAny(err.Error(), ErrUnsupportedVersion.Error()) {
 		t.Fatal(err)
@@ -493,43 +779,43 @@ func TestClusterAdminListPartitionReassignmentsWithDiffVersion(t *testing.T) {
 	}
 }
 
-func TestClusterAdminDeleteRecords(t *testing.T) {
-	topicName := "my_topic"
+func TestClusterAdminListPartitionReassignments(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
+	secondBroker := NewMockBroker(t, 2)
+	defer secondBroker.Close()
+
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
-			SetController(seedBroker.BrokerID()).
+			SetController(secondBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
-			SetLeader(topicName, 1, 1).
-			SetLeader(topicName, 2, 1).
-			SetLeader(topicName, 3, 1),
-		"DeleteRecordsRequest": NewMockDeleteRecordsResponse(t),
+			SetBroker(secondBroker.Addr(), secondBroker.BrokerID()),
+	})
+
+	secondBroker.SetHandlerByMap(map[string]MockResponse{
+		"ListPartitionReassignmentsRequest": NewMockListPartitionReassignmentsResponse(t),
 	})
 
 	config := NewTestConfig()
-	config.Version = V1_0_0_0
+	config.Version = V2_4_0_0
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	partitionOffsetFake := make(map[int32]int64)
-	partitionOffsetFake[4] = 1000
-	errFake := admin.DeleteRecords(topicName, partitionOffsetFake)
-	if errFake == nil {
+	response, err := admin.ListPartitionReassignments("my_topic", []int32{0, 1})
+	if err != nil {
 		t.Fatal(err)
 	}
 
-	partitionOffset := make(map[int32]int64)
-	partitionOffset[1] = 1000
-	partitionOffset[2] = 1000
-	partitionOffset[3] = 1000
-
-	err = admin.DeleteRecords(topicName, partitionOffset)
-	if err != nil {
-		t.Fatal(err)
+	partitionStatus, ok := response["my_topic"]
+	if !ok {
+		t.Fatalf("topic missing in response")
+	} else {
+		if len(partitionStatus) != 2 {
+			t.Fatalf("partition missing in response")
+		}
 	}
 
 	err = admin.Close()
@@ -538,32 +824,153 @@ func TestClusterAdminDeleteRecords(t *testing.T) {
 	}
 }
 
-func TestClusterAdminDeleteRecordsWithInCorrectBroker(t *testing.T) {
-	topicName := "my_topic"
+func TestClusterAdminWaitForReassignment(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	pending := &ListPartitionReassignmentsResponse{}
+	pending.AddBlock("my_topic", 1, []int32{1, 2, 3}, []int32{3}, []int32{1})
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"ListPartitionReassignmentsRequest": NewMockSequence(
+			pending,
+			&ListPartitionReassignmentsResponse{},
+		),
+		"DescribeConfigsRequest": NewMockWrapper(&DescribeConfigsResponse{
+			Version: 2,
+			Resources: []*ResourceResponse{{
+				Type: TopicResource,
+				Name: "my_topic",
+				Configs: []*ConfigEntry{{
+					Name:  "leader.replication.throttled.replicas",
+					Value: "1:1,1:2",
+				}},
+			}},
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	config.Admin.Reassignment.PollInterval = 10 * time.Millisecond
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reports []*ReassignmentProgress
+	err = admin.WaitForReassignment(context.Background(), map[string][]int32{"my_topic": {0, 1}}, func(p *ReassignmentProgress) {
+		reports = append(reports, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 progress reports, got %d", len(reports))
+	}
+	if reports[0].Completed != 1 || reports[0].Total != 2 {
+		t.Errorf("expected 1/2 completed, got %d/%d", reports[0].Completed, reports[0].Total)
+	}
+	if len(reports[0].ThrottledTopics) != 1 || reports[0].ThrottledTopics[0] != "my_topic" {
+		t.Errorf("expected my_topic to be throttled, got %v", reports[0].ThrottledTopics)
+	}
+	if reports[1].Completed != 2 || len(reports[1].Pending) != 0 {
+		t.Errorf("expected the reassignment to be completed, got %d/%d", reports[1].Completed, reports[1].Total)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminWaitForReassignmentCanceled(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
-	secondBroker := NewMockBroker(t, 2)
 	defer seedBroker.Close()
-	defer secondBroker.Close()
 
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"ListPartitionReassignmentsRequest": NewMockListPartitionReassignmentsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	config.Admin.Reassignment.PollInterval = 10 * time.Millisecond
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = admin.WaitForReassignment(ctx, map[string][]int32{"my_topic": {0}}, nil)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminListPartitionReassignmentsWithDiffVersion(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	secondBroker := NewMockBroker(t, 2)
+	defer secondBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(secondBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
-			SetBroker(secondBroker.Addr(), secondBroker.brokerID).
-			SetLeader(topicName, 1, 1).
-			SetLeader(topicName, 2, 1).
-			SetLeader(topicName, 3, 2),
-		"DeleteRecordsRequest": NewMockDeleteRecordsResponse(t),
+			SetBroker(secondBroker.Addr(), secondBroker.BrokerID()),
 	})
 
 	secondBroker.SetHandlerByMap(map[string]MockResponse{
+		"ListPartitionReassignmentsRequest": NewMockListPartitionReassignmentsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_3_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	partitions := make([]int32, 0)
+
+	_, err = admin.ListPartitionReassignments("my_topic", partitions)
+
+	if !strings.ContainsAny(err.Error(), ErrUnsupportedVersion.Error()) {
+		t.Fatal(err)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminDeleteRecords(t *testing.T) {
+	topicName := "my_topic"
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
-			SetBroker(secondBroker.Addr(), secondBroker.brokerID).
 			SetLeader(topicName, 1, 1).
 			SetLeader(topicName, 2, 1).
-			SetLeader(topicName, 3, 2),
+			SetLeader(topicName, 3, 1),
 		"DeleteRecordsRequest": NewMockDeleteRecordsResponse(t),
 	})
 
@@ -573,6 +980,14 @@ func TestClusterAdminDeleteRecordsWithInCorrectBroker(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	partitionOffsetFake := make(map[int32]int64)
+	partitionOffsetFake[4] = 1000
+	errFake := admin.DeleteRecords(topicName, partitionOffsetFake)
+	if errFake == nil {
+		t.Fatal(err)
+	}
+
 	partitionOffset := make(map[int32]int64)
 	partitionOffset[1] = 1000
 	partitionOffset[2] = 1000
@@ -589,357 +1004,1917 @@ func TestClusterAdminDeleteRecordsWithInCorrectBroker(t *testing.T) {
 	}
 }
 
-func TestClusterAdminDeleteRecordsWithDiffVersion(t *testing.T) {
+func TestClusterAdminDeleteRecordsWithInCorrectBroker(t *testing.T) {
 	topicName := "my_topic"
 	seedBroker := NewMockBroker(t, 1)
+	secondBroker := NewMockBroker(t, 2)
 	defer seedBroker.Close()
+	defer secondBroker.Close()
 
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetBroker(secondBroker.Addr(), secondBroker.brokerID).
 			SetLeader(topicName, 1, 1).
 			SetLeader(topicName, 2, 1).
-			SetLeader(topicName, 3, 1),
+			SetLeader(topicName, 3, 2),
+		"DeleteRecordsRequest": NewMockDeleteRecordsResponse(t),
+	})
+
+	secondBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetBroker(secondBroker.Addr(), secondBroker.brokerID).
+			SetLeader(topicName, 1, 1).
+			SetLeader(topicName, 2, 1).
+			SetLeader(topicName, 3, 2),
 		"DeleteRecordsRequest": NewMockDeleteRecordsResponse(t),
 	})
 
 	config := NewTestConfig()
-	config.Version = V0_10_2_0
+	config.Version = V1_0_0_0
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
-
 	partitionOffset := make(map[int32]int64)
 	partitionOffset[1] = 1000
 	partitionOffset[2] = 1000
 	partitionOffset[3] = 1000
 
 	err = admin.DeleteRecords(topicName, partitionOffset)
-	if !strings.HasPrefix(err.Error(), "kafka server: failed to delete records") {
+	if err != nil {
 		t.Fatal(err)
 	}
-	deleteRecordsError, ok := err.(ErrDeleteRecords)
 
-	if !ok {
+	err = admin.Close()
+	if err != nil {
 		t.Fatal(err)
 	}
+}
 
-	for _, err := range *deleteRecordsError.Errors {
-		if err != ErrUnsupportedVersion {
-			t.Fatal(err)
+func TestClusterAdminDeleteRecordsBatch(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	secondBroker := NewMockBroker(t, 2)
+	defer seedBroker.Close()
+	defer secondBroker.Close()
+
+	metadata := NewMockMetadataResponse(t).
+		SetController(seedBroker.BrokerID()).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetBroker(secondBroker.Addr(), secondBroker.BrokerID()).
+		SetLeader("jobs", 0, 1).
+		SetLeader("jobs", 1, 2).
+		SetLeader("events", 0, 2)
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest":      metadata,
+		"DeleteRecordsRequest": NewMockDeleteRecordsResponse(t),
+	})
+	secondBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest":      metadata,
+		"DeleteRecordsRequest": NewMockDeleteRecordsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := admin.DeleteRecordsBatch(map[string]map[int32]int64{
+		"jobs":   {0: 100, 1: 200},
+		"events": {0: 300},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]map[int32]int64{
+		"jobs":   {0: 100, 1: 200},
+		"events": {0: 300},
+	}
+	for topic, partitions := range expected {
+		for partition, lowWatermark := range partitions {
+			result := results[topic][partition]
+			if result == nil || result.Err != nil || result.LowWatermark != lowWatermark {
+				t.Errorf("%s-%d: unexpected result %+v", topic, partition, result)
+			}
 		}
 	}
 
+	results, err = admin.DeleteRecordsBatch(map[string]map[int32]int64{
+		"jobs": {0: 100, 5: 100},
+	})
+	if _, ok := err.(ErrDeleteRecords); !ok {
+		t.Fatalf("expected ErrDeleteRecords, got %v", err)
+	}
+	if results["jobs"][0].Err != nil {
+		t.Errorf("jobs-0: unexpected error %v", results["jobs"][0].Err)
+	}
+	if results["jobs"][5].Err == nil {
+		t.Error("jobs-5: expected an error for an unknown partition")
+	}
+
 	err = admin.Close()
 	if err != nil {
 		t.Fatal(err)
 	}
 }
 
-func TestClusterAdminDescribeConfig(t *testing.T) {
+func TestClusterAdminListOffsets(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
+	secondBroker := NewMockBroker(t, 2)
 	defer seedBroker.Close()
+	defer secondBroker.Close()
 
+	metadata := NewMockMetadataResponse(t).
+		SetController(seedBroker.BrokerID()).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetBroker(secondBroker.Addr(), secondBroker.BrokerID()).
+		SetLeader("jobs", 0, 1).
+		SetLeader("jobs", 1, 2).
+		SetLeader("events", 0, 2)
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": NewMockMetadataResponse(t).
-			SetController(seedBroker.BrokerID()).
-			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
-		"DescribeConfigsRequest": NewMockDescribeConfigsResponse(t),
+		"MetadataRequest": metadata,
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetVersion(7).
+			SetOffset("jobs", 0, OffsetOldest, 10).
+			SetLeaderEpoch("jobs", 0, 3),
+	})
+	secondBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": metadata,
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetVersion(7).
+			SetOffset("jobs", 1, OffsetNewest, 200).
+			SetOffset("events", 0, OffsetMaxTimestamp, 42).
+			SetLeaderEpoch("jobs", 1, 5).
+			SetLeaderEpoch("events", 0, 1),
 	})
 
-	tests := []struct {
-		saramaVersion   KafkaVersion
-		requestVersion  int16
-		includeSynonyms bool
-	}{
-		{V1_0_0_0, 0, false},
-		{V1_1_0_0, 1, true},
-		{V1_1_1_0, 1, true},
-		{V2_0_0_0, 2, true},
+	config := NewTestConfig()
+	config.Version = V3_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
 	}
-	for _, tt := range tests {
-		config := NewTestConfig()
-		config.Version = tt.saramaVersion
-		admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
-		if err != nil {
-			t.Fatal(err)
-		}
-		defer func() {
-			_ = admin.Close()
-		}()
-
-		resource := ConfigResource{
-			Name:        "r1",
-			Type:        TopicResource,
-			ConfigNames: []string{"my_topic"},
-		}
 
-		entries, err := admin.DescribeConfig(resource)
-		if err != nil {
-			t.Fatal(err)
-		}
+	results, err := admin.ListOffsets(map[string]map[int32]int64{
+		"jobs":   {0: OffsetOldest, 1: OffsetNewest},
+		"events": {0: OffsetMaxTimestamp},
+	}, ReadCommitted)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-		history := seedBroker.History()
-		describeReq, ok := history[len(history)-1].Request.(*DescribeConfigsRequest)
-		if !ok {
-			t.Fatal("failed to find DescribeConfigsRequest in mockBroker history")
+	expected := map[string]map[int32]ListOffsetsResult{
+		"jobs":   {0: {Offset: 10, LeaderEpoch: 3}, 1: {Offset: 200, LeaderEpoch: 5}},
+		"events": {0: {Offset: 42, LeaderEpoch: 1}},
+	}
+	for topic, partitions := range expected {
+		for partition, want := range partitions {
+			result := results[topic][partition]
+			if result == nil || result.Err != nil || result.Offset != want.Offset || result.LeaderEpoch != want.LeaderEpoch {
+				t.Errorf("%s-%d: unexpected result %+v", topic, partition, result)
+			}
 		}
+	}
 
-		if describeReq.Version != tt.requestVersion {
-			t.Fatalf(
-				"requestVersion %v did not match expected %v",
-				describeReq.Version, tt.requestVersion)
-		}
+	results, err = admin.ListOffsets(map[string]map[int32]int64{
+		"jobs": {0: OffsetOldest, 5: OffsetOldest},
+	}, ReadUncommitted)
+	if _, ok := err.(ErrListOffsets); !ok {
+		t.Fatalf("expected ErrListOffsets, got %v", err)
+	}
+	if results["jobs"][0].Err != nil {
+		t.Errorf("jobs-0: unexpected error %v", results["jobs"][0].Err)
+	}
+	if results["jobs"][5].Err == nil {
+		t.Error("jobs-5: expected an error for an unknown partition")
+	}
 
-		if len(entries) <= 0 {
-			t.Fatal(errors.New("no resource present"))
-		}
-		if tt.includeSynonyms {
-			if len(entries[0].Synonyms) == 0 {
-				t.Fatal("expected synonyms to have been included")
-			}
-		}
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
 	}
 }
 
-func TestClusterAdminDescribeConfigWithErrorCode(t *testing.T) {
+func TestClusterAdminListOffsetsMaxTimestampUnsupported(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
-			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
-		"DescribeConfigsRequest": NewMockDescribeConfigsResponseWithErrorCode(t),
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("jobs", 0, 1),
 	})
 
 	config := NewTestConfig()
-	config.Version = V1_1_0_0
+	config.Version = V2_5_0_0
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		_ = admin.Close()
-	}()
 
-	resource := ConfigResource{
-		Name:        "r1",
-		Type:        TopicResource,
-		ConfigNames: []string{"my_topic"},
+	_, err = admin.ListOffsets(map[string]map[int32]int64{"jobs": {0: OffsetMaxTimestamp}}, ReadUncommitted)
+	if _, ok := err.(ConfigurationError); !ok {
+		t.Fatalf("expected ConfigurationError, got %v", err)
 	}
 
-	_, err = admin.DescribeConfig(resource)
-	if err == nil {
-		t.Fatal(errors.New("ErrorCode present but no Error returned"))
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminDescribeProducers(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	secondBroker := NewMockBroker(t, 2)
+	defer seedBroker.Close()
+	defer secondBroker.Close()
+
+	metadata := NewMockMetadataResponse(t).
+		SetController(seedBroker.BrokerID()).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetBroker(secondBroker.Addr(), secondBroker.BrokerID()).
+		SetLeader("jobs", 0, 1).
+		SetLeader("jobs", 1, 2)
+	producer := ProducerState{
+		ProducerID:            1000,
+		ProducerEpoch:         3,
+		LastSequence:          41,
+		LastTimestamp:         1477920049286,
+		CoordinatorEpoch:      2,
+		CurrentTxnStartOffset: 12,
+	}
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest":          metadata,
+		"DescribeProducersRequest": NewMockDescribeProducersResponse(t).AddProducer("jobs", 0, producer),
+	})
+	secondBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest":          metadata,
+		"DescribeProducersRequest": NewMockDescribeProducersResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_8_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := admin.DescribeProducers(map[string][]int32{"jobs": {0, 1}})
+	if _, ok := err.(ErrDescribeProducers); !ok {
+		t.Fatalf("expected ErrDescribeProducers, got %v", err)
+	}
+	if result := results["jobs"][0]; result.Err != nil || !reflect.DeepEqual(result.ActiveProducers, []ProducerState{producer}) {
+		t.Errorf("jobs-0: unexpected result %+v", result)
+	}
+	if result := results["jobs"][1]; !errors.Is(result.Err, ErrUnknownTopicOrPartition) {
+		t.Errorf("jobs-1: expected ErrUnknownTopicOrPartition, got %v", result.Err)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminListTransactions(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	secondBroker := NewMockBroker(t, 2)
+	defer seedBroker.Close()
+	defer secondBroker.Close()
+
+	metadata := NewMockMetadataResponse(t).
+		SetController(seedBroker.BrokerID()).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetBroker(secondBroker.Addr(), secondBroker.BrokerID())
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": metadata,
+		"ListTransactionsRequest": NewMockListTransactionsResponse(t).
+			AddTransaction("tx1", 1000, TransactionStateOngoing).
+			AddTransaction("tx2", 1001, TransactionStateCompleteCommit),
+	})
+	secondBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": metadata,
+		"ListTransactionsRequest": NewMockListTransactionsResponse(t).
+			AddTransaction("tx3", 1002, TransactionStateOngoing),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listings, err := admin.ListTransactions([]string{TransactionStateOngoing}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(listings, func(i, j int) bool {
+		return listings[i].TransactionalID < listings[j].TransactionalID
+	})
+	expected := []*TransactionListing{
+		{TransactionalID: "tx1", ProducerID: 1000, State: TransactionStateOngoing, CoordinatorID: 1},
+		{TransactionalID: "tx3", ProducerID: 1002, State: TransactionStateOngoing, CoordinatorID: 2},
+	}
+	if !reflect.DeepEqual(listings, expected) {
+		t.Errorf("unexpected listings %+v", listings)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminDescribeTransactions(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	secondBroker := NewMockBroker(t, 2)
+	defer seedBroker.Close()
+	defer secondBroker.Close()
+
+	metadata := NewMockMetadataResponse(t).
+		SetController(seedBroker.BrokerID()).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetBroker(secondBroker.Addr(), secondBroker.BrokerID())
+	findCoordinator := NewMockFindCoordinatorResponse(t).
+		SetCoordinator(CoordinatorTransaction, "tx1", secondBroker).
+		SetCoordinator(CoordinatorTransaction, "tx2", secondBroker)
+	transaction := &TransactionDescription{
+		TransactionalID:        "tx1",
+		TransactionState:       TransactionStateOngoing,
+		TransactionTimeoutMs:   60000,
+		TransactionStartTimeMs: 1477920049286,
+		ProducerID:             1000,
+		ProducerEpoch:          5,
+		Topics:                 []TransactionDescriptionTopic{{Topic: "jobs", Partitions: []int32{0}}},
+	}
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest":        metadata,
+		"FindCoordinatorRequest": findCoordinator,
+	})
+	secondBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest":             metadata,
+		"FindCoordinatorRequest":      findCoordinator,
+		"DescribeTransactionsRequest": NewMockDescribeTransactionsResponse(t).AddTransaction(transaction),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	descriptions, err := admin.DescribeTransactions([]string{"tx1", "tx2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(descriptions) != 2 {
+		t.Fatalf("expected 2 descriptions, got %d", len(descriptions))
+	}
+	if !reflect.DeepEqual(descriptions[0], transaction) {
+		t.Errorf("unexpected description %+v", descriptions[0])
+	}
+	if descriptions[1].TransactionalID != "tx2" || descriptions[1].Err != ErrTransactionalIDNotFound {
+		t.Errorf("expected ErrTransactionalIDNotFound for tx2, got %+v", descriptions[1])
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminAbortTransaction(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("jobs", 0, 1).
+			SetLeader("jobs", 1, 1),
+		"WriteTxnMarkersRequest": NewMockWriteTxnMarkersResponse(t).
+			SetError("jobs", 1, ErrInvalidProducerEpoch),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec := AbortTransactionSpec{Topic: "jobs", Partition: 0, ProducerID: 1000, ProducerEpoch: 5, CoordinatorEpoch: 2}
+	if err := admin.AbortTransaction(spec); err != nil {
+		t.Fatal(err)
+	}
+
+	spec.Partition = 1
+	if err := admin.AbortTransaction(spec); !errors.Is(err, ErrInvalidProducerEpoch) {
+		t.Fatalf("expected ErrInvalidProducerEpoch, got %v", err)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminDescribeFeatures(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t).
+			SetSupportedFeature("metadata.version", 1, 21).
+			SetFinalizedFeature("metadata.version", 14, 14, 42),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_3_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := admin.DescribeFeatures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &FeatureMetadata{
+		SupportedFeatures: map[string]SupportedFeatureKey{
+			"metadata.version": {Name: "metadata.version", MinVersion: 1, MaxVersion: 21},
+		},
+		FinalizedFeatures: map[string]FinalizedFeatureKey{
+			"metadata.version": {Name: "metadata.version", MinVersionLevel: 14, MaxVersionLevel: 14},
+		},
+		FinalizedFeaturesEpoch: 42,
+	}
+	if !reflect.DeepEqual(metadata, expected) {
+		t.Errorf("unexpected features %+v", metadata)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminUpdateFeatures(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"UpdateFeaturesRequest": NewMockUpdateFeaturesResponse(t).
+			SetError("kraft.version", ErrInvalidUpdateVersion),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_3_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := admin.UpdateFeatures(map[string]FeatureUpdate{
+		"metadata.version": {MaxVersionLevel: 15},
+		"kraft.version":    {MaxVersionLevel: 0, UpgradeType: FeatureSafeDowngrade},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results["metadata.version"] != nil {
+		t.Errorf("metadata.version: unexpected error %v", results["metadata.version"])
+	}
+	if !errors.Is(results["kraft.version"], ErrInvalidUpdateVersion) {
+		t.Errorf("kraft.version: expected ErrInvalidUpdateVersion, got %v", results["kraft.version"])
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminUpdateFeaturesValidateOnlyUnsupported(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_8_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = admin.UpdateFeatures(map[string]FeatureUpdate{"metadata.version": {MaxVersionLevel: 15}}, true)
+	if _, ok := err.(ConfigurationError); !ok {
+		t.Fatalf("expected ConfigurationError, got %v", err)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminDescribeQuorum(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"DescribeQuorumRequest": NewMockDescribeQuorumResponse(t).
+			SetLeader(3000, 7, 120).
+			AddVoter(3000, 120).
+			AddVoter(3001, 100).
+			AddObserver(1, 130),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_3_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quorum, err := admin.DescribeQuorum()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quorum.LeaderID != 3000 || quorum.LeaderEpoch != 7 || quorum.HighWatermark != 120 {
+		t.Errorf("unexpected quorum leader %+v", quorum)
+	}
+	if len(quorum.Voters) != 2 || quorum.Voters[0].Lag != 0 || quorum.Voters[1].ReplicaID != 3001 || quorum.Voters[1].Lag != 20 {
+		t.Errorf("unexpected voters %+v", quorum.Voters)
+	}
+	if len(quorum.Observers) != 1 || quorum.Observers[0].ReplicaID != 1 || quorum.Observers[0].Lag != 0 {
+		t.Errorf("unexpected observers %+v", quorum.Observers)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminUnregisterBroker(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"UnregisterBrokerRequest": NewMockUnregisterBrokerResponse(t, 4),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_3_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := admin.UnregisterBroker(4); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.UnregisterBroker(4); !errors.Is(err, ErrBrokerIDNotRegistered) {
+		t.Fatalf("expected ErrBrokerIDNotRegistered, got %v", err)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminDeleteRecordsWithDiffVersion(t *testing.T) {
+	topicName := "my_topic"
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader(topicName, 1, 1).
+			SetLeader(topicName, 2, 1).
+			SetLeader(topicName, 3, 1),
+		"DeleteRecordsRequest": NewMockDeleteRecordsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V0_10_2_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	partitionOffset := make(map[int32]int64)
+	partitionOffset[1] = 1000
+	partitionOffset[2] = 1000
+	partitionOffset[3] = 1000
+
+	err = admin.DeleteRecords(topicName, partitionOffset)
+	if !strings.HasPrefix(err.Error(), "kafka server: failed to delete records") {
+		t.Fatal(err)
+	}
+	deleteRecordsError, ok := err.(ErrDeleteRecords)
+
+	if !ok {
+		t.Fatal(err)
+	}
+
+	for _, err := range *deleteRecordsError.Errors {
+		if err != ErrUnsupportedVersion {
+			t.Fatal(err)
+		}
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminDescribeConfig(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"DescribeConfigsRequest": NewMockDescribeConfigsResponse(t),
+	})
+
+	tests := []struct {
+		saramaVersion   KafkaVersion
+		requestVersion  int16
+		includeSynonyms bool
+		includeType     bool
+	}{
+		{V1_0_0_0, 0, false, false},
+		{V1_1_0_0, 1, true, false},
+		{V1_1_1_0, 1, true, false},
+		{V2_0_0_0, 2, true, false},
+		{V2_6_0_0, 3, true, true},
+		{V2_8_0_0, 4, true, true},
+	}
+	for _, tt := range tests {
+		config := NewTestConfig()
+		config.Version = tt.saramaVersion
+		admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = admin.Close()
+		}()
+
+		resource := ConfigResource{
+			Name:        "r1",
+			Type:        TopicResource,
+			ConfigNames: []string{"my_topic"},
+		}
+
+		entries, err := admin.DescribeConfig(resource)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		history := seedBroker.History()
+		describeReq, ok := history[len(history)-1].Request.(*DescribeConfigsRequest)
+		if !ok {
+			t.Fatal("failed to find DescribeConfigsRequest in mockBroker history")
+		}
+
+		if describeReq.Version != tt.requestVersion {
+			t.Fatalf(
+				"requestVersion %v did not match expected %v",
+				describeReq.Version, tt.requestVersion)
+		}
+
+		if len(entries) <= 0 {
+			t.Fatal(errors.New("no resource present"))
+		}
+		if tt.includeSynonyms {
+			if len(entries[0].Synonyms) == 0 {
+				t.Fatal("expected synonyms to have been included")
+			}
+		}
+		if tt.includeType {
+			if entries[0].Type != ConfigTypeInt {
+				t.Errorf("expected type %v, got %v", ConfigTypeInt, entries[0].Type)
+			}
+			if entries[0].Documentation == "" {
+				t.Error("expected documentation to have been included")
+			}
+		}
+	}
+}
+
+func TestClusterAdminDescribeConfigWithErrorCode(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"DescribeConfigsRequest": NewMockDescribeConfigsResponseWithErrorCode(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_1_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = admin.Close()
+	}()
+
+	resource := ConfigResource{
+		Name:        "r1",
+		Type:        TopicResource,
+		ConfigNames: []string{"my_topic"},
+	}
+
+	_, err = admin.DescribeConfig(resource)
+	if err == nil {
+		t.Fatal(errors.New("ErrorCode present but no Error returned"))
+	}
+}
+
+// TestClusterAdminDescribeBrokerConfig ensures that a describe broker config
+// is sent to the broker in the resource struct, _not_ the controller
+func TestClusterAdminDescribeBrokerConfig(t *testing.T) {
+	controllerBroker := NewMockBroker(t, 1)
+	defer controllerBroker.Close()
+	configBroker := NewMockBroker(t, 2)
+	defer configBroker.Close()
+
+	controllerBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(controllerBroker.BrokerID()).
+			SetBroker(controllerBroker.Addr(), controllerBroker.BrokerID()).
+			SetBroker(configBroker.Addr(), configBroker.BrokerID()),
+	})
+
+	configBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(controllerBroker.BrokerID()).
+			SetBroker(controllerBroker.Addr(), controllerBroker.BrokerID()).
+			SetBroker(configBroker.Addr(), configBroker.BrokerID()),
+		"DescribeConfigsRequest": NewMockDescribeConfigsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin(
+		[]string{
+			controllerBroker.Addr(),
+			configBroker.Addr(),
+		}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, resourceType := range []ConfigResourceType{BrokerResource, BrokerLoggerResource} {
+		resource := ConfigResource{Name: "2", Type: resourceType}
+		entries, err := admin.DescribeConfig(resource)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(entries) <= 0 {
+			t.Fatal(errors.New("no resource present"))
+		}
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminAlterConfig(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"AlterConfigsRequest": NewMockAlterConfigsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var value string
+	entries := make(map[string]*string)
+	value = "60000"
+	entries["retention.ms"] = &value
+	err = admin.AlterConfig(TopicResource, "my_topic", entries, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminAlterConfigWithErrorCode(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"AlterConfigsRequest": NewMockAlterConfigsResponseWithErrorCode(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = admin.Close()
+	}()
+
+	var value string
+	entries := make(map[string]*string)
+	value = "60000"
+	entries["retention.ms"] = &value
+	err = admin.AlterConfig(TopicResource, "my_topic", entries, false)
+	if err == nil {
+		t.Fatal(errors.New("ErrorCode present but no Error returned"))
+	}
+}
+
+func TestClusterAdminIncrementalAlterConfig(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"IncrementalAlterConfigsRequest": NewMockIncrementalAlterConfigsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_3_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retention := "60000"
+	policy := "compact"
+	entries := map[string]IncrementalAlterConfigsEntry{
+		"retention.ms":   {Operation: IncrementalAlterConfigsOperationSet, Value: &retention},
+		"cleanup.policy": {Operation: IncrementalAlterConfigsOperationAppend, Value: &policy},
+		"segment.ms":     {Operation: IncrementalAlterConfigsOperationDelete},
+	}
+	err = admin.IncrementalAlterConfig(TopicResource, "my_topic", entries, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminIncrementalAlterConfigWithErrorCode(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"IncrementalAlterConfigsRequest": NewMockIncrementalAlterConfigsResponseWithErrorCode(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_3_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = admin.Close()
+	}()
+
+	value := "60000"
+	entries := map[string]IncrementalAlterConfigsEntry{
+		"retention.ms": {Operation: IncrementalAlterConfigsOperationSet, Value: &value},
+	}
+	err = admin.IncrementalAlterConfig(TopicResource, "my_topic", entries, false)
+	if err == nil {
+		t.Fatal(errors.New("ErrorCode present but no Error returned"))
+	}
+}
+
+func TestClusterAdminAlterBrokerConfig(t *testing.T) {
+	controllerBroker := NewMockBroker(t, 1)
+	defer controllerBroker.Close()
+	configBroker := NewMockBroker(t, 2)
+	defer configBroker.Close()
+
+	controllerBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(controllerBroker.BrokerID()).
+			SetBroker(controllerBroker.Addr(), controllerBroker.BrokerID()).
+			SetBroker(configBroker.Addr(), configBroker.BrokerID()),
+	})
+	configBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(controllerBroker.BrokerID()).
+			SetBroker(controllerBroker.Addr(), controllerBroker.BrokerID()).
+			SetBroker(configBroker.Addr(), configBroker.BrokerID()),
+		"AlterConfigsRequest": NewMockAlterConfigsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin(
+		[]string{
+			controllerBroker.Addr(),
+			configBroker.Addr(),
+		}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var value string
+	entries := make(map[string]*string)
+	value = "3"
+	entries["min.insync.replicas"] = &value
+
+	for _, resourceType := range []ConfigResourceType{BrokerResource, BrokerLoggerResource} {
+		resource := ConfigResource{Name: "2", Type: resourceType}
+		err = admin.AlterConfig(
+			resource.Type,
+			resource.Name,
+			entries,
+			false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminCreateAcl(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"CreateAclsRequest": NewMockCreateAclsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := Resource{ResourceType: AclResourceTopic, ResourceName: "my_topic"}
+	a := Acl{Host: "localhost", Operation: AclOperationAlter, PermissionType: AclPermissionAny}
+
+	err = admin.CreateACL(r, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminListAcls(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"DescribeAclsRequest": NewMockListAclsResponse(t),
+		"CreateAclsRequest":   NewMockCreateAclsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := Resource{ResourceType: AclResourceTopic, ResourceName: "my_topic"}
+	a := Acl{Host: "localhost", Operation: AclOperationAlter, PermissionType: AclPermissionAny}
+
+	err = admin.CreateACL(r, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resourceName := "my_topic"
+	filter := AclFilter{
+		ResourceType: AclResourceTopic,
+		Operation:    AclOperationRead,
+		ResourceName: &resourceName,
+	}
+
+	rAcls, err := admin.ListAcls(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rAcls) <= 0 {
+		t.Fatal("no acls present")
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminDeleteAcl(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"DeleteAclsRequest": NewMockDeleteAclsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resourceName := "my_topic"
+	filter := AclFilter{
+		ResourceType: AclResourceTopic,
+		Operation:    AclOperationAlter,
+		ResourceName: &resourceName,
+	}
+
+	_, err = admin.DeleteACL(filter, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminCreateAndDeleteACLs(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"CreateAclsRequest": NewMockCreateAclsResponse(t).
+			SetError("forbidden_topic", ErrClusterAuthorizationFailed),
+		"DeleteAclsRequest": NewMockDeleteAclsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	acl := Acl{Principal: "User:alice", Host: "*", Operation: AclOperationRead, PermissionType: AclPermissionAllow}
+	bindings := []AclCreation{
+		{Resource{AclResourceTopic, "my_topic", AclPatternLiteral}, acl},
+		{Resource{AclResourceTopic, "forbidden_topic", AclPatternLiteral}, acl},
+		{Resource{AclResourceGroup, "my-", AclPatternPrefixed}, acl},
+	}
+	created, err := admin.CreateACLs(bindings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(created))
+	}
+	for i, result := range created {
+		if result.ResourceName != bindings[i].ResourceName {
+			t.Errorf("result %d: expected resource %s, got %s", i, bindings[i].ResourceName, result.ResourceName)
+		}
+		if expectErr := i == 1; expectErr != errors.Is(result.Err, ErrClusterAuthorizationFailed) {
+			t.Errorf("result %d: unexpected error %v", i, result.Err)
+		}
+	}
+
+	if err := admin.CreateACL(bindings[1].Resource, bindings[1].Acl); !errors.Is(err, ErrClusterAuthorizationFailed) {
+		t.Errorf("expected ErrClusterAuthorizationFailed, got %v", err)
+	}
+
+	principal := "User:alice"
+	filters := []AclFilter{
+		{ResourceType: AclResourceTopic, ResourcePatternTypeFilter: AclPatternAny, Principal: &principal, Operation: AclOperationAny, PermissionType: AclPermissionAny},
+		{ResourceType: AclResourceGroup, ResourcePatternTypeFilter: AclPatternPrefixed, Operation: AclOperationRead, PermissionType: AclPermissionAllow},
+	}
+	deleted, err := admin.DeleteACLs(filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(deleted))
+	}
+	for i, result := range deleted {
+		if result.Err != nil || len(result.MatchingAcls) != 1 || result.Filter.ResourceType != filters[i].ResourceType {
+			t.Errorf("result %d: unexpected deletion %+v", i, result)
+		}
+	}
+
+	var request *DeleteAclsRequest
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*DeleteAclsRequest); ok {
+			request = req
+		}
+	}
+	if request == nil || len(request.Filters) != 2 || request.Version != 1 {
+		t.Errorf("expected a single v1 request with the 2 filters, got %+v", request)
+	}
+}
+
+func TestDescribeTopic(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topics, err := admin.DescribeTopics([]string{"my_topic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(topics) != 1 {
+		t.Fatalf("Expected 1 result, got %v", len(topics))
+	}
+
+	if topics[0].Name != "my_topic" {
+		t.Fatalf("Incorrect topic name: %v", topics[0].Name)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDescribeTopicWithVersion0_11(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+
+	config := NewTestConfig()
+	config.Version = V0_11_0_0
+
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topics, err := admin.DescribeTopics([]string{"my_topic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(topics) != 1 {
+		t.Fatalf("Expected 1 result, got %v", len(topics))
+	}
+
+	if topics[0].Name != "my_topic" {
+		t.Fatalf("Incorrect topic name: %v", topics[0].Name)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDescribeConsumerGroup(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	expectedGroupID := "my-group"
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"DescribeGroupsRequest": NewMockDescribeGroupsResponse(t).AddGroupDescription(expectedGroupID, &GroupDescription{
+			GroupId: expectedGroupID,
+		}),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).SetCoordinator(CoordinatorGroup, expectedGroupID, seedBroker),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := admin.DescribeConsumerGroups([]string{expectedGroupID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 result, got %v", len(result))
+	}
+
+	if result[0].GroupId != expectedGroupID {
+		t.Fatalf("Expected groupID %v, got %v", expectedGroupID, result[0].GroupId)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListConsumerGroups(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"ListGroupsRequest": NewMockListGroupsResponse(t).
+			AddGroup("my-group", "consumer"),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := admin.ListConsumerGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(groups) != 1 {
+		t.Fatalf("Expected %v results, got %v", 1, len(groups))
+	}
+
+	protocolType, ok := groups["my-group"]
+
+	if !ok {
+		t.Fatal("Expected group to be returned, but it did not")
+	}
+
+	if protocolType != "consumer" {
+		t.Fatalf("Expected protocolType %v, got %v", "consumer", protocolType)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
 	}
 }
 
-// TestClusterAdminDescribeBrokerConfig ensures that a describe broker config
-// is sent to the broker in the resource struct, _not_ the controller
-func TestClusterAdminDescribeBrokerConfig(t *testing.T) {
-	controllerBroker := NewMockBroker(t, 1)
-	defer controllerBroker.Close()
-	configBroker := NewMockBroker(t, 2)
-	defer configBroker.Close()
+func TestListConsumerGroupsMultiBroker(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
 
-	controllerBroker.SetHandlerByMap(map[string]MockResponse{
+	secondBroker := NewMockBroker(t, 2)
+	defer secondBroker.Close()
+
+	firstGroup := "first"
+	secondGroup := "second"
+	nonExistingGroup := "non-existing-group"
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
-			SetController(controllerBroker.BrokerID()).
-			SetBroker(controllerBroker.Addr(), controllerBroker.BrokerID()).
-			SetBroker(configBroker.Addr(), configBroker.BrokerID()),
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetBroker(secondBroker.Addr(), secondBroker.BrokerID()),
+		"ListGroupsRequest": NewMockListGroupsResponse(t).
+			AddGroup(firstGroup, "consumer"),
 	})
 
-	configBroker.SetHandlerByMap(map[string]MockResponse{
+	secondBroker.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
-			SetController(controllerBroker.BrokerID()).
-			SetBroker(controllerBroker.Addr(), controllerBroker.BrokerID()).
-			SetBroker(configBroker.Addr(), configBroker.BrokerID()),
-		"DescribeConfigsRequest": NewMockDescribeConfigsResponse(t),
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetBroker(secondBroker.Addr(), secondBroker.BrokerID()),
+		"ListGroupsRequest": NewMockListGroupsResponse(t).
+			AddGroup(secondGroup, "consumer"),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := admin.ListConsumerGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(groups) != 2 {
+		t.Fatalf("Expected %v results, got %v", 1, len(groups))
+	}
+
+	if _, found := groups[firstGroup]; !found {
+		t.Fatalf("Expected group %v to be present in result set, but it isn't", firstGroup)
+	}
+
+	if _, found := groups[secondGroup]; !found {
+		t.Fatalf("Expected group %v to be present in result set, but it isn't", secondGroup)
+	}
+
+	if _, found := groups[nonExistingGroup]; found {
+		t.Fatalf("Expected group %v to not exist, but it exists", nonExistingGroup)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListConsumerGroupsWithFilters(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"ListGroupsRequest": NewMockListGroupsResponse(t).
+			AddGroupWithState("stable-group", "consumer", GroupStateStable, GroupTypeClassic).
+			AddGroupWithState("empty-group", "consumer", GroupStateEmpty, GroupTypeClassic).
+			AddGroupWithState("new-group", "consumer", GroupStateEmpty, GroupTypeConsumer),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_8_0_0
+
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	groups, err := admin.ListConsumerGroupsWithFilters([]string{GroupStateEmpty}, []string{GroupTypeClassic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("Expected %v results, got %v", 1, len(groups))
+	}
+	expected := &ConsumerGroupListing{
+		GroupID:      "empty-group",
+		ProtocolType: "consumer",
+		State:        GroupStateEmpty,
+		Type:         GroupTypeClassic,
+	}
+	if !reflect.DeepEqual(groups["empty-group"], expected) {
+		t.Errorf("Expected %+v, got %+v", expected, groups["empty-group"])
+	}
+
+	all, err := admin.ListConsumerGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("Expected %v results, got %v", 3, len(all))
+	}
+}
+
+func TestListConsumerGroupsWithFiltersUnsupported(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_6_0_0
+
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	_, err = admin.ListConsumerGroupsWithFilters(nil, []string{GroupTypeConsumer})
+	if !errors.As(err, new(ConfigurationError)) {
+		t.Fatalf("expected a ConfigurationError, got %v", err)
+	}
+}
+
+func TestListConsumerGroupOffsets(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	group := "my-group"
+	topic := "my-topic"
+	partition := int32(0)
+	expectedOffset := int64(0)
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"OffsetFetchRequest": NewMockOffsetFetchResponse(t).SetOffset(group, "my-topic", partition, expectedOffset, "", ErrNoError).SetError(ErrNoError),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).SetCoordinator(CoordinatorGroup, group, seedBroker),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := admin.ListConsumerGroupOffsets(group, map[string][]int32{
+		topic: {0},
+	})
+	if err != nil {
+		t.Fatalf("ListConsumerGroupOffsets failed with error %v", err)
+	}
+
+	block := response.GetBlock(topic, partition)
+	if block == nil {
+		t.Fatalf("Expected block for topic %v and partition %v to exist, but it doesn't", topic, partition)
+	}
+
+	if block.Offset != expectedOffset {
+		t.Fatalf("Expected offset %v, got %v", expectedOffset, block.Offset)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListConsumerGroupOffsetsCoordinatorRetriesExhausted(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	group := "my-group"
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"OffsetFetchRequest": NewMockOffsetFetchResponse(t).SetError(ErrOffsetsLoadInProgress),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).SetCoordinator(CoordinatorGroup, group, seedBroker),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	config.Admin.Retry.Max = 2
+	config.Admin.Retry.Backoff = 0
+
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	_, err = admin.ListConsumerGroupOffsets(group, map[string][]int32{"my-topic": {0}})
+	if !errors.Is(err, ErrOffsetsLoadInProgress) {
+		t.Fatalf("expected %v, got %v", ErrOffsetsLoadInProgress, err)
+	}
+
+	fetches := 0
+	for _, rr := range seedBroker.History() {
+		if _, ok := rr.Request.(*OffsetFetchRequest); ok {
+			fetches++
+		}
+	}
+	if fetches != config.Admin.Retry.Max {
+		t.Errorf("expected %d offset fetch requests, got %d", config.Admin.Retry.Max, fetches)
+	}
+}
+
+func TestDeleteConsumerGroupRetriesCoordinatorErrors(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	group := "my-group"
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"DeleteGroupsRequest": NewMockSequence(
+			NewMockWrapper(&DeleteGroupsResponse{
+				GroupErrorCodes: map[string]KError{group: ErrNotCoordinatorForConsumer},
+			}),
+			NewMockWrapper(&DeleteGroupsResponse{
+				GroupErrorCodes: map[string]KError{group: ErrOffsetsLoadInProgress},
+			}),
+			NewMockDeleteGroupsRequest(t).SetDeletedGroups([]string{group}),
+		),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).SetCoordinator(CoordinatorGroup, group, seedBroker),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_1_0_0
+	config.Admin.Retry.Backoff = 0
+
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	if err := admin.DeleteConsumerGroup(group); err != nil {
+		t.Fatalf("DeleteConsumerGroup failed with error %v", err)
+	}
+
+	deletes, findCoordinators := 0, 0
+	for _, rr := range seedBroker.History() {
+		switch rr.Request.(type) {
+		case *DeleteGroupsRequest:
+			deletes++
+		case *FindCoordinatorRequest:
+			findCoordinators++
+		}
+	}
+	if deletes != 3 {
+		t.Errorf("expected 3 DeleteGroupsRequests, got %d", deletes)
+	}
+	// the coordinator is only looked up again after it moved
+	if findCoordinators != 2 {
+		t.Errorf("expected 2 FindCoordinatorRequests, got %d", findCoordinators)
+	}
+}
+
+func TestDescribeConsumerGroupRetriesCoordinatorErrors(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"DescribeGroupsRequest": NewMockSequence(
+			NewMockWrapper(&DescribeGroupsResponse{
+				Groups: []*GroupDescription{
+					{GroupId: "loading", Err: ErrOffsetsLoadInProgress},
+					{GroupId: "stable", State: "Stable"},
+				},
+			}),
+			NewMockWrapper(&DescribeGroupsResponse{
+				Groups: []*GroupDescription{
+					{GroupId: "loading", State: "Empty"},
+				},
+			}),
+		),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "loading", seedBroker).
+			SetCoordinator(CoordinatorGroup, "stable", seedBroker),
 	})
 
 	config := NewTestConfig()
 	config.Version = V1_0_0_0
-	admin, err := NewClusterAdmin(
-		[]string{
-			controllerBroker.Addr(),
-			configBroker.Addr(),
-		}, config)
+	config.Admin.Retry.Backoff = 0
+
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer safeClose(t, admin)
 
-	for _, resourceType := range []ConfigResourceType{BrokerResource, BrokerLoggerResource} {
-		resource := ConfigResource{Name: "2", Type: resourceType}
-		entries, err := admin.DescribeConfig(resource)
-		if err != nil {
-			t.Fatal(err)
-		}
+	result, err := admin.DescribeConsumerGroups([]string{"stable", "loading"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(result))
+	}
+	if result[0].GroupId != "stable" || result[0].State != "Stable" {
+		t.Errorf("unexpected description of the stable group %+v", result[0])
+	}
+	if result[1].GroupId != "loading" || result[1].State != "Empty" || result[1].Err != ErrNoError {
+		t.Errorf("unexpected description of the loading group %+v", result[1])
+	}
 
-		if len(entries) <= 0 {
-			t.Fatal(errors.New("no resource present"))
+	var retried []string
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*DescribeGroupsRequest); ok {
+			retried = req.Groups
 		}
 	}
-
-	err = admin.Close()
-	if err != nil {
-		t.Fatal(err)
+	if !reflect.DeepEqual(retried, []string{"loading"}) {
+		t.Errorf("expected only the loading group to be described again, got %v", retried)
 	}
 }
 
-func TestClusterAdminAlterConfig(t *testing.T) {
+func TestDeleteConsumerGroupCoordinatorErrorRetriesExhausted(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
+	group := "my-group"
+
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"DeleteGroupsRequest": NewMockWrapper(&DeleteGroupsResponse{
+			GroupErrorCodes: map[string]KError{group: ErrOffsetsLoadInProgress},
+		}),
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
-		"AlterConfigsRequest": NewMockAlterConfigsResponse(t),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).SetCoordinator(CoordinatorGroup, group, seedBroker),
 	})
 
 	config := NewTestConfig()
-	config.Version = V1_0_0_0
+	config.Version = V1_1_0_0
+	config.Admin.Retry.Max = 3
+	config.Admin.Retry.Backoff = 0
+
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer safeClose(t, admin)
 
-	var value string
-	entries := make(map[string]*string)
-	value = "60000"
-	entries["retention.ms"] = &value
-	err = admin.AlterConfig(TopicResource, "my_topic", entries, false)
-	if err != nil {
-		t.Fatal(err)
+	if err := admin.DeleteConsumerGroup(group); !errors.Is(err, ErrOffsetsLoadInProgress) {
+		t.Fatalf("expected ErrOffsetsLoadInProgress, got %v", err)
 	}
 
-	err = admin.Close()
-	if err != nil {
-		t.Fatal(err)
+	deletes := 0
+	for _, rr := range seedBroker.History() {
+		if _, ok := rr.Request.(*DeleteGroupsRequest); ok {
+			deletes++
+		}
+	}
+	if deletes != 3 {
+		t.Errorf("expected 3 DeleteGroupsRequests, got %d", deletes)
 	}
 }
 
-func TestClusterAdminAlterConfigWithErrorCode(t *testing.T) {
+func TestDeleteConsumerGroup(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
+	group := "my-group"
+
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		// "OffsetFetchRequest":  NewMockOffsetFetchResponse(t).SetOffset(group, "my-topic", partition, expectedOffset, "", ErrNoError),
+		"DeleteGroupsRequest": NewMockDeleteGroupsRequest(t).SetDeletedGroups([]string{group}),
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
-		"AlterConfigsRequest": NewMockAlterConfigsResponseWithErrorCode(t),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).SetCoordinator(CoordinatorGroup, group, seedBroker),
 	})
 
 	config := NewTestConfig()
-	config.Version = V1_0_0_0
+	config.Version = V1_1_0_0
+
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		_ = admin.Close()
-	}()
 
-	var value string
-	entries := make(map[string]*string)
-	value = "60000"
-	entries["retention.ms"] = &value
-	err = admin.AlterConfig(TopicResource, "my_topic", entries, false)
-	if err == nil {
-		t.Fatal(errors.New("ErrorCode present but no Error returned"))
+	err = admin.DeleteConsumerGroup(group)
+	if err != nil {
+		t.Fatalf("DeleteConsumerGroup failed with error %v", err)
 	}
 }
 
-func TestClusterAdminAlterBrokerConfig(t *testing.T) {
-	controllerBroker := NewMockBroker(t, 1)
-	defer controllerBroker.Close()
-	configBroker := NewMockBroker(t, 2)
-	defer configBroker.Close()
+func TestDeleteOffset(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
 
-	controllerBroker.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": NewMockMetadataResponse(t).
-			SetController(controllerBroker.BrokerID()).
-			SetBroker(controllerBroker.Addr(), controllerBroker.BrokerID()).
-			SetBroker(configBroker.Addr(), configBroker.BrokerID()),
-	})
-	configBroker.SetHandlerByMap(map[string]MockResponse{
+	group := "group-delete-offset"
+	topic := "topic-delete-offset"
+	partition := int32(0)
+
+	handlerMap := map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
-			SetController(controllerBroker.BrokerID()).
-			SetBroker(controllerBroker.Addr(), controllerBroker.BrokerID()).
-			SetBroker(configBroker.Addr(), configBroker.BrokerID()),
-		"AlterConfigsRequest": NewMockAlterConfigsResponse(t),
-	})
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).SetCoordinator(CoordinatorGroup, group, seedBroker),
+	}
+	seedBroker.SetHandlerByMap(handlerMap)
 
 	config := NewTestConfig()
-	config.Version = V1_0_0_0
-	admin, err := NewClusterAdmin(
-		[]string{
-			controllerBroker.Addr(),
-			configBroker.Addr(),
-		}, config)
+	config.Version = V2_4_0_0
+
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	var value string
-	entries := make(map[string]*string)
-	value = "3"
-	entries["min.insync.replicas"] = &value
+	// Test NoError
+	handlerMap["DeleteOffsetsRequest"] = NewMockDeleteOffsetRequest(t).SetDeletedOffset(ErrNoError, topic, partition, ErrNoError)
+	err = admin.DeleteConsumerGroupOffset(group, topic, partition)
+	if err != nil {
+		t.Fatalf("DeleteConsumerGroupOffset failed with error %v", err)
+	}
 
-	for _, resourceType := range []ConfigResourceType{BrokerResource, BrokerLoggerResource} {
-		resource := ConfigResource{Name: "2", Type: resourceType}
-		err = admin.AlterConfig(
-			resource.Type,
-			resource.Name,
-			entries,
-			false)
-		if err != nil {
-			t.Fatal(err)
-		}
+	// Test Error
+	handlerMap["DeleteOffsetsRequest"] = NewMockDeleteOffsetRequest(t).SetDeletedOffset(ErrNotCoordinatorForConsumer, topic, partition, ErrNoError)
+	err = admin.DeleteConsumerGroupOffset(group, topic, partition)
+	if err != ErrNotCoordinatorForConsumer {
+		t.Fatalf("DeleteConsumerGroupOffset should have failed with error %v", ErrNotCoordinatorForConsumer)
 	}
 
-	err = admin.Close()
-	if err != nil {
-		t.Fatal(err)
+	// Test Error for partition
+	handlerMap["DeleteOffsetsRequest"] = NewMockDeleteOffsetRequest(t).SetDeletedOffset(ErrNoError, topic, partition, ErrGroupSubscribedToTopic)
+	err = admin.DeleteConsumerGroupOffset(group, topic, partition)
+	if err != ErrGroupSubscribedToTopic {
+		t.Fatalf("DeleteConsumerGroupOffset should have failed with error %v", ErrGroupSubscribedToTopic)
 	}
 }
 
-func TestClusterAdminCreateAcl(t *testing.T) {
+func TestDeleteOffsets(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
+	group := "group-delete-offsets"
+
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
-		"CreateAclsRequest": NewMockCreateAclsResponse(t),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).SetCoordinator(CoordinatorGroup, group, seedBroker),
+		"DeleteOffsetsRequest": NewMockDeleteOffsetRequest(t).
+			SetDeletedOffset(ErrNoError, "old-topic", 0, ErrNoError).
+			SetDeletedOffset(ErrNoError, "old-topic", 1, ErrNoError).
+			SetDeletedOffset(ErrNoError, "current-topic", 0, ErrGroupSubscribedToTopic),
 	})
 
 	config := NewTestConfig()
-	config.Version = V1_0_0_0
+	config.Version = V2_4_0_0
+
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	r := Resource{ResourceType: AclResourceTopic, ResourceName: "my_topic"}
-	a := Acl{Host: "localhost", Operation: AclOperationAlter, PermissionType: AclPermissionAny}
-
-	err = admin.CreateACL(r, a)
+	errs, err := admin.DeleteConsumerGroupOffsets(group, map[string][]int32{
+		"old-topic":     {0, 1},
+		"current-topic": {0},
+	})
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("DeleteConsumerGroupOffsets failed with error %v", err)
+	}
+	if errs["old-topic"][0] != ErrNoError || errs["old-topic"][1] != ErrNoError {
+		t.Errorf("unexpected errors for old-topic %v", errs["old-topic"])
+	}
+	if errs["current-topic"][0] != ErrGroupSubscribedToTopic {
+		t.Errorf("expected ErrGroupSubscribedToTopic for current-topic, got %v", errs["current-topic"][0])
 	}
 
-	err = admin.Close()
+	_, err = admin.DeleteConsumerGroupOffsets(group, map[string][]int32{"other-topic": {0}})
+	if err != ErrIncompleteResponse {
+		t.Errorf("expected ErrIncompleteResponse, got %v", err)
+	}
+}
+
+// TestRefreshMetaDataWithDifferentController ensures that the cached
+// controller can be forcibly updated from Metadata by the admin client
+func TestRefreshMetaDataWithDifferentController(t *testing.T) {
+	seedBroker1 := NewMockBroker(t, 1)
+	seedBroker2 := NewMockBroker(t, 2)
+	defer seedBroker1.Close()
+	defer seedBroker2.Close()
+
+	seedBroker1.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker1.BrokerID()).
+			SetBroker(seedBroker1.Addr(), seedBroker1.BrokerID()).
+			SetBroker(seedBroker2.Addr(), seedBroker2.BrokerID()),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_1_0_0
+
+	client, err := NewClient([]string{seedBroker1.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	ca := clusterAdmin{client: client, conf: config}
+
+	if b, _ := ca.Controller(); seedBroker1.BrokerID() != b.ID() {
+		t.Fatalf("expected cached controller to be %d rather than %d",
+			seedBroker1.BrokerID(), b.ID())
+	}
+
+	seedBroker1.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker2.BrokerID()).
+			SetBroker(seedBroker1.Addr(), seedBroker1.BrokerID()).
+			SetBroker(seedBroker2.Addr(), seedBroker2.BrokerID()),
+	})
+
+	if b, _ := ca.refreshController(); seedBroker2.BrokerID() != b.ID() {
+		t.Fatalf("expected refreshed controller to be %d rather than %d",
+			seedBroker2.BrokerID(), b.ID())
+	}
+
+	if b, _ := ca.Controller(); seedBroker2.BrokerID() != b.ID() {
+		t.Fatalf("expected cached controller to be %d rather than %d",
+			seedBroker2.BrokerID(), b.ID())
+	}
 }
 
-func TestClusterAdminListAcls(t *testing.T) {
+func TestDescribeLogDirs(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
@@ -947,111 +2922,126 @@ func TestClusterAdminListAcls(t *testing.T) {
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
-		"DescribeAclsRequest": NewMockListAclsResponse(t),
-		"CreateAclsRequest":   NewMockCreateAclsResponse(t),
+		"DescribeLogDirsRequest": NewMockDescribeLogDirsResponse(t).
+			SetLogDirs("/tmp/logs", map[string]int{"topic1": 2, "topic2": 2}),
 	})
 
 	config := NewTestConfig()
 	config.Version = V1_0_0_0
+
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	r := Resource{ResourceType: AclResourceTopic, ResourceName: "my_topic"}
-	a := Acl{Host: "localhost", Operation: AclOperationAlter, PermissionType: AclPermissionAny}
-
-	err = admin.CreateACL(r, a)
+	logDirsPerBroker, err := admin.DescribeLogDirs([]int32{seedBroker.BrokerID()})
 	if err != nil {
 		t.Fatal(err)
 	}
-	resourceName := "my_topic"
-	filter := AclFilter{
-		ResourceType: AclResourceTopic,
-		Operation:    AclOperationRead,
-		ResourceName: &resourceName,
-	}
 
-	rAcls, err := admin.ListAcls(filter)
-	if err != nil {
-		t.Fatal(err)
+	if len(logDirsPerBroker) != 1 {
+		t.Fatalf("Expected %v results, got %v", 1, len(logDirsPerBroker))
 	}
-	if len(rAcls) <= 0 {
-		t.Fatal("no acls present")
+	logDirs := logDirsPerBroker[seedBroker.BrokerID()]
+	if len(logDirs) != 1 {
+		t.Fatalf("Expected log dirs for broker %v to be returned, but it did not, got %v", seedBroker.BrokerID(), len(logDirs))
+	}
+	logDirsBroker := logDirs[0]
+	if logDirsBroker.ErrorCode != ErrNoError {
+		t.Fatalf("Expected no error for broker %v, but it was %v", seedBroker.BrokerID(), logDirsBroker.ErrorCode)
+	}
+	if logDirsBroker.Path != "/tmp/logs" {
+		t.Fatalf("Expected log dirs for broker %v to be '/tmp/logs', but it was %v", seedBroker.BrokerID(), logDirsBroker.Path)
+	}
+	if len(logDirsBroker.Topics) != 2 {
+		t.Fatalf("Expected log dirs for broker %v to have 2 topics, but it had %v", seedBroker.BrokerID(), len(logDirsBroker.Topics))
 	}
-
 	err = admin.Close()
 	if err != nil {
 		t.Fatal(err)
 	}
 }
 
-func TestClusterAdminDeleteAcl(t *testing.T) {
+func TestClusterAdminDescribeClientQuotas(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
+	user := []QuotaEntityComponent{{EntityType: QuotaEntityUser, MatchType: QuotaMatchExact, Name: "tenant-a"}}
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
-		"DeleteAclsRequest": NewMockDeleteAclsResponse(t),
+		"DescribeClientQuotasRequest": NewMockDescribeClientQuotasResponse(t).
+			AddEntry(user, map[string]float64{QuotaProducerByteRate: 1024}),
 	})
 
 	config := NewTestConfig()
-	config.Version = V1_0_0_0
+	config.Version = V2_6_0_0
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	resourceName := "my_topic"
-	filter := AclFilter{
-		ResourceType: AclResourceTopic,
-		Operation:    AclOperationAlter,
-		ResourceName: &resourceName,
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	_, err = admin.DeleteACL(filter, false)
+	entries, err := admin.DescribeClientQuotas([]QuotaFilterComponent{{
+		EntityType: QuotaEntityUser,
+		MatchType:  QuotaMatchExact,
+		Match:      "tenant-a",
+	}}, true)
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	if len(entries) != 1 || entries[0].Values[QuotaProducerByteRate] != 1024 {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+
 	err = admin.Close()
 	if err != nil {
 		t.Fatal(err)
 	}
 }
 
-func TestDescribeTopic(t *testing.T) {
+func TestClusterAdminAlterClientQuotasEntries(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
-			SetLeader("my_topic", 0, seedBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"AlterClientQuotasRequest": NewMockAlterClientQuotasResponse(t),
 	})
 
 	config := NewTestConfig()
-	config.Version = V1_0_0_0
-
+	config.Version = V2_6_0_0
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	topics, err := admin.DescribeTopics([]string{"my_topic"})
+	err = admin.AlterClientQuotasEntries([]AlterClientQuotasEntry{
+		{
+			Entity: []QuotaEntityComponent{{EntityType: QuotaEntityUser, MatchType: QuotaMatchDefault}},
+			Ops: []ClientQuotasOp{
+				{Key: QuotaProducerByteRate, Value: 1024},
+				{Key: QuotaConsumerByteRate, Value: 2048},
+			},
+		},
+		{
+			Entity: []QuotaEntityComponent{{EntityType: QuotaEntityIP, MatchType: QuotaMatchExact, Name: "10.0.0.1"}},
+			Ops:    []ClientQuotasOp{{Key: QuotaIPConnectionCreationRate, Remove: true}},
+		},
+	}, false)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if len(topics) != 1 {
-		t.Fatalf("Expected 1 result, got %v", len(topics))
-	}
-
-	if topics[0].Name != "my_topic" {
-		t.Fatalf("Incorrect topic name: %v", topics[0].Name)
+	err = admin.AlterClientQuotas(
+		[]QuotaEntityComponent{{EntityType: QuotaEntityClientID, MatchType: QuotaMatchExact, Name: "producer"}},
+		ClientQuotasOp{Key: QuotaRequestPercentage, Value: 50},
+		true)
+	if err != nil {
+		t.Fatal(err)
 	}
 
 	err = admin.Close()
@@ -1060,36 +3050,40 @@ func TestDescribeTopic(t *testing.T) {
 	}
 }
 
-func TestDescribeTopicWithVersion0_11(t *testing.T) {
+func TestClusterAdminDescribeUserScramCredentials(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
-			SetLeader("my_topic", 0, seedBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"DescribeUserScramCredentialsRequest": NewMockDescribeUserScramCredentialsResponse(t).
+			SetCredential("alice", SCRAM_MECHANISM_SHA_512, 8192),
 	})
 
 	config := NewTestConfig()
-	config.Version = V0_11_0_0
-
+	config.Version = V2_7_0_0
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	topics, err := admin.DescribeTopics([]string{"my_topic"})
+	results, err := admin.DescribeUserScramCredentials([]string{"alice", "bob"})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if len(topics) != 1 {
-		t.Fatalf("Expected 1 result, got %v", len(topics))
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
 	}
-
-	if topics[0].Name != "my_topic" {
-		t.Fatalf("Incorrect topic name: %v", topics[0].Name)
+	if results[0].ErrorCode != ErrNoError || len(results[0].CredentialInfos) != 1 ||
+		results[0].CredentialInfos[0].Mechanism != SCRAM_MECHANISM_SHA_512 ||
+		results[0].CredentialInfos[0].Iterations != 8192 {
+		t.Errorf("unexpected result for alice %+v", results[0])
+	}
+	if results[1].ErrorCode != ErrResourceNotFound {
+		t.Errorf("expected ErrResourceNotFound for bob, got %v", results[1].ErrorCode)
 	}
 
 	err = admin.Close()
@@ -1098,41 +3092,47 @@ func TestDescribeTopicWithVersion0_11(t *testing.T) {
 	}
 }
 
-func TestDescribeConsumerGroup(t *testing.T) {
+func TestClusterAdminAlterUserScramCredentials(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
-	expectedGroupID := "my-group"
-
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
-		"DescribeGroupsRequest": NewMockDescribeGroupsResponse(t).AddGroupDescription(expectedGroupID, &GroupDescription{
-			GroupId: expectedGroupID,
-		}),
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
-		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).SetCoordinator(CoordinatorGroup, expectedGroupID, seedBroker),
+		"AlterUserScramCredentialsRequest": NewMockAlterUserScramCredentialsResponse(t),
 	})
 
 	config := NewTestConfig()
-	config.Version = V1_0_0_0
-
+	config.Version = V2_7_0_0
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	result, err := admin.DescribeConsumerGroups([]string{expectedGroupID})
+	upsert := []AlterUserScramCredentialsUpsert{{
+		Name:      "alice",
+		Mechanism: SCRAM_MECHANISM_SHA_256,
+		Password:  []byte("alice-secret"),
+	}}
+	results, err := admin.AlterUserScramCredentials(upsert, []AlterUserScramCredentialsDelete{{
+		Name:      "bob",
+		Mechanism: SCRAM_MECHANISM_SHA_512,
+	}})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if len(result) != 1 {
-		t.Fatalf("Expected 1 result, got %v", len(result))
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
 	}
-
-	if result[0].GroupId != expectedGroupID {
-		t.Fatalf("Expected groupID %v, got %v", expectedGroupID, result[0].GroupId)
+	for _, result := range results {
+		if result.ErrorCode != ErrNoError {
+			t.Errorf("user %s: unexpected error %v", result.User, result.ErrorCode)
+		}
+	}
+	if upsert[0].Iterations != 0 || upsert[0].Salt != nil {
+		t.Error("expected the given upsertions to be left untouched")
 	}
 
 	err = admin.Close()
@@ -1141,43 +3141,49 @@ func TestDescribeConsumerGroup(t *testing.T) {
 	}
 }
 
-func TestListConsumerGroups(t *testing.T) {
+func TestClusterAdminDescribeClusterDetails(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
+	// Describe, Alter and DescribeConfigs
+	operations := int32(1<<AclOperationDescribe | 1<<AclOperationAlter | 1<<AclOperationDescribeConfigs)
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
-			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
-		"ListGroupsRequest": NewMockListGroupsResponse(t).
-			AddGroup("my-group", "consumer"),
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetClusterID("my_cluster").
+			SetClusterAuthorizedOperations(operations),
 	})
 
 	config := NewTestConfig()
-	config.Version = V1_0_0_0
-
+	config.Version = V2_3_0_0
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	groups, err := admin.ListConsumerGroups()
+	description, err := admin.DescribeClusterDetails(true)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if len(groups) != 1 {
-		t.Fatalf("Expected %v results, got %v", 1, len(groups))
+	if description.ClusterID != "my_cluster" {
+		t.Errorf("expected cluster ID my_cluster, got %q", description.ClusterID)
 	}
-
-	protocolType, ok := groups["my-group"]
-
-	if !ok {
-		t.Fatal("Expected group to be returned, but it did not")
+	if description.ControllerID != seedBroker.BrokerID() || len(description.Brokers) != 1 {
+		t.Errorf("unexpected controller %d and brokers %v", description.ControllerID, description.Brokers)
+	}
+	expected := []AclOperation{AclOperationAlter, AclOperationDescribe, AclOperationDescribeConfigs}
+	if !reflect.DeepEqual(description.AuthorizedOperations, expected) {
+		t.Errorf("expected authorized operations %v, got %v", expected, description.AuthorizedOperations)
 	}
 
-	if protocolType != "consumer" {
-		t.Fatalf("Expected protocolType %v, got %v", "consumer", protocolType)
+	description, err = admin.DescribeClusterDetails(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if description.AuthorizedOperations != nil {
+		t.Errorf("expected no authorized operations, got %v", description.AuthorizedOperations)
 	}
 
 	err = admin.Close()
@@ -1186,62 +3192,70 @@ func TestListConsumerGroups(t *testing.T) {
 	}
 }
 
-func TestListConsumerGroupsMultiBroker(t *testing.T) {
+func TestClusterAdminDelegationTokens(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
-	secondBroker := NewMockBroker(t, 2)
-	defer secondBroker.Close()
-
-	firstGroup := "first"
-	secondGroup := "second"
-	nonExistingGroup := "non-existing-group"
-
+	owner := KafkaPrincipal{Type: "User", Name: "bob"}
+	tokens := NewMockDelegationTokenResponse(t, owner)
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
-			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
-			SetBroker(secondBroker.Addr(), secondBroker.BrokerID()),
-		"ListGroupsRequest": NewMockListGroupsResponse(t).
-			AddGroup(firstGroup, "consumer"),
-	})
-
-	secondBroker.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": NewMockMetadataResponse(t).
-			SetController(seedBroker.BrokerID()).
-			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
-			SetBroker(secondBroker.Addr(), secondBroker.BrokerID()),
-		"ListGroupsRequest": NewMockListGroupsResponse(t).
-			AddGroup(secondGroup, "consumer"),
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"CreateDelegationTokenRequest":   tokens,
+		"RenewDelegationTokenRequest":    tokens,
+		"ExpireDelegationTokenRequest":   tokens,
+		"DescribeDelegationTokenRequest": tokens,
 	})
 
 	config := NewTestConfig()
-	config.Version = V1_0_0_0
-
+	config.Version = V2_0_0_0
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	groups, err := admin.ListConsumerGroups()
+	renewers := []KafkaPrincipal{{Type: "User", Name: "alice"}}
+	token, err := admin.CreateDelegationToken(renewers, time.Hour)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if token.Owner != owner || token.TokenID == "" || len(token.HMAC) == 0 {
+		t.Errorf("unexpected token %+v", token)
+	}
+	if !token.MaxTime.Equal(token.IssueTime.Add(time.Hour)) {
+		t.Errorf("expected a max lifetime of 1h, got %v", token.MaxTime.Sub(token.IssueTime))
+	}
 
-	if len(groups) != 2 {
-		t.Fatalf("Expected %v results, got %v", 1, len(groups))
+	expiry, err := admin.RenewDelegationToken(token.HMAC, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiry.Equal(token.MaxTime) {
+		t.Errorf("expected the renewal to be capped to %v, got %v", token.MaxTime, expiry)
 	}
 
-	if _, found := groups[firstGroup]; !found {
-		t.Fatalf("Expected group %v to be present in result set, but it isn't", firstGroup)
+	described, err := admin.DescribeDelegationToken([]KafkaPrincipal{owner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(described) != 1 || described[0].TokenID != token.TokenID || !reflect.DeepEqual(described[0].Renewers, renewers) {
+		t.Errorf("unexpected tokens %+v", described)
 	}
 
-	if _, found := groups[secondGroup]; !found {
-		t.Fatalf("Expected group %v to be present in result set, but it isn't", secondGroup)
+	if _, err := admin.ExpireDelegationToken(token.HMAC, -1); err != nil {
+		t.Fatal(err)
+	}
+	described, err = admin.DescribeDelegationToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(described) != 0 {
+		t.Errorf("expected the token to be expired, got %+v", described)
 	}
 
-	if _, found := groups[nonExistingGroup]; found {
-		t.Fatalf("Expected group %v to not exist, but it exists", nonExistingGroup)
+	if _, err := admin.RenewDelegationToken([]byte("unknown"), -1); err != ErrDelegationTokenNotFound {
+		t.Errorf("expected ErrDelegationTokenNotFound, got %v", err)
 	}
 
 	err = admin.Close()
@@ -1250,97 +3264,136 @@ func TestListConsumerGroupsMultiBroker(t *testing.T) {
 	}
 }
 
-func TestListConsumerGroupOffsets(t *testing.T) {
+func TestAlterConsumerGroupOffsets(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
-	group := "my-group"
-	topic := "my-topic"
-	partition := int32(0)
-	expectedOffset := int64(0)
+	group := "group-alter-offsets"
 
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
-		"OffsetFetchRequest": NewMockOffsetFetchResponse(t).SetOffset(group, "my-topic", partition, expectedOffset, "", ErrNoError).SetError(ErrNoError),
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
-			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my-topic", 0, seedBroker.BrokerID()).
+			SetLeader("my-topic", 1, seedBroker.BrokerID()),
 		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).SetCoordinator(CoordinatorGroup, group, seedBroker),
+		"OffsetCommitRequest": NewMockOffsetCommitResponse(t).
+			SetError(group, "my-topic", 1, ErrUnknownMemberId),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetVersion(6).
+			SetOffset("my-topic", 0, 1477920049286, 42).
+			SetOffset("my-topic", 1, OffsetOldest, 10).
+			SetLeaderEpoch("my-topic", 0, 3).
+			SetLeaderEpoch("my-topic", 1, 3),
 	})
 
 	config := NewTestConfig()
-	config.Version = V1_0_0_0
+	config.Version = V2_5_0_0
 
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	response, err := admin.ListConsumerGroupOffsets(group, map[string][]int32{
-		topic: {0},
+	errs, err := admin.AlterConsumerGroupOffsets(group, map[string]map[int32]int64{
+		"my-topic": {0: 100, 1: 200},
 	})
 	if err != nil {
-		t.Fatalf("ListConsumerGroupOffsets failed with error %v", err)
+		t.Fatalf("AlterConsumerGroupOffsets failed with error %v", err)
 	}
-
-	block := response.GetBlock(topic, partition)
-	if block == nil {
-		t.Fatalf("Expected block for topic %v and partition %v to exist, but it doesn't", topic, partition)
+	if errs["my-topic"][0] != ErrNoError || errs["my-topic"][1] != ErrUnknownMemberId {
+		t.Errorf("unexpected errors %v", errs["my-topic"])
 	}
 
-	if block.Offset != expectedOffset {
-		t.Fatalf("Expected offset %v, got %v", expectedOffset, block.Offset)
+	errs, err = admin.ResetConsumerGroupOffsets(group, map[string]map[int32]int64{
+		"my-topic": {0: 1477920049286, 1: OffsetOldest},
+	})
+	if err != nil {
+		t.Fatalf("ResetConsumerGroupOffsets failed with error %v", err)
+	}
+	if errs["my-topic"][0] != ErrNoError {
+		t.Errorf("unexpected error %v", errs["my-topic"][0])
 	}
 
-	err = admin.Close()
-	if err != nil {
-		t.Fatal(err)
+	var commit *OffsetCommitRequest
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*OffsetCommitRequest); ok {
+			commit = req
+		}
+	}
+	if commit == nil {
+		t.Fatal("no offset commit request was sent")
+	}
+	if commit.ConsumerGroupGeneration != GroupGenerationUndefined || commit.ConsumerID != "" {
+		t.Errorf("unexpected member %d/%q", commit.ConsumerGroupGeneration, commit.ConsumerID)
+	}
+	if block := commit.blocks["my-topic"][0]; block.offset != 42 || block.committedLeaderEpoch != 3 {
+		t.Errorf("my-topic-0: unexpected commit %+v", block)
+	}
+	if block := commit.blocks["my-topic"][1]; block.offset != 10 {
+		t.Errorf("my-topic-1: unexpected commit %+v", block)
 	}
 }
 
-func TestDeleteConsumerGroup(t *testing.T) {
+func TestAlterConsumerGroupOffsetsCoordinatorRetriesExhausted(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
-	group := "my-group"
+	group := "group-alter-offsets"
 
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
-		// "OffsetFetchRequest":  NewMockOffsetFetchResponse(t).SetOffset(group, "my-topic", partition, expectedOffset, "", ErrNoError),
-		"DeleteGroupsRequest": NewMockDeleteGroupsRequest(t).SetDeletedGroups([]string{group}),
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
 		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).SetCoordinator(CoordinatorGroup, group, seedBroker),
+		"OffsetCommitRequest": NewMockOffsetCommitResponse(t).
+			SetError(group, "my-topic", 0, ErrNotCoordinatorForConsumer),
 	})
 
 	config := NewTestConfig()
-	config.Version = V1_1_0_0
+	config.Version = V2_5_0_0
+	config.Admin.Retry.Max = 2
+	config.Admin.Retry.Backoff = 0
 
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer safeClose(t, admin)
 
-	err = admin.DeleteConsumerGroup(group)
-	if err != nil {
-		t.Fatalf("DeleteConsumerGroup failed with error %v", err)
+	errs, err := admin.AlterConsumerGroupOffsets(group, map[string]map[int32]int64{
+		"my-topic": {0: 100},
+	})
+	if !errors.Is(err, ErrNotCoordinatorForConsumer) {
+		t.Fatalf("expected %v, got %v", ErrNotCoordinatorForConsumer, err)
+	}
+	if errs["my-topic"][0] != ErrNotCoordinatorForConsumer {
+		t.Errorf("unexpected errors %v", errs["my-topic"])
 	}
 }
 
-func TestDeleteOffset(t *testing.T) {
+func TestRemoveMembersFromConsumerGroup(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
-	group := "group-delete-offset"
-	topic := "topic-delete-offset"
-	partition := int32(0)
-
-	handlerMap := map[string]MockResponse{
+	group := "my-group"
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
 		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).SetCoordinator(CoordinatorGroup, group, seedBroker),
-	}
-	seedBroker.SetHandlerByMap(handlerMap)
+		"LeaveGroupRequest": NewMockLeaveGroupResponse(t).
+			SetMemberError("instance-2", ErrUnknownMemberId),
+		"DescribeGroupsRequest": NewMockDescribeGroupsResponse(t).
+			AddGroupDescription(group, &GroupDescription{
+				GroupId: group,
+				State:   "Stable",
+				Members: map[string]*GroupMemberDescription{
+					"member-1": {ClientId: "client-1"},
+					"member-2": {ClientId: "client-2"},
+				},
+			}),
+	})
 
 	config := NewTestConfig()
 	config.Version = V2_4_0_0
@@ -1350,77 +3403,42 @@ func TestDeleteOffset(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Test NoError
-	handlerMap["DeleteOffsetsRequest"] = NewMockDeleteOffsetRequest(t).SetDeletedOffset(ErrNoError, topic, partition, ErrNoError)
-	err = admin.DeleteConsumerGroupOffset(group, topic, partition)
+	errs, err := admin.RemoveMembersFromConsumerGroup(group, []string{"instance-1", "instance-2"})
 	if err != nil {
-		t.Fatalf("DeleteConsumerGroupOffset failed with error %v", err)
+		t.Fatalf("RemoveMembersFromConsumerGroup failed with error %v", err)
 	}
-
-	// Test Error
-	handlerMap["DeleteOffsetsRequest"] = NewMockDeleteOffsetRequest(t).SetDeletedOffset(ErrNotCoordinatorForConsumer, topic, partition, ErrNoError)
-	err = admin.DeleteConsumerGroupOffset(group, topic, partition)
-	if err != ErrNotCoordinatorForConsumer {
-		t.Fatalf("DeleteConsumerGroupOffset should have failed with error %v", ErrNotCoordinatorForConsumer)
-	}
-
-	// Test Error for partition
-	handlerMap["DeleteOffsetsRequest"] = NewMockDeleteOffsetRequest(t).SetDeletedOffset(ErrNoError, topic, partition, ErrGroupSubscribedToTopic)
-	err = admin.DeleteConsumerGroupOffset(group, topic, partition)
-	if err != ErrGroupSubscribedToTopic {
-		t.Fatalf("DeleteConsumerGroupOffset should have failed with error %v", ErrGroupSubscribedToTopic)
+	if len(errs) != 2 || errs["instance-1"] != ErrNoError || errs["instance-2"] != ErrUnknownMemberId {
+		t.Errorf("unexpected errors %v", errs)
 	}
-}
-
-// TestRefreshMetaDataWithDifferentController ensures that the cached
-// controller can be forcibly updated from Metadata by the admin client
-func TestRefreshMetaDataWithDifferentController(t *testing.T) {
-	seedBroker1 := NewMockBroker(t, 1)
-	seedBroker2 := NewMockBroker(t, 2)
-	defer seedBroker1.Close()
-	defer seedBroker2.Close()
-
-	seedBroker1.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": NewMockMetadataResponse(t).
-			SetController(seedBroker1.BrokerID()).
-			SetBroker(seedBroker1.Addr(), seedBroker1.BrokerID()).
-			SetBroker(seedBroker2.Addr(), seedBroker2.BrokerID()),
-	})
-
-	config := NewTestConfig()
-	config.Version = V1_1_0_0
 
-	client, err := NewClient([]string{seedBroker1.Addr()}, config)
+	errs, err = admin.RemoveAllMembersFromConsumerGroup(group)
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("RemoveAllMembersFromConsumerGroup failed with error %v", err)
 	}
-
-	ca := clusterAdmin{client: client, conf: config}
-
-	if b, _ := ca.Controller(); seedBroker1.BrokerID() != b.ID() {
-		t.Fatalf("expected cached controller to be %d rather than %d",
-			seedBroker1.BrokerID(), b.ID())
+	if len(errs) != 2 || errs["member-1"] != ErrNoError || errs["member-2"] != ErrNoError {
+		t.Errorf("unexpected errors %v", errs)
 	}
 
-	seedBroker1.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": NewMockMetadataResponse(t).
-			SetController(seedBroker2.BrokerID()).
-			SetBroker(seedBroker1.Addr(), seedBroker1.BrokerID()).
-			SetBroker(seedBroker2.Addr(), seedBroker2.BrokerID()),
-	})
-
-	if b, _ := ca.refreshController(); seedBroker2.BrokerID() != b.ID() {
-		t.Fatalf("expected refreshed controller to be %d rather than %d",
-			seedBroker2.BrokerID(), b.ID())
+	var leave *LeaveGroupRequest
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*LeaveGroupRequest); ok {
+			leave = req
+		}
 	}
-
-	if b, _ := ca.Controller(); seedBroker2.BrokerID() != b.ID() {
-		t.Fatalf("expected cached controller to be %d rather than %d",
-			seedBroker2.BrokerID(), b.ID())
+	if leave == nil {
+		t.Fatal("no leave group request was sent")
+	}
+	if leave.Version != 4 || leave.GroupId != group || len(leave.Members) != 2 {
+		t.Errorf("unexpected leave group request %+v", leave)
+	}
+	for _, member := range leave.Members {
+		if member.GroupInstanceId != nil {
+			t.Errorf("unexpected group instance ID %q", *member.GroupInstanceId)
+		}
 	}
 }
 
-func TestDescribeLogDirs(t *testing.T) {
+func TestRemoveMembersFromConsumerGroupUnsupported(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
@@ -1428,42 +3446,18 @@ func TestDescribeLogDirs(t *testing.T) {
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
-		"DescribeLogDirsRequest": NewMockDescribeLogDirsResponse(t).
-			SetLogDirs("/tmp/logs", map[string]int{"topic1": 2, "topic2": 2}),
 	})
 
 	config := NewTestConfig()
-	config.Version = V1_0_0_0
+	config.Version = V2_3_0_0
 
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	logDirsPerBroker, err := admin.DescribeLogDirs([]int32{seedBroker.BrokerID()})
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if len(logDirsPerBroker) != 1 {
-		t.Fatalf("Expected %v results, got %v", 1, len(logDirsPerBroker))
-	}
-	logDirs := logDirsPerBroker[seedBroker.BrokerID()]
-	if len(logDirs) != 1 {
-		t.Fatalf("Expected log dirs for broker %v to be returned, but it did not, got %v", seedBroker.BrokerID(), len(logDirs))
-	}
-	logDirsBroker := logDirs[0]
-	if logDirsBroker.ErrorCode != ErrNoError {
-		t.Fatalf("Expected no error for broker %v, but it was %v", seedBroker.BrokerID(), logDirsBroker.ErrorCode)
-	}
-	if logDirsBroker.Path != "/tmp/logs" {
-		t.Fatalf("Expected log dirs for broker %v to be '/tmp/logs', but it was %v", seedBroker.BrokerID(), logDirsBroker.Path)
-	}
-	if len(logDirsBroker.Topics) != 2 {
-		t.Fatalf("Expected log dirs for broker %v to have 2 topics, but it had %v", seedBroker.BrokerID(), len(logDirsBroker.Topics))
-	}
-	err = admin.Close()
-	if err != nil {
-		t.Fatal(err)
+	_, err = admin.RemoveMembersFromConsumerGroup("my-group", []string{"instance-1"})
+	if !errors.As(err, new(ConfigurationError)) {
+		t.Fatalf("expected a ConfigurationError, got %v", err)
 	}
 }
@@ -33,6 +33,9 @@ func (a *AlterConfigsRequest) decode(pd packetDecoder, version int16) error {
 	if err != nil {
 		return err
 	}
+	if resourceCount == -1 {
+		resourceCount = 0
+	}
 
 	a.Resources = make([]*AlterConfigsResource, resourceCount)
 	for i := range a.Resources {
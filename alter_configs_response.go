@@ -43,6 +43,9 @@ func (a *AlterConfigsResponse) decode(pd packetDecoder, version int16) error {
 	if err != nil {
 		return err
 	}
+	if responseCount == -1 {
+		responseCount = 0
+	}
 
 	a.Resources = make([]*AlterConfigsResourceResponse, responseCount)
 
@@ -114,3 +117,7 @@ func (a *AlterConfigsResponse) headerVersion() int16 {
 func (a *AlterConfigsResponse) requiredVersion() KafkaVersion {
 	return V0_11_0_0
 }
+
+func (a *AlterConfigsResponse) throttleTime() time.Duration {
+	return a.ThrottleTime
+}
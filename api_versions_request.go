@@ -1,13 +1,80 @@
 package sarama
 
+import (
+	"reflect"
+	"runtime/debug"
+	"strings"
+)
+
+const defaultClientSoftwareName = "sarama"
+
+// defaultClientSoftwareVersion is the version of the sarama module the binary
+// is built with, or "dev"
+var defaultClientSoftwareVersion = clientSoftwareVersion(debug.ReadBuildInfo())
+
+// clientSoftwareVersion returns the version of the sarama module in the build
+// info, made acceptable to the brokers
+func clientSoftwareVersion(info *debug.BuildInfo, ok bool) string {
+	if !ok {
+		return "dev"
+	}
+	path := reflect.TypeOf(ApiVersionsRequest{}).PkgPath()
+	modules := append([]*debug.Module{&info.Main}, info.Deps...)
+	for _, module := range modules {
+		if module.Path != path && !strings.HasPrefix(path, module.Path+"/") {
+			continue
+		}
+		if module.Replace != nil {
+			module = module.Replace
+		}
+		// the brokers only accept letters, digits, '.' and '-'
+		version := strings.Map(func(r rune) rune {
+			if r == '.' || r == '-' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+				return r
+			}
+			return '-'
+		}, module.Version)
+		if validClientSoftware.MatchString(version) {
+			return version
+		}
+	}
+	return "dev"
+}
+
 // ApiVersionsRequest ...
-type ApiVersionsRequest struct{}
+type ApiVersionsRequest struct {
+	Version int16
+	// ClientSoftwareName and ClientSoftwareVersion are only used from version 3
+	ClientSoftwareName    string
+	ClientSoftwareVersion string
+}
 
 func (a *ApiVersionsRequest) encode(pe packetEncoder) error {
+	if a.Version >= 3 {
+		if err := pe.putCompactString(a.ClientSoftwareName); err != nil {
+			return err
+		}
+		if err := pe.putCompactString(a.ClientSoftwareVersion); err != nil {
+			return err
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
 	return nil
 }
 
 func (a *ApiVersionsRequest) decode(pd packetDecoder, version int16) (err error) {
+	a.Version = version
+	if version >= 3 {
+		if a.ClientSoftwareName, err = pd.getCompactString(); err != nil {
+			return err
+		}
+		if a.ClientSoftwareVersion, err = pd.getCompactString(); err != nil {
+			return err
+		}
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -16,13 +83,25 @@ func (a *ApiVersionsRequest) key() int16 {
 }
 
 func (a *ApiVersionsRequest) version() int16 {
-	return 0
+	return a.Version
 }
 
 func (a *ApiVersionsRequest) headerVersion() int16 {
+	if a.Version >= 3 {
+		return 2
+	}
 	return 1
 }
 
 func (a *ApiVersionsRequest) requiredVersion() KafkaVersion {
-	return V0_10_0_0
+	switch a.Version {
+	case 1:
+		return V0_11_0_0
+	case 2:
+		return V2_0_0_0
+	case 3:
+		return V2_4_0_0
+	default:
+		return V0_10_0_0
+	}
 }
@@ -1,10 +1,53 @@
 package sarama
 
-import "testing"
+import (
+	"runtime/debug"
+	"testing"
+)
 
-var apiVersionRequest []byte
+var (
+	apiVersionRequest []byte
+
+	apiVersionRequestV3 = []byte{
+		7, 's', 'a', 'r', 'a', 'm', 'a', // ClientSoftwareName
+		4, '1', '.', '0', // ClientSoftwareVersion
+		0, // empty tagged fields
+	}
+)
 
 func TestApiVersionsRequest(t *testing.T) {
 	request := new(ApiVersionsRequest)
 	testRequest(t, "basic", request, apiVersionRequest)
 }
+
+func TestApiVersionsRequestV3(t *testing.T) {
+	request := &ApiVersionsRequest{
+		Version:               3,
+		ClientSoftwareName:    "sarama",
+		ClientSoftwareVersion: "1.0",
+	}
+	testRequest(t, "v3", request, apiVersionRequestV3)
+}
+
+func TestClientSoftwareVersion(t *testing.T) {
+	for _, tt := range []struct {
+		info     *debug.BuildInfo
+		ok       bool
+		expected string
+	}{
+		{nil, false, "dev"},
+		{&debug.BuildInfo{Main: debug.Module{Path: "github.com/Shopify/sarama", Version: "(devel)"}}, true, "dev"},
+		{&debug.BuildInfo{
+			Main: debug.Module{Path: "example.com/app", Version: "v1.0.0"},
+			Deps: []*debug.Module{{Path: "github.com/Shopify/sarama", Version: "v1.30.0"}},
+		}, true, "v1.30.0"},
+		{&debug.BuildInfo{
+			Main: debug.Module{Path: "example.com/app"},
+			Deps: []*debug.Module{{Path: "github.com/Shopify/sarama", Version: "v1.30.0", Replace: &debug.Module{Path: "example.com/sarama", Version: "v1.30.1-0.20211014120000-abcdef123456+incompatible"}}},
+		}, true, "v1.30.1-0.20211014120000-abcdef123456-incompatible"},
+	} {
+		if version := clientSoftwareVersion(tt.info, tt.ok); version != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, version)
+		}
+	}
+}
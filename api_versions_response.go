@@ -1,5 +1,7 @@
 package sarama
 
+import "time"
+
 // ApiVersionsResponseBlock is an api version response block type
 type ApiVersionsResponseBlock struct {
 	ApiKey     int16
@@ -32,26 +34,186 @@ func (b *ApiVersionsResponseBlock) decode(pd packetDecoder) error {
 	return nil
 }
 
+// SupportedFeatureKey is the range of versions of a feature supported by a
+// broker (KIP-584).
+type SupportedFeatureKey struct {
+	Name       string
+	MinVersion int16
+	MaxVersion int16
+}
+
+// FinalizedFeatureKey is the range of versions of a feature finalized for
+// the whole cluster (KIP-584).
+type FinalizedFeatureKey struct {
+	Name            string
+	MaxVersionLevel int16
+	MinVersionLevel int16
+}
+
+// The tags of the tagged fields of the version 3 response
+const (
+	apiVersionsSupportedFeaturesTag      = 0
+	apiVersionsFinalizedFeaturesEpochTag = 1
+	apiVersionsFinalizedFeaturesTag      = 2
+)
+
+type supportedFeatureKeys []SupportedFeatureKey
+
+func (s supportedFeatureKeys) encode(pe packetEncoder) error {
+	pe.putCompactArrayLength(len(s))
+	for _, feature := range s {
+		if err := pe.putCompactString(feature.Name); err != nil {
+			return err
+		}
+		pe.putInt16(feature.MinVersion)
+		pe.putInt16(feature.MaxVersion)
+		pe.putEmptyTaggedFieldArray()
+	}
+	return nil
+}
+
+func decodeSupportedFeatureKeys(pd packetDecoder) ([]SupportedFeatureKey, error) {
+	n, err := pd.getCompactArrayLength()
+	if err != nil {
+		return nil, err
+	}
+	features := make([]SupportedFeatureKey, n)
+	for i := range features {
+		if features[i].Name, err = pd.getCompactString(); err != nil {
+			return nil, err
+		}
+		if features[i].MinVersion, err = pd.getInt16(); err != nil {
+			return nil, err
+		}
+		if features[i].MaxVersion, err = pd.getInt16(); err != nil {
+			return nil, err
+		}
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return nil, err
+		}
+	}
+	return features, nil
+}
+
+type finalizedFeatureKeys []FinalizedFeatureKey
+
+func (f finalizedFeatureKeys) encode(pe packetEncoder) error {
+	pe.putCompactArrayLength(len(f))
+	for _, feature := range f {
+		if err := pe.putCompactString(feature.Name); err != nil {
+			return err
+		}
+		pe.putInt16(feature.MaxVersionLevel)
+		pe.putInt16(feature.MinVersionLevel)
+		pe.putEmptyTaggedFieldArray()
+	}
+	return nil
+}
+
+func decodeFinalizedFeatureKeys(pd packetDecoder) ([]FinalizedFeatureKey, error) {
+	n, err := pd.getCompactArrayLength()
+	if err != nil {
+		return nil, err
+	}
+	features := make([]FinalizedFeatureKey, n)
+	for i := range features {
+		if features[i].Name, err = pd.getCompactString(); err != nil {
+			return nil, err
+		}
+		if features[i].MaxVersionLevel, err = pd.getInt16(); err != nil {
+			return nil, err
+		}
+		if features[i].MinVersionLevel, err = pd.getInt16(); err != nil {
+			return nil, err
+		}
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return nil, err
+		}
+	}
+	return features, nil
+}
+
+type finalizedFeaturesEpoch int64
+
+func (e finalizedFeaturesEpoch) encode(pe packetEncoder) error {
+	pe.putInt64(int64(e))
+	return nil
+}
+
 // ApiVersionsResponse is an api version response type
 type ApiVersionsResponse struct {
-	Err         KError
-	ApiVersions []*ApiVersionsResponseBlock
+	Version        int16
+	Err            KError
+	ApiVersions    []*ApiVersionsResponseBlock
+	ThrottleTimeMs int32 // Version 1
+
+	// The features are tagged fields of the version 3 response, only
+	// returned by brokers with version 2.7.0.0 or higher
+	SupportedFeatures []SupportedFeatureKey
+	// FinalizedFeaturesEpoch is -1 when unknown
+	FinalizedFeaturesEpoch int64
+	FinalizedFeatures      []FinalizedFeatureKey
 }
 
 func (r *ApiVersionsResponse) encode(pe packetEncoder) error {
 	pe.putInt16(int16(r.Err))
-	if err := pe.putArrayLength(len(r.ApiVersions)); err != nil {
+	if r.Version >= 3 {
+		pe.putCompactArrayLength(len(r.ApiVersions))
+	} else if err := pe.putArrayLength(len(r.ApiVersions)); err != nil {
 		return err
 	}
 	for _, apiVersion := range r.ApiVersions {
 		if err := apiVersion.encode(pe); err != nil {
 			return err
 		}
+		if r.Version >= 3 {
+			pe.putEmptyTaggedFieldArray()
+		}
+	}
+
+	if r.Version >= 1 {
+		pe.putInt32(r.ThrottleTimeMs)
+	}
+
+	if r.Version >= 3 {
+		return r.encodeTaggedFields(pe)
+	}
+	return nil
+}
+
+func (r *ApiVersionsResponse) encodeTaggedFields(pe packetEncoder) error {
+	numTags := 0
+	if len(r.SupportedFeatures) > 0 {
+		numTags++
+	}
+	if r.FinalizedFeaturesEpoch != -1 {
+		numTags++
+	}
+	if len(r.FinalizedFeatures) > 0 {
+		numTags++
+	}
+	pe.putUVarint(uint64(numTags))
+
+	if len(r.SupportedFeatures) > 0 {
+		if err := putTaggedField(pe, apiVersionsSupportedFeaturesTag, supportedFeatureKeys(r.SupportedFeatures)); err != nil {
+			return err
+		}
+	}
+	if r.FinalizedFeaturesEpoch != -1 {
+		if err := putTaggedField(pe, apiVersionsFinalizedFeaturesEpochTag, finalizedFeaturesEpoch(r.FinalizedFeaturesEpoch)); err != nil {
+			return err
+		}
+	}
+	if len(r.FinalizedFeatures) > 0 {
+		if err := putTaggedField(pe, apiVersionsFinalizedFeaturesTag, finalizedFeatureKeys(r.FinalizedFeatures)); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
 func (r *ApiVersionsResponse) decode(pd packetDecoder, version int16) error {
+	r.Version = version
 	kerr, err := pd.getInt16()
 	if err != nil {
 		return err
@@ -59,7 +221,12 @@ func (r *ApiVersionsResponse) decode(pd packetDecoder, version int16) error {
 
 	r.Err = KError(kerr)
 
-	numBlocks, err := pd.getArrayLength()
+	var numBlocks int
+	if version >= 3 {
+		numBlocks, err = pd.getCompactArrayLength()
+	} else {
+		numBlocks, err = pd.getArrayLength()
+	}
 	if err != nil {
 		return err
 	}
@@ -70,24 +237,127 @@ func (r *ApiVersionsResponse) decode(pd packetDecoder, version int16) error {
 		if err := block.decode(pd); err != nil {
 			return err
 		}
+		if version >= 3 {
+			if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+		}
 		r.ApiVersions[i] = block
 	}
 
+	if version >= 1 {
+		if r.ThrottleTimeMs, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+
+	if version >= 3 {
+		return r.decodeTaggedFields(pd)
+	}
 	return nil
 }
 
+func (r *ApiVersionsResponse) decodeTaggedFields(pd packetDecoder) error {
+	r.SupportedFeatures = nil
+	r.FinalizedFeaturesEpoch = -1
+	r.FinalizedFeatures = nil
+
+	return getTaggedFields(pd, func(tag uint64, field packetDecoder) (err error) {
+		switch tag {
+		case apiVersionsSupportedFeaturesTag:
+			r.SupportedFeatures, err = decodeSupportedFeatureKeys(field)
+		case apiVersionsFinalizedFeaturesEpochTag:
+			r.FinalizedFeaturesEpoch, err = field.getInt64()
+		case apiVersionsFinalizedFeaturesTag:
+			r.FinalizedFeatures, err = decodeFinalizedFeatureKeys(field)
+		}
+		return err
+	})
+}
+
 func (r *ApiVersionsResponse) key() int16 {
 	return 18
 }
 
 func (r *ApiVersionsResponse) version() int16 {
-	return 0
+	return r.Version
 }
 
 func (a *ApiVersionsResponse) headerVersion() int16 {
+	// the response header of ApiVersions is never flexible, for clients to be
+	// able to parse it when the broker does not support the request version
 	return 0
 }
 
 func (r *ApiVersionsResponse) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V0_11_0_0
+	case 2:
+		return V2_0_0_0
+	case 3:
+		return V2_4_0_0
+	default:
+		return V0_10_0_0
+	}
+}
+
+func (r *ApiVersionsResponse) throttleTime() time.Duration {
+	return time.Duration(r.ThrottleTimeMs) * time.Millisecond
+}
+
+// apiVersionMarker is an API version introduced by a Kafka release, used to
+// recognize the release of a broker from its ApiVersionsResponse
+type apiVersionMarker struct {
+	version    KafkaVersion
+	apiKey     int16
+	maxVersion int16
+}
+
+// apiVersionMarkers are sorted from the most recent release. Releases without
+// a reliable marker are recognized as the previous one, which is always safe
+// as Kafka is backward compatible.
+var apiVersionMarkers = []apiVersionMarker{
+	{V3_7_0_0, 1, 16},  // Fetch v16
+	{V3_6_0_0, 24, 4},  // AddPartitionsToTxn v4
+	{V3_5_0_0, 1, 15},  // Fetch v15
+	{V3_1_0_0, 1, 13},  // Fetch v13
+	{V3_0_0_0, 66, 0},  // ListTransactions
+	{V2_8_0_0, 60, 0},  // DescribeCluster
+	{V2_7_0_0, 50, 0},  // DescribeUserScramCredentials
+	{V2_6_0_0, 48, 0},  // DescribeClientQuotas
+	{V2_5_0_0, 11, 7},  // JoinGroup v7
+	{V2_4_0_0, 47, 0},  // OffsetDelete
+	{V2_3_0_0, 44, 0},  // IncrementalAlterConfigs
+	{V2_2_0_0, 43, 0},  // ElectLeaders
+	{V2_1_0_0, 0, 7},   // Produce v7
+	{V2_0_0_0, 0, 6},   // Produce v6
+	{V1_1_0_0, 42, 0},  // DeleteGroups
+	{V1_0_0_0, 37, 0},  // CreatePartitions
+	{V0_11_0_0, 22, 0}, // InitProducerId
+	{V0_10_2_0, 9, 2},  // OffsetFetch v2
+	{V0_10_1_0, 19, 0}, // CreateTopics
+}
+
+// KafkaVersion returns the most recent Kafka version whose protocol is
+// supported by the broker which sent the response, at least V0_10_0_0 as
+// ApiVersions was added by that version. Setting it as Config.Version makes
+// Sarama use the highest request versions supported by both the broker and
+// Sarama.
+func (r *ApiVersionsResponse) KafkaVersion() KafkaVersion {
+	maxVersions := make(map[int16]int16, len(r.ApiVersions))
+	for _, block := range r.ApiVersions {
+		maxVersions[block.ApiKey] = block.MaxVersion
+		// 4.0 removed the produce versions older than 3 (KIP-896)
+		if block.ApiKey == 0 && block.MinVersion >= 3 {
+			return V4_0_0_0
+		}
+	}
+
+	for _, marker := range apiVersionMarkers {
+		if maxVersion, ok := maxVersions[marker.apiKey]; ok && maxVersion >= marker.maxVersion {
+			return marker.version
+		}
+	}
 	return V0_10_0_0
 }
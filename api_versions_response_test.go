@@ -10,6 +10,32 @@ var apiVersionResponse = []byte{
 	0x00, 0x01,
 }
 
+var apiVersionResponseV3 = []byte{
+	0x00, 0x00, // Err
+	0x02,       // ApiVersions array, length 1
+	0x00, 0x12, // ApiKey
+	0x00, 0x00, // MinVersion
+	0x00, 0x03, // MaxVersion
+	0x00,                   // empty tagged fields
+	0x00, 0x00, 0x00, 0x0a, // ThrottleTimeMs
+	0x03, // 3 tagged fields
+	0x00, // SupportedFeatures tag
+	0x17, // size 23
+	0x02,
+	0x11, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a', '.', 'v', 'e', 'r', 's', 'i', 'o', 'n',
+	0x00, 0x01, 0x00, 0x15,
+	0x00,
+	0x01, // FinalizedFeaturesEpoch tag
+	0x08, // size 8
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x2a,
+	0x02, // FinalizedFeatures tag
+	0x17, // size 23
+	0x02,
+	0x11, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a', '.', 'v', 'e', 'r', 's', 'i', 'o', 'n',
+	0x00, 0x0e, 0x00, 0x0e,
+	0x00,
+}
+
 func TestApiVersionsResponse(t *testing.T) {
 	response := new(ApiVersionsResponse)
 	testVersionDecodable(t, "no error", response, apiVersionResponse, 0)
@@ -26,3 +52,47 @@ func TestApiVersionsResponse(t *testing.T) {
 		t.Error("Decoding error: expected 0x01 but got", response.ApiVersions[0].MaxVersion)
 	}
 }
+
+func TestApiVersionsResponseV3(t *testing.T) {
+	response := &ApiVersionsResponse{
+		Version: 3,
+		ApiVersions: []*ApiVersionsResponseBlock{
+			{ApiKey: 18, MinVersion: 0, MaxVersion: 3},
+		},
+		ThrottleTimeMs: 10,
+		SupportedFeatures: []SupportedFeatureKey{
+			{Name: "metadata.version", MinVersion: 1, MaxVersion: 21},
+		},
+		FinalizedFeaturesEpoch: 42,
+		FinalizedFeatures: []FinalizedFeatureKey{
+			{Name: "metadata.version", MaxVersionLevel: 14, MinVersionLevel: 14},
+		},
+	}
+	testResponse(t, "features", response, apiVersionResponseV3)
+
+	// a response without features has an unknown finalized features epoch
+	response = &ApiVersionsResponse{Version: 3, ApiVersions: []*ApiVersionsResponseBlock{}, FinalizedFeaturesEpoch: -1}
+	testResponse(t, "no features", response, []byte{0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00})
+}
+
+func TestApiVersionsResponseKafkaVersion(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		blocks   []*ApiVersionsResponseBlock
+		expected KafkaVersion
+	}{
+		{"no markers", []*ApiVersionsResponseBlock{{ApiKey: 18, MaxVersion: 0}}, V0_10_0_0},
+		{"create topics", []*ApiVersionsResponseBlock{{ApiKey: 19, MaxVersion: 0}}, V0_10_1_0},
+		{"produce v6", []*ApiVersionsResponseBlock{{ApiKey: 0, MaxVersion: 6}, {ApiKey: 42, MaxVersion: 1}}, V2_0_0_0},
+		{"offset delete", []*ApiVersionsResponseBlock{{ApiKey: 0, MaxVersion: 8}, {ApiKey: 47, MaxVersion: 0}}, V2_4_0_0},
+		{"fetch v13", []*ApiVersionsResponseBlock{{ApiKey: 1, MaxVersion: 13}, {ApiKey: 66, MaxVersion: 0}}, V3_1_0_0},
+		{"produce v0 removed", []*ApiVersionsResponseBlock{{ApiKey: 0, MinVersion: 3, MaxVersion: 12}}, V4_0_0_0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res := &ApiVersionsResponse{ApiVersions: tc.blocks}
+			if actual := res.KafkaVersion(); actual != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
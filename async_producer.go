@@ -1,6 +1,7 @@
 package sarama
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"sync"
@@ -101,7 +102,8 @@ func newTransactionManager(conf *Config, client Client) (*transactionManager, er
 		txnmgr.sequenceNumbers = make(map[string]int32)
 		txnmgr.mutex = sync.Mutex{}
 
-		Logger.Printf("Obtained a ProducerId: %d and ProducerEpoch: %d\n", txnmgr.producerID, txnmgr.producerEpoch)
+		logEntry(LogComponentProducer, LogLevelInfo, "producer/txnmanager obtained a producer ID",
+			LogField{Key: "producer_id", Value: txnmgr.producerID}, LogField{Key: "producer_epoch", Value: txnmgr.producerEpoch})
 	}
 
 	return txnmgr, nil
@@ -115,7 +117,7 @@ type asyncProducer struct {
 	input, successes, retries chan *ProducerMessage
 	inFlight                  sync.WaitGroup
 
-	brokers    map[*Broker]*brokerProducer
+	brokers    map[brokerProducerKey]*brokerProducer
 	brokerRefs map[*brokerProducer]int
 	brokerLock sync.Mutex
 
@@ -158,7 +160,7 @@ func newAsyncProducer(client Client) (AsyncProducer, error) {
 		input:      make(chan *ProducerMessage),
 		successes:  make(chan *ProducerMessage),
 		retries:    make(chan *ProducerMessage),
-		brokers:    make(map[*Broker]*brokerProducer),
+		brokers:    make(map[brokerProducerKey]*brokerProducer),
 		brokerRefs: make(map[*brokerProducer]int),
 		txnmgr:     txnmgr,
 	}
@@ -198,6 +200,11 @@ type ProducerMessage struct {
 	// pass-through data.
 	Metadata interface{}
 
+	// Context is the context of the span of the message started with
+	// Tracing.Tracer, in which case the span is its child. Sarama does not
+	// use it otherwise.
+	Context context.Context
+
 	// Below this point are filled in by the producer as the message is processed
 
 	// Offset is the offset of the message stored on the broker. This is only
@@ -226,6 +233,7 @@ type ProducerMessage struct {
 	sequenceNumber int32
 	producerEpoch  int16
 	hasSequence    bool
+	span           Span
 }
 
 const producerMessageOverhead = 26 // the metadata overhead of CRC, flags, etc.
@@ -346,7 +354,7 @@ func (p *asyncProducer) dispatcher() {
 				if p.conf.Producer.Return.Errors {
 					p.errors <- pErr
 				} else {
-					Logger.Println(pErr)
+					logEntry(LogComponentProducer, LogLevelError, "producer/message error", topicField(msg.Topic), partitionField(msg.Partition), errorField(pErr.Err))
 				}
 				continue
 			}
@@ -357,6 +365,17 @@ func (p *asyncProducer) dispatcher() {
 			msg.safelyApplyInterceptor(interceptor)
 		}
 
+		if msg.retries == 0 && msg.flags == 0 {
+			if err := msg.serialize(p.conf); err != nil {
+				p.returnError(msg, err)
+				continue
+			}
+		}
+
+		if p.conf.Tracing.Tracer != nil && msg.retries == 0 && msg.flags == 0 && msg.span == nil {
+			p.startProducerSpan(msg)
+		}
+
 		version := 1
 		if p.conf.Version.IsAtLeast(V0_11_0_0) {
 			version = 2
@@ -364,7 +383,7 @@ func (p *asyncProducer) dispatcher() {
 			p.returnError(msg, ConfigurationError("Producing headers requires Kafka at least v0.11"))
 			continue
 		}
-		if msg.byteSize(version) > p.conf.Producer.MaxMessageBytes {
+		if msg.byteSize(version) > p.conf.producerSettings(msg.Topic).maxMessageBytes {
 			p.returnError(msg, ErrMessageSizeTooLarge)
 			continue
 		}
@@ -516,7 +535,9 @@ func (p *asyncProducer) newPartitionProducer(topic string, partition int32) chan
 
 func (pp *partitionProducer) backoff(retries int) {
 	var backoff time.Duration
-	if pp.parent.conf.Producer.Retry.BackoffFunc != nil {
+	if pp.parent.conf.Producer.Retry.Policy != nil {
+		backoff = pp.parent.conf.Producer.Retry.Policy.Backoff(retries, pp.parent.conf.Producer.Retry.Max)
+	} else if pp.parent.conf.Producer.Retry.BackoffFunc != nil {
 		maxRetries := pp.parent.conf.Producer.Retry.Max
 		backoff = pp.parent.conf.Producer.Retry.BackoffFunc(retries, maxRetries)
 	} else {
@@ -532,7 +553,7 @@ func (pp *partitionProducer) dispatch() {
 	// on the first message
 	pp.leader, _ = pp.parent.client.Leader(pp.topic, pp.partition)
 	if pp.leader != nil {
-		pp.brokerProducer = pp.parent.getBrokerProducer(pp.leader)
+		pp.brokerProducer = pp.parent.getBrokerProducer(pp.leader, pp.topic)
 		pp.parent.inFlight.Add(1) // we're generating a syn message; track it so we don't shut down while it's still inflight
 		pp.brokerProducer.input <- &ProducerMessage{Topic: pp.topic, Partition: pp.partition, flags: syn}
 	}
@@ -548,7 +569,7 @@ func (pp *partitionProducer) dispatch() {
 			select {
 			case <-pp.brokerProducer.abandoned:
 				// a message on the abandoned channel means that our current broker selection is out of date
-				Logger.Printf("producer/leader/%s/%d abandoning broker %d\n", pp.topic, pp.partition, pp.leader.ID())
+				logEntry(LogComponentProducer, LogLevelInfo, "producer/leader abandoning broker", topicField(pp.topic), partitionField(pp.partition), brokerField(pp.leader.ID()))
 				pp.parent.unrefBrokerProducer(pp.leader, pp.brokerProducer)
 				pp.brokerProducer = nil
 				time.Sleep(pp.parent.conf.Producer.Retry.Backoff)
@@ -591,7 +612,7 @@ func (pp *partitionProducer) dispatch() {
 				pp.backoff(msg.retries)
 				continue
 			}
-			Logger.Printf("producer/leader/%s/%d selected broker %d\n", pp.topic, pp.partition, pp.leader.ID())
+			logEntry(LogComponentProducer, LogLevelInfo, "producer/leader selected broker", topicField(pp.topic), partitionField(pp.partition), brokerField(pp.leader.ID()))
 		}
 
 		// Now that we know we have a broker to actually try and send this message to, generate the sequence
@@ -608,7 +629,7 @@ func (pp *partitionProducer) dispatch() {
 }
 
 func (pp *partitionProducer) newHighWatermark(hwm int) {
-	Logger.Printf("producer/leader/%s/%d state change to [retrying-%d]\n", pp.topic, pp.partition, hwm)
+	logEntry(LogComponentProducer, LogLevelInfo, "producer/leader state change to [retrying]", topicField(pp.topic), partitionField(pp.partition), LogField{Key: "retries", Value: hwm})
 	pp.highWatermark = hwm
 
 	// send off a fin so that we know when everything "in between" has made it
@@ -618,13 +639,13 @@ func (pp *partitionProducer) newHighWatermark(hwm int) {
 	pp.brokerProducer.input <- &ProducerMessage{Topic: pp.topic, Partition: pp.partition, flags: fin, retries: pp.highWatermark - 1}
 
 	// a new HWM means that our current broker selection is out of date
-	Logger.Printf("producer/leader/%s/%d abandoning broker %d\n", pp.topic, pp.partition, pp.leader.ID())
+	logEntry(LogComponentProducer, LogLevelInfo, "producer/leader abandoning broker", topicField(pp.topic), partitionField(pp.partition), brokerField(pp.leader.ID()))
 	pp.parent.unrefBrokerProducer(pp.leader, pp.brokerProducer)
 	pp.brokerProducer = nil
 }
 
 func (pp *partitionProducer) flushRetryBuffers() {
-	Logger.Printf("producer/leader/%s/%d state change to [flushing-%d]\n", pp.topic, pp.partition, pp.highWatermark)
+	logEntry(LogComponentProducer, LogLevelInfo, "producer/leader state change to [flushing]", topicField(pp.topic), partitionField(pp.partition), LogField{Key: "retries", Value: pp.highWatermark})
 	for {
 		pp.highWatermark--
 
@@ -633,7 +654,7 @@ func (pp *partitionProducer) flushRetryBuffers() {
 				pp.parent.returnErrors(pp.retryState[pp.highWatermark].buf, err)
 				goto flushDone
 			}
-			Logger.Printf("producer/leader/%s/%d selected broker %d\n", pp.topic, pp.partition, pp.leader.ID())
+			logEntry(LogComponentProducer, LogLevelInfo, "producer/leader selected broker", topicField(pp.topic), partitionField(pp.partition), brokerField(pp.leader.ID()))
 		}
 
 		for _, msg := range pp.retryState[pp.highWatermark].buf {
@@ -643,10 +664,10 @@ func (pp *partitionProducer) flushRetryBuffers() {
 	flushDone:
 		pp.retryState[pp.highWatermark].buf = nil
 		if pp.retryState[pp.highWatermark].expectChaser {
-			Logger.Printf("producer/leader/%s/%d state change to [retrying-%d]\n", pp.topic, pp.partition, pp.highWatermark)
+			logEntry(LogComponentProducer, LogLevelInfo, "producer/leader state change to [retrying]", topicField(pp.topic), partitionField(pp.partition), LogField{Key: "retries", Value: pp.highWatermark})
 			break
 		} else if pp.highWatermark == 0 {
-			Logger.Printf("producer/leader/%s/%d state change to [normal]\n", pp.topic, pp.partition)
+			logEntry(LogComponentProducer, LogLevelInfo, "producer/leader state change to [normal]", topicField(pp.topic), partitionField(pp.partition))
 			break
 		}
 	}
@@ -662,7 +683,7 @@ func (pp *partitionProducer) updateLeader() error {
 			return err
 		}
 
-		pp.brokerProducer = pp.parent.getBrokerProducer(pp.leader)
+		pp.brokerProducer = pp.parent.getBrokerProducer(pp.leader, pp.topic)
 		pp.parent.inFlight.Add(1) // we're generating a syn message; track it so we don't shut down while it's still inflight
 		pp.brokerProducer.input <- &ProducerMessage{Topic: pp.topic, Partition: pp.partition, flags: syn}
 
@@ -670,8 +691,9 @@ func (pp *partitionProducer) updateLeader() error {
 	})
 }
 
-// one per broker; also constructs an associated flusher
-func (p *asyncProducer) newBrokerProducer(broker *Broker) *brokerProducer {
+// one per broker and topic with overrides, see Config.producerBatch; also
+// constructs an associated flusher
+func (p *asyncProducer) newBrokerProducer(broker *Broker, topic string) *brokerProducer {
 	var (
 		input     = make(chan *ProducerMessage)
 		bridge    = make(chan *produceSet)
@@ -681,11 +703,12 @@ func (p *asyncProducer) newBrokerProducer(broker *Broker) *brokerProducer {
 	bp := &brokerProducer{
 		parent:         p,
 		broker:         broker,
+		topic:          topic,
 		input:          input,
 		output:         bridge,
 		responses:      responses,
 		stopchan:       make(chan struct{}),
-		buffer:         newProduceSet(p),
+		buffer:         newProduceSet(p, topic),
 		currentRetries: make(map[string]map[int32]error),
 	}
 	go withRecover(bp.run)
@@ -724,6 +747,9 @@ type brokerProducerResponse struct {
 type brokerProducer struct {
 	parent *asyncProducer
 	broker *Broker
+	// topic is the topic of the messages when they have overrides, "" for
+	// the messages of the other topics
+	topic string
 
 	input     chan *ProducerMessage
 	output    chan<- *produceSet
@@ -741,13 +767,13 @@ type brokerProducer struct {
 
 func (bp *brokerProducer) run() {
 	var output chan<- *produceSet
-	Logger.Printf("producer/broker/%d starting up\n", bp.broker.ID())
+	logEntry(LogComponentProducer, LogLevelInfo, "producer/broker starting up", brokerField(bp.broker.ID()))
 
 	for {
 		select {
 		case msg, ok := <-bp.input:
 			if !ok {
-				Logger.Printf("producer/broker/%d input chan closed\n", bp.broker.ID())
+				logEntry(LogComponentProducer, LogLevelInfo, "producer/broker input chan closed", brokerField(bp.broker.ID()))
 				bp.shutdown()
 				return
 			}
@@ -757,8 +783,8 @@ func (bp *brokerProducer) run() {
 			}
 
 			if msg.flags&syn == syn {
-				Logger.Printf("producer/broker/%d state change to [open] on %s/%d\n",
-					bp.broker.ID(), msg.Topic, msg.Partition)
+				logEntry(LogComponentProducer, LogLevelInfo, "producer/broker state change to [open]",
+					brokerField(bp.broker.ID()), topicField(msg.Topic), partitionField(msg.Partition))
 				if bp.currentRetries[msg.Topic] == nil {
 					bp.currentRetries[msg.Topic] = make(map[int32]error)
 				}
@@ -773,15 +799,15 @@ func (bp *brokerProducer) run() {
 				if bp.closing == nil && msg.flags&fin == fin {
 					// we were retrying this partition but we can start processing again
 					delete(bp.currentRetries[msg.Topic], msg.Partition)
-					Logger.Printf("producer/broker/%d state change to [closed] on %s/%d\n",
-						bp.broker.ID(), msg.Topic, msg.Partition)
+					logEntry(LogComponentProducer, LogLevelInfo, "producer/broker state change to [closed]",
+						brokerField(bp.broker.ID()), topicField(msg.Topic), partitionField(msg.Partition))
 				}
 
 				continue
 			}
 
 			if bp.buffer.wouldOverflow(msg) {
-				Logger.Printf("producer/broker/%d maximum request accumulated, waiting for space\n", bp.broker.ID())
+				logEntry(LogComponentProducer, LogLevelInfo, "producer/broker maximum request accumulated, waiting for space", brokerField(bp.broker.ID()))
 				if err := bp.waitForSpace(msg, false); err != nil {
 					bp.parent.retryMessage(msg, err)
 					continue
@@ -790,7 +816,7 @@ func (bp *brokerProducer) run() {
 
 			if bp.parent.txnmgr.producerID != noProducerID && bp.buffer.producerEpoch != msg.producerEpoch {
 				// The epoch was reset, need to roll the buffer over
-				Logger.Printf("producer/broker/%d detected epoch rollover, waiting for new buffer\n", bp.broker.ID())
+				logEntry(LogComponentProducer, LogLevelInfo, "producer/broker detected epoch rollover, waiting for new buffer", brokerField(bp.broker.ID()))
 				if err := bp.waitForSpace(msg, true); err != nil {
 					bp.parent.retryMessage(msg, err)
 					continue
@@ -801,8 +827,8 @@ func (bp *brokerProducer) run() {
 				continue
 			}
 
-			if bp.parent.conf.Producer.Flush.Frequency > 0 && bp.timer == nil {
-				bp.timer = time.After(bp.parent.conf.Producer.Flush.Frequency)
+			if frequency := bp.parent.conf.producerSettings(bp.topic).flushFrequency; frequency > 0 && bp.timer == nil {
+				bp.timer = time.After(frequency)
 			}
 		case <-bp.timer:
 			bp.timerFired = true
@@ -813,8 +839,7 @@ func (bp *brokerProducer) run() {
 				bp.handleResponse(response)
 			}
 		case <-bp.stopchan:
-			Logger.Printf(
-				"producer/broker/%d run loop asked to stop\n", bp.broker.ID())
+			logEntry(LogComponentProducer, LogLevelInfo, "producer/broker run loop asked to stop", brokerField(bp.broker.ID()))
 			return
 		}
 
@@ -840,7 +865,7 @@ func (bp *brokerProducer) shutdown() {
 		bp.handleResponse(response)
 	}
 	close(bp.stopchan)
-	Logger.Printf("producer/broker/%d shut down\n", bp.broker.ID())
+	logEntry(LogComponentProducer, LogLevelInfo, "producer/broker shut down", brokerField(bp.broker.ID()))
 }
 
 func (bp *brokerProducer) needsRetry(msg *ProducerMessage) error {
@@ -872,7 +897,7 @@ func (bp *brokerProducer) waitForSpace(msg *ProducerMessage, forceRollover bool)
 func (bp *brokerProducer) rollOver() {
 	bp.timer = nil
 	bp.timerFired = false
-	bp.buffer = newProduceSet(bp.parent)
+	bp.buffer = newProduceSet(bp.parent, bp.topic)
 }
 
 func (bp *brokerProducer) handleResponse(response *brokerProducerResponse) {
@@ -955,8 +980,8 @@ func (bp *brokerProducer) handleSuccess(sent *produceSet, response *ProduceRespo
 			switch block.Err {
 			case ErrInvalidMessage, ErrUnknownTopicOrPartition, ErrLeaderNotAvailable, ErrNotLeaderForPartition,
 				ErrRequestTimedOut, ErrNotEnoughReplicas, ErrNotEnoughReplicasAfterAppend:
-				Logger.Printf("producer/broker/%d state change to [retrying] on %s/%d because %v\n",
-					bp.broker.ID(), topic, partition, block.Err)
+				logEntry(LogComponentProducer, LogLevelWarn, "producer/broker state change to [retrying]",
+					brokerField(bp.broker.ID()), topicField(topic), partitionField(partition), errorField(block.Err))
 				if bp.currentRetries[topic] == nil {
 					bp.currentRetries[topic] = make(map[int32]error)
 				}
@@ -974,8 +999,8 @@ func (bp *brokerProducer) handleSuccess(sent *produceSet, response *ProduceRespo
 }
 
 func (p *asyncProducer) retryBatch(topic string, partition int32, pSet *partitionSet, kerr KError) {
-	Logger.Printf("Retrying batch for %v-%d because of %s\n", topic, partition, kerr)
-	produceSet := newProduceSet(p)
+	logEntry(LogComponentProducer, LogLevelWarn, "producer/batch retrying", topicField(topic), partitionField(partition), errorField(kerr))
+	produceSet := newProduceSet(p, p.conf.producerBatch(topic))
 	produceSet.msgs[topic] = make(map[int32]*partitionSet)
 	produceSet.msgs[topic][partition] = pSet
 	produceSet.bufferBytes += pSet.bufferBytes
@@ -991,13 +1016,13 @@ func (p *asyncProducer) retryBatch(topic string, partition int32, pSet *partitio
 	// it's expected that a metadata refresh has been requested prior to calling retryBatch
 	leader, err := p.client.Leader(topic, partition)
 	if err != nil {
-		Logger.Printf("Failed retrying batch for %v-%d because of %v while looking up for new leader\n", topic, partition, err)
+		logEntry(LogComponentProducer, LogLevelError, "producer/batch failed retrying while looking up for new leader", topicField(topic), partitionField(partition), errorField(err))
 		for _, msg := range pSet.msgs {
 			p.returnError(msg, kerr)
 		}
 		return
 	}
-	bp := p.getBrokerProducer(leader)
+	bp := p.getBrokerProducer(leader, topic)
 	bp.output <- produceSet
 }
 
@@ -1008,7 +1033,7 @@ func (bp *brokerProducer) handleError(sent *produceSet, err error) {
 			bp.parent.returnErrors(pSet.msgs, err)
 		})
 	default:
-		Logger.Printf("producer/broker/%d state change to [closing] because %s\n", bp.broker.ID(), err)
+		logEntry(LogComponentProducer, LogLevelWarn, "producer/broker state change to [closing]", brokerField(bp.broker.ID()), errorField(err))
 		bp.parent.abandonBrokerConnection(bp.broker)
 		_ = bp.broker.Close()
 		bp.closing = err
@@ -1073,15 +1098,16 @@ func (p *asyncProducer) returnError(msg *ProducerMessage, err error) {
 	// We need to reset the producer ID epoch if we set a sequence number on it, because the broker
 	// will never see a message with this number, so we can never continue the sequence.
 	if msg.hasSequence {
-		Logger.Printf("producer/txnmanager rolling over epoch due to publish failure on %s/%d", msg.Topic, msg.Partition)
+		logEntry(LogComponentProducer, LogLevelWarn, "producer/txnmanager rolling over epoch due to publish failure", topicField(msg.Topic), partitionField(msg.Partition))
 		p.txnmgr.bumpEpoch()
 	}
 	msg.clear()
+	endProducerSpan(msg, err)
 	pErr := &ProducerError{Msg: msg, Err: err}
 	if p.conf.Producer.Return.Errors {
 		p.errors <- pErr
 	} else {
-		Logger.Println(pErr)
+		logEntry(LogComponentProducer, LogLevelError, "producer/message error", topicField(msg.Topic), partitionField(msg.Partition), errorField(err))
 	}
 	p.inFlight.Done()
 }
@@ -1094,6 +1120,7 @@ func (p *asyncProducer) returnErrors(batch []*ProducerMessage, err error) {
 
 func (p *asyncProducer) returnSuccesses(batch []*ProducerMessage) {
 	for _, msg := range batch {
+		endProducerSpan(msg, nil)
 		if p.conf.Producer.Return.Successes {
 			msg.clear()
 			p.successes <- msg
@@ -1117,15 +1144,23 @@ func (p *asyncProducer) retryMessages(batch []*ProducerMessage, err error) {
 	}
 }
 
-func (p *asyncProducer) getBrokerProducer(broker *Broker) *brokerProducer {
+// brokerProducerKey identifies the brokerProducer of a broker for the
+// messages of a topic, see Config.producerBatch
+type brokerProducerKey struct {
+	broker *Broker
+	topic  string
+}
+
+func (p *asyncProducer) getBrokerProducer(broker *Broker, topic string) *brokerProducer {
 	p.brokerLock.Lock()
 	defer p.brokerLock.Unlock()
 
-	bp := p.brokers[broker]
+	key := brokerProducerKey{broker: broker, topic: p.conf.producerBatch(topic)}
+	bp := p.brokers[key]
 
 	if bp == nil {
-		bp = p.newBrokerProducer(broker)
-		p.brokers[broker] = bp
+		bp = p.newBrokerProducer(broker, key.topic)
+		p.brokers[key] = bp
 		p.brokerRefs[bp] = 0
 	}
 
@@ -1143,8 +1178,9 @@ func (p *asyncProducer) unrefBrokerProducer(broker *Broker, bp *brokerProducer)
 		close(bp.input)
 		delete(p.brokerRefs, bp)
 
-		if p.brokers[broker] == bp {
-			delete(p.brokers, broker)
+		key := brokerProducerKey{broker: broker, topic: bp.topic}
+		if p.brokers[key] == bp {
+			delete(p.brokers, key)
 		}
 	}
 }
@@ -1153,10 +1189,13 @@ func (p *asyncProducer) abandonBrokerConnection(broker *Broker) {
 	p.brokerLock.Lock()
 	defer p.brokerLock.Unlock()
 
-	bc, ok := p.brokers[broker]
-	if ok && bc.abandoned != nil {
-		close(bc.abandoned)
+	for key, bc := range p.brokers {
+		if key.broker != broker {
+			continue
+		}
+		if bc.abandoned != nil {
+			close(bc.abandoned)
+		}
+		delete(p.brokers, key)
 	}
-
-	delete(p.brokers, broker)
 }
@@ -916,7 +916,7 @@ func TestAsyncProducerIdempotentGoldenPath(t *testing.T) {
 	broker := NewMockBroker(t, 1)
 
 	metadataResponse := &MetadataResponse{
-		Version:      1,
+		Version:      4,
 		ControllerID: 1,
 	}
 	metadataResponse.AddBroker(broker.Addr(), broker.BrokerID())
@@ -974,7 +974,7 @@ func TestAsyncProducerIdempotentRetryCheckBatch(t *testing.T) {
 		broker := NewMockBroker(t, 1)
 
 		metadataResponse := &MetadataResponse{
-			Version:      1,
+			Version:      4,
 			ControllerID: 1,
 		}
 		metadataResponse.AddBroker(broker.Addr(), broker.BrokerID())
@@ -1111,7 +1111,7 @@ func TestAsyncProducerIdempotentErrorOnOutOfSeq(t *testing.T) {
 	broker := NewMockBroker(t, 1)
 
 	metadataResponse := &MetadataResponse{
-		Version:      1,
+		Version:      4,
 		ControllerID: 1,
 	}
 	metadataResponse.AddBroker(broker.Addr(), broker.BrokerID())
@@ -1161,7 +1161,7 @@ func TestAsyncProducerIdempotentEpochRollover(t *testing.T) {
 	defer broker.Close()
 
 	metadataResponse := &MetadataResponse{
-		Version:      1,
+		Version:      4,
 		ControllerID: 1,
 	}
 	metadataResponse.AddBroker(broker.Addr(), broker.BrokerID())
@@ -1249,7 +1249,7 @@ func TestBrokerProducerShutdown(t *testing.T) {
 		addr: mockBroker.Addr(),
 		id:   mockBroker.BrokerID(),
 	}
-	bp := producer.(*asyncProducer).newBrokerProducer(broker)
+	bp := producer.(*asyncProducer).newBrokerProducer(broker, "")
 
 	bp.shutdown()
 	_ = producer.Close()
@@ -0,0 +1,520 @@
+package sarama
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SASLTypeAWSMSKIAM represents the AWS_MSK_IAM mechanism of Amazon MSK,
+	// authenticating with AWS IAM credentials
+	SASLTypeAWSMSKIAM = "AWS_MSK_IAM"
+
+	awsMSKIAMVersion   = "2020_10_22"
+	awsMSKIAMService   = "kafka-cluster"
+	awsMSKIAMAction    = "kafka-cluster:Connect"
+	awsSigV4Algorithm  = "AWS4-HMAC-SHA256"
+	awsSigV4Expires    = 15 * time.Minute
+	awsSigV4TimeFormat = "20060102T150405Z"
+	awsSigV4DateFormat = "20060102"
+	// awsEmptyPayloadHash is the hex encoded SHA-256 of an empty payload
+	awsEmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// AWSMSKIAMConfig configures the AWS_MSK_IAM SASL mechanism.
+type AWSMSKIAMConfig struct {
+	// Region is the AWS region of the MSK cluster (defaults to the
+	// AWS_REGION or AWS_DEFAULT_REGION environment variables).
+	Region string
+	// CredentialsProvider provides the AWS credentials signing the
+	// authentication payload (defaults to NewDefaultAWSCredentialsProvider).
+	CredentialsProvider AWSCredentialsProvider
+}
+
+// region returns the configured region or the one of the environment
+func (c *AWSMSKIAMConfig) region() string {
+	if c.Region != "" {
+		return c.Region
+	}
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// AWSCredentials are AWS IAM credentials.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials.
+	SessionToken string
+	// Expires is when temporary credentials expire, zero when they do not.
+	Expires time.Time
+}
+
+// AWSCredentialsProvider provides the AWS credentials of the AWS_MSK_IAM SASL
+// mechanism, for example wrapping the credentials of the AWS SDK. It must be
+// safe for concurrent use.
+type AWSCredentialsProvider interface {
+	Credentials() (AWSCredentials, error)
+}
+
+// AWSCredentialsProviderFunc adapts a function to the AWSCredentialsProvider
+// interface.
+type AWSCredentialsProviderFunc func() (AWSCredentials, error)
+
+// Credentials calls f().
+func (f AWSCredentialsProviderFunc) Credentials() (AWSCredentials, error) {
+	return f()
+}
+
+// AWSCredentialsChain is an AWSCredentialsProvider returning the credentials
+// of the first of its providers which has some.
+type AWSCredentialsChain []AWSCredentialsProvider
+
+// Credentials implements AWSCredentialsProvider.
+func (c AWSCredentialsChain) Credentials() (AWSCredentials, error) {
+	var errs []string
+	for _, provider := range c {
+		credentials, err := provider.Credentials()
+		if err == nil {
+			return credentials, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return AWSCredentials{}, fmt.Errorf("no AWS credentials found: %s", strings.Join(errs, "; "))
+}
+
+var (
+	defaultAWSCredentialsOnce     sync.Once
+	defaultAWSCredentialsProvider AWSCredentialsProvider
+)
+
+// defaultAWSCredentials returns the provider shared by the brokers when
+// AWSMSKIAMConfig.CredentialsProvider is not set
+func defaultAWSCredentials() AWSCredentialsProvider {
+	defaultAWSCredentialsOnce.Do(func() {
+		defaultAWSCredentialsProvider = NewDefaultAWSCredentialsProvider()
+	})
+	return defaultAWSCredentialsProvider
+}
+
+// NewDefaultAWSCredentialsProvider returns a provider looking up the
+// credentials like the AWS SDKs do, from:
+//   - the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+//     environment variables
+//   - the AWS_PROFILE (or default) profile of the shared credentials file
+//   - the web identity token of AWS_WEB_IDENTITY_TOKEN_FILE for AWS_ROLE_ARN,
+//     as set up by IAM roles for service accounts on EKS
+//   - the container credentials endpoint of ECS and EKS Pod Identity
+//   - the instance metadata service (IMDSv2) of EC2
+//
+// Temporary credentials are cached until shortly before they expire.
+func NewDefaultAWSCredentialsProvider() AWSCredentialsProvider {
+	client := &http.Client{Timeout: 5 * time.Second}
+	return &awsCachedCredentials{provider: AWSCredentialsChain{
+		AWSCredentialsProviderFunc(awsEnvCredentials),
+		AWSCredentialsProviderFunc(awsSharedCredentials),
+		&awsWebIdentityCredentials{client: client},
+		&awsContainerCredentials{client: client},
+		&awsIMDSCredentials{client: &http.Client{Timeout: time.Second}, endpoint: awsIMDSEndpoint},
+	}}
+}
+
+// awsCachedCredentials caches the credentials of its provider until a few
+// minutes before they expire
+type awsCachedCredentials struct {
+	provider AWSCredentialsProvider
+
+	lock        sync.Mutex
+	credentials *AWSCredentials
+}
+
+func (c *awsCachedCredentials) Credentials() (AWSCredentials, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.credentials != nil && (c.credentials.Expires.IsZero() || time.Until(c.credentials.Expires) > 5*time.Minute) {
+		return *c.credentials, nil
+	}
+	credentials, err := c.provider.Credentials()
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	c.credentials = &credentials
+	return credentials, nil
+}
+
+func awsEnvCredentials() (AWSCredentials, error) {
+	credentials := AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return AWSCredentials{}, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are not set")
+	}
+	return credentials, nil
+}
+
+func awsSharedCredentials() (AWSCredentials, error) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return AWSCredentials{}, err
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	defer file.Close()
+	return parseAWSSharedCredentials(file, profile)
+}
+
+// parseAWSSharedCredentials reads the credentials of the profile from a
+// shared credentials file, which is in the INI format
+func parseAWSSharedCredentials(r io.Reader, profile string) (AWSCredentials, error) {
+	var credentials AWSCredentials
+	found := false
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			found = found || section == profile
+		case section == profile:
+			parts := strings.SplitN(line, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			value := strings.TrimSpace(parts[1])
+			switch strings.ToLower(strings.TrimSpace(parts[0])) {
+			case "aws_access_key_id":
+				credentials.AccessKeyID = value
+			case "aws_secret_access_key":
+				credentials.SecretAccessKey = value
+			case "aws_session_token":
+				credentials.SessionToken = value
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return AWSCredentials{}, err
+	}
+	if !found {
+		return AWSCredentials{}, fmt.Errorf("no profile %s in the shared credentials file", profile)
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return AWSCredentials{}, fmt.Errorf("the profile %s of the shared credentials file has no access key", profile)
+	}
+	return credentials, nil
+}
+
+// awsTemporaryCredentials is the JSON document of the container credentials
+// endpoint and of the instance metadata service
+type awsTemporaryCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+	Expiration      string `json:"Expiration"`
+}
+
+func (c *awsTemporaryCredentials) credentials() (AWSCredentials, error) {
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return AWSCredentials{}, errors.New("the AWS credentials have no access key")
+	}
+	credentials := AWSCredentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, SessionToken: c.Token}
+	if c.Expiration != "" {
+		expires, err := time.Parse(time.RFC3339, c.Expiration)
+		if err != nil {
+			return AWSCredentials{}, fmt.Errorf("invalid expiration of the AWS credentials: %w", err)
+		}
+		credentials.Expires = expires
+	}
+	return credentials, nil
+}
+
+// awsHTTPRequest sends the request and returns the body of a successful
+// response
+func awsHTTPRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: %s", req.Method, req.URL.String(), resp.Status)
+	}
+	return body, nil
+}
+
+// awsWebIdentityCredentials assumes a role with a web identity token, as set
+// up by IAM roles for service accounts on EKS
+type awsWebIdentityCredentials struct {
+	client *http.Client
+}
+
+func (c *awsWebIdentityCredentials) Credentials() (AWSCredentials, error) {
+	tokenFile, roleARN := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN")
+	if tokenFile == "" || roleARN == "" {
+		return AWSCredentials{}, errors.New("AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN are not set")
+	}
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	sessionName := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = "sarama-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	}
+
+	endpoint := "https://sts.amazonaws.com/"
+	if region := (&AWSMSKIAMConfig{}).region(); region != "" {
+		endpoint = "https://sts." + region + ".amazonaws.com/"
+	}
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	body, err := awsHTTPRequest(c.client, req)
+	if err != nil {
+		return AWSCredentials{}, fmt.Errorf("failed to assume %s with the web identity token: %w", roleARN, err)
+	}
+
+	var resp struct {
+		Credentials struct {
+			AccessKeyID     string `xml:"AccessKeyId"`
+			SecretAccessKey string `xml:"SecretAccessKey"`
+			SessionToken    string `xml:"SessionToken"`
+			Expiration      string `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return AWSCredentials{}, fmt.Errorf("failed to decode the AssumeRoleWithWebIdentity response: %w", err)
+	}
+	credentials := awsTemporaryCredentials{
+		AccessKeyID:     resp.Credentials.AccessKeyID,
+		SecretAccessKey: resp.Credentials.SecretAccessKey,
+		Token:           resp.Credentials.SessionToken,
+		Expiration:      resp.Credentials.Expiration,
+	}
+	return credentials.credentials()
+}
+
+// awsContainerCredentials gets the credentials from the container
+// credentials endpoint of ECS and EKS Pod Identity
+type awsContainerCredentials struct {
+	client *http.Client
+}
+
+func (c *awsContainerCredentials) Credentials() (AWSCredentials, error) {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		endpoint = "http://169.254.170.2" + relative
+	}
+	if endpoint == "" {
+		return AWSCredentials{}, errors.New("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI and AWS_CONTAINER_CREDENTIALS_FULL_URI are not set")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+	authorization := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if tokenFile := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); tokenFile != "" {
+		token, err := ioutil.ReadFile(tokenFile)
+		if err != nil {
+			return AWSCredentials{}, err
+		}
+		authorization = strings.TrimSpace(string(token))
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	body, err := awsHTTPRequest(c.client, req)
+	if err != nil {
+		return AWSCredentials{}, fmt.Errorf("failed to get the container credentials: %w", err)
+	}
+	var credentials awsTemporaryCredentials
+	if err := json.Unmarshal(body, &credentials); err != nil {
+		return AWSCredentials{}, fmt.Errorf("failed to decode the container credentials: %w", err)
+	}
+	return credentials.credentials()
+}
+
+const awsIMDSEndpoint = "http://169.254.169.254"
+
+// awsIMDSCredentials gets the credentials of the instance role from the EC2
+// instance metadata service, with the session tokens of IMDSv2
+type awsIMDSCredentials struct {
+	client   *http.Client
+	endpoint string
+}
+
+func (c *awsIMDSCredentials) Credentials() (AWSCredentials, error) {
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return AWSCredentials{}, errors.New("the EC2 instance metadata service is disabled")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	request := func(method, path string, header http.Header) ([]byte, error) {
+		req, err := http.NewRequest(method, c.endpoint+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		req.Header = header
+		return awsHTTPRequest(c.client, req)
+	}
+
+	token, err := request(http.MethodPut, "/latest/api/token", http.Header{"X-Aws-Ec2-Metadata-Token-Ttl-Seconds": {"21600"}})
+	if err != nil {
+		return AWSCredentials{}, fmt.Errorf("failed to get an EC2 instance metadata token: %w", err)
+	}
+	header := http.Header{"X-Aws-Ec2-Metadata-Token": {string(token)}}
+	roles, err := request(http.MethodGet, "/latest/meta-data/iam/security-credentials/", header)
+	if err != nil {
+		return AWSCredentials{}, fmt.Errorf("failed to get the EC2 instance role: %w", err)
+	}
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+	if role == "" {
+		return AWSCredentials{}, errors.New("the EC2 instance has no role")
+	}
+	body, err := request(http.MethodGet, "/latest/meta-data/iam/security-credentials/"+role, header)
+	if err != nil {
+		return AWSCredentials{}, fmt.Errorf("failed to get the credentials of the EC2 instance role: %w", err)
+	}
+	var credentials awsTemporaryCredentials
+	if err := json.Unmarshal(body, &credentials); err != nil {
+		return AWSCredentials{}, fmt.Errorf("failed to decode the credentials of the EC2 instance role: %w", err)
+	}
+	return credentials.credentials()
+}
+
+// awsURIEncode encodes s as required by Signature Version 4, which only
+// leaves the unreserved characters of RFC 3986
+func awsURIEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func awsHMAC(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsPresign adds the Signature Version 4 query parameters presigning the
+// request with the given method, host and path, which only signs the host
+// header, to query
+func awsPresign(credentials AWSCredentials, region, service, method, host, path string, query url.Values, payloadHash string, now time.Time, expires time.Duration) {
+	now = now.UTC()
+	date := now.Format(awsSigV4DateFormat)
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+
+	query.Set("X-Amz-Algorithm", awsSigV4Algorithm)
+	query.Set("X-Amz-Credential", credentials.AccessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", now.Format(awsSigV4TimeFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if credentials.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	params := make([]string, 0, len(keys))
+	for _, key := range keys {
+		params = append(params, awsURIEncode(key)+"="+awsURIEncode(query.Get(key)))
+	}
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		path,
+		strings.Join(params, "&"),
+		"host:" + host + "\n",
+		"host",
+		payloadHash,
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{awsSigV4Algorithm, now.Format(awsSigV4TimeFormat), scope, hex.EncodeToString(hash[:])}, "\n")
+
+	key := awsHMAC([]byte("AWS4"+credentials.SecretAccessKey), date)
+	key = awsHMAC(key, region)
+	key = awsHMAC(key, service)
+	key = awsHMAC(key, "aws4_request")
+	query.Set("X-Amz-Signature", hex.EncodeToString(awsHMAC(key, stringToSign)))
+}
+
+// awsMSKIAMPayload returns the authentication payload of the AWS_MSK_IAM
+// mechanism for the broker host: a JSON document of the query parameters
+// presigning a kafka-cluster:Connect request, as the aws-msk-iam-auth library
+// of the JVM client sends it
+func awsMSKIAMPayload(credentials AWSCredentials, region, host string, now time.Time) ([]byte, error) {
+	query := url.Values{"Action": {awsMSKIAMAction}}
+	awsPresign(credentials, region, awsMSKIAMService, http.MethodGet, host, "/", query, awsEmptyPayloadHash, now, awsSigV4Expires)
+
+	payload := map[string]string{
+		"version":    awsMSKIAMVersion,
+		"host":       host,
+		"user-agent": "sarama",
+	}
+	for key := range query {
+		payload[strings.ToLower(key)] = query.Get(key)
+	}
+	return json.Marshal(payload)
+}
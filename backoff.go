@@ -0,0 +1,84 @@
+package sarama
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// BackoffPolicy computes how long to wait before retrying a failed operation.
+// It can be set on the Retry namespaces of the Config to replace the fixed
+// Backoff durations, and must be safe for concurrent use.
+type BackoffPolicy interface {
+	// Backoff returns how long to wait before the given retry, numbered from
+	// 1. maxRetries is the configured maximum number of retries, or 0 when
+	// the operation is retried indefinitely.
+	Backoff(retries, maxRetries int) time.Duration
+}
+
+// BackoffPolicyFunc adapts a function to the BackoffPolicy interface.
+type BackoffPolicyFunc func(retries, maxRetries int) time.Duration
+
+// Backoff calls f(retries, maxRetries).
+func (f BackoffPolicyFunc) Backoff(retries, maxRetries int) time.Duration {
+	return f(retries, maxRetries)
+}
+
+// ExponentialBackoff is a BackoffPolicy whose backoff grows exponentially with
+// the number of retries, up to a maximum. A random jitter is applied so that
+// clients failing at the same time, for example after a broker restart, do
+// not all retry at once.
+type ExponentialBackoff struct {
+	// Initial is the backoff before the first retry.
+	Initial time.Duration
+	// Max caps the backoff before jitter, 0 means no limit.
+	Max time.Duration
+	// Multiplier is the factor the backoff grows by after each retry, values
+	// lower than 1 are treated as 2.
+	Multiplier float64
+	// Jitter is the fraction of the backoff, between 0 and 1, which is
+	// randomized: the actual backoff is picked uniformly between
+	// backoff*(1-Jitter) and backoff.
+	Jitter float64
+}
+
+// NewExponentialBackoff returns an ExponentialBackoff starting at initial and
+// doubling after each retry up to max, with half of it randomized.
+func NewExponentialBackoff(initial, max time.Duration) *ExponentialBackoff {
+	return &ExponentialBackoff{
+		Initial:    initial,
+		Max:        max,
+		Multiplier: 2,
+		Jitter:     0.5,
+	}
+}
+
+// Backoff implements BackoffPolicy.
+func (b *ExponentialBackoff) Backoff(retries, maxRetries int) time.Duration {
+	if retries < 1 {
+		retries = 1
+	}
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	backoff := float64(b.Initial) * math.Pow(multiplier, float64(retries-1))
+	if b.Max > 0 && backoff > float64(b.Max) {
+		backoff = float64(b.Max)
+	}
+	// math.Pow can overflow time.Duration for many retries without a Max,
+	// clamp with some room as float64(math.MaxInt64) rounds up
+	if backoff >= math.MaxInt64 {
+		backoff = math.MaxInt64 / 2
+	}
+
+	jitter := b.Jitter
+	if jitter > 1 {
+		jitter = 1
+	}
+	if jitter > 0 {
+		backoff -= backoff * jitter * rand.Float64()
+	}
+	return time.Duration(backoff)
+}
@@ -0,0 +1,57 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoffWithoutJitter(t *testing.T) {
+	backoff := &ExponentialBackoff{Initial: 100 * time.Millisecond, Max: time.Second}
+
+	for retries, expected := range []time.Duration{
+		100 * time.Millisecond, // retries below 1 are treated as the first retry
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		if actual := backoff.Backoff(retries, 10); actual != expected {
+			t.Errorf("retry %d: expected backoff %s, got %s", retries, expected, actual)
+		}
+	}
+
+	backoff.Multiplier = 3
+	if actual := backoff.Backoff(3, 10); actual != 900*time.Millisecond {
+		t.Errorf("expected backoff 900ms with a multiplier of 3, got %s", actual)
+	}
+}
+
+func TestExponentialBackoffWithJitter(t *testing.T) {
+	backoff := NewExponentialBackoff(100*time.Millisecond, time.Second)
+
+	seen := make(map[time.Duration]none)
+	for i := 0; i < 100; i++ {
+		actual := backoff.Backoff(3, 10)
+		if actual < 200*time.Millisecond || actual > 400*time.Millisecond {
+			t.Fatalf("expected backoff between 200ms and 400ms, got %s", actual)
+		}
+		seen[actual] = none{}
+	}
+	if len(seen) < 2 {
+		t.Error("expected the backoff to be randomized")
+	}
+
+	if actual := backoff.Backoff(100, 0); actual < 500*time.Millisecond || actual > time.Second {
+		t.Errorf("expected backoff capped between 500ms and 1s, got %s", actual)
+	}
+}
+
+func TestExponentialBackoffUnbounded(t *testing.T) {
+	backoff := &ExponentialBackoff{Initial: time.Second}
+
+	if actual := backoff.Backoff(1000, 0); actual <= 0 {
+		t.Errorf("expected a positive backoff without a maximum, got %s", actual)
+	}
+}
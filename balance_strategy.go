@@ -19,6 +19,12 @@ const (
 	// StickyBalanceStrategyName identifies strategies that use the sticky-partition assignment strategy
 	StickyBalanceStrategyName = "sticky"
 
+	// RackAwareBalanceStrategyName identifies strategies that use the rack-aware partition assignment strategy
+	RackAwareBalanceStrategyName = "rack-aware"
+
+	// LagAwareBalanceStrategyName identifies strategies that use the lag-aware partition assignment strategy
+	LagAwareBalanceStrategyName = "lag-aware"
+
 	defaultGeneration = -1
 )
 
@@ -55,6 +61,38 @@ type BalanceStrategy interface {
 	AssignmentData(memberID string, topics map[string][]int32, generationID int32) ([]byte, error)
 }
 
+// BalanceStrategyMemberData may optionally be implemented by a BalanceStrategy
+// which needs to exchange its own user data with the group, e.g. to pass
+// capacity hints of each member to the leader. The user data of all members is
+// available to Plan through ConsumerGroupMemberMetadata.UserData, and the data
+// returned by AssignmentData is delivered back to each member.
+type BalanceStrategyMemberData interface {
+	BalanceStrategy
+
+	// MemberUserData returns the user data to send in the metadata of this
+	// member when joining the group. assignmentUserData is the user data
+	// received with the previous assignment of the member, nil on first join.
+	// It replaces Config.Consumer.Group.Member.UserData.
+	MemberUserData(topics []string, assignmentUserData []byte) ([]byte, error)
+
+	// OnAssignment is called with the assignment of this member, including the
+	// user data returned by AssignmentData on the leader, once the group has
+	// been synced and before the session starts.
+	OnAssignment(assignment *ConsumerGroupMemberAssignment, generationID int32) error
+}
+
+// BalanceStrategyReplicaRacks may optionally be implemented by a BalanceStrategy
+// which takes the location of the partition replicas into account, e.g. to
+// assign partitions to members in the same rack (KIP-881).
+type BalanceStrategyReplicaRacks interface {
+	BalanceStrategy
+
+	// PlanWithReplicaRacks is called instead of Plan with the racks of the
+	// replicas of each partition in the form of a `topic -> partition -> racks`
+	// map, left empty for partitions whose replica racks are unknown.
+	PlanWithReplicaRacks(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32, replicaRacks map[string]map[int32][]string) (BalanceStrategyPlan, error)
+}
+
 // --------------------------------------------------------------------
 
 // BalanceStrategyRange is the default and assigns partitions as ranges to consumer group members.
@@ -428,6 +466,320 @@ func (m *memberAndTopic) hasTopic(topic string) bool {
 	return isExist
 }
 
+// BalanceStrategyRackAware assigns the partitions of each topic evenly to the
+// members subscribed to it, preferring members whose Config.RackID matches the
+// rack of one of the partition replicas. Combined with Config.RackID and
+// fetching from the closest replica, this avoids cross-rack fetch traffic.
+// The number of partitions per member is the same as with BalanceStrategyRange.
+// Example with topic T with four partitions (0..3) whose replicas are in racks
+// (R1, R2, R1, R2) and two members (M1 in R1, M2 in R2):
+//   M1: {T: [0, 2]}
+//   M2: {T: [1, 3]}
+var BalanceStrategyRackAware = new(rackAwareBalancer)
+
+type rackAwareBalancer struct{}
+
+// Name implements BalanceStrategy.
+func (b *rackAwareBalancer) Name() string { return RackAwareBalanceStrategyName }
+
+// Plan implements BalanceStrategy, without any replica racks.
+func (b *rackAwareBalancer) Plan(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32) (BalanceStrategyPlan, error) {
+	return b.PlanWithReplicaRacks(members, topics, nil)
+}
+
+// PlanWithReplicaRacks implements BalanceStrategyReplicaRacks.
+func (b *rackAwareBalancer) PlanWithReplicaRacks(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32, replicaRacks map[string]map[int32][]string) (BalanceStrategyPlan, error) {
+	// Build members by topic map
+	mbt := make(map[string][]string)
+	for memberID, meta := range members {
+		for _, topic := range meta.Topics {
+			mbt[topic] = append(mbt[topic], memberID)
+		}
+	}
+
+	plan := make(BalanceStrategyPlan, len(members))
+	for topic, memberIDs := range mbt {
+		sort.Strings(memberIDs)
+
+		partitions := make([]int32, len(topics[topic]))
+		copy(partitions, topics[topic])
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+		// every member gets the same share of partitions, the first ones one
+		// more until the remainder is exhausted
+		quotas := make(map[string]int, len(memberIDs))
+		for i, memberID := range memberIDs {
+			quotas[memberID] = len(partitions) / len(memberIDs)
+			if i < len(partitions)%len(memberIDs) {
+				quotas[memberID]++
+			}
+		}
+
+		// first pass: assign partitions to members in the rack of a replica
+		var unassigned []int32
+		for _, partition := range partitions {
+			var candidate string
+			for _, memberID := range memberIDs {
+				rack := members[memberID].RackID
+				if quotas[memberID] == 0 || rack == nil || !stringSliceContains(replicaRacks[topic][partition], *rack) {
+					continue
+				}
+				if candidate == "" || quotas[memberID] > quotas[candidate] {
+					candidate = memberID
+				}
+			}
+			if candidate == "" {
+				unassigned = append(unassigned, partition)
+				continue
+			}
+			plan.Add(candidate, topic, partition)
+			quotas[candidate]--
+		}
+
+		// second pass: fill the remaining quotas regardless of racks
+		for _, memberID := range memberIDs {
+			for quotas[memberID] > 0 && len(unassigned) > 0 {
+				plan.Add(memberID, topic, unassigned[0])
+				unassigned = unassigned[1:]
+				quotas[memberID]--
+			}
+		}
+	}
+	return plan, nil
+}
+
+// AssignmentData implements BalanceStrategy, no assignment data is required.
+func (b *rackAwareBalancer) AssignmentData(memberID string, topics map[string][]int32, generationID int32) ([]byte, error) {
+	return nil, nil
+}
+
+// NewBalanceStrategyLagAware returns a strategy which distributes the lag of
+// the group evenly across its members, rather than the number of partitions,
+// so that no member ends up with all the backed-up partitions. The lag of each
+// partition is the difference between its newest offset and the offset
+// committed by groupID, both fetched through client when planning. Partitions
+// are assigned by decreasing lag to the member with the least total lag so
+// far, preferring the member with the fewest partitions on ties. If the lag
+// cannot be fetched, partitions are distributed by count.
+// Example with topic T with four partitions (0..3) lagging by (100, 10, 10, 80),
+// and two members (M1, M2):
+//   M1: {T: [0]}
+//   M2: {T: [1, 2, 3]}
+func NewBalanceStrategyLagAware(client Client, groupID string) BalanceStrategy {
+	return &lagAwareBalancer{
+		lagFn: func(topics map[string][]int32) (map[string]map[int32]int64, error) {
+			return fetchGroupLag(client, groupID, topics)
+		},
+	}
+}
+
+type lagAwareBalancer struct {
+	lagFn func(topics map[string][]int32) (map[string]map[int32]int64, error)
+}
+
+// Name implements BalanceStrategy.
+func (b *lagAwareBalancer) Name() string { return LagAwareBalanceStrategyName }
+
+// Plan implements BalanceStrategy.
+func (b *lagAwareBalancer) Plan(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32) (BalanceStrategyPlan, error) {
+	lag, err := b.lagFn(topics)
+	if err != nil {
+		Logger.Printf("lag-aware balance strategy failed to fetch the lag, balancing by partition count: %v\n", err)
+		lag = nil
+	}
+
+	type lagPartition struct {
+		topicAndPartition
+		lag int64
+	}
+	var partitions []lagPartition
+	for topic, ps := range topics {
+		for _, partition := range ps {
+			partitions = append(partitions, lagPartition{
+				topicAndPartition: topicAndPartition{topic: topic, partition: partition},
+				lag:               lag[topic][partition],
+			})
+		}
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		if partitions[i].lag != partitions[j].lag {
+			return partitions[i].lag > partitions[j].lag
+		}
+		if partitions[i].topic != partitions[j].topic {
+			return partitions[i].topic < partitions[j].topic
+		}
+		return partitions[i].partition < partitions[j].partition
+	})
+
+	memberIDs := make([]string, 0, len(members))
+	for memberID := range members {
+		memberIDs = append(memberIDs, memberID)
+	}
+	sort.Strings(memberIDs)
+
+	subscribed := make(map[string]map[string]bool, len(members))
+	for memberID, meta := range members {
+		subscribed[memberID] = make(map[string]bool, len(meta.Topics))
+		for _, topic := range meta.Topics {
+			subscribed[memberID][topic] = true
+		}
+	}
+
+	plan := make(BalanceStrategyPlan, len(members))
+	totalLag := make(map[string]int64, len(members))
+	count := make(map[string]int, len(members))
+	for _, p := range partitions {
+		var candidate string
+		for _, memberID := range memberIDs {
+			if !subscribed[memberID][p.topic] {
+				continue
+			}
+			if candidate == "" || totalLag[memberID] < totalLag[candidate] ||
+				(totalLag[memberID] == totalLag[candidate] && count[memberID] < count[candidate]) {
+				candidate = memberID
+			}
+		}
+		if candidate == "" {
+			continue
+		}
+		plan.Add(candidate, p.topic, p.partition)
+		totalLag[candidate] += p.lag
+		count[candidate]++
+	}
+	return plan, nil
+}
+
+// AssignmentData implements BalanceStrategy, no assignment data is required.
+func (b *lagAwareBalancer) AssignmentData(memberID string, topics map[string][]int32, generationID int32) ([]byte, error) {
+	return nil, nil
+}
+
+// fetchGroupLag returns the lag of groupID on every partition of topics.
+// Partitions without a committed offset lag by their whole retained log. The
+// committed offsets are fetched with a single OffsetFetchRequest and the log
+// offsets with one OffsetRequest per partition leader.
+func fetchGroupLag(client Client, groupID string, topics map[string][]int32) (map[string]map[int32]int64, error) {
+	coordinator, err := client.Coordinator(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &OffsetFetchRequest{Version: 1, ConsumerGroup: groupID}
+	for topic, partitions := range topics {
+		for _, partition := range partitions {
+			req.AddPartition(topic, partition)
+		}
+	}
+	resp, err := coordinator.FetchOffset(req)
+	if err != nil {
+		return nil, err
+	}
+
+	newest, err := getLeaderOffsets(client, topics, OffsetNewest)
+	if err != nil {
+		return nil, err
+	}
+
+	committed := make(map[string]map[int32]int64, len(topics))
+	uncommitted := make(map[string][]int32)
+	for topic, partitions := range topics {
+		committed[topic] = make(map[int32]int64, len(partitions))
+		for _, partition := range partitions {
+			if block := resp.GetBlock(topic, partition); block != nil && block.Err == ErrNoError && block.Offset >= 0 {
+				committed[topic][partition] = block.Offset
+			} else {
+				uncommitted[topic] = append(uncommitted[topic], partition)
+			}
+		}
+	}
+	if len(uncommitted) > 0 {
+		oldest, err := getLeaderOffsets(client, uncommitted, OffsetOldest)
+		if err != nil {
+			return nil, err
+		}
+		for topic, partitions := range oldest {
+			for partition, offset := range partitions {
+				committed[topic][partition] = offset
+			}
+		}
+	}
+
+	lag := make(map[string]map[int32]int64, len(topics))
+	for topic, partitions := range topics {
+		lag[topic] = make(map[int32]int64, len(partitions))
+		for _, partition := range partitions {
+			if n, c := newest[topic][partition], committed[topic][partition]; n > c {
+				lag[topic][partition] = n - c
+			}
+		}
+	}
+	return lag, nil
+}
+
+// getLeaderOffsets returns the offsets at time of the given partitions, as
+// client.GetOffset does, sending a single OffsetRequest to each of their
+// leaders
+func getLeaderOffsets(client Client, topics map[string][]int32, time int64) (map[string]map[int32]int64, error) {
+	version := int16(0)
+	if client.Config().Version.IsAtLeast(V0_10_1_0) {
+		version = 1
+	}
+
+	requests := make(map[*Broker]*OffsetRequest)
+	for topic, partitions := range topics {
+		for _, partition := range partitions {
+			broker, err := client.Leader(topic, partition)
+			if err != nil {
+				return nil, err
+			}
+			request := requests[broker]
+			if request == nil {
+				request = &OffsetRequest{Version: version}
+				requests[broker] = request
+			}
+			request.AddBlock(topic, partition, time, 1)
+		}
+	}
+
+	offsets := make(map[string]map[int32]int64, len(topics))
+	for broker, request := range requests {
+		response, err := broker.GetAvailableOffsets(request)
+		if err != nil {
+			_ = broker.Close()
+			return nil, err
+		}
+		for topic, partitions := range request.blocks {
+			if offsets[topic] == nil {
+				offsets[topic] = make(map[int32]int64, len(partitions))
+			}
+			for partition := range partitions {
+				block := response.GetBlock(topic, partition)
+				if block == nil {
+					_ = broker.Close()
+					return nil, ErrIncompleteResponse
+				}
+				if block.Err != ErrNoError {
+					return nil, block.Err
+				}
+				if len(block.Offsets) != 1 {
+					return nil, ErrOffsetOutOfRange
+				}
+				offsets[topic][partition] = block.Offsets[0]
+			}
+		}
+	}
+	return offsets, nil
+}
+
+func stringSliceContains(vs []string, v string) bool {
+	for _, s := range vs {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
 // Calculate the balance score of the given assignment, as the sum of assigned partitions size difference of all consumer pairs.
 // A perfectly balanced assignment (with all consumers getting the same number of partitions) has a balance score of 0.
 // Lower balance score indicates a more balanced assignment.
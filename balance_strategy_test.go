@@ -165,6 +165,185 @@ func TestBalanceStrategyRoundRobin(t *testing.T) {
 	}
 }
 
+func TestBalanceStrategyRackAware(t *testing.T) {
+	tests := []struct {
+		members      map[string][]string
+		racks        map[string]string
+		topics       map[string][]int32
+		replicaRacks map[string]map[int32][]string
+		expected     BalanceStrategyPlan
+	}{
+		{
+			members: map[string][]string{"M1": {"T"}, "M2": {"T"}},
+			racks:   map[string]string{"M1": "R1", "M2": "R2"},
+			topics:  map[string][]int32{"T": {0, 1, 2, 3}},
+			replicaRacks: map[string]map[int32][]string{
+				"T": {0: {"R1"}, 1: {"R2"}, 2: {"R1"}, 3: {"R2"}},
+			},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T": {0, 2}},
+				"M2": map[string][]int32{"T": {1, 3}},
+			},
+		},
+		{
+			// every replica is in R1, balance wins over locality
+			members: map[string][]string{"M1": {"T"}, "M2": {"T"}},
+			racks:   map[string]string{"M1": "R1", "M2": "R2"},
+			topics:  map[string][]int32{"T": {0, 1, 2, 3}},
+			replicaRacks: map[string]map[int32][]string{
+				"T": {0: {"R1"}, 1: {"R1"}, 2: {"R1"}, 3: {"R1"}},
+			},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T": {0, 1}},
+				"M2": map[string][]int32{"T": {2, 3}},
+			},
+		},
+		{
+			// unknown racks
+			members: map[string][]string{"M1": {"T1", "T2"}, "M2": {"T1"}},
+			topics:  map[string][]int32{"T1": {0, 1, 2}, "T2": {0}},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T1": {0, 1}, "T2": {0}},
+				"M2": map[string][]int32{"T1": {2}},
+			},
+		},
+	}
+
+	strategy := BalanceStrategyRackAware
+	if strategy.Name() != "rack-aware" {
+		t.Errorf("Unexpected strategy name\nexpected: rack-aware\nactual: %v", strategy.Name())
+	}
+
+	for _, test := range tests {
+		members := make(map[string]ConsumerGroupMemberMetadata)
+		for memberID, topics := range test.members {
+			meta := ConsumerGroupMemberMetadata{Topics: topics}
+			if rack, ok := test.racks[memberID]; ok {
+				meta.RackID = &rack
+			}
+			members[memberID] = meta
+		}
+
+		actual, err := strategy.PlanWithReplicaRacks(members, test.topics, test.replicaRacks)
+		if err != nil {
+			t.Errorf("Unexpected error %v", err)
+		} else if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Plan does not match expectation\nexpected: %#v\nactual: %#v", test.expected, actual)
+		}
+	}
+}
+
+func TestBalanceStrategyLagAware(t *testing.T) {
+	tests := []struct {
+		members  map[string][]string
+		topics   map[string][]int32
+		lag      map[string]map[int32]int64
+		expected BalanceStrategyPlan
+	}{
+		{
+			members: map[string][]string{"M1": {"T"}, "M2": {"T"}},
+			topics:  map[string][]int32{"T": {0, 1, 2, 3}},
+			lag:     map[string]map[int32]int64{"T": {0: 100, 1: 10, 2: 10, 3: 80}},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T": {0}},
+				"M2": map[string][]int32{"T": {3, 1, 2}},
+			},
+		},
+		{
+			// no lag at all, balanced by partition count
+			members: map[string][]string{"M1": {"T1", "T2"}, "M2": {"T1"}},
+			topics:  map[string][]int32{"T1": {0, 1}, "T2": {0, 1}},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T1": {0}, "T2": {0, 1}},
+				"M2": map[string][]int32{"T1": {1}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		lag := test.lag
+		strategy := &lagAwareBalancer{
+			lagFn: func(map[string][]int32) (map[string]map[int32]int64, error) { return lag, nil },
+		}
+		if strategy.Name() != "lag-aware" {
+			t.Errorf("Unexpected strategy name\nexpected: lag-aware\nactual: %v", strategy.Name())
+		}
+
+		members := make(map[string]ConsumerGroupMemberMetadata)
+		for memberID, topics := range test.members {
+			members[memberID] = ConsumerGroupMemberMetadata{Topics: topics}
+		}
+
+		actual, err := strategy.Plan(members, test.topics)
+		if err != nil {
+			t.Errorf("Unexpected error %v", err)
+		} else if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Plan does not match expectation\nexpected: %#v\nactual: %#v", test.expected, actual)
+		}
+	}
+}
+
+func TestFetchGroupLag(t *testing.T) {
+	broker := NewMockBroker(t, 1)
+	defer broker.Close()
+
+	broker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("my_topic", 0, broker.BrokerID()).
+			SetLeader("my_topic", 1, broker.BrokerID()).
+			SetLeader("my_topic", 2, broker.BrokerID()),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "my_group", broker),
+		"OffsetFetchRequest": NewMockOffsetFetchResponse(t).
+			SetOffset("my_group", "my_topic", 0, 40, "", ErrNoError).
+			SetOffset("my_group", "my_topic", 1, -1, "", ErrNoError),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetVersion(1).
+			SetOffset("my_topic", 0, OffsetNewest, 100).
+			SetOffset("my_topic", 1, OffsetNewest, 50).
+			SetOffset("my_topic", 1, OffsetOldest, 20).
+			SetOffset("my_topic", 2, OffsetNewest, 10).
+			SetOffset("my_topic", 2, OffsetOldest, 10),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	client, err := NewClient([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	lag, err := fetchGroupLag(client, "my_group", map[string][]int32{"my_topic": {0, 1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]map[int32]int64{"my_topic": {0: 60, 1: 30}}
+	if !reflect.DeepEqual(lag, expected) {
+		t.Errorf("Expected lag %v, got %v", expected, lag)
+	}
+
+	// a single OffsetFetchRequest for the group, and an OffsetRequest to the
+	// leader for the newest offsets then one for the oldest ones of the
+	// partitions without a committed offset
+	var fetches, offsets int
+	for _, rr := range broker.History() {
+		switch req := rr.Request.(type) {
+		case *OffsetFetchRequest:
+			fetches++
+		case *OffsetRequest:
+			offsets++
+			if offsets == 2 && len(req.blocks["my_topic"]) != 2 {
+				t.Errorf("Expected the oldest offsets of 2 partitions, got %v", req.blocks["my_topic"])
+			}
+		}
+	}
+	if fetches != 1 || offsets != 2 {
+		t.Errorf("Expected 1 OffsetFetchRequest and 2 OffsetRequests, got %d and %d", fetches, offsets)
+	}
+}
+
 func Test_deserializeTopicPartitionAssignment(t *testing.T) {
 	type args struct {
 		userDataBytes []byte
@@ -1,10 +1,14 @@
 package sarama
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand"
 	"net"
 	"sort"
 	"strconv"
@@ -30,26 +34,72 @@ type Broker struct {
 	opened        int32
 	responses     chan responsePromise
 	done          chan bool
+	// gate limits the requests in flight when Net.MaxOpenRequestsByKey or
+	// Net.PrioritizeRequests is set, protected by lock
+	gate *requestGate
+	// breaker is the circuit breaker of the client the broker belongs to, set
+	// before the broker is used when Net.CircuitBreaker is enabled
+	breaker *circuitBreaker
+
+	// pool holds the additional connections to the broker when
+	// Net.ConnectionsPerBroker is larger than 1, pool[0] being unused as the
+	// broker itself is the first connection. pooled is true for those
+	// additional connections, which share the metrics of their broker.
+	pool     []*Broker
+	poolLock sync.Mutex
+	pooled   bool
+
+	// shared is the broker whose connections a view returned by withContext
+	// sends its requests on, no longer waiting for their responses once ctx
+	// is done
+	shared *Broker
+	ctx    context.Context
+
+	// throttledUntil is when the requests on this connection can be sent
+	// again after the broker asked us to back off, protected by lock
+	throttledUntil time.Time
+
+	// certificate is the *tls.Certificate from Net.TLS.CertificateProvider
+	// the connection authenticated with, recycleLock serializes reconnecting
+	// once it is rotated
+	certificate atomic.Value
+	recycleLock sync.Mutex
+
+	// reauthenticateAt is when the SASL session is re-authenticated over the
+	// connection, ahead of the session lifetime returned by the broker when it
+	// enforces connections.max.reauth.ms (KIP-368), protected by lock
+	reauthenticateAt time.Time
+
+	// connectionState is the ConnectionState of the broker and inFlight the
+	// number of requests waiting for their response, both updated atomically,
+	// lastError is the brokerError of the last failed connection or request
+	connectionState int32
+	inFlight        int32
+	lastError       atomic.Value
 
 	registeredMetrics []string
-
-	incomingByteRate       metrics.Meter
-	requestRate            metrics.Meter
-	requestSize            metrics.Histogram
-	requestLatency         metrics.Histogram
-	outgoingByteRate       metrics.Meter
-	responseRate           metrics.Meter
-	responseSize           metrics.Histogram
-	requestsInFlight       metrics.Counter
-	brokerIncomingByteRate metrics.Meter
-	brokerRequestRate      metrics.Meter
-	brokerRequestSize      metrics.Histogram
-	brokerRequestLatency   metrics.Histogram
-	brokerOutgoingByteRate metrics.Meter
-	brokerResponseRate     metrics.Meter
-	brokerResponseSize     metrics.Histogram
-	brokerRequestsInFlight metrics.Counter
-	brokerThrottleTime     metrics.Histogram
+	// apiLatencies are the latency histograms of the API keys, created on
+	// first use
+	apiLatencies map[int16]*apiLatency
+
+	incomingByteRate       MetricMeter
+	requestRate            MetricMeter
+	requestSize            MetricHistogram
+	requestLatency         MetricHistogram
+	outgoingByteRate       MetricMeter
+	responseRate           MetricMeter
+	responseSize           MetricHistogram
+	requestsInFlight       MetricCounter
+	throttleTime           MetricHistogram
+	brokerIncomingByteRate MetricMeter
+	brokerRequestRate      MetricMeter
+	brokerRequestSize      MetricHistogram
+	brokerRequestLatency   MetricHistogram
+	brokerOutgoingByteRate MetricMeter
+	brokerResponseRate     MetricMeter
+	brokerResponseSize     MetricHistogram
+	brokerRequestsInFlight MetricCounter
+	brokerThrottleTime     MetricHistogram
 
 	kerberosAuthenticator GSSAPIKerberosAuth
 }
@@ -121,7 +171,10 @@ type responsePromise struct {
 	requestTime   time.Time
 	correlationID int32
 	headerVersion int16
-	packets       chan []byte
+	traced        bool // whether the request is traced by Net.ProtocolTrace
+	latency       *apiLatency
+	pooled        bool // whether the response is read into a buffer of fetchBuffers
+	packets       chan *pooledBuffer
 	errors        chan error
 }
 
@@ -137,6 +190,9 @@ func NewBroker(addr string) *Broker {
 // follow it by a call to Connected(). The only errors Open will return directly are ConfigurationError or
 // AlreadyConnected. If conf is nil, the result of NewConfig() is used.
 func (b *Broker) Open(conf *Config) error {
+	if b.shared != nil {
+		return b.shared.Open(conf)
+	}
 	if !atomic.CompareAndSwapInt32(&b.opened, 0, 1) {
 		return ErrAlreadyConnected
 	}
@@ -150,52 +206,85 @@ func (b *Broker) Open(conf *Config) error {
 		return err
 	}
 
+	atomic.StoreInt32(&b.connectionState, int32(ConnectionConnecting))
 	b.lock.Lock()
 
+	// read before connecting, as conf may be reused for other clients meanwhile
+	sendClientSoftware := conf.Version.IsAtLeast(V2_4_0_0)
+	clientSoftwareName, clientSoftwareVersion := conf.ClientSoftwareName, conf.ClientSoftwareVersion
+
 	go withRecover(func() {
-		defer b.lock.Unlock()
+		// the events are sent once the lock is released
+		var events []Event
+		defer func() {
+			b.lock.Unlock()
+			for _, event := range events {
+				conf.notify(event)
+			}
+		}()
 
-		dialer := conf.getDialer()
-		b.conn, b.connErr = dialer.Dial("tcp", b.addr)
+		b.conn, b.connErr = conf.dial("tcp", b.addr)
 		if b.connErr != nil {
-			Logger.Printf("Failed to connect to broker %s: %s\n", b.addr, b.connErr)
+			b.connErr = NetworkError{Addr: b.addr, Err: b.connErr}
+			logEntry(LogComponentNetwork, LogLevelWarn, "broker failed to connect", brokerField(b.id), addrField(b.addr), errorField(b.connErr))
 			b.conn = nil
+			b.connectionFailed(b.connErr)
 			atomic.StoreInt32(&b.opened, 0)
 			return
 		}
+		if conf.Net.OnConnect != nil {
+			b.conn = conf.Net.OnConnect(b.conn, b.id)
+		}
 		if conf.Net.TLS.Enable {
-			b.conn = tls.Client(b.conn, validServerNameTLS(b.addr, conf.Net.TLS.Config))
+			b.conn = tls.Client(b.conn, b.tlsConfig(conf))
 		}
 
 		b.conn = newBufConn(b.conn)
 		b.conf = conf
+		b.gate = newRequestGate(conf)
 
 		// Create or reuse the global metrics shared between brokers
-		b.incomingByteRate = metrics.GetOrRegisterMeter("incoming-byte-rate", conf.MetricRegistry)
-		b.requestRate = metrics.GetOrRegisterMeter("request-rate", conf.MetricRegistry)
-		b.requestSize = getOrRegisterHistogram("request-size", conf.MetricRegistry)
-		b.requestLatency = getOrRegisterHistogram("request-latency-in-ms", conf.MetricRegistry)
-		b.outgoingByteRate = metrics.GetOrRegisterMeter("outgoing-byte-rate", conf.MetricRegistry)
-		b.responseRate = metrics.GetOrRegisterMeter("response-rate", conf.MetricRegistry)
-		b.responseSize = getOrRegisterHistogram("response-size", conf.MetricRegistry)
-		b.requestsInFlight = metrics.GetOrRegisterCounter("requests-in-flight", conf.MetricRegistry)
+		recorder := conf.metricsRecorder()
+		b.incomingByteRate = recorder.Meter("incoming-byte-rate")
+		b.requestRate = recorder.Meter("request-rate")
+		b.requestSize = recorder.Histogram("request-size")
+		b.requestLatency = recorder.Histogram("request-latency-in-ms")
+		b.outgoingByteRate = recorder.Meter("outgoing-byte-rate")
+		b.responseRate = recorder.Meter("response-rate")
+		b.responseSize = recorder.Histogram("response-size")
+		b.requestsInFlight = recorder.Counter("requests-in-flight")
+		b.throttleTime = recorder.Histogram("throttle-time-in-ms")
 		// Do not gather metrics for seeded broker (only used during bootstrap) because they share
 		// the same id (-1) and are already exposed through the global metrics above
-		if b.id >= 0 && !metrics.UseNilMetrics {
+		if b.id >= 0 && !metrics.UseNilMetrics && !b.pooled {
 			b.registerMetrics()
 		}
 
+		if sendClientSoftware {
+			b.connErr = b.sendAndReceiveClientSoftware(clientSoftwareName, clientSoftwareVersion)
+			if b.connErr != nil {
+				logEntry(LogComponentNetwork, LogLevelWarn, "broker failed to send the client software", brokerField(b.id), addrField(b.addr), errorField(b.connErr))
+				_ = b.conn.Close()
+				b.conn = nil
+				b.connectionFailed(b.connErr)
+				atomic.StoreInt32(&b.opened, 0)
+				return
+			}
+		}
+
 		if conf.Net.SASL.Enable {
 			b.connErr = b.authenticateViaSASL()
 
 			if b.connErr != nil {
+				events = append(events, b.event(EventBrokerAuthFailed, b.connErr))
 				err = b.conn.Close()
 				if err == nil {
-					DebugLogger.Printf("Closed connection to broker %s\n", b.addr)
+					logEntry(LogComponentNetwork, LogLevelDebug, "broker closed connection", brokerField(b.id), addrField(b.addr))
 				} else {
-					Logger.Printf("Error while closing connection to broker %s: %s\n", b.addr, err)
+					logEntry(LogComponentNetwork, LogLevelWarn, "broker failed to close connection", brokerField(b.id), addrField(b.addr), errorField(err))
 				}
 				b.conn = nil
+				b.connectionFailed(b.connErr)
 				atomic.StoreInt32(&b.opened, 0)
 				return
 			}
@@ -204,12 +293,10 @@ func (b *Broker) Open(conf *Config) error {
 		b.done = make(chan bool)
 		b.responses = make(chan responsePromise, b.conf.Net.MaxOpenRequests-1)
 
-		if b.id >= 0 {
-			DebugLogger.Printf("Connected to broker at %s (registered as #%d)\n", b.addr, b.id)
-		} else {
-			DebugLogger.Printf("Connected to broker at %s (unregistered)\n", b.addr)
-		}
+		logEntry(LogComponentNetwork, LogLevelDebug, "broker connected", brokerField(b.id), addrField(b.addr))
+		atomic.StoreInt32(&b.connectionState, int32(ConnectionConnected))
 		go withRecover(b.responseReceiver)
+		events = append(events, b.event(EventBrokerConnected, nil))
 	})
 
 	return nil
@@ -218,6 +305,9 @@ func (b *Broker) Open(conf *Config) error {
 // Connected returns true if the broker is connected and false otherwise. If the broker is not
 // connected but it had tried to connect, the error from that connection attempt is also returned.
 func (b *Broker) Connected() (bool, error) {
+	if b.shared != nil {
+		return b.shared.Connected()
+	}
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
@@ -225,7 +315,10 @@ func (b *Broker) Connected() (bool, error) {
 }
 
 // Close closes the broker resources
-func (b *Broker) Close() error {
+func (b *Broker) Close() (err error) {
+	if b.shared != nil {
+		return b.shared.Close()
+	}
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
@@ -233,22 +326,28 @@ func (b *Broker) Close() error {
 		return ErrNotConnected
 	}
 
+	// the event is sent once the lock is released
+	conf := b.conf
+	defer func() { conf.notify(b.event(EventBrokerDisconnected, err)) }()
+
 	close(b.responses)
 	<-b.done
 
-	err := b.conn.Close()
+	err = b.conn.Close()
 
 	b.conn = nil
 	b.connErr = nil
 	b.done = nil
 	b.responses = nil
+	atomic.StoreInt32(&b.connectionState, int32(ConnectionDisconnected))
 
 	b.unregisterMetrics()
+	b.closePool()
 
 	if err == nil {
-		DebugLogger.Printf("Closed connection to broker %s\n", b.addr)
+		logEntry(LogComponentNetwork, LogLevelDebug, "broker closed connection", brokerField(b.id), addrField(b.addr))
 	} else {
-		Logger.Printf("Error while closing connection to broker %s: %s\n", b.addr, err)
+		logEntry(LogComponentNetwork, LogLevelWarn, "broker failed to close connection", brokerField(b.id), addrField(b.addr), errorField(err))
 	}
 
 	atomic.StoreInt32(&b.opened, 0)
@@ -280,6 +379,7 @@ func (b *Broker) Rack() string {
 // GetMetadata send a metadata request and returns a metadata response or error
 func (b *Broker) GetMetadata(request *MetadataRequest) (*MetadataResponse, error) {
 	response := new(MetadataResponse)
+	response.Version = request.Version // needed to handle the two header versions
 
 	err := b.sendAndReceive(request, response)
 	if err != nil {
@@ -304,6 +404,7 @@ func (b *Broker) GetConsumerMetadata(request *ConsumerMetadataRequest) (*Consume
 // FindCoordinator sends a find coordinate request and returns a response or error
 func (b *Broker) FindCoordinator(request *FindCoordinatorRequest) (*FindCoordinatorResponse, error) {
 	response := new(FindCoordinatorResponse)
+	response.Version = request.Version // needed to handle the two header versions
 
 	err := b.sendAndReceive(request, response)
 	if err != nil {
@@ -315,7 +416,7 @@ func (b *Broker) FindCoordinator(request *FindCoordinatorRequest) (*FindCoordina
 
 // GetAvailableOffsets return an offset response or error
 func (b *Broker) GetAvailableOffsets(request *OffsetRequest) (*OffsetResponse, error) {
-	response := new(OffsetResponse)
+	response := &OffsetResponse{Version: request.Version}
 
 	err := b.sendAndReceive(request, response)
 	if err != nil {
@@ -337,15 +438,6 @@ func (b *Broker) Produce(request *ProduceRequest) (*ProduceResponse, error) {
 	} else {
 		response = new(ProduceResponse)
 		err = b.sendAndReceive(request, response)
-		if response.ThrottleTime != time.Duration(0) {
-			DebugLogger.Printf(
-				"producer/broker/%d ProduceResponse throttled %v\n",
-				b.ID(), response.ThrottleTime)
-			if b.brokerThrottleTime != nil {
-				throttleTimeInMs := int64(response.ThrottleTime / time.Millisecond)
-				b.brokerThrottleTime.Update(throttleTimeInMs)
-			}
-		}
 	}
 
 	if err != nil {
@@ -357,7 +449,16 @@ func (b *Broker) Produce(request *ProduceRequest) (*ProduceResponse, error) {
 
 // Fetch returns a FetchResponse or error
 func (b *Broker) Fetch(request *FetchRequest) (*FetchResponse, error) {
+	return b.fetch(request, false)
+}
+
+// fetch is Fetch leaving the records of the response to decode by
+// FetchResponseBlock.decodeRecords when deferRecords is set.
+func (b *Broker) fetch(request *FetchRequest, deferRecords bool) (*FetchResponse, error) {
 	response := new(FetchResponse)
+	response.Version = request.Version // needed to handle the two header versions
+	response.TopicIDs = request.TopicIDs
+	response.deferRecords = deferRecords
 
 	err := b.sendAndReceive(request, response)
 	if err != nil {
@@ -370,6 +471,7 @@ func (b *Broker) Fetch(request *FetchRequest) (*FetchResponse, error) {
 // CommitOffset return an Offset commit response or error
 func (b *Broker) CommitOffset(request *OffsetCommitRequest) (*OffsetCommitResponse, error) {
 	response := new(OffsetCommitResponse)
+	response.Version = request.Version // needed to handle the two header versions
 
 	err := b.sendAndReceive(request, response)
 	if err != nil {
@@ -418,7 +520,7 @@ func (b *Broker) SyncGroup(request *SyncGroupRequest) (*SyncGroupResponse, error
 
 // LeaveGroup return a leave group response or error
 func (b *Broker) LeaveGroup(request *LeaveGroupRequest) (*LeaveGroupResponse, error) {
-	response := new(LeaveGroupResponse)
+	response := &LeaveGroupResponse{Version: request.Version}
 
 	err := b.sendAndReceive(request, response)
 	if err != nil {
@@ -442,7 +544,7 @@ func (b *Broker) Heartbeat(request *HeartbeatRequest) (*HeartbeatResponse, error
 
 // ListGroups return a list group response or error
 func (b *Broker) ListGroups(request *ListGroupsRequest) (*ListGroupsResponse, error) {
-	response := new(ListGroupsResponse)
+	response := &ListGroupsResponse{Version: request.Version}
 
 	err := b.sendAndReceive(request, response)
 	if err != nil {
@@ -466,7 +568,7 @@ func (b *Broker) DescribeGroups(request *DescribeGroupsRequest) (*DescribeGroups
 
 // ApiVersions return api version response or error
 func (b *Broker) ApiVersions(request *ApiVersionsRequest) (*ApiVersionsResponse, error) {
-	response := new(ApiVersionsResponse)
+	response := &ApiVersionsResponse{Version: request.Version}
 
 	err := b.sendAndReceive(request, response)
 	if err != nil {
@@ -526,6 +628,19 @@ func (b *Broker) AlterPartitionReassignments(request *AlterPartitionReassignment
 	return response, nil
 }
 
+// ElectLeaders sends an elect leaders request and returns elect leaders
+// response
+func (b *Broker) ElectLeaders(request *ElectLeadersRequest) (*ElectLeadersResponse, error) {
+	response := &ElectLeadersResponse{Version: request.Version}
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
 // ListPartitionReassignments sends a list partition reassignments request and
 // returns list partition reassignments response
 func (b *Broker) ListPartitionReassignments(request *ListPartitionReassignmentsRequest) (*ListPartitionReassignmentsResponse, error) {
@@ -654,7 +769,7 @@ func (b *Broker) TxnOffsetCommit(request *TxnOffsetCommitRequest) (*TxnOffsetCom
 // DescribeConfigs sends a request to describe config and returns a response or
 // error
 func (b *Broker) DescribeConfigs(request *DescribeConfigsRequest) (*DescribeConfigsResponse, error) {
-	response := new(DescribeConfigsResponse)
+	response := &DescribeConfigsResponse{Version: request.Version}
 
 	err := b.sendAndReceive(request, response)
 	if err != nil {
@@ -688,6 +803,54 @@ func (b *Broker) IncrementalAlterConfigs(request *IncrementalAlterConfigsRequest
 	return response, nil
 }
 
+// CreateDelegationToken sends a request to create a delegation token and returns a response or error
+func (b *Broker) CreateDelegationToken(request *CreateDelegationTokenRequest) (*CreateDelegationTokenResponse, error) {
+	response := new(CreateDelegationTokenResponse)
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// RenewDelegationToken sends a request to renew a delegation token and returns a response or error
+func (b *Broker) RenewDelegationToken(request *RenewDelegationTokenRequest) (*RenewDelegationTokenResponse, error) {
+	response := new(RenewDelegationTokenResponse)
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// ExpireDelegationToken sends a request to expire a delegation token and returns a response or error
+func (b *Broker) ExpireDelegationToken(request *ExpireDelegationTokenRequest) (*ExpireDelegationTokenResponse, error) {
+	response := new(ExpireDelegationTokenResponse)
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// DescribeDelegationToken sends a request to describe delegation tokens and returns a response or error
+func (b *Broker) DescribeDelegationToken(request *DescribeDelegationTokenRequest) (*DescribeDelegationTokenResponse, error) {
+	response := new(DescribeDelegationTokenResponse)
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
 // DeleteGroups sends a request to delete groups and returns a response or error
 func (b *Broker) DeleteGroups(request *DeleteGroupsRequest) (*DeleteGroupsResponse, error) {
 	response := new(DeleteGroupsResponse)
@@ -710,6 +873,88 @@ func (b *Broker) DeleteOffsets(request *DeleteOffsetsRequest) (*DeleteOffsetsRes
 	return response, nil
 }
 
+// DescribeProducers sends a request to describe the active producers of some
+// partitions led by the broker
+func (b *Broker) DescribeProducers(request *DescribeProducersRequest) (*DescribeProducersResponse, error) {
+	response := new(DescribeProducersResponse)
+
+	if err := b.sendAndReceive(request, response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// ListTransactions sends a request to list the transactions the broker is the
+// coordinator of
+func (b *Broker) ListTransactions(request *ListTransactionsRequest) (*ListTransactionsResponse, error) {
+	response := new(ListTransactionsResponse)
+
+	if err := b.sendAndReceive(request, response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// DescribeTransactions sends a request to describe transactions coordinated by
+// the broker
+func (b *Broker) DescribeTransactions(request *DescribeTransactionsRequest) (*DescribeTransactionsResponse, error) {
+	response := new(DescribeTransactionsResponse)
+
+	if err := b.sendAndReceive(request, response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// WriteTxnMarkers sends a request to write transaction markers to partitions
+// led by the broker
+func (b *Broker) WriteTxnMarkers(request *WriteTxnMarkersRequest) (*WriteTxnMarkersResponse, error) {
+	response := new(WriteTxnMarkersResponse)
+
+	if err := b.sendAndReceive(request, response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// UpdateFeatures sends a request to update the finalized versions of cluster
+// wide features
+func (b *Broker) UpdateFeatures(request *UpdateFeaturesRequest) (*UpdateFeaturesResponse, error) {
+	response := new(UpdateFeaturesResponse)
+
+	if err := b.sendAndReceive(request, response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// DescribeQuorum sends a request to describe the state of the KRaft quorum
+func (b *Broker) DescribeQuorum(request *DescribeQuorumRequest) (*DescribeQuorumResponse, error) {
+	response := new(DescribeQuorumResponse)
+
+	if err := b.sendAndReceive(request, response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// UnregisterBroker sends a request to remove the registration of a broker
+func (b *Broker) UnregisterBroker(request *UnregisterBrokerRequest) (*UnregisterBrokerResponse, error) {
+	response := new(UnregisterBrokerResponse)
+
+	if err := b.sendAndReceive(request, response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
 // DescribeLogDirs sends a request to get the broker's log dir paths and sizes
 func (b *Broker) DescribeLogDirs(request *DescribeLogDirsRequest) (*DescribeLogDirsResponse, error) {
 	response := new(DescribeLogDirsResponse)
@@ -745,6 +990,48 @@ func (b *Broker) AlterUserScramCredentials(req *AlterUserScramCredentialsRequest
 	return res, nil
 }
 
+// ConsumerGroupHeartbeat sends a heartbeat request using the next generation
+// consumer group protocol (KIP-848) and returns the response or error
+func (b *Broker) ConsumerGroupHeartbeat(request *ConsumerGroupHeartbeatRequest) (*ConsumerGroupHeartbeatResponse, error) {
+	response := new(ConsumerGroupHeartbeatResponse)
+	response.Version = request.Version
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// GetTelemetrySubscriptions sends a request to get the metrics the broker
+// wants the client to push (KIP-714) and returns the response or error
+func (b *Broker) GetTelemetrySubscriptions(request *GetTelemetrySubscriptionsRequest) (*GetTelemetrySubscriptionsResponse, error) {
+	response := new(GetTelemetrySubscriptionsResponse)
+	response.Version = request.Version
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// PushTelemetry sends a request to push the metrics of the client (KIP-714)
+// and returns the response or error
+func (b *Broker) PushTelemetry(request *PushTelemetryRequest) (*PushTelemetryResponse, error) {
+	response := new(PushTelemetryResponse)
+	response.Version = request.Version
+
+	err := b.sendAndReceive(request, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
 // DescribeClientQuotas sends a request to get the broker's quotas
 func (b *Broker) DescribeClientQuotas(request *DescribeClientQuotasRequest) (*DescribeClientQuotasResponse, error) {
 	response := new(DescribeClientQuotasResponse)
@@ -773,23 +1060,31 @@ func (b *Broker) AlterClientQuotas(request *AlterClientQuotasRequest) (*AlterCli
 // call to io.ReadFull
 func (b *Broker) readFull(buf []byte) (n int, err error) {
 	if err := b.conn.SetReadDeadline(time.Now().Add(b.conf.Net.ReadTimeout)); err != nil {
-		return 0, err
+		return 0, NetworkError{Addr: b.addr, Err: err}
 	}
 
-	return io.ReadFull(b.conn, buf)
+	n, err = io.ReadFull(b.conn, buf)
+	if err != nil {
+		err = NetworkError{Addr: b.addr, Err: err}
+	}
+	return n, err
 }
 
 // write  ensures the conn WriteDeadline has been setup before making a
 // call to conn.Write
 func (b *Broker) write(buf []byte) (n int, err error) {
 	if err := b.conn.SetWriteDeadline(time.Now().Add(b.conf.Net.WriteTimeout)); err != nil {
-		return 0, err
+		return 0, NetworkError{Addr: b.addr, Err: err}
 	}
 
-	return b.conn.Write(buf)
+	n, err = b.conn.Write(buf)
+	if err != nil {
+		err = NetworkError{Addr: b.addr, Err: err}
+	}
+	return n, err
 }
 
-func (b *Broker) send(rb protocolBody, promiseResponse bool, responseHeaderVersion int16) (*responsePromise, error) {
+func (b *Broker) send(rb protocolBody, promiseResponse bool, responseHeaderVersion int16, pooled bool) (*responsePromise, error) {
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
@@ -800,13 +1095,20 @@ func (b *Broker) send(rb protocolBody, promiseResponse bool, responseHeaderVersi
 		return nil, ErrNotConnected
 	}
 
+	traced := b.tracesRequest(rb)
 	if !b.conf.Version.IsAtLeast(rb.requiredVersion()) {
+		if traced {
+			b.traceRequest(rb, b.correlationID, 0, ErrUnsupportedVersion)
+		}
 		return nil, ErrUnsupportedVersion
 	}
 
 	req := &request{correlationID: b.correlationID, clientID: b.conf.ClientID, body: rb}
-	buf, err := encode(req, b.conf.MetricRegistry)
+	buf, err := encode(req, b.conf.metricsRecorder())
 	if err != nil {
+		if traced {
+			b.traceRequest(rb, req.correlationID, 0, err)
+		}
 		return nil, err
 	}
 
@@ -815,31 +1117,211 @@ func (b *Broker) send(rb protocolBody, promiseResponse bool, responseHeaderVersi
 	b.addRequestInFlightMetrics(1)
 	bytes, err := b.write(buf)
 	b.updateOutgoingCommunicationMetrics(bytes)
+	if traced {
+		b.traceRequest(rb, req.correlationID, bytes, err)
+	}
 	if err != nil {
 		b.addRequestInFlightMetrics(-1)
 		return nil, err
 	}
 	b.correlationID++
 
+	latency := b.apiLatency(rb.key())
 	if !promiseResponse {
 		// Record request latency without the response
 		b.updateRequestLatencyAndInFlightMetrics(time.Since(requestTime))
+		latency.update(time.Since(requestTime))
 		return nil, nil
 	}
 
-	promise := responsePromise{requestTime, req.correlationID, responseHeaderVersion, make(chan []byte), make(chan error)}
+	promise := responsePromise{requestTime, req.correlationID, responseHeaderVersion, traced, latency, pooled, make(chan *pooledBuffer), make(chan error)}
 	b.responses <- promise
 
 	return &promise, nil
 }
 
+// DefaultConnectionRouter is the default Net.ConnectionRouter. It sends the
+// produce requests on the second connection and the fetch requests on the
+// third one, or both on the second one when there are only two, and all the
+// other requests on the first connection.
+func DefaultConnectionRouter(apiKey int16, connections int) int {
+	switch {
+	case connections < 2:
+		return 0
+	case apiKey == 0: // Produce
+		return 1
+	case apiKey == 1: // Fetch
+		if connections < 3 {
+			return 1
+		}
+		return 2
+	default:
+		return 0
+	}
+}
+
+// circuitOpen returns whether the requests sent to the broker currently fail
+// with ErrCircuitOpen
+func (b *Broker) circuitOpen() bool {
+	return b.breaker != nil && b.breaker.isOpen(b.addr)
+}
+
+// connection returns the connection to send requests with the given API key
+// on, opening it if needed
+func (b *Broker) connection(apiKey int16) (*Broker, error) {
+	if b.pooled {
+		return b, nil
+	}
+
+	b.lock.Lock()
+	conf, connected := b.conf, b.conn != nil
+	b.lock.Unlock()
+	// send reports why the broker is not connected
+	if !connected || conf.Net.ConnectionsPerBroker < 2 {
+		return b, nil
+	}
+
+	router := conf.Net.ConnectionRouter
+	if router == nil {
+		router = DefaultConnectionRouter
+	}
+	index := router(apiKey, conf.Net.ConnectionsPerBroker)
+	if index <= 0 || index >= conf.Net.ConnectionsPerBroker {
+		return b, nil
+	}
+
+	// the lock is released before waiting for the dial, which would otherwise
+	// hold up the requests using the other connections
+	b.poolLock.Lock()
+	if len(b.pool) != conf.Net.ConnectionsPerBroker {
+		b.pool = make([]*Broker, conf.Net.ConnectionsPerBroker)
+	}
+	conn := b.pool[index]
+	if conn == nil {
+		conn = &Broker{
+			id:                     b.id,
+			addr:                   b.addr,
+			rack:                   b.rack,
+			pooled:                 true,
+			brokerIncomingByteRate: b.brokerIncomingByteRate,
+			brokerRequestRate:      b.brokerRequestRate,
+			brokerRequestSize:      b.brokerRequestSize,
+			brokerRequestLatency:   b.brokerRequestLatency,
+			brokerOutgoingByteRate: b.brokerOutgoingByteRate,
+			brokerResponseRate:     b.brokerResponseRate,
+			brokerResponseSize:     b.brokerResponseSize,
+			brokerRequestsInFlight: b.brokerRequestsInFlight,
+			brokerThrottleTime:     b.brokerThrottleTime,
+		}
+		b.pool[index] = conn
+	}
+	b.poolLock.Unlock()
+
+	if err := conn.Open(conf); err != nil && err != ErrAlreadyConnected {
+		return nil, err
+	}
+	if connected, err := conn.Connected(); !connected {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// closePool closes the additional connections to the broker
+func (b *Broker) closePool() {
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+
+	for _, conn := range b.pool {
+		if conn != nil {
+			_ = conn.Close()
+		}
+	}
+	b.pool = nil
+}
+
+// withContext returns a view of the broker for a single caller, sending its
+// requests on the connections of the broker but returning ctx.Err() instead
+// of waiting for their responses once ctx is done. The responses are still
+// read off the connections then, so that the other requests in flight on them
+// are not affected. Opening or closing the view opens or closes the broker.
+func (b *Broker) withContext(ctx context.Context) *Broker {
+	return &Broker{id: b.id, addr: b.addr, rack: b.rack, shared: b, ctx: ctx}
+}
+
 func (b *Broker) sendAndReceive(req protocolBody, res protocolBody) error {
+	if b.shared != nil {
+		return b.shared.sendAndReceiveContext(b.ctx, req, res)
+	}
+	return b.sendAndReceiveContext(context.Background(), req, res)
+}
+
+// sendAndReceiveContext sends req and decodes its response into res, unless
+// ctx is done first, see withContext
+func (b *Broker) sendAndReceiveContext(ctx context.Context, req protocolBody, res protocolBody) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if b.breaker != nil {
+		if err := b.breaker.allow(b); err != nil {
+			return err
+		}
+		defer func() { b.breaker.record(b, err) }()
+	}
+	if !b.pooled {
+		atomic.AddInt32(&b.inFlight, 1)
+		defer func() {
+			atomic.AddInt32(&b.inFlight, -1)
+			if err != nil && err != ctx.Err() {
+				b.lastError.Store(brokerError{err: err, at: time.Now()})
+			}
+		}()
+	}
+
+	conn, err := b.connection(req.key())
+	if err != nil {
+		return err
+	}
+	if conn != b {
+		return conn.sendAndReceiveContext(ctx, req, res)
+	}
+
+	b.lock.Lock()
+	gate := b.gate
+	conf := b.conf
+	b.lock.Unlock()
+	if span := b.startRequestSpan(conf, req); span != nil {
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+			}
+			span.End()
+		}()
+	}
+	// the gate is released once the response is read, which may be after
+	// the request was abandoned
+	release := gate != nil
+	if gate != nil {
+		gate.acquire(req.key())
+		defer func() {
+			if release {
+				gate.release(req.key())
+			}
+		}()
+	}
+
 	responseHeaderVersion := int16(-1)
 	if res != nil {
 		responseHeaderVersion = res.headerVersion()
 	}
+	// the messages of the fetch responses reference the buffer they were
+	// read into, which is recycled once they are all released
+	fetchResponse, pooled := res.(*FetchResponse)
+	pooled = pooled && conf.Consumer.Fetch.PooledBuffers
 
-	promise, err := b.send(req, res != nil, responseHeaderVersion)
+	b.recycleRotatedCertificate()
+	b.reauthenticateIfExpiring()
+	b.waitThrottle()
+	promise, err := b.send(req, res != nil, responseHeaderVersion, pooled)
 	if err != nil {
 		return err
 	}
@@ -849,10 +1331,211 @@ func (b *Broker) sendAndReceive(req protocolBody, res protocolBody) error {
 	}
 
 	select {
-	case buf := <-promise.packets:
-		return versionedDecode(buf, res, req.version())
+	case packet := <-promise.packets:
+		err = versionedDecode(packet.buf, res, req.version())
+		if promise.traced {
+			b.traceResponse(req, promise, len(packet.buf), res, err)
+		}
+		if pooled {
+			if err != nil {
+				packet.release()
+			} else {
+				fetchResponse.buffer = packet
+			}
+		}
+		if err != nil {
+			return err
+		}
+		b.handleThrottle(req, res)
+		return nil
 	case err = <-promise.errors:
+		if promise.traced {
+			b.traceResponse(req, promise, 0, res, err)
+		}
 		return err
+	case <-ctx.Done():
+		release = false
+		go withRecover(func() {
+			select {
+			case packet := <-promise.packets:
+				if pooled {
+					packet.release()
+				}
+			case <-promise.errors:
+			}
+			if gate != nil {
+				gate.release(req.key())
+			}
+		})
+		return ctx.Err()
+	}
+}
+
+// requestGate limits the requests in flight on a connection to
+// Net.MaxOpenRequests, and those of the API keys of Net.MaxOpenRequestsByKey
+// to their own limit. With Net.PrioritizeRequests, the requests other than
+// produce and fetch ones are let through first when waiting for a slot.
+type requestGate struct {
+	max        int
+	maxByKey   map[int16]int
+	prioritize bool
+
+	lock      sync.Mutex
+	cond      *sync.Cond
+	open      int
+	openByKey map[int16]int
+	// prioritized is the number of prioritized requests waiting for a slot
+	// only, the others waiting until it is 0
+	prioritized int
+}
+
+// newRequestGate returns the requestGate of a connection opened with conf, or
+// nil if MaxOpenRequests is only enforced by the length of Broker.responses
+func newRequestGate(conf *Config) *requestGate {
+	if len(conf.Net.MaxOpenRequestsByKey) == 0 && !conf.Net.PrioritizeRequests {
+		return nil
+	}
+	g := &requestGate{
+		max:        conf.Net.MaxOpenRequests,
+		maxByKey:   conf.Net.MaxOpenRequestsByKey,
+		prioritize: conf.Net.PrioritizeRequests,
+		openByKey:  make(map[int16]int),
+	}
+	g.cond = sync.NewCond(&g.lock)
+	return g
+}
+
+// acquire waits for a slot to send a request with the given API key
+func (g *requestGate) acquire(apiKey int16) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	prioritized := g.prioritize && apiKey != 0 && apiKey != 1 // Produce, Fetch
+	counted := false
+	for {
+		keyAvailable := true
+		if max, ok := g.maxByKey[apiKey]; ok && g.openByKey[apiKey] >= max {
+			keyAvailable = false
+		}
+		if keyAvailable && g.open < g.max && (prioritized || g.prioritized == 0) {
+			break
+		}
+		if prioritized && keyAvailable != counted {
+			// only the requests a slot would let through hold up the others
+			counted = keyAvailable
+			if counted {
+				g.prioritized++
+			} else {
+				g.prioritized--
+				g.cond.Broadcast()
+			}
+		}
+		g.cond.Wait()
+	}
+	if counted {
+		g.prioritized--
+		g.cond.Broadcast()
+	}
+	g.open++
+	g.openByKey[apiKey]++
+}
+
+// release frees the slot of a request with the given API key once its
+// response was received or it failed
+func (g *requestGate) release(apiKey int16) {
+	g.lock.Lock()
+	g.open--
+	g.openByKey[apiKey]--
+	g.lock.Unlock()
+	g.cond.Broadcast()
+}
+
+// throttleSupport is implemented by the responses carrying the time the
+// request was throttled for because of a quota violation
+type throttleSupport interface {
+	throttleTime() time.Duration
+}
+
+// clientThrottleVersions are the first versions of the responses for which
+// the broker expects the client to wait for the throttle time before sending
+// other requests, see KIP-219. Older versions are delayed by the broker
+// itself. The APIs added after KIP-219 are not listed as all their versions
+// are throttled by the client.
+var clientThrottleVersions = map[int16]int16{
+	0:  6, // Produce
+	1:  8, // Fetch
+	2:  3, // ListOffsets
+	3:  6, // Metadata
+	8:  4, // OffsetCommit
+	9:  4, // OffsetFetch
+	10: 2, // FindCoordinator
+	11: 3, // JoinGroup
+	13: 2, // LeaveGroup
+	16: 2, // ListGroups
+	18: 2, // ApiVersions
+	19: 3, // CreateTopics
+	20: 2, // DeleteTopics
+	21: 1, // DeleteRecords
+	22: 1, // InitProducerId
+	24: 1, // AddPartitionsToTxn
+	25: 1, // AddOffsetsToTxn
+	26: 1, // EndTxn
+	28: 1, // TxnOffsetCommit
+	29: 1, // DescribeAcls
+	30: 1, // CreateAcls
+	31: 1, // DeleteAcls
+	32: 2, // DescribeConfigs
+	33: 1, // AlterConfigs
+	35: 1, // DescribeLogDirs
+	37: 1, // CreatePartitions
+	38: 1, // CreateDelegationToken
+	39: 1, // RenewDelegationToken
+	40: 1, // ExpireDelegationToken
+	41: 1, // DescribeDelegationToken
+	42: 1, // DeleteGroups
+}
+
+// handleThrottle records the throttle time of the response and delays the
+// next requests on this connection if the broker expects us to
+func (b *Broker) handleThrottle(req, res protocolBody) {
+	throttled, ok := res.(throttleSupport)
+	if !ok {
+		return
+	}
+	throttleTime := throttled.throttleTime()
+	if throttleTime <= 0 {
+		return
+	}
+
+	logEntry(LogComponentNetwork, LogLevelDebug, fmt.Sprintf("broker %T throttled", res), brokerField(b.ID()), LogField{Key: "throttle", Value: throttleTime})
+	throttleTimeInMs := int64(throttleTime / time.Millisecond)
+	if b.throttleTime != nil {
+		b.throttleTime.Update(throttleTimeInMs)
+	}
+	if b.brokerThrottleTime != nil {
+		b.brokerThrottleTime.Update(throttleTimeInMs)
+	}
+
+	if req.version() < clientThrottleVersions[req.key()] {
+		return
+	}
+	b.lock.Lock()
+	if until := time.Now().Add(throttleTime); until.After(b.throttledUntil) {
+		b.throttledUntil = until
+	}
+	b.lock.Unlock()
+}
+
+// waitThrottle waits until the throttle time requested by the broker for
+// this connection is over
+func (b *Broker) waitThrottle() {
+	b.lock.Lock()
+	wait := time.Until(b.throttledUntil)
+	b.lock.Unlock()
+
+	if wait > 0 {
+		logEntry(LogComponentNetwork, LogLevelDebug, "broker waiting before sending requests as throttled", brokerField(b.ID()), LogField{Key: "throttle", Value: wait})
+		time.Sleep(wait)
 	}
 }
 
@@ -862,7 +1545,12 @@ func (b *Broker) decode(pd packetDecoder, version int16) (err error) {
 		return err
 	}
 
-	host, err := pd.getString()
+	var host string
+	if version >= 9 {
+		host, err = pd.getCompactString()
+	} else {
+		host, err = pd.getString()
+	}
 	if err != nil {
 		return err
 	}
@@ -872,7 +1560,12 @@ func (b *Broker) decode(pd packetDecoder, version int16) (err error) {
 		return err
 	}
 
-	if version >= 1 {
+	if version >= 9 {
+		b.rack, err = pd.getCompactNullableString()
+		if err != nil {
+			return err
+		}
+	} else if version >= 1 {
 		b.rack, err = pd.getNullableString()
 		if err != nil {
 			return err
@@ -884,6 +1577,12 @@ func (b *Broker) decode(pd packetDecoder, version int16) (err error) {
 		return err
 	}
 
+	if version >= 9 {
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -900,25 +1599,40 @@ func (b *Broker) encode(pe packetEncoder, version int16) (err error) {
 
 	pe.putInt32(b.id)
 
-	err = pe.putString(host)
+	if version >= 9 {
+		err = pe.putCompactString(host)
+	} else {
+		err = pe.putString(host)
+	}
 	if err != nil {
 		return err
 	}
 
 	pe.putInt32(int32(port))
 
-	if version >= 1 {
+	if version >= 9 {
+		err = pe.putNullableCompactString(b.rack)
+		if err != nil {
+			return err
+		}
+	} else if version >= 1 {
 		err = pe.putNullableString(b.rack)
 		if err != nil {
 			return err
 		}
 	}
 
+	if version >= 9 {
+		pe.putEmptyTaggedFieldArray()
+	}
+
 	return nil
 }
 
 func (b *Broker) responseReceiver() {
 	var dead error
+	// the header is decoded before the next one is read
+	var headerBuf [9]byte
 
 	for response := range b.responses {
 		if dead != nil {
@@ -930,14 +1644,14 @@ func (b *Broker) responseReceiver() {
 		}
 
 		headerLength := getHeaderLength(response.headerVersion)
-		header := make([]byte, headerLength)
+		header := headerBuf[:headerLength]
 
 		bytesReadHeader, err := b.readFull(header)
 		requestLatency := time.Since(response.requestTime)
+		response.latency.update(requestLatency)
 		if err != nil {
 			b.updateIncomingCommunicationMetrics(bytesReadHeader, requestLatency)
-			dead = err
-			response.errors <- err
+			dead = b.failResponse(response, err)
 			continue
 		}
 
@@ -945,33 +1659,46 @@ func (b *Broker) responseReceiver() {
 		err = versionedDecode(header, &decodedHeader, response.headerVersion)
 		if err != nil {
 			b.updateIncomingCommunicationMetrics(bytesReadHeader, requestLatency)
-			dead = err
-			response.errors <- err
+			dead = b.failResponse(response, err)
 			continue
 		}
 		if decodedHeader.correlationID != response.correlationID {
 			b.updateIncomingCommunicationMetrics(bytesReadHeader, requestLatency)
 			// TODO if decoded ID < cur ID, discard until we catch up
 			// TODO if decoded ID > cur ID, save it so when cur ID catches up we have a response
-			dead = PacketDecodingError{fmt.Sprintf("correlation ID didn't match, wanted %d, got %d", response.correlationID, decodedHeader.correlationID)}
-			response.errors <- dead
+			dead = b.failResponse(response, PacketDecodingError{fmt.Sprintf("correlation ID didn't match, wanted %d, got %d", response.correlationID, decodedHeader.correlationID)})
 			continue
 		}
 
-		buf := make([]byte, decodedHeader.length-int32(headerLength)+4)
-		bytesReadBody, err := b.readFull(buf)
+		size := int(decodedHeader.length - int32(headerLength) + 4)
+		var packet *pooledBuffer
+		if response.pooled {
+			packet = fetchBuffers.get(size)
+		} else {
+			packet = &pooledBuffer{buf: make([]byte, size), refs: 1}
+		}
+		bytesReadBody, err := b.readFull(packet.buf)
 		b.updateIncomingCommunicationMetrics(bytesReadHeader+bytesReadBody, requestLatency)
 		if err != nil {
-			dead = err
-			response.errors <- err
+			packet.release()
+			dead = b.failResponse(response, err)
 			continue
 		}
 
-		response.packets <- buf
+		response.packets <- packet
 	}
 	close(b.done)
 }
 
+// failResponse fails the response with err, which the responseReceiver then
+// fails the following responses with
+func (b *Broker) failResponse(response responsePromise, err error) error {
+	logEntry(LogComponentNetwork, LogLevelWarn, "broker failed to read the response", brokerField(b.id), addrField(b.addr),
+		LogField{Key: LogKeyCorrelationID, Value: response.correlationID}, errorField(err))
+	response.errors <- err
+	return err
+}
+
 func getHeaderLength(headerVersion int16) int8 {
 	if headerVersion < 1 {
 		return 8
@@ -982,6 +1709,7 @@ func getHeaderLength(headerVersion int16) int8 {
 }
 
 func (b *Broker) authenticateViaSASL() error {
+	b.reauthenticateAt = time.Time{}
 	switch b.conf.Net.SASL.Mechanism {
 	case SASLTypeOAuth:
 		return b.sendAndReceiveSASLOAuth(b.conf.Net.SASL.TokenProvider)
@@ -989,7 +1717,12 @@ func (b *Broker) authenticateViaSASL() error {
 		return b.sendAndReceiveSASLSCRAM()
 	case SASLTypeGSSAPI:
 		return b.sendAndReceiveKerberos()
+	case SASLTypeAWSMSKIAM:
+		return b.sendAndReceiveSASLAWSMSKIAM()
 	default:
+		if factory, ok := registeredSASLMechanism(b.conf.Net.SASL.Mechanism); ok {
+			return b.sendAndReceiveSASLMechanism(factory)
+		}
 		return b.sendAndReceiveSASLPlainAuth()
 	}
 }
@@ -1002,11 +1735,58 @@ func (b *Broker) sendAndReceiveKerberos() error {
 	return b.kerberosAuthenticator.Authorize(b)
 }
 
+// sendAndReceiveClientSoftware sends an ApiVersions request identifying the
+// client software on the new connection, as the JVM client does (KIP-511).
+// The response is read directly as the responseReceiver is not started yet.
+// Like the TLS handshake, the exchange is not counted in the request metrics.
+func (b *Broker) sendAndReceiveClientSoftware(name, version string) error {
+	rb := &ApiVersionsRequest{
+		Version:               3,
+		ClientSoftwareName:    name,
+		ClientSoftwareVersion: version,
+	}
+	req := &request{correlationID: b.correlationID, clientID: b.conf.ClientID, body: rb}
+	buf, err := encode(req, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := b.write(buf); err != nil {
+		return err
+	}
+	b.correlationID++
+
+	// the header of the ApiVersions responses is always v0
+	header := make([]byte, 8)
+	if _, err := b.readFull(header); err != nil {
+		return err
+	}
+	decodedHeader := responseHeader{}
+	if err := versionedDecode(header, &decodedHeader, 0); err != nil {
+		return err
+	}
+	if decodedHeader.correlationID != req.correlationID {
+		return PacketDecodingError{fmt.Sprintf("correlation ID didn't match, wanted %d, got %d", req.correlationID, decodedHeader.correlationID)}
+	}
+	payload := make([]byte, decodedHeader.length-4)
+	if _, err := b.readFull(payload); err != nil {
+		return err
+	}
+
+	res := &ApiVersionsResponse{Version: rb.Version}
+	if err := versionedDecode(payload, res, rb.Version); err != nil {
+		return err
+	}
+	if res.Err != ErrNoError {
+		Logger.Printf("broker %s did not accept the client software %s %s: %s\n", b.addr, rb.ClientSoftwareName, rb.ClientSoftwareVersion, res.Err)
+	}
+	return nil
+}
+
 func (b *Broker) sendAndReceiveSASLHandshake(saslType SASLMechanism, version int16) error {
 	rb := &SaslHandshakeRequest{Mechanism: string(saslType), Version: version}
 
 	req := &request{correlationID: b.correlationID, clientID: b.conf.ClientID, body: rb}
-	buf, err := encode(req, b.conf.MetricRegistry)
+	buf, err := encode(req, b.conf.metricsRecorder())
 	if err != nil {
 		return err
 	}
@@ -1237,7 +2017,7 @@ func (b *Broker) sendAndReceiveSASLSCRAMv0() error {
 		return err
 	}
 
-	scramClient := b.conf.Net.SASL.SCRAMClientGeneratorFunc()
+	scramClient := b.newSCRAMClient()
 	if err := scramClient.Begin(b.conf.Net.SASL.User, b.conf.Net.SASL.Password, b.conf.Net.SASL.SCRAMAuthzID); err != nil {
 		return fmt.Errorf("failed to start SCRAM exchange with the server: %s", err.Error())
 	}
@@ -1294,7 +2074,7 @@ func (b *Broker) sendAndReceiveSASLSCRAMv1() error {
 		return err
 	}
 
-	scramClient := b.conf.Net.SASL.SCRAMClientGeneratorFunc()
+	scramClient := b.newSCRAMClient()
 	if err := scramClient.Begin(b.conf.Net.SASL.User, b.conf.Net.SASL.Password, b.conf.Net.SASL.SCRAMAuthzID); err != nil {
 		return fmt.Errorf("failed to start SCRAM exchange with the server: %s", err.Error())
 	}
@@ -1337,10 +2117,127 @@ func (b *Broker) sendAndReceiveSASLSCRAMv1() error {
 	return nil
 }
 
+// sendAndReceiveSASLAWSMSKIAM authenticates with the AWS IAM credentials of
+// Net.SASL.AWSMSKIAM, sending a payload signed with Signature Version 4
+func (b *Broker) sendAndReceiveSASLAWSMSKIAM() error {
+	if err := b.sendAndReceiveSASLHandshake(SASLTypeAWSMSKIAM, SASLHandshakeV1); err != nil {
+		return err
+	}
+
+	provider := b.conf.Net.SASL.AWSMSKIAM.CredentialsProvider
+	if provider == nil {
+		provider = defaultAWSCredentials()
+	}
+	credentials, err := provider.Credentials()
+	if err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(b.addr)
+	if err != nil {
+		return err
+	}
+	payload, err := awsMSKIAMPayload(credentials, b.conf.Net.SASL.AWSMSKIAM.region(), host, time.Now())
+	if err != nil {
+		return err
+	}
+
+	requestTime := time.Now()
+	// Will be decremented in updateIncomingCommunicationMetrics (except error)
+	b.addRequestInFlightMetrics(1)
+	correlationID := b.correlationID
+	bytesWritten, err := b.sendSaslAuthenticateRequest(correlationID, payload)
+	b.updateOutgoingCommunicationMetrics(bytesWritten)
+	if err != nil {
+		b.addRequestInFlightMetrics(-1)
+		Logger.Printf("Failed to write SASL auth header to broker %s: %s\n", b.addr, err.Error())
+		return err
+	}
+
+	b.correlationID++
+	response, err := b.receiveSaslAuthenticateResponse(correlationID)
+	if err != nil {
+		b.addRequestInFlightMetrics(-1)
+		Logger.Printf("Error returned from broker during SASL flow %s: %s\n", b.addr, err.Error())
+		return err
+	}
+	b.updateIncomingCommunicationMetrics(len(response), time.Since(requestTime))
+
+	var res struct {
+		RequestID string `json:"request-id"`
+	}
+	if err := json.Unmarshal(response, &res); err != nil {
+		return fmt.Errorf("failed to decode the AWS_MSK_IAM response: %w", err)
+	}
+	DebugLogger.Printf("SASL authentication succeeded with broker %s, request id %s\n", b.addr, res.RequestID)
+	return nil
+}
+
+// sendAndReceiveSASLMechanism performs the exchange of a mechanism registered
+// with RegisterSASLMechanism, wrapping its messages in SaslAuthenticate
+// requests
+func (b *Broker) sendAndReceiveSASLMechanism(factory SASLMechanismFactory) error {
+	if err := b.sendAndReceiveSASLHandshake(b.conf.Net.SASL.Mechanism, SASLHandshakeV1); err != nil {
+		return err
+	}
+
+	client, err := factory(b.conf, b.addr)
+	if err != nil {
+		return err
+	}
+	msg, err := client.Step(nil)
+	if err != nil {
+		return fmt.Errorf("failed to start the %s exchange: %w", b.conf.Net.SASL.Mechanism, err)
+	}
+
+	for !client.Done() {
+		requestTime := time.Now()
+		// Will be decremented in updateIncomingCommunicationMetrics (except error)
+		b.addRequestInFlightMetrics(1)
+		correlationID := b.correlationID
+		bytesWritten, err := b.sendSaslAuthenticateRequest(correlationID, msg)
+		b.updateOutgoingCommunicationMetrics(bytesWritten)
+		if err != nil {
+			b.addRequestInFlightMetrics(-1)
+			Logger.Printf("Failed to write SASL auth header to broker %s: %s\n", b.addr, err.Error())
+			return err
+		}
+
+		b.correlationID++
+		challenge, err := b.receiveSaslAuthenticateResponse(correlationID)
+		if err != nil {
+			b.addRequestInFlightMetrics(-1)
+			Logger.Printf("Failed to read response while authenticating with SASL to broker %s: %s\n", b.addr, err.Error())
+			return err
+		}
+
+		b.updateIncomingCommunicationMetrics(len(challenge), time.Since(requestTime))
+		msg, err = client.Step(challenge)
+		if err != nil {
+			Logger.Println("SASL authentication failed", err)
+			return err
+		}
+	}
+
+	DebugLogger.Println("SASL authentication succeeded")
+	return nil
+}
+
+// newSCRAMClient returns the SCRAM client of Net.SASL.SCRAMClientGeneratorFunc,
+// or the built-in one for the configured mechanism
+func (b *Broker) newSCRAMClient() SCRAMClient {
+	if b.conf.Net.SASL.SCRAMClientGeneratorFunc != nil {
+		return b.conf.Net.SASL.SCRAMClientGeneratorFunc()
+	}
+	if b.conf.Net.SASL.Mechanism == SASLTypeSCRAMSHA512 {
+		return NewSCRAMClient(SCRAM_MECHANISM_SHA_512)
+	}
+	return NewSCRAMClient(SCRAM_MECHANISM_SHA_256)
+}
+
 func (b *Broker) sendSaslAuthenticateRequest(correlationID int32, msg []byte) (int, error) {
-	rb := &SaslAuthenticateRequest{msg}
+	rb := &SaslAuthenticateRequest{Version: b.saslAuthenticateVersion(), SaslAuthBytes: msg}
 	req := &request{correlationID: correlationID, clientID: b.conf.ClientID, body: rb}
-	buf, err := encode(req, b.conf.MetricRegistry)
+	buf, err := encode(req, b.conf.metricsRecorder())
 	if err != nil {
 		return 0, err
 	}
@@ -1372,12 +2269,13 @@ func (b *Broker) receiveSaslAuthenticateResponse(correlationID int32) ([]byte, e
 	}
 
 	res := &SaslAuthenticateResponse{}
-	if err := versionedDecode(buf, res, 0); err != nil {
+	if err := versionedDecode(buf, res, b.saslAuthenticateVersion()); err != nil {
 		return nil, err
 	}
 	if res.Err != ErrNoError {
 		return nil, res.Err
 	}
+	b.scheduleReauthentication(res.SessionLifetimeMs)
 	return res.SaslAuthBytes, nil
 }
 
@@ -1414,9 +2312,9 @@ func mapToString(extensions map[string]string, keyValSep string, elemSep string)
 
 func (b *Broker) sendSASLPlainAuthClientResponse(correlationID int32) (int, error) {
 	authBytes := []byte(b.conf.Net.SASL.AuthIdentity + "\x00" + b.conf.Net.SASL.User + "\x00" + b.conf.Net.SASL.Password)
-	rb := &SaslAuthenticateRequest{authBytes}
+	rb := &SaslAuthenticateRequest{Version: b.saslAuthenticateVersion(), SaslAuthBytes: authBytes}
 	req := &request{correlationID: correlationID, clientID: b.conf.ClientID, body: rb}
-	buf, err := encode(req, b.conf.MetricRegistry)
+	buf, err := encode(req, b.conf.metricsRecorder())
 	if err != nil {
 		return 0, err
 	}
@@ -1425,11 +2323,11 @@ func (b *Broker) sendSASLPlainAuthClientResponse(correlationID int32) (int, erro
 }
 
 func (b *Broker) sendSASLOAuthBearerClientMessage(initialResp []byte, correlationID int32) (int, error) {
-	rb := &SaslAuthenticateRequest{initialResp}
+	rb := &SaslAuthenticateRequest{Version: b.saslAuthenticateVersion(), SaslAuthBytes: initialResp}
 
 	req := &request{correlationID: correlationID, clientID: b.conf.ClientID, body: rb}
 
-	buf, err := encode(req, b.conf.MetricRegistry)
+	buf, err := encode(req, b.conf.metricsRecorder())
 	if err != nil {
 		return 0, err
 	}
@@ -1461,7 +2359,7 @@ func (b *Broker) receiveSASLServerResponse(res *SaslAuthenticateResponse, correl
 		return bytesRead, err
 	}
 
-	if err := versionedDecode(buf, res, 0); err != nil {
+	if err := versionedDecode(buf, res, b.saslAuthenticateVersion()); err != nil {
 		return bytesRead, err
 	}
 
@@ -1469,9 +2367,76 @@ func (b *Broker) receiveSASLServerResponse(res *SaslAuthenticateResponse, correl
 		return bytesRead, res.Err
 	}
 
+	b.scheduleReauthentication(res.SessionLifetimeMs)
 	return bytesRead, nil
 }
 
+// saslAuthenticateVersion returns the version of the SaslAuthenticate
+// requests, v1 returning the session lifetime being supported from 2.2
+func (b *Broker) saslAuthenticateVersion() int16 {
+	if b.conf.Version.IsAtLeast(V2_2_0_0) {
+		return 1
+	}
+	return 0
+}
+
+// scheduleReauthentication schedules the re-authentication of the session
+// before its lifetime is over, at a random point between 85% and 95% of it
+// like the JVM client so that the connections do not all re-authenticate at
+// once. The lock must be held.
+func (b *Broker) scheduleReauthentication(sessionLifetimeMs int64) {
+	if sessionLifetimeMs <= 0 {
+		return
+	}
+	lifetime := time.Duration(sessionLifetimeMs) * time.Millisecond
+	b.reauthenticateAt = time.Now().Add(time.Duration(float64(lifetime) * (0.85 + 0.1*rand.Float64())))
+	logEntry(LogComponentNetwork, LogLevelDebug, "broker SASL session expires", brokerField(b.ID()), addrField(b.addr), LogField{Key: "lifetime", Value: lifetime})
+}
+
+// reauthenticateIfExpiring re-authenticates the SASL session over the
+// existing connection once it is about to expire. The in-flight requests are
+// completed first, as the SASL exchange reads from the connection directly;
+// the connection is closed if the re-authentication fails.
+func (b *Broker) reauthenticateIfExpiring() {
+	// the events are sent once the lock is released
+	var events []Event
+	var conf *Config
+	defer func() {
+		for _, event := range events {
+			conf.notify(event)
+		}
+	}()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	conf = b.conf
+
+	if b.conn == nil || b.reauthenticateAt.IsZero() || time.Now().Before(b.reauthenticateAt) {
+		return
+	}
+
+	close(b.responses)
+	<-b.done
+
+	logEntry(LogComponentNetwork, LogLevelDebug, "broker re-authenticating the SASL session", brokerField(b.ID()), addrField(b.addr))
+	if err := b.authenticateViaSASL(); err != nil {
+		logEntry(LogComponentNetwork, LogLevelWarn, "broker failed to re-authenticate, closing the connection", brokerField(b.ID()), addrField(b.addr), errorField(err))
+		events = append(events, b.event(EventBrokerAuthFailed, err), b.event(EventBrokerDisconnected, nil))
+		_ = b.conn.Close()
+		b.conn = nil
+		b.connErr = err
+		b.done = nil
+		b.responses = nil
+		b.unregisterMetrics()
+		atomic.StoreInt32(&b.opened, 0)
+		return
+	}
+
+	b.done = make(chan bool)
+	b.responses = make(chan responsePromise, b.conf.Net.MaxOpenRequests-1)
+	go withRecover(b.responseReceiver)
+}
+
 func (b *Broker) updateIncomingCommunicationMetrics(bytes int, requestLatency time.Duration) {
 	b.updateRequestLatencyAndInFlightMetrics(requestLatency)
 	b.responseRate.Mark(1)
@@ -1541,28 +2506,158 @@ func (b *Broker) registerMetrics() {
 }
 
 func (b *Broker) unregisterMetrics() {
+	if len(b.registeredMetrics) == 0 {
+		return
+	}
+	recorder := b.conf.metricsRecorder()
 	for _, name := range b.registeredMetrics {
-		b.conf.MetricRegistry.Unregister(name)
+		recorder.Unregister(name, brokerLabel(b.id))
+	}
+	for key, latency := range b.apiLatencies {
+		if latency.broker != nil {
+			recorder.Unregister("request-latency-in-ms", brokerLabel(b.id), apiLabel(key))
+		}
 	}
 	b.registeredMetrics = nil
+	b.apiLatencies = nil
+}
+
+// apiLatency are the request-latency-in-ms histograms of the requests of an
+// API key, for all the brokers and for a given broker
+type apiLatency struct {
+	all    MetricHistogram
+	broker MetricHistogram
+}
+
+// apiLatency returns the latency histograms of the requests of key, b.lock
+// being held, the histogram of b being nil when b has no metrics of its own
+func (b *Broker) apiLatency(key int16) *apiLatency {
+	if latency, ok := b.apiLatencies[key]; ok {
+		return latency
+	}
+	recorder := b.conf.metricsRecorder()
+	if recorder == nil {
+		return nil
+	}
+	latency := &apiLatency{all: recorder.Histogram("request-latency-in-ms", apiLabel(key))}
+	if b.brokerRequestLatency != nil {
+		latency.broker = recorder.Histogram("request-latency-in-ms", brokerLabel(b.id), apiLabel(key))
+	}
+	if b.apiLatencies == nil {
+		b.apiLatencies = make(map[int16]*apiLatency)
+	}
+	b.apiLatencies[key] = latency
+	return latency
+}
+
+func (l *apiLatency) update(latency time.Duration) {
+	if l == nil {
+		return
+	}
+	latencyInMs := int64(latency / time.Millisecond)
+	l.all.Update(latencyInMs)
+	if l.broker != nil {
+		l.broker.Update(latencyInMs)
+	}
+}
+
+func (b *Broker) registerMeter(name string) MetricMeter {
+	b.registeredMetrics = append(b.registeredMetrics, name)
+	return b.conf.metricsRecorder().Meter(name, brokerLabel(b.id))
+}
+
+func (b *Broker) registerHistogram(name string) MetricHistogram {
+	b.registeredMetrics = append(b.registeredMetrics, name)
+	return b.conf.metricsRecorder().Histogram(name, brokerLabel(b.id))
 }
 
-func (b *Broker) registerMeter(name string) metrics.Meter {
-	nameForBroker := getMetricNameForBroker(name, b)
-	b.registeredMetrics = append(b.registeredMetrics, nameForBroker)
-	return metrics.GetOrRegisterMeter(nameForBroker, b.conf.MetricRegistry)
+func (b *Broker) registerCounter(name string) MetricCounter {
+	b.registeredMetrics = append(b.registeredMetrics, name)
+	return b.conf.metricsRecorder().Counter(name, brokerLabel(b.id))
 }
 
-func (b *Broker) registerHistogram(name string) metrics.Histogram {
-	nameForBroker := getMetricNameForBroker(name, b)
-	b.registeredMetrics = append(b.registeredMetrics, nameForBroker)
-	return getOrRegisterHistogram(nameForBroker, b.conf.MetricRegistry)
+// event returns the event of b of type t, failed with err if not nil
+func (b *Broker) event(t EventType, err error) Event {
+	return Event{Type: t, BrokerID: b.id, Addr: b.addr, Err: err}
+}
+
+// tlsConfig returns the TLS configuration to connect to the broker
+func (b *Broker) tlsConfig(conf *Config) *tls.Config {
+	cfg := validServerNameTLS(b.addr, conf.Net.TLS.Config)
+	if serverName := conf.Net.TLS.ServerName; serverName != nil {
+		if name := serverName(b.addr); name != "" {
+			cfg = cfg.Clone()
+			cfg.ServerName = name
+		}
+	}
+	if verify := conf.Net.TLS.VerifyPeerCertificate; verify != nil {
+		cfg = cfg.Clone()
+		// the verification is replaced, not added to the default one
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			certificates := make([]*x509.Certificate, len(rawCerts))
+			for i, raw := range rawCerts {
+				certificate, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return err
+				}
+				certificates[i] = certificate
+			}
+			return verify(b.addr, certificates)
+		}
+	}
+	if provider := conf.Net.TLS.CertificateProvider; provider != nil {
+		cfg = b.withCertificateProvider(cfg, provider)
+	}
+	if conf.Net.FIPS {
+		cfg = fipsTLSConfig(cfg)
+	}
+	return cfg
 }
 
-func (b *Broker) registerCounter(name string) metrics.Counter {
-	nameForBroker := getMetricNameForBroker(name, b)
-	b.registeredMetrics = append(b.registeredMetrics, nameForBroker)
-	return metrics.GetOrRegisterCounter(nameForBroker, b.conf.MetricRegistry)
+// withCertificateProvider returns a copy of cfg getting the client
+// certificate from the provider, and remembering it for the connection
+func (b *Broker) withCertificateProvider(cfg *tls.Config, provider CertificateProvider) *tls.Config {
+	c := cfg.Clone()
+	c.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		certificate, err := provider.Certificate()
+		if err != nil {
+			return nil, err
+		}
+		b.certificate.Store(certificate)
+		return certificate, nil
+	}
+	return c
+}
+
+// recycleRotatedCertificate closes and opens the connection again if the
+// client certificate it authenticated with was rotated. Close waits for the
+// in-flight requests to be answered.
+func (b *Broker) recycleRotatedCertificate() {
+	b.lock.Lock()
+	conf, connected := b.conf, b.conn != nil
+	b.lock.Unlock()
+	if !connected || conf == nil || !conf.Net.TLS.Enable || conf.Net.TLS.CertificateProvider == nil {
+		return
+	}
+
+	b.recycleLock.Lock()
+	defer b.recycleLock.Unlock()
+
+	// the certificate is only known once the handshake is done
+	used, _ := b.certificate.Load().(*tls.Certificate)
+	if used == nil {
+		return
+	}
+	current, err := conf.Net.TLS.CertificateProvider.Certificate()
+	if err != nil || current == used {
+		return
+	}
+
+	logEntry(LogComponentNetwork, LogLevelInfo, "broker reconnecting with the rotated client certificate", brokerField(b.ID()), addrField(b.addr))
+	b.certificate.Store((*tls.Certificate)(nil))
+	_ = b.Close()
+	_ = b.Open(conf)
 }
 
 func validServerNameTLS(addr string, cfg *tls.Config) *tls.Config {
@@ -0,0 +1,106 @@
+package sarama
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ConnectionState is the state of the connection to a broker, see
+// Broker.State.
+type ConnectionState int8
+
+const (
+	// ConnectionDisconnected is the state of the brokers never opened or
+	// closed since.
+	ConnectionDisconnected ConnectionState = iota
+	// ConnectionConnecting is the state of the brokers being dialed and
+	// authenticated.
+	ConnectionConnecting
+	// ConnectionConnected is the state of the brokers requests can be sent to.
+	ConnectionConnected
+	// ConnectionFailed is the state of the brokers whose last connection
+	// attempt failed, until they are opened again.
+	ConnectionFailed
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case ConnectionDisconnected:
+		return "Disconnected"
+	case ConnectionConnecting:
+		return "Connecting"
+	case ConnectionConnected:
+		return "Connected"
+	case ConnectionFailed:
+		return "Failed"
+	}
+	return "Unknown"
+}
+
+// BrokerState is a snapshot of the state of a Broker, returned by
+// Broker.State for monitoring and request routing.
+type BrokerState struct {
+	Connection ConnectionState
+	// InFlight is the number of requests sent to the broker which are waiting
+	// for their response.
+	InFlight int
+	// Circuit is the state of the circuit of the broker when the broker
+	// belongs to a client with Net.CircuitBreaker enabled, and CircuitClosed
+	// otherwise.
+	Circuit CircuitState
+	// LastError is the error of the last failed connection attempt or request,
+	// at LastErrorTime, nil if there was none.
+	LastError     error
+	LastErrorTime time.Time
+}
+
+// brokerError is the error stored in Broker.lastError
+type brokerError struct {
+	err error
+	at  time.Time
+}
+
+// State returns the current state of the broker. It never blocks, even while
+// the broker is connecting.
+func (b *Broker) State() BrokerState {
+	state := BrokerState{
+		Connection: ConnectionState(atomic.LoadInt32(&b.connectionState)),
+		InFlight:   int(atomic.LoadInt32(&b.inFlight)),
+	}
+	if b.breaker != nil {
+		state.Circuit = b.breaker.state(b.addr)
+	}
+	if last, ok := b.lastError.Load().(brokerError); ok {
+		state.LastError, state.LastErrorTime = last.err, last.at
+	}
+	return state
+}
+
+// connectionFailed records the error of a connection attempt, you must hold
+// the lock
+func (b *Broker) connectionFailed(err error) {
+	b.lastError.Store(brokerError{err: err, at: time.Now()})
+	atomic.StoreInt32(&b.connectionState, int32(ConnectionFailed))
+}
+
+// lessLoaded returns whether the broker in state a should be preferred over
+// the one in state b by Client.LeastLoadedBroker: the connected brokers come
+// first, then those connecting, and among those with the same connection state
+// the ones with the fewest requests in flight
+func lessLoaded(a, b BrokerState) bool {
+	rank := func(s ConnectionState) int {
+		switch s {
+		case ConnectionConnected:
+			return 0
+		case ConnectionConnecting:
+			return 1
+		case ConnectionDisconnected:
+			return 2
+		}
+		return 3
+	}
+	if rank(a.Connection) != rank(b.Connection) {
+		return rank(a.Connection) < rank(b.Connection)
+	}
+	return a.InFlight < b.InFlight
+}
@@ -0,0 +1,82 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBrokerState(t *testing.T) {
+	mb := NewMockBroker(t, 1)
+	defer mb.Close()
+	// the metadata requests are never answered
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": mockResponseFunc(func(versionedDecoder) encoderWithHeader { return nil }),
+	})
+
+	conf := NewTestConfig()
+	conf.Net.ReadTimeout = 100 * time.Millisecond
+	broker := NewBroker(mb.Addr())
+	if state := broker.State(); state.Connection != ConnectionDisconnected || state.LastError != nil {
+		t.Fatalf("unexpected state of a new broker %+v", state)
+	}
+
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	if connected, err := broker.Connected(); !connected {
+		t.Fatal(err)
+	}
+	if state := broker.State(); state.Connection != ConnectionConnected {
+		t.Fatalf("expected the broker to be connected, got %s", state.Connection)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := broker.GetMetadata(new(MetadataRequest))
+		done <- err
+	}()
+	for broker.State().InFlight != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	err := <-done
+	if err == nil {
+		t.Fatal("expected the request to time out")
+	}
+	state := broker.State()
+	if state.InFlight != 0 || state.LastError != err || state.LastErrorTime.IsZero() {
+		t.Errorf("unexpected state after the request failed %+v", state)
+	}
+
+	safeClose(t, broker)
+	if state := broker.State(); state.Connection != ConnectionDisconnected {
+		t.Errorf("expected the broker to be disconnected, got %s", state.Connection)
+	}
+
+	dead := NewMockBroker(t, 2)
+	broker = NewBroker(dead.Addr())
+	dead.Close()
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	_, err = broker.Connected()
+	if state := broker.State(); state.Connection != ConnectionFailed || state.LastError != err || err == nil {
+		t.Errorf("expected the broker to have failed with %v, got %+v", err, state)
+	}
+}
+
+func TestLessLoaded(t *testing.T) {
+	connected := BrokerState{Connection: ConnectionConnected, InFlight: 3}
+	idle := BrokerState{Connection: ConnectionConnected}
+	connecting := BrokerState{Connection: ConnectionConnecting}
+	failed := BrokerState{Connection: ConnectionFailed}
+
+	if !lessLoaded(idle, connected) || lessLoaded(connected, idle) {
+		t.Error("expected the broker with fewer requests in flight to be preferred")
+	}
+	if !lessLoaded(connected, connecting) || !lessLoaded(connecting, BrokerState{}) {
+		t.Error("expected the connected brokers to be preferred")
+	}
+	if !lessLoaded(BrokerState{}, failed) {
+		t.Error("expected the failed brokers to come last")
+	}
+}
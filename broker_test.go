@@ -2,10 +2,14 @@ package sarama
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -78,6 +82,527 @@ func TestBrokerAccessors(t *testing.T) {
 	}
 }
 
+func TestBrokerDialFn(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+
+	var dialed []string
+	conf := NewTestConfig()
+	conf.Net.DialFn = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("expected the dial context to have the deadline of Net.DialTimeout")
+		}
+		dialed = append(dialed, network+"://"+address)
+		// route the connection to the mock broker whatever the address
+		return (&net.Dialer{}).DialContext(ctx, network, mb.Addr())
+	}
+
+	broker := NewBroker("unreachable.invalid:9092")
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	if connected, err := broker.Connected(); !connected || err != nil {
+		t.Fatalf("expected the broker to be connected through DialFn, got %v", err)
+	}
+	if !reflect.DeepEqual(dialed, []string{"tcp://unreachable.invalid:9092"}) {
+		t.Errorf("unexpected dials %v", dialed)
+	}
+}
+
+// countingConn counts the bytes read and written on a connection
+type countingConn struct {
+	net.Conn
+	read, written int64
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(&c.read, int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.written, int64(n))
+	return n, err
+}
+
+func TestBrokerOnConnect(t *testing.T) {
+	mb := NewMockBroker(t, 3)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).SetBroker(mb.Addr(), mb.BrokerID()),
+	})
+
+	var conns []*countingConn
+	var brokerIDs []int32
+	conf := NewTestConfig()
+	conf.Net.OnConnect = func(conn net.Conn, brokerID int32) net.Conn {
+		counting := &countingConn{Conn: conn}
+		conns = append(conns, counting)
+		brokerIDs = append(brokerIDs, brokerID)
+		return counting
+	}
+
+	broker := &Broker{id: mb.BrokerID(), addr: mb.Addr()}
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+	if _, err := broker.GetMetadata(new(MetadataRequest)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(brokerIDs, []int32{3}) {
+		t.Fatalf("expected the connection of broker #3 to be wrapped, got %v", brokerIDs)
+	}
+	if atomic.LoadInt64(&conns[0].written) == 0 || atomic.LoadInt64(&conns[0].read) == 0 {
+		t.Error("expected the request and response to go through the wrapped connection")
+	}
+}
+
+// serveSOCKS5 accepts a single SOCKS5 connection on ln, which must
+// authenticate as user/password if user is not empty, relays it to target
+// and sends the address requested by the client on requested
+func serveSOCKS5(t *testing.T, ln net.Listener, user, password, target string, requested chan<- string) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	fail := func(err error) {
+		t.Error(err)
+		close(requested)
+	}
+	read := func(n int) []byte {
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return nil
+		}
+		return buf
+	}
+
+	// greeting: version, number of methods, methods
+	greeting := read(2)
+	if greeting == nil || read(int(greeting[1])) == nil {
+		fail(errors.New("failed to read the SOCKS5 greeting"))
+		return
+	}
+	if user == "" {
+		_, _ = conn.Write([]byte{5, 0})
+	} else {
+		_, _ = conn.Write([]byte{5, 2})
+		// username/password sub-negotiation
+		header := read(2)
+		gotUser := string(read(int(header[1])))
+		gotPassword := string(read(int(read(1)[0])))
+		if gotUser != user || gotPassword != password {
+			_, _ = conn.Write([]byte{1, 1})
+			fail(fmt.Errorf("unexpected SOCKS5 credentials %s/%s", gotUser, gotPassword))
+			return
+		}
+		_, _ = conn.Write([]byte{1, 0})
+	}
+
+	// connect request: version, command, reserved, domain name address type
+	request := read(4)
+	if request == nil || request[3] != 3 {
+		fail(errors.New("expected a SOCKS5 connect request to a domain name"))
+		return
+	}
+	host := string(read(int(read(1)[0])))
+	port := read(2)
+	requested <- fmt.Sprintf("%s:%d", host, int(port[0])<<8|int(port[1]))
+
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer upstream.Close()
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go func() {
+		_, _ = io.Copy(upstream, conn)
+		_ = upstream.Close()
+	}()
+	_, _ = io.Copy(conn, upstream)
+}
+
+func TestBrokerSOCKS5Proxy(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t),
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	requested := make(chan string, 1)
+	go serveSOCKS5(t, ln, "bastion", "secret", mb.Addr(), requested)
+
+	conf := NewTestConfig()
+	conf.Net.Proxy.Enable = true
+	conf.Net.Proxy.Address = ln.Addr().String()
+	conf.Net.Proxy.User = "bastion"
+	conf.Net.Proxy.Password = "secret"
+
+	// the address of the broker only resolves behind the proxy
+	broker := NewBroker("kafka-1.internal:9092")
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	if connected, err := broker.Connected(); !connected || err != nil {
+		t.Fatalf("expected the broker to be connected through the proxy, got %v", err)
+	}
+	if addr := <-requested; addr != "kafka-1.internal:9092" {
+		t.Errorf("expected the proxy to be asked for kafka-1.internal:9092, got %q", addr)
+	}
+
+	if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+		t.Errorf("expected a metadata response relayed by the proxy, got %v", err)
+	}
+}
+
+func TestBrokerWithContext(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+
+	// the first metadata response is delayed
+	var requests int32
+	metadata := NewMockMetadataResponse(t)
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": mockResponseFunc(func(reqBody versionedDecoder) encoderWithHeader {
+			if atomic.AddInt32(&requests, 1) == 1 {
+				time.Sleep(300 * time.Millisecond)
+			}
+			return metadata.For(reqBody)
+		}),
+	})
+
+	// a single metadata request is let in flight, until the response of the
+	// abandoned one is read
+	config := NewTestConfig()
+	config.Net.MaxOpenRequestsByKey = map[int16]int{3: 1} // Metadata
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(config); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := broker.withContext(ctx).GetMetadata(&MetadataRequest{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("the request was abandoned after %v instead of at the deadline", elapsed)
+	}
+
+	// the connection is kept, the next request being answered once the
+	// response of the abandoned one was read off it
+	if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+		t.Error(err)
+	}
+	if connected, err := broker.Connected(); !connected {
+		t.Errorf("expected the broker to stay connected, got %v", err)
+	}
+	if broker.lastError.Load() != nil {
+		t.Errorf("expected the abandoned request not to be recorded as a broker error, got %v", broker.lastError.Load())
+	}
+
+	// no request is sent once the context is done
+	if _, err := broker.withContext(ctx).GetMetadata(&MetadataRequest{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if requests := atomic.LoadInt32(&requests); requests != 2 {
+		t.Errorf("expected 2 metadata requests, got %d", requests)
+	}
+}
+
+func TestRequestGateMaxOpenRequestsByKey(t *testing.T) {
+	conf := NewTestConfig()
+	conf.Net.MaxOpenRequestsByKey = map[int16]int{1: 1}
+	gate := newRequestGate(conf)
+
+	gate.acquire(1)
+	fetched := make(chan none)
+	go func() {
+		gate.acquire(1)
+		close(fetched)
+	}()
+
+	// the other requests are not limited by the fetch in flight
+	gate.acquire(3)
+	gate.release(3)
+	select {
+	case <-fetched:
+		t.Fatal("expected the second fetch to wait for the first one")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	gate.release(1)
+	select {
+	case <-fetched:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the second fetch was not let through")
+	}
+}
+
+func TestRequestGatePrioritizeRequests(t *testing.T) {
+	conf := NewTestConfig()
+	conf.Net.MaxOpenRequests = 1
+	conf.Net.PrioritizeRequests = true
+	gate := newRequestGate(conf)
+
+	gate.acquire(0)
+	acquired := make(chan int16, 2)
+	go func() {
+		gate.acquire(1)
+		acquired <- 1
+		gate.release(1)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	go func() {
+		gate.acquire(12)
+		acquired <- 12
+		gate.release(12)
+	}()
+	for {
+		gate.lock.Lock()
+		prioritized := gate.prioritized
+		gate.lock.Unlock()
+		if prioritized == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// the heartbeat goes before the fetch that was waiting first
+	gate.release(0)
+	if apiKey := <-acquired; apiKey != 12 {
+		t.Errorf("expected the heartbeat to be let through first, got %d", apiKey)
+	}
+	if apiKey := <-acquired; apiKey != 1 {
+		t.Errorf("expected the fetch to be let through next, got %d", apiKey)
+	}
+}
+
+func TestNewRequestGate(t *testing.T) {
+	if gate := newRequestGate(NewTestConfig()); gate != nil {
+		t.Error("expected no gate by default")
+	}
+}
+
+func TestBrokerConnectionsPerBrokerSlowDial(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t),
+		"ProduceRequest":  NewMockProduceResponse(t),
+		"FetchRequest":    NewMockFetchResponse(t, 1),
+	})
+
+	var dials int32
+	release := make(chan none)
+	conf := NewTestConfig()
+	conf.Net.ConnectionsPerBroker = 3
+	conf.Net.DialFn = func(ctx context.Context, network, address string) (net.Conn, error) {
+		// hold up the dial of the produce connection
+		if atomic.AddInt32(&dials, 1) == 2 {
+			<-release
+		}
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	}
+
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+	if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	produced := make(chan error, 1)
+	go func() {
+		_, err := broker.Produce(&ProduceRequest{RequiredAcks: WaitForLocal})
+		produced <- err
+	}()
+	for atomic.LoadInt32(&dials) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the fetch connection is opened while the produce one is still dialing
+	fetched := make(chan error, 1)
+	go func() {
+		_, err := broker.Fetch(&FetchRequest{})
+		fetched <- err
+	}()
+	select {
+	case err := <-fetched:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected the fetch not to wait for the dial of the produce connection")
+	}
+
+	close(release)
+	if err := <-produced; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBrokerConnectionsPerBroker(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t),
+		"ProduceRequest":  NewMockProduceResponse(t),
+		"FetchRequest":    NewMockFetchResponse(t, 1),
+	})
+
+	var lock sync.Mutex
+	var conns []net.Conn
+	conf := NewTestConfig()
+	conf.Net.ConnectionsPerBroker = 3
+	conf.Net.DialFn = func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, address)
+		if err == nil {
+			lock.Lock()
+			conns = append(conns, conn)
+			lock.Unlock()
+		}
+		return conn, err
+	}
+	dials := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(conns)
+	}
+
+	broker := NewBroker(mb.Addr())
+	broker.id = 0
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if dials() != 1 {
+		t.Fatalf("expected metadata to use the first connection, got %d connections", dials())
+	}
+
+	produce := &ProduceRequest{RequiredAcks: WaitForLocal}
+	for i := 0; i < 2; i++ {
+		if _, err := broker.Produce(produce); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if dials() != 2 {
+		t.Fatalf("expected produces to share a second connection, got %d connections", dials())
+	}
+
+	if _, err := broker.Fetch(&FetchRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if dials() != 3 {
+		t.Fatalf("expected fetches to use a third connection, got %d connections", dials())
+	}
+
+	if err := broker.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// all the connections are closed along with the broker
+	for i, conn := range conns {
+		if _, err := conn.Write([]byte{0}); err == nil {
+			t.Errorf("expected connection %d to be closed", i)
+		}
+	}
+}
+
+func TestBrokerThrottleTime(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.Returns(&MetadataResponse{Version: 6, ThrottleTimeMs: 300})
+	mb.Returns(&MetadataResponse{Version: 6})
+	mb.Returns(&MetadataResponse{Version: 3, ThrottleTimeMs: 300})
+	mb.Returns(&MetadataResponse{Version: 3})
+
+	conf := NewTestConfig()
+	conf.Version = V2_0_0_0
+	broker := NewBroker(mb.Addr())
+	broker.id = 0
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	// from version 6 the broker expects the client to wait out the throttle time
+	if _, err := broker.GetMetadata(&MetadataRequest{Version: 6}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := broker.GetMetadata(&MetadataRequest{Version: 6}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("expected the request to be delayed by the throttle time, sent after %v", elapsed)
+	}
+
+	// older versions are delayed by the broker
+	if _, err := broker.GetMetadata(&MetadataRequest{Version: 3}); err != nil {
+		t.Fatal(err)
+	}
+	start = time.Now()
+	if _, err := broker.GetMetadata(&MetadataRequest{Version: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("expected the request not to be delayed, sent after %v", elapsed)
+	}
+
+	metricValidators := newMetricValidators()
+	metricValidators.registerForAllBrokers(broker, countHistogramValidator("throttle-time-in-ms", 2))
+	metricValidators.registerForAllBrokers(broker, minMaxHistogramValidator("throttle-time-in-ms", 300, 300))
+	metricValidators.run(t, broker.conf.MetricRegistry)
+}
+
+func TestDefaultConnectionRouter(t *testing.T) {
+	for _, tt := range []struct {
+		apiKey      int16
+		connections int
+		expected    int
+	}{
+		{0, 1, 0},
+		{1, 1, 0},
+		{3, 1, 0},
+		{0, 2, 1},
+		{1, 2, 1},
+		{3, 2, 0},
+		{0, 3, 1},
+		{1, 3, 2},
+		{3, 3, 0},
+		{1, 5, 2},
+	} {
+		if got := DefaultConnectionRouter(tt.apiKey, tt.connections); got != tt.expected {
+			t.Errorf("request %d with %d connections: expected connection %d, got %d",
+				tt.apiKey, tt.connections, tt.expected, got)
+		}
+	}
+}
+
 func TestSimpleBrokerCommunication(t *testing.T) {
 	for _, tt := range brokerTestTable {
 		t.Run(tt.name, func(t *testing.T) {
@@ -117,6 +642,37 @@ func TestSimpleBrokerCommunication(t *testing.T) {
 	}
 }
 
+func TestBrokerAPILatencyMetrics(t *testing.T) {
+	mb := NewMockBroker(t, 1)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).SetBroker(mb.Addr(), mb.BrokerID()),
+	})
+
+	broker := NewBroker(mb.Addr())
+	broker.id = mb.BrokerID()
+	conf := NewTestConfig()
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	metricValidators := newMetricValidators()
+	metricValidators.register(countHistogramValidator("request-latency-in-ms-for-api-Metadata", 1))
+	metricValidators.register(countHistogramValidator("request-latency-in-ms-for-broker-1-for-api-Metadata", 1))
+	metricValidators.run(t, conf.MetricRegistry)
+
+	safeClose(t, broker)
+	if conf.MetricRegistry.Get("request-latency-in-ms-for-broker-1-for-api-Metadata") != nil {
+		t.Error("Expected the latency histogram of the broker to be unregistered")
+	}
+	if conf.MetricRegistry.Get("request-latency-in-ms-for-api-Metadata") == nil {
+		t.Error("Expected the latency histogram of all the brokers to be kept")
+	}
+}
+
 var ErrTokenFailure = errors.New("Failure generating token")
 
 type TokenProvider struct {
@@ -524,6 +1080,254 @@ func TestSASLPlainAuth(t *testing.T) {
 	}
 }
 
+func TestBrokerClientSoftware(t *testing.T) {
+	for _, version := range []KafkaVersion{V2_3_0_0, V2_4_0_0} {
+		mb := NewMockBroker(t, 0)
+		mb.SetHandlerByMap(map[string]MockResponse{
+			"MetadataRequest": NewMockMetadataResponse(t),
+		})
+
+		conf := NewTestConfig()
+		conf.Version = version
+		conf.ClientSoftwareName = "my-app"
+		conf.ClientSoftwareVersion = "1.2.3"
+		broker := NewBroker(mb.Addr())
+		if err := broker.Open(conf); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+			t.Errorf("%s: %v", version, err)
+		}
+
+		software := mb.ClientSoftware()
+		if !version.IsAtLeast(V2_4_0_0) {
+			if len(software) != 0 {
+				t.Errorf("%s: expected no client software, got %+v", version, software)
+			}
+		} else if len(software) != 1 || software[0].ClientSoftwareName != "my-app" || software[0].ClientSoftwareVersion != "1.2.3" {
+			t.Errorf("%s: expected the client software to be sent once, got %+v", version, software)
+		}
+		if history := mb.History(); len(history) != 1 {
+			t.Errorf("%s: expected only the metadata request in the history, got %d requests", version, len(history))
+		}
+
+		safeClose(t, broker)
+		mb.Close()
+	}
+}
+
+func TestMockBrokerFaults(t *testing.T) {
+	testTable := []struct {
+		name  string
+		fault MockFault
+	}{
+		{"delay", MockFault{Request: "MetadataRequest", Skip: 1, Delay: 500 * time.Millisecond}},
+		{"truncate", MockFault{Request: "MetadataRequest", Skip: 1, TruncateAt: 6}},
+		{"reset", MockFault{Skip: 1, Reset: true}},
+	}
+
+	for _, tt := range testTable {
+		mb := NewMockBroker(t, 0)
+		mb.SetHandlerByMap(map[string]MockResponse{
+			"MetadataRequest": NewMockMetadataResponse(t),
+		})
+		mb.InjectFault(MockFault{Request: "ProduceRequest", Reset: true})
+		mb.InjectFault(tt.fault)
+
+		conf := NewTestConfig()
+		conf.Net.ReadTimeout = 100 * time.Millisecond
+		broker := NewBroker(mb.Addr())
+		if err := broker.Open(conf); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+			t.Errorf("%s: expected the first request to succeed, got %v", tt.name, err)
+		}
+		if _, err := broker.GetMetadata(&MetadataRequest{}); err == nil {
+			t.Errorf("%s: expected the faulted request to fail", tt.name)
+		}
+		safeClose(t, broker)
+
+		mb.ClearFaults()
+		broker = NewBroker(mb.Addr())
+		if err := broker.Open(conf); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+			t.Errorf("%s: expected no fault once cleared, got %v", tt.name, err)
+		}
+		safeClose(t, broker)
+		mb.Close()
+	}
+}
+
+func TestMockTransactionCoordinator(t *testing.T) {
+	txn := NewMockTransactionCoordinator(t)
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"InitProducerIDRequest":     txn,
+		"AddPartitionsToTxnRequest": txn,
+		"AddOffsetsToTxnRequest":    txn,
+		"TxnOffsetCommitRequest":    txn,
+		"EndTxnRequest":             txn,
+	})
+
+	conf := NewTestConfig()
+	conf.Version = V0_11_0_0
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	id := "txn"
+	initRes, err := broker.InitProducerID(&InitProducerIDRequest{TransactionalID: &id, TransactionTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, epoch := initRes.ProducerID, initRes.ProducerEpoch
+	if initRes.Err != ErrNoError || epoch != 0 {
+		t.Fatalf("unexpected InitProducerID response %+v", initRes)
+	}
+
+	addRes, err := broker.AddPartitionsToTxn(&AddPartitionsToTxnRequest{
+		TransactionalID: id, ProducerID: pid, ProducerEpoch: epoch,
+		TopicPartitions: map[string][]int32{"my_topic": {0, 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addRes.Errors["my_topic"]) != 2 || addRes.Errors["my_topic"][0].Err != ErrNoError {
+		t.Errorf("unexpected AddPartitionsToTxn response %+v", addRes.Errors)
+	}
+	if res, err := broker.AddOffsetsToTxn(&AddOffsetsToTxnRequest{
+		TransactionalID: id, ProducerID: pid, ProducerEpoch: epoch, GroupID: "group",
+	}); err != nil || res.Err != ErrNoError {
+		t.Fatalf("unexpected AddOffsetsToTxn result %+v, %v", res, err)
+	}
+	if _, err := broker.TxnOffsetCommit(&TxnOffsetCommitRequest{
+		TransactionalID: id, GroupID: "group", ProducerID: pid, ProducerEpoch: epoch,
+		Topics: map[string][]*PartitionOffsetMetadata{"my_topic": {{Partition: 0, Offset: 42}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	state, ok := txn.Transaction(id)
+	if !ok || state.State != "Ongoing" || len(state.Partitions["my_topic"]) != 2 || len(state.Groups) != 1 {
+		t.Errorf("unexpected ongoing transaction %+v", state)
+	}
+
+	// a new producer fences the ongoing transaction, which is aborted
+	if initRes, err = broker.InitProducerID(&InitProducerIDRequest{TransactionalID: &id}); err != nil {
+		t.Fatal(err)
+	}
+	if initRes.ProducerID != pid || initRes.ProducerEpoch != epoch+1 {
+		t.Errorf("expected the epoch to be bumped, got %+v", initRes)
+	}
+	if res, err := broker.EndTxn(&EndTxnRequest{
+		TransactionalID: id, ProducerID: pid, ProducerEpoch: epoch, TransactionResult: true,
+	}); err != nil || res.Err != ErrProducerFenced {
+		t.Errorf("expected the fenced producer to fail, got %+v, %v", res, err)
+	}
+	if offsets := txn.CommittedOffsets("group"); len(offsets) != 0 {
+		t.Errorf("expected the aborted offsets not to be committed, got %v", offsets)
+	}
+
+	epoch = initRes.ProducerEpoch
+	if res, err := broker.EndTxn(&EndTxnRequest{
+		TransactionalID: id, ProducerID: pid, ProducerEpoch: epoch, TransactionResult: true,
+	}); err != nil || res.Err != ErrInvalidTxnState {
+		t.Errorf("expected no ongoing transaction, got %+v, %v", res, err)
+	}
+	if _, err := broker.AddOffsetsToTxn(&AddOffsetsToTxnRequest{
+		TransactionalID: id, ProducerID: pid, ProducerEpoch: epoch, GroupID: "group",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := broker.TxnOffsetCommit(&TxnOffsetCommitRequest{
+		TransactionalID: id, GroupID: "group", ProducerID: pid, ProducerEpoch: epoch,
+		Topics: map[string][]*PartitionOffsetMetadata{"my_topic": {{Partition: 1, Offset: 7}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := broker.EndTxn(&EndTxnRequest{
+		TransactionalID: id, ProducerID: pid, ProducerEpoch: epoch, TransactionResult: true,
+	}); err != nil || res.Err != ErrNoError {
+		t.Fatalf("unexpected EndTxn result %+v, %v", res, err)
+	}
+
+	state, _ = txn.Transaction(id)
+	if state.State != "CompleteCommit" || state.Commits != 1 || state.Aborts != 1 {
+		t.Errorf("unexpected transaction %+v", state)
+	}
+	if offsets := txn.CommittedOffsets("group"); !reflect.DeepEqual(offsets, map[string]map[int32]int64{"my_topic": {1: 7}}) {
+		t.Errorf("unexpected committed offsets %v", offsets)
+	}
+}
+
+func TestSASLReauthentication(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"SaslHandshakeRequest": NewMockSaslHandshakeResponse(t).
+			SetEnabledMechanisms([]string{SASLTypePlaintext}),
+		"SaslAuthenticateRequest": NewMockSaslAuthenticateResponse(t).
+			SetSessionLifetimeMs(100),
+		"MetadataRequest": NewMockMetadataResponse(t),
+	})
+
+	var dials int32
+	conf := NewTestConfig()
+	conf.Version = V2_2_0_0
+	conf.Net.SASL.Enable = true
+	conf.Net.SASL.Mechanism = SASLTypePlaintext
+	conf.Net.SASL.User = "token"
+	conf.Net.SASL.Password = "password"
+	conf.Net.SASL.Version = SASLHandshakeV1
+	conf.Net.DialFn = func(ctx context.Context, network, address string) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	}
+
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	// the session is re-authenticated before its lifetime is over
+	time.Sleep(100 * time.Millisecond)
+	if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var requests []string
+	for _, rr := range mb.History() {
+		switch r := rr.Request.(type) {
+		case *SaslHandshakeRequest:
+			requests = append(requests, "handshake")
+		case *SaslAuthenticateRequest:
+			if r.Version != 1 {
+				t.Errorf("expected SaslAuthenticate v1, got v%d", r.Version)
+			}
+			requests = append(requests, "authenticate")
+		case *MetadataRequest:
+			requests = append(requests, "metadata")
+		}
+	}
+	expected := []string{"handshake", "authenticate", "metadata", "handshake", "authenticate", "metadata"}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("expected requests %v, got %v", expected, requests)
+	}
+	if atomic.LoadInt32(&dials) != 1 {
+		t.Errorf("expected the session to be re-authenticated over the connection, got %d dials", dials)
+	}
+}
+
 // TestSASLReadTimeout ensures that the broker connection won't block forever
 // if the remote end never responds after the handshake
 func TestSASLReadTimeout(t *testing.T) {
@@ -694,6 +1498,65 @@ func TestGSSAPIKerberosAuth_Authorize(t *testing.T) {
 	}
 }
 
+func TestGSSAPIKerberosAuthHandshakeV1(t *testing.T) {
+	handler := KafkaGSSAPIHandler{client: &MockKerberosClient{}}
+	token := handler.MockKafkaGSSAPI(nil)[4:]
+
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"SaslHandshakeRequest": NewMockSaslHandshakeResponse(t).
+			SetEnabledMechanisms([]string{SASLTypeGSSAPI}),
+		"SaslAuthenticateRequest": NewMockSequence(
+			NewMockSaslAuthenticateResponse(t).SetAuthBytes(token),
+			NewMockSaslAuthenticateResponse(t).SetSessionLifetimeMs(60000),
+		),
+	})
+
+	conf := NewTestConfig()
+	conf.Version = V2_2_0_0
+	conf.Net.SASL.Enable = true
+	conf.Net.SASL.Mechanism = SASLTypeGSSAPI
+	conf.Net.SASL.Version = SASLHandshakeV1
+	conf.Net.SASL.GSSAPI.ServiceName = "kafka"
+	conf.Net.SASL.GSSAPI.KerberosConfigPath = "krb5.conf"
+	conf.Net.SASL.GSSAPI.Realm = "EXAMPLE.COM"
+	conf.Net.SASL.GSSAPI.Username = "kafka"
+	conf.Net.SASL.GSSAPI.Password = "kafka"
+	conf.Net.SASL.GSSAPI.AuthType = KRB5_USER_AUTH
+
+	broker := NewBroker(mb.Addr())
+	broker.kerberosAuthenticator.NewKerberosClientFunc = func(config *GSSAPIConfig) (KerberosClient, error) {
+		return &MockKerberosClient{}, nil
+	}
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+	if connected, err := broker.Connected(); !connected || err != nil {
+		t.Fatalf("expected the broker to authenticate, got %v", err)
+	}
+
+	authenticates := 0
+	for _, rr := range mb.History() {
+		if _, ok := rr.Request.(*SaslAuthenticateRequest); ok {
+			authenticates++
+		}
+	}
+	if authenticates != 2 {
+		t.Errorf("expected the tokens to be sent in 2 SaslAuthenticate requests, got %d", authenticates)
+	}
+
+	// the session lifetime returned by the broker schedules the
+	// re-authentication
+	broker.lock.Lock()
+	reauthenticateIn := time.Until(broker.reauthenticateAt)
+	broker.lock.Unlock()
+	if reauthenticateIn <= 0 || reauthenticateIn > time.Minute {
+		t.Errorf("expected the session to be re-authenticated within its lifetime, got %v", reauthenticateIn)
+	}
+}
+
 func TestBuildClientFirstMessage(t *testing.T) {
 	testTable := []struct {
 		name        string
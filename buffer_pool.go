@@ -0,0 +1,72 @@
+package sarama
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	minPooledBufferShift = 10 // 1KiB
+	maxPooledBufferShift = 27 // 128MiB, above the default MaxResponseSize
+)
+
+// fetchBuffers recycles the buffers the fetch responses are read into when
+// Consumer.Fetch.PooledBuffers is enabled.
+var fetchBuffers bufferPool
+
+// bufferPool recycles buffers in size classes of powers of two, so that a
+// recycled buffer is never more than twice as large as needed. Larger buffers
+// than the largest class are not recycled.
+type bufferPool struct {
+	classes [maxPooledBufferShift - minPooledBufferShift + 1]sync.Pool
+}
+
+// pooledBuffer is a buffer taken from a bufferPool, returned to it once all
+// its references are released.
+type pooledBuffer struct {
+	buf  []byte
+	refs int32
+	pool *sync.Pool // nil when the buffer is too large to be recycled
+}
+
+// get returns a buffer of the given size, with a single reference.
+func (p *bufferPool) get(size int) *pooledBuffer {
+	class := bufferSizeClass(size)
+	if class < 0 {
+		return &pooledBuffer{buf: make([]byte, size), refs: 1}
+	}
+
+	pool := &p.classes[class]
+	b, _ := pool.Get().(*pooledBuffer)
+	if b == nil {
+		b = &pooledBuffer{buf: make([]byte, 1<<(class+minPooledBufferShift)), pool: pool}
+	}
+	b.buf = b.buf[:size]
+	b.refs = 1
+	return b
+}
+
+// bufferSizeClass returns the index of the smallest class of buffers of at
+// least size bytes, or -1 when they are too large to be recycled.
+func bufferSizeClass(size int) int {
+	if size <= 1<<minPooledBufferShift {
+		return 0
+	}
+	shift := bits.Len(uint(size - 1))
+	if shift > maxPooledBufferShift {
+		return -1
+	}
+	return shift - minPooledBufferShift
+}
+
+func (b *pooledBuffer) retain(n int) {
+	atomic.AddInt32(&b.refs, int32(n))
+}
+
+func (b *pooledBuffer) release() {
+	if atomic.AddInt32(&b.refs, -1) == 0 && b.pool != nil {
+		b.buf = b.buf[:cap(b.buf)]
+		b.pool.Put(b)
+	}
+}
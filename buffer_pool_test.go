@@ -0,0 +1,44 @@
+package sarama
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestBufferSizeClass(t *testing.T) {
+	for _, tc := range []struct {
+		size  int
+		class int
+	}{
+		{0, 0},
+		{1, 0},
+		{1 << minPooledBufferShift, 0},
+		{1<<minPooledBufferShift + 1, 1},
+		{3000, 2},
+		{1 << maxPooledBufferShift, maxPooledBufferShift - minPooledBufferShift},
+		{1<<maxPooledBufferShift + 1, -1},
+	} {
+		if class := bufferSizeClass(tc.size); class != tc.class {
+			t.Errorf("Expected the class %d for %d bytes, got %d", tc.class, tc.size, class)
+		}
+	}
+}
+
+func TestBufferPool(t *testing.T) {
+	var pool bufferPool
+
+	b := pool.get(3000)
+	if len(b.buf) != 3000 || cap(b.buf) != 4096 || b.refs != 1 || b.pool == nil {
+		t.Fatalf("Expected a buffer of 3000 bytes of the 4KiB class, got %d/%d", len(b.buf), cap(b.buf))
+	}
+	b.retain(2)
+	b.release()
+	b.release()
+	if refs := atomic.LoadInt32(&b.refs); refs != 1 {
+		t.Errorf("Expected a reference left, got %d", refs)
+	}
+	b.release()
+	if len(b.buf) != cap(b.buf) {
+		t.Errorf("Expected the released buffer to be reset to its class size, got %d", len(b.buf))
+	}
+}
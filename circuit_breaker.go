@@ -0,0 +1,218 @@
+package sarama
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of the circuit of a broker, see
+// Net.CircuitBreaker.
+type CircuitState int8
+
+const (
+	// CircuitClosed is the state of the brokers requests are sent to.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen is the state of the brokers which failed too many times in a
+	// row, no request is sent to them until the cooldown is over.
+	CircuitOpen
+	// CircuitHalfOpen is the state of the brokers whose cooldown is over, a
+	// single request is sent to them to probe whether they recovered.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "Closed"
+	case CircuitOpen:
+		return "Open"
+	case CircuitHalfOpen:
+		return "HalfOpen"
+	}
+	return "Unknown"
+}
+
+// CircuitStateChange describes a change of the state of the circuit of a
+// broker, see Net.CircuitBreaker.OnStateChange.
+type CircuitStateChange struct {
+	BrokerID int32
+	Addr     string
+	From     CircuitState
+	To       CircuitState
+	// Err is the last failure of the broker when the circuit opens
+	Err error
+}
+
+// circuitBreaker tracks the consecutive failures of the brokers of a client,
+// by address so that it outlives the Broker instances replaced when the
+// metadata is refreshed.
+type circuitBreaker struct {
+	failures      int
+	cooldown      time.Duration
+	onStateChange func(change CircuitStateChange)
+
+	lock     sync.Mutex
+	circuits map[string]*circuit
+}
+
+// circuit is the state of the circuit of a broker which failed since it last
+// succeeded, the brokers without one are closed.
+type circuit struct {
+	state    CircuitState
+	failures int
+	// until is when the cooldown of the open circuit is over
+	until time.Time
+	// probing is whether the probe of the half open circuit is in flight
+	probing bool
+}
+
+// newCircuitBreaker returns the circuitBreaker of a client with conf, or nil
+// if Net.CircuitBreaker is disabled
+func newCircuitBreaker(conf *Config) *circuitBreaker {
+	if conf.Net.CircuitBreaker.Failures <= 0 {
+		return nil
+	}
+	return &circuitBreaker{
+		failures:      conf.Net.CircuitBreaker.Failures,
+		cooldown:      conf.Net.CircuitBreaker.Cooldown,
+		onStateChange: conf.Net.CircuitBreaker.OnStateChange,
+		circuits:      make(map[string]*circuit),
+	}
+}
+
+// allow returns ErrCircuitOpen if no request can be sent to the broker, and
+// otherwise lets the request through, as the probe of a circuit whose cooldown
+// is over. The outcome of the request must then be passed to record.
+func (cb *circuitBreaker) allow(b *Broker) error {
+	cb.lock.Lock()
+	c := cb.circuits[b.addr]
+	if c == nil {
+		cb.lock.Unlock()
+		return nil
+	}
+
+	var changes []CircuitStateChange
+	switch c.state {
+	case CircuitOpen:
+		if time.Now().Before(c.until) {
+			cb.lock.Unlock()
+			return ErrCircuitOpen
+		}
+		changes = append(changes, cb.transition(b, c, CircuitHalfOpen, nil))
+		c.probing = true
+	case CircuitHalfOpen:
+		if c.probing {
+			cb.lock.Unlock()
+			return ErrCircuitOpen
+		}
+		c.probing = true
+	}
+	cb.lock.Unlock()
+
+	cb.notify(changes)
+	return nil
+}
+
+// record counts the outcome of a request let through by allow
+func (cb *circuitBreaker) record(b *Broker, err error) {
+	cb.lock.Lock()
+	c := cb.circuits[b.addr]
+
+	var changes []CircuitStateChange
+	switch {
+	case err == nil:
+		if c != nil {
+			if c.state != CircuitClosed {
+				changes = append(changes, cb.transition(b, c, CircuitClosed, nil))
+			}
+			delete(cb.circuits, b.addr)
+		}
+	case !isBrokerFailure(err):
+		// the broker was not at fault, another request probes it
+		if c != nil {
+			c.probing = false
+		}
+	default:
+		if c == nil {
+			c = &circuit{}
+			cb.circuits[b.addr] = c
+		}
+		c.failures++
+		if c.state == CircuitHalfOpen || (c.state == CircuitClosed && c.failures >= cb.failures) {
+			changes = append(changes, cb.transition(b, c, CircuitOpen, err))
+			c.until = time.Now().Add(cb.cooldown)
+			c.probing = false
+		}
+	}
+	cb.lock.Unlock()
+
+	cb.notify(changes)
+}
+
+// isOpen returns whether a request sent to the broker at addr would fail with
+// ErrCircuitOpen
+func (cb *circuitBreaker) isOpen(addr string) bool {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	c := cb.circuits[addr]
+	if c == nil {
+		return false
+	}
+	switch c.state {
+	case CircuitOpen:
+		return time.Now().Before(c.until)
+	case CircuitHalfOpen:
+		return c.probing
+	}
+	return false
+}
+
+// state returns the state of the circuit of the broker at addr
+func (cb *circuitBreaker) state(addr string) CircuitState {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	if c := cb.circuits[addr]; c != nil {
+		return c.state
+	}
+	return CircuitClosed
+}
+
+// transition changes the state of c, you must hold the lock and pass the
+// change returned to notify once it is released
+func (cb *circuitBreaker) transition(b *Broker, c *circuit, state CircuitState, err error) CircuitStateChange {
+	change := CircuitStateChange{BrokerID: b.id, Addr: b.addr, From: c.state, To: state, Err: err}
+	c.state = state
+	if err != nil {
+		Logger.Printf("client/brokers circuit of broker #%d at %s is %s after %d failures: %s\n", b.id, b.addr, state, c.failures, err)
+	} else {
+		Logger.Printf("client/brokers circuit of broker #%d at %s is %s\n", b.id, b.addr, state)
+	}
+	return change
+}
+
+func (cb *circuitBreaker) notify(changes []CircuitStateChange) {
+	if cb.onStateChange == nil {
+		return
+	}
+	for _, change := range changes {
+		cb.onStateChange(change)
+	}
+}
+
+// isBrokerFailure returns whether err, returned by a request to a broker, means
+// that the broker could not be reached or did not answer, as opposed to errors
+// of the request itself, of the client or of a caller who stopped waiting
+func isBrokerFailure(err error) bool {
+	switch err.(type) {
+	case KError, ConfigurationError, PacketEncodingError:
+		return false
+	}
+	switch err {
+	case ErrCircuitOpen, ErrNotConnected, context.Canceled, context.DeadlineExceeded:
+		return false
+	}
+	return true
+}
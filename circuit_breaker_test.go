@@ -0,0 +1,83 @@
+package sarama
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var changes []CircuitStateChange
+	conf := NewTestConfig()
+	conf.Net.CircuitBreaker.Failures = 2
+	conf.Net.CircuitBreaker.Cooldown = 50 * time.Millisecond
+	conf.Net.CircuitBreaker.OnStateChange = func(change CircuitStateChange) {
+		changes = append(changes, change)
+	}
+	cb := newCircuitBreaker(conf)
+	broker := &Broker{id: 1, addr: "kafka-1:9092"}
+
+	// the errors of the requests do not count
+	cb.record(broker, ErrUnknownTopicOrPartition)
+	cb.record(broker, io.EOF)
+	cb.record(broker, nil)
+	cb.record(broker, io.EOF)
+	if err := cb.allow(broker); err != nil {
+		t.Fatal("expected the circuit to be closed, got", err)
+	}
+
+	cb.record(broker, io.EOF)
+	if !cb.isOpen(broker.addr) {
+		t.Fatal("expected the circuit to be open")
+	}
+	if err := cb.allow(broker); err != ErrCircuitOpen {
+		t.Fatal("expected ErrCircuitOpen, got", err)
+	}
+	if cb.isOpen("kafka-2:9092") {
+		t.Error("expected the circuits of the other brokers to be closed")
+	}
+
+	// a single probe is let through once the cooldown is over
+	time.Sleep(conf.Net.CircuitBreaker.Cooldown)
+	if err := cb.allow(broker); err != nil {
+		t.Fatal("expected the probe to be let through, got", err)
+	}
+	if err := cb.allow(broker); err != ErrCircuitOpen {
+		t.Fatal("expected ErrCircuitOpen while probing, got", err)
+	}
+	cb.record(broker, io.EOF)
+	if err := cb.allow(broker); err != ErrCircuitOpen {
+		t.Fatal("expected the failed probe to open the circuit again, got", err)
+	}
+
+	time.Sleep(conf.Net.CircuitBreaker.Cooldown)
+	if err := cb.allow(broker); err != nil {
+		t.Fatal("expected the probe to be let through, got", err)
+	}
+	cb.record(broker, nil)
+	if cb.isOpen(broker.addr) {
+		t.Error("expected the successful probe to close the circuit")
+	}
+
+	expected := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d state changes, got %v", len(expected), changes)
+	}
+	for i, change := range changes {
+		if change.To != expected[i] || change.BrokerID != 1 || change.Addr != broker.addr {
+			t.Errorf("unexpected state change #%d %+v", i, change)
+		}
+		if i > 0 && change.From != expected[i-1] {
+			t.Errorf("expected state change #%d from %s, got %s", i, expected[i-1], change.From)
+		}
+	}
+	if changes[0].Err != io.EOF {
+		t.Error("expected the opening change to carry the last failure, got", changes[0].Err)
+	}
+}
+
+func TestNewCircuitBreakerDisabled(t *testing.T) {
+	if cb := newCircuitBreaker(NewTestConfig()); cb != nil {
+		t.Error("expected no circuit breaker by default")
+	}
+}
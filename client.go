@@ -1,7 +1,10 @@
 package sarama
 
 import (
+	"context"
+	"fmt"
 	"math/rand"
+	"net"
 	"sort"
 	"sync"
 	"time"
@@ -26,18 +29,34 @@ type Client interface {
 	// and stores it in the local cache. Requires Kafka 0.10 or higher.
 	RefreshController() (*Broker, error)
 
+	// RefreshControllerContext is RefreshController giving up when ctx is done,
+	// in which case the error of ctx is returned.
+	RefreshControllerContext(ctx context.Context) (*Broker, error)
+
 	// Brokers returns the current set of active brokers as retrieved from cluster metadata.
 	Brokers() []*Broker
 
 	// Broker returns the active Broker if available for the broker ID.
 	Broker(brokerID int32) (*Broker, error)
 
+	// LeastLoadedBroker returns the broker with the fewest requests in flight,
+	// preferring the connected brokers and skipping those whose circuit is
+	// open, see Broker.State. The seed brokers are considered until the
+	// brokers of the cluster are known. It returns ErrOutOfBrokers if none is
+	// available.
+	LeastLoadedBroker() (*Broker, error)
+
 	// Topics returns the set of available topics as retrieved from cluster metadata.
 	Topics() ([]string, error)
 
 	// Partitions returns the sorted list of all partition IDs for the given topic.
 	Partitions(topic string) ([]int32, error)
 
+	// TopicID returns the ID of the given topic, which brokers return from
+	// version 2.8.0.0. It returns ErrUnknownTopicID when the ID is not known,
+	// such as when Config.Version is older.
+	TopicID(topic string) (Uuid, error)
+
 	// WritablePartitions returns the sorted list of all writable partition IDs for
 	// the given topic, where "writable" means "having a valid leader accepting
 	// writes".
@@ -69,6 +88,22 @@ type Client interface {
 	// metadata for all topics.
 	RefreshMetadata(topics ...string) error
 
+	// RefreshMetadataContext is RefreshMetadata giving up when ctx is done,
+	// in which case the error of ctx is returned. Retries are still bounded by
+	// Metadata.Retry and Metadata.Timeout.
+	RefreshMetadataContext(ctx context.Context, topics ...string) error
+
+	// RefreshMetadataFor refreshes the metadata of the given topics only, for
+	// example after a NotLeaderForPartition error. Unlike RefreshMetadata it
+	// never falls back to refreshing all the topics: nothing is done when no
+	// topics are given. It gives up when ctx is done.
+	RefreshMetadataFor(ctx context.Context, topics ...string) error
+
+	// RefreshMetadataWithOptions is RefreshMetadataContext with options
+	// overriding the Metadata configuration for this call only, for example
+	// to look up topics without creating them.
+	RefreshMetadataWithOptions(ctx context.Context, options MetadataRefreshOptions, topics ...string) error
+
 	// GetOffset queries the cluster to get the most recent available offset at the
 	// given time (in milliseconds) on the topic/partition combination.
 	// Time should be OffsetOldest for the earliest available offset,
@@ -85,9 +120,31 @@ type Client interface {
 	// in local cache. This function only works on Kafka 0.8.2 and higher.
 	RefreshCoordinator(consumerGroup string) error
 
+	// Coordinators returns the coordinating brokers of consumer groups, like
+	// Coordinator. The coordinators which are not cached are retrieved with a
+	// single request from Kafka 3.0 (KIP-699), and with a request per group
+	// before. The groups whose coordinator could not be found are left out of
+	// the result and reported by the ErrFindCoordinators error returned.
+	Coordinators(consumerGroups []string) (map[string]*Broker, error)
+
 	// InitProducerID retrieves information required for Idempotent Producer
 	InitProducerID() (*InitProducerIDResponse, error)
 
+	// SendRequest sends the encoded request to the broker selected by target
+	// and returns its encoded response, giving up waiting for it when ctx is
+	// done. It lets applications use the APIs Sarama does not wrap yet.
+	SendRequest(ctx context.Context, target RequestTarget, request *RawRequest) (*RawResponse, error)
+
+	// Ping sends an ApiVersions request to the brokers with the given IDs, or
+	// to all the known brokers, or the seed brokers until the metadata is
+	// fetched, when none is given, and returns the result of each of them in
+	// the order of the given IDs, or else of their IDs. The brokers that did not answer by the time ctx is
+	// done fail with the error of ctx. The error returned is nil when at
+	// least one broker answered, or else the one of the first broker, which
+	// makes it suitable for readiness probes. Ping requires Version >=
+	// V0_10_0_0.
+	Ping(ctx context.Context, brokerIDs ...int32) ([]*PingResult, error)
+
 	// Close shuts down all broker connections managed by this client. It is required
 	// to call this function before a client object passes out of scope, as it will
 	// otherwise leak memory. You must close any Producers or Consumers using a client
@@ -109,6 +166,10 @@ const (
 	// offset, or when calling ConsumePartition to start consuming from the
 	// oldest offset that is still available on the broker.
 	OffsetOldest int64 = -2
+	// OffsetMaxTimestamp stands for the offset of the message with the largest
+	// timestamp in a partition. It is only supported by ClusterAdmin.ListOffsets
+	// against brokers running Kafka 3.0.0 or later.
+	OffsetMaxTimestamp int64 = -3
 )
 
 type client struct {
@@ -120,11 +181,15 @@ type client struct {
 	// so we store them separately
 	seedBrokers []*Broker
 	deadSeeds   []*Broker
+	// seedAddrs are the broker addresses given to us, resolved again when
+	// Metadata.ReResolveBootstrap is set and no broker can be reached
+	seedAddrs []string
 
 	controllerID   int32                                   // cluster controller broker id
 	brokers        map[int32]*Broker                       // maps broker ids to brokers
 	metadata       map[string]map[int32]*PartitionMetadata // maps topics to partition ids to metadata
 	metadataTopics map[string]none                         // topics that need to collect metadata
+	topicIDs       map[string]Uuid                         // maps topics to their IDs, returned by brokers >= 2.8
 	coordinators   map[string]int32                        // Maps consumer group names to coordinating broker IDs
 
 	// If the number of partitions is large, we can get some churn calling cachedPartitions,
@@ -132,12 +197,49 @@ type client struct {
 	cachedPartitionsResults map[string][maxPartitionIndex][]int32
 
 	lock sync.RWMutex // protects access to the maps that hold cluster state.
+
+	// breaker is set on all the brokers of the client when
+	// Net.CircuitBreaker is enabled
+	breaker *circuitBreaker
+
+	// telemetry pushes the metrics of the client when Telemetry is enabled
+	telemetry *clientTelemetry
+}
+
+// LeaderChange describes a change of the leader of a partition noticed while
+// refreshing metadata, see Metadata.OnLeaderChange.
+type LeaderChange struct {
+	Topic     string
+	Partition int32
+	// OldLeader and NewLeader are broker IDs, -1 when there is no leader
+	OldLeader int32
+	NewLeader int32
+	// LeaderEpoch is the epoch of the new leader, only valid with Kafka 2.1
+	// or higher
+	LeaderEpoch int32
+}
+
+// MetadataRefreshOptions overrides the Metadata configuration for a single
+// Client.RefreshMetadataWithOptions call. Zero values keep the configured
+// ones.
+type MetadataRefreshOptions struct {
+	// AllowAutoTopicCreation replaces Metadata.AllowAutoTopicCreation: the
+	// requested topics which do not exist are only created by the brokers,
+	// when they are configured to, if it is true.
+	AllowAutoTopicCreation *bool
 }
 
 // NewClient creates a new Client. It connects to one of the given broker addresses
 // and uses that broker to automatically fetch metadata on the rest of the kafka cluster. If metadata cannot
 // be retrieved from any of the given broker addresses, the client is not created.
 func NewClient(addrs []string, conf *Config) (Client, error) {
+	return NewClientContext(context.Background(), addrs, conf)
+}
+
+// NewClientContext creates a new Client as NewClient does, giving up fetching
+// the initial metadata when ctx is done, in which case the error of ctx is
+// returned. The context is not used once the client is created.
+func NewClientContext(ctx context.Context, addrs []string, conf *Config) (Client, error) {
 	DebugLogger.Println("Initializing new client")
 
 	if conf == nil {
@@ -152,6 +254,12 @@ func NewClient(addrs []string, conf *Config) (Client, error) {
 		return nil, ConfigurationError("You must provide at least one broker address")
 	}
 
+	if conf.DiscoverVersion {
+		// the discovered version must not change the configuration of the caller
+		discovered := *conf
+		conf = &discovered
+	}
+
 	client := &client{
 		conf:                    conf,
 		closer:                  make(chan none),
@@ -159,15 +267,30 @@ func NewClient(addrs []string, conf *Config) (Client, error) {
 		brokers:                 make(map[int32]*Broker),
 		metadata:                make(map[string]map[int32]*PartitionMetadata),
 		metadataTopics:          make(map[string]none),
+		topicIDs:                make(map[string]Uuid),
 		cachedPartitionsResults: make(map[string][maxPartitionIndex][]int32),
 		coordinators:            make(map[string]int32),
+		breaker:                 newCircuitBreaker(conf),
 	}
 
+	client.seedAddrs = addrs
 	client.randomizeSeedBrokers(addrs)
 
+	if conf.DiscoverVersion {
+		// the metadata request already depends on the version, no broker of
+		// the client is open yet
+		seeds := make([]string, 0, len(client.seedBrokers))
+		for _, broker := range client.seedBrokers {
+			seeds = append(seeds, broker.Addr())
+		}
+		if version, ok := client.discoverVersion(ctx, seeds, true); ok {
+			conf.Version = version
+		}
+	}
+
 	if conf.Metadata.Full {
 		// do an initial fetch of all cluster metadata by specifying an empty list of topics
-		err := client.RefreshMetadata()
+		err := client.RefreshMetadataContext(ctx)
 		switch err {
 		case nil:
 			break
@@ -180,7 +303,22 @@ func NewClient(addrs []string, conf *Config) (Client, error) {
 			return nil, err
 		}
 	}
+
+	if conf.DiscoverVersion {
+		brokers := client.Brokers()
+		addrs := make([]string, 0, len(brokers))
+		for _, broker := range brokers {
+			addrs = append(addrs, broker.Addr())
+		}
+		if version, ok := client.discoverVersion(ctx, addrs, false); ok && version != conf.Version {
+			client.setVersion(version)
+		}
+	}
 	go withRecover(client.backgroundMetadataUpdater)
+	if conf.Telemetry.Enable && conf.Version.IsAtLeast(V3_7_0_0) {
+		client.telemetry = newClientTelemetry(client)
+		go withRecover(client.telemetry.run)
+	}
 
 	DebugLogger.Println("Successfully initialized new client")
 
@@ -212,6 +350,41 @@ func (client *client) Broker(brokerID int32) (*Broker, error) {
 	return broker, nil
 }
 
+func (client *client) LeastLoadedBroker() (*Broker, error) {
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+
+	brokers := client.seedBrokers
+	if len(client.brokers) > 0 {
+		brokers = make([]*Broker, 0, len(client.brokers))
+		for _, broker := range client.brokers {
+			brokers = append(brokers, broker)
+		}
+		sort.Slice(brokers, func(i, j int) bool { return brokers[i].ID() < brokers[j].ID() })
+	}
+
+	var least *Broker
+	var leastState BrokerState
+	for _, broker := range brokers {
+		if broker.circuitOpen() {
+			continue
+		}
+		if state := broker.State(); least == nil || lessLoaded(state, leastState) {
+			least, leastState = broker, state
+		}
+	}
+	if least == nil {
+		return nil, ErrOutOfBrokers
+	}
+
+	_ = least.Open(client.conf)
+	return least, nil
+}
+
 func (client *client) InitProducerID() (*InitProducerIDResponse, error) {
 	err := ErrOutOfBrokers
 	for broker := client.any(); broker != nil; broker = client.any() {
@@ -244,6 +417,10 @@ func (client *client) Close() error {
 	// shutdown and wait for the background thread before we take the lock, to avoid races
 	close(client.closer)
 	<-client.closed
+	if client.telemetry != nil {
+		// the last push must be sent before the brokers are closed
+		<-client.telemetry.done
+	}
 
 	client.lock.Lock()
 	defer client.lock.Unlock()
@@ -326,6 +503,32 @@ func (client *client) Partitions(topic string) ([]int32, error) {
 	return partitions, nil
 }
 
+func (client *client) TopicID(topic string) (Uuid, error) {
+	if client.Closed() {
+		return Uuid{}, ErrClosedClient
+	}
+
+	topicID, ok := client.cachedTopicID(topic)
+	if !ok {
+		if err := client.RefreshMetadata(topic); err != nil {
+			return Uuid{}, err
+		}
+		if topicID, ok = client.cachedTopicID(topic); !ok {
+			return Uuid{}, ErrUnknownTopicID
+		}
+	}
+
+	return topicID, nil
+}
+
+func (client *client) cachedTopicID(topic string) (Uuid, bool) {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+
+	topicID, ok := client.topicIDs[topic]
+	return topicID, ok
+}
+
 func (client *client) WritablePartitions(topic string) ([]int32, error) {
 	if client.Closed() {
 		return nil, ErrClosedClient
@@ -463,12 +666,21 @@ func (client *client) RefreshBrokers(addrs []string) error {
 	client.seedBrokers = nil
 	client.deadSeeds = nil
 
+	client.seedAddrs = addrs
 	client.randomizeSeedBrokers(addrs)
 
 	return nil
 }
 
 func (client *client) RefreshMetadata(topics ...string) error {
+	return client.RefreshMetadataContext(context.Background(), topics...)
+}
+
+func (client *client) RefreshMetadataContext(ctx context.Context, topics ...string) error {
+	return client.RefreshMetadataWithOptions(ctx, MetadataRefreshOptions{}, topics...)
+}
+
+func (client *client) RefreshMetadataWithOptions(ctx context.Context, options MetadataRefreshOptions, topics ...string) error {
 	if client.Closed() {
 		return ErrClosedClient
 	}
@@ -486,7 +698,21 @@ func (client *client) RefreshMetadata(topics ...string) error {
 	if client.conf.Metadata.Timeout > 0 {
 		deadline = time.Now().Add(client.conf.Metadata.Timeout)
 	}
-	return client.tryRefreshMetadata(topics, client.conf.Metadata.Retry.Max, deadline)
+	allowAutoTopicCreation := client.conf.Metadata.AllowAutoTopicCreation
+	if options.AllowAutoTopicCreation != nil {
+		allowAutoTopicCreation = *options.AllowAutoTopicCreation
+	}
+	return client.tryRefreshMetadata(ctx, topics, allowAutoTopicCreation, client.conf.Metadata.Retry.Max, deadline)
+}
+
+func (client *client) RefreshMetadataFor(ctx context.Context, topics ...string) error {
+	if len(topics) == 0 {
+		if client.Closed() {
+			return ErrClosedClient
+		}
+		return nil
+	}
+	return client.RefreshMetadataContext(ctx, topics...)
 }
 
 func (client *client) GetOffset(topic string, partitionID int32, time int64) (int64, error) {
@@ -516,7 +742,7 @@ func (client *client) Controller() (*Broker, error) {
 
 	controller := client.cachedController()
 	if controller == nil {
-		if err := client.refreshMetadata(); err != nil {
+		if err := client.refreshMetadata(context.Background()); err != nil {
 			return nil, err
 		}
 		controller = client.cachedController()
@@ -540,13 +766,17 @@ func (client *client) deregisterController() {
 // RefreshController retrieves the cluster controller from fresh metadata
 // and stores it in the local cache. Requires Kafka 0.10 or higher.
 func (client *client) RefreshController() (*Broker, error) {
+	return client.RefreshControllerContext(context.Background())
+}
+
+func (client *client) RefreshControllerContext(ctx context.Context) (*Broker, error) {
 	if client.Closed() {
 		return nil, ErrClosedClient
 	}
 
 	client.deregisterController()
 
-	if err := client.refreshMetadata(); err != nil {
+	if err := client.refreshMetadata(ctx); err != nil {
 		return nil, err
 	}
 
@@ -591,26 +821,326 @@ func (client *client) RefreshCoordinator(consumerGroup string) error {
 		return err
 	}
 
-	client.lock.Lock()
-	defer client.lock.Unlock()
-	client.registerBroker(response.Coordinator)
-	client.coordinators[consumerGroup] = response.Coordinator.ID()
+	client.registerCoordinator(consumerGroup, response.Coordinator)
 	return nil
 }
 
+func (client *client) Coordinators(consumerGroups []string) (map[string]*Broker, error) {
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+
+	coordinators := make(map[string]*Broker, len(consumerGroups))
+	var missing []string
+	for _, group := range consumerGroups {
+		if coordinator := client.cachedCoordinator(group); coordinator != nil {
+			coordinators[group] = coordinator
+		} else {
+			missing = append(missing, group)
+		}
+	}
+
+	var failed map[string]error
+	if len(missing) > 0 {
+		if client.conf.Version.IsAtLeast(V3_0_0_0) {
+			failed = client.refreshCoordinators(missing, client.conf.Metadata.Retry.Max)
+		} else {
+			failed = make(map[string]error)
+			for _, group := range missing {
+				if err := client.RefreshCoordinator(group); err != nil {
+					failed[group] = err
+				}
+			}
+		}
+	}
+
+	errs := make([]error, 0)
+	for _, group := range missing {
+		if err, ok := failed[group]; ok {
+			errs = append(errs, fmt.Errorf("[%s]: %w", group, err))
+		} else if coordinator := client.cachedCoordinator(group); coordinator != nil {
+			coordinators[group] = coordinator
+		}
+	}
+	for _, coordinator := range coordinators {
+		_ = coordinator.Open(client.conf)
+	}
+
+	if len(errs) > 0 {
+		return coordinators, ErrFindCoordinators{MultiError{&errs}}
+	}
+	return coordinators, nil
+}
+
+// registerCoordinator caches the coordinator of a consumer group
+func (client *client) registerCoordinator(consumerGroup string, coordinator *Broker) {
+	client.lock.Lock()
+	previous, known := client.coordinators[consumerGroup]
+	client.registerBroker(coordinator)
+	client.coordinators[consumerGroup] = coordinator.ID()
+	client.lock.Unlock()
+
+	if !known || previous != coordinator.ID() {
+		client.conf.notify(Event{
+			Type:     EventCoordinatorChanged,
+			BrokerID: coordinator.ID(),
+			Addr:     coordinator.Addr(),
+			Group:    consumerGroup,
+		})
+	}
+}
+
+func (client *client) SendRequest(ctx context.Context, target RequestTarget, request *RawRequest) (*RawResponse, error) {
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var (
+		broker *Broker
+		err    error
+	)
+	switch target.kind {
+	case anyBrokerTarget:
+		if broker = client.any(); broker == nil {
+			err = ErrOutOfBrokers
+		}
+	case controllerTarget:
+		broker, err = client.Controller()
+	case coordinatorTarget:
+		broker, err = client.Coordinator(target.group)
+	default:
+		broker, err = client.Broker(target.brokerID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response := &RawResponse{request: request}
+	if ctx.Done() == nil {
+		if err := broker.sendAndReceive(request, response); err != nil {
+			return nil, err
+		}
+		return response, nil
+	}
+
+	errs := make(chan error, 1)
+	go withRecover(func() {
+		errs <- broker.sendAndReceive(request, response)
+	})
+
+	select {
+	case err := <-errs:
+		if err != nil {
+			return nil, err
+		}
+		return response, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// PingResult is the result of Client.Ping for a broker.
+type PingResult struct {
+	// BrokerID is -1 for the seed brokers
+	BrokerID int32
+	Addr     string
+	// Latency is the time the broker took to answer, 0 if it failed
+	Latency time.Duration
+	Err     error
+}
+
+func (client *client) Ping(ctx context.Context, brokerIDs ...int32) ([]*PingResult, error) {
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+	if !client.conf.Version.IsAtLeast(V0_10_0_0) {
+		return nil, ConfigurationError("Ping requires Version >= V0_10_0_0")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var brokers []*Broker
+	if len(brokerIDs) == 0 {
+		brokers = client.Brokers()
+		sort.Slice(brokers, func(i, j int) bool { return brokers[i].ID() < brokers[j].ID() })
+		if len(brokers) == 0 {
+			client.lock.RLock()
+			brokers = append(brokers, client.seedBrokers...)
+			client.lock.RUnlock()
+		}
+		if len(brokers) == 0 {
+			return nil, ErrOutOfBrokers
+		}
+	}
+
+	results := make([]*PingResult, 0, len(brokerIDs)+len(brokers))
+	pinged := make(map[int]*Broker, len(brokers))
+	for _, broker := range brokers {
+		pinged[len(results)] = broker
+		results = append(results, nil)
+	}
+	for _, id := range brokerIDs {
+		broker, err := client.Broker(id)
+		if err != nil {
+			results = append(results, &PingResult{BrokerID: id, Err: err})
+			continue
+		}
+		pinged[len(results)] = broker
+		results = append(results, nil)
+	}
+
+	type pong struct {
+		index  int
+		result *PingResult
+	}
+	pongs := make(chan pong, len(pinged))
+	for index, broker := range pinged {
+		index, broker := index, broker
+		go withRecover(func() {
+			result := &PingResult{BrokerID: broker.ID(), Addr: broker.Addr()}
+			_ = broker.Open(client.conf)
+			start := time.Now()
+			res, err := broker.ApiVersions(&ApiVersionsRequest{})
+			if err == nil && res.Err != ErrNoError {
+				err = res.Err
+			}
+			if err != nil {
+				result.Err = err
+			} else {
+				result.Latency = time.Since(start)
+			}
+			pongs <- pong{index, result}
+		})
+	}
+
+wait:
+	for range pinged {
+		select {
+		case p := <-pongs:
+			results[p.index] = p.result
+		case <-ctx.Done():
+			break wait
+		}
+	}
+	for index, broker := range pinged {
+		if results[index] == nil {
+			results[index] = &PingResult{BrokerID: broker.ID(), Addr: broker.Addr(), Err: ctx.Err()}
+		}
+	}
+
+	for _, result := range results {
+		if result.Err == nil {
+			return results, nil
+		}
+	}
+	return results, results[0].Err
+}
+
 // private broker management helpers
 
+// discoverVersion returns the oldest of the versions recognized from the
+// ApiVersions responses of the brokers at the given addresses, only querying
+// them until one answers with firstOnly, and false if none answers. The
+// brokers are queried over connections of their own, opened with a copy of
+// the config, which is left untouched.
+func (client *client) discoverVersion(ctx context.Context, addrs []string, firstOnly bool) (KafkaVersion, bool) {
+	conf := *client.conf
+	// ApiVersions requires 0.10, older brokers just fail to answer
+	if !conf.Version.IsAtLeast(V0_10_0_0) {
+		conf.Version = V0_10_0_0
+	}
+
+	var (
+		lock       sync.Mutex
+		wg         sync.WaitGroup
+		discovered *KafkaVersion
+	)
+	query := func(addr string) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		broker := NewBroker(addr)
+		_ = broker.Open(&conf)
+		defer func() { _ = broker.Close() }()
+
+		res, err := broker.ApiVersions(&ApiVersionsRequest{})
+		if err == nil && res.Err != ErrNoError {
+			err = res.Err
+		}
+		if err != nil {
+			Logger.Printf("client/version failed to get the API versions of broker %s: %v\n", addr, err)
+			return false
+		}
+
+		version := res.KafkaVersion()
+		DebugLogger.Printf("client/version broker %s supports Kafka %s\n", addr, version)
+		lock.Lock()
+		defer lock.Unlock()
+		if discovered == nil || !version.IsAtLeast(*discovered) {
+			discovered = &version
+		}
+		return true
+	}
+
+	for _, addr := range addrs {
+		if firstOnly {
+			if query(addr) {
+				break
+			}
+			continue
+		}
+		addr := addr
+		wg.Add(1)
+		go withRecover(func() {
+			defer wg.Done()
+			query(addr)
+		})
+	}
+	wg.Wait()
+
+	if discovered == nil {
+		return KafkaVersion{}, false
+	}
+	Logger.Printf("client/version using Kafka version %s discovered from the brokers\n", *discovered)
+	return *discovered, true
+}
+
+// setVersion replaces Config.Version once the client was created. The brokers
+// read the config from their goroutines, so they are closed first, to be
+// reopened with the new version when next used.
+func (client *client) setVersion(version KafkaVersion) {
+	client.lock.RLock()
+	brokers := append([]*Broker(nil), client.seedBrokers...)
+	for _, broker := range client.brokers {
+		brokers = append(brokers, broker)
+	}
+	client.lock.RUnlock()
+
+	for _, broker := range brokers {
+		_ = broker.Close()
+	}
+	client.conf.Version = version
+}
+
 func (client *client) randomizeSeedBrokers(addrs []string) {
 	random := rand.New(rand.NewSource(time.Now().UnixNano()))
 	for _, index := range random.Perm(len(addrs)) {
-		client.seedBrokers = append(client.seedBrokers, NewBroker(addrs[index]))
+		broker := NewBroker(addrs[index])
+		broker.breaker = client.breaker
+		client.seedBrokers = append(client.seedBrokers, broker)
 	}
 }
 
 func (client *client) updateBroker(brokers []*Broker) {
 	currentBroker := make(map[int32]*Broker, len(brokers))
+	client.conf.resolveBrokers(brokers...)
 
 	for _, broker := range brokers {
+		broker.breaker = client.breaker
 		currentBroker[broker.ID()] = broker
 		if client.brokers[broker.ID()] == nil { // add new broker
 			client.brokers[broker.ID()] = broker
@@ -640,6 +1170,8 @@ func (client *client) registerBroker(broker *Broker) {
 		return
 	}
 
+	client.conf.resolveBrokers(broker)
+	broker.breaker = client.breaker
 	if client.brokers[broker.ID()] == nil {
 		client.brokers[broker.ID()] = broker
 		DebugLogger.Printf("client/brokers registered new broker #%d at %s", broker.ID(), broker.Addr())
@@ -656,9 +1188,17 @@ func (client *client) deregisterBroker(broker *Broker) {
 	client.lock.Lock()
 	defer client.lock.Unlock()
 
-	if len(client.seedBrokers) > 0 && broker == client.seedBrokers[0] {
+	seed := -1
+	for i, seedBroker := range client.seedBrokers {
+		if broker == seedBroker {
+			seed = i
+			break
+		}
+	}
+
+	if seed >= 0 {
 		client.deadSeeds = append(client.deadSeeds, broker)
-		client.seedBrokers = client.seedBrokers[1:]
+		client.seedBrokers = append(client.seedBrokers[:seed:seed], client.seedBrokers[seed+1:]...)
 	} else {
 		// we do this so that our loop in `tryRefreshMetadata` doesn't go on forever,
 		// but we really shouldn't have to; once that loop is made better this case can be
@@ -670,6 +1210,11 @@ func (client *client) deregisterBroker(broker *Broker) {
 }
 
 func (client *client) resurrectDeadBrokers() {
+	if client.conf.Metadata.ReResolveBootstrap {
+		client.reResolveSeedBrokers()
+		return
+	}
+
 	client.lock.Lock()
 	defer client.lock.Unlock()
 
@@ -678,16 +1223,81 @@ func (client *client) resurrectDeadBrokers() {
 	client.deadSeeds = nil
 }
 
+// lookupHost resolves a host name, it is a variable so tests can stub DNS
+var lookupHost = net.DefaultResolver.LookupHost
+
+// reResolveSeedBrokers resolves the host names of the seed broker addresses
+// again and replaces all the known brokers by one seed broker per address
+func (client *client) reResolveSeedBrokers() {
+	client.lock.RLock()
+	seedAddrs := client.seedAddrs
+	client.lock.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), client.conf.Net.DialTimeout)
+	defer cancel()
+
+	var addrs []string
+	for _, addr := range seedAddrs {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			addrs = append(addrs, addr)
+			continue
+		}
+		hosts, err := lookupHost(ctx, host)
+		if err != nil || len(hosts) == 0 {
+			Logger.Printf("client/brokers failed to resolve seed broker %s: %v", addr, err)
+			addrs = append(addrs, addr)
+			continue
+		}
+		for _, h := range hosts {
+			addrs = append(addrs, net.JoinHostPort(h, port))
+		}
+	}
+
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	Logger.Printf("client/brokers re-resolved %d seed brokers to %d addresses", len(seedAddrs), len(addrs))
+	for _, broker := range client.seedBrokers {
+		safeAsyncClose(broker)
+	}
+	for _, broker := range client.deadSeeds {
+		safeAsyncClose(broker)
+	}
+	for id, broker := range client.brokers {
+		safeAsyncClose(broker)
+		delete(client.brokers, id)
+	}
+	client.seedBrokers = nil
+	client.deadSeeds = nil
+	client.randomizeSeedBrokers(addrs)
+}
+
 func (client *client) any() *Broker {
 	client.lock.RLock()
 	defer client.lock.RUnlock()
 
+	// the brokers whose circuit is open are skipped, unless they all are
+	for _, broker := range client.seedBrokers {
+		if !broker.circuitOpen() {
+			_ = broker.Open(client.conf)
+			return broker
+		}
+	}
+
+	// not guaranteed to be random *or* deterministic
+	for _, broker := range client.brokers {
+		if !broker.circuitOpen() {
+			_ = broker.Open(client.conf)
+			return broker
+		}
+	}
+
+	// they fail fast, letting tryRefreshMetadata move on to the next one
 	if len(client.seedBrokers) > 0 {
 		_ = client.seedBrokers[0].Open(client.conf)
 		return client.seedBrokers[0]
 	}
-
-	// not guaranteed to be random *or* deterministic
 	for _, broker := range client.brokers {
 		_ = broker.Open(client.conf)
 		return broker
@@ -823,7 +1433,7 @@ func (client *client) backgroundMetadataUpdater() {
 	for {
 		select {
 		case <-ticker.C:
-			if err := client.refreshMetadata(); err != nil {
+			if err := client.refreshMetadata(context.Background()); err != nil {
 				Logger.Println("Client background metadata update:", err)
 			}
 		case <-client.closer:
@@ -832,7 +1442,7 @@ func (client *client) backgroundMetadataUpdater() {
 	}
 }
 
-func (client *client) refreshMetadata() error {
+func (client *client) refreshMetadata(ctx context.Context) error {
 	var topics []string
 
 	if !client.conf.Metadata.Full {
@@ -845,14 +1455,14 @@ func (client *client) refreshMetadata() error {
 		}
 	}
 
-	if err := client.RefreshMetadata(topics...); err != nil {
+	if err := client.RefreshMetadataContext(ctx, topics...); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (client *client) tryRefreshMetadata(topics []string, attemptsRemaining int, deadline time.Time) error {
+func (client *client) tryRefreshMetadata(ctx context.Context, topics []string, allowAutoTopicCreation bool, attemptsRemaining int, deadline time.Time) error {
 	pastDeadline := func(backoff time.Duration) bool {
 		if !deadline.IsZero() && time.Now().Add(backoff).After(deadline) {
 			// we are past the deadline
@@ -869,30 +1479,38 @@ func (client *client) tryRefreshMetadata(topics []string, attemptsRemaining int,
 			}
 			Logger.Printf("client/metadata retrying after %dms... (%d attempts remaining)\n", backoff/time.Millisecond, attemptsRemaining)
 			if backoff > 0 {
-				time.Sleep(backoff)
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(backoff):
+				}
 			}
-			return client.tryRefreshMetadata(topics, attemptsRemaining-1, deadline)
+			return client.tryRefreshMetadata(ctx, topics, allowAutoTopicCreation, attemptsRemaining-1, deadline)
 		}
 		return err
 	}
 
 	broker := client.any()
 	for ; broker != nil && !pastDeadline(0); broker = client.any() {
-		allowAutoTopicCreation := client.conf.Metadata.AllowAutoTopicCreation
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		req := NewMetadataRequest(client.conf.Version, topics)
 		if len(topics) > 0 {
+			req.AllowAutoTopicCreation = allowAutoTopicCreation
 			DebugLogger.Printf("client/metadata fetching metadata for %v from broker %s\n", topics, broker.addr)
 		} else {
-			allowAutoTopicCreation = false
 			DebugLogger.Printf("client/metadata fetching metadata for all topics from broker %s\n", broker.addr)
 		}
-
-		req := &MetadataRequest{Topics: topics, AllowAutoTopicCreation: allowAutoTopicCreation}
-		if client.conf.Version.IsAtLeast(V1_0_0_0) {
-			req.Version = 5
-		} else if client.conf.Version.IsAtLeast(V0_10_0_0) {
-			req.Version = 1
+		response, err := getMetadataContext(ctx, broker, req)
+		if err != nil && ctx.Err() != nil {
+			// the broker is not to blame
+			return ctx.Err()
+		}
+		if err == nil && response.Err != ErrNoError {
+			err = response.Err
 		}
-		response, err := broker.GetMetadata(req)
 		switch err := err.(type) {
 		case nil:
 			allKnownMetaData := len(topics) == 0
@@ -942,12 +1560,56 @@ func (client *client) tryRefreshMetadata(topics []string, attemptsRemaining int,
 	return retry(ErrOutOfBrokers)
 }
 
+// getMetadataContext sends the metadata request to the broker, giving up
+// waiting for the response when ctx is done
+func getMetadataContext(ctx context.Context, broker *Broker, req *MetadataRequest) (*MetadataResponse, error) {
+	if ctx.Done() == nil {
+		return broker.GetMetadata(req)
+	}
+
+	type result struct {
+		response *MetadataResponse
+		err      error
+	}
+	results := make(chan result, 1)
+	go withRecover(func() {
+		response, err := broker.GetMetadata(req)
+		results <- result{response, err}
+	})
+
+	select {
+	case r := <-results:
+		return r.response, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 // if no fatal error, returns a list of topics that need retrying due to ErrLeaderNotAvailable
 func (client *client) updateMetadata(data *MetadataResponse, allKnownMetaData bool) (retry bool, err error) {
 	if client.Closed() {
 		return
 	}
 
+	// leader changes and the event are notified once the lock is released
+	var leaderChanges []LeaderChange
+	if onLeaderChange := client.conf.Metadata.OnLeaderChange; onLeaderChange != nil {
+		defer func() {
+			for _, change := range leaderChanges {
+				onLeaderChange(change)
+			}
+		}()
+	}
+	if client.conf.EventHandler != nil {
+		defer func() {
+			topics := make([]string, len(data.Topics))
+			for i, topic := range data.Topics {
+				topics[i] = topic.Name
+			}
+			client.conf.notify(Event{Type: EventMetadataUpdated, Topics: topics, Err: err})
+		}()
+	}
+
 	client.lock.Lock()
 	defer client.lock.Unlock()
 
@@ -960,9 +1622,11 @@ func (client *client) updateMetadata(data *MetadataResponse, allKnownMetaData bo
 
 	client.controllerID = data.ControllerID
 
+	previous := client.metadata
 	if allKnownMetaData {
 		client.metadata = make(map[string]map[int32]*PartitionMetadata)
 		client.metadataTopics = make(map[string]none)
+		client.topicIDs = make(map[string]Uuid)
 		client.cachedPartitionsResults = make(map[string][maxPartitionIndex][]int32)
 	}
 	for _, topic := range data.Topics {
@@ -972,7 +1636,9 @@ func (client *client) updateMetadata(data *MetadataResponse, allKnownMetaData bo
 		if _, exists := client.metadataTopics[topic.Name]; !exists {
 			client.metadataTopics[topic.Name] = none{}
 		}
+		previousPartitions := previous[topic.Name]
 		delete(client.metadata, topic.Name)
+		delete(client.topicIDs, topic.Name)
 		delete(client.cachedPartitionsResults, topic.Name)
 
 		switch topic.Err {
@@ -993,12 +1659,24 @@ func (client *client) updateMetadata(data *MetadataResponse, allKnownMetaData bo
 			continue
 		}
 
+		if topic.Uuid != (Uuid{}) {
+			client.topicIDs[topic.Name] = topic.Uuid
+		}
 		client.metadata[topic.Name] = make(map[int32]*PartitionMetadata, len(topic.Partitions))
 		for _, partition := range topic.Partitions {
 			client.metadata[topic.Name][partition.ID] = partition
 			if partition.Err == ErrLeaderNotAvailable {
 				retry = true
 			}
+			if old := previousPartitions[partition.ID]; old != nil && old.Leader != partition.Leader && client.conf.Metadata.OnLeaderChange != nil {
+				leaderChanges = append(leaderChanges, LeaderChange{
+					Topic:       topic.Name,
+					Partition:   partition.ID,
+					OldLeader:   old.Leader,
+					NewLeader:   partition.Leader,
+					LeaderEpoch: partition.LeaderEpoch,
+				})
+			}
 		}
 
 		var partitionCache [maxPartitionIndex][]int32
@@ -1027,8 +1705,11 @@ func (client *client) cachedController() *Broker {
 }
 
 func (client *client) computeBackoff(attemptsRemaining int) time.Duration {
+	maxRetries := client.conf.Metadata.Retry.Max
+	if client.conf.Metadata.Retry.Policy != nil {
+		return client.conf.Metadata.Retry.Policy.Backoff(maxRetries-attemptsRemaining+1, maxRetries)
+	}
 	if client.conf.Metadata.Retry.BackoffFunc != nil {
-		maxRetries := client.conf.Metadata.Retry.Max
 		retries := maxRetries - attemptsRemaining
 		return client.conf.Metadata.Retry.BackoffFunc(retries, maxRetries)
 	}
@@ -1098,6 +1779,90 @@ func (client *client) getConsumerMetadata(consumerGroup string, attemptsRemainin
 	return retry(ErrOutOfBrokers)
 }
 
+// refreshCoordinators retrieves and caches the coordinators of consumer
+// groups with a batched FindCoordinator request, retrying the groups whose
+// coordinator is not available, and returns the errors of the groups whose
+// coordinator was not found.
+func (client *client) refreshCoordinators(consumerGroups []string, attemptsRemaining int) map[string]error {
+	failed := make(map[string]error, len(consumerGroups))
+	// the groups keep their last error when no attempts remain
+	retry := func(groups []string) map[string]error {
+		if attemptsRemaining > 0 {
+			backoff := client.computeBackoff(attemptsRemaining)
+			Logger.Printf("client/coordinator retrying %d groups after %dms... (%d attempts remaining)\n", len(groups), backoff/time.Millisecond, attemptsRemaining)
+			time.Sleep(backoff)
+			for _, group := range groups {
+				delete(failed, group)
+			}
+			for group, err := range client.refreshCoordinators(groups, attemptsRemaining-1) {
+				failed[group] = err
+			}
+		}
+		return failed
+	}
+
+	for broker := client.any(); broker != nil; broker = client.any() {
+		DebugLogger.Printf("client/coordinator requesting coordinators for %d consumer groups from %s\n", len(consumerGroups), broker.Addr())
+
+		request := &FindCoordinatorRequest{
+			Version:         4,
+			CoordinatorType: CoordinatorGroup,
+			CoordinatorKeys: consumerGroups,
+		}
+
+		response, err := broker.FindCoordinator(request)
+		if err != nil {
+			Logger.Printf("client/coordinator request to broker %s failed: %s\n", broker.Addr(), err)
+
+			switch err.(type) {
+			case PacketEncodingError:
+				for _, group := range consumerGroups {
+					failed[group] = err
+				}
+				return failed
+			default:
+				_ = broker.Close()
+				client.deregisterBroker(broker)
+				continue
+			}
+		}
+
+		found := make(map[string]bool, len(response.Coordinators))
+		var retriable []string
+		for _, coordinator := range response.Coordinators {
+			found[coordinator.Key] = true
+			switch coordinator.Err {
+			case ErrNoError:
+				DebugLogger.Printf("client/coordinator coordinator for consumergroup %s is #%d (%s)\n", coordinator.Key, coordinator.Coordinator.ID(), coordinator.Coordinator.Addr())
+				client.registerCoordinator(coordinator.Key, coordinator.Coordinator)
+			case ErrConsumerCoordinatorNotAvailable, ErrGroupAuthorizationFailed:
+				Logger.Printf("client/coordinator coordinator for consumer group %s is not available: %s\n", coordinator.Key, coordinator.Err)
+				retriable = append(retriable, coordinator.Key)
+				failed[coordinator.Key] = coordinator.Err
+			default:
+				failed[coordinator.Key] = coordinator.Err
+			}
+		}
+		for _, group := range consumerGroups {
+			if !found[group] {
+				failed[group] = ErrIncompleteResponse
+			}
+		}
+
+		if len(retriable) > 0 {
+			return retry(retriable)
+		}
+		return failed
+	}
+
+	Logger.Println("client/coordinator no available broker to send consumer metadata request to")
+	client.resurrectDeadBrokers()
+	for _, group := range consumerGroups {
+		failed[group] = ErrOutOfBrokers
+	}
+	return retry(consumerGroups)
+}
+
 // nopCloserClient embeds an existing Client, but disables
 // the Close method (yet all other methods pass
 // through unchanged). This is for use in larger structs
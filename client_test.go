@@ -1,8 +1,12 @@
 package sarama
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"reflect"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -214,6 +218,125 @@ func TestClientMetadata(t *testing.T) {
 	safeClose(t, client)
 }
 
+func TestClientTopicID(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	topicID := Uuid{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()).
+			SetLeader("other_topic", 0, seedBroker.BrokerID()).
+			SetTopicID("my_topic", topicID),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_1_0_0
+	config.Metadata.Retry.Max = 0
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	if id, err := client.TopicID("my_topic"); err != nil || id != topicID {
+		t.Errorf("Expected the ID of my_topic, got %s, %v", id, err)
+	}
+	if _, err := client.TopicID("other_topic"); !errors.Is(err, ErrUnknownTopicID) {
+		t.Errorf("Expected ErrUnknownTopicID for a topic without ID, got %v", err)
+	}
+	for _, entry := range seedBroker.History() {
+		if request, ok := entry.Request.(*MetadataRequest); ok && request.Version != 12 {
+			t.Errorf("Expected a metadata request of version 12, got %d", request.Version)
+		}
+	}
+}
+
+func TestClientCoordinators(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	coordinator := NewMockBroker(t, 2)
+	defer coordinator.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "group_a", coordinator).
+			SetCoordinator(CoordinatorGroup, "group_b", seedBroker).
+			SetError(CoordinatorGroup, "group_c", ErrGroupAuthorizationFailed),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_0_0_0
+	config.Metadata.Retry.Max = 1
+	config.Metadata.Retry.Backoff = 0
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	coordinators, err := client.Coordinators([]string{"group_a", "group_b", "group_c"})
+	var findErr ErrFindCoordinators
+	if !errors.As(err, &findErr) || len(*findErr.Errors) != 1 || !errors.Is((*findErr.Errors)[0], ErrGroupAuthorizationFailed) {
+		t.Errorf("Expected the coordinator of group_c not to be found, got %v", err)
+	}
+	if len(coordinators) != 2 ||
+		coordinators["group_a"].ID() != coordinator.BrokerID() ||
+		coordinators["group_b"].ID() != seedBroker.BrokerID() {
+		t.Errorf("Unexpected coordinators %v", coordinators)
+	}
+
+	// the cached coordinators are not requested again
+	if coordinators, err := client.Coordinators([]string{"group_a", "group_b"}); err != nil || len(coordinators) != 2 {
+		t.Errorf("Expected the cached coordinators, got %v, %v", coordinators, err)
+	}
+
+	var keys [][]string
+	for _, entry := range seedBroker.History() {
+		if request, ok := entry.Request.(*FindCoordinatorRequest); ok {
+			if request.Version != 4 {
+				t.Errorf("Expected a FindCoordinator request of version 4, got %d", request.Version)
+			}
+			keys = append(keys, request.CoordinatorKeys)
+		}
+	}
+	expected := [][]string{{"group_a", "group_b", "group_c"}, {"group_c"}}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected the coordinators to be requested for %v, got %v", expected, keys)
+	}
+}
+
+func TestClientCoordinatorsBeforeKIP699(t *testing.T) {
+	cluster := NewMockCluster(t, 2)
+	defer cluster.Close()
+
+	config := NewTestConfig()
+	config.Version = V2_8_0_0
+	client, err := NewClient(cluster.Addrs(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	groups := []string{"group_a", "group_b", "group_c"}
+	coordinators, err := client.Coordinators(groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, group := range groups {
+		expected, err := client.Coordinator(group)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if coordinators[group] == nil || coordinators[group].ID() != expected.ID() {
+			t.Errorf("Expected the coordinator of %s to be #%d, got %v", group, expected.ID(), coordinators[group])
+		}
+	}
+}
+
 func TestClientMetadataWithOfflineReplicas(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	leader := NewMockBroker(t, 5)
@@ -387,6 +510,50 @@ func TestClientReceivingUnknownTopicWithBackoffFunc(t *testing.T) {
 	}
 }
 
+func TestClientReceivingUnknownTopicWithBackoffPolicy(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+
+	metadataResponse1 := new(MetadataResponse)
+	seedBroker.Returns(metadataResponse1)
+
+	var backoffRetries []int
+
+	config := NewTestConfig()
+	config.Metadata.Retry.Max = 2
+	config.Metadata.Retry.BackoffFunc = func(retries, maxRetries int) time.Duration {
+		t.Error("BackoffFunc should not be called when a Policy is set")
+		return 0
+	}
+	config.Metadata.Retry.Policy = BackoffPolicyFunc(func(retries, maxRetries int) time.Duration {
+		if maxRetries != 2 {
+			t.Errorf("Expected maxRetries 2, got %d", maxRetries)
+		}
+		backoffRetries = append(backoffRetries, retries)
+		return 0
+	})
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadataUnknownTopic := new(MetadataResponse)
+	metadataUnknownTopic.AddTopic("new_topic", ErrUnknownTopicOrPartition)
+	seedBroker.Returns(metadataUnknownTopic)
+	seedBroker.Returns(metadataUnknownTopic)
+	seedBroker.Returns(metadataUnknownTopic)
+
+	if err := client.RefreshMetadata("new_topic"); err != ErrUnknownTopicOrPartition {
+		t.Error("ErrUnknownTopicOrPartition expected, got", err)
+	}
+
+	safeClose(t, client)
+	seedBroker.Close()
+
+	if !reflect.DeepEqual(backoffRetries, []int{1, 2}) {
+		t.Errorf("Expected the policy to be called for retries [1 2], got %v", backoffRetries)
+	}
+}
+
 func TestClientReceivingUnknownTopic(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 
@@ -859,6 +1026,185 @@ func TestClientCoordinatorWithConsumerOffsetsTopic(t *testing.T) {
 	safeClose(t, client)
 }
 
+func TestClientAddressResolver(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	leader := NewMockBroker(t, 2)
+	defer leader.Close()
+
+	// the brokers advertise listeners that are not reachable from the client
+	advertised := map[string]string{
+		"kafka-1.internal:9092": seedBroker.Addr(),
+		"kafka-2.internal:9092": leader.Addr(),
+	}
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker("kafka-1.internal:9092", seedBroker.BrokerID())
+	metadataResponse.AddBroker("kafka-2.internal:9092", leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	config := NewTestConfig()
+	config.Net.AddressResolver = func(broker string) string {
+		if addr, ok := advertised[broker]; ok {
+			return addr
+		}
+		return broker
+	}
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	broker, err := client.Leader("my_topic", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if broker.Addr() != leader.Addr() {
+		t.Errorf("expected the leader at %s, got %s", leader.Addr(), broker.Addr())
+	}
+
+	coordinatorResponse := new(ConsumerMetadataResponse)
+	coordinatorResponse.CoordinatorID = leader.BrokerID()
+	coordinatorResponse.CoordinatorHost = "kafka-2.internal"
+	coordinatorResponse.CoordinatorPort = 9092
+	seedBroker.Returns(coordinatorResponse)
+
+	coordinator, err := client.Coordinator("my_group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coordinator.Addr() != leader.Addr() {
+		t.Errorf("expected the coordinator at %s, got %s", leader.Addr(), coordinator.Addr())
+	}
+	// the resolved address of the registered broker did not change
+	if coordinator != broker {
+		t.Error("expected the registered broker to be kept")
+	}
+}
+
+func TestClientSendRequest(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	controller := NewMockBroker(t, 2)
+	defer controller.Close()
+
+	metadata := NewMockMetadataResponse(t).
+		SetController(controller.BrokerID()).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetBroker(controller.Addr(), controller.BrokerID())
+	seedBroker.SetHandlerByMap(map[string]MockResponse{"MetadataRequest": metadata})
+	controller.SetHandlerByMap(map[string]MockResponse{"MetadataRequest": metadata})
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	// a flexible version, whose headers carry tagged fields
+	body, err := encode(&MetadataRequest{Version: 9}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := &RawRequest{APIKey: 3, APIVersion: 9, Flexible: true, Body: body}
+
+	for name, target := range map[string]RequestTarget{
+		"any":        AnyBrokerTarget(),
+		"controller": ControllerTarget(),
+		"broker":     BrokerTarget(controller.BrokerID()),
+	} {
+		response, err := client.SendRequest(context.Background(), target, request)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		decoded := &MetadataResponse{Version: 9}
+		if err := versionedDecode(response.Body, decoded, 9); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if decoded.ControllerID != controller.BrokerID() || len(decoded.Brokers) != 2 {
+			t.Errorf("%s: unexpected response %+v", name, decoded)
+		}
+	}
+
+	received := false
+	for _, rr := range controller.History() {
+		if req, ok := rr.Request.(*MetadataRequest); ok && req.Version == 9 {
+			received = true
+		}
+	}
+	if !received {
+		t.Error("expected the controller to receive the raw request")
+	}
+	if _, err := client.SendRequest(context.Background(), BrokerTarget(42), request); !errors.Is(err, ErrBrokerNotFound) {
+		t.Errorf("expected ErrBrokerNotFound, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.SendRequest(ctx, AnyBrokerTarget(), request); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestClientPing(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	deadBroker := NewMockBroker(t, 2)
+	defer deadBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetBroker(deadBroker.Addr(), deadBroker.BrokerID()),
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+	})
+	// the ApiVersions requests of the dead broker are never answered
+	deadBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": mockResponseFunc(func(versionedDecoder) encoderWithHeader { return nil }),
+	})
+
+	config := NewTestConfig()
+	config.Version = V0_10_0_0
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	results, err := client.Ping(ctx)
+	if err != nil {
+		t.Fatalf("expected the ping to succeed with one broker up, got %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if r := results[0]; r.BrokerID != seedBroker.BrokerID() || r.Addr != seedBroker.Addr() || r.Err != nil || r.Latency <= 0 {
+		t.Errorf("unexpected result for the seed broker %+v", r)
+	}
+	if r := results[1]; r.BrokerID != deadBroker.BrokerID() || !errors.Is(r.Err, context.DeadlineExceeded) {
+		t.Errorf("unexpected result for the dead broker %+v", r)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := client.Ping(ctx, deadBroker.BrokerID()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	results, err = client.Ping(context.Background(), 42, seedBroker.BrokerID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || !errors.Is(results[0].Err, ErrBrokerNotFound) || results[1].Err != nil {
+		t.Errorf("unexpected results %+v %+v", results[0], results[1])
+	}
+}
+
 func TestClientCoordinatorWithoutConsumerOffsetsTopic(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	coordinator := NewMockBroker(t, 2)
@@ -954,3 +1300,458 @@ func TestClientAutorefreshShutdownRace(t *testing.T) {
 	// give the update time to happen so we get a panic if it's still running (which it shouldn't)
 	time.Sleep(10 * time.Millisecond)
 }
+
+func TestNewClientContextDeadline(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+	// the initial metadata never arrives in time
+	seedBroker.SetLatency(500 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	client, err := NewClientContext(ctx, []string{seedBroker.Addr()}, NewTestConfig())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if client != nil {
+		t.Error("expected no client")
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("NewClientContext returned after %v instead of at the deadline", elapsed)
+	}
+}
+
+func TestClientRefreshMetadataContextCanceledDuringBackoff(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(seedBroker.Addr(), seedBroker.BrokerID())
+	seedBroker.Returns(metadataResponse)
+
+	config := NewTestConfig()
+	config.Metadata.Retry.Max = 3
+	config.Metadata.Retry.Backoff = time.Minute
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	// a leaderless partition makes the refresh back off before retrying
+	leaderless := new(MetadataResponse)
+	leaderless.AddBroker(seedBroker.Addr(), seedBroker.BrokerID())
+	leaderless.AddTopicPartition("my_topic", 0, -1, []int32{}, []int32{}, []int32{}, ErrLeaderNotAvailable)
+	seedBroker.Returns(leaderless)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := client.RefreshMetadataContext(ctx, "my_topic"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("RefreshMetadataContext returned after %v instead of at the deadline", elapsed)
+	}
+
+	// the broker is not blamed for the canceled refresh
+	if len(client.Brokers()) != 1 {
+		t.Errorf("expected the broker to still be registered, got %d brokers", len(client.Brokers()))
+	}
+}
+
+func TestClientRefreshControllerContextCanceled(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.RefreshControllerContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	controller, err := client.RefreshControllerContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if controller.ID() != seedBroker.BrokerID() {
+		t.Errorf("expected controller %d, got %d", seedBroker.BrokerID(), controller.ID())
+	}
+}
+
+func TestClientReResolveBootstrap(t *testing.T) {
+	oldCluster := NewMockBroker(t, 1)
+	newCluster := NewMockBroker(t, 2)
+	defer newCluster.Close()
+
+	oldCluster.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(oldCluster.Addr(), oldCluster.BrokerID()),
+	})
+	newCluster.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(newCluster.Addr(), newCluster.BrokerID()),
+	})
+
+	// the bootstrap name resolves to the new cluster once the old one is gone
+	defer func(original func(ctx context.Context, host string) ([]string, error)) {
+		lookupHost = original
+	}(lookupHost)
+	lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if host != "bootstrap.test" {
+			return nil, fmt.Errorf("unexpected host %s", host)
+		}
+		return []string{"10.0.0.2"}, nil
+	}
+
+	config := NewTestConfig()
+	config.Metadata.Retry.Max = 1
+	config.Metadata.Retry.Backoff = 0
+	config.Metadata.ReResolveBootstrap = true
+	config.Net.DialFn = func(ctx context.Context, network, address string) (net.Conn, error) {
+		switch address {
+		case "bootstrap.test:9092":
+			address = oldCluster.Addr()
+		case "10.0.0.2:9092":
+			address = newCluster.Addr()
+		}
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	client, err := NewClient([]string{"bootstrap.test:9092"}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	oldCluster.Close()
+
+	if err := client.RefreshMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	brokers := client.Brokers()
+	if len(brokers) != 1 || brokers[0].ID() != newCluster.BrokerID() {
+		t.Errorf("expected the brokers of the new cluster, got %v", brokers)
+	}
+}
+
+func TestClientRefreshMetadataForNotifiesLeaderChanges(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(seedBroker.Addr(), seedBroker.BrokerID())
+	metadataResponse.AddBroker("localhost:9093", 2)
+	metadataResponse.AddTopicPartition("my_topic", 0, 2, []int32{1, 2}, []int32{1, 2}, nil, ErrNoError)
+	metadataResponse.AddTopicPartition("my_topic", 1, 1, []int32{1, 2}, []int32{1, 2}, nil, ErrNoError)
+	metadataResponse.AddTopicPartition("other_topic", 0, 2, []int32{1, 2}, []int32{1, 2}, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	var changes []LeaderChange
+	config := NewTestConfig()
+	config.Metadata.OnLeaderChange = func(change LeaderChange) {
+		changes = append(changes, change)
+	}
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	if len(changes) != 0 {
+		t.Errorf("expected no leader change for the initial metadata, got %v", changes)
+	}
+
+	// nothing is refreshed without topics
+	if err := client.RefreshMetadataFor(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	metadataResponse = new(MetadataResponse)
+	metadataResponse.AddBroker(seedBroker.Addr(), seedBroker.BrokerID())
+	metadataResponse.AddBroker("localhost:9093", 2)
+	metadataResponse.AddTopicPartition("my_topic", 0, 1, []int32{1, 2}, []int32{1}, nil, ErrNoError)
+	metadataResponse.AddTopicPartition("my_topic", 1, 1, []int32{1, 2}, []int32{1}, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	if err := client.RefreshMetadataFor(context.Background(), "my_topic"); err != nil {
+		t.Fatal(err)
+	}
+
+	history := seedBroker.History()
+	if len(history) != 2 {
+		t.Fatalf("expected 2 metadata requests, got %d", len(history))
+	}
+	request, ok := history[1].Request.(*MetadataRequest)
+	if !ok || !reflect.DeepEqual(request.Topics, []string{"my_topic"}) {
+		t.Errorf("expected a metadata request for my_topic only, got %v", history[1].Request)
+	}
+
+	expected := []LeaderChange{{Topic: "my_topic", Partition: 0, OldLeader: 2, NewLeader: 1}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected leader changes %v, got %v", expected, changes)
+	}
+	if leader, err := client.Leader("other_topic", 0); err != nil || leader.ID() != 2 {
+		t.Errorf("expected the metadata of other_topic to be kept, got %v %v", leader, err)
+	}
+}
+
+func TestClientDiscoverVersion(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	otherBroker := NewMockBroker(t, 2)
+	defer otherBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t).SetApiVersions([]*ApiVersionsResponseBlock{
+			{ApiKey: 3, MaxVersion: 8},
+			{ApiKey: 44, MaxVersion: 1},
+		}),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetBroker(otherBroker.Addr(), otherBroker.BrokerID()),
+	})
+	// the other broker is older, its version is the one of the cluster
+	otherBroker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t).SetApiVersions([]*ApiVersionsResponseBlock{
+			{ApiKey: 3, MaxVersion: 5},
+			{ApiKey: 42, MaxVersion: 1},
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V0_10_0_0
+	config.DiscoverVersion = true
+	c, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, c)
+	client := c.(*client)
+
+	if version := client.Config().Version; version != V1_1_0_0 {
+		t.Errorf("expected the discovered version %s, got %s", V1_1_0_0, version)
+	}
+	if config.Version != V0_10_0_0 {
+		t.Errorf("expected the version of the caller to be kept, got %s", config.Version)
+	}
+
+	// the metadata request was sent with the version of the seed broker
+	for _, entry := range seedBroker.History() {
+		if request, ok := entry.Request.(*MetadataRequest); ok && request.Version != 8 {
+			t.Errorf("expected a metadata request of version 8, got %d", request.Version)
+		}
+	}
+
+	// the brokers opened before the version changed were closed, to be
+	// reopened with the config of the client
+	if err := client.RefreshMetadata(); err != nil {
+		t.Fatal(err)
+	}
+	for _, broker := range append(client.seedBrokers, client.Brokers()...) {
+		if connected, _ := broker.Connected(); connected && broker.conf.Version != V1_1_0_0 {
+			t.Errorf("broker %s is connected with version %s", broker.Addr(), broker.conf.Version)
+		}
+	}
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	deadBroker := NewMockBroker(t, 2)
+	deadAddr := deadBroker.Addr()
+	deadBroker.Close()
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(seedBroker.Addr(), seedBroker.BrokerID())
+	metadataResponse.AddBroker(deadAddr, 2)
+	metadataResponse.AddTopicPartition("my_topic", 0, 2, nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	changes := make(chan CircuitStateChange, 10)
+	config := NewTestConfig()
+	config.Net.CircuitBreaker.Failures = 2
+	config.Net.CircuitBreaker.OnStateChange = func(change CircuitStateChange) {
+		changes <- change
+	}
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	leader, err := client.Leader("my_topic", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < config.Net.CircuitBreaker.Failures; i++ {
+		if _, err := leader.GetMetadata(new(MetadataRequest)); err == nil || err == ErrCircuitOpen {
+			t.Fatal("expected the dial to fail, got", err)
+		}
+		_ = leader.Open(config)
+	}
+	if _, err := leader.GetMetadata(new(MetadataRequest)); err != ErrCircuitOpen {
+		t.Fatal("expected ErrCircuitOpen, got", err)
+	}
+
+	select {
+	case change := <-changes:
+		if change.BrokerID != 2 || change.Addr != deadAddr || change.From != CircuitClosed || change.To != CircuitOpen {
+			t.Errorf("unexpected state change %+v", change)
+		}
+	default:
+		t.Error("expected the circuit opening to be notified")
+	}
+}
+
+func TestClientAnySkipsOpenCircuits(t *testing.T) {
+	seed1 := NewMockBroker(t, 1)
+	defer seed1.Close()
+	seed2 := NewMockBroker(t, 2)
+	defer seed2.Close()
+	seed1.SetHandlerByMap(map[string]MockResponse{"MetadataRequest": NewMockMetadataResponse(t)})
+	seed2.SetHandlerByMap(map[string]MockResponse{"MetadataRequest": NewMockMetadataResponse(t)})
+
+	config := NewTestConfig()
+	config.Net.CircuitBreaker.Failures = 1
+	c, err := NewClient([]string{seed1.Addr(), seed2.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, c)
+	client := c.(*client)
+
+	first, second := client.seedBrokers[0], client.seedBrokers[1]
+	client.breaker.record(first, io.EOF)
+	if broker := client.any(); broker != second {
+		t.Errorf("expected the seed broker %s, got %s", second.Addr(), broker.Addr())
+	}
+
+	// all the circuits are open, the first seed broker fails fast
+	client.breaker.record(second, io.EOF)
+	if broker := client.any(); broker != first {
+		t.Errorf("expected the seed broker %s, got %s", first.Addr(), broker.Addr())
+	}
+}
+
+func TestClientRefreshMetadataWithOptions(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	allowed := make(chan bool, 10)
+	metadata := NewMockMetadataResponse(t).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetLeader("my_topic", 0, seedBroker.BrokerID())
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": mockResponseFunc(func(reqBody versionedDecoder) encoderWithHeader {
+			if req := reqBody.(*MetadataRequest); len(req.Topics) > 0 {
+				allowed <- req.AllowAutoTopicCreation
+			}
+			return metadata.For(reqBody)
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	config.Metadata.AllowAutoTopicCreation = false
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	allow := true
+	for _, tc := range []struct {
+		options  MetadataRefreshOptions
+		expected bool
+	}{
+		{MetadataRefreshOptions{}, false},
+		{MetadataRefreshOptions{AllowAutoTopicCreation: &allow}, true},
+	} {
+		if err := client.RefreshMetadataWithOptions(context.Background(), tc.options, "my_topic"); err != nil {
+			t.Fatal(err)
+		}
+		if got := <-allowed; got != tc.expected {
+			t.Errorf("expected AllowAutoTopicCreation %t with %+v, got %t", tc.expected, tc.options, got)
+		}
+	}
+}
+
+func TestClientLeastLoadedBroker(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	otherBroker := NewMockBroker(t, 2)
+	defer otherBroker.Close()
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(seedBroker.Addr(), seedBroker.BrokerID())
+	metadataResponse.AddBroker(otherBroker.Addr(), otherBroker.BrokerID())
+	seedBroker.Returns(metadataResponse)
+
+	config := NewTestConfig()
+	config.Net.CircuitBreaker.Failures = 1
+	c, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, c)
+	client := c.(*client)
+
+	// none is connected nor busy yet, the lowest ID wins
+	broker, err := client.LeastLoadedBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if broker.ID() != 1 {
+		t.Errorf("expected broker #1, got #%d", broker.ID())
+	}
+	if connected, err := broker.Connected(); !connected {
+		t.Fatal(err)
+	}
+
+	// the connected brokers are preferred, then the least busy ones
+	atomic.AddInt32(&broker.inFlight, 1)
+	if broker, _ := client.LeastLoadedBroker(); broker.ID() != 1 {
+		t.Errorf("expected broker #1, got #%d", broker.ID())
+	}
+	other, _ := client.Broker(2)
+	if connected, err := other.Connected(); !connected {
+		t.Fatal(err)
+	}
+	if broker, _ := client.LeastLoadedBroker(); broker != other {
+		t.Errorf("expected broker #2, got #%d", broker.ID())
+	}
+	atomic.AddInt32(&broker.inFlight, -1)
+
+	client.breaker.record(client.brokers[1], io.EOF)
+	if broker, _ := client.LeastLoadedBroker(); broker.ID() != 2 {
+		t.Errorf("expected broker #2 while the circuit of #1 is open, got #%d", broker.ID())
+	}
+	client.breaker.record(client.brokers[2], io.EOF)
+	if _, err := client.LeastLoadedBroker(); err != ErrOutOfBrokers {
+		t.Errorf("expected ErrOutOfBrokers, got %v", err)
+	}
+}
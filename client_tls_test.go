@@ -6,6 +6,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"errors"
 	"math/big"
 	"net"
 	"testing"
@@ -233,3 +234,91 @@ func TestSetServerName(t *testing.T) {
 		t.Fatal("Expected empty ServerName as the broker addr is missing the port")
 	}
 }
+
+func TestTLSCustomVerification(t *testing.T) {
+	ca := newTestCA(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	serverConfig := &tls.Config{
+		// the certificate is not valid for the address of the broker
+		Certificates: []tls.Certificate{*ca.issue(t, "kafka-1", x509.ExtKeyUsageServerAuth, "kafka-1.internal")},
+		MinVersion:   tls.VersionTLS12,
+	}
+	clientConfig := &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+
+	connect := func(t *testing.T, configure func(conf *Config)) error {
+		listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mb := NewMockBrokerListener(t, 0, listener)
+		defer mb.Close()
+		mb.SetHandlerByMap(map[string]MockResponse{
+			"MetadataRequest": NewMockMetadataResponse(t),
+		})
+
+		conf := NewTestConfig()
+		conf.Net.TLS.Enable = true
+		conf.Net.TLS.Config = clientConfig
+		configure(conf)
+		broker := NewBroker(mb.Addr())
+		if err := broker.Open(conf); err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = broker.Close() }()
+		_, err = broker.GetMetadata(&MetadataRequest{})
+		return err
+	}
+
+	t.Run("default verification", func(t *testing.T) {
+		if err := connect(t, func(conf *Config) {}); err == nil {
+			t.Error("expected the verification against the address of the broker to fail")
+		}
+	})
+
+	t.Run("server name", func(t *testing.T) {
+		var addrs []string
+		err := connect(t, func(conf *Config) {
+			conf.Net.TLS.ServerName = func(addr string) string {
+				addrs = append(addrs, addr)
+				return "kafka-1.internal"
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(addrs) != 1 {
+			t.Errorf("expected ServerName to be called once, got %v", addrs)
+		}
+	})
+
+	t.Run("verify peer certificate", func(t *testing.T) {
+		var verified []string
+		err := connect(t, func(conf *Config) {
+			conf.Net.TLS.VerifyPeerCertificate = func(addr string, certificates []*x509.Certificate) error {
+				if _, err := certificates[0].Verify(x509.VerifyOptions{Roots: pool}); err != nil {
+					return err
+				}
+				verified = append(verified, certificates[0].Subject.CommonName)
+				return nil
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(verified) != 1 || verified[0] != "kafka-1" {
+			t.Errorf("expected the certificate of kafka-1 to be verified, got %v", verified)
+		}
+	})
+
+	t.Run("rejected by verify peer certificate", func(t *testing.T) {
+		err := connect(t, func(conf *Config) {
+			conf.Net.TLS.VerifyPeerCertificate = func(addr string, certificates []*x509.Certificate) error {
+				return errors.New("unknown identity")
+			}
+		})
+		if err == nil {
+			t.Error("expected the connection to fail")
+		}
+	})
+}
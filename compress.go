@@ -4,165 +4,46 @@ import (
 	"bytes"
 	"compress/gzip"
 	"fmt"
+	"runtime"
 	"sync"
 
 	snappy "github.com/eapache/go-xerial-snappy"
 	"github.com/pierrec/lz4"
 )
 
-var (
-	lz4WriterPool = sync.Pool{
-		New: func() interface{} {
-			return lz4.NewWriter(nil)
-		},
-	}
+var lz4WriterPool = sync.Pool{
+	New: func() interface{} {
+		return lz4.NewWriter(nil)
+	},
+}
 
-	gzipWriterPool = sync.Pool{
-		New: func() interface{} {
-			return gzip.NewWriter(nil)
-		},
-	}
-	gzipWriterPoolForCompressionLevel1 = sync.Pool{
-		New: func() interface{} {
-			gz, err := gzip.NewWriterLevel(nil, 1)
-			if err != nil {
-				panic(err)
-			}
-			return gz
-		},
-	}
-	gzipWriterPoolForCompressionLevel2 = sync.Pool{
-		New: func() interface{} {
-			gz, err := gzip.NewWriterLevel(nil, 2)
-			if err != nil {
-				panic(err)
-			}
-			return gz
-		},
+// compress compresses data with the codec cc at level, with at most
+// concurrency encoders of the codec and level at once, see
+// Producer.CompressionConcurrency
+func compress(cc CompressionCodec, level, concurrency int, data []byte) ([]byte, error) {
+	if c, ok := registeredCompressorOf(cc); ok {
+		return c.compressor.Compress(level, data)
 	}
-	gzipWriterPoolForCompressionLevel3 = sync.Pool{
-		New: func() interface{} {
-			gz, err := gzip.NewWriterLevel(nil, 3)
-			if err != nil {
-				panic(err)
-			}
-			return gz
-		},
-	}
-	gzipWriterPoolForCompressionLevel4 = sync.Pool{
-		New: func() interface{} {
-			gz, err := gzip.NewWriterLevel(nil, 4)
-			if err != nil {
-				panic(err)
-			}
-			return gz
-		},
-	}
-	gzipWriterPoolForCompressionLevel5 = sync.Pool{
-		New: func() interface{} {
-			gz, err := gzip.NewWriterLevel(nil, 5)
-			if err != nil {
-				panic(err)
-			}
-			return gz
-		},
-	}
-	gzipWriterPoolForCompressionLevel6 = sync.Pool{
-		New: func() interface{} {
-			gz, err := gzip.NewWriterLevel(nil, 6)
-			if err != nil {
-				panic(err)
-			}
-			return gz
-		},
-	}
-	gzipWriterPoolForCompressionLevel7 = sync.Pool{
-		New: func() interface{} {
-			gz, err := gzip.NewWriterLevel(nil, 7)
-			if err != nil {
-				panic(err)
-			}
-			return gz
-		},
-	}
-	gzipWriterPoolForCompressionLevel8 = sync.Pool{
-		New: func() interface{} {
-			gz, err := gzip.NewWriterLevel(nil, 8)
-			if err != nil {
-				panic(err)
-			}
-			return gz
-		},
-	}
-	gzipWriterPoolForCompressionLevel9 = sync.Pool{
-		New: func() interface{} {
-			gz, err := gzip.NewWriterLevel(nil, 9)
-			if err != nil {
-				panic(err)
-			}
-			return gz
-		},
-	}
-)
 
-func compress(cc CompressionCodec, level int, data []byte) ([]byte, error) {
 	switch cc {
 	case CompressionNone:
 		return data, nil
 	case CompressionGZIP:
-		var (
-			err    error
-			buf    bytes.Buffer
-			writer *gzip.Writer
-		)
-
-		switch level {
-		case CompressionLevelDefault:
-			writer = gzipWriterPool.Get().(*gzip.Writer)
-			defer gzipWriterPool.Put(writer)
-			writer.Reset(&buf)
-		case 1:
-			writer = gzipWriterPoolForCompressionLevel1.Get().(*gzip.Writer)
-			defer gzipWriterPoolForCompressionLevel1.Put(writer)
-			writer.Reset(&buf)
-		case 2:
-			writer = gzipWriterPoolForCompressionLevel2.Get().(*gzip.Writer)
-			defer gzipWriterPoolForCompressionLevel2.Put(writer)
-			writer.Reset(&buf)
-		case 3:
-			writer = gzipWriterPoolForCompressionLevel3.Get().(*gzip.Writer)
-			defer gzipWriterPoolForCompressionLevel3.Put(writer)
-			writer.Reset(&buf)
-		case 4:
-			writer = gzipWriterPoolForCompressionLevel4.Get().(*gzip.Writer)
-			defer gzipWriterPoolForCompressionLevel4.Put(writer)
-			writer.Reset(&buf)
-		case 5:
-			writer = gzipWriterPoolForCompressionLevel5.Get().(*gzip.Writer)
-			defer gzipWriterPoolForCompressionLevel5.Put(writer)
-			writer.Reset(&buf)
-		case 6:
-			writer = gzipWriterPoolForCompressionLevel6.Get().(*gzip.Writer)
-			defer gzipWriterPoolForCompressionLevel6.Put(writer)
-			writer.Reset(&buf)
-		case 7:
-			writer = gzipWriterPoolForCompressionLevel7.Get().(*gzip.Writer)
-			defer gzipWriterPoolForCompressionLevel7.Put(writer)
-			writer.Reset(&buf)
-		case 8:
-			writer = gzipWriterPoolForCompressionLevel8.Get().(*gzip.Writer)
-			defer gzipWriterPoolForCompressionLevel8.Put(writer)
-			writer.Reset(&buf)
-		case 9:
-			writer = gzipWriterPoolForCompressionLevel9.Get().(*gzip.Writer)
-			defer gzipWriterPoolForCompressionLevel9.Put(writer)
-			writer.Reset(&buf)
-		default:
-			writer, err = gzip.NewWriterLevel(&buf, level)
-			if err != nil {
-				return nil, err
+		pool := encoderPoolOf(encoderPoolKey{cc, level, concurrency}, func() (interface{}, error) {
+			if level == CompressionLevelDefault {
+				return gzip.NewWriter(nil), nil
 			}
+			return gzip.NewWriterLevel(nil, level)
+		})
+		encoder, err := pool.get()
+		if err != nil {
+			return nil, err
 		}
+		defer pool.put(encoder)
+
+		var buf bytes.Buffer
+		writer := encoder.(*gzip.Writer)
+		writer.Reset(&buf)
 		if _, err := writer.Write(data); err != nil {
 			return nil, err
 		}
@@ -187,8 +68,65 @@ func compress(cc CompressionCodec, level int, data []byte) ([]byte, error) {
 		}
 		return buf.Bytes(), nil
 	case CompressionZSTD:
-		return zstdCompress(nil, data)
+		return zstdCompress(level, concurrency, nil, data)
 	default:
 		return nil, PacketEncodingError{fmt.Sprintf("unsupported compression codec (%d)", cc)}
 	}
 }
+
+// encoderPoolKey identifies the pool of the encoders of a codec and level
+type encoderPoolKey struct {
+	codec       CompressionCodec
+	level       int
+	concurrency int
+}
+
+// encoderPool bounds the number of encoders compressing at once, such as the
+// gzip writers of a level, and keeps them for reuse rather than creating one
+// per batch
+type encoderPool struct {
+	new  func() (interface{}, error)
+	busy chan struct{}
+	idle chan interface{}
+}
+
+var encoderPools sync.Map // of encoderPoolKey to *encoderPool
+
+// encoderPoolOf returns the pool of key, creating its encoders with new
+func encoderPoolOf(key encoderPoolKey, new func() (interface{}, error)) *encoderPool {
+	if key.concurrency <= 0 {
+		key.concurrency = runtime.GOMAXPROCS(0)
+	}
+	if pool, ok := encoderPools.Load(key); ok {
+		return pool.(*encoderPool)
+	}
+	pool, _ := encoderPools.LoadOrStore(key, &encoderPool{
+		new:  new,
+		busy: make(chan struct{}, key.concurrency),
+		idle: make(chan interface{}, key.concurrency),
+	})
+	return pool.(*encoderPool)
+}
+
+// get returns an idle encoder, or a new one, waiting until fewer than the
+// concurrency of the pool are in use
+func (p *encoderPool) get() (interface{}, error) {
+	p.busy <- struct{}{}
+	select {
+	case encoder := <-p.idle:
+		return encoder, nil
+	default:
+	}
+	encoder, err := p.new()
+	if err != nil {
+		<-p.busy
+		return nil, err
+	}
+	return encoder, nil
+}
+
+// put returns an encoder got from the pool for reuse
+func (p *encoderPool) put(encoder interface{}) {
+	p.idle <- encoder
+	<-p.busy
+}
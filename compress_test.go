@@ -0,0 +1,82 @@
+package sarama
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEncoderPoolConcurrency(t *testing.T) {
+	var created int32
+	pool := encoderPoolOf(encoderPoolKey{CompressionCodec(-1), 1, 2}, func() (interface{}, error) {
+		return atomic.AddInt32(&created, 1), nil
+	})
+	if encoderPoolOf(encoderPoolKey{CompressionCodec(-1), 1, 2}, nil) != pool {
+		t.Fatal("Expected the pool of the same codec, level and concurrency to be shared")
+	}
+
+	first, _ := pool.get()
+	second, _ := pool.get()
+	got := make(chan interface{})
+	go func() {
+		third, _ := pool.get()
+		got <- third
+	}()
+	select {
+	case <-got:
+		t.Fatal("Expected the encoders over the concurrency to wait")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	pool.put(first)
+	if third := <-got; third != first {
+		t.Errorf("Expected the encoder to be reused, got %v", third)
+	}
+	pool.put(second)
+	if created != 2 {
+		t.Errorf("Expected 2 encoders to be created, got %d", created)
+	}
+}
+
+func TestCompressPooledEncoders(t *testing.T) {
+	data := bytes.Repeat([]byte("sarama compression "), 1000)
+	for _, tc := range []struct {
+		codec CompressionCodec
+		level int
+	}{
+		{CompressionGZIP, CompressionLevelDefault},
+		{CompressionGZIP, 1},
+		{CompressionGZIP, 0},
+		{CompressionZSTD, CompressionLevelDefault},
+		{CompressionZSTD, 1},
+		{CompressionZSTD, 19},
+	} {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				compressed, err := compress(tc.codec, tc.level, 2, data)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				decompressed, err := decompress(tc.codec, compressed)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !bytes.Equal(decompressed, data) {
+					t.Errorf("Expected %s at level %d to round trip", tc.codec, tc.level)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	if _, err := compress(CompressionGZIP, 42, 2, data); err == nil {
+		t.Error("Expected an invalid gzip level to fail")
+	}
+}
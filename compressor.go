@@ -0,0 +1,82 @@
+package sarama
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Compressor compresses and decompresses the records of a CompressionCodec
+// registered with RegisterCompressor.
+type Compressor interface {
+	// Compress returns the compressed data. The level is the one of
+	// Producer.CompressionLevel, CompressionLevelDefault unless set.
+	Compress(level int, data []byte) ([]byte, error)
+	// Decompress returns the decompressed data.
+	Decompress(data []byte) ([]byte, error)
+}
+
+type registeredCompressor struct {
+	name       string
+	compressor Compressor
+}
+
+var (
+	compressorsLock sync.RWMutex
+	compressors     = make(map[CompressionCodec]registeredCompressor)
+)
+
+// RegisterCompressor makes the compressor compress and decompress the records
+// of the codec cc, such as a hardware accelerated implementation of gzip. A
+// built in codec is replaced by registering it with its name, while a custom
+// codec takes one of the codecs not used by Kafka (5 to 7) and a new name,
+// which can then be selected with Producer.Compression or the
+// compression.type property. The brokers and the other clients must support
+// custom codecs. It panics if the codec does not fit in the attributes of the
+// records, or if it is already registered.
+func RegisterCompressor(cc CompressionCodec, name string, compressor Compressor) {
+	if compressor == nil {
+		panic("sarama: RegisterCompressor compressor is nil")
+	}
+	switch {
+	case cc <= CompressionNone || int8(cc) > compressionCodecMask:
+		panic(fmt.Sprintf("sarama: compression codec %d can not be registered", cc))
+	case cc <= CompressionZSTD && name != cc.String():
+		panic(fmt.Sprintf("sarama: compression codec %d is built in as %s", cc, cc))
+	case name == "":
+		panic("sarama: RegisterCompressor name is empty")
+	}
+	for builtin := CompressionNone; builtin <= CompressionZSTD; builtin++ {
+		if cc != builtin && name == builtin.String() {
+			panic(fmt.Sprintf("sarama: compression codec %s is built in as %d", name, builtin))
+		}
+	}
+
+	compressorsLock.Lock()
+	defer compressorsLock.Unlock()
+	for registered, c := range compressors {
+		if registered == cc || c.name == name {
+			panic(fmt.Sprintf("sarama: RegisterCompressor called twice for %s", name))
+		}
+	}
+	compressors[cc] = registeredCompressor{name: name, compressor: compressor}
+}
+
+// registeredCompressorOf returns the compressor registered for a codec
+func registeredCompressorOf(cc CompressionCodec) (registeredCompressor, bool) {
+	compressorsLock.RLock()
+	defer compressorsLock.RUnlock()
+	c, ok := compressors[cc]
+	return c, ok
+}
+
+// registeredCompressionCodec returns the custom codec registered with a name
+func registeredCompressionCodec(name string) (CompressionCodec, bool) {
+	compressorsLock.RLock()
+	defer compressorsLock.RUnlock()
+	for cc, c := range compressors {
+		if c.name == name {
+			return cc, true
+		}
+	}
+	return CompressionNone, false
+}
@@ -0,0 +1,138 @@
+package sarama
+
+import (
+	"bytes"
+	"testing"
+)
+
+// xorCompressor "compresses" by flipping the bits of the data
+type xorCompressor struct {
+	levels []int
+}
+
+func (c *xorCompressor) Compress(level int, data []byte) ([]byte, error) {
+	c.levels = append(c.levels, level)
+	return c.Decompress(data)
+}
+
+func (c *xorCompressor) Decompress(data []byte) ([]byte, error) {
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[i] = b ^ 0xff
+	}
+	return out, nil
+}
+
+// registerTestCompressor registers a compressor until the end of the test
+func registerTestCompressor(t *testing.T, cc CompressionCodec, name string, compressor Compressor) {
+	RegisterCompressor(cc, name, compressor)
+	t.Cleanup(func() {
+		compressorsLock.Lock()
+		defer compressorsLock.Unlock()
+		delete(compressors, cc)
+	})
+}
+
+func TestRegisterCompressor(t *testing.T) {
+	const codec = CompressionCodec(5)
+	compressor := new(xorCompressor)
+	registerTestCompressor(t, codec, "xor", compressor)
+
+	if codec.String() != "xor" {
+		t.Errorf("Expected the name of the codec to be xor, got %s", codec)
+	}
+
+	batch := &RecordBatch{
+		Version:          2,
+		Codec:            codec,
+		CompressionLevel: 3,
+		Records:          []*Record{{Value: []byte("value")}},
+	}
+	buf, err := encode(batch, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buf, []byte("value")) {
+		t.Error("Expected the records to be compressed")
+	}
+	if len(compressor.levels) != 1 || compressor.levels[0] != 3 {
+		t.Errorf("Expected the records to be compressed at level 3, got %v", compressor.levels)
+	}
+
+	decoded := new(RecordBatch)
+	if err := decode(buf, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Codec != codec || len(decoded.Records) != 1 || string(decoded.Records[0].Value) != "value" {
+		t.Errorf("Unexpected decoded batch %+v", decoded)
+	}
+
+	config, _, err := NewConfigFromMap(map[string]string{"compression.type": "xor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Producer.Compression != codec {
+		t.Errorf("Expected the compression.type property to select the codec, got %s", config.Producer.Compression)
+	}
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+	config.Producer.Compression = 6
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an unregistered codec to be invalid")
+	}
+}
+
+func TestRegisterCompressorReplacesBuiltIn(t *testing.T) {
+	compressor := new(xorCompressor)
+	registerTestCompressor(t, CompressionGZIP, "gzip", compressor)
+
+	compressed, err := compress(CompressionGZIP, CompressionLevelDefault, 0, []byte("value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressor.levels) != 1 {
+		t.Error("Expected gzip to be compressed by the registered compressor")
+	}
+	decompressed, err := decompress(CompressionGZIP, compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decompressed) != "value" {
+		t.Errorf("Expected the value to be decompressed, got %q", decompressed)
+	}
+
+	// the level is left to the registered compressor
+	config := NewTestConfig()
+	config.Producer.Compression = CompressionGZIP
+	config.Producer.CompressionLevel = 42
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRegisterCompressorInvalid(t *testing.T) {
+	registerTestCompressor(t, 7, "twice", new(xorCompressor))
+
+	for _, tc := range []struct {
+		cc   CompressionCodec
+		name string
+	}{
+		{CompressionNone, "none"},
+		{8, "eight"},
+		{CompressionSnappy, "fast"},
+		{5, "lz4"},
+		{5, ""},
+		{7, "seven"},
+		{5, "twice"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected registering %d as %q to panic", tc.cc, tc.name)
+				}
+			}()
+			RegisterCompressor(tc.cc, tc.name, new(xorCompressor))
+		}()
+	}
+}
@@ -2,11 +2,15 @@ package sarama
 
 import (
 	"compress/gzip"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"net"
 	"regexp"
+	"runtime"
+	"strings"
 	"time"
 
 	"github.com/rcrowley/go-metrics"
@@ -15,8 +19,22 @@ import (
 
 const defaultClientID = "sarama"
 
+// ProxyType is the protocol of the proxy configured by Config.Net.Proxy.
+type ProxyType string
+
+const (
+	// ProxyTypeSOCKS5 connects through a SOCKS5 proxy
+	ProxyTypeSOCKS5 ProxyType = "socks5"
+	// ProxyTypeHTTP connects through an HTTP proxy with the CONNECT method
+	ProxyTypeHTTP ProxyType = "http"
+)
+
 var validID = regexp.MustCompile(`\A[A-Za-z0-9._-]+\z`)
 
+// validClientSoftware matches the client software names and versions accepted
+// by the brokers
+var validClientSoftware = regexp.MustCompile(`\A[a-zA-Z0-9](?:[a-zA-Z0-9\-.]*[a-zA-Z0-9])?\z`)
+
 // Config is used to pass multiple configuration options to Sarama's constructors.
 type Config struct {
 	// Admin is the namespace for ClusterAdmin properties used by the administrative Kafka client.
@@ -31,6 +49,18 @@ type Config struct {
 		// The maximum duration the administrative Kafka client will wait for ClusterAdmin operations,
 		// including topics, brokers, configurations and ACLs (defaults to 3 seconds).
 		Timeout time.Duration
+
+		Reassignment struct {
+			// How frequently WaitForReassignment polls the controller for the
+			// progress of the ongoing partition reassignments (default 1s).
+			PollInterval time.Duration
+		}
+
+		// Whether CreateTopic and CreatePartitions compute a balanced, rack-aware
+		// replica assignment from the brokers in the metadata when none is given,
+		// instead of letting the controller assign the replicas (default false).
+		// See AssignReplicas.
+		RackAwareAssignment bool
 	}
 
 	// Net is the namespace for network-level properties used by the Broker, and
@@ -39,6 +69,32 @@ type Config struct {
 		// How many outstanding requests a connection is allowed to have before
 		// sending on it blocks (default 5).
 		MaxOpenRequests int
+		// MaxOpenRequestsByKey limits the outstanding requests of the given API
+		// keys a connection is allowed to have, within MaxOpenRequests, so that
+		// for instance {1: 1} keeps a single fetch in flight while the other
+		// requests are still pipelined (defaults to nil).
+		MaxOpenRequestsByKey map[int16]int
+		// PrioritizeRequests makes the requests other than produce and fetch
+		// ones waiting for one of the MaxOpenRequests of a connection to be
+		// sent first, so that a queue of large fetches or produces cannot hold
+		// up heartbeats and metadata refreshes sharing the connection. The
+		// broker still handles the requests sent on a connection in order
+		// (default false).
+		PrioritizeRequests bool
+
+		// ConnectionsPerBroker is the number of connections opened to each
+		// broker, so that slow requests such as fetches do not delay the
+		// others (default 1). The additional connections are opened when a
+		// request is first routed to them by ConnectionRouter.
+		ConnectionsPerBroker int
+		// ConnectionRouter returns the index, between 0 and connections-1, of
+		// the connection to send requests with the given API key on when
+		// ConnectionsPerBroker is larger than 1 (defaults to
+		// DefaultConnectionRouter). Requests are only guaranteed to be handled
+		// by the broker in the order they are sent on the same connection, so
+		// all the produce requests of an idempotent producer must use the same
+		// connection.
+		ConnectionRouter func(apiKey int16, connections int) int
 
 		// All three of the below configurations are similar to the
 		// `socket.timeout.ms` setting in JVM kafka. All of them default
@@ -54,8 +110,43 @@ type Config struct {
 			// The TLS configuration to use for secure connections if
 			// enabled (defaults to nil).
 			Config *tls.Config
+			// CertificateProvider provides the client certificate, taking
+			// precedence over Config.Certificates, so that certificates
+			// issued by short-lived CAs can be rotated without recreating
+			// the clients. New connections use the current certificate and
+			// the connections using a rotated one are closed and opened again
+			// before their next request, once their in-flight requests are
+			// answered (defaults to nil).
+			CertificateProvider CertificateProvider
+			// ServerName returns the name used to verify the certificate of
+			// the broker at the given address, instead of its host name or
+			// Config.ServerName, for example when the brokers are reached
+			// through addresses not in their certificates. An empty name
+			// keeps the default (defaults to nil).
+			ServerName func(addr string) string
+			// VerifyPeerCertificate replaces the verification of the broker
+			// certificates, for deployments whose certificates cannot be
+			// verified against the broker host names such as SAN-less
+			// internal certificates or SPIFFE identities. It is called with
+			// the address of the broker and the certificates it presented,
+			// the first one being the leaf, and the connection fails if it
+			// returns an error. The certificates are not verified otherwise:
+			// the function must check the chain itself, for example with
+			// x509.Certificate.Verify (defaults to nil).
+			VerifyPeerCertificate func(addr string, certificates []*x509.Certificate) error
 		}
 
+		// FIPS restricts the connections to FIPS 140 approved cryptography,
+		// for deployments under FedRAMP requirements. TLS must be enabled and
+		// is limited to TLS 1.2 with the ECDHE and AES-GCM cipher suites over
+		// the NIST curves, as the TLS 1.3 cipher suites cannot be chosen, and
+		// SASL to the PLAIN, SCRAM-SHA-256, SCRAM-SHA-512, OAUTHBEARER and
+		// AWS_MSK_IAM mechanisms. Validate fails when Net.TLS.Config,
+		// Net.Proxy.TLS.Config or Net.SASL.Mechanism use anything else. It
+		// does not make the Go cryptography a validated module, which takes a
+		// FIPS build of the Go toolchain (defaults to false).
+		FIPS bool
+
 		// SASL based authentication with broker. While there are multiple SASL authentication methods
 		// the current implementation is limited to plaintext (SASL/PLAIN) authentication
 		SASL struct {
@@ -63,7 +154,9 @@ type Config struct {
 			// (defaults to false).
 			Enable bool
 			// SASLMechanism is the name of the enabled SASL mechanism.
-			// Possible values: OAUTHBEARER, PLAIN (defaults to PLAIN).
+			// Possible values: OAUTHBEARER, PLAIN, SCRAM-SHA-256, SCRAM-SHA-512,
+			// GSSAPI, AWS_MSK_IAM or a mechanism registered with
+			// RegisterSASLMechanism (defaults to PLAIN).
 			Mechanism SASLMechanism
 			// Version is the SASL Protocol Version to use
 			// Kafka > 1.x should use V1, except on Azure EventHub which use V0
@@ -85,15 +178,21 @@ type Config struct {
 			// authz id used for SASL/SCRAM authentication
 			SCRAMAuthzID string
 			// SCRAMClientGeneratorFunc is a generator of a user provided implementation of a SCRAM
-			// client used to perform the SCRAM exchange with the server.
+			// client used to perform the SCRAM exchange with the server (defaults to the
+			// built-in client of NewSCRAMClient).
 			SCRAMClientGeneratorFunc func() SCRAMClient
 			// TokenProvider is a user-defined callback for generating
 			// access tokens for SASL/OAUTHBEARER auth. See the
 			// AccessTokenProvider interface docs for proper implementation
-			// guidelines.
+			// guidelines, or use NewOIDCTokenProvider to get the tokens from
+			// an OIDC provider.
 			TokenProvider AccessTokenProvider
 
 			GSSAPI GSSAPIConfig
+
+			// AWSMSKIAM configures the AWS_MSK_IAM mechanism of Amazon MSK,
+			// which requires the brokers to be addressed by their host names.
+			AWSMSKIAM AWSMSKIAMConfig
 		}
 
 		// KeepAlive specifies the keep-alive period for an active network connection (defaults to 0).
@@ -101,6 +200,28 @@ type Config struct {
 		// If negative, keep-alives are disabled.
 		KeepAlive time.Duration
 
+		// Socket tunes the TCP connections opened to the brokers by the
+		// dialer, directly or to the proxy, but not those opened by DialFn.
+		// The zero values keep the defaults of the operating system.
+		Socket struct {
+			// SendBuffer and ReceiveBuffer are the sizes in bytes of the send
+			// and receive buffers of the socket, SO_SNDBUF and SO_RCVBUF.
+			SendBuffer    int
+			ReceiveBuffer int
+			// KeepAliveInterval is the time between the keep-alive probes sent
+			// once the connection was idle for KeepAlive, TCP_KEEPINTVL,
+			// rounded up to the second, and KeepAliveCount the number of
+			// unanswered probes after which it is closed, TCP_KEEPCNT. They
+			// are only supported on Linux.
+			KeepAliveInterval time.Duration
+			KeepAliveCount    int
+			// UserTimeout is how long the data sent may stay unacknowledged
+			// before the connection is closed, TCP_USER_TIMEOUT, so that dead
+			// connections are detected without waiting for the keep-alives
+			// to give up. It is only supported on Linux.
+			UserTimeout time.Duration
+		}
+
 		// LocalAddr is the local address to use when dialing an
 		// address. The address must be of a compatible type for the
 		// network being dialed.
@@ -113,6 +234,87 @@ type Config struct {
 			Enable bool
 			// The proxy dialer to use enabled (defaults to nil).
 			Dialer proxy.Dialer
+			// Type is the protocol of the proxy at Address, ProxyTypeSOCKS5 or
+			// ProxyTypeHTTP (defaults to ProxyTypeSOCKS5).
+			Type ProxyType
+			// Address is the host:port of a proxy to connect to every broker
+			// through when enabled, used if Dialer is nil. The proxy is dialed
+			// as configured by DialTimeout, KeepAlive and LocalAddr.
+			Address string
+			// User and Password authenticate with the proxy at Address when
+			// User is not empty.
+			User     string
+			Password string
+			// TLS secures the connection to an HTTP proxy, independently of the
+			// TLS connection to the broker tunneled through it.
+			TLS struct {
+				// Whether or not to use TLS when connecting to the proxy
+				// (defaults to false).
+				Enable bool
+				// The TLS configuration to use to connect to the proxy if
+				// enabled (defaults to nil).
+				Config *tls.Config
+			}
+		}
+
+		// DialFn, if set, opens all the connections to the brokers instead of
+		// the dialer configured by DialTimeout, KeepAlive and LocalAddr, for
+		// instance to route them through a tunnel or an in-memory transport.
+		// The DialContext method of a proxy.ContextDialer can be used as is.
+		// The context is done once DialTimeout expires. It cannot be used
+		// together with Proxy (defaults to nil).
+		DialFn func(ctx context.Context, network, address string) (net.Conn, error)
+
+		// AddressResolver, if set, rewrites the host:port addresses of the
+		// brokers returned by the cluster in Metadata and FindCoordinator
+		// responses before they are connected to, for when the advertised
+		// listeners are not reachable as is, behind NAT, a port-forward into
+		// Kubernetes or an SSH tunnel. It must return the address unchanged to
+		// keep it. The seed broker addresses are used as is (defaults to nil).
+		AddressResolver func(broker string) string
+
+		// OnConnect, if set, is called with each connection dialed to a
+		// broker, before the TLS handshake, and the connection it returns is
+		// used instead. It lets the connections be wrapped, for instance to
+		// count the bytes, trace the latency of the reads and writes, or
+		// inject faults in tests. The brokerID is -1 for the seed brokers. It
+		// must return conn itself to leave it as is (defaults to nil).
+		OnConnect func(conn net.Conn, brokerID int32) net.Conn
+
+		// CircuitBreaker stops the client from routing new requests to a
+		// broker that keeps failing, instead of retrying it in a tight loop.
+		CircuitBreaker struct {
+			// Failures is the number of consecutive failures to reach a
+			// broker, or to get a response from it, after which its circuit
+			// opens: requests sent to it fail with ErrCircuitOpen, and the
+			// other brokers are used to fetch metadata, or the leader when the
+			// broker is the preferred read replica of a partition consumer
+			// (defaults to 0, disabled).
+			Failures int
+			// Cooldown is how long the circuit stays open before a single
+			// request is let through to probe the broker again, closing the
+			// circuit if it succeeds (defaults to 30s).
+			Cooldown time.Duration
+			// OnStateChange, if set, is called when the circuit of a broker
+			// opens, half-opens to probe it, or closes (defaults to nil).
+			OnStateChange func(change CircuitStateChange)
+		}
+
+		// ProtocolTrace writes a summary of each request sent and response
+		// received to the loggers, at LogLevelInfo with LogComponentNetwork:
+		// the API key and version, the correlation ID, the sizes, the
+		// latency, the throttle time and the error. It helps debugging the
+		// version negotiation and the quotas.
+		ProtocolTrace struct {
+			// Enable traces the requests (default false).
+			Enable bool
+			// Brokers, if not empty, restricts the trace to the requests
+			// sent to the brokers with these IDs, -1 for the seed brokers.
+			Brokers []int32
+			// Topics, if not empty, restricts the trace to the requests
+			// concerning these topics: produce, fetch, metadata, list
+			// offsets, offset commit and offset fetch requests.
+			Topics []string
 		}
 	}
 
@@ -130,6 +332,10 @@ type Config struct {
 			// more sophisticated backoff strategies. This takes precedence over
 			// `Backoff` if set.
 			BackoffFunc func(retries, maxRetries int) time.Duration
+			// Policy computes the backoff before each retry, for example with
+			// NewExponentialBackoff. This takes precedence over `BackoffFunc`
+			// and `Backoff` if set.
+			Policy BackoffPolicy
 		}
 		// How frequently to refresh the cluster metadata in the background.
 		// Defaults to 10 minutes. Set to 0 to disable. Similar to
@@ -151,8 +357,25 @@ type Config struct {
 
 		// Whether to allow auto-create topics in metadata refresh. If set to true,
 		// the broker may auto-create topics that we requested which do not already exist,
-		// if it is configured to do so (`auto.create.topics.enable` is true). Defaults to true.
+		// if it is configured to do so (`auto.create.topics.enable` is true). It is only
+		// sent with Version V1_0_0_0 or higher (MetadataRequest v4+), the brokers
+		// auto-create the topics of older requests regardless. It can be overridden per call
+		// with Client.RefreshMetadataWithOptions. Defaults to true.
 		AllowAutoTopicCreation bool
+
+		// Whether to resolve the host names of the broker addresses given to
+		// the client again once none of the known brokers can be reached,
+		// replacing the seed brokers by one broker per resolved address and
+		// forgetting the brokers learnt from metadata. This lets clients
+		// recover when a cluster is replaced behind a stable DNS name
+		// (default false).
+		ReResolveBootstrap bool
+
+		// Called with each change of the leader of a partition whose metadata
+		// was already known, once the refreshed metadata is stored. Together
+		// with Client.RefreshMetadataFor this lets applications react to
+		// leadership changes. It must not block (default nil).
+		OnLeaderChange func(change LeaderChange)
 	}
 
 	// Producer is the namespace for configuration related to producing messages,
@@ -178,6 +401,12 @@ type Config struct {
 		// on the actual compression type used and defaults to default compression
 		// level for the codec.
 		CompressionLevel int
+		// The maximum number of batches compressed at once with gzip or zstd
+		// at a given level (defaults to GOMAXPROCS). Their encoders are kept
+		// for reuse rather than created per batch, and the batches over the
+		// limit wait for one to be available, bounding the CPU and memory
+		// spent on compression at high produce rates.
+		CompressionConcurrency int
 		// Generates partitioners for choosing the partition to send messages to
 		// (defaults to hashing the message key). Similar to the `partitioner.class`
 		// setting for the JVM producer.
@@ -233,6 +462,10 @@ type Config struct {
 			// more sophisticated backoff strategies. This takes precedence over
 			// `Backoff` if set.
 			BackoffFunc func(retries, maxRetries int) time.Duration
+			// Policy computes the backoff before each retry, for example with
+			// NewExponentialBackoff. This takes precedence over `BackoffFunc`
+			// and `Backoff` if set.
+			Policy BackoffPolicy
 		}
 
 		// Interceptors to be called when the producer dispatcher reads the
@@ -242,6 +475,19 @@ type Config struct {
 		// OnSend() is passed to the second interceptor OnSend(), and so on in
 		// the interceptor chain.
 		Interceptors []ProducerInterceptor
+
+		// KeySerializer and ValueSerializer serialize the keys and the values
+		// of the messages after the Interceptors, such as in the format of a
+		// schema registry, replacing them with the ByteEncoder of their
+		// serialization (default nil, the Encoders are used as is).
+		KeySerializer   Serializer
+		ValueSerializer Serializer
+
+		// TopicOverrides overrides MaxMessageBytes, RequiredAcks and Flush for
+		// the messages of specific topics. As these settings apply to whole
+		// produce requests, the messages of each topic with overrides are sent
+		// in requests of their own (default nil).
+		TopicOverrides map[string]ProducerTopicOverrides
 	}
 
 	// Consumer is the namespace for configuration related to consuming messages,
@@ -250,6 +496,17 @@ type Config struct {
 
 		// Group is the namespace for configuring consumer group.
 		Group struct {
+			// Protocol is the group rebalance protocol to use (default GroupProtocolClassic).
+			// GroupProtocolConsumer selects the next generation protocol of KIP-848, where
+			// partitions are assigned by the group coordinator and Rebalance.Strategy is
+			// not used. It requires Version >= V4_0_0_0 and falls back to the classic
+			// protocol when the coordinator does not support it.
+			Protocol ConsumerGroupProtocol
+			// ServerAssignor is the name of the assignor the group coordinator
+			// should use with GroupProtocolConsumer, e.g. "uniform" or "range".
+			// Leave empty to use the default assignor of the broker.
+			ServerAssignor string
+
 			Session struct {
 				// The timeout used to detect consumer failures when using Kafka's group management facility.
 				// The consumer sends periodic heartbeats to indicate its liveness to the broker.
@@ -267,7 +524,25 @@ type Config struct {
 				// higher than 1/3 of that value.
 				// It can be adjusted even lower to control the expected time for normal rebalances (default 3s)
 				Interval time.Duration
+				// DedicatedConnection sends the heartbeats over a connection to the
+				// coordinator of their own, so that they are not delayed by offset
+				// commits and other requests sharing the coordinator connection
+				// (default false).
+				DedicatedConnection bool
+				// Timeout is used as the dial, read and write timeout of the
+				// dedicated heartbeat connection instead of the Net timeouts. Only
+				// used with DedicatedConnection (default 0: use the Net timeouts).
+				Timeout time.Duration
 			}
+			// MaxPollInterval is the maximum time a message of a claim may wait
+			// to be read by ConsumeClaim, without any offset of the claim being
+			// marked meanwhile. When exceeded, the handler is considered stuck:
+			// the member stops heartbeating, leaves the group so that its
+			// partitions can be reassigned and reports a
+			// *MaxPollIntervalExceededError. Similar to `max.poll.interval.ms`
+			// in the JVM consumer (default 0: disabled).
+			MaxPollInterval time.Duration
+
 			Rebalance struct {
 				// Strategy for allocating topic partitions to members (default BalanceStrategyRange)
 				Strategy BalanceStrategy
@@ -285,6 +560,9 @@ type Config struct {
 					Max int
 					// Backoff time between retries during rebalance (default 2s)
 					Backoff time.Duration
+					// Policy computes the backoff before each retry during
+					// rebalance. This takes precedence over `Backoff` if set.
+					Policy BackoffPolicy
 				}
 			}
 			Member struct {
@@ -293,6 +571,20 @@ type Config struct {
 				// coordinator for the group.
 				UserData []byte
 			}
+			// ResolveOffset, if set, is called for each claimed partition with the
+			// offset the group would resume from, which is the committed offset or
+			// Consumer.Offsets.Initial when none was committed. The claim starts
+			// from the returned offset instead, which may also be OffsetOldest or
+			// OffsetNewest. This allows resuming from offsets stored outside
+			// Kafka, e.g. alongside the processed data. When it returns an error,
+			// the partition is not consumed, the error is reported as a
+			// *ConsumerError and the session ends (default nil).
+			ResolveOffset func(topic string, partition int32, committed int64) (int64, error)
+			// Middlewares wrap the handler given to ConsumerGroup.Consume, to
+			// implement cross-cutting concerns such as metrics, tracing or
+			// recovering from panics once for all handlers. The first
+			// middleware is the outermost one.
+			Middlewares []ConsumerGroupHandlerMiddleware
 		}
 
 		Retry struct {
@@ -303,6 +595,11 @@ type Config struct {
 			// more sophisticated backoff strategies. This takes precedence over
 			// `Backoff` if set.
 			BackoffFunc func(retries int) time.Duration
+			// Policy computes the backoff before each retry, for example with
+			// NewExponentialBackoff. Partitions are retried indefinitely so it
+			// is called with a maxRetries of 0. This takes precedence over
+			// `BackoffFunc` and `Backoff` if set.
+			Policy BackoffPolicy
 		}
 
 		// Fetch is the namespace for controlling how many bytes are retrieved by any
@@ -326,6 +623,15 @@ type Config struct {
 			// (no limit). Similar to the JVM's `fetch.message.max.bytes`. The
 			// global `sarama.MaxResponseSize` still applies.
 			Max int32
+			// If enabled, the fetch responses are read into buffers taken
+			// from a pool, rather than allocated for every response, and
+			// the Key, Value and Headers of the messages reference them. A
+			// buffer is returned to the pool once all the messages decoded
+			// from it are released with ConsumerMessage.Release, after
+			// which they must not be used anymore. The buffers of the
+			// messages which are not released are left to the garbage
+			// collector (default disabled).
+			PooledBuffers bool
 		}
 		// The maximum amount of time the broker will wait for Consumer.Fetch.Min
 		// bytes to become available before it returns fewer than that anyways. The
@@ -381,6 +687,20 @@ type Config struct {
 				Interval time.Duration
 			}
 
+			// Batch caps the OffsetCommitRequests. The offsets marked on all
+			// the partitions are committed together, in as few requests as
+			// the caps allow, and the commits requested while one is in
+			// flight are coalesced into the next one.
+			Batch struct {
+				// The maximum number of partitions of a request
+				// (default 0, unlimited).
+				MaxPartitions int
+				// The approximate maximum size of a request in bytes
+				// (default 0, unlimited). A partition whose metadata
+				// exceeds it is sent in a request of its own.
+				MaxBytes int
+			}
+
 			// The initial offset to use if no offset was previously committed.
 			// Should be OffsetNewest or OffsetOldest. Defaults to OffsetNewest.
 			Initial int64
@@ -397,6 +717,44 @@ type Config struct {
 				// The total number of times to retry failing commit
 				// requests during OffsetManager shutdown (default 3).
 				Max int
+				// The total number of times to retry the other failing
+				// commits, those of AutoCommit and of the Commit methods of
+				// the OffsetManager, before giving up until the next one
+				// (default 0).
+				CommitMax int
+				// How long to wait between the retries of CommitMax
+				// (default 100ms).
+				Backoff time.Duration
+				// Policy computes the backoff before each retry of
+				// CommitMax, for example with NewExponentialBackoff. This
+				// takes precedence over `Backoff` if set.
+				Policy BackoffPolicy
+			}
+
+			// ErrorHandler, if set, is called once a commit failed to
+			// commit the offset of some partitions, its retries included,
+			// so that the application can detect persistent failures, such
+			// as a revoked ACL or a lost coordinator, rather than
+			// re-processing the messages consumed since the last
+			// successful commit after a restart. It is called from the
+			// goroutine committing the offsets, which it must not block.
+			ErrorHandler func(failure *OffsetCommitFailure)
+
+			// Store, if set, stores the offsets outside Kafka rather than
+			// committing them to the group coordinator, see OffsetStore
+			// (default nil).
+			Store OffsetStore
+
+			// OutOfRange configures what a consumer group does when the
+			// committed offset of a partition is no longer available on the
+			// broker, e.g. because the log was truncated or the messages were
+			// removed by retention.
+			OutOfRange struct {
+				// Policy applies to every topic not listed in Topics
+				// (default OffsetOutOfRangeResetInitial).
+				Policy OffsetOutOfRangePolicy
+				// Topics overrides Policy for specific topics.
+				Topics map[string]OffsetOutOfRangePolicy
 			}
 		}
 
@@ -412,12 +770,32 @@ type Config struct {
 		// passed to the second interceptor OnConsume(), and so on in the
 		// interceptor chain.
 		Interceptors []ConsumerInterceptor
+
+		// KeyDeserializer and ValueDeserializer deserialize the keys and the
+		// values of the messages, before the Interceptors, in the
+		// DeserializedKey and DeserializedValue of the ConsumerMessages. The
+		// messages failing to deserialize are returned without them, and the
+		// error is returned on the Errors channel (default nil).
+		KeyDeserializer   Deserializer
+		ValueDeserializer Deserializer
+
+		// TopicOverrides overrides Fetch and IsolationLevel for the partitions
+		// of specific topics. As Fetch.Min and IsolationLevel apply to whole
+		// fetch requests, the partitions of each topic with overrides are
+		// fetched in requests of their own (default nil).
+		TopicOverrides map[string]ConsumerTopicOverrides
 	}
 
 	// A user-provided string sent with every request to the brokers for logging,
 	// debugging, and auditing purposes. Defaults to "sarama", but you should
 	// probably set it to something specific to your application.
 	ClientID string
+	// ClientSoftwareName and ClientSoftwareVersion identify the client
+	// software to the brokers from Kafka 2.4, which report them in their
+	// client metrics (KIP-511). They default to "sarama" and the version of
+	// its module, and may only contain letters, digits, '.' and '-'.
+	ClientSoftwareName    string
+	ClientSoftwareVersion string
 	// A rack identifier for this client. This can be any string value which
 	// indicates where this client is physically located.
 	// It corresponds with the broker config 'broker.rack'
@@ -434,12 +812,58 @@ type Config struct {
 	// latest features. Setting it to a version greater than you are actually
 	// running may lead to random breakage.
 	Version KafkaVersion
+	// Whether the Client replaces Version by the version of the cluster when
+	// it is created, which is the oldest of the versions recognized from the
+	// ApiVersions responses of the brokers. This makes Sarama use the highest
+	// request versions supported by the whole cluster without having to
+	// maintain Version. Requires Kafka 0.10 or higher, Version is kept when no
+	// broker answers (default false).
+	DiscoverVersion bool
 	// The registry to define metrics into.
 	// Defaults to a local registry.
 	// If you want to disable metrics gathering, set "metrics.UseNilMetrics" to "true"
 	// prior to starting Sarama.
 	// See Examples on how to use the metrics registry
 	MetricRegistry metrics.Registry
+	// MetricsRecorder, if set, receives the metrics instead of
+	// MetricRegistry, e.g. to emit them to Prometheus or OpenTelemetry with
+	// labels and native histograms (defaults to nil: those of
+	// NewGoMetricsRecorder(MetricRegistry)).
+	MetricsRecorder MetricsRecorder
+
+	// Telemetry is the namespace for pushing the metrics of the clients to
+	// the brokers (KIP-714), so that the operators of the cluster can monitor
+	// them centrally.
+	Telemetry struct {
+		// Enable makes the Client push the metrics of MetricRegistry covering
+		// the whole client to the brokers enabling the client metrics
+		// collection, at the interval and for the metrics requested by their
+		// subscription, and a last time when it is closed. Requires Kafka
+		// 3.7 or higher (default false).
+		Enable bool
+	}
+
+	// Tracing is the namespace for the spans of the produced and consumed
+	// messages and of the requests, for OpenTelemetry or a similar tracing
+	// system.
+	Tracing struct {
+		// Tracer, if set, starts a span for each produced message, from its
+		// input to its success or failure, and injects its context in the
+		// headers with Kafka 0.11 and higher, e.g. as a W3C traceparent
+		// header. Each consumed message gets a span as well, child of the
+		// context extracted from its headers and set as
+		// ConsumerMessage.Context (defaults to nil).
+		Tracer Tracer
+		// Requests makes Tracer start a span for each request sent to the
+		// brokers as well (default false).
+		Requests bool
+	}
+
+	// EventHandler, if set, receives the state transitions of the clients:
+	// the connections to the brokers, the metadata and coordinator updates
+	// and the rebalances of the consumer groups, for dashboards and alerting
+	// (defaults to nil).
+	EventHandler EventHandler
 }
 
 // NewConfig returns a new configuration instance with sane defaults.
@@ -449,11 +873,14 @@ func NewConfig() *Config {
 	c.Admin.Retry.Max = 5
 	c.Admin.Retry.Backoff = 100 * time.Millisecond
 	c.Admin.Timeout = 3 * time.Second
+	c.Admin.Reassignment.PollInterval = 1 * time.Second
 
 	c.Net.MaxOpenRequests = 5
+	c.Net.ConnectionsPerBroker = 1
 	c.Net.DialTimeout = 30 * time.Second
 	c.Net.ReadTimeout = 30 * time.Second
 	c.Net.WriteTimeout = 30 * time.Second
+	c.Net.CircuitBreaker.Cooldown = 30 * time.Second
 	c.Net.SASL.Handshake = true
 	c.Net.SASL.Version = SASLHandshakeV0
 
@@ -471,6 +898,7 @@ func NewConfig() *Config {
 	c.Producer.Retry.Backoff = 100 * time.Millisecond
 	c.Producer.Return.Errors = true
 	c.Producer.CompressionLevel = CompressionLevelDefault
+	c.Producer.CompressionConcurrency = runtime.GOMAXPROCS(0)
 
 	c.Consumer.Fetch.Min = 1
 	c.Consumer.Fetch.Default = 1024 * 1024
@@ -482,6 +910,7 @@ func NewConfig() *Config {
 	c.Consumer.Offsets.AutoCommit.Interval = 1 * time.Second
 	c.Consumer.Offsets.Initial = OffsetNewest
 	c.Consumer.Offsets.Retry.Max = 3
+	c.Consumer.Offsets.Retry.Backoff = 100 * time.Millisecond
 
 	c.Consumer.Group.Session.Timeout = 10 * time.Second
 	c.Consumer.Group.Heartbeat.Interval = 3 * time.Second
@@ -491,6 +920,8 @@ func NewConfig() *Config {
 	c.Consumer.Group.Rebalance.Retry.Backoff = 2 * time.Second
 
 	c.ClientID = defaultClientID
+	c.ClientSoftwareName = defaultClientSoftwareName
+	c.ClientSoftwareVersion = defaultClientSoftwareVersion
 	c.ChannelBufferSize = 256
 	c.Version = DefaultVersion
 	c.MetricRegistry = metrics.NewRegistry()
@@ -498,68 +929,176 @@ func NewConfig() *Config {
 	return c
 }
 
-// Validate checks a Config instance. It will return a
-// ConfigurationError if the specified values don't make sense.
-func (c *Config) Validate() error {
-	// some configuration values should be warned on but not fail completely, do those first
+// ConfigWarning is a setting of a Config which is valid but most likely a
+// mistake, returned by Config.Warnings.
+type ConfigWarning struct {
+	// Field is the path of the setting, e.g. "Producer.Flush.Frequency"
+	Field string
+	// Message describes the problem
+	Message string
+}
+
+func (w ConfigWarning) String() string {
+	return w.Message
+}
+
+// Warnings returns the settings of the Config which are valid but most likely
+// mistakes. Validate logs them to Logger, and ValidateStrict fails with them.
+func (c *Config) Warnings() []ConfigWarning {
+	var warnings []ConfigWarning
+	warn := func(field, message string) {
+		warnings = append(warnings, ConfigWarning{Field: field, Message: message})
+	}
+
 	if !c.Net.TLS.Enable && c.Net.TLS.Config != nil {
-		Logger.Println("Net.TLS is disabled but a non-nil configuration was provided.")
+		warn("Net.TLS.Config", "Net.TLS is disabled but a non-nil configuration was provided.")
+	}
+	if !c.Net.TLS.Enable && c.Net.TLS.CertificateProvider != nil {
+		warn("Net.TLS.CertificateProvider", "Net.TLS is disabled but a certificate provider was provided.")
 	}
 	if !c.Net.SASL.Enable {
 		if c.Net.SASL.User != "" {
-			Logger.Println("Net.SASL is disabled but a non-empty username was provided.")
+			warn("Net.SASL.User", "Net.SASL is disabled but a non-empty username was provided.")
 		}
 		if c.Net.SASL.Password != "" {
-			Logger.Println("Net.SASL is disabled but a non-empty password was provided.")
+			warn("Net.SASL.Password", "Net.SASL is disabled but a non-empty password was provided.")
 		}
 	}
 	if c.Producer.RequiredAcks > 1 {
-		Logger.Println("Producer.RequiredAcks > 1 is deprecated and will raise an exception with kafka >= 0.8.2.0.")
+		warn("Producer.RequiredAcks", "Producer.RequiredAcks > 1 is deprecated and will raise an exception with kafka >= 0.8.2.0.")
 	}
 	if c.Producer.MaxMessageBytes >= int(MaxRequestSize) {
-		Logger.Println("Producer.MaxMessageBytes must be smaller than MaxRequestSize; it will be ignored.")
+		warn("Producer.MaxMessageBytes", "Producer.MaxMessageBytes must be smaller than MaxRequestSize; it will be ignored.")
 	}
 	if c.Producer.Flush.Bytes >= int(MaxRequestSize) {
-		Logger.Println("Producer.Flush.Bytes must be smaller than MaxRequestSize; it will be ignored.")
+		warn("Producer.Flush.Bytes", "Producer.Flush.Bytes must be smaller than MaxRequestSize; it will be ignored.")
 	}
 	if (c.Producer.Flush.Bytes > 0 || c.Producer.Flush.Messages > 0) && c.Producer.Flush.Frequency == 0 {
-		Logger.Println("Producer.Flush: Bytes or Messages are set, but Frequency is not; messages may not get flushed.")
+		warn("Producer.Flush.Frequency", "Producer.Flush: Bytes or Messages are set, but Frequency is not; messages may not get flushed.")
 	}
 	if c.Producer.Timeout%time.Millisecond != 0 {
-		Logger.Println("Producer.Timeout only supports millisecond resolution; nanoseconds will be truncated.")
+		warn("Producer.Timeout", "Producer.Timeout only supports millisecond resolution; nanoseconds will be truncated.")
+	}
+	if c.Producer.RequiredAcks == WaitForAll && !c.Producer.Idempotent && c.Producer.Retry.Max > 0 {
+		warn("Producer.Idempotent", "Producer.RequiredAcks is WaitForAll with retries but Producer.Idempotent is disabled; the retried messages may be duplicated or reordered.")
+	}
+	if c.Producer.Retry.Policy == nil && c.Producer.Retry.BackoffFunc == nil && c.Producer.Retry.Max > 0 &&
+		time.Duration(c.Producer.Retry.Max)*c.Producer.Retry.Backoff < c.Metadata.Retry.Backoff {
+		warn("Producer.Retry", "Producer.Retry.Max retries wait less than Metadata.Retry.Backoff; the messages may fail before the metadata is refreshed.")
+	}
+	if c.Consumer.Fetch.Max > 0 && int(c.Consumer.Fetch.Max) < c.Producer.MaxMessageBytes {
+		warn("Consumer.Fetch.Max", "Consumer.Fetch.Max is smaller than Producer.MaxMessageBytes; the larger messages cannot be consumed.")
+	}
+	if c.Consumer.Fetch.Default > 0 && c.Consumer.Fetch.Default < 1024 {
+		warn("Consumer.Fetch.Default", "Consumer.Fetch.Default is very low; most messages will need several fetch requests.")
 	}
 	if c.Consumer.MaxWaitTime < 100*time.Millisecond {
-		Logger.Println("Consumer.MaxWaitTime is very low, which can cause high CPU and network usage. See documentation for details.")
+		warn("Consumer.MaxWaitTime", "Consumer.MaxWaitTime is very low, which can cause high CPU and network usage. See documentation for details.")
 	}
 	if c.Consumer.MaxWaitTime%time.Millisecond != 0 {
-		Logger.Println("Consumer.MaxWaitTime only supports millisecond precision; nanoseconds will be truncated.")
+		warn("Consumer.MaxWaitTime", "Consumer.MaxWaitTime only supports millisecond precision; nanoseconds will be truncated.")
+	}
+	if c.Consumer.Offsets.CommitInterval != 0 {
+		warn("Consumer.Offsets.CommitInterval", "Deprecation warning: Consumer.Offsets.CommitInterval exists for historical compatibility"+
+			" and should not be used. Please use Consumer.Offsets.AutoCommit, the current value will be ignored")
 	}
 	if c.Consumer.Offsets.Retention%time.Millisecond != 0 {
-		Logger.Println("Consumer.Offsets.Retention only supports millisecond precision; nanoseconds will be truncated.")
+		warn("Consumer.Offsets.Retention", "Consumer.Offsets.Retention only supports millisecond precision; nanoseconds will be truncated.")
 	}
 	if c.Consumer.Group.Session.Timeout%time.Millisecond != 0 {
-		Logger.Println("Consumer.Group.Session.Timeout only supports millisecond precision; nanoseconds will be truncated.")
+		warn("Consumer.Group.Session.Timeout", "Consumer.Group.Session.Timeout only supports millisecond precision; nanoseconds will be truncated.")
 	}
 	if c.Consumer.Group.Heartbeat.Interval%time.Millisecond != 0 {
-		Logger.Println("Consumer.Group.Heartbeat.Interval only supports millisecond precision; nanoseconds will be truncated.")
+		warn("Consumer.Group.Heartbeat.Interval", "Consumer.Group.Heartbeat.Interval only supports millisecond precision; nanoseconds will be truncated.")
+	}
+	if c.Consumer.Group.Heartbeat.Interval > c.Consumer.Group.Session.Timeout/3 && c.Consumer.Group.Heartbeat.Interval < c.Consumer.Group.Session.Timeout {
+		warn("Consumer.Group.Heartbeat.Interval", "Consumer.Group.Heartbeat.Interval is higher than 1/3 of Consumer.Group.Session.Timeout; a single late heartbeat may expire the session.")
 	}
 	if c.Consumer.Group.Rebalance.Timeout%time.Millisecond != 0 {
-		Logger.Println("Consumer.Group.Rebalance.Timeout only supports millisecond precision; nanoseconds will be truncated.")
+		warn("Consumer.Group.Rebalance.Timeout", "Consumer.Group.Rebalance.Timeout only supports millisecond precision; nanoseconds will be truncated.")
+	}
+	if c.Consumer.Group.ServerAssignor != "" && c.Consumer.Group.Protocol != GroupProtocolConsumer {
+		warn("Consumer.Group.ServerAssignor", "Consumer.Group.ServerAssignor is only used with GroupProtocolConsumer, it will be ignored.")
+	}
+	if c.Telemetry.Enable && !c.Version.IsAtLeast(V3_7_0_0) {
+		warn("Telemetry.Enable", "Telemetry requires Version to be at least 3.7.0.0, it will be ignored.")
+	}
+	if c.Telemetry.Enable && c.MetricsRecorder != nil {
+		warn("Telemetry.Enable", "Telemetry pushes the metrics of MetricRegistry, which receives none when MetricsRecorder is set.")
 	}
 	if c.ClientID == defaultClientID {
-		Logger.Println("ClientID is the default of 'sarama', you should consider setting it to something application-specific.")
+		warn("ClientID", "ClientID is the default of 'sarama', you should consider setting it to something application-specific.")
+	}
+	return warnings
+}
+
+// ValidateStrict is the strict mode of Validate, meant to check the configs of
+// the services in CI: it also returns a ConfigurationError listing the
+// Warnings, if any.
+func (c *Config) ValidateStrict() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	warnings := c.Warnings()
+	if len(warnings) == 0 {
+		return nil
+	}
+	messages := make([]string, len(warnings))
+	for i, warning := range warnings {
+		messages[i] = warning.Field + ": " + warning.Message
+	}
+	return ConfigurationError(fmt.Sprintf("%d warnings in strict mode: %s", len(warnings), strings.Join(messages, "; ")))
+}
+
+// Validate checks a Config instance. It will return a
+// ConfigurationError if the specified values don't make sense.
+func (c *Config) Validate() error {
+	// some configuration values should be warned on but not fail completely, do those first
+	for _, warning := range c.Warnings() {
+		Logger.Println(warning.Message)
 	}
 
 	// validate Net values
+	for apiKey, max := range c.Net.MaxOpenRequestsByKey {
+		if max <= 0 {
+			return ConfigurationError(fmt.Sprintf("Net.MaxOpenRequestsByKey[%d] must be > 0", apiKey))
+		}
+	}
 	switch {
 	case c.Net.MaxOpenRequests <= 0:
 		return ConfigurationError("Net.MaxOpenRequests must be > 0")
+	case c.Net.ConnectionsPerBroker <= 0:
+		return ConfigurationError("Net.ConnectionsPerBroker must be > 0")
 	case c.Net.DialTimeout <= 0:
 		return ConfigurationError("Net.DialTimeout must be > 0")
 	case c.Net.ReadTimeout <= 0:
 		return ConfigurationError("Net.ReadTimeout must be > 0")
 	case c.Net.WriteTimeout <= 0:
 		return ConfigurationError("Net.WriteTimeout must be > 0")
+	case c.Net.Socket.SendBuffer < 0:
+		return ConfigurationError("Net.Socket.SendBuffer must be >= 0")
+	case c.Net.Socket.ReceiveBuffer < 0:
+		return ConfigurationError("Net.Socket.ReceiveBuffer must be >= 0")
+	case c.Net.Socket.KeepAliveInterval < 0:
+		return ConfigurationError("Net.Socket.KeepAliveInterval must be >= 0")
+	case c.Net.Socket.KeepAliveCount < 0:
+		return ConfigurationError("Net.Socket.KeepAliveCount must be >= 0")
+	case c.Net.Socket.UserTimeout < 0:
+		return ConfigurationError("Net.Socket.UserTimeout must be >= 0")
+	case !tcpOptionsSupported && (c.Net.Socket.KeepAliveInterval > 0 || c.Net.Socket.KeepAliveCount > 0 || c.Net.Socket.UserTimeout > 0):
+		return ConfigurationError("Net.Socket.KeepAliveInterval, KeepAliveCount and UserTimeout are only supported on Linux")
+	case c.Net.CircuitBreaker.Failures < 0:
+		return ConfigurationError("Net.CircuitBreaker.Failures must be >= 0")
+	case c.Net.CircuitBreaker.Failures > 0 && c.Net.CircuitBreaker.Cooldown <= 0:
+		return ConfigurationError("Net.CircuitBreaker.Cooldown must be > 0")
+	case c.Net.DialFn != nil && c.Net.Proxy.Enable:
+		return ConfigurationError("Net.DialFn and Net.Proxy cannot be used together")
+	case c.Net.Proxy.Enable && c.Net.Proxy.Dialer == nil && c.Net.Proxy.Address == "":
+		return ConfigurationError("Net.Proxy.Dialer or Net.Proxy.Address must be set when the proxy is enabled")
+	case c.Net.Proxy.Type != "" && c.Net.Proxy.Type != ProxyTypeSOCKS5 && c.Net.Proxy.Type != ProxyTypeHTTP:
+		return ConfigurationError(fmt.Sprintf("Net.Proxy.Type %q is invalid", c.Net.Proxy.Type))
+	case c.Net.Proxy.TLS.Enable && c.Net.Proxy.Type != ProxyTypeHTTP:
+		return ConfigurationError("Net.Proxy.TLS can only be enabled with ProxyTypeHTTP")
 	case c.Net.SASL.Enable:
 		if c.Net.SASL.Mechanism == "" {
 			c.Net.SASL.Mechanism = SASLTypePlaintext
@@ -584,9 +1123,6 @@ func (c *Config) Validate() error {
 			if c.Net.SASL.Password == "" {
 				return ConfigurationError("Net.SASL.Password must not be empty when SASL is enabled")
 			}
-			if c.Net.SASL.SCRAMClientGeneratorFunc == nil {
-				return ConfigurationError("A SCRAMClientGeneratorFunc function must be provided to Net.SASL.SCRAMClientGeneratorFunc")
-			}
 		case SASLTypeGSSAPI:
 			if c.Net.SASL.GSSAPI.ServiceName == "" {
 				return ConfigurationError("Net.SASL.GSSAPI.ServiceName must not be empty when GSS-API mechanism is used")
@@ -602,29 +1138,52 @@ func (c *Config) Validate() error {
 					return ConfigurationError("Net.SASL.GSSAPI.KeyTabPath must not be empty when GSS-API mechanism is used" +
 						" and  Net.SASL.GSSAPI.AuthType = KRB5_KEYTAB_AUTH")
 				}
+			} else if c.Net.SASL.GSSAPI.AuthType == KRB5_CCACHE_AUTH {
+				if c.Net.SASL.GSSAPI.CCachePath == "" && c.Net.SASL.GSSAPI.CCacheFunc == nil {
+					return ConfigurationError("Net.SASL.GSSAPI.CCachePath or Net.SASL.GSSAPI.CCacheFunc must be set when GSS-API mechanism is used" +
+						" and Net.SASL.GSSAPI.AuthType = KRB5_CCACHE_AUTH")
+				}
 			} else {
-				return ConfigurationError("Net.SASL.GSSAPI.AuthType is invalid. Possible values are KRB5_USER_AUTH and KRB5_KEYTAB_AUTH")
+				return ConfigurationError("Net.SASL.GSSAPI.AuthType is invalid. Possible values are KRB5_USER_AUTH, KRB5_KEYTAB_AUTH and KRB5_CCACHE_AUTH")
 			}
 			if c.Net.SASL.GSSAPI.KerberosConfigPath == "" {
 				return ConfigurationError("Net.SASL.GSSAPI.KerberosConfigPath must not be empty when GSS-API mechanism is used")
 			}
-			if c.Net.SASL.GSSAPI.Username == "" {
-				return ConfigurationError("Net.SASL.GSSAPI.Username must not be empty when GSS-API mechanism is used")
+			// the principal of a credentials cache is the one of its tickets
+			if c.Net.SASL.GSSAPI.AuthType != KRB5_CCACHE_AUTH {
+				if c.Net.SASL.GSSAPI.Username == "" {
+					return ConfigurationError("Net.SASL.GSSAPI.Username must not be empty when GSS-API mechanism is used")
+				}
+				if c.Net.SASL.GSSAPI.Realm == "" {
+					return ConfigurationError("Net.SASL.GSSAPI.Realm must not be empty when GSS-API mechanism is used")
+				}
 			}
-			if c.Net.SASL.GSSAPI.Realm == "" {
-				return ConfigurationError("Net.SASL.GSSAPI.Realm must not be empty when GSS-API mechanism is used")
+		case SASLTypeAWSMSKIAM:
+			if c.Net.SASL.AWSMSKIAM.region() == "" {
+				return ConfigurationError("Net.SASL.AWSMSKIAM.Region must be set when the AWS_MSK_IAM mechanism is used, unless AWS_REGION is")
 			}
 		default:
-			msg := fmt.Sprintf("The SASL mechanism configuration is invalid. Possible values are `%s`, `%s`, `%s`, `%s` and `%s`",
-				SASLTypeOAuth, SASLTypePlaintext, SASLTypeSCRAMSHA256, SASLTypeSCRAMSHA512, SASLTypeGSSAPI)
+			if _, ok := registeredSASLMechanism(c.Net.SASL.Mechanism); ok {
+				break
+			}
+			msg := fmt.Sprintf("The SASL mechanism configuration is invalid. Possible values are `%s`, `%s`, `%s`, `%s`, `%s`, `%s` and the registered mechanisms",
+				SASLTypeOAuth, SASLTypePlaintext, SASLTypeSCRAMSHA256, SASLTypeSCRAMSHA512, SASLTypeGSSAPI, SASLTypeAWSMSKIAM)
 			return ConfigurationError(msg)
 		}
 	}
 
+	if c.Net.FIPS {
+		if err := c.validateFIPS(); err != nil {
+			return err
+		}
+	}
+
 	// validate the Admin values
 	switch {
 	case c.Admin.Timeout <= 0:
 		return ConfigurationError("Admin.Timeout must be > 0")
+	case c.Admin.Reassignment.PollInterval <= 0:
+		return ConfigurationError("Admin.Reassignment.PollInterval must be > 0")
 	}
 
 	// validate the Metadata values
@@ -647,6 +1206,8 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Producer.Timeout must be > 0")
 	case c.Producer.Partitioner == nil:
 		return ConfigurationError("Producer.Partitioner must not be nil")
+	case c.Producer.CompressionConcurrency <= 0:
+		return ConfigurationError("Producer.CompressionConcurrency must be > 0")
 	case c.Producer.Flush.Bytes < 0:
 		return ConfigurationError("Producer.Flush.Bytes must be >= 0")
 	case c.Producer.Flush.Messages < 0:
@@ -667,7 +1228,13 @@ func (c *Config) Validate() error {
 		return ConfigurationError("lz4 compression requires Version >= V0_10_0_0")
 	}
 
-	if c.Producer.Compression == CompressionGZIP {
+	if c.Producer.Compression < CompressionNone || c.Producer.Compression > CompressionZSTD {
+		if _, ok := registeredCompressorOf(c.Producer.Compression); !ok {
+			return ConfigurationError(fmt.Sprintf("Producer.Compression %s is not registered with RegisterCompressor", c.Producer.Compression))
+		}
+	}
+
+	if _, replaced := registeredCompressorOf(CompressionGZIP); c.Producer.Compression == CompressionGZIP && !replaced {
 		if c.Producer.CompressionLevel != CompressionLevelDefault {
 			if _, err := gzip.NewWriterLevel(io.Discard, c.Producer.CompressionLevel); err != nil {
 				return ConfigurationError(fmt.Sprintf("gzip compression does not work with level %d: %v", c.Producer.CompressionLevel, err))
@@ -714,13 +1281,24 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Consumer.Offsets.Initial must be OffsetOldest or OffsetNewest")
 	case c.Consumer.Offsets.Retry.Max < 0:
 		return ConfigurationError("Consumer.Offsets.Retry.Max must be >= 0")
+	case c.Consumer.Offsets.Batch.MaxPartitions < 0:
+		return ConfigurationError("Consumer.Offsets.Batch.MaxPartitions must be >= 0")
+	case c.Consumer.Offsets.Batch.MaxBytes < 0:
+		return ConfigurationError("Consumer.Offsets.Batch.MaxBytes must be >= 0")
+	case c.Consumer.Offsets.Retry.CommitMax < 0:
+		return ConfigurationError("Consumer.Offsets.Retry.CommitMax must be >= 0")
+	case c.Consumer.Offsets.Retry.Backoff < 0:
+		return ConfigurationError("Consumer.Offsets.Retry.Backoff must be >= 0")
 	case c.Consumer.IsolationLevel != ReadUncommitted && c.Consumer.IsolationLevel != ReadCommitted:
 		return ConfigurationError("Consumer.IsolationLevel must be ReadUncommitted or ReadCommitted")
+	case !c.Consumer.Offsets.OutOfRange.Policy.valid():
+		return ConfigurationError("Consumer.Offsets.OutOfRange.Policy is invalid")
 	}
 
-	if c.Consumer.Offsets.CommitInterval != 0 {
-		Logger.Println("Deprecation warning: Consumer.Offsets.CommitInterval exists for historical compatibility" +
-			" and should not be used. Please use Consumer.Offsets.AutoCommit, the current value will be ignored")
+	for topic, policy := range c.Consumer.Offsets.OutOfRange.Topics {
+		if !policy.valid() {
+			return ConfigurationError(fmt.Sprintf("Consumer.Offsets.OutOfRange.Topics has an invalid policy for topic %s", topic))
+		}
 	}
 
 	// validate IsolationLevel
@@ -728,14 +1306,26 @@ func (c *Config) Validate() error {
 		return ConfigurationError("ReadCommitted requires Version >= V0_11_0_0")
 	}
 
+	if err := c.validateTopicOverrides(); err != nil {
+		return err
+	}
+
 	// validate the Consumer Group values
 	switch {
+	case c.Consumer.Group.Protocol != GroupProtocolClassic && c.Consumer.Group.Protocol != GroupProtocolConsumer:
+		return ConfigurationError("Consumer.Group.Protocol must be GroupProtocolClassic or GroupProtocolConsumer")
 	case c.Consumer.Group.Session.Timeout <= 2*time.Millisecond:
 		return ConfigurationError("Consumer.Group.Session.Timeout must be >= 2ms")
 	case c.Consumer.Group.Heartbeat.Interval < 1*time.Millisecond:
 		return ConfigurationError("Consumer.Group.Heartbeat.Interval must be >= 1ms")
 	case c.Consumer.Group.Heartbeat.Interval >= c.Consumer.Group.Session.Timeout:
 		return ConfigurationError("Consumer.Group.Heartbeat.Interval must be < Consumer.Group.Session.Timeout")
+	case c.Consumer.Group.Heartbeat.Timeout < 0:
+		return ConfigurationError("Consumer.Group.Heartbeat.Timeout must be >= 0")
+	case c.Consumer.Group.MaxPollInterval < 0:
+		return ConfigurationError("Consumer.Group.MaxPollInterval must be >= 0")
+	case hasNilMiddleware(c.Consumer.Group.Middlewares):
+		return ConfigurationError("Consumer.Group.Middlewares must not contain nil middlewares")
 	case c.Consumer.Group.Rebalance.Strategy == nil:
 		return ConfigurationError("Consumer.Group.Rebalance.Strategy must not be empty")
 	case c.Consumer.Group.Rebalance.Timeout <= time.Millisecond:
@@ -752,20 +1342,89 @@ func (c *Config) Validate() error {
 		return ConfigurationError("ChannelBufferSize must be >= 0")
 	case !validID.MatchString(c.ClientID):
 		return ConfigurationError("ClientID is invalid")
+	case !validClientSoftware.MatchString(c.ClientSoftwareName):
+		return ConfigurationError("ClientSoftwareName is invalid")
+	case !validClientSoftware.MatchString(c.ClientSoftwareVersion):
+		return ConfigurationError("ClientSoftwareVersion is invalid")
 	}
 
 	return nil
 }
 
-func (c *Config) getDialer() proxy.Dialer {
-	if c.Net.Proxy.Enable {
+// resolveBrokers rewrites the addresses of the given brokers, just decoded
+// from a response, with Net.AddressResolver if set
+func (c *Config) resolveBrokers(brokers ...*Broker) {
+	if c.Net.AddressResolver == nil {
+		return
+	}
+	for _, broker := range brokers {
+		broker.addr = c.Net.AddressResolver(broker.addr)
+	}
+}
+
+// dial opens a connection to a broker with Net.DialFn if set, or else with the
+// dialer returned by getDialer
+func (c *Config) dial(network, address string) (net.Conn, error) {
+	if c.Net.DialFn != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), c.Net.DialTimeout)
+		defer cancel()
+		return c.Net.DialFn(ctx, network, address)
+	}
+	dialer, err := c.getDialer()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialer.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.tuneSocket(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c *Config) getDialer() (proxy.Dialer, error) {
+	direct := &net.Dialer{
+		Timeout:   c.Net.DialTimeout,
+		KeepAlive: c.Net.KeepAlive,
+		LocalAddr: c.Net.LocalAddr,
+	}
+	if !c.Net.Proxy.Enable {
+		return direct, nil
+	}
+
+	if c.Net.Proxy.Dialer != nil {
 		Logger.Printf("using proxy %s", c.Net.Proxy.Dialer)
-		return c.Net.Proxy.Dialer
-	} else {
-		return &net.Dialer{
-			Timeout:   c.Net.DialTimeout,
-			KeepAlive: c.Net.KeepAlive,
-			LocalAddr: c.Net.LocalAddr,
+		return c.Net.Proxy.Dialer, nil
+	}
+
+	if c.Net.Proxy.Type == ProxyTypeHTTP {
+		Logger.Printf("using HTTP proxy %s", c.Net.Proxy.Address)
+		dialer := &httpProxyDialer{
+			address:  c.Net.Proxy.Address,
+			user:     c.Net.Proxy.User,
+			password: c.Net.Proxy.Password,
+			timeout:  c.Net.DialTimeout,
+			forward:  direct,
+		}
+		if c.Net.Proxy.TLS.Enable {
+			dialer.tlsConfig = c.Net.Proxy.TLS.Config
+			if dialer.tlsConfig == nil {
+				dialer.tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+			}
+			if c.Net.FIPS {
+				dialer.tlsConfig = fipsTLSConfig(dialer.tlsConfig)
+			}
 		}
+		return dialer, nil
+	}
+
+	var auth *proxy.Auth
+	if c.Net.Proxy.User != "" {
+		auth = &proxy.Auth{User: c.Net.Proxy.User, Password: c.Net.Proxy.Password}
 	}
+	Logger.Printf("using SOCKS5 proxy %s", c.Net.Proxy.Address)
+	return proxy.SOCKS5("tcp", c.Net.Proxy.Address, auth, direct)
 }
@@ -0,0 +1,335 @@
+package sarama
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// configProperties maps the properties of the Java clients understood by
+// NewConfigFromMap to the Config fields they set
+var configProperties = map[string]func(c *Config, value string) error{
+	"client.id": func(c *Config, value string) error {
+		c.ClientID = value
+		return nil
+	},
+	"client.rack": func(c *Config, value string) error {
+		c.RackID = value
+		return nil
+	},
+	"security.protocol": func(c *Config, value string) error {
+		switch strings.ToUpper(value) {
+		case "PLAINTEXT":
+			c.Net.TLS.Enable, c.Net.SASL.Enable = false, false
+		case "SSL":
+			c.Net.TLS.Enable, c.Net.SASL.Enable = true, false
+		case "SASL_PLAINTEXT":
+			c.Net.TLS.Enable, c.Net.SASL.Enable = false, true
+		case "SASL_SSL":
+			c.Net.TLS.Enable, c.Net.SASL.Enable = true, true
+		default:
+			return errors.New("must be PLAINTEXT, SSL, SASL_PLAINTEXT or SASL_SSL")
+		}
+		return nil
+	},
+	"sasl.mechanism": func(c *Config, value string) error {
+		c.Net.SASL.Mechanism = SASLMechanism(value)
+		return nil
+	},
+	"sasl.jaas.config": func(c *Config, value string) error {
+		options := jaasOptions(value)
+		user, ok := options["username"]
+		if !ok {
+			return errors.New("only login modules with a username and password are supported")
+		}
+		c.Net.SASL.User, c.Net.SASL.Password = user, options["password"]
+		return nil
+	},
+	"max.in.flight.requests.per.connection": intProperty(func(c *Config, value int) { c.Net.MaxOpenRequests = value }),
+	"socket.connection.setup.timeout.ms":    msProperty(func(c *Config, value time.Duration) { c.Net.DialTimeout = value }),
+	"request.timeout.ms":                    msProperty(func(c *Config, value time.Duration) { c.Net.ReadTimeout = value }),
+	"retry.backoff.ms": msProperty(func(c *Config, value time.Duration) {
+		c.Producer.Retry.Backoff = value
+		c.Metadata.Retry.Backoff = value
+		c.Admin.Retry.Backoff = value
+	}),
+	"metadata.max.age.ms":      msProperty(func(c *Config, value time.Duration) { c.Metadata.RefreshFrequency = value }),
+	"allow.auto.create.topics": boolProperty(func(c *Config, value bool) { c.Metadata.AllowAutoTopicCreation = value }),
+
+	"acks": func(c *Config, value string) error {
+		switch value {
+		case "all", "-1":
+			c.Producer.RequiredAcks = WaitForAll
+		case "0":
+			c.Producer.RequiredAcks = NoResponse
+		case "1":
+			c.Producer.RequiredAcks = WaitForLocal
+		default:
+			return errors.New("must be all, -1, 0 or 1")
+		}
+		return nil
+	},
+	"compression.type": func(c *Config, value string) error {
+		switch value {
+		case "none":
+			c.Producer.Compression = CompressionNone
+		case "gzip":
+			c.Producer.Compression = CompressionGZIP
+		case "snappy":
+			c.Producer.Compression = CompressionSnappy
+		case "lz4":
+			c.Producer.Compression = CompressionLZ4
+		case "zstd":
+			c.Producer.Compression = CompressionZSTD
+		default:
+			cc, ok := registeredCompressionCodec(value)
+			if !ok {
+				return errors.New("must be none, gzip, snappy, lz4, zstd or a codec registered with RegisterCompressor")
+			}
+			c.Producer.Compression = cc
+		}
+		return nil
+	},
+	"enable.idempotence": boolProperty(func(c *Config, value bool) { c.Producer.Idempotent = value }),
+	"retries":            intProperty(func(c *Config, value int) { c.Producer.Retry.Max = value }),
+	"linger.ms":          msProperty(func(c *Config, value time.Duration) { c.Producer.Flush.Frequency = value }),
+	"max.request.size":   intProperty(func(c *Config, value int) { c.Producer.MaxMessageBytes = value }),
+
+	"auto.offset.reset": func(c *Config, value string) error {
+		switch value {
+		case "earliest":
+			c.Consumer.Offsets.Initial = OffsetOldest
+		case "latest":
+			c.Consumer.Offsets.Initial = OffsetNewest
+		default:
+			return errors.New("must be earliest or latest")
+		}
+		return nil
+	},
+	"enable.auto.commit":        boolProperty(func(c *Config, value bool) { c.Consumer.Offsets.AutoCommit.Enable = value }),
+	"auto.commit.interval.ms":   msProperty(func(c *Config, value time.Duration) { c.Consumer.Offsets.AutoCommit.Interval = value }),
+	"session.timeout.ms":        msProperty(func(c *Config, value time.Duration) { c.Consumer.Group.Session.Timeout = value }),
+	"heartbeat.interval.ms":     msProperty(func(c *Config, value time.Duration) { c.Consumer.Group.Heartbeat.Interval = value }),
+	"max.poll.interval.ms":      msProperty(func(c *Config, value time.Duration) { c.Consumer.Group.MaxPollInterval = value }),
+	"fetch.min.bytes":           int32Property(func(c *Config, value int32) { c.Consumer.Fetch.Min = value }),
+	"max.partition.fetch.bytes": int32Property(func(c *Config, value int32) { c.Consumer.Fetch.Default = value }),
+	"fetch.max.wait.ms":         msProperty(func(c *Config, value time.Duration) { c.Consumer.MaxWaitTime = value }),
+	"isolation.level": func(c *Config, value string) error {
+		switch value {
+		case "read_uncommitted":
+			c.Consumer.IsolationLevel = ReadUncommitted
+		case "read_committed":
+			c.Consumer.IsolationLevel = ReadCommitted
+		default:
+			return errors.New("must be read_uncommitted or read_committed")
+		}
+		return nil
+	},
+	"partition.assignment.strategy": func(c *Config, value string) error {
+		// the assignors of the Java client, by class or short name
+		switch strings.TrimPrefix(value, "org.apache.kafka.clients.consumer.") {
+		case "RangeAssignor", "range":
+			c.Consumer.Group.Rebalance.Strategy = BalanceStrategyRange
+		case "RoundRobinAssignor", "roundrobin":
+			c.Consumer.Group.Rebalance.Strategy = BalanceStrategyRoundRobin
+		case "StickyAssignor", "sticky":
+			c.Consumer.Group.Rebalance.Strategy = BalanceStrategySticky
+		default:
+			return errors.New("must be a single RangeAssignor, RoundRobinAssignor or StickyAssignor")
+		}
+		return nil
+	},
+}
+
+// tlsProperties are the ssl properties of the Java clients understood by
+// NewConfigFromMap, only the PEM key and trust stores are supported
+var tlsProperties = map[string]bool{
+	"ssl.truststore.type":                   true,
+	"ssl.truststore.location":               true,
+	"ssl.truststore.certificates":           true,
+	"ssl.keystore.type":                     true,
+	"ssl.keystore.location":                 true,
+	"ssl.keystore.certificate.chain":        true,
+	"ssl.keystore.key":                      true,
+	"ssl.endpoint.identification.algorithm": true,
+}
+
+// NewConfigFromMap returns the result of NewConfig with the properties of the
+// Java clients set, such as `acks` or `security.protocol`, so that the
+// configuration can be shared with services written in other languages. The
+// broker addresses of `bootstrap.servers` are returned to be given to the
+// constructors. The durations are in milliseconds, as the property names say,
+// and TLS only supports the PEM key and trust stores. The unknown properties
+// and invalid values are returned as a ConfigurationError, as well as the
+// errors of Validate.
+func NewConfigFromMap(properties map[string]string) (*Config, []string, error) {
+	c := NewConfig()
+	var brokers []string
+	ssl := make(map[string]string)
+
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := strings.TrimSpace(properties[key])
+		if key == "bootstrap.servers" {
+			for _, broker := range strings.Split(value, ",") {
+				if broker = strings.TrimSpace(broker); broker != "" {
+					brokers = append(brokers, broker)
+				}
+			}
+			continue
+		}
+		if tlsProperties[key] {
+			ssl[key] = value
+			continue
+		}
+		set, ok := configProperties[key]
+		if !ok {
+			return nil, nil, ConfigurationError(fmt.Sprintf("unknown property %s", key))
+		}
+		if err := set(c, value); err != nil {
+			return nil, nil, ConfigurationError(fmt.Sprintf("invalid property %s: %v", key, err))
+		}
+	}
+
+	if len(ssl) > 0 {
+		tlsConfig, err := tlsConfigFromProperties(ssl)
+		if err != nil {
+			return nil, nil, err
+		}
+		c.Net.TLS.Config = tlsConfig
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return c, brokers, nil
+}
+
+// tlsConfigFromProperties returns the TLS configuration of the ssl properties
+func tlsConfigFromProperties(ssl map[string]string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if algorithm, ok := ssl["ssl.endpoint.identification.algorithm"]; ok && !strings.EqualFold(algorithm, "https") {
+		return nil, ConfigurationError("invalid property ssl.endpoint.identification.algorithm: must be https, the broker host names are always verified")
+	}
+
+	trust, err := pemStore(ssl, "ssl.truststore", "ssl.truststore.certificates")
+	if err != nil {
+		return nil, err
+	}
+	if trust != nil {
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(trust) {
+			return nil, ConfigurationError("invalid property ssl.truststore: no PEM certificate found")
+		}
+	}
+
+	chain, err := pemStore(ssl, "ssl.keystore", "ssl.keystore.certificate.chain")
+	if err != nil {
+		return nil, err
+	}
+	if chain != nil {
+		// the key is in the same PEM file as the chain, unless given inline
+		key := chain
+		if inline, ok := ssl["ssl.keystore.key"]; ok {
+			key = []byte(inline)
+		}
+		certificate, err := tls.X509KeyPair(chain, key)
+		if err != nil {
+			return nil, ConfigurationError(fmt.Sprintf("invalid property ssl.keystore: %v", err))
+		}
+		cfg.Certificates = []tls.Certificate{certificate}
+	}
+
+	return cfg, nil
+}
+
+// pemStore returns the content of the PEM key or trust store with the given
+// prefix, read from its location or given inline, or nil if it is not set
+func pemStore(ssl map[string]string, store, inline string) ([]byte, error) {
+	location, hasLocation := ssl[store+".location"]
+	content, hasContent := ssl[inline]
+	if !hasLocation && !hasContent {
+		return nil, nil
+	}
+	if storeType := ssl[store+".type"]; !strings.EqualFold(storeType, "PEM") {
+		return nil, ConfigurationError(fmt.Sprintf("invalid property %s.type: must be PEM, the other store types are not supported", store))
+	}
+	if hasContent {
+		return []byte(content), nil
+	}
+	file, err := ioutil.ReadFile(location)
+	if err != nil {
+		return nil, ConfigurationError(fmt.Sprintf("invalid property %s.location: %v", store, err))
+	}
+	return file, nil
+}
+
+// jaasOption matches the key="value" options of a JAAS login module
+var jaasOption = regexp.MustCompile(`([A-Za-z_.]+)\s*=\s*"((?:[^"\\]|\\.)*)"`)
+
+// jaasOptions returns the options of the JAAS configuration of a login
+// module, such as `PlainLoginModule required username="u" password="p";`
+func jaasOptions(config string) map[string]string {
+	options := make(map[string]string)
+	for _, match := range jaasOption.FindAllStringSubmatch(config, -1) {
+		options[match[1]] = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(match[2])
+	}
+	return options
+}
+
+func intProperty(set func(c *Config, value int)) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		set(c, v)
+		return nil
+	}
+}
+
+func int32Property(set func(c *Config, value int32)) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		v, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return err
+		}
+		set(c, int32(v))
+		return nil
+	}
+}
+
+func msProperty(set func(c *Config, value time.Duration)) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		set(c, time.Duration(ms)*time.Millisecond)
+		return nil
+	}
+}
+
+func boolProperty(set func(c *Config, value bool)) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		set(c, v)
+		return nil
+	}
+}
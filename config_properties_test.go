@@ -0,0 +1,153 @@
+package sarama
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewConfigFromMap(t *testing.T) {
+	config, brokers, err := NewConfigFromMap(map[string]string{
+		"bootstrap.servers":             "kafka-1:9092, kafka-2:9092",
+		"client.id":                     "billing",
+		"security.protocol":             "SASL_SSL",
+		"sasl.mechanism":                "SCRAM-SHA-512",
+		"sasl.jaas.config":              `org.apache.kafka.common.security.scram.ScramLoginModule required username="billing" password="s3cr\"t";`,
+		"acks":                          "all",
+		"retries":                       "7",
+		"retry.backoff.ms":              "500",
+		"compression.type":              "lz4",
+		"linger.ms":                     "20",
+		"auto.offset.reset":             "earliest",
+		"session.timeout.ms":            "45000",
+		"isolation.level":               "read_committed",
+		"partition.assignment.strategy": "org.apache.kafka.clients.consumer.RoundRobinAssignor",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(brokers, []string{"kafka-1:9092", "kafka-2:9092"}) {
+		t.Errorf("unexpected brokers %v", brokers)
+	}
+	if config.ClientID != "billing" {
+		t.Errorf("unexpected client ID %s", config.ClientID)
+	}
+	if !config.Net.TLS.Enable || !config.Net.SASL.Enable || config.Net.SASL.Mechanism != SASLTypeSCRAMSHA512 {
+		t.Errorf("unexpected security settings %+v", config.Net.SASL)
+	}
+	if config.Net.SASL.User != "billing" || config.Net.SASL.Password != `s3cr"t` {
+		t.Errorf("unexpected credentials %s/%s", config.Net.SASL.User, config.Net.SASL.Password)
+	}
+	if config.Producer.RequiredAcks != WaitForAll || config.Producer.Retry.Max != 7 || config.Producer.Compression != CompressionLZ4 {
+		t.Errorf("unexpected producer settings %+v", config.Producer)
+	}
+	if config.Producer.Retry.Backoff != 500*time.Millisecond || config.Metadata.Retry.Backoff != 500*time.Millisecond {
+		t.Error("expected retry.backoff.ms to set the retry backoffs")
+	}
+	if config.Producer.Flush.Frequency != 20*time.Millisecond {
+		t.Errorf("unexpected flush frequency %s", config.Producer.Flush.Frequency)
+	}
+	if config.Consumer.Offsets.Initial != OffsetOldest || config.Consumer.IsolationLevel != ReadCommitted {
+		t.Errorf("unexpected consumer settings %+v", config.Consumer)
+	}
+	if config.Consumer.Group.Session.Timeout != 45*time.Second || config.Consumer.Group.Rebalance.Strategy != BalanceStrategyRoundRobin {
+		t.Errorf("unexpected consumer group settings %+v", config.Consumer.Group)
+	}
+}
+
+func TestNewConfigFromMapErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		properties map[string]string
+		err        string
+	}{
+		{
+			"UnknownProperty",
+			map[string]string{"group.instance.idd": "a"},
+			"unknown property group.instance.idd",
+		},
+		{
+			"InvalidEnum",
+			map[string]string{"acks": "2"},
+			"invalid property acks: must be all, -1, 0 or 1",
+		},
+		{
+			"InvalidDuration",
+			map[string]string{"linger.ms": "5s"},
+			`invalid property linger.ms: strconv.ParseInt: parsing "5s": invalid syntax`,
+		},
+		{
+			"JKSTrustStore",
+			map[string]string{"security.protocol": "SSL", "ssl.truststore.location": "/etc/kafka/truststore.jks"},
+			"invalid property ssl.truststore.type: must be PEM, the other store types are not supported",
+		},
+		{
+			"HostNameVerification",
+			map[string]string{"ssl.endpoint.identification.algorithm": ""},
+			"invalid property ssl.endpoint.identification.algorithm: must be https, the broker host names are always verified",
+		},
+		{
+			"Validation",
+			map[string]string{"max.in.flight.requests.per.connection": "0"},
+			"Net.MaxOpenRequests must be > 0",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, _, err := NewConfigFromMap(test.properties)
+			if config != nil || err == nil || string(err.(ConfigurationError)) != test.err {
+				t.Errorf("expected %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestNewConfigFromMapPEMStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sarama")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t)
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	writeCertificate(t, ca.issue(t, "client", 0), certFile, keyFile, time.Now())
+	certPEM, _ := ioutil.ReadFile(certFile)
+	keyPEM, _ := ioutil.ReadFile(keyFile)
+	keystore := filepath.Join(dir, "keystore.pem")
+	if err := ioutil.WriteFile(keystore, append(certPEM, keyPEM...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, _, err := NewConfigFromMap(map[string]string{
+		"security.protocol":           "SSL",
+		"ssl.truststore.type":         "PEM",
+		"ssl.truststore.certificates": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})),
+		"ssl.keystore.type":           "PEM",
+		"ssl.keystore.location":       keystore,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tlsConfig := config.Net.TLS.Config
+	if tlsConfig == nil || tlsConfig.RootCAs == nil || len(tlsConfig.Certificates) != 1 {
+		t.Fatalf("expected the trust and key stores to be loaded, got %+v", tlsConfig)
+	}
+	if !reflect.DeepEqual(tlsConfig.Certificates[0].Certificate[0], pemBlock(t, certPEM)) {
+		t.Error("expected the certificate of the key store")
+	}
+}
+
+func pemBlock(t *testing.T, data []byte) []byte {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatal("no PEM block found")
+	}
+	return block.Bytes
+}
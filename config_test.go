@@ -1,8 +1,11 @@
 package sarama
 
 import (
+	"net"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/rcrowley/go-metrics"
 )
@@ -25,6 +28,27 @@ func TestInvalidClientIDConfigValidates(t *testing.T) {
 	}
 }
 
+func TestInvalidClientSoftwareConfigValidates(t *testing.T) {
+	config := NewTestConfig()
+	config.ClientSoftwareName = "my app"
+	if err := config.Validate(); string(err.(ConfigurationError)) != "ClientSoftwareName is invalid" {
+		t.Error("Expected invalid ClientSoftwareName, got ", err)
+	}
+
+	config = NewTestConfig()
+	config.ClientSoftwareVersion = "1.0-"
+	if err := config.Validate(); string(err.(ConfigurationError)) != "ClientSoftwareVersion is invalid" {
+		t.Error("Expected invalid ClientSoftwareVersion, got ", err)
+	}
+
+	config = NewTestConfig()
+	config.ClientSoftwareName = "my-app"
+	config.ClientSoftwareVersion = "1.2.3-rc.1"
+	if err := config.Validate(); err != nil {
+		t.Error("Expected the client software to be valid, got ", err)
+	}
+}
+
 func TestEmptyClientIDConfigValidates(t *testing.T) {
 	config := NewTestConfig()
 	config.ClientID = ""
@@ -52,6 +76,13 @@ func TestNetConfigValidates(t *testing.T) {
 			},
 			"Net.MaxOpenRequests must be > 0",
 		},
+		{
+			"OpenRequestsByKey",
+			func(cfg *Config) {
+				cfg.Net.MaxOpenRequestsByKey = map[int16]int{1: 0}
+			},
+			"Net.MaxOpenRequestsByKey[1] must be > 0",
+		},
 		{
 			"DialTimeout",
 			func(cfg *Config) {
@@ -59,6 +90,28 @@ func TestNetConfigValidates(t *testing.T) {
 			},
 			"Net.DialTimeout must be > 0",
 		},
+		{
+			"CircuitBreakerCooldown",
+			func(cfg *Config) {
+				cfg.Net.CircuitBreaker.Failures = 3
+				cfg.Net.CircuitBreaker.Cooldown = 0
+			},
+			"Net.CircuitBreaker.Cooldown must be > 0",
+		},
+		{
+			"SocketSendBuffer",
+			func(cfg *Config) {
+				cfg.Net.Socket.SendBuffer = -1
+			},
+			"Net.Socket.SendBuffer must be >= 0",
+		},
+		{
+			"SocketUserTimeout",
+			func(cfg *Config) {
+				cfg.Net.Socket.UserTimeout = -1
+			},
+			"Net.Socket.UserTimeout must be >= 0",
+		},
 		{
 			"ReadTimeout",
 			func(cfg *Config) {
@@ -73,6 +126,42 @@ func TestNetConfigValidates(t *testing.T) {
 			},
 			"Net.WriteTimeout must be > 0",
 		},
+		{
+			"ConnectionsPerBroker",
+			func(cfg *Config) {
+				cfg.Net.ConnectionsPerBroker = 0
+			},
+			"Net.ConnectionsPerBroker must be > 0",
+		},
+		{
+			"DialFn with Proxy",
+			func(cfg *Config) {
+				cfg.Net.DialFn = (&net.Dialer{}).DialContext
+				cfg.Net.Proxy.Enable = true
+			},
+			"Net.DialFn and Net.Proxy cannot be used together",
+		},
+		{
+			"Proxy without dialer nor address",
+			func(cfg *Config) {
+				cfg.Net.Proxy.Enable = true
+			},
+			"Net.Proxy.Dialer or Net.Proxy.Address must be set when the proxy is enabled",
+		},
+		{
+			"Proxy.Type",
+			func(cfg *Config) {
+				cfg.Net.Proxy.Type = "socks4"
+			},
+			"Net.Proxy.Type \"socks4\" is invalid",
+		},
+		{
+			"Proxy.TLS without HTTP proxy",
+			func(cfg *Config) {
+				cfg.Net.Proxy.TLS.Enable = true
+			},
+			"Net.Proxy.TLS can only be enabled with ProxyTypeHTTP",
+		},
 		{
 			"SASL.User",
 			func(cfg *Config) {
@@ -97,38 +186,24 @@ func TestNetConfigValidates(t *testing.T) {
 				cfg.Net.SASL.Mechanism = "AnIncorrectSASLMechanism"
 				cfg.Net.SASL.TokenProvider = &DummyTokenProvider{}
 			},
-			"The SASL mechanism configuration is invalid. Possible values are `OAUTHBEARER`, `PLAIN`, `SCRAM-SHA-256`, `SCRAM-SHA-512` and `GSSAPI`",
+			"The SASL mechanism configuration is invalid. Possible values are `OAUTHBEARER`, `PLAIN`, `SCRAM-SHA-256`, `SCRAM-SHA-512`, `GSSAPI`, `AWS_MSK_IAM` and the registered mechanisms",
 		},
 		{
-			"SASL.Mechanism.OAUTHBEARER - Missing token provider",
+			"SASL.Mechanism.AWS_MSK_IAM - Missing region",
 			func(cfg *Config) {
 				cfg.Net.SASL.Enable = true
-				cfg.Net.SASL.Mechanism = SASLTypeOAuth
-				cfg.Net.SASL.TokenProvider = nil
+				cfg.Net.SASL.Mechanism = SASLTypeAWSMSKIAM
 			},
-			"An AccessTokenProvider instance must be provided to Net.SASL.TokenProvider",
+			"Net.SASL.AWSMSKIAM.Region must be set when the AWS_MSK_IAM mechanism is used, unless AWS_REGION is",
 		},
 		{
-			"SASL.Mechanism SCRAM-SHA-256 - Missing SCRAM client",
-			func(cfg *Config) {
-				cfg.Net.SASL.Enable = true
-				cfg.Net.SASL.Mechanism = SASLTypeSCRAMSHA256
-				cfg.Net.SASL.SCRAMClientGeneratorFunc = nil
-				cfg.Net.SASL.User = "user"
-				cfg.Net.SASL.Password = "strong_password"
-			},
-			"A SCRAMClientGeneratorFunc function must be provided to Net.SASL.SCRAMClientGeneratorFunc",
-		},
-		{
-			"SASL.Mechanism SCRAM-SHA-512 - Missing SCRAM client",
+			"SASL.Mechanism.OAUTHBEARER - Missing token provider",
 			func(cfg *Config) {
 				cfg.Net.SASL.Enable = true
-				cfg.Net.SASL.Mechanism = SASLTypeSCRAMSHA512
-				cfg.Net.SASL.SCRAMClientGeneratorFunc = nil
-				cfg.Net.SASL.User = "user"
-				cfg.Net.SASL.Password = "strong_password"
+				cfg.Net.SASL.Mechanism = SASLTypeOAuth
+				cfg.Net.SASL.TokenProvider = nil
 			},
-			"A SCRAMClientGeneratorFunc function must be provided to Net.SASL.SCRAMClientGeneratorFunc",
+			"An AccessTokenProvider instance must be provided to Net.SASL.TokenProvider",
 		},
 		{
 			"SASL.Mechanism GSSAPI (Kerberos) - Using User/Password, Missing password field",
@@ -195,7 +270,18 @@ func TestNetConfigValidates(t *testing.T) {
 				cfg.Net.SASL.GSSAPI.Realm = "kafka"
 				cfg.Net.SASL.GSSAPI.KerberosConfigPath = "/etc/krb5.conf"
 			},
-			"Net.SASL.GSSAPI.AuthType is invalid. Possible values are KRB5_USER_AUTH and KRB5_KEYTAB_AUTH",
+			"Net.SASL.GSSAPI.AuthType is invalid. Possible values are KRB5_USER_AUTH, KRB5_KEYTAB_AUTH and KRB5_CCACHE_AUTH",
+		},
+		{
+			"SASL.Mechanism GSSAPI (Kerberos) - Using KRB5_CCACHE_AUTH without CCachePath",
+			func(cfg *Config) {
+				cfg.Net.SASL.Enable = true
+				cfg.Net.SASL.GSSAPI.ServiceName = "kafka"
+				cfg.Net.SASL.Mechanism = SASLTypeGSSAPI
+				cfg.Net.SASL.GSSAPI.AuthType = KRB5_CCACHE_AUTH
+				cfg.Net.SASL.GSSAPI.KerberosConfigPath = "/etc/krb5.conf"
+			},
+			"Net.SASL.GSSAPI.CCachePath or Net.SASL.GSSAPI.CCacheFunc must be set when GSS-API mechanism is used and Net.SASL.GSSAPI.AuthType = KRB5_CCACHE_AUTH",
 		},
 		{
 			"SASL.Mechanism GSSAPI (Kerberos) - Missing KerberosConfigPath",
@@ -285,6 +371,13 @@ func TestAdminConfigValidates(t *testing.T) {
 			},
 			"Admin.Timeout must be > 0",
 		},
+		{
+			"Reassignment.PollInterval",
+			func(cfg *Config) {
+				cfg.Admin.Reassignment.PollInterval = 0
+			},
+			"Admin.Reassignment.PollInterval must be > 0",
+		},
 	}
 
 	for i, test := range tests {
@@ -414,6 +507,28 @@ func TestProducerConfigValidates(t *testing.T) {
 			},
 			"Idempotent producer requires Net.MaxOpenRequests to be 1",
 		},
+		{
+			"TopicOverrides.Flush.MaxMessages",
+			func(cfg *Config) {
+				var overrides ProducerTopicOverrides
+				overrides.Flush.MaxMessages = 1
+				cfg.Producer.Flush.Messages = 2
+				cfg.Producer.TopicOverrides = map[string]ProducerTopicOverrides{"my_topic": overrides}
+			},
+			"Producer.TopicOverrides[my_topic].Flush.MaxMessages must be >= Flush.Messages when set",
+		},
+		{
+			"Idempotent with TopicOverrides.RequiredAcks",
+			func(cfg *Config) {
+				cfg.Version = V0_11_0_0
+				cfg.Producer.Idempotent = true
+				cfg.Producer.RequiredAcks = WaitForAll
+				cfg.Net.MaxOpenRequests = 1
+				acks := WaitForLocal
+				cfg.Producer.TopicOverrides = map[string]ProducerTopicOverrides{"my_topic": {RequiredAcks: &acks}}
+			},
+			"Producer.TopicOverrides[my_topic].RequiredAcks must be WaitForAll for the idempotent producer",
+		},
 	}
 
 	for i, test := range tests {
@@ -447,6 +562,69 @@ func TestConsumerConfigValidates(t *testing.T) {
 			},
 			"Consumer.IsolationLevel must be ReadUncommitted or ReadCommitted",
 		},
+		{
+			"TopicOverrides.Fetch.Min",
+			func(cfg *Config) {
+				var overrides ConsumerTopicOverrides
+				overrides.Fetch.Min = -1
+				cfg.Consumer.TopicOverrides = map[string]ConsumerTopicOverrides{"my_topic": overrides}
+			},
+			"Consumer.TopicOverrides[my_topic].Fetch.Min must be >= 0",
+		},
+		{
+			"TopicOverrides ReadCommitted Version",
+			func(cfg *Config) {
+				cfg.Version = V0_10_0_0
+				isolation := ReadCommitted
+				cfg.Consumer.TopicOverrides = map[string]ConsumerTopicOverrides{"my_topic": {IsolationLevel: &isolation}}
+			},
+			"Consumer.TopicOverrides[my_topic]: ReadCommitted requires Version >= V0_11_0_0",
+		},
+		{
+			"Incorrect group protocol",
+			func(cfg *Config) {
+				cfg.Consumer.Group.Protocol = ConsumerGroupProtocol(42)
+			},
+			"Consumer.Group.Protocol must be GroupProtocolClassic or GroupProtocolConsumer",
+		},
+		{
+			"Incorrect out of range policy",
+			func(cfg *Config) {
+				cfg.Consumer.Offsets.OutOfRange.Policy = OffsetOutOfRangePolicy(42)
+			},
+			"Consumer.Offsets.OutOfRange.Policy is invalid",
+		},
+		{
+			"Incorrect topic out of range policy",
+			func(cfg *Config) {
+				cfg.Consumer.Offsets.OutOfRange.Topics = map[string]OffsetOutOfRangePolicy{
+					"my_topic": OffsetOutOfRangePolicy(-1),
+				}
+			},
+			"Consumer.Offsets.OutOfRange.Topics has an invalid policy for topic my_topic",
+		},
+		{
+			"Negative heartbeat timeout",
+			func(cfg *Config) {
+				cfg.Consumer.Group.Heartbeat.DedicatedConnection = true
+				cfg.Consumer.Group.Heartbeat.Timeout = -1
+			},
+			"Consumer.Group.Heartbeat.Timeout must be >= 0",
+		},
+		{
+			"Negative max poll interval",
+			func(cfg *Config) {
+				cfg.Consumer.Group.MaxPollInterval = -1
+			},
+			"Consumer.Group.MaxPollInterval must be >= 0",
+		},
+		{
+			"Nil group middleware",
+			func(cfg *Config) {
+				cfg.Consumer.Group.Middlewares = []ConsumerGroupHandlerMiddleware{nil}
+			},
+			"Consumer.Group.Middlewares must not contain nil middlewares",
+		},
 	}
 
 	for i, test := range tests {
@@ -482,6 +660,52 @@ func TestZstdConfigValidation(t *testing.T) {
 	}
 }
 
+func TestConfigWarnings(t *testing.T) {
+	config := NewTestConfig()
+	if warnings := config.Warnings(); len(warnings) != 1 || warnings[0].Field != "ClientID" {
+		t.Errorf("Expected only the default ClientID warning, got %v", warnings)
+	}
+
+	config.ClientID = "my-service"
+	config.Producer.RequiredAcks = WaitForAll
+	config.Consumer.Fetch.Max = 1024
+	config.Consumer.Group.Heartbeat.Interval = 5 * time.Second
+	var fields []string
+	for _, warning := range config.Warnings() {
+		fields = append(fields, warning.Field)
+	}
+	expected := []string{"Producer.Idempotent", "Consumer.Fetch.Max", "Consumer.Group.Heartbeat.Interval"}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected warnings on %v, got %v", expected, fields)
+	}
+}
+
+func TestConfigValidateStrict(t *testing.T) {
+	config := NewTestConfig()
+	config.Producer.Retry.Backoff = time.Millisecond
+	err := config.ValidateStrict()
+	expected := "2 warnings in strict mode: " +
+		"Producer.Retry: Producer.Retry.Max retries wait less than Metadata.Retry.Backoff; the messages may fail before the metadata is refreshed.; " +
+		"ClientID: ClientID is the default of 'sarama', you should consider setting it to something application-specific."
+	if cerr, ok := err.(ConfigurationError); !ok || string(cerr) != expected {
+		t.Errorf("Expected %q, got %v", expected, err)
+	}
+	if err := config.Validate(); err != nil {
+		t.Error("Expected the warnings not to fail Validate, got", err)
+	}
+
+	config.ClientID = "my-service"
+	config.Producer.Retry.Backoff = 100 * time.Millisecond
+	if err := config.ValidateStrict(); err != nil {
+		t.Error("Expected no warning, got", err)
+	}
+
+	config.Producer.RequiredAcks = -2
+	if err := config.ValidateStrict(); err == nil || string(err.(ConfigurationError)) != "Producer.RequiredAcks must be >= -1" {
+		t.Error("Expected the error of Validate, got", err)
+	}
+}
+
 // This example shows how to integrate with an existing registry as well as publishing metrics
 // on the standard output
 func ExampleConfig_metrics() {
@@ -1,19 +1,18 @@
 package sarama
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
 	"sync"
 	"sync/atomic"
 	"time"
-
-	"github.com/rcrowley/go-metrics"
 )
 
 // ConsumerMessage encapsulates a Kafka message returned by the consumer.
 type ConsumerMessage struct {
-	Headers        []*RecordHeader // only set if kafka is version 0.11+
+	Headers        []*RecordHeader // only set if kafka is version 0.11+, in the order of the record
 	Timestamp      time.Time       // only set if kafka is version 0.10+, inner message timestamp
 	BlockTimestamp time.Time       // only set if kafka is version 0.10+, outer (compressed) block timestamp
 
@@ -21,6 +20,65 @@ type ConsumerMessage struct {
 	Topic      string
 	Partition  int32
 	Offset     int64
+
+	// DeserializedKey and DeserializedValue are the Key and Value
+	// deserialized with Consumer.KeyDeserializer and ValueDeserializer, nil
+	// without them.
+	DeserializedKey, DeserializedValue interface{}
+
+	// Context is the context of the span of the message started with
+	// Tracing.Tracer, child of the span context of its headers, or nil
+	// without a Tracer.
+	Context context.Context
+
+	// buffer is the pooled buffer referenced by Key, Value and Headers, see
+	// Consumer.Fetch.PooledBuffers
+	buffer *pooledBuffer
+}
+
+// Release returns the buffer the message was decoded from to the pool of
+// Consumer.Fetch.PooledBuffers once all the messages decoded from it are
+// released. The Key, Value and Headers of the message must not be used
+// afterwards. It does nothing when the buffers are not pooled.
+func (m *ConsumerMessage) Release() {
+	if m.buffer != nil {
+		m.buffer.release()
+		m.buffer = nil
+	}
+}
+
+// Header returns the value of the last header of the message with the key,
+// like lastHeader of the Java client as the headers added along the way, such
+// as by interceptors, follow the original ones, and whether there is one.
+func (m *ConsumerMessage) Header(key string) ([]byte, bool) {
+	for i := len(m.Headers) - 1; i >= 0; i-- {
+		if h := m.Headers[i]; h != nil && string(h.Key) == key {
+			return h.Value, true
+		}
+	}
+	return nil, false
+}
+
+// HeaderValues returns the values of all the headers of the message with the
+// key, in the order of the record.
+func (m *ConsumerMessage) HeaderValues(key string) [][]byte {
+	var values [][]byte
+	for _, h := range m.Headers {
+		if h != nil && string(h.Key) == key {
+			values = append(values, h.Value)
+		}
+	}
+	return values
+}
+
+// RangeHeaders calls f with the key and the value of each header of the
+// message, in the order of the record, until f returns false.
+func (m *ConsumerMessage) RangeHeaders(f func(key, value []byte) bool) {
+	for _, h := range m.Headers {
+		if h != nil && !f(h.Key, h.Value) {
+			return
+		}
+	}
 }
 
 // ConsumerError is what is provided to the user when an error occurs.
@@ -71,6 +129,26 @@ type Consumer interface {
 	// Consistency between partitions is not guaranteed since high water marks are updated separately.
 	HighWaterMarks() map[string]map[int32]int64
 
+	// Pause suspends fetching from the requested partitions. Future calls to the broker will not return any
+	// records from these partitions until they have been resumed using Resume()/ResumeAll().
+	// Note that this method does not affect partition subscription.
+	// In particular, it does not cause a group rebalance when automatic assignment is used.
+	Pause(topicPartitions map[string][]int32)
+
+	// Resume resumes specified partitions which have been paused with Pause()/PauseAll().
+	// New calls to the broker will return records from these partitions if there are any to be fetched.
+	Resume(topicPartitions map[string][]int32)
+
+	// PauseAll suspends fetching from all partitions. Future calls to the broker will not return any
+	// records from these partitions until they have been resumed using Resume()/ResumeAll().
+	// Note that this method does not affect partition subscription.
+	// In particular, it does not cause a group rebalance when automatic assignment is used.
+	PauseAll()
+
+	// ResumeAll resumes all partitions which have been paused with Pause()/PauseAll().
+	// New calls to the broker will return records from these partitions if there are any to be fetched.
+	ResumeAll()
+
 	// Close shuts down the consumer. It must be called after all child
 	// PartitionConsumers have already been closed.
 	Close() error
@@ -79,7 +157,7 @@ type Consumer interface {
 type consumer struct {
 	conf            *Config
 	children        map[string]map[int32]*partitionConsumer
-	brokerConsumers map[*Broker]*brokerConsumer
+	brokerConsumers map[brokerConsumerKey]*brokerConsumer
 	client          Client
 	lock            sync.Mutex
 }
@@ -112,7 +190,7 @@ func newConsumer(client Client) (Consumer, error) {
 		client:          client,
 		conf:            client.Config(),
 		children:        make(map[string]map[int32]*partitionConsumer),
-		brokerConsumers: make(map[*Broker]*brokerConsumer),
+		brokerConsumers: make(map[brokerConsumerKey]*brokerConsumer),
 	}
 
 	return c, nil
@@ -141,7 +219,7 @@ func (c *consumer) ConsumePartition(topic string, partition int32, offset int64)
 		feeder:    make(chan *FetchResponse, 1),
 		trigger:   make(chan none, 1),
 		dying:     make(chan none),
-		fetchSize: c.conf.Consumer.Fetch.Default,
+		fetchSize: c.conf.consumerSettings(topic).fetchDefault,
 	}
 
 	if err := child.chooseStartingOffset(offset); err != nil {
@@ -153,6 +231,12 @@ func (c *consumer) ConsumePartition(topic string, partition int32, offset int64)
 	if leader, err = c.client.Leader(child.topic, child.partition); err != nil {
 		return nil, err
 	}
+	if c.conf.Version.IsAtLeast(V3_1_0_0) {
+		// fetch requests identify the topics by their IDs when all are known
+		if topicID, err := c.client.TopicID(child.topic); err == nil {
+			child.topicID = topicID
+		}
+	}
 
 	if err := c.addChild(child); err != nil {
 		return nil, err
@@ -161,7 +245,7 @@ func (c *consumer) ConsumePartition(topic string, partition int32, offset int64)
 	go withRecover(child.dispatcher)
 	go withRecover(child.responseFeeder)
 
-	child.broker = c.refBrokerConsumer(leader)
+	child.broker = c.refBrokerConsumer(leader, child.topic)
 	child.broker.input <- child
 
 	return child, nil
@@ -183,6 +267,62 @@ func (c *consumer) HighWaterMarks() map[string]map[int32]int64 {
 	return hwms
 }
 
+// Pause implements Consumer.
+func (c *consumer) Pause(topicPartitions map[string][]int32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for topic, partitions := range topicPartitions {
+		for _, partition := range partitions {
+			if topicConsumers, ok := c.children[topic]; ok {
+				if partitionConsumer, ok := topicConsumers[partition]; ok {
+					partitionConsumer.Pause()
+				}
+			}
+		}
+	}
+}
+
+// Resume implements Consumer.
+func (c *consumer) Resume(topicPartitions map[string][]int32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for topic, partitions := range topicPartitions {
+		for _, partition := range partitions {
+			if topicConsumers, ok := c.children[topic]; ok {
+				if partitionConsumer, ok := topicConsumers[partition]; ok {
+					partitionConsumer.Resume()
+				}
+			}
+		}
+	}
+}
+
+// PauseAll implements Consumer.
+func (c *consumer) PauseAll() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, partitions := range c.children {
+		for _, child := range partitions {
+			child.Pause()
+		}
+	}
+}
+
+// ResumeAll implements Consumer.
+func (c *consumer) ResumeAll() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, partitions := range c.children {
+		for _, child := range partitions {
+			child.Resume()
+		}
+	}
+}
+
 func (c *consumer) addChild(child *partitionConsumer) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -208,14 +348,22 @@ func (c *consumer) removeChild(child *partitionConsumer) {
 	delete(c.children[child.topic], child.partition)
 }
 
-func (c *consumer) refBrokerConsumer(broker *Broker) *brokerConsumer {
+// brokerConsumerKey identifies the brokerConsumer of a broker for the
+// partitions of a topic, see Config.consumerBatch
+type brokerConsumerKey struct {
+	broker *Broker
+	topic  string
+}
+
+func (c *consumer) refBrokerConsumer(broker *Broker, topic string) *brokerConsumer {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	bc := c.brokerConsumers[broker]
+	key := brokerConsumerKey{broker: broker, topic: c.conf.consumerBatch(topic)}
+	bc := c.brokerConsumers[key]
 	if bc == nil {
-		bc = c.newBrokerConsumer(broker)
-		c.brokerConsumers[broker] = bc
+		bc = c.newBrokerConsumer(broker, key.topic)
+		c.brokerConsumers[key] = bc
 	}
 
 	bc.refs++
@@ -231,8 +379,9 @@ func (c *consumer) unrefBrokerConsumer(brokerWorker *brokerConsumer) {
 
 	if brokerWorker.refs == 0 {
 		close(brokerWorker.input)
-		if c.brokerConsumers[brokerWorker.broker] == brokerWorker {
-			delete(c.brokerConsumers, brokerWorker.broker)
+		key := brokerConsumerKey{broker: brokerWorker.broker, topic: brokerWorker.topic}
+		if c.brokerConsumers[key] == brokerWorker {
+			delete(c.brokerConsumers, key)
 		}
 	}
 }
@@ -241,7 +390,7 @@ func (c *consumer) abandonBrokerConsumer(brokerWorker *brokerConsumer) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	delete(c.brokerConsumers, brokerWorker.broker)
+	delete(c.brokerConsumers, brokerConsumerKey{broker: brokerWorker.broker, topic: brokerWorker.topic})
 }
 
 // PartitionConsumer
@@ -291,6 +440,20 @@ type PartitionConsumer interface {
 	// i.e. the offset that will be used for the next message that will be produced.
 	// You can use this to determine how far behind the processing is.
 	HighWaterMarkOffset() int64
+
+	// Pause suspends fetching from this partition. Future calls to the broker will not return
+	// any records from these partition until it have been resumed using Resume().
+	// Note that this method does not affect partition subscription.
+	// In particular, it does not cause a group rebalance when automatic assignment is used.
+	Pause()
+
+	// Resume resumes this partition which have been paused with Pause().
+	// New calls to the broker will return records from these partitions if there are any to be fetched.
+	// If the partition was not previously paused, this method is a no-op.
+	Resume()
+
+	// IsPaused indicates if this partition consumer is paused or not
+	IsPaused() bool
 }
 
 type partitionConsumer struct {
@@ -308,11 +471,17 @@ type partitionConsumer struct {
 	trigger, dying chan none
 	closeOnce      sync.Once
 	topic          string
+	topicID        Uuid // zero when unknown
 	partition      int32
 	responseResult error
 	fetchSize      int32
 	offset         int64
 	retries        int32
+
+	paused int32
+	// fetching is whether the partition is part of the current fetch request,
+	// only used by the broker consumer
+	fetching bool
 }
 
 var errTimedOut = errors.New("timed out feeding messages to the user") // not user-facing
@@ -327,11 +496,15 @@ func (child *partitionConsumer) sendError(err error) {
 	if child.conf.Consumer.Return.Errors {
 		child.errors <- cErr
 	} else {
-		Logger.Println(cErr)
+		logEntry(LogComponentConsumer, LogLevelError, "consumer/partition error", topicField(child.topic), partitionField(child.partition), errorField(err))
 	}
 }
 
 func (child *partitionConsumer) computeBackoff() time.Duration {
+	if child.conf.Consumer.Retry.Policy != nil {
+		retries := atomic.AddInt32(&child.retries, 1)
+		return child.conf.Consumer.Retry.Policy.Backoff(int(retries), 0)
+	}
 	if child.conf.Consumer.Retry.BackoffFunc != nil {
 		retries := atomic.AddInt32(&child.retries, 1)
 		return child.conf.Consumer.Retry.BackoffFunc(int(retries))
@@ -350,7 +523,7 @@ func (child *partitionConsumer) dispatcher() {
 				child.broker = nil
 			}
 
-			Logger.Printf("consumer/%s/%d finding new broker\n", child.topic, child.partition)
+			logEntry(LogComponentConsumer, LogLevelInfo, "consumer/partition finding new broker", topicField(child.topic), partitionField(child.partition))
 			if err := child.dispatch(); err != nil {
 				child.sendError(err)
 				child.trigger <- none{}
@@ -368,12 +541,12 @@ func (child *partitionConsumer) dispatcher() {
 func (child *partitionConsumer) preferredBroker() (*Broker, error) {
 	if child.preferredReadReplica >= 0 {
 		broker, err := child.consumer.client.Broker(child.preferredReadReplica)
-		if err == nil {
+		if err == nil && !broker.circuitOpen() {
 			return broker, nil
 		}
 	}
 
-	// if preferred replica cannot be found fallback to leader
+	// if preferred replica cannot be found, or keeps failing, fallback to leader
 	return child.consumer.client.Leader(child.topic, child.partition)
 }
 
@@ -387,7 +560,7 @@ func (child *partitionConsumer) dispatch() error {
 		return err
 	}
 
-	child.broker = child.consumer.refBrokerConsumer(broker)
+	child.broker = child.consumer.refBrokerConsumer(broker, child.topic)
 
 	child.broker.input <- child
 
@@ -454,6 +627,18 @@ func (child *partitionConsumer) HighWaterMarkOffset() int64 {
 	return atomic.LoadInt64(&child.highWaterMarkOffset)
 }
 
+func (child *partitionConsumer) Pause() {
+	atomic.StoreInt32(&child.paused, 1)
+}
+
+func (child *partitionConsumer) Resume() {
+	atomic.StoreInt32(&child.paused, 0)
+}
+
+func (child *partitionConsumer) IsPaused() bool {
+	return atomic.LoadInt32(&child.paused) == 1
+}
+
 func (child *partitionConsumer) responseFeeder() {
 	var msgs []*ConsumerMessage
 	expiryTicker := time.NewTicker(child.conf.Consumer.MaxProcessingTime)
@@ -466,12 +651,21 @@ feederLoop:
 		if child.responseResult == nil {
 			atomic.StoreInt32(&child.retries, 0)
 		}
+		if response.buffer != nil && len(msgs) > 0 {
+			response.buffer.retain(len(msgs))
+			for _, msg := range msgs {
+				msg.buffer = response.buffer
+			}
+		}
 
 		for i, msg := range msgs {
-			child.interceptors(msg)
+			child.prepareMessage(msg)
 		messageSelect:
 			select {
 			case <-child.dying:
+				for _, msg := range msgs[i:] {
+					msg.Release()
+				}
 				child.broker.acks.Done()
 				continue feederLoop
 			case child.messages <- msg:
@@ -481,11 +675,14 @@ feederLoop:
 					child.responseResult = errTimedOut
 					child.broker.acks.Done()
 				remainingLoop:
-					for _, msg = range msgs[i:] {
-						child.interceptors(msg)
+					for j, msg := range msgs[i:] {
+						child.prepareMessage(msg)
 						select {
 						case child.messages <- msg:
 						case <-child.dying:
+							for _, msg := range msgs[i+j:] {
+								msg.Release()
+							}
 							break remainingLoop
 						}
 					}
@@ -504,6 +701,9 @@ feederLoop:
 	}
 
 	expiryTicker.Stop()
+	if recorder := child.conf.metricsRecorder(); recorder != nil {
+		recorder.Unregister("consumer-records-lag", topicLabel(child.topic), partitionLabel(child.partition))
+	}
 	close(child.messages)
 	close(child.errors)
 }
@@ -572,20 +772,12 @@ func (child *partitionConsumer) parseRecords(batch *RecordBatch) ([]*ConsumerMes
 }
 
 func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*ConsumerMessage, error) {
-	var (
-		metricRegistry          = child.conf.MetricRegistry
-		consumerBatchSizeMetric metrics.Histogram
-	)
-
-	if metricRegistry != nil {
-		consumerBatchSizeMetric = getOrRegisterHistogram("consumer-batch-size", metricRegistry)
-	}
+	recorder := child.conf.metricsRecorder()
 
 	// If request was throttled and empty we log and return without error
 	if response.ThrottleTime != time.Duration(0) && len(response.Blocks) == 0 {
-		Logger.Printf(
-			"consumer/broker/%d FetchResponse throttled %v\n",
-			child.broker.broker.ID(), response.ThrottleTime)
+		logEntry(LogComponentConsumer, LogLevelInfo, "consumer/broker FetchResponse throttled",
+			brokerField(child.broker.broker.ID()), LogField{Key: "throttle", Value: response.ThrottleTime})
 		return nil, nil
 	}
 
@@ -598,12 +790,18 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 		return nil, block.Err
 	}
 
+	if err := block.decodeRecords(); err != nil {
+		return nil, err
+	}
+
 	nRecs, err := block.numRecords()
 	if err != nil {
 		return nil, err
 	}
 
-	consumerBatchSizeMetric.Update(int64(nRecs))
+	if recorder != nil {
+		recorder.Histogram("consumer-batch-size").Update(int64(nRecs))
+	}
 
 	child.preferredReadReplica = block.PreferredReadReplica
 
@@ -615,7 +813,8 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 		// We got no messages. If we got a trailing one then we need to ask for more data.
 		// Otherwise we just poll again and wait for one to be produced...
 		if partialTrailingMessage {
-			if child.conf.Consumer.Fetch.Max > 0 && child.fetchSize == child.conf.Consumer.Fetch.Max {
+			fetchMax := child.conf.consumerSettings(child.topic).fetchMax
+			if fetchMax > 0 && child.fetchSize == fetchMax {
 				// we can't ask for more data, we've hit the configured limit
 				child.sendError(ErrMessageTooLarge)
 				child.offset++ // skip this one so we can keep processing future messages
@@ -625,17 +824,19 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 				if child.fetchSize < 0 {
 					child.fetchSize = math.MaxInt32
 				}
-				if child.conf.Consumer.Fetch.Max > 0 && child.fetchSize > child.conf.Consumer.Fetch.Max {
-					child.fetchSize = child.conf.Consumer.Fetch.Max
+				if fetchMax > 0 && child.fetchSize > fetchMax {
+					child.fetchSize = fetchMax
 				}
 			}
 		}
 
+		child.updateFetchMetrics(recorder, block, nil)
 		return nil, nil
 	}
 
 	// we got messages, reset our fetch size in case it was increased for a previous request
-	child.fetchSize = child.conf.Consumer.Fetch.Default
+	settings := child.conf.consumerSettings(child.topic)
+	child.fetchSize = settings.fetchDefault
 	atomic.StoreInt64(&child.highWaterMarkOffset, block.HighWaterMarkOffset)
 
 	// abortedProducerIDs contains producerID which message should be ignored as uncommitted
@@ -680,7 +881,7 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 				// I don't know why there is this continue in case of error to begin with
 				// Safe bet is to ignore control messages if ReadUncommitted
 				// and block on them in case of error and ReadCommitted
-				if child.conf.Consumer.IsolationLevel == ReadCommitted {
+				if settings.isolationLevel == ReadCommitted {
 					return nil, err
 				}
 				continue
@@ -698,7 +899,7 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 			}
 
 			// filter aborted transactions
-			if child.conf.Consumer.IsolationLevel == ReadCommitted {
+			if settings.isolationLevel == ReadCommitted {
 				_, isAborted := abortedProducerIDs[records.RecordBatch.ProducerID]
 				if records.RecordBatch.IsTransactional && isAborted {
 					continue
@@ -711,18 +912,50 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 		}
 	}
 
+	child.updateFetchMetrics(recorder, block, messages)
 	return messages, nil
 }
 
-func (child *partitionConsumer) interceptors(msg *ConsumerMessage) {
+// updateFetchMetrics records the bytes of block and the messages consumed
+// from it, and the lag of the partition once they are
+func (child *partitionConsumer) updateFetchMetrics(recorder MetricsRecorder, block *FetchResponseBlock, messages []*ConsumerMessage) {
+	if recorder == nil {
+		return
+	}
+	topic := topicLabel(child.topic)
+	recorder.Meter("consumer-bytes-consumed-rate").Mark(int64(block.recordsSize))
+	recorder.Meter("consumer-bytes-consumed-rate", topic).Mark(int64(block.recordsSize))
+	recorder.Meter("consumer-records-consumed-rate").Mark(int64(len(messages)))
+	recorder.Meter("consumer-records-consumed-rate", topic).Mark(int64(len(messages)))
+
+	lag := block.HighWaterMarkOffset - child.offset
+	if lag < 0 {
+		lag = 0
+	}
+	recorder.Histogram("consumer-records-lag").Update(lag)
+	recorder.Histogram("consumer-records-lag", topic, partitionLabel(child.partition)).Update(lag)
+}
+
+// prepareMessage deserializes msg, applies the interceptors to it and records
+// its span, right before its delivery
+func (child *partitionConsumer) prepareMessage(msg *ConsumerMessage) {
+	if err := msg.deserialize(child.conf); err != nil {
+		child.sendError(fmt.Errorf("deserializing the message at offset %d: %w", msg.Offset, err))
+	}
 	for _, interceptor := range child.conf.Consumer.Interceptors {
 		msg.safelyApplyInterceptor(interceptor)
 	}
+	if tracer := child.conf.Tracing.Tracer; tracer != nil {
+		traceConsumerMessage(tracer, msg)
+	}
 }
 
 type brokerConsumer struct {
-	consumer         *consumer
-	broker           *Broker
+	consumer *consumer
+	broker   *Broker
+	// topic is the topic of the partitions when they have overrides, "" for
+	// the partitions of the other topics
+	topic            string
 	input            chan *partitionConsumer
 	newSubscriptions chan []*partitionConsumer
 	subscriptions    map[*partitionConsumer]none
@@ -731,10 +964,11 @@ type brokerConsumer struct {
 	refs             int
 }
 
-func (c *consumer) newBrokerConsumer(broker *Broker) *brokerConsumer {
+func (c *consumer) newBrokerConsumer(broker *Broker, topic string) *brokerConsumer {
 	bc := &brokerConsumer{
 		consumer:         c,
 		broker:           broker,
+		topic:            topic,
 		input:            make(chan *partitionConsumer),
 		newSubscriptions: make(chan []*partitionConsumer),
 		wait:             make(chan none),
@@ -802,32 +1036,74 @@ func (bc *brokerConsumer) subscriptionConsumer() {
 			continue
 		}
 
+		fetchStart := time.Now()
 		response, err := bc.fetchNewMessages()
 		if err != nil {
-			Logger.Printf("consumer/broker/%d disconnecting due to error processing FetchRequest: %s\n", bc.broker.ID(), err)
+			logEntry(LogComponentConsumer, LogLevelWarn, "consumer/broker disconnecting due to error processing FetchRequest", brokerField(bc.broker.ID()), errorField(err))
 			bc.abort(err)
 			return
 		}
+		fetchLatency := time.Since(fetchStart)
 
 		bc.acks.Add(len(bc.subscriptions))
 		for child := range bc.subscriptions {
+			if !child.fetching {
+				// paused partitions were not part of the request
+				bc.acks.Done()
+				continue
+			}
 			child.feeder <- response
 		}
 		bc.acks.Wait()
+		// the records are decoded once the partition consumers are done
+		bc.updateFetchMetrics(response, fetchLatency)
+		if response.buffer != nil {
+			// the messages hold their own references to the buffer
+			response.buffer.release()
+		}
 		bc.handleResponses()
 	}
 }
 
+// updateFetchMetrics records the latency of a fetch request, and the bytes
+// and the records of its response in total and by topic
+func (bc *brokerConsumer) updateFetchMetrics(response *FetchResponse, latency time.Duration) {
+	recorder := bc.consumer.conf.metricsRecorder()
+	if recorder == nil || response == nil {
+		return
+	}
+	recorder.Histogram("consumer-fetch-latency-in-ms").Update(int64(latency / time.Millisecond))
+
+	var bytes, records int64
+	for topic, blocks := range response.Blocks {
+		var topicBytes, topicRecords int64
+		for _, block := range blocks {
+			n, err := block.numRecords()
+			if err != nil {
+				continue
+			}
+			topicBytes += int64(block.recordsSize)
+			topicRecords += int64(n)
+		}
+		recorder.Histogram("consumer-fetch-size", topicLabel(topic)).Update(topicBytes)
+		recorder.Histogram("consumer-records-per-request", topicLabel(topic)).Update(topicRecords)
+		bytes += topicBytes
+		records += topicRecords
+	}
+	recorder.Histogram("consumer-fetch-size").Update(bytes)
+	recorder.Histogram("consumer-records-per-request").Update(records)
+}
+
 func (bc *brokerConsumer) updateSubscriptions(newSubscriptions []*partitionConsumer) {
 	for _, child := range newSubscriptions {
 		bc.subscriptions[child] = none{}
-		Logger.Printf("consumer/broker/%d added subscription to %s/%d\n", bc.broker.ID(), child.topic, child.partition)
+		logEntry(LogComponentConsumer, LogLevelInfo, "consumer/broker added subscription", brokerField(bc.broker.ID()), topicField(child.topic), partitionField(child.partition))
 	}
 
 	for child := range bc.subscriptions {
 		select {
 		case <-child.dying:
-			Logger.Printf("consumer/broker/%d closed dead subscription to %s/%d\n", bc.broker.ID(), child.topic, child.partition)
+			logEntry(LogComponentConsumer, LogLevelInfo, "consumer/broker closed dead subscription", brokerField(bc.broker.ID()), topicField(child.topic), partitionField(child.partition))
 			close(child.trigger)
 			delete(bc.subscriptions, child)
 		default:
@@ -858,27 +1134,27 @@ func (bc *brokerConsumer) handleResponses() {
 
 		switch result {
 		case errTimedOut:
-			Logger.Printf("consumer/broker/%d abandoned subscription to %s/%d because consuming was taking too long\n",
-				bc.broker.ID(), child.topic, child.partition)
+			logEntry(LogComponentConsumer, LogLevelWarn, "consumer/broker abandoned subscription because consuming was taking too long",
+				brokerField(bc.broker.ID()), topicField(child.topic), partitionField(child.partition))
 			delete(bc.subscriptions, child)
 		case ErrOffsetOutOfRange:
 			// there's no point in retrying this it will just fail the same way again
 			// shut it down and force the user to choose what to do
 			child.sendError(result)
-			Logger.Printf("consumer/%s/%d shutting down because %s\n", child.topic, child.partition, result)
+			logEntry(LogComponentConsumer, LogLevelError, "consumer/partition shutting down", topicField(child.topic), partitionField(child.partition), errorField(result))
 			close(child.trigger)
 			delete(bc.subscriptions, child)
 		case ErrUnknownTopicOrPartition, ErrNotLeaderForPartition, ErrLeaderNotAvailable, ErrReplicaNotAvailable:
 			// not an error, but does need redispatching
-			Logger.Printf("consumer/broker/%d abandoned subscription to %s/%d because %s\n",
-				bc.broker.ID(), child.topic, child.partition, result)
+			logEntry(LogComponentConsumer, LogLevelWarn, "consumer/broker abandoned subscription",
+				brokerField(bc.broker.ID()), topicField(child.topic), partitionField(child.partition), errorField(result))
 			child.trigger <- none{}
 			delete(bc.subscriptions, child)
 		default:
 			// dunno, tell the user and try redispatching
 			child.sendError(result)
-			Logger.Printf("consumer/broker/%d abandoned subscription to %s/%d because %s\n",
-				bc.broker.ID(), child.topic, child.partition, result)
+			logEntry(LogComponentConsumer, LogLevelWarn, "consumer/broker abandoned subscription",
+				brokerField(bc.broker.ID()), topicField(child.topic), partitionField(child.partition), errorField(result))
 			child.trigger <- none{}
 			delete(bc.subscriptions, child)
 		}
@@ -907,8 +1183,9 @@ func (bc *brokerConsumer) abort(err error) {
 }
 
 func (bc *brokerConsumer) fetchNewMessages() (*FetchResponse, error) {
+	settings := bc.consumer.conf.consumerSettings(bc.topic)
 	request := &FetchRequest{
-		MinBytes:    bc.consumer.conf.Consumer.Fetch.Min,
+		MinBytes:    settings.fetchMin,
 		MaxWaitTime: int32(bc.consumer.conf.Consumer.MaxWaitTime / time.Millisecond),
 	}
 	if bc.consumer.conf.Version.IsAtLeast(V0_9_0_0) {
@@ -923,7 +1200,7 @@ func (bc *brokerConsumer) fetchNewMessages() (*FetchResponse, error) {
 	}
 	if bc.consumer.conf.Version.IsAtLeast(V0_11_0_0) {
 		request.Version = 4
-		request.Isolation = bc.consumer.conf.Consumer.IsolationLevel
+		request.Isolation = settings.isolationLevel
 	}
 	if bc.consumer.conf.Version.IsAtLeast(V1_1_0_0) {
 		request.Version = 7
@@ -940,10 +1217,36 @@ func (bc *brokerConsumer) fetchNewMessages() (*FetchResponse, error) {
 		request.Version = 11
 		request.RackID = bc.consumer.conf.RackID
 	}
+	if bc.consumer.conf.Version.IsAtLeast(V2_7_0_0) {
+		request.Version = 12
+	}
+	if bc.consumer.conf.Version.IsAtLeast(V3_1_0_0) {
+		request.Version = 13
+		request.TopicIDs = make(map[string]Uuid)
+	}
 
 	for child := range bc.subscriptions {
-		request.AddBlock(child.topic, child.partition, child.offset, child.fetchSize)
+		child.fetching = !child.IsPaused()
+		if !child.fetching {
+			continue
+		}
+		if request.Version >= 13 {
+			if child.topicID == (Uuid{}) {
+				// the topic IDs are not all known, identify the topics by name
+				request.Version = 12
+				request.TopicIDs = nil
+			} else {
+				request.TopicIDs[child.topic] = child.topicID
+			}
+		}
+	}
+	for child := range bc.subscriptions {
+		if child.fetching {
+			request.AddBlock(child.topic, child.partition, child.offset, child.fetchSize)
+		}
 	}
 
-	return bc.broker.Fetch(request)
+	// the records are decoded by the partition consumers, so that a large
+	// compressed batch of a partition does not hold up the other ones
+	return bc.broker.fetch(request, true)
 }
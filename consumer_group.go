@@ -4,16 +4,82 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
-
-	"github.com/rcrowley/go-metrics"
 )
 
 // ErrClosedConsumerGroup is the error returned when a method is called on a consumer group that has been closed.
 var ErrClosedConsumerGroup = errors.New("kafka: tried to use a consumer group that was closed")
 
+// ConsumerGroupProtocol is the rebalance protocol used by the members of a consumer group.
+type ConsumerGroupProtocol int8
+
+const (
+	// GroupProtocolClassic is the JoinGroup and SyncGroup based protocol, where
+	// one of the members computes the assignment using Rebalance.Strategy.
+	GroupProtocolClassic ConsumerGroupProtocol = iota
+	// GroupProtocolConsumer is the ConsumerGroupHeartbeat based protocol of
+	// KIP-848, where the group coordinator computes the assignment.
+	GroupProtocolConsumer
+)
+
+// OffsetOutOfRangePolicy is what a consumer group does when the committed offset
+// of a partition is out of range of the offsets available on the broker.
+type OffsetOutOfRangePolicy int8
+
+const (
+	// OffsetOutOfRangeResetInitial resumes consumption from Consumer.Offsets.Initial.
+	OffsetOutOfRangeResetInitial OffsetOutOfRangePolicy = iota
+	// OffsetOutOfRangeResetOldest resumes consumption from the oldest available offset.
+	OffsetOutOfRangeResetOldest
+	// OffsetOutOfRangeResetNewest resumes consumption from the newest offset.
+	OffsetOutOfRangeResetNewest
+	// OffsetOutOfRangeFail does not consume the partition for the rest of the
+	// session, the other partitions being consumed as usual, and reports an
+	// *OffsetOutOfRangeError instead, on Errors and to the handler when it
+	// implements ConsumerGroupOffsetOutOfRangeListener.
+	OffsetOutOfRangeFail
+)
+
+func (p OffsetOutOfRangePolicy) valid() bool {
+	return p >= OffsetOutOfRangeResetInitial && p <= OffsetOutOfRangeFail
+}
+
+// OffsetOutOfRangeError is reported by a consumer group, wrapped in a
+// *ConsumerError, when the committed offset of a partition is out of range and
+// its OffsetOutOfRangePolicy is OffsetOutOfRangeFail. It is also given to the
+// OnOffsetOutOfRange of a ConsumerGroupOffsetOutOfRangeListener.
+type OffsetOutOfRangeError struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+}
+
+func (e *OffsetOutOfRangeError) Error() string {
+	return fmt.Sprintf("kafka: committed offset %d of %s/%d is out of range", e.Offset, e.Topic, e.Partition)
+}
+
+func (e *OffsetOutOfRangeError) Unwrap() error {
+	return ErrOffsetOutOfRange
+}
+
+// MaxPollIntervalExceededError is reported by a consumer group, wrapped in a
+// *ConsumerError, when a message of the partition was left unread and no
+// offset was marked for longer than Consumer.Group.MaxPollInterval, after
+// which the member leaves the group.
+type MaxPollIntervalExceededError struct {
+	Topic     string
+	Partition int32
+	Interval  time.Duration
+}
+
+func (e *MaxPollIntervalExceededError) Error() string {
+	return fmt.Sprintf("kafka: no progress on %s/%d for more than %s, leaving the group", e.Topic, e.Partition, e.Interval)
+}
+
 // ConsumerGroup is responsible for dividing up processing of topics and partitions
 // over a collection of processes (the members of the consumer group).
 type ConsumerGroup interface {
@@ -45,6 +111,15 @@ type ConsumerGroup interface {
 	// recreated to get the new claims.
 	Consume(ctx context.Context, topics []string, handler ConsumerGroupHandler) error
 
+	// ConsumePattern is like Consume, but subscribes to all the topics whose
+	// name matches pattern. It blocks until at least one topic matches.
+	// The cluster metadata is refreshed every Config.Metadata.RefreshFrequency,
+	// which must be set, and the session ends as soon as a topic starts or
+	// stops matching, so that the next call picks up the new subscription and
+	// rebalances the group. Like Consume, it should be called inside an
+	// infinite loop.
+	ConsumePattern(ctx context.Context, pattern *regexp.Regexp, handler ConsumerGroupHandler) error
+
 	// Errors returns a read channel of errors that occurred during the consumer life-cycle.
 	// By default, errors are logged and not returned over this channel.
 	// If you want to implement any custom error handling, set your config's
@@ -54,6 +129,40 @@ type ConsumerGroup interface {
 	// Close stops the ConsumerGroup and detaches any running sessions. It is required to call
 	// this function before the object passes out of scope, as it will otherwise leak memory.
 	Close() error
+
+	// CloseWithContext is like Close, but waits for the running session to be
+	// released before leaving the group: its partitions are revoked, the
+	// handler's Cleanup() hook is called and the marked offsets are committed
+	// one last time, even with Consumer.Offsets.AutoCommit disabled. The
+	// offsets which could not be committed are reported as OffsetCommitErrors.
+	// If ctx is done before the session is released, such as when the
+	// handler does not return, ctx.Err() is returned right away and the group
+	// is left and the client closed in the background once it is released.
+	CloseWithContext(ctx context.Context) error
+
+	// Pause suspends fetching from the requested partitions. Future calls to the broker will not return any
+	// records from these partitions until they have been resumed using Resume()/ResumeAll().
+	// Note that this method does not affect partition subscription.
+	// In particular, it does not cause a group rebalance when automatic assignment is used.
+	// Partitions stay paused across rebalances as long as they remain claimed by this member.
+	Pause(partitions map[string][]int32)
+
+	// Resume resumes specified partitions which have been paused with Pause()/PauseAll().
+	// New calls to the broker will return records from these partitions if there are any to be fetched.
+	Resume(partitions map[string][]int32)
+
+	// PauseAll suspends fetching from all partitions claimed by this member. Future calls to the broker
+	// will not return any records from these partitions until they have been resumed using Resume()/ResumeAll().
+	// Note that this method does not affect partition subscription.
+	// In particular, it does not cause a group rebalance when automatic assignment is used.
+	PauseAll()
+
+	// ResumeAll resumes all partitions which have been paused with Pause()/PauseAll().
+	// New calls to the broker will return records from these partitions if there are any to be fetched.
+	ResumeAll()
+
+	// Paused returns the paused partitions by topic.
+	Paused() map[string][]int32
 }
 
 type consumerGroup struct {
@@ -68,8 +177,40 @@ type consumerGroup struct {
 	lock      sync.Mutex
 	closed    chan none
 	closeOnce sync.Once
+	// finalCommit is set by CloseWithContext to commit the offsets when
+	// releasing the session, uncommitted holds the offsets which failed to
+	// be committed then and is protected by lock
+	finalCommit int32
+	uncommitted OffsetCommitErrors
+
+	// paused holds the paused partitions and claimed the claims of the
+	// current session, both protected by pausedLock
+	pausedLock sync.Mutex
+	paused     map[string]map[int32]none
+	claimed    map[string][]int32
 
 	userData []byte
+	// assignmentUserData is the user data of the last assignment
+	assignmentUserData []byte
+
+	// rebalanceReason is the reason the last session ended, the reason of
+	// the next rebalance, protected by lock
+	rebalanceReason string
+
+	// protocol is the rebalance protocol negotiated with the coordinator
+	protocol           ConsumerGroupProtocol
+	protocolNegotiated bool
+
+	// state of the member when using GroupProtocolConsumer
+	memberEpoch       int32
+	heartbeatInterval time.Duration
+	// topics is the subscription of the member, subscribedTopics the one
+	// acknowledged by the coordinator
+	topics, subscribedTopics []string
+	// owned is the assignment acknowledged to the coordinator, assignment
+	// the new one to consume with the next session
+	owned, assignment []ConsumerGroupHeartbeatTopicPartitions
+	topicNames        map[Uuid]string
 }
 
 // NewConsumerGroup creates a new consumer group the given broker addresses and configuration.
@@ -125,29 +266,232 @@ func (c *consumerGroup) Errors() <-chan error { return c.errors }
 func (c *consumerGroup) Close() (err error) {
 	c.closeOnce.Do(func() {
 		close(c.closed)
+		err = c.shutdown()
+	})
+	return
+}
 
-		// leave group
-		if e := c.leave(); e != nil {
-			err = e
-		}
+func (c *consumerGroup) CloseWithContext(ctx context.Context) (err error) {
+	err = ErrClosedConsumerGroup
+	c.closeOnce.Do(func() {
+		atomic.StoreInt32(&c.finalCommit, 1)
+		close(c.closed)
 
-		// drain errors
+		// the running Consume() holds the lock until its session is released
+		locked := make(chan none)
 		go func() {
-			close(c.errors)
+			c.lock.Lock()
+			close(locked)
 		}()
-		for e := range c.errors {
-			err = e
+
+		var uncommitted OffsetCommitErrors
+		select {
+		case <-locked:
+			uncommitted = c.uncommitted
+			c.lock.Unlock()
+		case <-ctx.Done():
+			// leaving needs the lock, so the group is left once the session
+			// is released rather than past the deadline of ctx
+			go func() {
+				<-locked
+				c.lock.Unlock()
+				if err := c.shutdown(); err != nil {
+					logEntry(LogComponentGroup, LogLevelWarn, "consumergroup/close failed", groupField(c.groupID), errorField(err))
+				}
+			}()
+			err = ctx.Err()
+			return
 		}
 
-		if e := c.client.Close(); e != nil {
-			err = e
+		err = c.shutdown()
+		if len(uncommitted) > 0 {
+			err = uncommitted
 		}
 	})
 	return
 }
 
+// shutdown leaves the group and closes the client once the group is closed.
+func (c *consumerGroup) shutdown() (err error) {
+	// leave group
+	if e := c.leave(); e != nil {
+		err = e
+	}
+
+	// drain errors
+	go func() {
+		close(c.errors)
+	}()
+	for e := range c.errors {
+		err = e
+	}
+
+	if e := c.client.Close(); e != nil {
+		err = e
+	}
+	return
+}
+
+// Pause implements ConsumerGroup.
+func (c *consumerGroup) Pause(partitions map[string][]int32) {
+	c.pausedLock.Lock()
+	defer c.pausedLock.Unlock()
+
+	for topic, ps := range partitions {
+		for _, partition := range ps {
+			c.addPausedLocked(topic, partition)
+		}
+	}
+	c.consumer.Pause(partitions)
+}
+
+// Resume implements ConsumerGroup.
+func (c *consumerGroup) Resume(partitions map[string][]int32) {
+	c.pausedLock.Lock()
+	defer c.pausedLock.Unlock()
+
+	for topic, ps := range partitions {
+		for _, partition := range ps {
+			delete(c.paused[topic], partition)
+		}
+		if len(c.paused[topic]) == 0 {
+			delete(c.paused, topic)
+		}
+	}
+	c.consumer.Resume(partitions)
+}
+
+// PauseAll implements ConsumerGroup.
+func (c *consumerGroup) PauseAll() {
+	c.pausedLock.Lock()
+	defer c.pausedLock.Unlock()
+
+	for topic, partitions := range c.claimed {
+		for _, partition := range partitions {
+			c.addPausedLocked(topic, partition)
+		}
+	}
+	c.consumer.PauseAll()
+}
+
+// ResumeAll implements ConsumerGroup.
+func (c *consumerGroup) ResumeAll() {
+	c.pausedLock.Lock()
+	defer c.pausedLock.Unlock()
+
+	c.paused = nil
+	c.consumer.ResumeAll()
+}
+
+// Paused implements ConsumerGroup.
+func (c *consumerGroup) Paused() map[string][]int32 {
+	c.pausedLock.Lock()
+	defer c.pausedLock.Unlock()
+
+	paused := make(map[string][]int32, len(c.paused))
+	for topic, partitions := range c.paused {
+		for partition := range partitions {
+			paused[topic] = append(paused[topic], partition)
+		}
+		sort.Sort(int32Slice(paused[topic]))
+	}
+	return paused
+}
+
+func (c *consumerGroup) addPausedLocked(topic string, partition int32) {
+	if c.paused == nil {
+		c.paused = make(map[string]map[int32]none)
+	}
+	if c.paused[topic] == nil {
+		c.paused[topic] = make(map[int32]none)
+	}
+	c.paused[topic][partition] = none{}
+}
+
+// setClaimed records the claims of a new session, forgetting paused
+// partitions which are no longer claimed.
+func (c *consumerGroup) setClaimed(claims map[string][]int32) {
+	c.pausedLock.Lock()
+	defer c.pausedLock.Unlock()
+
+	c.claimed = claims
+	for topic, partitions := range c.paused {
+		for partition := range partitions {
+			if !int32SliceContains(claims[topic], partition) {
+				delete(partitions, partition)
+			}
+		}
+		if len(partitions) == 0 {
+			delete(c.paused, topic)
+		}
+	}
+}
+
+func (c *consumerGroup) isPaused(topic string, partition int32) bool {
+	c.pausedLock.Lock()
+	defer c.pausedLock.Unlock()
+
+	_, ok := c.paused[topic][partition]
+	return ok
+}
+
 // Consume implements ConsumerGroup.
 func (c *consumerGroup) Consume(ctx context.Context, topics []string, handler ConsumerGroupHandler) error {
+	return c.consume(ctx, topics, nil, handler)
+}
+
+func (c *consumerGroup) ConsumePattern(ctx context.Context, pattern *regexp.Regexp, handler ConsumerGroupHandler) error {
+	// the topics matching the pattern are only found by refreshing the metadata
+	if c.config.Metadata.RefreshFrequency <= 0 {
+		return ConfigurationError("ConsumePattern requires Metadata.RefreshFrequency to be > 0")
+	}
+	pause := time.NewTicker(c.config.Metadata.RefreshFrequency)
+	defer pause.Stop()
+
+	for {
+		topics, err := c.matchingTopics(pattern)
+		if err == ErrClosedClient {
+			return ErrClosedConsumerGroup
+		} else if err != nil {
+			return err
+		}
+		if len(topics) > 0 {
+			return c.consume(ctx, topics, pattern, handler)
+		}
+
+		logEntry(LogComponentGroup, LogLevelInfo, "consumergroup/pattern no topic matches yet", groupField(c.groupID), LogField{Key: "pattern", Value: pattern})
+		select {
+		case <-pause.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.closed:
+			return ErrClosedConsumerGroup
+		}
+	}
+}
+
+// matchingTopics refreshes the metadata of the cluster and returns the sorted
+// names of the topics matching pattern.
+func (c *consumerGroup) matchingTopics(pattern *regexp.Regexp) ([]string, error) {
+	if err := c.client.RefreshMetadata(); err != nil {
+		return nil, err
+	}
+	all, err := c.client.Topics()
+	if err != nil {
+		return nil, err
+	}
+
+	var topics []string
+	for _, topic := range all {
+		if pattern.MatchString(topic) {
+			topics = append(topics, topic)
+		}
+	}
+	sort.Strings(topics)
+	return topics, nil
+}
+
+func (c *consumerGroup) consume(ctx context.Context, topics []string, pattern *regexp.Regexp, handler ConsumerGroupHandler) error {
 	// Ensure group is not closed
 	select {
 	case <-c.closed:
@@ -163,13 +507,25 @@ func (c *consumerGroup) Consume(ctx context.Context, topics []string, handler Co
 		return fmt.Errorf("no topics provided")
 	}
 
+	handler = wrapConsumerGroupHandler(handler, c.config.Consumer.Group.Middlewares)
+
 	// Refresh metadata for requested topics
 	if err := c.client.RefreshMetadata(topics...); err != nil {
 		return err
 	}
 
 	// Init session
+	reason := c.rebalanceReason
+	if reason == "" {
+		reason = RebalanceReasonInitialJoin
+	}
+	c.config.notify(Event{Type: EventRebalanceStarted, Group: c.groupID, Reason: reason})
 	sess, err := c.newSession(ctx, topics, handler, c.config.Consumer.Group.Rebalance.Retry.Max)
+	finished := Event{Type: EventRebalanceFinished, Group: c.groupID, Err: err}
+	if sess != nil {
+		finished.Member, finished.Generation = sess.memberID, sess.generationID
+	}
+	c.config.notify(finished)
 	if err == ErrClosedClient {
 		return ErrClosedConsumerGroup
 	} else if err != nil {
@@ -179,10 +535,19 @@ func (c *consumerGroup) Consume(ctx context.Context, topics []string, handler Co
 	// loop check topic partition numbers changed
 	// will trigger rebalance when any topic partitions number had changed
 	// avoid Consume function called again that will generate more than loopCheckPartitionNumbers coroutine
-	go c.loopCheckPartitionNumbers(topics, sess)
+	// the coordinator takes care of this with GroupProtocolConsumer
+	if c.protocol == GroupProtocolClassic {
+		go c.loopCheckPartitionNumbers(topics, sess)
+	}
+
+	// pick up the topics starting or stopping to match the pattern
+	if pattern != nil {
+		go c.loopCheckTopicPattern(pattern, topics, sess)
+	}
 
 	// Wait for session exit signal
 	<-sess.ctx.Done()
+	c.rebalanceReason = sess.endReason()
 
 	// Gracefully release session claims
 	return sess.release(true)
@@ -192,7 +557,7 @@ func (c *consumerGroup) retryNewSession(ctx context.Context, topics []string, ha
 	select {
 	case <-c.closed:
 		return nil, ErrClosedConsumerGroup
-	case <-time.After(c.config.Consumer.Group.Rebalance.Retry.Backoff):
+	case <-time.After(c.rebalanceBackoff(retries)):
 	}
 
 	if refreshCoordinator {
@@ -205,6 +570,16 @@ func (c *consumerGroup) retryNewSession(ctx context.Context, topics []string, ha
 	return c.newSession(ctx, topics, handler, retries-1)
 }
 
+// rebalanceBackoff returns how long to wait before retrying to create a
+// session, with retries being the number of attempts remaining
+func (c *consumerGroup) rebalanceBackoff(retries int) time.Duration {
+	if policy := c.config.Consumer.Group.Rebalance.Retry.Policy; policy != nil {
+		maxRetries := c.config.Consumer.Group.Rebalance.Retry.Max
+		return policy.Backoff(maxRetries-retries+1, maxRetries)
+	}
+	return c.config.Consumer.Group.Rebalance.Retry.Backoff
+}
+
 func (c *consumerGroup) newSession(ctx context.Context, topics []string, handler ConsumerGroupHandler, retries int) (*consumerGroupSession, error) {
 	coordinator, err := c.client.Coordinator(c.groupID)
 	if err != nil {
@@ -215,19 +590,32 @@ func (c *consumerGroup) newSession(ctx context.Context, topics []string, handler
 		return c.retryNewSession(ctx, topics, handler, retries, true)
 	}
 
+	protocol, err := c.negotiateProtocol(coordinator)
+	if err != nil {
+		_ = coordinator.Close()
+		if retries <= 0 {
+			return nil, err
+		}
+
+		return c.retryNewSession(ctx, topics, handler, retries, false)
+	}
+	if protocol == GroupProtocolConsumer {
+		return c.newConsumerProtocolSession(ctx, coordinator, topics, handler, retries)
+	}
+
 	var (
-		metricRegistry          = c.config.MetricRegistry
-		consumerGroupJoinTotal  metrics.Counter
-		consumerGroupJoinFailed metrics.Counter
-		consumerGroupSyncTotal  metrics.Counter
-		consumerGroupSyncFailed metrics.Counter
+		recorder                = c.config.metricsRecorder()
+		consumerGroupJoinTotal  MetricCounter
+		consumerGroupJoinFailed MetricCounter
+		consumerGroupSyncTotal  MetricCounter
+		consumerGroupSyncFailed MetricCounter
 	)
 
-	if metricRegistry != nil {
-		consumerGroupJoinTotal = metrics.GetOrRegisterCounter(fmt.Sprintf("consumer-group-join-total-%s", c.groupID), metricRegistry)
-		consumerGroupJoinFailed = metrics.GetOrRegisterCounter(fmt.Sprintf("consumer-group-join-failed-%s", c.groupID), metricRegistry)
-		consumerGroupSyncTotal = metrics.GetOrRegisterCounter(fmt.Sprintf("consumer-group-sync-total-%s", c.groupID), metricRegistry)
-		consumerGroupSyncFailed = metrics.GetOrRegisterCounter(fmt.Sprintf("consumer-group-sync-failed-%s", c.groupID), metricRegistry)
+	if recorder != nil {
+		consumerGroupJoinTotal = recorder.Counter("consumer-group-join-total", groupLabel(c.groupID))
+		consumerGroupJoinFailed = recorder.Counter("consumer-group-join-failed", groupLabel(c.groupID))
+		consumerGroupSyncTotal = recorder.Counter("consumer-group-sync-total", groupLabel(c.groupID))
+		consumerGroupSyncFailed = recorder.Counter("consumer-group-sync-failed", groupLabel(c.groupID))
 	}
 
 	// Join consumer group
@@ -324,22 +712,30 @@ func (c *consumerGroup) newSession(ctx context.Context, topics []string, handler
 
 	// Retrieve and sort claims
 	var claims map[string][]int32
+	c.assignmentUserData = nil
 	if len(groupRequest.MemberAssignment) > 0 {
 		members, err := groupRequest.GetMemberAssignment()
 		if err != nil {
-			return nil, err
+			return nil, c.abandonAssignment(err)
 		}
 		claims = members.Topics
 
 		// in the case of stateful balance strategies, hold on to the returned
 		// assignment metadata, otherwise, reset the statically defined conusmer
 		// group metadata
+		c.assignmentUserData = members.UserData
 		if members.UserData != nil {
 			c.userData = members.UserData
 		} else {
 			c.userData = c.config.Consumer.Group.Member.UserData
 		}
 
+		if s, ok := c.config.Consumer.Group.Rebalance.Strategy.(BalanceStrategyMemberData); ok {
+			if err := s.OnAssignment(members, join.GenerationId); err != nil {
+				return nil, c.abandonAssignment(err)
+			}
+		}
+
 		for _, partitions := range claims {
 			sort.Sort(int32Slice(partitions))
 		}
@@ -348,6 +744,234 @@ func (c *consumerGroup) newSession(ctx context.Context, topics []string, handler
 	return newConsumerGroupSession(ctx, c, claims, join.MemberId, join.GenerationId, handler)
 }
 
+// abandonAssignment leaves the group when no session can be started on the
+// assignment of the member, so that its partitions are reassigned to the other
+// members rather than after its session timed out. It returns err.
+func (c *consumerGroup) abandonAssignment(err error) error {
+	if e := c.leaveLocked(); e != nil {
+		logEntry(LogComponentGroup, LogLevelWarn, "consumergroup/member failed to leave the group", groupField(c.groupID), errorField(e))
+	}
+	return err
+}
+
+// negotiateProtocol returns the rebalance protocol to use, falling back to
+// GroupProtocolClassic if GroupProtocolConsumer was requested but is not
+// supported by the coordinator. The result is kept for the lifetime of the
+// consumer group.
+func (c *consumerGroup) negotiateProtocol(coordinator *Broker) (ConsumerGroupProtocol, error) {
+	if c.protocolNegotiated || c.config.Consumer.Group.Protocol != GroupProtocolConsumer {
+		return c.protocol, nil
+	}
+
+	if c.config.Version.IsAtLeast(V4_0_0_0) {
+		res, err := coordinator.ApiVersions(&ApiVersionsRequest{})
+		if err != nil {
+			return GroupProtocolClassic, err
+		}
+		for _, block := range res.ApiVersions {
+			if block.ApiKey == (&ConsumerGroupHeartbeatRequest{}).key() {
+				c.protocol = GroupProtocolConsumer
+			}
+		}
+	}
+	if c.protocol != GroupProtocolConsumer {
+		logEntry(LogComponentGroup, LogLevelWarn, "consumergroup/coordinator does not support the consumer group protocol, falling back to the classic protocol", groupField(c.groupID))
+	}
+	c.protocolNegotiated = true
+	return c.protocol, nil
+}
+
+// newConsumerProtocolSession joins the group using GroupProtocolConsumer if
+// necessary and starts a session on the latest assignment.
+func (c *consumerGroup) newConsumerProtocolSession(ctx context.Context, coordinator *Broker, topics []string, handler ConsumerGroupHandler, retries int) (*consumerGroupSession, error) {
+	c.topics = topics
+	if c.assignment == nil && c.memberEpoch > 0 {
+		// still a member, resume with the current assignment
+		c.assignment = c.owned
+	}
+
+	for c.assignment == nil {
+		resp, err := c.consumerGroupHeartbeatRequest(coordinator, topics, []ConsumerGroupHeartbeatTopicPartitions{})
+		if err != nil {
+			_ = coordinator.Close()
+			return nil, err
+		}
+
+		switch resp.Err {
+		case ErrNoError:
+		case ErrUnknownMemberId, ErrFencedMemberEpoch: // reset member and retry immediately
+			c.resetMember()
+			return c.newSession(ctx, topics, handler, retries)
+		case ErrNotCoordinatorForConsumer, ErrConsumerCoordinatorNotAvailable: // retry after backoff with coordinator refresh
+			if retries <= 0 {
+				return nil, resp.Err
+			}
+
+			return c.retryNewSession(ctx, topics, handler, retries, true)
+		case ErrOffsetsLoadInProgress: // retry after backoff
+			if retries <= 0 {
+				return nil, resp.Err
+			}
+
+			return c.retryNewSession(ctx, topics, handler, retries, false)
+		default:
+			return nil, resp.Err
+		}
+
+		c.handleConsumerGroupHeartbeatResponse(resp)
+		if c.assignment == nil {
+			select {
+			case <-c.closed:
+				return nil, ErrClosedConsumerGroup
+			case <-time.After(c.heartbeatInterval):
+			}
+		}
+	}
+
+	claims, err := c.assignmentClaims(coordinator, c.assignment)
+	if err != nil {
+		return nil, err
+	}
+	c.owned, c.assignment = c.assignment, nil
+
+	return newConsumerGroupSession(ctx, c, claims, c.memberID, c.memberEpoch, handler)
+}
+
+func (c *consumerGroup) consumerGroupHeartbeatRequest(coordinator *Broker, topics []string, owned []ConsumerGroupHeartbeatTopicPartitions) (*ConsumerGroupHeartbeatResponse, error) {
+	req := &ConsumerGroupHeartbeatRequest{
+		GroupId:            c.groupID,
+		MemberId:           c.memberID,
+		MemberEpoch:        c.memberEpoch,
+		RebalanceTimeoutMs: int32(c.config.Consumer.Group.Rebalance.Timeout / time.Millisecond),
+		TopicPartitions:    owned,
+	}
+	// the full state of the member must be sent when joining, and only
+	// changes afterwards
+	if c.memberEpoch == 0 || !stringSliceEqual(topics, c.subscribedTopics) {
+		req.SubscribedTopicNames = topics
+	}
+	if c.memberEpoch == 0 {
+		if c.config.RackID != "" {
+			req.RackId = &c.config.RackID
+		}
+		if c.config.Consumer.Group.ServerAssignor != "" {
+			req.ServerAssignor = &c.config.Consumer.Group.ServerAssignor
+		}
+	}
+
+	resp, err := coordinator.ConsumerGroupHeartbeat(req)
+	if err == nil && resp.Err == ErrNoError && req.SubscribedTopicNames != nil {
+		c.subscribedTopics = topics
+	}
+	return resp, err
+}
+
+// handleConsumerGroupHeartbeatResponse updates the state of the member from
+// a successful heartbeat and reports whether a new assignment was received.
+func (c *consumerGroup) handleConsumerGroupHeartbeatResponse(resp *ConsumerGroupHeartbeatResponse) bool {
+	if resp.MemberId != nil {
+		c.memberID = *resp.MemberId
+	}
+	c.memberEpoch = resp.MemberEpoch
+	c.heartbeatInterval = time.Duration(resp.HeartbeatIntervalMs) * time.Millisecond
+	if c.heartbeatInterval <= 0 {
+		c.heartbeatInterval = c.config.Consumer.Group.Heartbeat.Interval
+	}
+
+	if resp.Assignment == nil || (c.owned != nil && sameTopicPartitions(resp.Assignment.TopicPartitions, c.owned)) {
+		return false
+	}
+	c.assignment = resp.Assignment.TopicPartitions
+	if c.assignment == nil {
+		c.assignment = []ConsumerGroupHeartbeatTopicPartitions{}
+	}
+	return true
+}
+
+func (c *consumerGroup) resetMember() {
+	c.memberID = ""
+	c.memberEpoch = 0
+	c.owned = nil
+	c.assignment = nil
+	c.subscribedTopics = nil
+}
+
+// assignmentClaims maps the topic IDs of an assignment to topic names,
+// fetching the IDs of unknown topics with a v10 metadata request.
+func (c *consumerGroup) assignmentClaims(coordinator *Broker, assignment []ConsumerGroupHeartbeatTopicPartitions) (map[string][]int32, error) {
+	for _, tp := range assignment {
+		if _, ok := c.topicNames[tp.TopicId]; ok {
+			continue
+		}
+
+		resp, err := coordinator.GetMetadata(&MetadataRequest{Version: 10, Topics: c.subscribedTopics})
+		if err != nil {
+			_ = coordinator.Close()
+			return nil, err
+		}
+		if c.topicNames == nil {
+			c.topicNames = make(map[Uuid]string, len(resp.Topics))
+		}
+		for _, topic := range resp.Topics {
+			if topic.Err == ErrNoError {
+				c.topicNames[topic.Uuid] = topic.Name
+			}
+		}
+		break
+	}
+
+	claims := make(map[string][]int32, len(assignment))
+	for _, tp := range assignment {
+		topic, ok := c.topicNames[tp.TopicId]
+		if !ok {
+			return nil, ErrUnknownTopicID
+		}
+		partitions := make([]int32, len(tp.Partitions))
+		copy(partitions, tp.Partitions)
+		sort.Sort(int32Slice(partitions))
+		claims[topic] = partitions
+	}
+	return claims, nil
+}
+
+func sameTopicPartitions(a, b []ConsumerGroupHeartbeatTopicPartitions) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	partitions := make(map[Uuid]map[int32]none, len(a))
+	for _, tp := range a {
+		if partitions[tp.TopicId] == nil {
+			partitions[tp.TopicId] = make(map[int32]none, len(tp.Partitions))
+		}
+		for _, p := range tp.Partitions {
+			partitions[tp.TopicId][p] = none{}
+		}
+	}
+	for _, tp := range b {
+		if len(partitions[tp.TopicId]) != len(tp.Partitions) {
+			return false
+		}
+		for _, p := range tp.Partitions {
+			if _, ok := partitions[tp.TopicId][p]; !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func stringSliceEqual(a, b []string) bool {
+	if len(a) != len(b) || (a == nil) != (b == nil) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
 func (c *consumerGroup) joinGroupRequest(coordinator *Broker, topics []string) (*JoinGroupResponse, error) {
 	req := &JoinGroupRequest{
 		GroupId:        c.groupID,
@@ -364,7 +988,20 @@ func (c *consumerGroup) joinGroupRequest(coordinator *Broker, topics []string) (
 		Topics:   topics,
 		UserData: c.userData,
 	}
+	if rackID := c.config.RackID; rackID != "" {
+		// the rack of the member was added to version 3 of the metadata (KIP-881)
+		meta.Version = 3
+		meta.GenerationID = defaultGeneration
+		meta.RackID = &rackID
+	}
 	strategy := c.config.Consumer.Group.Rebalance.Strategy
+	if s, ok := strategy.(BalanceStrategyMemberData); ok {
+		userData, err := s.MemberUserData(topics, c.assignmentUserData)
+		if err != nil {
+			return nil, err
+		}
+		meta.UserData = userData
+	}
 	if err := req.AddGroupProtocolMetadata(strategy.Name(), meta); err != nil {
 		return nil, err
 	}
@@ -420,13 +1057,47 @@ func (c *consumerGroup) balance(members map[string]ConsumerGroupMemberMetadata)
 	}
 
 	strategy := c.config.Consumer.Group.Rebalance.Strategy
+	if s, ok := strategy.(BalanceStrategyReplicaRacks); ok {
+		return s.PlanWithReplicaRacks(members, topics, c.replicaRacks(topics))
+	}
 	return strategy.Plan(members, topics)
 }
 
+// replicaRacks returns the racks of the replicas of each partition, as known
+// from the metadata. Partitions with no known racks are left out.
+func (c *consumerGroup) replicaRacks(topics map[string][]int32) map[string]map[int32][]string {
+	racks := make(map[string]map[int32][]string, len(topics))
+	for topic, partitions := range topics {
+		for _, partition := range partitions {
+			replicas, err := c.client.Replicas(topic, partition)
+			if err != nil {
+				continue
+			}
+			for _, id := range replicas {
+				broker, err := c.client.Broker(id)
+				if err != nil || broker.Rack() == "" {
+					continue
+				}
+				if racks[topic] == nil {
+					racks[topic] = make(map[int32][]string)
+				}
+				racks[topic][partition] = append(racks[topic][partition], broker.Rack())
+			}
+		}
+	}
+	return racks
+}
+
 // Leaves the cluster, called by Close.
 func (c *consumerGroup) leave() error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	return c.leaveLocked()
+}
+
+// leaveLocked leaves the group while the lock is held, or from a session whose
+// heartbeats are stopped.
+func (c *consumerGroup) leaveLocked() error {
 	if c.memberID == "" {
 		return nil
 	}
@@ -436,6 +1107,10 @@ func (c *consumerGroup) leave() error {
 		return err
 	}
 
+	if c.protocol == GroupProtocolConsumer {
+		return c.leaveConsumerProtocol(coordinator)
+	}
+
 	resp, err := coordinator.LeaveGroup(&LeaveGroupRequest{
 		GroupId:  c.groupID,
 		MemberId: c.memberID,
@@ -457,6 +1132,24 @@ func (c *consumerGroup) leave() error {
 	}
 }
 
+func (c *consumerGroup) leaveConsumerProtocol(coordinator *Broker) error {
+	c.memberEpoch = -1 // leave the group
+	resp, err := c.consumerGroupHeartbeatRequest(coordinator, nil, nil)
+	if err != nil {
+		_ = coordinator.Close()
+		return err
+	}
+
+	c.resetMember()
+
+	switch resp.Err {
+	case ErrUnknownMemberId, ErrFencedMemberEpoch, ErrNoError:
+		return nil
+	default:
+		return resp.Err
+	}
+}
+
 func (c *consumerGroup) handleError(err error, topic string, partition int32) {
 	if _, ok := err.(*ConsumerError); !ok && topic != "" && partition > -1 {
 		err = &ConsumerError{
@@ -467,7 +1160,11 @@ func (c *consumerGroup) handleError(err error, topic string, partition int32) {
 	}
 
 	if !c.config.Consumer.Return.Errors {
-		Logger.Println(err)
+		fields := []LogField{groupField(c.groupID), errorField(err)}
+		if topic != "" && partition > -1 {
+			fields = append(fields, topicField(topic), partitionField(partition))
+		}
+		logEntry(LogComponentGroup, LogLevelError, "consumergroup/error", fields...)
 		return
 	}
 
@@ -500,6 +1197,7 @@ func (c *consumerGroup) loopCheckPartitionNumbers(topics []string, session *cons
 		} else {
 			for topic, num := range oldTopicToPartitionNum {
 				if newTopicToPartitionNum[topic] != num {
+					session.recordEndReason(RebalanceReasonPartitionsChanged)
 					return // trigger the end of the session on exit
 				}
 			}
@@ -516,6 +1214,32 @@ func (c *consumerGroup) loopCheckPartitionNumbers(topics []string, session *cons
 	}
 }
 
+func (c *consumerGroup) loopCheckTopicPattern(pattern *regexp.Regexp, topics []string, session *consumerGroupSession) {
+	pause := time.NewTicker(c.config.Metadata.RefreshFrequency)
+	defer pause.Stop()
+	for {
+		select {
+		case <-pause.C:
+		case <-session.ctx.Done():
+			return
+		case <-c.closed:
+			return
+		}
+
+		matching, err := c.matchingTopics(pattern)
+		if err != nil {
+			logEntry(LogComponentGroup, LogLevelWarn, "consumergroup/pattern failed to refresh the matching topics", groupField(c.groupID), LogField{Key: "pattern", Value: pattern}, errorField(err))
+			continue
+		}
+		if !stringSliceEqual(matching, topics) {
+			logEntry(LogComponentGroup, LogLevelInfo, "consumergroup/pattern matching topics changed", groupField(c.groupID), LogField{Key: "pattern", Value: pattern}, LogField{Key: "topics", Value: matching})
+			session.recordEndReason(RebalanceReasonTopicsChanged)
+			session.cancel() // trigger the end of the session
+			return
+		}
+	}
+}
+
 func (c *consumerGroup) topicToPartitionNumbers(topics []string) (map[string]int, error) {
 	topicToPartitionNum := make(map[string]int, len(topics))
 	for _, topic := range topics {
@@ -562,6 +1286,18 @@ type ConsumerGroupSession interface {
 	// Note: calling Commit performs a blocking synchronous operation.
 	Commit()
 
+	// CommitAsync commits the offsets to the backend without blocking. The
+	// callback, if not nil, is invoked once the commit completes with the
+	// outcome for every partition that was part of it: nil on success, or
+	// the error which prevented the offset from being committed.
+	CommitAsync(callback func(map[TopicPartitionID]error))
+
+	// CommitSync commits the offsets to the backend and waits for the outcome,
+	// which holds nil on success or the commit error for every partition that
+	// was part of the commit. It returns ctx.Err() if ctx is done before the
+	// commit completes.
+	CommitSync(ctx context.Context) (map[TopicPartitionID]error, error)
+
 	// ResetOffset resets to the provided offset, alongside a metadata string that
 	// represents the state of the partition consumer at that point in time. Reset
 	// acts as a counterpart to MarkOffset, the difference being that it allows to
@@ -589,12 +1325,29 @@ type consumerGroupSession struct {
 
 	waitGroup       sync.WaitGroup
 	releaseOnce     sync.Once
+	hbStopOnce      sync.Once
 	hbDying, hbDead chan none
+	// hbBroker is the dedicated heartbeat connection, only used by the
+	// heartbeat loop
+	hbBroker *Broker
+	// polled holds the claims watched for Consumer.Group.MaxPollInterval
+	polledLock sync.Mutex
+	polled     map[TopicPartitionID]*consumerGroupClaim
+	// assigned is set once the rebalance listener has been notified, lost to
+	// 1 when the member is no longer part of the group
+	assigned bool
+	lost     int32
+	// leaving is set to 1 by pollIntervalLoop for release to leave the group
+	// without waiting for the stuck claims
+	leaving int32
+	// reason is the first reason recorded for the end of the session
+	reasonLock sync.Mutex
+	reason     string
 }
 
 func newConsumerGroupSession(ctx context.Context, parent *consumerGroup, claims map[string][]int32, memberID string, generationID int32, handler ConsumerGroupHandler) (*consumerGroupSession, error) {
 	// init offset manager
-	offsets, err := newOffsetManagerFromClient(parent.groupID, memberID, generationID, parent.client)
+	offsets, err := newOffsetManagerFromClient(parent.groupID, memberID, generationID, parent.protocol, parent.client)
 	if err != nil {
 		return nil, err
 	}
@@ -614,10 +1367,18 @@ func newConsumerGroupSession(ctx context.Context, parent *consumerGroup, claims
 		cancel:       cancel,
 		hbDying:      make(chan none),
 		hbDead:       make(chan none),
+		polled:       make(map[TopicPartitionID]*consumerGroupClaim),
 	}
 
+	parent.setClaimed(claims)
+	logEntry(LogComponentGroup, LogLevelInfo, "consumergroup/session started", sess.logFields()...)
+
 	// start heartbeat loop
-	go sess.heartbeatLoop()
+	if parent.protocol == GroupProtocolConsumer {
+		go sess.consumerProtocolHeartbeatLoop()
+	} else {
+		go sess.heartbeatLoop()
+	}
 
 	// create a POM for each claim
 	for topic, partitions := range claims {
@@ -642,6 +1403,13 @@ func newConsumerGroupSession(ctx context.Context, parent *consumerGroup, claims
 		_ = sess.release(true)
 		return nil, err
 	}
+	if listener, ok := handler.(ConsumerGroupRebalanceListener); ok {
+		if err := listener.OnPartitionsAssigned(sess, claims); err != nil {
+			_ = sess.release(true)
+			return nil, err
+		}
+		sess.assigned = true
+	}
 
 	// start consuming
 	for topic, partitions := range claims {
@@ -660,6 +1428,12 @@ func newConsumerGroupSession(ctx context.Context, parent *consumerGroup, claims
 			}(topic, partition)
 		}
 	}
+
+	// leave the group when a claim stops making progress
+	if sess.parent.config.Consumer.Group.MaxPollInterval > 0 {
+		go sess.pollIntervalLoop()
+	}
+
 	return sess, nil
 }
 
@@ -667,20 +1441,39 @@ func (s *consumerGroupSession) Claims() map[string][]int32 { return s.claims }
 func (s *consumerGroupSession) MemberID() string           { return s.memberID }
 func (s *consumerGroupSession) GenerationID() int32        { return s.generationID }
 
+// logFields returns the fields identifying the session in the log entries
+func (s *consumerGroupSession) logFields() []LogField {
+	return []LogField{
+		groupField(s.parent.groupID),
+		{Key: LogKeyMember, Value: s.memberID},
+		{Key: LogKeyGeneration, Value: s.generationID},
+	}
+}
+
 func (s *consumerGroupSession) MarkOffset(topic string, partition int32, offset int64, metadata string) {
 	if pom := s.offsets.findPOM(topic, partition); pom != nil {
 		pom.MarkOffset(offset, metadata)
 	}
+	s.polledProgress(topic, partition)
 }
 
 func (s *consumerGroupSession) Commit() {
 	s.offsets.Commit()
 }
 
+func (s *consumerGroupSession) CommitAsync(callback func(map[TopicPartitionID]error)) {
+	s.offsets.CommitAsync(callback)
+}
+
+func (s *consumerGroupSession) CommitSync(ctx context.Context) (map[TopicPartitionID]error, error) {
+	return s.offsets.CommitSync(ctx)
+}
+
 func (s *consumerGroupSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {
 	if pom := s.offsets.findPOM(topic, partition); pom != nil {
 		pom.ResetOffset(offset, metadata)
 	}
+	s.polledProgress(topic, partition)
 }
 
 func (s *consumerGroupSession) MarkMessage(msg *ConsumerMessage, metadata string) {
@@ -706,13 +1499,35 @@ func (s *consumerGroupSession) consume(topic string, partition int32) {
 	if pom := s.offsets.findPOM(topic, partition); pom != nil {
 		offset, _ = pom.NextOffset()
 	}
+	if resolve := s.parent.config.Consumer.Group.ResolveOffset; resolve != nil {
+		var err error
+		if offset, err = resolve(topic, partition, offset); err != nil {
+			s.parent.handleError(err, topic, partition)
+			return
+		}
+	}
 
 	// create new claim
 	claim, err := newConsumerGroupClaim(s, topic, partition, offset)
+	if outOfRange, ok := err.(*OffsetOutOfRangeError); ok {
+		s.skipOutOfRange(outOfRange)
+		return
+	}
 	if err != nil {
 		s.parent.handleError(err, topic, partition)
 		return
 	}
+	if claim.messages != nil {
+		id := TopicPartitionID{Topic: topic, Partition: partition}
+		s.polledLock.Lock()
+		s.polled[id] = claim
+		s.polledLock.Unlock()
+		defer func() {
+			s.polledLock.Lock()
+			delete(s.polled, id)
+			s.polledLock.Unlock()
+		}()
+	}
 
 	// handle errors
 	go func() {
@@ -727,6 +1542,7 @@ func (s *consumerGroupSession) consume(topic string, partition int32) {
 		case <-s.ctx.Done():
 		case <-s.parent.closed:
 		}
+		claim.cancel()
 		claim.AsyncClose()
 	}()
 
@@ -736,41 +1552,171 @@ func (s *consumerGroupSession) consume(topic string, partition int32) {
 	}
 
 	// ensure consumer is closed & drained
+	claim.cancel()
 	claim.AsyncClose()
 	for _, err := range claim.waitClosed() {
 		s.parent.handleError(err, topic, partition)
 	}
 }
 
+// skipOutOfRange reports the partition whose committed offset is out of range
+// under OffsetOutOfRangeFail and waits for the end of the session, which goes
+// on for the other partitions.
+func (s *consumerGroupSession) skipOutOfRange(err *OffsetOutOfRangeError) {
+	s.parent.handleError(err, err.Topic, err.Partition)
+	if listener, ok := s.handler.(ConsumerGroupOffsetOutOfRangeListener); ok {
+		listener.OnOffsetOutOfRange(s, err)
+	}
+
+	select {
+	case <-s.ctx.Done():
+	case <-s.parent.closed:
+	}
+}
+
 func (s *consumerGroupSession) release(withCleanup bool) (err error) {
 	// signal release, stop heartbeat
 	s.cancel()
 
+	// leave the group first when MaxPollInterval was exceeded, as the stuck
+	// claims may not return soon, from the goroutine holding the lock
+	if atomic.CompareAndSwapInt32(&s.leaving, 1, 0) {
+		s.stopHeartbeat()
+		if e := s.parent.leaveLocked(); e != nil {
+			s.parent.handleError(e, "", -1)
+		}
+	}
+
 	// wait for consumers to exit
 	s.waitGroup.Wait()
 
 	// perform release
 	s.releaseOnce.Do(func() {
+		logEntry(LogComponentGroup, LogLevelInfo, "consumergroup/session released", s.logFields()...)
 		if withCleanup {
+			if listener, ok := s.handler.(ConsumerGroupRebalanceListener); ok && s.assigned {
+				var e error
+				if atomic.LoadInt32(&s.lost) == 1 {
+					e = listener.OnPartitionsLost(s, s.claims)
+				} else {
+					e = listener.OnPartitionsRevoked(s, s.claims)
+				}
+				if e != nil {
+					s.parent.handleError(e, "", -1)
+					err = e
+				}
+			}
+
 			if e := s.handler.Cleanup(s); e != nil {
 				s.parent.handleError(e, "", -1)
 				err = e
 			}
 		}
 
-		if e := s.offsets.Close(); e != nil {
-			err = e
-		}
+		flush := s.parent.config.Consumer.Offsets.AutoCommit.Enable || atomic.LoadInt32(&s.parent.finalCommit) == 1
+		s.parent.uncommitted = s.offsets.close(flush)
 
-		close(s.hbDying)
-		<-s.hbDead
+		s.stopHeartbeat()
 	})
 
 	return
 }
 
+// stopHeartbeat stops the heartbeat loop and waits for it to exit.
+func (s *consumerGroupSession) stopHeartbeat() {
+	s.hbStopOnce.Do(func() {
+		close(s.hbDying)
+	})
+	<-s.hbDead
+}
+
+// polledProgress records progress on a claim watched for MaxPollInterval.
+func (s *consumerGroupSession) polledProgress(topic string, partition int32) {
+	s.polledLock.Lock()
+	claim := s.polled[TopicPartitionID{Topic: topic, Partition: partition}]
+	s.polledLock.Unlock()
+	if claim != nil {
+		claim.progress()
+	}
+}
+
+// pollIntervalLoop leaves the group as soon as a claim has a message waiting
+// to be read for longer than Consumer.Group.MaxPollInterval with no offset
+// marked meanwhile, as its ConsumeClaim is most likely stuck. The session is
+// canceled for its release, which holds the lock of the group, to stop the
+// heartbeats and leave first so that the partitions are not held any longer.
+func (s *consumerGroupSession) pollIntervalLoop() {
+	interval := s.parent.config.Consumer.Group.MaxPollInterval
+	pause := time.NewTicker(interval/4 + 1)
+	defer pause.Stop()
+
+	for {
+		select {
+		case <-pause.C:
+		case <-s.ctx.Done():
+			return
+		case <-s.parent.closed:
+			return
+		}
+
+		var stalled *consumerGroupClaim
+		s.polledLock.Lock()
+		for _, claim := range s.polled {
+			if claim.stalledFor() > interval {
+				stalled = claim
+				break
+			}
+		}
+		s.polledLock.Unlock()
+
+		if stalled != nil {
+			s.parent.handleError(&MaxPollIntervalExceededError{
+				Topic:     stalled.topic,
+				Partition: stalled.partition,
+				Interval:  interval,
+			}, stalled.topic, stalled.partition)
+
+			s.markLost()
+			s.recordEndReason(RebalanceReasonMaxPollIntervalExceeded)
+			atomic.StoreInt32(&s.leaving, 1)
+			s.cancel()
+			return
+		}
+	}
+}
+
+// recordEndReason records reason as the reason the session ends, unless one
+// was already recorded
+func (s *consumerGroupSession) recordEndReason(reason string) {
+	s.reasonLock.Lock()
+	defer s.reasonLock.Unlock()
+	if s.reason == "" {
+		s.reason = reason
+	}
+}
+
+// endReason returns the reason the session ended, RebalanceReasonSessionEnded
+// if none was recorded
+func (s *consumerGroupSession) endReason() string {
+	s.reasonLock.Lock()
+	defer s.reasonLock.Unlock()
+	if s.reason == "" {
+		return RebalanceReasonSessionEnded
+	}
+	return s.reason
+}
+
+// markLost records that the partitions of the session were lost rather than
+// revoked, as the member is no longer part of the group: it was fenced, its
+// session timed out or it left after exceeding MaxPollInterval. Failed
+// requests alone do not lose the partitions, which are revoked then.
+func (s *consumerGroupSession) markLost() {
+	atomic.StoreInt32(&s.lost, 1)
+}
+
 func (s *consumerGroupSession) heartbeatLoop() {
 	defer close(s.hbDead)
+	defer s.closeHeartbeatConnection()
 	defer s.cancel() // trigger the end of the session on exit
 
 	pause := time.NewTicker(s.parent.config.Consumer.Group.Heartbeat.Interval)
@@ -781,10 +1727,11 @@ func (s *consumerGroupSession) heartbeatLoop() {
 
 	retries := s.parent.config.Metadata.Retry.Max
 	for {
-		coordinator, err := s.parent.client.Coordinator(s.parent.groupID)
+		coordinator, err := s.heartbeatCoordinator()
 		if err != nil {
 			if retries <= 0 {
 				s.parent.handleError(err, "", -1)
+				s.recordEndReason(RebalanceReasonHeartbeatFailed)
 				return
 			}
 			retryBackoff.Reset(s.parent.config.Metadata.Retry.Backoff)
@@ -803,6 +1750,7 @@ func (s *consumerGroupSession) heartbeatLoop() {
 
 			if retries <= 0 {
 				s.parent.handleError(err, "", -1)
+				s.recordEndReason(RebalanceReasonHeartbeatFailed)
 				return
 			}
 
@@ -813,18 +1761,142 @@ func (s *consumerGroupSession) heartbeatLoop() {
 		switch resp.Err {
 		case ErrNoError:
 			retries = s.parent.config.Metadata.Retry.Max
-		case ErrRebalanceInProgress, ErrUnknownMemberId, ErrIllegalGeneration:
+		case ErrRebalanceInProgress:
+			s.recordEndReason(RebalanceReasonRebalanceInProgress)
+			return
+		case ErrUnknownMemberId, ErrIllegalGeneration:
+			// the session of the member timed out or it was fenced
+			s.markLost()
+			s.recordEndReason(RebalanceReasonMemberLost)
 			return
 		default:
 			s.parent.handleError(resp.Err, "", -1)
+			s.recordEndReason(RebalanceReasonHeartbeatFailed)
+			return
+		}
+
+		select {
+		case <-pause.C:
+		case <-s.hbDying:
 			return
 		}
+	}
+}
+
+// heartbeatCoordinator returns the broker to send heartbeats to, which is a
+// dedicated connection to the coordinator with
+// Consumer.Group.Heartbeat.DedicatedConnection.
+func (s *consumerGroupSession) heartbeatCoordinator() (*Broker, error) {
+	coordinator, err := s.parent.client.Coordinator(s.parent.groupID)
+	if err != nil || !s.parent.config.Consumer.Group.Heartbeat.DedicatedConnection {
+		return coordinator, err
+	}
+
+	if s.hbBroker != nil && s.hbBroker.Addr() == coordinator.Addr() {
+		if connected, _ := s.hbBroker.Connected(); connected {
+			return s.hbBroker, nil
+		}
+	}
+	s.closeHeartbeatConnection()
+
+	conf := *s.parent.config
+	if timeout := conf.Consumer.Group.Heartbeat.Timeout; timeout > 0 {
+		conf.Net.DialTimeout = timeout
+		conf.Net.ReadTimeout = timeout
+		conf.Net.WriteTimeout = timeout
+	}
+
+	broker := NewBroker(coordinator.Addr())
+	if err := broker.Open(&conf); err != nil {
+		return nil, err
+	}
+	if _, err := broker.Connected(); err != nil {
+		return nil, err
+	}
+	s.hbBroker = broker
+	return broker, nil
+}
+
+func (s *consumerGroupSession) closeHeartbeatConnection() {
+	if s.hbBroker != nil {
+		_ = s.hbBroker.Close()
+		s.hbBroker = nil
+	}
+}
 
+// consumerProtocolHeartbeatLoop heartbeats the coordinator with
+// GroupProtocolConsumer. The first heartbeat acknowledges the assignment of the
+// session, which ends as soon as the coordinator sends a new one.
+func (s *consumerGroupSession) consumerProtocolHeartbeatLoop() {
+	defer close(s.hbDead)
+	defer s.closeHeartbeatConnection()
+	defer s.cancel() // trigger the end of the session on exit
+
+	pause := time.NewTimer(0)
+	defer pause.Stop()
+
+	owned := s.parent.owned
+	retries := s.parent.config.Metadata.Retry.Max
+	for {
 		select {
 		case <-pause.C:
 		case <-s.hbDying:
 			return
 		}
+
+		coordinator, err := s.heartbeatCoordinator()
+		if err != nil {
+			if retries <= 0 {
+				s.parent.handleError(err, "", -1)
+				return
+			}
+			retries--
+			pause.Reset(s.parent.config.Metadata.Retry.Backoff)
+			continue
+		}
+
+		resp, err := s.parent.consumerGroupHeartbeatRequest(coordinator, s.parent.topics, owned)
+		if err != nil {
+			_ = coordinator.Close()
+
+			if retries <= 0 {
+				s.parent.handleError(err, "", -1)
+				return
+			}
+			retries--
+			pause.Reset(s.parent.config.Metadata.Retry.Backoff)
+			continue
+		}
+
+		switch resp.Err {
+		case ErrNoError:
+			retries = s.parent.config.Metadata.Retry.Max
+		case ErrNotCoordinatorForConsumer, ErrConsumerCoordinatorNotAvailable:
+			if retries <= 0 {
+				s.parent.handleError(resp.Err, "", -1)
+				return
+			}
+			_ = s.parent.client.RefreshCoordinator(s.parent.groupID)
+			retries--
+			pause.Reset(s.parent.config.Metadata.Retry.Backoff)
+			continue
+		case ErrUnknownMemberId, ErrFencedMemberEpoch:
+			// the member must give up its partitions and join again
+			s.parent.resetMember()
+			s.markLost()
+			return
+		default:
+			s.parent.handleError(resp.Err, "", -1)
+			return
+		}
+
+		owned = nil
+		newAssignment := s.parent.handleConsumerGroupHeartbeatResponse(resp)
+		s.offsets.updateGeneration(s.parent.memberEpoch)
+		if newAssignment {
+			return
+		}
+		pause.Reset(s.parent.heartbeatInterval)
 	}
 }
 
@@ -850,6 +1922,111 @@ type ConsumerGroupHandler interface {
 	ConsumeClaim(ConsumerGroupSession, ConsumerGroupClaim) error
 }
 
+// ConsumerGroupHandlerMiddleware wraps a ConsumerGroupHandler, returning a
+// handler which usually delegates to the wrapped one.
+type ConsumerGroupHandlerMiddleware func(ConsumerGroupHandler) ConsumerGroupHandler
+
+// wrapConsumerGroupHandler applies the middlewares to handler, the first one
+// being the outermost. A ConsumerGroupRebalanceListener or
+// ConsumerGroupOffsetOutOfRangeListener implemented by handler is kept when
+// the middlewares do not implement it themselves.
+func wrapConsumerGroupHandler(handler ConsumerGroupHandler, middlewares []ConsumerGroupHandlerMiddleware) ConsumerGroupHandler {
+	wrapped := handler
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		wrapped = middlewares[i](wrapped)
+	}
+
+	rebalance, hasRebalance := wrapped.(ConsumerGroupRebalanceListener)
+	if !hasRebalance {
+		rebalance, _ = handler.(ConsumerGroupRebalanceListener)
+	}
+	outOfRange, hasOutOfRange := wrapped.(ConsumerGroupOffsetOutOfRangeListener)
+	if !hasOutOfRange {
+		outOfRange, _ = handler.(ConsumerGroupOffsetOutOfRangeListener)
+	}
+
+	switch {
+	case (hasRebalance || rebalance == nil) && (hasOutOfRange || outOfRange == nil):
+		return wrapped
+	case outOfRange == nil:
+		return &listeningConsumerGroupHandler{
+			ConsumerGroupHandler:           wrapped,
+			ConsumerGroupRebalanceListener: rebalance,
+		}
+	case rebalance == nil:
+		return &outOfRangeListeningConsumerGroupHandler{
+			ConsumerGroupHandler:                  wrapped,
+			ConsumerGroupOffsetOutOfRangeListener: outOfRange,
+		}
+	default:
+		return &fullyListeningConsumerGroupHandler{
+			ConsumerGroupHandler:                  wrapped,
+			ConsumerGroupRebalanceListener:        rebalance,
+			ConsumerGroupOffsetOutOfRangeListener: outOfRange,
+		}
+	}
+}
+
+type listeningConsumerGroupHandler struct {
+	ConsumerGroupHandler
+	ConsumerGroupRebalanceListener
+}
+
+type outOfRangeListeningConsumerGroupHandler struct {
+	ConsumerGroupHandler
+	ConsumerGroupOffsetOutOfRangeListener
+}
+
+type fullyListeningConsumerGroupHandler struct {
+	ConsumerGroupHandler
+	ConsumerGroupRebalanceListener
+	ConsumerGroupOffsetOutOfRangeListener
+}
+
+func hasNilMiddleware(middlewares []ConsumerGroupHandlerMiddleware) bool {
+	for _, middleware := range middlewares {
+		if middleware == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ConsumerGroupRebalanceListener may optionally be implemented by a
+// ConsumerGroupHandler to be notified when partitions are assigned to the
+// member and when they are taken away, making it possible to flush state and
+// commit offsets at the right moments.
+//
+// With the classic protocol all claims are revoked at the end of each session
+// and assigned again with the next one.
+type ConsumerGroupRebalanceListener interface {
+	// OnPartitionsAssigned is called with the claims of a new session, after
+	// Setup and before ConsumeClaim.
+	OnPartitionsAssigned(sess ConsumerGroupSession, assigned map[string][]int32) error
+
+	// OnPartitionsRevoked is called with the claims of the session once all
+	// ConsumeClaim goroutines have exited and before Cleanup. Offsets marked
+	// here are included in the final commit of the session.
+	OnPartitionsRevoked(sess ConsumerGroupSession, revoked map[string][]int32) error
+
+	// OnPartitionsLost is called instead of OnPartitionsRevoked when the
+	// member was removed from the group, e.g. because its session timed out
+	// or it was fenced. The partitions may already be owned by another member
+	// and offsets can no longer be committed.
+	OnPartitionsLost(sess ConsumerGroupSession, lost map[string][]int32) error
+}
+
+// ConsumerGroupOffsetOutOfRangeListener may optionally be implemented by a
+// ConsumerGroupHandler to be notified of the partitions which are not consumed
+// for the rest of the session because their committed offset is out of range
+// and their OffsetOutOfRangePolicy is OffsetOutOfRangeFail.
+type ConsumerGroupOffsetOutOfRangeListener interface {
+	// OnOffsetOutOfRange is called instead of ConsumeClaim for the partition
+	// of err. An offset set with ConsumerGroupSession.ResetOffset is committed
+	// at the end of the session and consumed from by the following ones.
+	OnOffsetOutOfRange(sess ConsumerGroupSession, err *OffsetOutOfRangeError)
+}
+
 // ConsumerGroupClaim processes Kafka messages from a given topic and partition within a consumer group.
 type ConsumerGroupClaim interface {
 	// Topic returns the consumed topic name.
@@ -872,6 +2049,13 @@ type ConsumerGroupClaim interface {
 	// Config.Consumer.Group.Session.Timeout before the topic/partition is eventually
 	// re-assigned to another group member.
 	Messages() <-chan *ConsumerMessage
+
+	// Context returns the context of the claim. It is derived from the session
+	// context, and thus carries the deadline and values of the context given to
+	// Consume, and is canceled as soon as the claim is revoked, i.e. when the
+	// session ends or the group is closed. Long-running processing should
+	// watch it to be interrupted cleanly during rebalances.
+	Context() context.Context
 }
 
 type consumerGroupClaim struct {
@@ -879,35 +2063,107 @@ type consumerGroupClaim struct {
 	partition int32
 	offset    int64
 	PartitionConsumer
+
+	ctx    context.Context
+	cancel func()
+
+	// messages relays the messages of the PartitionConsumer when the claim
+	// is watched for Consumer.Group.MaxPollInterval, waitingSince is the
+	// time in nanoseconds since which the next message waits to be read,
+	// zero when none is waiting
+	messages     chan *ConsumerMessage
+	waitingSince int64
 }
 
 func newConsumerGroupClaim(sess *consumerGroupSession, topic string, partition int32, offset int64) (*consumerGroupClaim, error) {
 	pcm, err := sess.parent.consumer.ConsumePartition(topic, partition, offset)
 	if err == ErrOffsetOutOfRange {
-		offset = sess.parent.config.Consumer.Offsets.Initial
+		conf := &sess.parent.config.Consumer.Offsets
+		policy, ok := conf.OutOfRange.Topics[topic]
+		if !ok {
+			policy = conf.OutOfRange.Policy
+		}
+
+		switch policy {
+		case OffsetOutOfRangeFail:
+			return nil, &OffsetOutOfRangeError{Topic: topic, Partition: partition, Offset: offset}
+		case OffsetOutOfRangeResetOldest:
+			offset = OffsetOldest
+		case OffsetOutOfRangeResetNewest:
+			offset = OffsetNewest
+		default:
+			offset = conf.Initial
+		}
 		pcm, err = sess.parent.consumer.ConsumePartition(topic, partition, offset)
 	}
 	if err != nil {
 		return nil, err
 	}
 
+	if sess.parent.isPaused(topic, partition) {
+		pcm.Pause()
+	}
+
 	go func() {
 		for err := range pcm.Errors() {
 			sess.parent.handleError(err, topic, partition)
 		}
 	}()
 
-	return &consumerGroupClaim{
+	claim := &consumerGroupClaim{
 		topic:             topic,
 		partition:         partition,
 		offset:            offset,
 		PartitionConsumer: pcm,
-	}, nil
+	}
+	claim.ctx, claim.cancel = context.WithCancel(sess.ctx)
+	if sess.parent.config.Consumer.Group.MaxPollInterval > 0 {
+		claim.messages = make(chan *ConsumerMessage)
+		go withRecover(claim.relayMessages)
+	}
+	return claim, nil
+}
+
+func (c *consumerGroupClaim) relayMessages() {
+	defer close(c.messages)
+	for msg := range c.PartitionConsumer.Messages() {
+		atomic.StoreInt64(&c.waitingSince, time.Now().UnixNano())
+		c.messages <- msg
+		atomic.StoreInt64(&c.waitingSince, 0)
+	}
+}
+
+// progress restarts the wait of the next message, if any.
+func (c *consumerGroupClaim) progress() {
+	for {
+		since := atomic.LoadInt64(&c.waitingSince)
+		if since == 0 || atomic.CompareAndSwapInt64(&c.waitingSince, since, time.Now().UnixNano()) {
+			return
+		}
+	}
+}
+
+// stalledFor returns for how long the next message has been waiting to be read.
+func (c *consumerGroupClaim) stalledFor() time.Duration {
+	since := atomic.LoadInt64(&c.waitingSince)
+	if since == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, since))
+}
+
+// Messages returns the read channel for the messages of the claim.
+func (c *consumerGroupClaim) Messages() <-chan *ConsumerMessage {
+	if c.messages != nil {
+		return c.messages
+	}
+	return c.PartitionConsumer.Messages()
 }
 
-func (c *consumerGroupClaim) Topic() string        { return c.topic }
-func (c *consumerGroupClaim) Partition() int32     { return c.partition }
-func (c *consumerGroupClaim) InitialOffset() int64 { return c.offset }
+func (c *consumerGroupClaim) Topic() string            { return c.topic }
+func (c *consumerGroupClaim) Partition() int32         { return c.partition }
+func (c *consumerGroupClaim) InitialOffset() int64     { return c.offset }
+func (c *consumerGroupClaim) Context() context.Context { return c.ctx }
 
 // Drains messages and errors, ensures the claim is fully closed.
 func (c *consumerGroupClaim) waitClosed() (errs ConsumerErrors) {
@@ -0,0 +1,191 @@
+package sarama
+
+// ConsumerGroupHeartbeatRequest is used by members of a consumer group using
+// the next generation rebalance protocol (KIP-848) to join, leave and
+// heartbeat the group. Assignments are computed by the group coordinator.
+type ConsumerGroupHeartbeatRequest struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	GroupId string
+	// MemberId is empty when joining the group for the first time
+	MemberId string
+	// MemberEpoch is 0 to join the group, -1 to leave the group and -2 for a
+	// static member to temporarily leave the group
+	MemberEpoch int32
+	InstanceId  *string
+	// RackId must only be sent when joining or when it changes, nil otherwise
+	RackId             *string
+	RebalanceTimeoutMs int32
+	// SubscribedTopicNames must only be sent when joining or when the
+	// subscription changes, nil otherwise
+	SubscribedTopicNames []string
+	// ServerAssignor must only be sent when joining or when it changes, nil
+	// otherwise. A nil value on join lets the coordinator choose the assignor
+	ServerAssignor *string
+	// TopicPartitions holds the partitions currently owned by the member. It
+	// must be sent when joining and whenever the owned partitions change, and
+	// be nil otherwise
+	TopicPartitions []ConsumerGroupHeartbeatTopicPartitions
+}
+
+// ConsumerGroupHeartbeatTopicPartitions is a set of partitions of a topic,
+// identified by its topic ID.
+type ConsumerGroupHeartbeatTopicPartitions struct {
+	TopicId    Uuid
+	Partitions []int32
+}
+
+func (t *ConsumerGroupHeartbeatTopicPartitions) encode(pe packetEncoder) error {
+	if err := pe.putRawBytes(t.TopicId[:]); err != nil {
+		return err
+	}
+	if err := pe.putCompactInt32Array(t.Partitions); err != nil {
+		return err
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (t *ConsumerGroupHeartbeatTopicPartitions) decode(pd packetDecoder) error {
+	topicID, err := pd.getRawBytes(len(t.TopicId))
+	if err != nil {
+		return err
+	}
+	copy(t.TopicId[:], topicID)
+	if t.Partitions, err = pd.getCompactInt32Array(); err != nil {
+		return err
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func encodeConsumerGroupHeartbeatTopicPartitions(pe packetEncoder, in []ConsumerGroupHeartbeatTopicPartitions) error {
+	if in == nil {
+		pe.putCompactArrayLength(-1)
+		return nil
+	}
+	pe.putCompactArrayLength(len(in))
+	for i := range in {
+		if err := in[i].encode(pe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeConsumerGroupHeartbeatTopicPartitions(pd packetDecoder) ([]ConsumerGroupHeartbeatTopicPartitions, error) {
+	// 0 represents a null array
+	n, err := pd.getUVarint()
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	out := make([]ConsumerGroupHeartbeatTopicPartitions, n-1)
+	for i := range out {
+		if err := out[i].decode(pd); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (r *ConsumerGroupHeartbeatRequest) encode(pe packetEncoder) error {
+	if err := pe.putCompactString(r.GroupId); err != nil {
+		return err
+	}
+	if err := pe.putCompactString(r.MemberId); err != nil {
+		return err
+	}
+	pe.putInt32(r.MemberEpoch)
+	if err := pe.putNullableCompactString(r.InstanceId); err != nil {
+		return err
+	}
+	if err := pe.putNullableCompactString(r.RackId); err != nil {
+		return err
+	}
+	pe.putInt32(r.RebalanceTimeoutMs)
+
+	if r.SubscribedTopicNames == nil {
+		pe.putCompactArrayLength(-1)
+	} else {
+		pe.putCompactArrayLength(len(r.SubscribedTopicNames))
+		for _, topic := range r.SubscribedTopicNames {
+			if err := pe.putCompactString(topic); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := pe.putNullableCompactString(r.ServerAssignor); err != nil {
+		return err
+	}
+	if err := encodeConsumerGroupHeartbeatTopicPartitions(pe, r.TopicPartitions); err != nil {
+		return err
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *ConsumerGroupHeartbeatRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.GroupId, err = pd.getCompactString(); err != nil {
+		return err
+	}
+	if r.MemberId, err = pd.getCompactString(); err != nil {
+		return err
+	}
+	if r.MemberEpoch, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if r.InstanceId, err = pd.getCompactNullableString(); err != nil {
+		return err
+	}
+	if r.RackId, err = pd.getCompactNullableString(); err != nil {
+		return err
+	}
+	if r.RebalanceTimeoutMs, err = pd.getInt32(); err != nil {
+		return err
+	}
+
+	// 0 represents a null array
+	n, err := pd.getUVarint()
+	if err != nil {
+		return err
+	}
+	r.SubscribedTopicNames = nil
+	if n > 0 {
+		r.SubscribedTopicNames = make([]string, n-1)
+		for i := range r.SubscribedTopicNames {
+			if r.SubscribedTopicNames[i], err = pd.getCompactString(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if r.ServerAssignor, err = pd.getCompactNullableString(); err != nil {
+		return err
+	}
+	if r.TopicPartitions, err = decodeConsumerGroupHeartbeatTopicPartitions(pd); err != nil {
+		return err
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *ConsumerGroupHeartbeatRequest) key() int16 {
+	return 68
+}
+
+func (r *ConsumerGroupHeartbeatRequest) version() int16 {
+	return r.Version
+}
+
+func (r *ConsumerGroupHeartbeatRequest) headerVersion() int16 {
+	return 2
+}
+
+func (r *ConsumerGroupHeartbeatRequest) requiredVersion() KafkaVersion {
+	return V4_0_0_0
+}
@@ -0,0 +1,61 @@
+package sarama
+
+import "testing"
+
+var (
+	consumerGroupHeartbeatRequestJoin = []byte{
+		4, 'f', 'o', 'o', // GroupId
+		1,          // MemberId
+		0, 0, 0, 0, // MemberEpoch
+		0,                     // InstanceId
+		5, 'r', 'a', 'c', 'k', // RackId
+		0, 0, 0xea, 0x60, // RebalanceTimeoutMs
+		2,                // SubscribedTopicNames
+		4, 'o', 'n', 'e', // Topic
+		8, 'u', 'n', 'i', 'f', 'o', 'r', 'm', // ServerAssignor
+		1, // TopicPartitions
+		0, // empty tagged fields
+	}
+
+	consumerGroupHeartbeatRequestHeartbeat = []byte{
+		4, 'f', 'o', 'o', // GroupId
+		4, 'b', 'a', 'r', // MemberId
+		0, 0, 0, 5, // MemberEpoch
+		0,                // InstanceId
+		0,                // RackId
+		0, 0, 0xea, 0x60, // RebalanceTimeoutMs
+		0,                                                     // SubscribedTopicNames
+		0,                                                     // ServerAssignor
+		2,                                                     // TopicPartitions
+		1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, // TopicId
+		3, 0, 0, 0, 0, 0, 0, 0, 1, // Partitions
+		0, // empty tagged fields
+		0, // empty tagged fields
+	}
+)
+
+func TestConsumerGroupHeartbeatRequest(t *testing.T) {
+	rack := "rack"
+	assignor := "uniform"
+	request := &ConsumerGroupHeartbeatRequest{
+		GroupId:              "foo",
+		RackId:               &rack,
+		RebalanceTimeoutMs:   60000,
+		SubscribedTopicNames: []string{"one"},
+		ServerAssignor:       &assignor,
+		TopicPartitions:      []ConsumerGroupHeartbeatTopicPartitions{},
+	}
+	testRequest(t, "join", request, consumerGroupHeartbeatRequestJoin)
+
+	request = &ConsumerGroupHeartbeatRequest{
+		GroupId:            "foo",
+		MemberId:           "bar",
+		MemberEpoch:        5,
+		RebalanceTimeoutMs: 60000,
+		TopicPartitions: []ConsumerGroupHeartbeatTopicPartitions{{
+			TopicId:    Uuid{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+			Partitions: []int32{0, 1},
+		}},
+	}
+	testRequest(t, "heartbeat", request, consumerGroupHeartbeatRequestHeartbeat)
+}
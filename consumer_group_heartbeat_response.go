@@ -0,0 +1,129 @@
+package sarama
+
+import "time"
+
+// ConsumerGroupHeartbeatResponse is the response to a ConsumerGroupHeartbeatRequest
+type ConsumerGroupHeartbeatResponse struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	ThrottleTime time.Duration
+	Err          KError
+	ErrorMessage *string
+	// MemberId is generated by the coordinator when joining the group
+	MemberId            *string
+	MemberEpoch         int32
+	HeartbeatIntervalMs int32
+	// Assignment is nil when the assignment of the member has not changed
+	Assignment *ConsumerGroupHeartbeatAssignment
+}
+
+// ConsumerGroupHeartbeatAssignment is the partitions the coordinator assigned
+// to the member.
+type ConsumerGroupHeartbeatAssignment struct {
+	TopicPartitions []ConsumerGroupHeartbeatTopicPartitions
+}
+
+func (r *ConsumerGroupHeartbeatResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	pe.putInt16(int16(r.Err))
+	if err := pe.putNullableCompactString(r.ErrorMessage); err != nil {
+		return err
+	}
+	if err := pe.putNullableCompactString(r.MemberId); err != nil {
+		return err
+	}
+	pe.putInt32(r.MemberEpoch)
+	pe.putInt32(r.HeartbeatIntervalMs)
+
+	// nullable structs are prefixed by -1 when null and 1 otherwise
+	if r.Assignment == nil {
+		pe.putInt8(-1)
+	} else {
+		pe.putInt8(1)
+		pe.putCompactArrayLength(len(r.Assignment.TopicPartitions))
+		for i := range r.Assignment.TopicPartitions {
+			if err := r.Assignment.TopicPartitions[i].encode(pe); err != nil {
+				return err
+			}
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *ConsumerGroupHeartbeatResponse) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	throttleTime, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
+
+	if r.ErrorMessage, err = pd.getCompactNullableString(); err != nil {
+		return err
+	}
+	if r.MemberId, err = pd.getCompactNullableString(); err != nil {
+		return err
+	}
+	if r.MemberEpoch, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if r.HeartbeatIntervalMs, err = pd.getInt32(); err != nil {
+		return err
+	}
+
+	present, err := pd.getInt8()
+	if err != nil {
+		return err
+	}
+	r.Assignment = nil
+	if present >= 0 {
+		n, err := pd.getCompactArrayLength()
+		if err != nil {
+			return err
+		}
+		r.Assignment = &ConsumerGroupHeartbeatAssignment{
+			TopicPartitions: make([]ConsumerGroupHeartbeatTopicPartitions, n),
+		}
+		for i := range r.Assignment.TopicPartitions {
+			if err := r.Assignment.TopicPartitions[i].decode(pd); err != nil {
+				return err
+			}
+		}
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *ConsumerGroupHeartbeatResponse) key() int16 {
+	return 68
+}
+
+func (r *ConsumerGroupHeartbeatResponse) version() int16 {
+	return r.Version
+}
+
+func (r *ConsumerGroupHeartbeatResponse) headerVersion() int16 {
+	return 1
+}
+
+func (r *ConsumerGroupHeartbeatResponse) requiredVersion() KafkaVersion {
+	return V4_0_0_0
+}
+
+func (r *ConsumerGroupHeartbeatResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
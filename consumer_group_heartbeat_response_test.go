@@ -0,0 +1,77 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	consumerGroupHeartbeatResponseNoAssignment = []byte{
+		0, 0, 0, 100, // ThrottleTimeMs
+		0, 0, // ErrorCode
+		0,                // ErrorMessage
+		4, 'b', 'a', 'r', // MemberId
+		0, 0, 0, 5, // MemberEpoch
+		0, 0, 0x0b, 0xb8, // HeartbeatIntervalMs
+		0xff, // Assignment
+		0,    // empty tagged fields
+	}
+
+	consumerGroupHeartbeatResponseAssignment = []byte{
+		0, 0, 0, 0, // ThrottleTimeMs
+		0, 0, // ErrorCode
+		0,                // ErrorMessage
+		4, 'b', 'a', 'r', // MemberId
+		0, 0, 0, 6, // MemberEpoch
+		0, 0, 0x0b, 0xb8, // HeartbeatIntervalMs
+		1,                                                     // Assignment
+		2,                                                     // TopicPartitions
+		1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, // TopicId
+		2, 0, 0, 0, 2, // Partitions
+		0, // empty tagged fields
+		0, // empty tagged fields
+		0, // empty tagged fields
+	}
+
+	consumerGroupHeartbeatResponseError = []byte{
+		0, 0, 0, 0, // ThrottleTimeMs
+		0, 110, // ErrorCode
+		6, 'e', 'r', 'r', 'o', 'r', // ErrorMessage
+		0,          // MemberId
+		0, 0, 0, 0, // MemberEpoch
+		0, 0, 0, 0, // HeartbeatIntervalMs
+		0xff, // Assignment
+		0,    // empty tagged fields
+	}
+)
+
+func TestConsumerGroupHeartbeatResponse(t *testing.T) {
+	memberID := "bar"
+	response := &ConsumerGroupHeartbeatResponse{
+		ThrottleTime:        100 * time.Millisecond,
+		MemberId:            &memberID,
+		MemberEpoch:         5,
+		HeartbeatIntervalMs: 3000,
+	}
+	testResponse(t, "no assignment", response, consumerGroupHeartbeatResponseNoAssignment)
+
+	response = &ConsumerGroupHeartbeatResponse{
+		MemberId:            &memberID,
+		MemberEpoch:         6,
+		HeartbeatIntervalMs: 3000,
+		Assignment: &ConsumerGroupHeartbeatAssignment{
+			TopicPartitions: []ConsumerGroupHeartbeatTopicPartitions{{
+				TopicId:    Uuid{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+				Partitions: []int32{2},
+			}},
+		},
+	}
+	testResponse(t, "assignment", response, consumerGroupHeartbeatResponseAssignment)
+
+	errorMessage := "error"
+	response = &ConsumerGroupHeartbeatResponse{
+		Err:          ErrFencedMemberEpoch,
+		ErrorMessage: &errorMessage,
+	}
+	testResponse(t, "error", response, consumerGroupHeartbeatResponseError)
+}
@@ -2,9 +2,24 @@ package sarama
 
 // ConsumerGroupMemberMetadata holds the metadata for consumer group
 type ConsumerGroupMemberMetadata struct {
-	Version  int16
-	Topics   []string
+	// Version of the metadata schema. The fields added by versions 1 to 3 are
+	// only encoded with Version 3 or above, and decoded whenever present
+	Version int16
+	Topics  []string
+	// UserData is opaque to Kafka and only interpreted by the balance strategy
 	UserData []byte
+	// OwnedPartitions holds the partitions owned by the member (version 1+)
+	OwnedPartitions []*OwnedPartition
+	// GenerationID is the generation of the owned partitions (version 2+)
+	GenerationID int32
+	// RackID is the rack of the member, nil if unknown (version 3+)
+	RackID *string
+}
+
+// OwnedPartition is a set of partitions of a topic owned by a group member
+type OwnedPartition struct {
+	Topic      string
+	Partitions []int32
 }
 
 func (m *ConsumerGroupMemberMetadata) encode(pe packetEncoder) error {
@@ -18,6 +33,26 @@ func (m *ConsumerGroupMemberMetadata) encode(pe packetEncoder) error {
 		return err
 	}
 
+	if m.Version >= 3 {
+		if err := pe.putArrayLength(len(m.OwnedPartitions)); err != nil {
+			return err
+		}
+		for _, owned := range m.OwnedPartitions {
+			if err := pe.putString(owned.Topic); err != nil {
+				return err
+			}
+			if err := pe.putInt32Array(owned.Partitions); err != nil {
+				return err
+			}
+		}
+
+		pe.putInt32(m.GenerationID)
+
+		if err := pe.putNullableString(m.RackID); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -34,13 +69,47 @@ func (m *ConsumerGroupMemberMetadata) decode(pd packetDecoder) (err error) {
 		return
 	}
 
+	if m.Version >= 1 && pd.remaining() > 0 {
+		var n int
+		if n, err = pd.getArrayLength(); err != nil {
+			return
+		}
+		m.OwnedPartitions = make([]*OwnedPartition, n)
+		for i := range m.OwnedPartitions {
+			owned := new(OwnedPartition)
+			if owned.Topic, err = pd.getString(); err != nil {
+				return
+			}
+			if owned.Partitions, err = pd.getInt32Array(); err != nil {
+				return
+			}
+			m.OwnedPartitions[i] = owned
+		}
+	}
+
+	if m.Version >= 2 && pd.remaining() > 0 {
+		if m.GenerationID, err = pd.getInt32(); err != nil {
+			return
+		}
+	}
+
+	if m.Version >= 3 && pd.remaining() > 0 {
+		if m.RackID, err = pd.getNullableString(); err != nil {
+			return
+		}
+	}
+
 	return nil
 }
 
 // ConsumerGroupMemberAssignment holds the member assignment for a consume group
 type ConsumerGroupMemberAssignment struct {
-	Version  int16
-	Topics   map[string][]int32
+	// Version of the assignment schema, only the fields of version 0 are
+	// decoded and fields added by newer versions are ignored
+	Version int16
+	Topics  map[string][]int32
+	// UserData is the data returned by BalanceStrategy.AssignmentData on the
+	// leader for this member
 	UserData []byte
 }
 
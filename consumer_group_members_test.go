@@ -14,6 +14,17 @@ var (
 		0, 3, 't', 'w', 'o', // Topic two
 		0, 0, 0, 3, 0x01, 0x02, 0x03, // Userdata
 	}
+	groupMemberMetadataV3 = []byte{
+		0, 3, // Version
+		0, 0, 0, 1, // Topic array length
+		0, 3, 'o', 'n', 'e', // Topic one
+		0, 0, 0, 3, 0x01, 0x02, 0x03, // Userdata
+		0, 0, 0, 1, // OwnedPartitions array length
+		0, 3, 'o', 'n', 'e', // Topic one
+		0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 2, // 0, 2
+		0, 0, 0, 5, // GenerationID
+		0, 4, 'r', 'a', 'c', 'k', // RackID
+	}
 	groupMemberAssignment = []byte{
 		0, 1, // Version
 		0, 0, 0, 1, // Topic array length
@@ -47,6 +58,35 @@ func TestConsumerGroupMemberMetadata(t *testing.T) {
 	}
 }
 
+func TestConsumerGroupMemberMetadataV3(t *testing.T) {
+	rack := "rack"
+	meta := &ConsumerGroupMemberMetadata{
+		Version:  3,
+		Topics:   []string{"one"},
+		UserData: []byte{0x01, 0x02, 0x03},
+		OwnedPartitions: []*OwnedPartition{
+			{Topic: "one", Partitions: []int32{0, 2}},
+		},
+		GenerationID: 5,
+		RackID:       &rack,
+	}
+
+	buf, err := encode(meta, nil)
+	if err != nil {
+		t.Error("Failed to encode data", err)
+	} else if !bytes.Equal(groupMemberMetadataV3, buf) {
+		t.Errorf("Encoded data does not match expectation\nexpected: %v\nactual: %v", groupMemberMetadataV3, buf)
+	}
+
+	meta2 := new(ConsumerGroupMemberMetadata)
+	err = decode(buf, meta2)
+	if err != nil {
+		t.Error("Failed to decode data", err)
+	} else if !reflect.DeepEqual(meta, meta2) {
+		t.Errorf("Encoded data does not match expectation\nexpected: %v\nactual: %v", meta, meta2)
+	}
+}
+
 func TestConsumerGroupMemberAssignment(t *testing.T) {
 	amt := &ConsumerGroupMemberAssignment{
 		Version: 1,
@@ -2,7 +2,15 @@ package sarama
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
 )
 
 type exampleConsumerGroupHandler struct{}
@@ -50,3 +58,1286 @@ func ExampleConsumerGroup() {
 		}
 	}
 }
+
+type tracingConsumerGroupHandler struct {
+	ConsumerGroupHandler
+	name  string
+	calls *[]string
+}
+
+func (h *tracingConsumerGroupHandler) Setup(sess ConsumerGroupSession) error {
+	*h.calls = append(*h.calls, h.name)
+	return h.ConsumerGroupHandler.Setup(sess)
+}
+
+type listeningExampleHandler struct {
+	exampleConsumerGroupHandler
+}
+
+func (listeningExampleHandler) OnPartitionsAssigned(ConsumerGroupSession, map[string][]int32) error {
+	return nil
+}
+
+func (listeningExampleHandler) OnPartitionsRevoked(ConsumerGroupSession, map[string][]int32) error {
+	return nil
+}
+
+func (listeningExampleHandler) OnPartitionsLost(ConsumerGroupSession, map[string][]int32) error {
+	return nil
+}
+
+func (listeningExampleHandler) OnOffsetOutOfRange(ConsumerGroupSession, *OffsetOutOfRangeError) {}
+
+func TestWrapConsumerGroupHandler(t *testing.T) {
+	var calls []string
+	tracing := func(name string) ConsumerGroupHandlerMiddleware {
+		return func(next ConsumerGroupHandler) ConsumerGroupHandler {
+			return &tracingConsumerGroupHandler{ConsumerGroupHandler: next, name: name, calls: &calls}
+		}
+	}
+
+	handler := wrapConsumerGroupHandler(listeningExampleHandler{}, []ConsumerGroupHandlerMiddleware{
+		tracing("outer"), tracing("inner"),
+	})
+	if err := handler.Setup(nil); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"outer", "inner"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected middlewares to be called in order %v, got %v", expected, calls)
+	}
+	if _, ok := handler.(ConsumerGroupRebalanceListener); !ok {
+		t.Error("Expected the rebalance listener of the handler to be kept")
+	}
+	if _, ok := handler.(ConsumerGroupOffsetOutOfRangeListener); !ok {
+		t.Error("Expected the offset out of range listener of the handler to be kept")
+	}
+
+	handler = wrapConsumerGroupHandler(exampleConsumerGroupHandler{}, nil)
+	if _, ok := handler.(exampleConsumerGroupHandler); !ok {
+		t.Errorf("Expected the handler to be left unwrapped, got %T", handler)
+	}
+}
+
+type relayedPartitionConsumer struct {
+	PartitionConsumer
+	messages chan *ConsumerMessage
+}
+
+func (pc *relayedPartitionConsumer) Messages() <-chan *ConsumerMessage { return pc.messages }
+
+func TestConsumerGroupClaimStalled(t *testing.T) {
+	pc := &relayedPartitionConsumer{messages: make(chan *ConsumerMessage)}
+	claim := &consumerGroupClaim{
+		topic:             "my_topic",
+		partition:         0,
+		PartitionConsumer: pc,
+		messages:          make(chan *ConsumerMessage),
+	}
+	go claim.relayMessages()
+
+	if d := claim.stalledFor(); d != 0 {
+		t.Errorf("Expected no stall without messages, got %v", d)
+	}
+
+	pc.messages <- &ConsumerMessage{Offset: 1}
+	time.Sleep(20 * time.Millisecond)
+	if d := claim.stalledFor(); d < 20*time.Millisecond {
+		t.Errorf("Expected the unread message to stall the claim, got %v", d)
+	}
+
+	claim.progress()
+	if d := claim.stalledFor(); d >= 20*time.Millisecond {
+		t.Errorf("Expected progress to restart the wait, got %v", d)
+	}
+
+	if msg := <-claim.Messages(); msg.Offset != 1 {
+		t.Errorf("Expected to read the relayed message, got offset %d", msg.Offset)
+	}
+	close(pc.messages)
+	for range claim.Messages() {
+	}
+	if d := claim.stalledFor(); d != 0 {
+		t.Errorf("Expected no stall once the message was read, got %v", d)
+	}
+}
+
+// mockResponseFunc adapts a function to a MockResponse, for responses
+// depending on the request or on the progress of a test
+type mockResponseFunc func(reqBody versionedDecoder) encoderWithHeader
+
+func (f mockResponseFunc) For(reqBody versionedDecoder) encoderWithHeader { return f(reqBody) }
+
+// newConsumerGroupTestHandlers returns the handlers of a MockBroker leading
+// my-topic/0, on which the offset 5 of my-group is committed, and assigning it
+// to member-1 of my-group with the classic protocol.
+func newConsumerGroupTestHandlers(t *testing.T, broker *MockBroker, fetchVersion int16) map[string]MockResponse {
+	return map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("my-topic", 0, broker.BrokerID()),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "my-group", broker),
+		"JoinGroupRequest": NewMockJoinGroupResponse(t).
+			SetGroupProtocol(RangeBalanceStrategyName).
+			SetGenerationId(1).
+			SetMemberId("member-1").
+			SetLeaderId("member-0"),
+		"SyncGroupRequest": NewMockSyncGroupResponse(t).
+			SetMemberAssignment(&ConsumerGroupMemberAssignment{Topics: map[string][]int32{"my-topic": {0}}}),
+		"HeartbeatRequest": NewMockHeartbeatResponse(t),
+		"OffsetFetchRequest": NewMockOffsetFetchResponse(t).
+			SetOffset("my-group", "my-topic", 0, 5, "", ErrNoError),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetVersion(1).
+			SetOffset("my-topic", 0, OffsetOldest, 0).
+			SetOffset("my-topic", 0, OffsetNewest, 10),
+		"FetchRequest":        NewMockFetchResponse(t, 1).SetVersion(fetchVersion),
+		"OffsetCommitRequest": NewMockOffsetCommitResponse(t),
+		"LeaveGroupRequest":   NewMockLeaveGroupResponse(t),
+	}
+}
+
+// withHandlers returns a copy of handlers with the given ones replaced, to
+// change the handlers of a running MockBroker
+func withHandlers(handlers map[string]MockResponse, replaced map[string]MockResponse) map[string]MockResponse {
+	copied := make(map[string]MockResponse, len(handlers))
+	for name, handler := range handlers {
+		copied[name] = handler
+	}
+	for name, handler := range replaced {
+		copied[name] = handler
+	}
+	return copied
+}
+
+func newConsumerGroupTestConfig() *Config {
+	config := NewTestConfig()
+	config.Version = V2_0_0_0
+	config.Consumer.Return.Errors = true
+	config.Consumer.Group.Heartbeat.Interval = 10 * time.Millisecond
+	config.Consumer.Group.Rebalance.Retry.Backoff = 10 * time.Millisecond
+	config.Metadata.Retry.Backoff = 10 * time.Millisecond
+	return config
+}
+
+// testConsumerGroupHandler consumes its claims until they are revoked and
+// records the calls of the rebalance listener
+type testConsumerGroupHandler struct {
+	claimed chan ConsumerGroupClaim
+
+	lock  sync.Mutex
+	calls []string
+}
+
+func newTestConsumerGroupHandler() *testConsumerGroupHandler {
+	return &testConsumerGroupHandler{claimed: make(chan ConsumerGroupClaim, 10)}
+}
+
+func (h *testConsumerGroupHandler) Setup(ConsumerGroupSession) error   { return nil }
+func (h *testConsumerGroupHandler) Cleanup(ConsumerGroupSession) error { return nil }
+
+func (h *testConsumerGroupHandler) ConsumeClaim(_ ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	h.claimed <- claim
+	for range claim.Messages() {
+	}
+	return nil
+}
+
+func (h *testConsumerGroupHandler) record(call string) error {
+	h.lock.Lock()
+	h.calls = append(h.calls, call)
+	h.lock.Unlock()
+	return nil
+}
+
+func (h *testConsumerGroupHandler) Calls() []string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return append([]string(nil), h.calls...)
+}
+
+func (h *testConsumerGroupHandler) OnPartitionsAssigned(ConsumerGroupSession, map[string][]int32) error {
+	return h.record("assigned")
+}
+
+func (h *testConsumerGroupHandler) OnPartitionsRevoked(ConsumerGroupSession, map[string][]int32) error {
+	return h.record("revoked")
+}
+
+func (h *testConsumerGroupHandler) OnPartitionsLost(ConsumerGroupSession, map[string][]int32) error {
+	return h.record("lost")
+}
+
+// waitClaim returns the next claim consumed by h
+func (h *testConsumerGroupHandler) waitClaim(t *testing.T) ConsumerGroupClaim {
+	t.Helper()
+	select {
+	case claim := <-h.claimed:
+		return claim
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a claim to be consumed")
+		return nil
+	}
+}
+
+// consumeInBackground runs consume and returns the channel of its result
+func consumeInBackground(consume func() error) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- consume() }()
+	return done
+}
+
+func waitConsumed(t *testing.T, done <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the session to end")
+		return nil
+	}
+}
+
+func TestConsumerGroupPartitionsRevokedOrLost(t *testing.T) {
+	for _, tt := range []struct {
+		err      KError
+		expected string
+	}{
+		{ErrRebalanceInProgress, "revoked"},
+		{ErrGroupAuthorizationFailed, "revoked"},
+		{ErrUnknownMemberId, "lost"},
+		{ErrIllegalGeneration, "lost"},
+	} {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			broker := NewMockBroker(t, 0)
+			defer broker.Close()
+			handlers := newConsumerGroupTestHandlers(t, broker, 7)
+			broker.SetHandlerByMap(handlers)
+
+			group, err := NewConsumerGroup([]string{broker.Addr()}, "my-group", newConsumerGroupTestConfig())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer safeClose(t, group)
+			go func() {
+				for range group.Errors() {
+				}
+			}()
+
+			handler := newTestConsumerGroupHandler()
+			done := consumeInBackground(func() error {
+				return group.Consume(context.Background(), []string{"my-topic"}, handler)
+			})
+			handler.waitClaim(t)
+			broker.SetHandlerByMap(withHandlers(handlers, map[string]MockResponse{
+				"HeartbeatRequest": NewMockHeartbeatResponse(t).SetError(tt.err),
+			}))
+			if err := waitConsumed(t, done); err != nil {
+				t.Fatal(err)
+			}
+
+			if calls := handler.Calls(); !reflect.DeepEqual(calls, []string{"assigned", tt.expected}) {
+				t.Errorf("Expected the partitions to be assigned then %s, got %v", tt.expected, calls)
+			}
+		})
+	}
+}
+
+func TestConsumerGroupClaimContext(t *testing.T) {
+	broker := NewMockBroker(t, 0)
+	defer broker.Close()
+	handlers := newConsumerGroupTestHandlers(t, broker, 7)
+	broker.SetHandlerByMap(handlers)
+
+	group, err := NewConsumerGroup([]string{broker.Addr()}, "my-group", newConsumerGroupTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+	handler := newTestConsumerGroupHandler()
+	done := consumeInBackground(func() error {
+		return group.Consume(ctx, []string{"my-topic"}, handler)
+	})
+	claim := handler.waitClaim(t)
+	if err := claim.Context().Err(); err != nil {
+		t.Fatalf("Expected the context of the claim to be live, got %v", err)
+	}
+	if claim.Context().Value(key{}) != "value" {
+		t.Error("Expected the context of the claim to derive from the context given to Consume")
+	}
+
+	// revoke the claim with a rebalance
+	broker.SetHandlerByMap(withHandlers(handlers, map[string]MockResponse{
+		"HeartbeatRequest": NewMockHeartbeatResponse(t).SetError(ErrRebalanceInProgress),
+	}))
+	select {
+	case <-claim.Context().Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the context of the claim to be canceled on revocation")
+	}
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+
+	// end the next session with the context given to Consume
+	broker.SetHandlerByMap(handlers)
+	ctx, cancel := context.WithCancel(context.Background())
+	done = consumeInBackground(func() error {
+		return group.Consume(ctx, []string{"my-topic"}, handler)
+	})
+	claim = handler.waitClaim(t)
+	cancel()
+	select {
+	case <-claim.Context().Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the context of the claim to be canceled at the end of the session")
+	}
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConsumerGroupPause(t *testing.T) {
+	broker := NewMockBroker(t, 0)
+	defer broker.Close()
+	handlers := newConsumerGroupTestHandlers(t, broker, 7)
+	broker.SetHandlerByMap(handlers)
+
+	group, err := NewConsumerGroup([]string{broker.Addr()}, "my-group", newConsumerGroupTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	handler := newTestConsumerGroupHandler()
+	done := consumeInBackground(func() error {
+		return group.Consume(context.Background(), []string{"my-topic"}, handler)
+	})
+	claim := handler.waitClaim(t).(*consumerGroupClaim)
+	expectPaused := func(claim *consumerGroupClaim, paused map[string][]int32) {
+		t.Helper()
+		if actual := group.Paused(); !reflect.DeepEqual(actual, paused) {
+			t.Errorf("Expected %v to be paused, got %v", paused, actual)
+		}
+		if expected := len(paused["my-topic"]) > 0; claim.IsPaused() != expected {
+			t.Errorf("Expected the claim to be paused: %v, got %v", expected, claim.IsPaused())
+		}
+	}
+	expectPaused(claim, map[string][]int32{})
+
+	group.Pause(map[string][]int32{"my-topic": {0}})
+	expectPaused(claim, map[string][]int32{"my-topic": {0}})
+	group.Resume(map[string][]int32{"my-topic": {0}})
+	expectPaused(claim, map[string][]int32{})
+
+	// PauseAll pauses the claimed partitions
+	group.PauseAll()
+	expectPaused(claim, map[string][]int32{"my-topic": {0}})
+	group.ResumeAll()
+	expectPaused(claim, map[string][]int32{})
+
+	// the claimed partitions stay paused when they are claimed again after a
+	// rebalance, unlike the others
+	group.Pause(map[string][]int32{"my-topic": {0}, "other-topic": {0}})
+	broker.SetHandlerByMap(withHandlers(handlers, map[string]MockResponse{
+		"HeartbeatRequest": NewMockHeartbeatResponse(t).SetError(ErrRebalanceInProgress),
+	}))
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+	broker.SetHandlerByMap(handlers)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done = consumeInBackground(func() error {
+		return group.Consume(ctx, []string{"my-topic"}, handler)
+	})
+	reclaimed := handler.waitClaim(t).(*consumerGroupClaim)
+	if reclaimed == claim {
+		t.Fatal("Expected the claim to be created again")
+	}
+	expectPaused(reclaimed, map[string][]int32{"my-topic": {0}})
+
+	group.ResumeAll()
+	expectPaused(reclaimed, map[string][]int32{})
+	cancel()
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// stuckConsumerGroupHandler does not return from ConsumeClaim until released
+type stuckConsumerGroupHandler struct {
+	*testConsumerGroupHandler
+	released chan none
+}
+
+func (h *stuckConsumerGroupHandler) ConsumeClaim(_ ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	h.claimed <- claim
+	<-h.released
+	return nil
+}
+
+func TestConsumerGroupCloseWithContextStuckHandler(t *testing.T) {
+	broker := NewMockBroker(t, 0)
+	defer broker.Close()
+	broker.SetHandlerByMap(newConsumerGroupTestHandlers(t, broker, 7))
+
+	group, err := NewConsumerGroup([]string{broker.Addr()}, "my-group", newConsumerGroupTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := &stuckConsumerGroupHandler{testConsumerGroupHandler: newTestConsumerGroupHandler(), released: make(chan none)}
+	done := consumeInBackground(func() error {
+		return group.Consume(context.Background(), []string{"my-topic"}, handler)
+	})
+	handler.waitClaim(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := group.CloseWithContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected the deadline of the context to be exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected CloseWithContext to return once the context is done, took %v", elapsed)
+	}
+
+	// the group is left once the handler returns
+	close(handler.released)
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+	left := func() bool {
+		for _, rr := range broker.History() {
+			if _, ok := rr.Request.(*LeaveGroupRequest); ok {
+				return true
+			}
+		}
+		return false
+	}
+	for deadline := time.Now().Add(5 * time.Second); !left(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the group to be left once the session is released")
+		}
+	}
+}
+
+func TestConsumerGroupMaxPollIntervalExceeded(t *testing.T) {
+	broker := NewMockBroker(t, 0)
+	defer broker.Close()
+	broker.SetHandlerByMap(withHandlers(newConsumerGroupTestHandlers(t, broker, 7), map[string]MockResponse{
+		"FetchRequest": NewMockFetchResponse(t, 1).SetVersion(7).
+			SetMessage("my-topic", 0, 5, StringEncoder("value")),
+	}))
+
+	config := newConsumerGroupTestConfig()
+	config.Consumer.Group.MaxPollInterval = 50 * time.Millisecond
+	group, err := NewConsumerGroup([]string{broker.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	handler := &stuckConsumerGroupHandler{testConsumerGroupHandler: newTestConsumerGroupHandler(), released: make(chan none)}
+	done := consumeInBackground(func() error {
+		return group.Consume(context.Background(), []string{"my-topic"}, handler)
+	})
+	handler.waitClaim(t)
+
+	select {
+	case err := <-group.Errors():
+		var exceeded *MaxPollIntervalExceededError
+		if !errors.As(err, &exceeded) {
+			t.Errorf("Expected the max poll interval to be exceeded, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the max poll interval to be exceeded")
+	}
+
+	// the group is left while the handler is still stuck
+	left := func() bool {
+		for _, rr := range broker.History() {
+			if _, ok := rr.Request.(*LeaveGroupRequest); ok {
+				return true
+			}
+		}
+		return false
+	}
+	for deadline := time.Now().Add(5 * time.Second); !left(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the group to be left before the handler returns")
+		}
+	}
+
+	close(handler.released)
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+	if calls := handler.Calls(); !reflect.DeepEqual(calls, []string{"assigned", "lost"}) {
+		t.Errorf("Expected the partitions to be lost, got %v", calls)
+	}
+}
+
+// committingConsumerGroupHandler commits the offset 6 of its claims before
+// consuming them
+type committingConsumerGroupHandler struct {
+	*testConsumerGroupHandler
+}
+
+func (h *committingConsumerGroupHandler) ConsumeClaim(sess ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	h.claimed <- claim
+	sess.MarkOffset(claim.Topic(), claim.Partition(), 6, "")
+	sess.Commit()
+	for range claim.Messages() {
+	}
+	return nil
+}
+
+func TestConsumerGroupHeartbeatDedicatedConnection(t *testing.T) {
+	broker := NewMockBroker(t, 0)
+	defer broker.Close()
+
+	// the first offset commit holds the coordinator connection for 500ms
+	var heartbeats int32
+	committing := make(chan none)
+	var commitOnce sync.Once
+	broker.SetHandlerByMap(withHandlers(newConsumerGroupTestHandlers(t, broker, 7), map[string]MockResponse{
+		"HeartbeatRequest": mockResponseFunc(func(reqBody versionedDecoder) encoderWithHeader {
+			atomic.AddInt32(&heartbeats, 1)
+			return NewMockHeartbeatResponse(t).For(reqBody)
+		}),
+		"OffsetCommitRequest": mockResponseFunc(func(reqBody versionedDecoder) encoderWithHeader {
+			commitOnce.Do(func() { close(committing) })
+			return NewMockOffsetCommitResponse(t).For(reqBody)
+		}),
+	}))
+	broker.InjectFault(MockFault{Request: "OffsetCommitRequest", Times: 1, Delay: 500 * time.Millisecond})
+
+	config := newConsumerGroupTestConfig()
+	config.Consumer.Group.Heartbeat.DedicatedConnection = true
+	group, err := NewConsumerGroup([]string{broker.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler := &committingConsumerGroupHandler{newTestConsumerGroupHandler()}
+	done := consumeInBackground(func() error {
+		return group.Consume(ctx, []string{"my-topic"}, handler)
+	})
+	handler.waitClaim(t)
+
+	select {
+	case <-committing:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the offset to be committed")
+	}
+	// the heartbeats go on over their own connection while the commit is
+	// delayed on the coordinator one
+	sent := atomic.LoadInt32(&heartbeats)
+	time.Sleep(200 * time.Millisecond)
+	if heartbeats := atomic.LoadInt32(&heartbeats) - sent; heartbeats < 5 {
+		t.Errorf("Expected the heartbeats not to wait for the commit, got %d in 200ms", heartbeats)
+	}
+
+	cancel()
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConsumerGroupHeartbeatTimeout(t *testing.T) {
+	broker := NewMockBroker(t, 0)
+	defer broker.Close()
+
+	// the heartbeats are answered later than Heartbeat.Timeout but well
+	// within Net.ReadTimeout
+	broker.SetHandlerByMap(newConsumerGroupTestHandlers(t, broker, 7))
+	broker.InjectFault(MockFault{Request: "HeartbeatRequest", Delay: 200 * time.Millisecond})
+
+	config := newConsumerGroupTestConfig()
+	config.Consumer.Group.Heartbeat.DedicatedConnection = true
+	config.Consumer.Group.Heartbeat.Timeout = 50 * time.Millisecond
+	config.Metadata.Retry.Max = 1
+	group, err := NewConsumerGroup([]string{broker.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	handler := newTestConsumerGroupHandler()
+	done := consumeInBackground(func() error {
+		return group.Consume(context.Background(), []string{"my-topic"}, handler)
+	})
+	handler.waitClaim(t)
+
+	select {
+	case err := <-group.Errors():
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			t.Errorf("Expected the heartbeat to time out, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the heartbeat to time out")
+	}
+	// the failed heartbeats end the session
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConsumerGroupResolveOffset(t *testing.T) {
+	broker := NewMockBroker(t, 0)
+	defer broker.Close()
+	broker.SetHandlerByMap(newConsumerGroupTestHandlers(t, broker, 7))
+
+	var committed []int64
+	config := newConsumerGroupTestConfig()
+	config.Consumer.Group.ResolveOffset = func(topic string, partition int32, offset int64) (int64, error) {
+		committed = append(committed, offset)
+		if len(committed) > 1 {
+			return 0, errors.New("offset store unavailable")
+		}
+		return 7, nil
+	}
+	group, err := NewConsumerGroup([]string{broker.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	handler := newTestConsumerGroupHandler()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := consumeInBackground(func() error {
+		return group.Consume(ctx, []string{"my-topic"}, handler)
+	})
+	if claim := handler.waitClaim(t); claim.InitialOffset() != 7 {
+		t.Errorf("Expected the claim to start from the resolved offset 7, got %d", claim.InitialOffset())
+	}
+	cancel()
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(committed, []int64{5}) {
+		t.Errorf("Expected the committed offset to be resolved, got %v", committed)
+	}
+
+	// the partition is not consumed when the offset cannot be resolved
+	done = consumeInBackground(func() error {
+		return group.Consume(context.Background(), []string{"my-topic"}, handler)
+	})
+	select {
+	case err := <-group.Errors():
+		var consumerErr *ConsumerError
+		if !errors.As(err, &consumerErr) || consumerErr.Topic != "my-topic" || consumerErr.Err.Error() != "offset store unavailable" {
+			t.Errorf("Expected the error of ResolveOffset for my-topic/0, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the error of ResolveOffset to be reported")
+	}
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case claim := <-handler.claimed:
+		t.Errorf("Expected the partition not to be consumed, got a claim from %d", claim.InitialOffset())
+	default:
+	}
+}
+
+// outOfRangeTestHandler relays the first message of each claim and the
+// partitions given up on because their committed offset is out of range
+type outOfRangeTestHandler struct {
+	first      chan *ConsumerMessage
+	outOfRange chan *OffsetOutOfRangeError
+}
+
+func (h *outOfRangeTestHandler) Setup(ConsumerGroupSession) error   { return nil }
+func (h *outOfRangeTestHandler) Cleanup(ConsumerGroupSession) error { return nil }
+
+func (h *outOfRangeTestHandler) ConsumeClaim(_ ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	first := true
+	for msg := range claim.Messages() {
+		if first {
+			h.first <- msg
+			first = false
+		}
+	}
+	return nil
+}
+
+func (h *outOfRangeTestHandler) OnOffsetOutOfRange(_ ConsumerGroupSession, err *OffsetOutOfRangeError) {
+	h.outOfRange <- err
+}
+
+func TestConsumerGroupOffsetOutOfRange(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		policy OffsetOutOfRangePolicy
+		topics map[string]OffsetOutOfRangePolicy
+		// the offset my-topic/0 is consumed from, -1 when it is not consumed
+		expected int64
+	}{
+		{"ResetOldest", OffsetOutOfRangeResetOldest, nil, 8},
+		{"ResetNewest", OffsetOutOfRangeResetNewest, nil, 10},
+		{"Fail", OffsetOutOfRangeFail, nil, -1},
+		{"TopicResetOldest", OffsetOutOfRangeFail, map[string]OffsetOutOfRangePolicy{"my-topic": OffsetOutOfRangeResetOldest}, 8},
+		{"TopicFail", OffsetOutOfRangeResetNewest, map[string]OffsetOutOfRangePolicy{"my-topic": OffsetOutOfRangeFail}, -1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := NewMockBroker(t, 0)
+			defer broker.Close()
+
+			// the committed offset 5 of my-topic/0 was truncated, the one of
+			// other-topic/0 is still available
+			broker.SetHandlerByMap(withHandlers(newConsumerGroupTestHandlers(t, broker, 7), map[string]MockResponse{
+				"MetadataRequest": NewMockMetadataResponse(t).
+					SetBroker(broker.Addr(), broker.BrokerID()).
+					SetLeader("my-topic", 0, broker.BrokerID()).
+					SetLeader("other-topic", 0, broker.BrokerID()),
+				"SyncGroupRequest": NewMockSyncGroupResponse(t).
+					SetMemberAssignment(&ConsumerGroupMemberAssignment{Topics: map[string][]int32{"my-topic": {0}, "other-topic": {0}}}),
+				"OffsetFetchRequest": NewMockOffsetFetchResponse(t).
+					SetOffset("my-group", "my-topic", 0, 5, "", ErrNoError).
+					SetOffset("my-group", "other-topic", 0, 9, "", ErrNoError),
+				"OffsetRequest": NewMockOffsetResponse(t).
+					SetVersion(1).
+					SetOffset("my-topic", 0, OffsetOldest, 8).
+					SetOffset("my-topic", 0, OffsetNewest, 10).
+					SetOffset("other-topic", 0, OffsetOldest, 8).
+					SetOffset("other-topic", 0, OffsetNewest, 10),
+				"FetchRequest": NewMockFetchResponse(t, 1).
+					SetVersion(7).
+					SetMessage("my-topic", 0, 8, StringEncoder("value")).
+					SetMessage("my-topic", 0, 10, StringEncoder("value")).
+					SetMessage("other-topic", 0, 9, StringEncoder("value")),
+			}))
+
+			config := newConsumerGroupTestConfig()
+			config.Consumer.Offsets.OutOfRange.Policy = tt.policy
+			config.Consumer.Offsets.OutOfRange.Topics = tt.topics
+			group, err := NewConsumerGroup([]string{broker.Addr()}, "my-group", config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer safeClose(t, group)
+
+			handler := &outOfRangeTestHandler{
+				first:      make(chan *ConsumerMessage, 2),
+				outOfRange: make(chan *OffsetOutOfRangeError, 1),
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := consumeInBackground(func() error {
+				return group.Consume(ctx, []string{"my-topic", "other-topic"}, handler)
+			})
+
+			consumed := make(map[string]int64)
+			for len(consumed) < 2 && (tt.expected >= 0 || len(consumed) < 1) {
+				select {
+				case msg := <-handler.first:
+					consumed[msg.Topic] = msg.Offset
+				case <-time.After(5 * time.Second):
+					t.Fatalf("Expected the partitions to be consumed, got %v", consumed)
+				}
+			}
+			if offset := consumed["other-topic"]; offset != 9 {
+				t.Errorf("Expected other-topic/0 to be consumed from its committed offset 9, got %d", offset)
+			}
+
+			if tt.expected >= 0 {
+				if offset := consumed["my-topic"]; offset != tt.expected {
+					t.Errorf("Expected my-topic/0 to be consumed from %d, got %d", tt.expected, offset)
+				}
+			} else {
+				select {
+				case err := <-handler.outOfRange:
+					if err.Topic != "my-topic" || err.Partition != 0 || err.Offset != 5 {
+						t.Errorf("Expected the committed offset 5 of my-topic/0 to be out of range, got %v", err)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatal("Expected the handler to be told about my-topic/0")
+				}
+
+				var outOfRange *OffsetOutOfRangeError
+				select {
+				case err := <-group.Errors():
+					if !errors.As(err, &outOfRange) {
+						t.Errorf("Expected an *OffsetOutOfRangeError, got %v", err)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatal("Expected the *OffsetOutOfRangeError to be reported")
+				}
+
+				// the session goes on for other-topic/0
+				select {
+				case err := <-done:
+					t.Fatalf("Expected the session to go on, ended with %v", err)
+				case <-time.After(100 * time.Millisecond):
+				}
+				if _, ok := consumed["my-topic"]; ok {
+					t.Error("Expected my-topic/0 not to be consumed")
+				}
+			}
+
+			cancel()
+			if err := waitConsumed(t, done); err != nil {
+				t.Fatal(err)
+			}
+			var joins int
+			for _, rr := range broker.History() {
+				if _, ok := rr.Request.(*JoinGroupRequest); ok {
+					joins++
+				}
+			}
+			if joins != 1 {
+				t.Errorf("Expected a single session, got %d JoinGroupRequests", joins)
+			}
+		})
+	}
+}
+
+// memberDataStrategy is a range strategy exchanging user data with the group
+type memberDataStrategy struct {
+	BalanceStrategy
+	err error
+
+	lock        sync.Mutex
+	members     map[string]ConsumerGroupMemberMetadata
+	assignments []*ConsumerGroupMemberAssignment
+}
+
+func (s *memberDataStrategy) Plan(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32) (BalanceStrategyPlan, error) {
+	s.lock.Lock()
+	s.members = members
+	s.lock.Unlock()
+	return s.BalanceStrategy.Plan(members, topics)
+}
+
+func (s *memberDataStrategy) AssignmentData(memberID string, topics map[string][]int32, generationID int32) ([]byte, error) {
+	return []byte("assigned-" + memberID), nil
+}
+
+func (s *memberDataStrategy) MemberUserData(topics []string, assignmentUserData []byte) ([]byte, error) {
+	return []byte("hint"), nil
+}
+
+func (s *memberDataStrategy) OnAssignment(assignment *ConsumerGroupMemberAssignment, generationID int32) error {
+	s.lock.Lock()
+	s.assignments = append(s.assignments, assignment)
+	s.lock.Unlock()
+	return s.err
+}
+
+func TestConsumerGroupMemberData(t *testing.T) {
+	broker := NewMockBroker(t, 0)
+	defer broker.Close()
+	broker.SetHandlerByMap(withHandlers(newConsumerGroupTestHandlers(t, broker, 7), map[string]MockResponse{
+		"JoinGroupRequest": NewMockJoinGroupResponse(t).
+			SetGroupProtocol(RangeBalanceStrategyName).
+			SetGenerationId(1).
+			SetMemberId("member-1").
+			SetLeaderId("member-1").
+			SetMember("member-1", &ConsumerGroupMemberMetadata{Topics: []string{"my-topic"}, UserData: []byte("hint-1")}),
+		"SyncGroupRequest": NewMockSyncGroupResponse(t).
+			SetMemberAssignment(&ConsumerGroupMemberAssignment{
+				Topics:   map[string][]int32{"my-topic": {0}},
+				UserData: []byte("assigned-member-1"),
+			}),
+	}))
+
+	strategy := &memberDataStrategy{BalanceStrategy: BalanceStrategyRange}
+	config := newConsumerGroupTestConfig()
+	config.Consumer.Group.Rebalance.Strategy = strategy
+	group, err := NewConsumerGroup([]string{broker.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	handler := newTestConsumerGroupHandler()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := consumeInBackground(func() error {
+		return group.Consume(ctx, []string{"my-topic"}, handler)
+	})
+	handler.waitClaim(t)
+	cancel()
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rr := range broker.History() {
+		switch req := rr.Request.(type) {
+		case *JoinGroupRequest:
+			var meta ConsumerGroupMemberMetadata
+			if err := decode(req.OrderedGroupProtocols[0].Metadata, &meta); err != nil {
+				t.Fatal(err)
+			}
+			if string(meta.UserData) != "hint" {
+				t.Errorf("Expected the member user data of the strategy to be sent, got %q", meta.UserData)
+			}
+		case *SyncGroupRequest:
+			var assignment ConsumerGroupMemberAssignment
+			if err := decode(req.GroupAssignments["member-1"], &assignment); err != nil {
+				t.Fatal(err)
+			}
+			if string(assignment.UserData) != "assigned-member-1" {
+				t.Errorf("Expected the assignment data of the strategy to be sent, got %q", assignment.UserData)
+			}
+		}
+	}
+
+	strategy.lock.Lock()
+	defer strategy.lock.Unlock()
+	if string(strategy.members["member-1"].UserData) != "hint-1" {
+		t.Errorf("Expected the user data of the members to reach the strategy, got %+v", strategy.members)
+	}
+	if len(strategy.assignments) != 1 || string(strategy.assignments[0].UserData) != "assigned-member-1" {
+		t.Errorf("Expected OnAssignment to be called with the assignment user data, got %+v", strategy.assignments)
+	}
+}
+
+func TestConsumerGroupOnAssignmentError(t *testing.T) {
+	broker := NewMockBroker(t, 0)
+	defer broker.Close()
+	broker.SetHandlerByMap(newConsumerGroupTestHandlers(t, broker, 7))
+
+	strategy := &memberDataStrategy{BalanceStrategy: BalanceStrategyRange, err: errors.New("assignment rejected")}
+	config := newConsumerGroupTestConfig()
+	config.Consumer.Group.Rebalance.Strategy = strategy
+	group, err := NewConsumerGroup([]string{broker.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	handler := newTestConsumerGroupHandler()
+	if err := group.Consume(context.Background(), []string{"my-topic"}, handler); err != strategy.err {
+		t.Fatalf("Expected the error of OnAssignment, got %v", err)
+	}
+	if calls := handler.Calls(); len(calls) != 0 {
+		t.Errorf("Expected no session to start, got %v", calls)
+	}
+
+	var left bool
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*LeaveGroupRequest); ok && req.MemberId == "member-1" {
+			left = true
+		}
+	}
+	if !left {
+		t.Error("Expected the member to leave the group")
+	}
+}
+
+func TestConsumePatternMatchingTopics(t *testing.T) {
+	broker := NewMockBroker(t, 0)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("orders-eu", 0, broker.BrokerID()).
+			SetLeader("payments", 0, broker.BrokerID()).
+			SetLeader("orders-us", 0, broker.BrokerID()),
+	})
+
+	group, err := NewConsumerGroup([]string{broker.Addr()}, "my-group", newConsumerGroupTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	topics, err := group.(*consumerGroup).matchingTopics(regexp.MustCompile(`^orders-`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"orders-eu", "orders-us"}; !reflect.DeepEqual(topics, expected) {
+		t.Errorf("Expected the matching topics %v, got %v", expected, topics)
+	}
+	if topics, err := group.(*consumerGroup).matchingTopics(regexp.MustCompile(`^refunds$`)); err != nil || len(topics) != 0 {
+		t.Errorf("Expected no matching topics, got %v %v", topics, err)
+	}
+}
+
+func TestConsumePatternNewTopic(t *testing.T) {
+	broker := NewMockBroker(t, 0)
+	defer broker.Close()
+	handlers := newConsumerGroupTestHandlers(t, broker, 7)
+	broker.SetHandlerByMap(handlers)
+
+	config := newConsumerGroupTestConfig()
+	config.Metadata.RefreshFrequency = 20 * time.Millisecond
+	group, err := NewConsumerGroup([]string{broker.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	pattern := regexp.MustCompile(`^my-`)
+	handler := newTestConsumerGroupHandler()
+	done := consumeInBackground(func() error {
+		return group.ConsumePattern(context.Background(), pattern, handler)
+	})
+	handler.waitClaim(t)
+
+	// a new matching topic ends the session
+	broker.SetHandlerByMap(withHandlers(handlers, map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("my-topic", 0, broker.BrokerID()).
+			SetLeader("my-new-topic", 0, broker.BrokerID()),
+	}))
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+	if calls := handler.Calls(); !reflect.DeepEqual(calls, []string{"assigned", "revoked"}) {
+		t.Errorf("Expected the partitions to be revoked, got %v", calls)
+	}
+
+	// and the group is joined again with it
+	ctx, cancel := context.WithCancel(context.Background())
+	done = consumeInBackground(func() error {
+		return group.ConsumePattern(ctx, pattern, handler)
+	})
+	handler.waitClaim(t)
+	cancel()
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+
+	var subscriptions [][]string
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*JoinGroupRequest); ok {
+			var meta ConsumerGroupMemberMetadata
+			if err := decode(req.OrderedGroupProtocols[0].Metadata, &meta); err != nil {
+				t.Fatal(err)
+			}
+			subscriptions = append(subscriptions, meta.Topics)
+		}
+	}
+	if expected := [][]string{{"my-topic"}, {"my-new-topic", "my-topic"}}; !reflect.DeepEqual(subscriptions, expected) {
+		t.Errorf("Expected the subscriptions %v, got %v", expected, subscriptions)
+	}
+}
+
+func TestConsumePatternRequiresRefreshFrequency(t *testing.T) {
+	broker := NewMockBroker(t, 0)
+	defer broker.Close()
+	broker.SetHandlerByMap(newConsumerGroupTestHandlers(t, broker, 7))
+
+	config := newConsumerGroupTestConfig()
+	config.Metadata.RefreshFrequency = 0
+	group, err := NewConsumerGroup([]string{broker.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	err = group.ConsumePattern(context.Background(), regexp.MustCompile(`^none-`), newTestConsumerGroupHandler())
+	if _, ok := err.(ConfigurationError); !ok {
+		t.Errorf("Expected a ConfigurationError, got %v", err)
+	}
+}
+
+var testTopicID = Uuid{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+
+// newConsumerProtocolTestHandlers returns the handlers of
+// newConsumerGroupTestHandlers for a coordinator supporting the consumer group
+// protocol, which assigns my-topic/0 to member-1 when it joins. heartbeat
+// overrides the response to the heartbeats of the member once joined.
+func newConsumerProtocolTestHandlers(t *testing.T, broker *MockBroker, heartbeat func(*ConsumerGroupHeartbeatRequest) KError) map[string]MockResponse {
+	metadata := NewMockMetadataResponse(t).
+		SetBroker(broker.Addr(), broker.BrokerID()).
+		SetLeader("my-topic", 0, broker.BrokerID())
+	return withHandlers(newConsumerGroupTestHandlers(t, broker, 13), map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t).SetApiVersions([]*ApiVersionsResponseBlock{
+			{ApiKey: (&ConsumerGroupHeartbeatRequest{}).key()},
+		}),
+		"MetadataRequest": mockResponseFunc(func(reqBody versionedDecoder) encoderWithHeader {
+			res := metadata.For(reqBody).(*MetadataResponse)
+			for _, topic := range res.Topics {
+				if topic.Name == "my-topic" {
+					topic.Uuid = testTopicID
+				}
+			}
+			return res
+		}),
+		"ConsumerGroupHeartbeatRequest": mockResponseFunc(func(reqBody versionedDecoder) encoderWithHeader {
+			req := reqBody.(*ConsumerGroupHeartbeatRequest)
+			memberID := "member-1"
+			res := &ConsumerGroupHeartbeatResponse{
+				Version:             req.Version,
+				MemberId:            &memberID,
+				MemberEpoch:         req.MemberEpoch,
+				HeartbeatIntervalMs: 10,
+			}
+			switch {
+			case req.MemberEpoch == 0:
+				res.MemberEpoch = 1
+				res.Assignment = &ConsumerGroupHeartbeatAssignment{
+					TopicPartitions: []ConsumerGroupHeartbeatTopicPartitions{{TopicId: testTopicID, Partitions: []int32{0}}},
+				}
+			case req.MemberEpoch > 0 && heartbeat != nil:
+				res.Err = heartbeat(req)
+			}
+			return res
+		}),
+	})
+}
+
+func newConsumerProtocolTestConfig() *Config {
+	config := newConsumerGroupTestConfig()
+	config.Version = V4_0_0_0
+	config.Consumer.Group.Protocol = GroupProtocolConsumer
+	return config
+}
+
+func TestConsumerGroupConsumerProtocol(t *testing.T) {
+	broker := NewMockBroker(t, 0)
+	defer broker.Close()
+	broker.SetHandlerByMap(newConsumerProtocolTestHandlers(t, broker, nil))
+
+	group, err := NewConsumerGroup([]string{broker.Addr()}, "my-group", newConsumerProtocolTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := newTestConsumerGroupHandler()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := consumeInBackground(func() error {
+		return group.Consume(ctx, []string{"my-topic"}, handler)
+	})
+	claim := handler.waitClaim(t)
+	if claim.Topic() != "my-topic" || claim.Partition() != 0 || claim.InitialOffset() != 5 {
+		t.Errorf("Expected to consume my-topic/0 from the committed offset, got %s/%d from %d", claim.Topic(), claim.Partition(), claim.InitialOffset())
+	}
+	time.Sleep(50 * time.Millisecond) // heartbeat a few times
+	cancel()
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+	safeClose(t, group)
+
+	var heartbeats []*ConsumerGroupHeartbeatRequest
+	for _, rr := range broker.History() {
+		switch req := rr.Request.(type) {
+		case *ConsumerGroupHeartbeatRequest:
+			heartbeats = append(heartbeats, req)
+		case *JoinGroupRequest, *SyncGroupRequest, *HeartbeatRequest:
+			t.Errorf("Expected no request of the classic protocol, got %T", req)
+		}
+	}
+	if len(heartbeats) < 3 {
+		t.Fatalf("Expected to join, heartbeat and leave the group, got %d heartbeats", len(heartbeats))
+	}
+	join, ack, leave := heartbeats[0], heartbeats[1], heartbeats[len(heartbeats)-1]
+	if join.MemberEpoch != 0 || join.MemberId != "" || !reflect.DeepEqual(join.SubscribedTopicNames, []string{"my-topic"}) {
+		t.Errorf("Expected to join the group subscribed to my-topic, got %+v", join)
+	}
+	if ack.MemberEpoch != 1 || ack.MemberId != "member-1" || len(ack.TopicPartitions) != 1 || ack.TopicPartitions[0].TopicId != testTopicID {
+		t.Errorf("Expected to acknowledge the assignment, got %+v", ack)
+	}
+	if leave.MemberEpoch != -1 || leave.MemberId != "member-1" {
+		t.Errorf("Expected to leave the group, got %+v", leave)
+	}
+}
+
+func TestConsumerGroupConsumerProtocolFallback(t *testing.T) {
+	broker := NewMockBroker(t, 0)
+	defer broker.Close()
+	broker.SetHandlerByMap(withHandlers(newConsumerProtocolTestHandlers(t, broker, nil), map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+	}))
+
+	group, err := NewConsumerGroup([]string{broker.Addr()}, "my-group", newConsumerProtocolTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	handler := newTestConsumerGroupHandler()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := consumeInBackground(func() error {
+		return group.Consume(ctx, []string{"my-topic"}, handler)
+	})
+	handler.waitClaim(t)
+	cancel()
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+
+	var joined bool
+	for _, rr := range broker.History() {
+		switch rr.Request.(type) {
+		case *JoinGroupRequest:
+			joined = true
+		case *ConsumerGroupHeartbeatRequest:
+			t.Error("Expected no ConsumerGroupHeartbeat request to a coordinator not supporting it")
+		}
+	}
+	if !joined {
+		t.Error("Expected to join the group with the classic protocol")
+	}
+}
+
+func TestConsumerGroupConsumerProtocolResetMember(t *testing.T) {
+	for _, kerr := range []KError{ErrFencedMemberEpoch, ErrUnknownMemberId} {
+		t.Run(kerr.Error(), func(t *testing.T) {
+			broker := NewMockBroker(t, 0)
+			defer broker.Close()
+			var fence int32
+			broker.SetHandlerByMap(newConsumerProtocolTestHandlers(t, broker, func(*ConsumerGroupHeartbeatRequest) KError {
+				if atomic.CompareAndSwapInt32(&fence, 1, 0) {
+					return kerr
+				}
+				return ErrNoError
+			}))
+
+			group, err := NewConsumerGroup([]string{broker.Addr()}, "my-group", newConsumerProtocolTestConfig())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer safeClose(t, group)
+
+			handler := newTestConsumerGroupHandler()
+			done := consumeInBackground(func() error {
+				return group.Consume(context.Background(), []string{"my-topic"}, handler)
+			})
+			handler.waitClaim(t)
+			atomic.StoreInt32(&fence, 1)
+			if err := waitConsumed(t, done); err != nil {
+				t.Fatal(err)
+			}
+			if calls := handler.Calls(); !reflect.DeepEqual(calls, []string{"assigned", "lost"}) {
+				t.Errorf("Expected the partitions to be lost, got %v", calls)
+			}
+
+			// the member joins again from scratch
+			ctx, cancel := context.WithCancel(context.Background())
+			done = consumeInBackground(func() error {
+				return group.Consume(ctx, []string{"my-topic"}, handler)
+			})
+			handler.waitClaim(t)
+			cancel()
+			if err := waitConsumed(t, done); err != nil {
+				t.Fatal(err)
+			}
+
+			var joins []*ConsumerGroupHeartbeatRequest
+			for _, rr := range broker.History() {
+				if req, ok := rr.Request.(*ConsumerGroupHeartbeatRequest); ok && req.MemberEpoch == 0 {
+					joins = append(joins, req)
+				}
+			}
+			if len(joins) != 2 || joins[1].MemberId != "" || !reflect.DeepEqual(joins[1].SubscribedTopicNames, []string{"my-topic"}) {
+				t.Errorf("Expected to join the group again as a new member, got %+v", joins)
+			}
+		})
+	}
+}
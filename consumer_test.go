@@ -1004,6 +1004,7 @@ func TestConsumeMessagesTrackLeader(t *testing.T) {
 
 	safeClose(t, pConsumer)
 	safeClose(t, consumer)
+	safeClose(t, client)
 	leader1.Close()
 	leader2.Close()
 }
@@ -1637,6 +1638,77 @@ func TestExcludeUncommitted(t *testing.T) {
 	broker0.Close()
 }
 
+func TestConsumerTopicOverrides(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()).
+			SetLeader("other_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetVersion(1).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 1).
+			SetOffset("other_topic", 0, OffsetOldest, 0).
+			SetOffset("other_topic", 0, OffsetNewest, 1),
+		"FetchRequest": NewMockFetchResponse(t, 1).
+			SetVersion(4).
+			SetMessage("my_topic", 0, 0, testMsg).
+			SetMessage("other_topic", 0, 0, testMsg),
+	})
+
+	isolation := ReadCommitted
+	overrides := ConsumerTopicOverrides{IsolationLevel: &isolation}
+	overrides.Fetch.Min = 1024
+	overrides.Fetch.Default = 4096
+	cfg := NewTestConfig()
+	cfg.Version = V0_11_0_0
+	cfg.Consumer.TopicOverrides = map[string]ConsumerTopicOverrides{"my_topic": overrides}
+
+	// When
+	master, err := NewConsumer([]string{broker0.Addr()}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, topic := range []string{"my_topic", "other_topic"} {
+		consumer, err := master.ConsumePartition(topic, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertMessageOffset(t, <-consumer.Messages(), 0)
+		safeClose(t, consumer)
+	}
+	safeClose(t, master)
+	broker0.Close()
+
+	// Then: the partitions of my_topic are fetched with its overrides
+	var fetches int
+	for _, rr := range broker0.History() {
+		req, ok := rr.Request.(*FetchRequest)
+		if !ok {
+			continue
+		}
+		fetches++
+		if len(req.blocks) != 1 {
+			t.Fatal("Expected the topics to be fetched apart, got", len(req.blocks))
+		}
+		minBytes, maxBytes, isolation := int32(1), cfg.Consumer.Fetch.Default, ReadUncommitted
+		if req.blocks["my_topic"] != nil {
+			minBytes, maxBytes, isolation = 1024, 4096, ReadCommitted
+		}
+		for _, blocks := range req.blocks {
+			if req.MinBytes != minBytes || blocks[0].maxBytes != maxBytes || req.Isolation != isolation {
+				t.Errorf("Expected MinBytes %d, MaxBytes %d and Isolation %d, got %d, %d and %d",
+					minBytes, maxBytes, isolation, req.MinBytes, blocks[0].maxBytes, req.Isolation)
+			}
+		}
+	}
+	if fetches < 2 {
+		t.Error("Expected at least 2 fetch requests, got", fetches)
+	}
+}
+
 func assertMessageOffset(t *testing.T, msg *ConsumerMessage, expectedOffset int64) {
 	t.Helper()
 	if msg.Offset != expectedOffset {
@@ -1842,3 +1914,313 @@ func TestConsumerInterceptors(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) { testConsumerInterceptor(t, tt.interceptors, tt.expectationFn) })
 	}
 }
+
+func TestConsumerPauseResume(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+
+	mockFetchResponse := NewMockFetchResponse(t, 1)
+	for i := 0; i < 100; i++ {
+		mockFetchResponse.SetMessage("my_topic", 0, int64(i), testMsg)
+	}
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 100),
+		"FetchRequest": mockFetchResponse,
+	})
+
+	master, err := NewConsumer([]string{broker0.Addr()}, NewTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := master.ConsumePartition("my_topic", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMessageOffset(t, <-consumer.Messages(), 0)
+
+	// When
+	master.Pause(map[string][]int32{"my_topic": {0}})
+	if !consumer.IsPaused() {
+		t.Fatal("Expected the partition consumer to be paused")
+	}
+
+	// Then: fetch requests no longer include the partition
+	pausedFetch := func() bool {
+		for _, rr := range broker0.History() {
+			if req, ok := rr.Request.(*FetchRequest); ok && len(req.blocks) == 0 {
+				return true
+			}
+		}
+		return false
+	}
+	for i := 0; !pausedFetch(); i++ {
+		if i == 100 {
+			t.Fatal("Expected a fetch request without the paused partition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	lastOffset := int64(0)
+drain:
+	for {
+		select {
+		case msg := <-consumer.Messages():
+			lastOffset = msg.Offset
+		case <-time.After(50 * time.Millisecond):
+			break drain
+		}
+	}
+
+	// When
+	master.ResumeAll()
+	if consumer.IsPaused() {
+		t.Fatal("Expected the partition consumer to be resumed")
+	}
+
+	// Then: consuming continues where it stopped
+	assertMessageOffset(t, <-consumer.Messages(), lastOffset+1)
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+}
+
+func TestConsumerMessageHeaders(t *testing.T) {
+	headers := []*RecordHeader{
+		{Key: []byte("trace"), Value: []byte("a")},
+		{Key: []byte("origin"), Value: []byte("b")},
+		{Key: []byte("trace"), Value: []byte("c")},
+	}
+	fetchResponse := &FetchResponse{Version: 4}
+	fetchResponse.AddRecord("my_topic", 0, nil, testMsg, 1)
+	fetchResponse.SetLastOffsetDelta("my_topic", 0, 1)
+	fetchResponse.GetBlock("my_topic", 0).RecordsSet[0].RecordBatch.Records[0].Headers = headers
+
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetVersion(1).
+			SetOffset("my_topic", 0, OffsetNewest, 1234).
+			SetOffset("my_topic", 0, OffsetOldest, 0),
+		"FetchRequest": NewMockWrapper(fetchResponse),
+	})
+
+	config := NewTestConfig()
+	config.Version = V0_11_0_0
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, master)
+	consumer, err := master.ConsumePartition("my_topic", 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, consumer)
+
+	msg := <-consumer.Messages()
+	if !reflect.DeepEqual(msg.Headers, headers) {
+		t.Errorf("Expected the headers in the order of the record, got %v", msg.Headers)
+	}
+	if value, ok := msg.Header("trace"); !ok || string(value) != "c" {
+		t.Errorf("Expected the value of the last trace header, got %q", value)
+	}
+	if _, ok := msg.Header("missing"); ok {
+		t.Error("Expected no missing header")
+	}
+	if values := msg.HeaderValues("trace"); len(values) != 2 || string(values[0]) != "a" || string(values[1]) != "c" {
+		t.Errorf("Expected the values of the trace headers in order, got %q", values)
+	}
+	var keys []string
+	msg.RangeHeaders(func(key, value []byte) bool {
+		keys = append(keys, string(key))
+		return len(keys) < 2
+	})
+	if !reflect.DeepEqual(keys, []string{"trace", "origin"}) {
+		t.Errorf("Expected to range over the headers until stopped, got %v", keys)
+	}
+}
+
+func TestConsumerPooledBuffers(t *testing.T) {
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 2345),
+		"FetchRequest": NewMockFetchResponse(t, 2).
+			SetMessage("my_topic", 0, 1234, StringEncoder("foo")).
+			SetMessage("my_topic", 0, 1235, StringEncoder("bar")),
+	})
+
+	config := NewTestConfig()
+	config.Consumer.Fetch.PooledBuffers = true
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, master)
+	consumer, err := master.ConsumePartition("my_topic", 0, 1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, consumer)
+
+	var msgs []*ConsumerMessage
+	for _, value := range []string{"foo", "bar"} {
+		msg := <-consumer.Messages()
+		if string(msg.Value) != value {
+			t.Errorf("Expected the value %s, got %s", value, msg.Value)
+		}
+		msgs = append(msgs, msg)
+	}
+	buffer := msgs[0].buffer
+	if buffer == nil || msgs[1].buffer != buffer {
+		t.Fatal("Expected the messages to reference the pooled buffer of their response")
+	}
+
+	// the reference of the response is released once it is processed
+	for i := 0; atomic.LoadInt32(&buffer.refs) != 2; i++ {
+		if i == 100 {
+			t.Fatalf("Expected the buffer to be referenced by the messages only, got %d references", atomic.LoadInt32(&buffer.refs))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	msgs[0].Release()
+	msgs[0].Release()
+	if refs := atomic.LoadInt32(&buffer.refs); refs != 1 {
+		t.Errorf("Expected a reference left after releasing a message twice, got %d", refs)
+	}
+	// the buffer may be reused by the next fetch once the last message is released
+	msgs[1].Release()
+	if msgs[1].buffer != nil {
+		t.Error("Expected the released message not to reference the buffer anymore")
+	}
+}
+
+func TestConsumerFetchTopicIDs(t *testing.T) {
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	topicID := Uuid{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()).
+			SetTopicID("my_topic", topicID),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetVersion(1).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 2345),
+		"FetchRequest": NewMockFetchResponse(t, 1).
+			SetVersion(13).
+			SetTopicID("my_topic", topicID).
+			SetMessage("my_topic", 0, 1234, testMsg).
+			SetHighWaterMark("my_topic", 0, 2345),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_1_0_0
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, master)
+	consumer, err := master.ConsumePartition("my_topic", 0, 1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, consumer)
+
+	select {
+	case message := <-consumer.Messages():
+		assertMessageOffset(t, message, 1234)
+		if message.Topic != "my_topic" {
+			t.Errorf("Expected a message of my_topic, got %s", message.Topic)
+		}
+	case err := <-consumer.Errors():
+		t.Fatal(err)
+	}
+
+	for _, entry := range broker0.History() {
+		if request, ok := entry.Request.(*FetchRequest); ok {
+			if request.Version != 13 || request.TopicIDs[topicID.String()] != topicID {
+				t.Errorf("Expected a fetch request of version 13 by topic ID, got %d %v", request.Version, request.TopicIDs)
+			}
+		}
+	}
+}
+
+func TestConsumerFetchMetrics(t *testing.T) {
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	mockFetchResponse := NewMockFetchResponse(t, 10).SetHighWaterMark("my_topic", 0, 2345)
+	for i := 0; i < 10; i++ {
+		mockFetchResponse.SetMessage("my_topic", 0, int64(i+1234), testMsg)
+	}
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 2345),
+		"FetchRequest": mockFetchResponse,
+	})
+
+	config := NewTestConfig()
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumer, err := master.ConsumePartition("my_topic", 0, 1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		select {
+		case message := <-consumer.Messages():
+			assertMessageOffset(t, message, int64(i+1234))
+		case err := <-consumer.Errors():
+			t.Error(err)
+		}
+	}
+
+	// the metrics of a fetch are recorded once its records are decoded and
+	// delivered by the partition consumers
+	for i := 0; i < 100 && config.MetricRegistry.Get("consumer-records-per-request") == nil; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	metricValidators := newMetricValidators()
+	metricValidators.register(minCountHistogramValidator("consumer-fetch-latency-in-ms", 1))
+	metricValidators.registerForGlobalAndTopic("my_topic", minCountHistogramValidator("consumer-fetch-size", 1))
+	metricValidators.registerForGlobalAndTopic("my_topic", maxValHistogramValidator("consumer-records-per-request", 10))
+	metricValidators.registerForGlobalAndTopic("my_topic", countMeterValidator("consumer-records-consumed-rate", 10))
+	metricValidators.registerForGlobalAndTopic("my_topic", minCountMeterValidator("consumer-bytes-consumed-rate", 1))
+	metricValidators.register(minMaxHistogramValidator("consumer-records-lag", 2345-1244, 2345-1244))
+	metricValidators.register(minMaxHistogramValidator("consumer-records-lag-for-topic-my_topic-for-partition-0", 2345-1244, 2345-1244))
+	metricValidators.run(t, config.MetricRegistry)
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	if config.MetricRegistry.Get("consumer-records-lag-for-topic-my_topic-for-partition-0") != nil {
+		t.Error("Expected the lag of the partition to be unregistered once closed")
+	}
+}
@@ -0,0 +1,65 @@
+package sarama
+
+import "time"
+
+// CreateDelegationTokenRequest creates a delegation token for the
+// authenticated principal.
+type CreateDelegationTokenRequest struct {
+	Version int16
+	// Renewers are the principals allowed to renew the token besides its owner
+	Renewers []KafkaPrincipal
+	// MaxLifetime is the maximum lifetime of the token, -1 to use the maximum
+	// lifetime configured on the brokers
+	MaxLifetime time.Duration
+}
+
+func (r *CreateDelegationTokenRequest) encode(pe packetEncoder) error {
+	if err := encodeKafkaPrincipals(pe, r.Renewers); err != nil {
+		return err
+	}
+
+	maxLifetime := int64(-1)
+	if r.MaxLifetime >= 0 {
+		maxLifetime = int64(r.MaxLifetime / time.Millisecond)
+	}
+	pe.putInt64(maxLifetime)
+	return nil
+}
+
+func (r *CreateDelegationTokenRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.Renewers, err = decodeKafkaPrincipals(pd); err != nil {
+		return err
+	}
+
+	maxLifetime, err := pd.getInt64()
+	if err != nil {
+		return err
+	}
+	r.MaxLifetime = -1
+	if maxLifetime >= 0 {
+		r.MaxLifetime = time.Duration(maxLifetime) * time.Millisecond
+	}
+	return nil
+}
+
+func (r *CreateDelegationTokenRequest) key() int16 {
+	return 38
+}
+
+func (r *CreateDelegationTokenRequest) version() int16 {
+	return r.Version
+}
+
+func (r *CreateDelegationTokenRequest) headerVersion() int16 {
+	return 1
+}
+
+func (r *CreateDelegationTokenRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V2_0_0_0
+	default:
+		return V1_1_0_0
+	}
+}
@@ -0,0 +1,32 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	createDelegationTokenRequestNoRenewers = []byte{
+		0, 0, 0, 0, // no renewers
+		255, 255, 255, 255, 255, 255, 255, 255, // max lifetime -1
+	}
+
+	createDelegationTokenRequestOneRenewer = []byte{
+		0, 0, 0, 1, // 1 renewer
+		0, 4, 'U', 's', 'e', 'r',
+		0, 5, 'a', 'l', 'i', 'c', 'e',
+		0, 0, 0, 0, 0, 0, 0x0e, 0x10, // max lifetime 3600ms
+	}
+)
+
+func TestCreateDelegationTokenRequest(t *testing.T) {
+	request := &CreateDelegationTokenRequest{MaxLifetime: -1}
+	testRequest(t, "no renewers", request, createDelegationTokenRequestNoRenewers)
+
+	request = &CreateDelegationTokenRequest{
+		Version:     1,
+		Renewers:    []KafkaPrincipal{{Type: "User", Name: "alice"}},
+		MaxLifetime: 3600 * time.Millisecond,
+	}
+	testRequest(t, "one renewer", request, createDelegationTokenRequestOneRenewer)
+}
@@ -0,0 +1,32 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var createDelegationTokenResponse = []byte{
+	0, 0, // no error
+	0, 4, 'U', 's', 'e', 'r',
+	0, 3, 'b', 'o', 'b',
+	0, 0, 0, 0, 0, 0, 0x03, 0xe8, // issue timestamp 1000
+	0, 0, 0, 0, 0, 0, 0x07, 0xd0, // expiry timestamp 2000
+	0, 0, 0, 0, 0, 0, 0x0b, 0xb8, // max timestamp 3000
+	0, 7, 't', 'o', 'k', 'e', 'n', '-', '0',
+	0, 0, 0, 4, 'h', 'm', 'a', 'c',
+	0, 0, 0, 100, // throttle time
+}
+
+func TestCreateDelegationTokenResponse(t *testing.T) {
+	response := &CreateDelegationTokenResponse{
+		Version:      1,
+		Owner:        KafkaPrincipal{Type: "User", Name: "bob"},
+		IssueTime:    time.Unix(1, 0),
+		ExpiryTime:   time.Unix(2, 0),
+		MaxTime:      time.Unix(3, 0),
+		TokenID:      "token-0",
+		HMAC:         []byte("hmac"),
+		ThrottleTime: 100 * time.Millisecond,
+	}
+	testResponse(t, "token", response, createDelegationTokenResponse)
+}
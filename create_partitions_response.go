@@ -39,6 +39,9 @@ func (c *CreatePartitionsResponse) decode(pd packetDecoder, version int16) (err
 	if err != nil {
 		return err
 	}
+	if n == -1 {
+		n = 0
+	}
 
 	c.TopicPartitionErrors = make(map[string]*TopicPartitionError, n)
 	for i := 0; i < n; i++ {
@@ -71,6 +74,10 @@ func (r *CreatePartitionsResponse) requiredVersion() KafkaVersion {
 	return V1_0_0_0
 }
 
+func (r *CreatePartitionsResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
+
 type TopicPartitionError struct {
 	Err    KError
 	ErrMsg *string
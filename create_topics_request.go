@@ -39,6 +39,9 @@ func (c *CreateTopicsRequest) decode(pd packetDecoder, version int16) (err error
 	if err != nil {
 		return err
 	}
+	if n == -1 {
+		n = 0
+	}
 
 	c.TopicDetails = make(map[string]*TopicDetail, n)
 
@@ -46,6 +46,9 @@ func (c *CreateTopicsResponse) decode(pd packetDecoder, version int16) (err erro
 	if err != nil {
 		return err
 	}
+	if n == -1 {
+		n = 0
+	}
 
 	c.TopicErrors = make(map[string]*TopicError, n)
 	for i := 0; i < n; i++ {
@@ -85,6 +88,10 @@ func (c *CreateTopicsResponse) requiredVersion() KafkaVersion {
 	}
 }
 
+func (c *CreateTopicsResponse) throttleTime() time.Duration {
+	return c.ThrottleTime
+}
+
 type TopicError struct {
 	Err    KError
 	ErrMsg *string
@@ -22,6 +22,10 @@ var (
 )
 
 func decompress(cc CompressionCodec, data []byte) ([]byte, error) {
+	if c, ok := registeredCompressorOf(cc); ok {
+		return c.compressor.Decompress(data)
+	}
+
 	switch cc {
 	case CompressionNone:
 		return data, nil
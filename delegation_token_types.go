@@ -0,0 +1,69 @@
+package sarama
+
+import "time"
+
+// KafkaPrincipal is a principal, such as the owner or a renewer of a
+// delegation token. Type is "User" for the principals of the default
+// principal builder.
+type KafkaPrincipal struct {
+	Type string
+	Name string
+}
+
+func (p *KafkaPrincipal) encode(pe packetEncoder) error {
+	if err := pe.putString(p.Type); err != nil {
+		return err
+	}
+	return pe.putString(p.Name)
+}
+
+func (p *KafkaPrincipal) decode(pd packetDecoder) (err error) {
+	if p.Type, err = pd.getString(); err != nil {
+		return err
+	}
+	p.Name, err = pd.getString()
+	return err
+}
+
+func encodeKafkaPrincipals(pe packetEncoder, principals []KafkaPrincipal) error {
+	if err := pe.putArrayLength(len(principals)); err != nil {
+		return err
+	}
+	for i := range principals {
+		if err := principals[i].encode(pe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeKafkaPrincipals(pd packetDecoder) ([]KafkaPrincipal, error) {
+	n, err := pd.getArrayLength()
+	if err != nil || n <= 0 {
+		return nil, err
+	}
+	principals := make([]KafkaPrincipal, n)
+	for i := range principals {
+		if err := principals[i].decode(pd); err != nil {
+			return nil, err
+		}
+	}
+	return principals, nil
+}
+
+// DelegationToken is a delegation token, used by clients to authenticate with
+// SASL/SCRAM without the credentials of its owner.
+type DelegationToken struct {
+	Owner    KafkaPrincipal
+	Renewers []KafkaPrincipal
+	// IssueTime is the time the token was created
+	IssueTime time.Time
+	// ExpiryTime is the time the token expires unless renewed
+	ExpiryTime time.Time
+	// MaxTime is the time after which the token can no longer be renewed
+	MaxTime time.Time
+	// TokenID is the user to authenticate with
+	TokenID string
+	// HMAC is the password to authenticate with, once base64 encoded
+	HMAC []byte
+}
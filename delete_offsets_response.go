@@ -75,6 +75,9 @@ func (r *DeleteOffsetsResponse) decode(pd packetDecoder, version int16) error {
 		if err != nil {
 			return err
 		}
+		if numErrors == -1 {
+			numErrors = 0
+		}
 
 		r.Errors[name] = make(map[int32]KError, numErrors)
 
@@ -110,3 +113,7 @@ func (r *DeleteOffsetsResponse) headerVersion() int16 {
 func (r *DeleteOffsetsResponse) requiredVersion() KafkaVersion {
 	return V2_4_0_0
 }
+
+func (r *DeleteOffsetsResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
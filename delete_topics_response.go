@@ -41,6 +41,9 @@ func (d *DeleteTopicsResponse) decode(pd packetDecoder, version int16) (err erro
 	if err != nil {
 		return err
 	}
+	if n == -1 {
+		n = 0
+	}
 
 	d.TopicErrorCodes = make(map[string]KError, n)
 
@@ -80,3 +83,7 @@ func (d *DeleteTopicsResponse) requiredVersion() KafkaVersion {
 		return V0_10_1_0
 	}
 }
+
+func (d *DeleteTopicsResponse) throttleTime() time.Duration {
+	return d.ThrottleTime
+}
@@ -4,6 +4,9 @@ type DescribeConfigsRequest struct {
 	Version         int16
 	Resources       []*ConfigResource
 	IncludeSynonyms bool
+	// IncludeDocumentation asks for the documentation of each config from
+	// version 3
+	IncludeDocumentation bool
 }
 
 type ConfigResource struct {
@@ -13,12 +16,20 @@ type ConfigResource struct {
 }
 
 func (r *DescribeConfigsRequest) encode(pe packetEncoder) error {
-	if err := pe.putArrayLength(len(r.Resources)); err != nil {
+	if r.Version >= 4 {
+		pe.putCompactArrayLength(len(r.Resources))
+	} else if err := pe.putArrayLength(len(r.Resources)); err != nil {
 		return err
 	}
 
 	for _, c := range r.Resources {
 		pe.putInt8(int8(c.Type))
+		if r.Version >= 4 {
+			if err := c.encodeFlexible(pe); err != nil {
+				return err
+			}
+			continue
+		}
 		if err := pe.putString(c.Name); err != nil {
 			return err
 		}
@@ -35,15 +46,49 @@ func (r *DescribeConfigsRequest) encode(pe packetEncoder) error {
 	if r.Version >= 1 {
 		pe.putBool(r.IncludeSynonyms)
 	}
+	if r.Version >= 3 {
+		pe.putBool(r.IncludeDocumentation)
+	}
+	if r.Version >= 4 {
+		pe.putEmptyTaggedFieldArray()
+	}
 
 	return nil
 }
 
+func (c *ConfigResource) encodeFlexible(pe packetEncoder) error {
+	if err := pe.putCompactString(c.Name); err != nil {
+		return err
+	}
+
+	if len(c.ConfigNames) == 0 {
+		pe.putCompactArrayLength(-1)
+	} else {
+		pe.putCompactArrayLength(len(c.ConfigNames))
+		for _, name := range c.ConfigNames {
+			if err := pe.putCompactString(name); err != nil {
+				return err
+			}
+		}
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
 func (r *DescribeConfigsRequest) decode(pd packetDecoder, version int16) (err error) {
-	n, err := pd.getArrayLength()
+	var n int
+	if version >= 4 {
+		n, err = pd.getCompactArrayLength()
+	} else {
+		n, err = pd.getArrayLength()
+	}
 	if err != nil {
 		return err
 	}
+	if n == -1 {
+		n = 0
+	}
 
 	r.Resources = make([]*ConfigResource, n)
 
@@ -54,6 +99,12 @@ func (r *DescribeConfigsRequest) decode(pd packetDecoder, version int16) (err er
 			return err
 		}
 		r.Resources[i].Type = ConfigResourceType(t)
+		if version >= 4 {
+			if err := r.Resources[i].decodeFlexible(pd); err != nil {
+				return err
+			}
+			continue
+		}
 		name, err := pd.getString()
 		if err != nil {
 			return err
@@ -87,8 +138,39 @@ func (r *DescribeConfigsRequest) decode(pd packetDecoder, version int16) (err er
 		}
 		r.IncludeSynonyms = b
 	}
+	if r.Version >= 3 {
+		if r.IncludeDocumentation, err = pd.getBool(); err != nil {
+			return err
+		}
+	}
+	if r.Version >= 4 {
+		_, err = pd.getEmptyTaggedFieldArray()
+	}
 
-	return nil
+	return err
+}
+
+func (c *ConfigResource) decodeFlexible(pd packetDecoder) (err error) {
+	if c.Name, err = pd.getCompactString(); err != nil {
+		return err
+	}
+
+	// a null array of config names, meaning all of them, is read as empty
+	n, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		c.ConfigNames = make([]string, n)
+		for i := range c.ConfigNames {
+			if c.ConfigNames[i], err = pd.getCompactString(); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
 }
 
 func (r *DescribeConfigsRequest) key() int16 {
@@ -100,6 +182,9 @@ func (r *DescribeConfigsRequest) version() int16 {
 }
 
 func (r *DescribeConfigsRequest) headerVersion() int16 {
+	if r.Version >= 4 {
+		return 2
+	}
 	return 1
 }
 
@@ -109,6 +194,10 @@ func (r *DescribeConfigsRequest) requiredVersion() KafkaVersion {
 		return V1_1_0_0
 	case 2:
 		return V2_0_0_0
+	case 3:
+		return V2_6_0_0
+	case 4:
+		return V2_8_0_0
 	default:
 		return V0_11_0_0
 	}
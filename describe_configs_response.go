@@ -21,6 +21,12 @@ func (s ConfigSource) String() string {
 		return "StaticBroker"
 	case SourceDefault:
 		return "Default"
+	case SourceDynamicBrokerLogger:
+		return "DynamicBrokerLogger"
+	case SourceClientMetrics:
+		return "ClientMetrics"
+	case SourceGroup:
+		return "Group"
 	}
 	return fmt.Sprintf("Source Invalid: %d", int(s))
 }
@@ -32,6 +38,52 @@ const (
 	SourceDynamicDefaultBroker
 	SourceStaticBroker
 	SourceDefault
+	SourceDynamicBrokerLogger
+	SourceClientMetrics
+	SourceGroup
+)
+
+// ConfigType is the type of the value of a config, returned from version 3
+// of DescribeConfigs.
+type ConfigType int8
+
+func (t ConfigType) String() string {
+	switch t {
+	case ConfigTypeUnknown:
+		return "Unknown"
+	case ConfigTypeBoolean:
+		return "Boolean"
+	case ConfigTypeString:
+		return "String"
+	case ConfigTypeInt:
+		return "Int"
+	case ConfigTypeShort:
+		return "Short"
+	case ConfigTypeLong:
+		return "Long"
+	case ConfigTypeDouble:
+		return "Double"
+	case ConfigTypeList:
+		return "List"
+	case ConfigTypeClass:
+		return "Class"
+	case ConfigTypePassword:
+		return "Password"
+	}
+	return fmt.Sprintf("Type Invalid: %d", int(t))
+}
+
+const (
+	ConfigTypeUnknown ConfigType = iota
+	ConfigTypeBoolean
+	ConfigTypeString
+	ConfigTypeInt
+	ConfigTypeShort
+	ConfigTypeLong
+	ConfigTypeDouble
+	ConfigTypeList
+	ConfigTypeClass
+	ConfigTypePassword
 )
 
 type DescribeConfigsResponse struct {
@@ -56,6 +108,10 @@ type ConfigEntry struct {
 	Source    ConfigSource
 	Sensitive bool
 	Synonyms  []*ConfigSynonym
+	// Type and Documentation are returned from version 3, Documentation only
+	// when requested with IncludeDocumentation
+	Type          ConfigType
+	Documentation string
 }
 
 type ConfigSynonym struct {
@@ -66,7 +122,9 @@ type ConfigSynonym struct {
 
 func (r *DescribeConfigsResponse) encode(pe packetEncoder) (err error) {
 	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
-	if err = pe.putArrayLength(len(r.Resources)); err != nil {
+	if r.Version >= 4 {
+		pe.putCompactArrayLength(len(r.Resources))
+	} else if err = pe.putArrayLength(len(r.Resources)); err != nil {
 		return err
 	}
 
@@ -76,6 +134,9 @@ func (r *DescribeConfigsResponse) encode(pe packetEncoder) (err error) {
 		}
 	}
 
+	if r.Version >= 4 {
+		pe.putEmptyTaggedFieldArray()
+	}
 	return nil
 }
 
@@ -87,7 +148,12 @@ func (r *DescribeConfigsResponse) decode(pd packetDecoder, version int16) (err e
 	}
 	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
 
-	n, err := pd.getArrayLength()
+	var n int
+	if version >= 4 {
+		n, err = pd.getCompactArrayLength()
+	} else {
+		n, err = pd.getArrayLength()
+	}
 	if err != nil {
 		return err
 	}
@@ -101,7 +167,10 @@ func (r *DescribeConfigsResponse) decode(pd packetDecoder, version int16) (err e
 		r.Resources[i] = rr
 	}
 
-	return nil
+	if version >= 4 {
+		_, err = pd.getEmptyTaggedFieldArray()
+	}
+	return err
 }
 
 func (r *DescribeConfigsResponse) key() int16 {
@@ -113,6 +182,9 @@ func (r *DescribeConfigsResponse) version() int16 {
 }
 
 func (r *DescribeConfigsResponse) headerVersion() int16 {
+	if r.Version >= 4 {
+		return 1
+	}
 	return 0
 }
 
@@ -122,25 +194,49 @@ func (r *DescribeConfigsResponse) requiredVersion() KafkaVersion {
 		return V1_0_0_0
 	case 2:
 		return V2_0_0_0
+	case 3:
+		return V2_6_0_0
+	case 4:
+		return V2_8_0_0
 	default:
 		return V0_11_0_0
 	}
 }
 
+func (r *DescribeConfigsResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
+
 func (r *ResourceResponse) encode(pe packetEncoder, version int16) (err error) {
 	pe.putInt16(r.ErrorCode)
 
-	if err = pe.putString(r.ErrorMsg); err != nil {
+	if version >= 4 {
+		var errorMsg *string
+		if r.ErrorMsg != "" {
+			errorMsg = &r.ErrorMsg
+		}
+		err = pe.putNullableCompactString(errorMsg)
+	} else {
+		err = pe.putString(r.ErrorMsg)
+	}
+	if err != nil {
 		return err
 	}
 
 	pe.putInt8(int8(r.Type))
 
-	if err = pe.putString(r.Name); err != nil {
+	if version >= 4 {
+		err = pe.putCompactString(r.Name)
+	} else {
+		err = pe.putString(r.Name)
+	}
+	if err != nil {
 		return err
 	}
 
-	if err = pe.putArrayLength(len(r.Configs)); err != nil {
+	if version >= 4 {
+		pe.putCompactArrayLength(len(r.Configs))
+	} else if err = pe.putArrayLength(len(r.Configs)); err != nil {
 		return err
 	}
 
@@ -149,6 +245,10 @@ func (r *ResourceResponse) encode(pe packetEncoder, version int16) (err error) {
 			return err
 		}
 	}
+
+	if version >= 4 {
+		pe.putEmptyTaggedFieldArray()
+	}
 	return nil
 }
 
@@ -159,11 +259,21 @@ func (r *ResourceResponse) decode(pd packetDecoder, version int16) (err error) {
 	}
 	r.ErrorCode = ec
 
-	em, err := pd.getString()
-	if err != nil {
-		return err
+	if version >= 4 {
+		em, err := pd.getCompactNullableString()
+		if err != nil {
+			return err
+		}
+		if em != nil {
+			r.ErrorMsg = *em
+		}
+	} else {
+		em, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		r.ErrorMsg = em
 	}
-	r.ErrorMsg = em
 
 	t, err := pd.getInt8()
 	if err != nil {
@@ -171,13 +281,21 @@ func (r *ResourceResponse) decode(pd packetDecoder, version int16) (err error) {
 	}
 	r.Type = ConfigResourceType(t)
 
-	name, err := pd.getString()
+	if version >= 4 {
+		r.Name, err = pd.getCompactString()
+	} else {
+		r.Name, err = pd.getString()
+	}
 	if err != nil {
 		return err
 	}
-	r.Name = name
 
-	n, err := pd.getArrayLength()
+	var n int
+	if version >= 4 {
+		n, err = pd.getCompactArrayLength()
+	} else {
+		n, err = pd.getArrayLength()
+	}
 	if err != nil {
 		return err
 	}
@@ -190,16 +308,28 @@ func (r *ResourceResponse) decode(pd packetDecoder, version int16) (err error) {
 		}
 		r.Configs[i] = c
 	}
-	return nil
-}
 
-func (r *ConfigEntry) encode(pe packetEncoder, version int16) (err error) {
-	if err = pe.putString(r.Name); err != nil {
-		return err
+	if version >= 4 {
+		_, err = pd.getEmptyTaggedFieldArray()
 	}
+	return err
+}
 
-	if err = pe.putString(r.Value); err != nil {
-		return err
+func (r *ConfigEntry) encode(pe packetEncoder, version int16) (err error) {
+	if version >= 4 {
+		if err = pe.putCompactString(r.Name); err != nil {
+			return err
+		}
+		if err = pe.putNullableCompactString(&r.Value); err != nil {
+			return err
+		}
+	} else {
+		if err = pe.putString(r.Name); err != nil {
+			return err
+		}
+		if err = pe.putString(r.Value); err != nil {
+			return err
+		}
 	}
 
 	pe.putBool(r.ReadOnly)
@@ -207,20 +337,43 @@ func (r *ConfigEntry) encode(pe packetEncoder, version int16) (err error) {
 	if version <= 0 {
 		pe.putBool(r.Default)
 		pe.putBool(r.Sensitive)
-	} else {
-		pe.putInt8(int8(r.Source))
-		pe.putBool(r.Sensitive)
+		return nil
+	}
 
-		if err := pe.putArrayLength(len(r.Synonyms)); err != nil {
+	pe.putInt8(int8(r.Source))
+	pe.putBool(r.Sensitive)
+
+	if version >= 4 {
+		pe.putCompactArrayLength(len(r.Synonyms))
+	} else if err := pe.putArrayLength(len(r.Synonyms)); err != nil {
+		return err
+	}
+	for _, c := range r.Synonyms {
+		if err = c.encode(pe, version); err != nil {
 			return err
 		}
-		for _, c := range r.Synonyms {
-			if err = c.encode(pe, version); err != nil {
-				return err
-			}
+	}
+
+	if version >= 3 {
+		pe.putInt8(int8(r.Type))
+
+		var documentation *string
+		if r.Documentation != "" {
+			documentation = &r.Documentation
+		}
+		if version >= 4 {
+			err = pe.putNullableCompactString(documentation)
+		} else {
+			err = pe.putNullableString(documentation)
+		}
+		if err != nil {
+			return err
 		}
 	}
 
+	if version >= 4 {
+		pe.putEmptyTaggedFieldArray()
+	}
 	return nil
 }
 
@@ -229,17 +382,26 @@ func (r *ConfigEntry) decode(pd packetDecoder, version int16) (err error) {
 	if version == 0 {
 		r.Source = SourceUnknown
 	}
-	name, err := pd.getString()
-	if err != nil {
-		return err
-	}
-	r.Name = name
-
-	value, err := pd.getString()
-	if err != nil {
-		return err
+	if version >= 4 {
+		if r.Name, err = pd.getCompactString(); err != nil {
+			return err
+		}
+		// the values of sensitive configs are null
+		value, err := pd.getCompactNullableString()
+		if err != nil {
+			return err
+		}
+		if value != nil {
+			r.Value = *value
+		}
+	} else {
+		if r.Name, err = pd.getString(); err != nil {
+			return err
+		}
+		if r.Value, err = pd.getString(); err != nil {
+			return err
+		}
 	}
-	r.Value = value
 
 	read, err := pd.getBool()
 	if err != nil {
@@ -271,57 +433,110 @@ func (r *ConfigEntry) decode(pd packetDecoder, version int16) (err error) {
 	}
 	r.Sensitive = sensitive
 
-	if version > 0 {
-		n, err := pd.getArrayLength()
+	if version == 0 {
+		return nil
+	}
+
+	var n int
+	if version >= 4 {
+		n, err = pd.getCompactArrayLength()
+	} else {
+		n, err = pd.getArrayLength()
+	}
+	if err != nil {
+		return err
+	}
+	r.Synonyms = make([]*ConfigSynonym, n)
+
+	for i := 0; i < n; i++ {
+		s := &ConfigSynonym{}
+		if err := s.decode(pd, version); err != nil {
+			return err
+		}
+		r.Synonyms[i] = s
+	}
+
+	if version >= 3 {
+		t, err := pd.getInt8()
 		if err != nil {
 			return err
 		}
-		r.Synonyms = make([]*ConfigSynonym, n)
+		r.Type = ConfigType(t)
 
-		for i := 0; i < n; i++ {
-			s := &ConfigSynonym{}
-			if err := s.decode(pd, version); err != nil {
-				return err
-			}
-			r.Synonyms[i] = s
+		var documentation *string
+		if version >= 4 {
+			documentation, err = pd.getCompactNullableString()
+		} else {
+			documentation, err = pd.getNullableString()
+		}
+		if err != nil {
+			return err
+		}
+		if documentation != nil {
+			r.Documentation = *documentation
 		}
 	}
-	return nil
-}
 
-func (c *ConfigSynonym) encode(pe packetEncoder, version int16) (err error) {
-	err = pe.putString(c.ConfigName)
-	if err != nil {
-		return err
+	if version >= 4 {
+		_, err = pd.getEmptyTaggedFieldArray()
 	}
+	return err
+}
 
-	err = pe.putString(c.ConfigValue)
-	if err != nil {
-		return err
+func (c *ConfigSynonym) encode(pe packetEncoder, version int16) (err error) {
+	if version >= 4 {
+		if err = pe.putCompactString(c.ConfigName); err != nil {
+			return err
+		}
+		if err = pe.putNullableCompactString(&c.ConfigValue); err != nil {
+			return err
+		}
+	} else {
+		if err = pe.putString(c.ConfigName); err != nil {
+			return err
+		}
+		if err = pe.putString(c.ConfigValue); err != nil {
+			return err
+		}
 	}
 
 	pe.putInt8(int8(c.Source))
 
+	if version >= 4 {
+		pe.putEmptyTaggedFieldArray()
+	}
 	return nil
 }
 
-func (c *ConfigSynonym) decode(pd packetDecoder, version int16) error {
-	name, err := pd.getString()
-	if err != nil {
-		return err
-	}
-	c.ConfigName = name
-
-	value, err := pd.getString()
-	if err != nil {
-		return err
+func (c *ConfigSynonym) decode(pd packetDecoder, version int16) (err error) {
+	if version >= 4 {
+		if c.ConfigName, err = pd.getCompactString(); err != nil {
+			return err
+		}
+		value, err := pd.getCompactNullableString()
+		if err != nil {
+			return err
+		}
+		if value != nil {
+			c.ConfigValue = *value
+		}
+	} else {
+		if c.ConfigName, err = pd.getString(); err != nil {
+			return err
+		}
+		if c.ConfigValue, err = pd.getString(); err != nil {
+			return err
+		}
 	}
-	c.ConfigValue = value
 
 	source, err := pd.getInt8()
 	if err != nil {
 		return err
 	}
 	c.Source = ConfigSource(source)
-	return nil
+
+	if version >= 4 {
+		_, err = pd.getEmptyTaggedFieldArray()
+	}
+	return err
 }
@@ -90,6 +90,53 @@ var (
 		0,          // Sensitive
 		0, 0, 0, 0, // No Synonym
 	}
+
+	describeConfigsResponseWithTypev3 = []byte{
+		0, 0, 0, 0, // throttle
+		0, 0, 0, 1, // response
+		0, 0, // errorcode
+		0, 0, // string
+		2, // topic
+		0, 3, 'f', 'o', 'o',
+		0, 0, 0, 1, // configs
+		0, 10, 's', 'e', 'g', 'm', 'e', 'n', 't', '.', 'm', 's',
+		0, 4, '1', '0', '0', '0',
+		0,          // ReadOnly
+		1,          // Source
+		0,          // Sensitive
+		0, 0, 0, 1, // 1 Synonym
+		0, 14, 'l', 'o', 'g', '.', 's', 'e', 'g', 'm', 'e', 'n', 't', '.', 'm', 's',
+		0, 4, '5', '0', '0', '0',
+		5,    // Source
+		5,    // Type
+		0, 4, // Documentation
+		'r', 'o', 'l', 'l',
+	}
+
+	describeConfigsResponseWithTypev4 = []byte{
+		0, 0, 0, 0, // throttle
+		2,    // response
+		0, 0, // errorcode
+		0, // null error message
+		2, // topic
+		4, 'f', 'o', 'o',
+		2, // configs
+		11, 's', 'e', 'g', 'm', 'e', 'n', 't', '.', 'm', 's',
+		5, '1', '0', '0', '0',
+		0, // ReadOnly
+		1, // Source
+		0, // Sensitive
+		2, // 1 Synonym
+		15, 'l', 'o', 'g', '.', 's', 'e', 'g', 'm', 'e', 'n', 't', '.', 'm', 's',
+		5, '5', '0', '0', '0',
+		5, // Source
+		0, // empty tagged fields
+		5, // Type
+		0, // null Documentation
+		0, // empty tagged fields
+		0, // empty tagged fields
+		0, // empty tagged fields
+	}
 )
 
 func TestDescribeConfigsResponsev0(t *testing.T) {
@@ -273,3 +320,60 @@ func TestDescribeConfigsResponseWithDefaultv1(t *testing.T) {
 	}
 	testResponse(t, "response with error", response, describeConfigsResponseWithDefaultv1)
 }
+
+func TestDescribeConfigsResponseWithTypev3(t *testing.T) {
+	response := &DescribeConfigsResponse{
+		Version: 3,
+		Resources: []*ResourceResponse{
+			{
+				Type: TopicResource,
+				Name: "foo",
+				Configs: []*ConfigEntry{
+					{
+						Name:   "segment.ms",
+						Value:  "1000",
+						Source: SourceTopic,
+						Synonyms: []*ConfigSynonym{
+							{
+								ConfigName:  "log.segment.ms",
+								ConfigValue: "5000",
+								Source:      SourceDefault,
+							},
+						},
+						Type:          ConfigTypeLong,
+						Documentation: "roll",
+					},
+				},
+			},
+		},
+	}
+	testResponse(t, "response with type and documentation", response, describeConfigsResponseWithTypev3)
+}
+
+func TestDescribeConfigsResponseWithTypev4(t *testing.T) {
+	response := &DescribeConfigsResponse{
+		Version: 4,
+		Resources: []*ResourceResponse{
+			{
+				Type: TopicResource,
+				Name: "foo",
+				Configs: []*ConfigEntry{
+					{
+						Name:   "segment.ms",
+						Value:  "1000",
+						Source: SourceTopic,
+						Synonyms: []*ConfigSynonym{
+							{
+								ConfigName:  "log.segment.ms",
+								ConfigValue: "5000",
+								Source:      SourceDefault,
+							},
+						},
+						Type: ConfigTypeLong,
+					},
+				},
+			},
+		},
+	}
+	testResponse(t, "response with type", response, describeConfigsResponseWithTypev4)
+}
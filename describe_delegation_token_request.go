@@ -0,0 +1,56 @@
+package sarama
+
+// DescribeDelegationTokenRequest describes the delegation tokens the
+// authenticated principal is allowed to describe.
+type DescribeDelegationTokenRequest struct {
+	Version int16
+	// Owners filters the tokens by owner, nil to describe all the tokens
+	Owners []KafkaPrincipal
+}
+
+func (r *DescribeDelegationTokenRequest) encode(pe packetEncoder) error {
+	if r.Owners == nil {
+		pe.putInt32(-1)
+		return nil
+	}
+	return encodeKafkaPrincipals(pe, r.Owners)
+}
+
+func (r *DescribeDelegationTokenRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Owners = nil
+	if n >= 0 {
+		r.Owners = make([]KafkaPrincipal, n)
+		for i := range r.Owners {
+			if err := r.Owners[i].decode(pd); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *DescribeDelegationTokenRequest) key() int16 {
+	return 41
+}
+
+func (r *DescribeDelegationTokenRequest) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeDelegationTokenRequest) headerVersion() int16 {
+	return 1
+}
+
+func (r *DescribeDelegationTokenRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V2_0_0_0
+	default:
+		return V1_1_0_0
+	}
+}
@@ -0,0 +1,26 @@
+package sarama
+
+import "testing"
+
+var (
+	describeDelegationTokenRequestAll = []byte{
+		255, 255, 255, 255, // null owners
+	}
+
+	describeDelegationTokenRequestOneOwner = []byte{
+		0, 0, 0, 1, // 1 owner
+		0, 4, 'U', 's', 'e', 'r',
+		0, 3, 'b', 'o', 'b',
+	}
+)
+
+func TestDescribeDelegationTokenRequest(t *testing.T) {
+	request := &DescribeDelegationTokenRequest{}
+	testRequest(t, "all tokens", request, describeDelegationTokenRequestAll)
+
+	request = &DescribeDelegationTokenRequest{
+		Version: 1,
+		Owners:  []KafkaPrincipal{{Type: "User", Name: "bob"}},
+	}
+	testRequest(t, "one owner", request, describeDelegationTokenRequestOneOwner)
+}
@@ -0,0 +1,114 @@
+package sarama
+
+import "time"
+
+// DescribeDelegationTokenResponse is the response to a
+// DescribeDelegationTokenRequest.
+type DescribeDelegationTokenResponse struct {
+	Version int16
+	Err     KError
+	Tokens  []*DelegationToken
+	// ThrottleTime is applied before sending the response from version 1
+	ThrottleTime time.Duration
+}
+
+func (r *DescribeDelegationTokenResponse) encode(pe packetEncoder) error {
+	pe.putInt16(int16(r.Err))
+
+	if err := pe.putArrayLength(len(r.Tokens)); err != nil {
+		return err
+	}
+	for _, token := range r.Tokens {
+		if err := token.Owner.encode(pe); err != nil {
+			return err
+		}
+		for _, t := range []*time.Time{&token.IssueTime, &token.ExpiryTime, &token.MaxTime} {
+			if err := (Timestamp{t}).encode(pe); err != nil {
+				return err
+			}
+		}
+		if err := pe.putString(token.TokenID); err != nil {
+			return err
+		}
+		if err := pe.putBytes(token.HMAC); err != nil {
+			return err
+		}
+		if err := encodeKafkaPrincipals(pe, token.Renewers); err != nil {
+			return err
+		}
+	}
+
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	return nil
+}
+
+func (r *DescribeDelegationTokenResponse) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		n = 0
+	}
+	r.Tokens = make([]*DelegationToken, n)
+	for i := range r.Tokens {
+		token := new(DelegationToken)
+		if err := token.Owner.decode(pd); err != nil {
+			return err
+		}
+		for _, t := range []*time.Time{&token.IssueTime, &token.ExpiryTime, &token.MaxTime} {
+			if err := (Timestamp{t}).decode(pd); err != nil {
+				return err
+			}
+		}
+		if token.TokenID, err = pd.getString(); err != nil {
+			return err
+		}
+		if token.HMAC, err = pd.getBytes(); err != nil {
+			return err
+		}
+		if token.Renewers, err = decodeKafkaPrincipals(pd); err != nil {
+			return err
+		}
+		r.Tokens[i] = token
+	}
+
+	throttleTime, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+	return nil
+}
+
+func (r *DescribeDelegationTokenResponse) key() int16 {
+	return 41
+}
+
+func (r *DescribeDelegationTokenResponse) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeDelegationTokenResponse) headerVersion() int16 {
+	return 0
+}
+
+func (r *DescribeDelegationTokenResponse) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V2_0_0_0
+	default:
+		return V1_1_0_0
+	}
+}
+
+func (r *DescribeDelegationTokenResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
@@ -40,6 +40,9 @@ func (r *DescribeLogDirsResponse) decode(pd packetDecoder, version int16) error
 	if err != nil {
 		return err
 	}
+	if n == -1 {
+		n = 0
+	}
 
 	r.LogDirs = make([]DescribeLogDirsResponseDirMetadata, n)
 	for i := 0; i < n; i++ {
@@ -69,6 +72,10 @@ func (r *DescribeLogDirsResponse) requiredVersion() KafkaVersion {
 	return V1_0_0_0
 }
 
+func (r *DescribeLogDirsResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
+
 type DescribeLogDirsResponseDirMetadata struct {
 	ErrorCode KError
 
@@ -114,6 +121,9 @@ func (r *DescribeLogDirsResponseDirMetadata) decode(pd packetDecoder, version in
 	if err != nil {
 		return err
 	}
+	if n == -1 {
+		n = 0
+	}
 
 	r.Topics = make([]DescribeLogDirsResponseTopic, n)
 	for i := 0; i < n; i++ {
@@ -163,6 +173,9 @@ func (r *DescribeLogDirsResponseTopic) decode(pd packetDecoder, version int16) e
 	if err != nil {
 		return err
 	}
+	if n == -1 {
+		n = 0
+	}
 	r.Partitions = make([]DescribeLogDirsResponsePartition, n)
 	for i := 0; i < n; i++ {
 		p := DescribeLogDirsResponsePartition{}
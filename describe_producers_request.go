@@ -0,0 +1,72 @@
+package sarama
+
+// DescribeProducersRequest is a request to describe the active producers of
+// some partitions, it must be sent to the leader of the partitions (KIP-664)
+type DescribeProducersRequest struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	Topics []DescribeProducersRequestTopic
+}
+
+// DescribeProducersRequestTopic is the set of partitions of a topic to describe
+type DescribeProducersRequestTopic struct {
+	Name             string
+	PartitionIndexes []int32
+}
+
+func (r *DescribeProducersRequest) encode(pe packetEncoder) error {
+	pe.putCompactArrayLength(len(r.Topics))
+	for _, topic := range r.Topics {
+		if err := pe.putCompactString(topic.Name); err != nil {
+			return err
+		}
+		if err := pe.putCompactInt32Array(topic.PartitionIndexes); err != nil {
+			return err
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *DescribeProducersRequest) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	n, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+
+	r.Topics = make([]DescribeProducersRequestTopic, n)
+	for i := range r.Topics {
+		if r.Topics[i].Name, err = pd.getCompactString(); err != nil {
+			return err
+		}
+		if r.Topics[i].PartitionIndexes, err = pd.getCompactInt32Array(); err != nil {
+			return err
+		}
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *DescribeProducersRequest) key() int16 {
+	return 61
+}
+
+func (r *DescribeProducersRequest) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeProducersRequest) headerVersion() int16 {
+	return 2
+}
+
+func (r *DescribeProducersRequest) requiredVersion() KafkaVersion {
+	return V2_8_0_0
+}
@@ -0,0 +1,21 @@
+package sarama
+
+import "testing"
+
+var describeProducersRequest = []byte{
+	2,                // Topics array, length 1
+	4, 'f', 'o', 'o', // Name
+	3, 0, 0, 0, 0, 0, 0, 0, 2, // PartitionIndexes [0, 2]
+	0, // empty tagged fields
+	0, // empty tagged fields
+}
+
+func TestDescribeProducersRequest(t *testing.T) {
+	request := &DescribeProducersRequest{
+		Version: 0,
+		Topics: []DescribeProducersRequestTopic{
+			{Name: "foo", PartitionIndexes: []int32{0, 2}},
+		},
+	}
+	testRequest(t, "one topic", request, describeProducersRequest)
+}
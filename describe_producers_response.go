@@ -0,0 +1,184 @@
+package sarama
+
+import "time"
+
+// DescribeProducersResponse is the response to a DescribeProducersRequest
+type DescribeProducersResponse struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	ThrottleTime time.Duration
+	Topics       []DescribeProducersResponseTopic
+}
+
+// DescribeProducersResponseTopic holds the producers of the partitions of a topic
+type DescribeProducersResponseTopic struct {
+	Name       string
+	Partitions []DescribeProducersResponsePartition
+}
+
+// DescribeProducersResponsePartition holds the active producers of a partition
+type DescribeProducersResponsePartition struct {
+	PartitionIndex  int32
+	ErrorCode       KError
+	ErrorMessage    *string
+	ActiveProducers []ProducerState
+}
+
+// ProducerState describes an idempotent or transactional producer that has
+// written to a partition.
+type ProducerState struct {
+	ProducerID    int64
+	ProducerEpoch int32
+	// LastSequence is -1 when the producer has not written any records
+	LastSequence  int32
+	LastTimestamp int64
+	// CoordinatorEpoch is -1 if the producer has never been part of a
+	// transaction
+	CoordinatorEpoch int32
+	// CurrentTxnStartOffset is the offset of the first record of the ongoing
+	// transaction of the producer, -1 if it has none
+	CurrentTxnStartOffset int64
+}
+
+func (p *ProducerState) encode(pe packetEncoder) {
+	pe.putInt64(p.ProducerID)
+	pe.putInt32(p.ProducerEpoch)
+	pe.putInt32(p.LastSequence)
+	pe.putInt64(p.LastTimestamp)
+	pe.putInt32(p.CoordinatorEpoch)
+	pe.putInt64(p.CurrentTxnStartOffset)
+	pe.putEmptyTaggedFieldArray()
+}
+
+func (p *ProducerState) decode(pd packetDecoder) (err error) {
+	if p.ProducerID, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if p.ProducerEpoch, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if p.LastSequence, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if p.LastTimestamp, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if p.CoordinatorEpoch, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if p.CurrentTxnStartOffset, err = pd.getInt64(); err != nil {
+		return err
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *DescribeProducersResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+
+	pe.putCompactArrayLength(len(r.Topics))
+	for _, topic := range r.Topics {
+		if err := pe.putCompactString(topic.Name); err != nil {
+			return err
+		}
+		pe.putCompactArrayLength(len(topic.Partitions))
+		for _, partition := range topic.Partitions {
+			pe.putInt32(partition.PartitionIndex)
+			pe.putInt16(int16(partition.ErrorCode))
+			if err := pe.putNullableCompactString(partition.ErrorMessage); err != nil {
+				return err
+			}
+			pe.putCompactArrayLength(len(partition.ActiveProducers))
+			for i := range partition.ActiveProducers {
+				partition.ActiveProducers[i].encode(pe)
+			}
+			pe.putEmptyTaggedFieldArray()
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *DescribeProducersResponse) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	throttleTime, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+
+	numTopics, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Topics = make([]DescribeProducersResponseTopic, numTopics)
+	for i := range r.Topics {
+		topic := &r.Topics[i]
+		if topic.Name, err = pd.getCompactString(); err != nil {
+			return err
+		}
+
+		numPartitions, err := pd.getCompactArrayLength()
+		if err != nil {
+			return err
+		}
+		topic.Partitions = make([]DescribeProducersResponsePartition, numPartitions)
+		for j := range topic.Partitions {
+			partition := &topic.Partitions[j]
+			if partition.PartitionIndex, err = pd.getInt32(); err != nil {
+				return err
+			}
+			kerr, err := pd.getInt16()
+			if err != nil {
+				return err
+			}
+			partition.ErrorCode = KError(kerr)
+			if partition.ErrorMessage, err = pd.getCompactNullableString(); err != nil {
+				return err
+			}
+
+			numProducers, err := pd.getCompactArrayLength()
+			if err != nil {
+				return err
+			}
+			partition.ActiveProducers = make([]ProducerState, numProducers)
+			for k := range partition.ActiveProducers {
+				if err := partition.ActiveProducers[k].decode(pd); err != nil {
+					return err
+				}
+			}
+			if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+		}
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *DescribeProducersResponse) key() int16 {
+	return 61
+}
+
+func (r *DescribeProducersResponse) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeProducersResponse) headerVersion() int16 {
+	return 1
+}
+
+func (r *DescribeProducersResponse) requiredVersion() KafkaVersion {
+	return V2_8_0_0
+}
+
+func (r *DescribeProducersResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
@@ -0,0 +1,62 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var describeProducersResponse = []byte{
+	0, 0, 0, 100, // ThrottleTime
+	2,                // Topics array, length 1
+	4, 'f', 'o', 'o', // Name
+	3,          // Partitions array, length 2
+	0, 0, 0, 0, // PartitionIndex
+	0, 0, // ErrorCode
+	0,                        // ErrorMessage
+	2,                        // ActiveProducers array, length 1
+	0, 0, 0, 0, 0, 0, 3, 232, // ProducerID
+	0, 0, 0, 5, // ProducerEpoch
+	0, 0, 0, 41, // LastSequence
+	0, 0, 1, 88, 26, 230, 72, 134, // LastTimestamp
+	0, 0, 0, 2, // CoordinatorEpoch
+	0, 0, 0, 0, 0, 0, 0, 12, // CurrentTxnStartOffset
+	0,          // empty tagged fields
+	0,          // empty tagged fields
+	0, 0, 0, 2, // PartitionIndex
+	0, 6, // ErrorCode
+	0, // ErrorMessage
+	1, // ActiveProducers array, empty
+	0, // empty tagged fields
+	0, // empty tagged fields
+	0, // empty tagged fields
+}
+
+func TestDescribeProducersResponse(t *testing.T) {
+	response := &DescribeProducersResponse{
+		Version:      0,
+		ThrottleTime: 100 * time.Millisecond,
+		Topics: []DescribeProducersResponseTopic{{
+			Name: "foo",
+			Partitions: []DescribeProducersResponsePartition{
+				{
+					PartitionIndex: 0,
+					ErrorCode:      ErrNoError,
+					ActiveProducers: []ProducerState{{
+						ProducerID:            1000,
+						ProducerEpoch:         5,
+						LastSequence:          41,
+						LastTimestamp:         1477920049286,
+						CoordinatorEpoch:      2,
+						CurrentTxnStartOffset: 12,
+					}},
+				},
+				{
+					PartitionIndex:  2,
+					ErrorCode:       ErrNotLeaderForPartition,
+					ActiveProducers: []ProducerState{},
+				},
+			},
+		}},
+	}
+	testResponse(t, "two partitions", response, describeProducersResponse)
+}
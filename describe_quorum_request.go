@@ -0,0 +1,92 @@
+package sarama
+
+// kraftMetadataTopic is the topic of the metadata log of KRaft clusters
+const kraftMetadataTopic = "__cluster_metadata"
+
+// DescribeQuorumRequest is a request to describe the state of the KRaft
+// quorum of some partitions (KIP-595)
+type DescribeQuorumRequest struct {
+	// Version 0 and 1 are supported
+	Version int16
+
+	Topics []DescribeQuorumRequestTopic
+}
+
+// DescribeQuorumRequestTopic lists the partitions of a topic to describe.
+type DescribeQuorumRequestTopic struct {
+	TopicName  string
+	Partitions []int32
+}
+
+func (r *DescribeQuorumRequest) encode(pe packetEncoder) error {
+	pe.putCompactArrayLength(len(r.Topics))
+	for _, topic := range r.Topics {
+		if err := pe.putCompactString(topic.TopicName); err != nil {
+			return err
+		}
+		pe.putCompactArrayLength(len(topic.Partitions))
+		for _, partition := range topic.Partitions {
+			pe.putInt32(partition)
+			pe.putEmptyTaggedFieldArray()
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *DescribeQuorumRequest) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	numTopics, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Topics = make([]DescribeQuorumRequestTopic, numTopics)
+	for i := range r.Topics {
+		topic := &r.Topics[i]
+		if topic.TopicName, err = pd.getCompactString(); err != nil {
+			return err
+		}
+		numPartitions, err := pd.getCompactArrayLength()
+		if err != nil {
+			return err
+		}
+		topic.Partitions = make([]int32, numPartitions)
+		for j := range topic.Partitions {
+			if topic.Partitions[j], err = pd.getInt32(); err != nil {
+				return err
+			}
+			if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+		}
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *DescribeQuorumRequest) key() int16 {
+	return 55
+}
+
+func (r *DescribeQuorumRequest) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeQuorumRequest) headerVersion() int16 {
+	return 2
+}
+
+func (r *DescribeQuorumRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V3_3_0_0
+	default:
+		return V2_7_0_0
+	}
+}
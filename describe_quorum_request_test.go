@@ -0,0 +1,23 @@
+package sarama
+
+import "testing"
+
+var describeQuorumRequest = []byte{
+	2, // Topics array, length 1
+	0x13, '_', '_', 'c', 'l', 'u', 's', 't', 'e', 'r', '_', 'm', 'e', 't', 'a', 'd', 'a', 't', 'a',
+	2,          // Partitions array, length 1
+	0, 0, 0, 0, // PartitionIndex
+	0, // empty tagged fields
+	0, // empty tagged fields
+	0, // empty tagged fields
+}
+
+func TestDescribeQuorumRequest(t *testing.T) {
+	request := &DescribeQuorumRequest{
+		Version: 1,
+		Topics: []DescribeQuorumRequestTopic{
+			{TopicName: kraftMetadataTopic, Partitions: []int32{0}},
+		},
+	}
+	testRequest(t, "metadata partition", request, describeQuorumRequest)
+}
@@ -0,0 +1,197 @@
+package sarama
+
+// DescribeQuorumResponse is the response to a DescribeQuorumRequest
+type DescribeQuorumResponse struct {
+	Version int16
+
+	ErrorCode KError
+	Topics    []DescribeQuorumResponseTopic
+}
+
+// DescribeQuorumResponseTopic holds the quorum state of the partitions of a
+// topic.
+type DescribeQuorumResponseTopic struct {
+	TopicName  string
+	Partitions []DescribeQuorumResponsePartition
+}
+
+// DescribeQuorumResponsePartition is the quorum state of a partition.
+type DescribeQuorumResponsePartition struct {
+	PartitionIndex int32
+	ErrorCode      KError
+	LeaderID       int32
+	LeaderEpoch    int32
+	HighWatermark  int64
+	CurrentVoters  []ReplicaState
+	Observers      []ReplicaState
+}
+
+// ReplicaState is the replication state of a voter or observer of a quorum.
+type ReplicaState struct {
+	ReplicaID    int32
+	LogEndOffset int64
+	// LastFetchTimestamp and LastCaughtUpTimestamp are in milliseconds, only
+	// returned from version 1 and -1 if unknown
+	LastFetchTimestamp    int64
+	LastCaughtUpTimestamp int64
+}
+
+func (s *ReplicaState) encode(pe packetEncoder, version int16) {
+	pe.putInt32(s.ReplicaID)
+	pe.putInt64(s.LogEndOffset)
+	if version >= 1 {
+		pe.putInt64(s.LastFetchTimestamp)
+		pe.putInt64(s.LastCaughtUpTimestamp)
+	}
+	pe.putEmptyTaggedFieldArray()
+}
+
+func (s *ReplicaState) decode(pd packetDecoder, version int16) (err error) {
+	if s.ReplicaID, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if s.LogEndOffset, err = pd.getInt64(); err != nil {
+		return err
+	}
+	s.LastFetchTimestamp, s.LastCaughtUpTimestamp = -1, -1
+	if version >= 1 {
+		if s.LastFetchTimestamp, err = pd.getInt64(); err != nil {
+			return err
+		}
+		if s.LastCaughtUpTimestamp, err = pd.getInt64(); err != nil {
+			return err
+		}
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func encodeReplicaStates(pe packetEncoder, states []ReplicaState, version int16) {
+	pe.putCompactArrayLength(len(states))
+	for i := range states {
+		states[i].encode(pe, version)
+	}
+}
+
+func decodeReplicaStates(pd packetDecoder, version int16) ([]ReplicaState, error) {
+	n, err := pd.getCompactArrayLength()
+	if err != nil {
+		return nil, err
+	}
+	states := make([]ReplicaState, n)
+	for i := range states {
+		if err := states[i].decode(pd, version); err != nil {
+			return nil, err
+		}
+	}
+	return states, nil
+}
+
+func (r *DescribeQuorumResponse) encode(pe packetEncoder) error {
+	pe.putInt16(int16(r.ErrorCode))
+
+	pe.putCompactArrayLength(len(r.Topics))
+	for _, topic := range r.Topics {
+		if err := pe.putCompactString(topic.TopicName); err != nil {
+			return err
+		}
+		pe.putCompactArrayLength(len(topic.Partitions))
+		for _, partition := range topic.Partitions {
+			pe.putInt32(partition.PartitionIndex)
+			pe.putInt16(int16(partition.ErrorCode))
+			pe.putInt32(partition.LeaderID)
+			pe.putInt32(partition.LeaderEpoch)
+			pe.putInt64(partition.HighWatermark)
+			encodeReplicaStates(pe, partition.CurrentVoters, r.Version)
+			encodeReplicaStates(pe, partition.Observers, r.Version)
+			pe.putEmptyTaggedFieldArray()
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *DescribeQuorumResponse) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.ErrorCode = KError(kerr)
+
+	numTopics, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Topics = make([]DescribeQuorumResponseTopic, numTopics)
+	for i := range r.Topics {
+		topic := &r.Topics[i]
+		if topic.TopicName, err = pd.getCompactString(); err != nil {
+			return err
+		}
+
+		numPartitions, err := pd.getCompactArrayLength()
+		if err != nil {
+			return err
+		}
+		topic.Partitions = make([]DescribeQuorumResponsePartition, numPartitions)
+		for j := range topic.Partitions {
+			partition := &topic.Partitions[j]
+			if partition.PartitionIndex, err = pd.getInt32(); err != nil {
+				return err
+			}
+			kerr, err := pd.getInt16()
+			if err != nil {
+				return err
+			}
+			partition.ErrorCode = KError(kerr)
+			if partition.LeaderID, err = pd.getInt32(); err != nil {
+				return err
+			}
+			if partition.LeaderEpoch, err = pd.getInt32(); err != nil {
+				return err
+			}
+			if partition.HighWatermark, err = pd.getInt64(); err != nil {
+				return err
+			}
+			if partition.CurrentVoters, err = decodeReplicaStates(pd, version); err != nil {
+				return err
+			}
+			if partition.Observers, err = decodeReplicaStates(pd, version); err != nil {
+				return err
+			}
+			if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+		}
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *DescribeQuorumResponse) key() int16 {
+	return 55
+}
+
+func (r *DescribeQuorumResponse) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeQuorumResponse) headerVersion() int16 {
+	return 1
+}
+
+func (r *DescribeQuorumResponse) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V3_3_0_0
+	default:
+		return V2_7_0_0
+	}
+}
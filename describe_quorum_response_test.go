@@ -0,0 +1,88 @@
+package sarama
+
+import "testing"
+
+var (
+	describeQuorumResponseV0 = []byte{
+		0, 0, // ErrorCode
+		2, // Topics array, length 1
+		0x13, '_', '_', 'c', 'l', 'u', 's', 't', 'e', 'r', '_', 'm', 'e', 't', 'a', 'd', 'a', 't', 'a',
+		2,          // Partitions array, length 1
+		0, 0, 0, 0, // PartitionIndex
+		0, 0, // ErrorCode
+		0, 0, 0, 1, // LeaderID
+		0, 0, 0, 5, // LeaderEpoch
+		0, 0, 0, 0, 0, 0, 0, 100, // HighWatermark
+		2,          // CurrentVoters array, length 1
+		0, 0, 0, 1, // ReplicaID
+		0, 0, 0, 0, 0, 0, 0, 100, // LogEndOffset
+		0,          // empty tagged fields
+		2,          // Observers array, length 1
+		0, 0, 0, 4, // ReplicaID
+		0, 0, 0, 0, 0, 0, 0, 90, // LogEndOffset
+		0, // empty tagged fields
+		0, // empty tagged fields
+		0, // empty tagged fields
+		0, // empty tagged fields
+	}
+
+	describeQuorumResponseV1 = []byte{
+		0, 0, // ErrorCode
+		2, // Topics array, length 1
+		0x13, '_', '_', 'c', 'l', 'u', 's', 't', 'e', 'r', '_', 'm', 'e', 't', 'a', 'd', 'a', 't', 'a',
+		2,          // Partitions array, length 1
+		0, 0, 0, 0, // PartitionIndex
+		0, 0, // ErrorCode
+		0, 0, 0, 1, // LeaderID
+		0, 0, 0, 5, // LeaderEpoch
+		0, 0, 0, 0, 0, 0, 0, 100, // HighWatermark
+		2,          // CurrentVoters array, length 1
+		0, 0, 0, 1, // ReplicaID
+		0, 0, 0, 0, 0, 0, 0, 100, // LogEndOffset
+		0, 0, 1, 88, 26, 230, 72, 134, // LastFetchTimestamp
+		0, 0, 1, 88, 26, 230, 72, 134, // LastCaughtUpTimestamp
+		0, // empty tagged fields
+		1, // Observers array, empty
+		0, // empty tagged fields
+		0, // empty tagged fields
+		0, // empty tagged fields
+	}
+)
+
+func TestDescribeQuorumResponse(t *testing.T) {
+	response := &DescribeQuorumResponse{
+		Version: 0,
+		Topics: []DescribeQuorumResponseTopic{{
+			TopicName: kraftMetadataTopic,
+			Partitions: []DescribeQuorumResponsePartition{{
+				LeaderID:      1,
+				LeaderEpoch:   5,
+				HighWatermark: 100,
+				CurrentVoters: []ReplicaState{
+					{ReplicaID: 1, LogEndOffset: 100, LastFetchTimestamp: -1, LastCaughtUpTimestamp: -1},
+				},
+				Observers: []ReplicaState{
+					{ReplicaID: 4, LogEndOffset: 90, LastFetchTimestamp: -1, LastCaughtUpTimestamp: -1},
+				},
+			}},
+		}},
+	}
+	testResponse(t, "v0", response, describeQuorumResponseV0)
+
+	response = &DescribeQuorumResponse{
+		Version: 1,
+		Topics: []DescribeQuorumResponseTopic{{
+			TopicName: kraftMetadataTopic,
+			Partitions: []DescribeQuorumResponsePartition{{
+				LeaderID:      1,
+				LeaderEpoch:   5,
+				HighWatermark: 100,
+				CurrentVoters: []ReplicaState{
+					{ReplicaID: 1, LogEndOffset: 100, LastFetchTimestamp: 1477920049286, LastCaughtUpTimestamp: 1477920049286},
+				},
+				Observers: []ReplicaState{},
+			}},
+		}},
+	}
+	testResponse(t, "v1", response, describeQuorumResponseV1)
+}
@@ -0,0 +1,55 @@
+package sarama
+
+// DescribeTransactionsRequest is a request to describe transactions, it must
+// be sent to their transaction coordinator (KIP-664)
+type DescribeTransactionsRequest struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	TransactionalIDs []string
+}
+
+func (r *DescribeTransactionsRequest) encode(pe packetEncoder) error {
+	pe.putCompactArrayLength(len(r.TransactionalIDs))
+	for _, transactionalID := range r.TransactionalIDs {
+		if err := pe.putCompactString(transactionalID); err != nil {
+			return err
+		}
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *DescribeTransactionsRequest) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	n, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	r.TransactionalIDs = make([]string, n)
+	for i := range r.TransactionalIDs {
+		if r.TransactionalIDs[i], err = pd.getCompactString(); err != nil {
+			return err
+		}
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *DescribeTransactionsRequest) key() int16 {
+	return 65
+}
+
+func (r *DescribeTransactionsRequest) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeTransactionsRequest) headerVersion() int16 {
+	return 2
+}
+
+func (r *DescribeTransactionsRequest) requiredVersion() KafkaVersion {
+	return V3_0_0_0
+}
@@ -0,0 +1,17 @@
+package sarama
+
+import "testing"
+
+var describeTransactionsRequest = []byte{
+	3,                // TransactionalIDs array, length 2
+	4, 't', 'x', '1', // tx1
+	4, 't', 'x', '2', // tx2
+	0, // empty tagged fields
+}
+
+func TestDescribeTransactionsRequest(t *testing.T) {
+	request := &DescribeTransactionsRequest{
+		TransactionalIDs: []string{"tx1", "tx2"},
+	}
+	testRequest(t, "two transactions", request, describeTransactionsRequest)
+}
@@ -0,0 +1,165 @@
+package sarama
+
+import "time"
+
+// DescribeTransactionsResponse is the response to a DescribeTransactionsRequest
+type DescribeTransactionsResponse struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	ThrottleTime      time.Duration
+	TransactionStates []*TransactionDescription
+}
+
+// TransactionDescription describes a transaction as seen by its coordinator.
+type TransactionDescription struct {
+	Err                  KError
+	TransactionalID      string
+	TransactionState     string
+	TransactionTimeoutMs int32
+	// TransactionStartTimeMs is -1 when no transaction is ongoing
+	TransactionStartTimeMs int64
+	ProducerID             int64
+	ProducerEpoch          int16
+	// Topics are the partitions included in the ongoing transaction
+	Topics []TransactionDescriptionTopic
+}
+
+// TransactionDescriptionTopic is the set of partitions of a topic included in
+// a transaction.
+type TransactionDescriptionTopic struct {
+	Topic      string
+	Partitions []int32
+}
+
+func (t *TransactionDescription) encode(pe packetEncoder) error {
+	pe.putInt16(int16(t.Err))
+	if err := pe.putCompactString(t.TransactionalID); err != nil {
+		return err
+	}
+	if err := pe.putCompactString(t.TransactionState); err != nil {
+		return err
+	}
+	pe.putInt32(t.TransactionTimeoutMs)
+	pe.putInt64(t.TransactionStartTimeMs)
+	pe.putInt64(t.ProducerID)
+	pe.putInt16(t.ProducerEpoch)
+
+	pe.putCompactArrayLength(len(t.Topics))
+	for _, topic := range t.Topics {
+		if err := pe.putCompactString(topic.Topic); err != nil {
+			return err
+		}
+		if err := pe.putCompactInt32Array(topic.Partitions); err != nil {
+			return err
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (t *TransactionDescription) decode(pd packetDecoder) (err error) {
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	t.Err = KError(kerr)
+	if t.TransactionalID, err = pd.getCompactString(); err != nil {
+		return err
+	}
+	if t.TransactionState, err = pd.getCompactString(); err != nil {
+		return err
+	}
+	if t.TransactionTimeoutMs, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if t.TransactionStartTimeMs, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if t.ProducerID, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if t.ProducerEpoch, err = pd.getInt16(); err != nil {
+		return err
+	}
+
+	numTopics, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	t.Topics = make([]TransactionDescriptionTopic, numTopics)
+	for i := range t.Topics {
+		if t.Topics[i].Topic, err = pd.getCompactString(); err != nil {
+			return err
+		}
+		if t.Topics[i].Partitions, err = pd.getCompactInt32Array(); err != nil {
+			return err
+		}
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *DescribeTransactionsResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+
+	pe.putCompactArrayLength(len(r.TransactionStates))
+	for _, state := range r.TransactionStates {
+		if err := state.encode(pe); err != nil {
+			return err
+		}
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *DescribeTransactionsResponse) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	throttleTime, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+
+	n, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	r.TransactionStates = make([]*TransactionDescription, n)
+	for i := range r.TransactionStates {
+		r.TransactionStates[i] = new(TransactionDescription)
+		if err := r.TransactionStates[i].decode(pd); err != nil {
+			return err
+		}
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *DescribeTransactionsResponse) key() int16 {
+	return 65
+}
+
+func (r *DescribeTransactionsResponse) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeTransactionsResponse) headerVersion() int16 {
+	return 1
+}
+
+func (r *DescribeTransactionsResponse) requiredVersion() KafkaVersion {
+	return V3_0_0_0
+}
+
+func (r *DescribeTransactionsResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
@@ -0,0 +1,43 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var describeTransactionsResponse = []byte{
+	0, 0, 0, 100, // ThrottleTime
+	2,    // TransactionStates array, length 1
+	0, 0, // ErrorCode
+	4, 't', 'x', '1', // TransactionalID
+	8, 'O', 'n', 'g', 'o', 'i', 'n', 'g', // TransactionState
+	0, 0, 234, 96, // TransactionTimeoutMs
+	0, 0, 1, 88, 26, 230, 72, 134, // TransactionStartTimeMs
+	0, 0, 0, 0, 0, 0, 3, 232, // ProducerID
+	0, 5, // ProducerEpoch
+	2,                // Topics array, length 1
+	4, 'f', 'o', 'o', // Topic
+	2, 0, 0, 0, 2, // Partitions [2]
+	0, // empty tagged fields
+	0, // empty tagged fields
+	0, // empty tagged fields
+}
+
+func TestDescribeTransactionsResponse(t *testing.T) {
+	response := &DescribeTransactionsResponse{
+		ThrottleTime: 100 * time.Millisecond,
+		TransactionStates: []*TransactionDescription{{
+			Err:                    ErrNoError,
+			TransactionalID:        "tx1",
+			TransactionState:       TransactionStateOngoing,
+			TransactionTimeoutMs:   60000,
+			TransactionStartTimeMs: 1477920049286,
+			ProducerID:             1000,
+			ProducerEpoch:          5,
+			Topics: []TransactionDescriptionTopic{
+				{Topic: "foo", Partitions: []int32{2}},
+			},
+		}},
+	}
+	testResponse(t, "one transaction", response, describeTransactionsResponse)
+}
@@ -0,0 +1,157 @@
+package sarama
+
+// ElectionType is the type of leader election to perform.
+type ElectionType int8
+
+const (
+	// PreferredElection elects the preferred replica as leader.
+	PreferredElection ElectionType = iota
+	// UncleanElection elects an out-of-sync replica as leader when no in-sync
+	// replica is available.
+	UncleanElection
+)
+
+// ElectLeadersRequest triggers the election of the leaders of a set of
+// partitions, sent to the controller.
+type ElectLeadersRequest struct {
+	Version int16
+	// Type is the type of election, only PreferredElection is supported by
+	// version 0
+	Type ElectionType
+	// TopicPartitions holds the partitions to elect a leader for, nil to
+	// elect leaders for all the partitions
+	TopicPartitions map[string][]int32
+	TimeoutMs       int32
+}
+
+func (r *ElectLeadersRequest) encode(pe packetEncoder) error {
+	if r.Version >= 1 {
+		pe.putInt8(int8(r.Type))
+	}
+
+	if r.TopicPartitions == nil {
+		if r.Version >= 2 {
+			pe.putCompactArrayLength(-1)
+		} else {
+			pe.putInt32(-1)
+		}
+	} else {
+		if r.Version >= 2 {
+			pe.putCompactArrayLength(len(r.TopicPartitions))
+		} else if err := pe.putArrayLength(len(r.TopicPartitions)); err != nil {
+			return err
+		}
+
+		for topic, partitions := range r.TopicPartitions {
+			if r.Version >= 2 {
+				if err := pe.putCompactString(topic); err != nil {
+					return err
+				}
+				if err := pe.putCompactInt32Array(partitions); err != nil {
+					return err
+				}
+				pe.putEmptyTaggedFieldArray()
+			} else {
+				if err := pe.putString(topic); err != nil {
+					return err
+				}
+				if err := pe.putInt32Array(partitions); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	pe.putInt32(r.TimeoutMs)
+
+	if r.Version >= 2 {
+		pe.putEmptyTaggedFieldArray()
+	}
+	return nil
+}
+
+func (r *ElectLeadersRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.Version >= 1 {
+		t, err := pd.getInt8()
+		if err != nil {
+			return err
+		}
+		r.Type = ElectionType(t)
+	}
+
+	var n int
+	if r.Version >= 2 {
+		// 0 represents a null array
+		var length uint64
+		if length, err = pd.getUVarint(); err != nil {
+			return err
+		}
+		n = int(length) - 1
+	} else if n, err = pd.getArrayLength(); err != nil {
+		return err
+	}
+
+	r.TopicPartitions = nil
+	if n >= 0 {
+		r.TopicPartitions = make(map[string][]int32, n)
+		for i := 0; i < n; i++ {
+			var topic string
+			var partitions []int32
+			if r.Version >= 2 {
+				if topic, err = pd.getCompactString(); err != nil {
+					return err
+				}
+				if partitions, err = pd.getCompactInt32Array(); err != nil {
+					return err
+				}
+				if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+					return err
+				}
+			} else {
+				if topic, err = pd.getString(); err != nil {
+					return err
+				}
+				if partitions, err = pd.getInt32Array(); err != nil {
+					return err
+				}
+			}
+			r.TopicPartitions[topic] = partitions
+		}
+	}
+
+	if r.TimeoutMs, err = pd.getInt32(); err != nil {
+		return err
+	}
+
+	if r.Version >= 2 {
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ElectLeadersRequest) key() int16 {
+	return 43
+}
+
+func (r *ElectLeadersRequest) version() int16 {
+	return r.Version
+}
+
+func (r *ElectLeadersRequest) headerVersion() int16 {
+	if r.Version >= 2 {
+		return 2
+	}
+	return 1
+}
+
+func (r *ElectLeadersRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1, 2:
+		return V2_4_0_0
+	default:
+		return V2_2_0_0
+	}
+}
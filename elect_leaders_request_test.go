@@ -0,0 +1,63 @@
+package sarama
+
+import "testing"
+
+var (
+	electLeadersRequestAllV0 = []byte{
+		0xff, 0xff, 0xff, 0xff, // null topic partitions
+		0x00, 0x00, 0x27, 0x10, // timeout 10000
+	}
+
+	electLeadersRequestOneTopicV1 = []byte{
+		0x01,                   // unclean election
+		0x00, 0x00, 0x00, 0x01, // 1 topic
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x02, // 2 partitions
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x27, 0x10, // timeout 10000
+	}
+
+	electLeadersRequestOneTopicV2 = []byte{
+		0x00, // preferred election
+		0x02, // 1 topic
+		0x06, 't', 'o', 'p', 'i', 'c',
+		0x03, // 2 partitions
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x01,
+		0x00,                   // empty tagged fields
+		0x00, 0x00, 0x27, 0x10, // timeout 10000
+		0x00, // empty tagged fields
+	}
+
+	electLeadersRequestAllV2 = []byte{
+		0x00,                   // preferred election
+		0x00,                   // null topic partitions
+		0x00, 0x00, 0x27, 0x10, // timeout 10000
+		0x00, // empty tagged fields
+	}
+)
+
+func TestElectLeadersRequest(t *testing.T) {
+	request := &ElectLeadersRequest{TimeoutMs: 10000}
+	testRequest(t, "all partitions V0", request, electLeadersRequestAllV0)
+
+	request = &ElectLeadersRequest{
+		Version:         1,
+		Type:            UncleanElection,
+		TopicPartitions: map[string][]int32{"topic": {0, 1}},
+		TimeoutMs:       10000,
+	}
+	testRequest(t, "one topic V1", request, electLeadersRequestOneTopicV1)
+
+	request = &ElectLeadersRequest{
+		Version:         2,
+		Type:            PreferredElection,
+		TopicPartitions: map[string][]int32{"topic": {0, 1}},
+		TimeoutMs:       10000,
+	}
+	testRequest(t, "one topic V2", request, electLeadersRequestOneTopicV2)
+
+	request = &ElectLeadersRequest{Version: 2, TimeoutMs: 10000}
+	testRequest(t, "all partitions V2", request, electLeadersRequestAllV2)
+}
@@ -0,0 +1,189 @@
+package sarama
+
+import "time"
+
+// PartitionResult is the outcome of the election of a partition leader.
+type PartitionResult struct {
+	ErrorCode    KError
+	ErrorMessage *string
+}
+
+func (b *PartitionResult) encode(pe packetEncoder, version int16) error {
+	pe.putInt16(int16(b.ErrorCode))
+	if version >= 2 {
+		if err := pe.putNullableCompactString(b.ErrorMessage); err != nil {
+			return err
+		}
+		pe.putEmptyTaggedFieldArray()
+		return nil
+	}
+	return pe.putNullableString(b.ErrorMessage)
+}
+
+func (b *PartitionResult) decode(pd packetDecoder, version int16) (err error) {
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	b.ErrorCode = KError(kerr)
+	if version >= 2 {
+		if b.ErrorMessage, err = pd.getCompactNullableString(); err != nil {
+			return err
+		}
+		_, err = pd.getEmptyTaggedFieldArray()
+		return err
+	}
+	b.ErrorMessage, err = pd.getNullableString()
+	return err
+}
+
+// ElectLeadersResponse is the response to an ElectLeadersRequest.
+type ElectLeadersResponse struct {
+	Version        int16
+	ThrottleTimeMs int32
+	// ErrorCode is the top level error, version 1+
+	ErrorCode              KError
+	ReplicaElectionResults map[string]map[int32]*PartitionResult
+}
+
+func (r *ElectLeadersResponse) encode(pe packetEncoder) error {
+	pe.putInt32(r.ThrottleTimeMs)
+
+	if r.Version >= 1 {
+		pe.putInt16(int16(r.ErrorCode))
+	}
+
+	if r.Version >= 2 {
+		pe.putCompactArrayLength(len(r.ReplicaElectionResults))
+	} else if err := pe.putArrayLength(len(r.ReplicaElectionResults)); err != nil {
+		return err
+	}
+	for topic, partitions := range r.ReplicaElectionResults {
+		if r.Version >= 2 {
+			if err := pe.putCompactString(topic); err != nil {
+				return err
+			}
+			pe.putCompactArrayLength(len(partitions))
+		} else {
+			if err := pe.putString(topic); err != nil {
+				return err
+			}
+			if err := pe.putArrayLength(len(partitions)); err != nil {
+				return err
+			}
+		}
+		for partition, result := range partitions {
+			pe.putInt32(partition)
+			if err := result.encode(pe, r.Version); err != nil {
+				return err
+			}
+		}
+		if r.Version >= 2 {
+			pe.putEmptyTaggedFieldArray()
+		}
+	}
+
+	if r.Version >= 2 {
+		pe.putEmptyTaggedFieldArray()
+	}
+	return nil
+}
+
+func (r *ElectLeadersResponse) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.ThrottleTimeMs, err = pd.getInt32(); err != nil {
+		return err
+	}
+
+	if r.Version >= 1 {
+		kerr, err := pd.getInt16()
+		if err != nil {
+			return err
+		}
+		r.ErrorCode = KError(kerr)
+	}
+
+	var numTopics int
+	if r.Version >= 2 {
+		numTopics, err = pd.getCompactArrayLength()
+	} else {
+		numTopics, err = pd.getArrayLength()
+	}
+	if err != nil {
+		return err
+	}
+
+	r.ReplicaElectionResults = make(map[string]map[int32]*PartitionResult, numTopics)
+	for i := 0; i < numTopics; i++ {
+		var topic string
+		var numPartitions int
+		if r.Version >= 2 {
+			if topic, err = pd.getCompactString(); err != nil {
+				return err
+			}
+			numPartitions, err = pd.getCompactArrayLength()
+		} else {
+			if topic, err = pd.getString(); err != nil {
+				return err
+			}
+			numPartitions, err = pd.getArrayLength()
+		}
+		if err != nil {
+			return err
+		}
+
+		r.ReplicaElectionResults[topic] = make(map[int32]*PartitionResult, numPartitions)
+		for j := 0; j < numPartitions; j++ {
+			partition, err := pd.getInt32()
+			if err != nil {
+				return err
+			}
+			result := new(PartitionResult)
+			if err := result.decode(pd, r.Version); err != nil {
+				return err
+			}
+			r.ReplicaElectionResults[topic][partition] = result
+		}
+
+		if r.Version >= 2 {
+			if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if r.Version >= 2 {
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ElectLeadersResponse) key() int16 {
+	return 43
+}
+
+func (r *ElectLeadersResponse) version() int16 {
+	return r.Version
+}
+
+func (r *ElectLeadersResponse) headerVersion() int16 {
+	if r.Version >= 2 {
+		return 1
+	}
+	return 0
+}
+
+func (r *ElectLeadersResponse) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1, 2:
+		return V2_4_0_0
+	default:
+		return V2_2_0_0
+	}
+}
+
+func (r *ElectLeadersResponse) throttleTime() time.Duration {
+	return time.Duration(r.ThrottleTimeMs) * time.Millisecond
+}
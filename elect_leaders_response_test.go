@@ -0,0 +1,46 @@
+package sarama
+
+import "testing"
+
+var (
+	electLeadersResponseV0 = []byte{
+		0x00, 0x00, 0x00, 0x00, // throttle time
+		0x00, 0x00, 0x00, 0x01, // 1 topic
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x01, // 1 partition
+		0x00, 0x00, 0x00, 0x00, // partition 0
+		0x00, 0x54, // ELECTION_NOT_NEEDED
+		0xff, 0xff, // null error message
+	}
+
+	electLeadersResponseV2 = []byte{
+		0x00, 0x00, 0x00, 0x00, // throttle time
+		0x00, 0x00, // no error
+		0x02, // 1 topic
+		0x06, 't', 'o', 'p', 'i', 'c',
+		0x02,                   // 1 partition
+		0x00, 0x00, 0x00, 0x00, // partition 0
+		0x00, 0x00, // no error
+		0x00, // null error message
+		0x00, // empty tagged fields
+		0x00, // empty tagged fields
+		0x00, // empty tagged fields
+	}
+)
+
+func TestElectLeadersResponse(t *testing.T) {
+	response := &ElectLeadersResponse{
+		ReplicaElectionResults: map[string]map[int32]*PartitionResult{
+			"topic": {0: {ErrorCode: ErrElectionNotNeeded}},
+		},
+	}
+	testResponse(t, "V0", response, electLeadersResponseV0)
+
+	response = &ElectLeadersResponse{
+		Version: 2,
+		ReplicaElectionResults: map[string]map[int32]*PartitionResult{
+			"topic": {0: {ErrorCode: ErrNoError}},
+		},
+	}
+	testResponse(t, "V2", response, electLeadersResponseV2)
+}
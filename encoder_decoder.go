@@ -1,10 +1,6 @@
 package sarama
 
-import (
-	"fmt"
-
-	"github.com/rcrowley/go-metrics"
-)
+import "fmt"
 
 // Encoder is the interface that wraps the basic Encode method.
 // Anything implementing Encoder can be turned into bytes using Kafka's encoding rules.
@@ -18,7 +14,7 @@ type encoderWithHeader interface {
 }
 
 // Encode takes an Encoder and turns it into bytes while potentially recording metrics.
-func encode(e encoder, metricRegistry metrics.Registry) ([]byte, error) {
+func encode(e encoder, metricsRecorder MetricsRecorder) ([]byte, error) {
 	if e == nil {
 		return nil, nil
 	}
@@ -36,7 +32,7 @@ func encode(e encoder, metricRegistry metrics.Registry) ([]byte, error) {
 	}
 
 	realEnc.raw = make([]byte, prepEnc.length)
-	realEnc.registry = metricRegistry
+	realEnc.recorder = metricsRecorder
 	err = e.encode(&realEnc)
 	if err != nil {
 		return nil, err
@@ -3,6 +3,7 @@ package sarama
 import (
 	"errors"
 	"fmt"
+	"net"
 )
 
 // ErrOutOfBrokers is the error returned when the client has run out of brokers to talk to because all of them errored
@@ -29,6 +30,10 @@ var ErrAlreadyConnected = errors.New("kafka: broker connection already initiated
 // ErrNotConnected is the error returned when trying to send or call Close() on a Broker that is not connected.
 var ErrNotConnected = errors.New("kafka: broker not connected")
 
+// ErrCircuitOpen is the error returned when trying to send to a Broker whose circuit is open after it failed
+// Net.CircuitBreaker.Failures times in a row.
+var ErrCircuitOpen = errors.New("kafka: broker circuit is open after consecutive failures")
+
 // ErrInsufficientData is returned when decoding and the packet is truncated. This can be expected
 // when requesting messages, since as an optimization the server is allowed to return a partial message at the end
 // of the message set.
@@ -55,6 +60,11 @@ var ErrNoTopicsToUpdateMetadata = errors.New("kafka: no specific topics to updat
 // ErrUnknownScramMechanism is returned when user tries to AlterUserScramCredentials with unknown SCRAM mechanism
 var ErrUnknownScramMechanism = errors.New("kafka: unknown SCRAM mechanism provided")
 
+// ErrInvalidSchemaRegistryPayload is returned by DecodeSchemaRegistryPayload
+// when the payload does not start with the magic byte and schema ID of the
+// schema registry wire format.
+var ErrInvalidSchemaRegistryPayload = errors.New("kafka: payload is not in the schema registry wire format")
+
 // PacketEncodingError is returned from a failure while encoding a Kafka packet. This can happen, for example,
 // if you try to encode a string over 2^15 characters in length, since Kafka's encoding rules do not permit that.
 type PacketEncodingError struct {
@@ -75,6 +85,22 @@ func (err PacketDecodingError) Error() string {
 	return fmt.Sprintf("kafka: error decoding packet: %s", err.Info)
 }
 
+// NetworkError is the type of error returned when the connection to a broker
+// fails, wrapping the error of the connection, such as a net.Error, io.EOF or
+// a TLS error.
+type NetworkError struct {
+	Addr string // the address of the broker
+	Err  error
+}
+
+func (err NetworkError) Error() string {
+	return fmt.Sprintf("kafka: network error with broker %s: %v", err.Addr, err.Err)
+}
+
+func (err NetworkError) Unwrap() error {
+	return err.Err
+}
+
 // ConfigurationError is the type of error returned from a constructor (e.g. NewClient, or NewConsumer)
 // when the specified configuration is invalid.
 type ConfigurationError string
@@ -117,6 +143,36 @@ func (err ErrDeleteRecords) Error() string {
 	return "kafka server: failed to delete records " + err.MultiError.Error()
 }
 
+// ErrListOffsets is the type of error returned when fail to list the offsets
+// of some of the required partitions
+type ErrListOffsets struct {
+	MultiError
+}
+
+func (err ErrListOffsets) Error() string {
+	return "kafka server: failed to list offsets " + err.MultiError.Error()
+}
+
+// ErrDescribeProducers is the type of error returned when fail to describe the
+// producers of some of the required partitions
+type ErrDescribeProducers struct {
+	MultiError
+}
+
+func (err ErrDescribeProducers) Error() string {
+	return "kafka server: failed to describe producers " + err.MultiError.Error()
+}
+
+// ErrFindCoordinators is the type of error returned when fail to find the
+// coordinators of some of the required consumer groups
+type ErrFindCoordinators struct {
+	MultiError
+}
+
+func (err ErrFindCoordinators) Error() string {
+	return "kafka server: failed to find coordinators " + err.MultiError.Error()
+}
+
 type ErrReassignPartitions struct {
 	MultiError
 }
@@ -217,6 +273,36 @@ const (
 	ErrGroupSubscribedToTopic             KError = 86
 	ErrInvalidRecord                      KError = 87
 	ErrUnstableOffsetCommit               KError = 88
+	ErrThrottlingQuotaExceeded            KError = 89
+	ErrProducerFenced                     KError = 90
+	ErrResourceNotFound                   KError = 91
+	ErrDuplicateResource                  KError = 92
+	ErrUnacceptableCredential             KError = 93
+	ErrInconsistentVoterSet               KError = 94
+	ErrInvalidUpdateVersion               KError = 95
+	ErrFeatureUpdateFailed                KError = 96
+	ErrPrincipalDeserializationFailure    KError = 97
+	ErrSnapshotNotFound                   KError = 98
+	ErrPositionOutOfRange                 KError = 99
+	ErrUnknownTopicID                     KError = 100
+	ErrDuplicateBrokerRegistration        KError = 101
+	ErrBrokerIDNotRegistered              KError = 102
+	ErrInconsistentTopicID                KError = 103
+	ErrInconsistentClusterID              KError = 104
+	ErrTransactionalIDNotFound            KError = 105
+	ErrFetchSessionTopicIDError           KError = 106
+	ErrIneligibleReplica                  KError = 107
+	ErrNewLeaderElected                   KError = 108
+	ErrOffsetMovedToTieredStorage         KError = 109
+	ErrFencedMemberEpoch                  KError = 110
+	ErrUnreleasedInstanceID               KError = 111
+	ErrUnsupportedAssignor                KError = 112
+	ErrStaleMemberEpoch                   KError = 113
+	ErrMismatchedEndpointType             KError = 114
+	ErrUnsupportedEndpointType            KError = 115
+	ErrUnknownControllerID                KError = 116
+	ErrUnknownSubscriptionID              KError = 117
+	ErrTelemetryTooLarge                  KError = 118
 )
 
 func (err KError) Error() string {
@@ -403,7 +489,161 @@ func (err KError) Error() string {
 		return "kafka server: This record has failed the validation on broker and hence will be rejected."
 	case ErrUnstableOffsetCommit:
 		return "kafka server: There are unstable offsets that need to be cleared."
+	case ErrThrottlingQuotaExceeded:
+		return "kafka server: The throttling quota has been exceeded."
+	case ErrProducerFenced:
+		return "kafka server: There is a newer producer with the same transactionalId which fences the current one."
+	case ErrResourceNotFound:
+		return "kafka server: A request illegally referred to a resource that does not exist."
+	case ErrDuplicateResource:
+		return "kafka server: A request illegally referred to the same resource twice."
+	case ErrUnacceptableCredential:
+		return "kafka server: Requested credential would not meet criteria for acceptability."
+	case ErrInconsistentVoterSet:
+		return "kafka server: Indicates that the either the sender or recipient of a voter-only request is not one of the expected voters."
+	case ErrInvalidUpdateVersion:
+		return "kafka server: The given update version was invalid."
+	case ErrFeatureUpdateFailed:
+		return "kafka server: Unable to update finalized features due to an unexpected server error."
+	case ErrPrincipalDeserializationFailure:
+		return "kafka server: Request principal deserialization failed during forwarding. This indicates an internal error on the broker cluster security setup."
+	case ErrSnapshotNotFound:
+		return "kafka server: Requested snapshot was not found."
+	case ErrPositionOutOfRange:
+		return "kafka server: Requested position is not greater than or equal to zero, and less than the size of the snapshot."
+	case ErrUnknownTopicID:
+		return "kafka server: This server does not host this topic ID."
+	case ErrDuplicateBrokerRegistration:
+		return "kafka server: This broker ID is already in use."
+	case ErrBrokerIDNotRegistered:
+		return "kafka server: The given broker ID was not registered."
+	case ErrInconsistentTopicID:
+		return "kafka server: The log's topic ID did not match the topic ID in the request."
+	case ErrInconsistentClusterID:
+		return "kafka server: The clusterId in the request does not match that found on the server."
+	case ErrTransactionalIDNotFound:
+		return "kafka server: The transactionalId could not be found."
+	case ErrFetchSessionTopicIDError:
+		return "kafka server: The fetch session encountered inconsistent topic ID usage."
+	case ErrIneligibleReplica:
+		return "kafka server: The new ISR contains at least one ineligible replica."
+	case ErrNewLeaderElected:
+		return "kafka server: The AlterPartition request successfully updated the partition state but the leader has changed."
+	case ErrOffsetMovedToTieredStorage:
+		return "kafka server: The requested offset is moved to tiered storage."
+	case ErrFencedMemberEpoch:
+		return "kafka server: The member epoch is fenced by the group coordinator. The member must abandon all its partitions and rejoin."
+	case ErrUnreleasedInstanceID:
+		return "kafka server: The instance ID is still used by another member in the consumer group. That member must leave first."
+	case ErrUnsupportedAssignor:
+		return "kafka server: The assignor or its version range is not supported by the consumer group."
+	case ErrStaleMemberEpoch:
+		return "kafka server: The member epoch is stale. The member must retry after receiving its updated member epoch via the ConsumerGroupHeartbeat API."
+	case ErrMismatchedEndpointType:
+		return "kafka server: The request was sent to an endpoint of the wrong type."
+	case ErrUnsupportedEndpointType:
+		return "kafka server: This endpoint type is not supported yet."
+	case ErrUnknownControllerID:
+		return "kafka server: This controller ID is not known."
+	case ErrUnknownSubscriptionID:
+		return "kafka server: Client sent a push telemetry request with an invalid or outdated subscription ID."
+	case ErrTelemetryTooLarge:
+		return "kafka server: Client sent a push telemetry request larger than the maximum size the broker will accept."
 	}
 
 	return fmt.Sprintf("Unknown error, how did this happen? Error code = %d", err)
 }
+
+// retriableKErrors are the transient errors of the brokers, the ones of the
+// RetriableException of the Java client
+var retriableKErrors = map[KError]bool{
+	ErrInvalidMessage:                  true,
+	ErrUnknownTopicOrPartition:         true,
+	ErrLeaderNotAvailable:              true,
+	ErrNotLeaderForPartition:           true,
+	ErrRequestTimedOut:                 true,
+	ErrReplicaNotAvailable:             true,
+	ErrNetworkException:                true,
+	ErrOffsetsLoadInProgress:           true,
+	ErrConsumerCoordinatorNotAvailable: true,
+	ErrNotCoordinatorForConsumer:       true,
+	ErrNotEnoughReplicas:               true,
+	ErrNotEnoughReplicasAfterAppend:    true,
+	ErrNotController:                   true,
+	ErrConcurrentTransactions:          true,
+	ErrKafkaStorageError:               true,
+	ErrFetchSessionIDNotFound:          true,
+	ErrInvalidFetchSessionEpoch:        true,
+	ErrListenerNotFound:                true,
+	ErrFencedLeaderEpoch:               true,
+	ErrUnknownLeaderEpoch:              true,
+	ErrOffsetNotAvailable:              true,
+	ErrPreferredLeaderNotAvailable:     true,
+	ErrEligibleLeadersNotAvailable:     true,
+	ErrUnstableOffsetCommit:            true,
+	ErrThrottlingQuotaExceeded:         true,
+	ErrUnknownTopicID:                  true,
+	ErrInconsistentTopicID:             true,
+	ErrFetchSessionTopicIDError:        true,
+}
+
+// fatalKErrors are the errors of the brokers rejecting the client for good,
+// after which a producer or a consumer can not go on
+var fatalKErrors = map[KError]bool{
+	ErrClusterAuthorizationFailed:         true,
+	ErrUnsupportedSASLMechanism:           true,
+	ErrIllegalSASLState:                   true,
+	ErrUnsupportedVersion:                 true,
+	ErrUnsupportedForMessageFormat:        true,
+	ErrOutOfOrderSequenceNumber:           true,
+	ErrInvalidProducerEpoch:               true,
+	ErrTransactionCoordinatorFenced:       true,
+	ErrTransactionalIDAuthorizationFailed: true,
+	ErrSASLAuthenticationFailed:           true,
+	ErrUnsupportedCompressionType:         true,
+	ErrFencedInstancedId:                  true,
+	ErrProducerFenced:                     true,
+}
+
+// IsRetriable returns whether the call failing with err, which may be
+// wrapped, may succeed if retried once the metadata is refreshed or the
+// brokers are reachable again: the transient errors of the brokers, the
+// network errors and the errors of the client while no broker is available.
+func IsRetriable(err error) bool {
+	var kerr KError
+	if errors.As(err, &kerr) {
+		return retriableKErrors[kerr]
+	}
+	var networkErr NetworkError
+	if errors.As(err, &networkErr) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	for _, target := range []error{ErrOutOfBrokers, ErrNotConnected, ErrCircuitOpen, ErrControllerNotAvailable} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFatal returns whether err, which may be wrapped, means that the client,
+// or the producer or consumer using it, can not go on: the invalid
+// configurations, the closed clients and producers, and the errors of the
+// brokers rejecting the client for good, such as failed authentications or
+// fenced producers. Retriable errors are never fatal, while the other errors,
+// such as ErrOffsetOutOfRange, are left to the application to handle.
+func IsFatal(err error) bool {
+	var kerr KError
+	if errors.As(err, &kerr) {
+		return fatalKErrors[kerr]
+	}
+	var configErr ConfigurationError
+	if errors.As(err, &configErr) {
+		return true
+	}
+	return errors.Is(err, ErrClosedClient) || errors.Is(err, ErrShuttingDown)
+}
@@ -0,0 +1,68 @@
+package sarama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestIsRetriableAndIsFatal(t *testing.T) {
+	for _, tc := range []struct {
+		err       error
+		retriable bool
+		fatal     bool
+	}{
+		{ErrNotLeaderForPartition, true, false},
+		{fmt.Errorf("producing: %w", ErrRequestTimedOut), true, false},
+		{ErrOffsetOutOfRange, false, false},
+		{ErrTopicAuthorizationFailed, false, false},
+		{ErrProducerFenced, false, true},
+		{fmt.Errorf("authenticating: %w", ErrSASLAuthenticationFailed), false, true},
+		{NetworkError{Addr: "localhost:9092", Err: io.EOF}, true, false},
+		{&net.OpError{Op: "read", Err: context.DeadlineExceeded}, true, false},
+		{ErrOutOfBrokers, true, false},
+		{ErrCircuitOpen, true, false},
+		{ConfigurationError("invalid"), false, true},
+		{ErrClosedClient, false, true},
+		{PacketDecodingError{"invalid"}, false, false},
+		{errors.New("unknown"), false, false},
+		{nil, false, false},
+	} {
+		if retriable := IsRetriable(tc.err); retriable != tc.retriable {
+			t.Errorf("Expected IsRetriable(%v) to be %v", tc.err, tc.retriable)
+		}
+		if fatal := IsFatal(tc.err); fatal != tc.fatal {
+			t.Errorf("Expected IsFatal(%v) to be %v", tc.err, tc.fatal)
+		}
+	}
+}
+
+func TestBrokerNetworkError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	broker := NewBroker(addr)
+	if err := broker.Open(NewTestConfig()); err != nil {
+		t.Fatal(err)
+	}
+	_, err = broker.Connected()
+
+	var networkErr NetworkError
+	if !errors.As(err, &networkErr) || networkErr.Addr != addr {
+		t.Fatalf("Expected a network error with %s, got %v", addr, err)
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		t.Errorf("Expected the error of the connection to be wrapped, got %v", networkErr.Err)
+	}
+	if !IsRetriable(err) {
+		t.Error("Expected a network error to be retriable")
+	}
+}
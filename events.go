@@ -0,0 +1,124 @@
+package sarama
+
+// EventType is the type of an Event.
+type EventType int8
+
+const (
+	// EventBrokerConnected is sent when the connection to a broker is open,
+	// authenticated if SASL is enabled.
+	EventBrokerConnected EventType = iota
+	// EventBrokerDisconnected is sent when the connection to a broker is
+	// closed, with the error of the close if any. The clients close the
+	// connections which failed as well.
+	EventBrokerDisconnected
+	// EventBrokerAuthFailed is sent when the SASL authentication to a broker
+	// fails, when connecting or re-authenticating, with the error.
+	EventBrokerAuthFailed
+	// EventMetadataUpdated is sent when a client stored refreshed metadata,
+	// with the topics of the response.
+	EventMetadataUpdated
+	// EventCoordinatorChanged is sent when the coordinator of a consumer
+	// group known by a client changes, or becomes known, with the broker of
+	// the new coordinator.
+	EventCoordinatorChanged
+	// EventRebalanceStarted is sent when a consumer group joins the group,
+	// with the reason: RebalanceReasonInitialJoin for the first session, and
+	// otherwise the reason the previous session ended.
+	EventRebalanceStarted
+	// EventRebalanceFinished is sent once the member is part of the group
+	// with its assignment, with the member ID and generation, or with the
+	// error of the rebalance.
+	EventRebalanceFinished
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventBrokerConnected:
+		return "BrokerConnected"
+	case EventBrokerDisconnected:
+		return "BrokerDisconnected"
+	case EventBrokerAuthFailed:
+		return "BrokerAuthFailed"
+	case EventMetadataUpdated:
+		return "MetadataUpdated"
+	case EventCoordinatorChanged:
+		return "CoordinatorChanged"
+	case EventRebalanceStarted:
+		return "RebalanceStarted"
+	case EventRebalanceFinished:
+		return "RebalanceFinished"
+	}
+	return "Unknown"
+}
+
+// The reasons of EventRebalanceStarted.
+const (
+	// RebalanceReasonInitialJoin is the reason of the first session.
+	RebalanceReasonInitialJoin = "initial join"
+	// RebalanceReasonSessionEnded is the reason when the session ended as its
+	// context was canceled, a claim ended or the group was closed.
+	RebalanceReasonSessionEnded = "session ended"
+	// RebalanceReasonRebalanceInProgress is the reason when the coordinator
+	// started a rebalance, e.g. as a member joined or left.
+	RebalanceReasonRebalanceInProgress = "rebalance in progress"
+	// RebalanceReasonMemberLost is the reason when the member was no longer
+	// part of the group: its session timed out or it was fenced.
+	RebalanceReasonMemberLost = "member lost"
+	// RebalanceReasonHeartbeatFailed is the reason when the heartbeats failed.
+	RebalanceReasonHeartbeatFailed = "heartbeat failed"
+	// RebalanceReasonPartitionsChanged is the reason when the number of
+	// partitions of a topic changed.
+	RebalanceReasonPartitionsChanged = "partitions changed"
+	// RebalanceReasonTopicsChanged is the reason when the topics matching the
+	// pattern of ConsumePattern changed.
+	RebalanceReasonTopicsChanged = "topics changed"
+	// RebalanceReasonMaxPollIntervalExceeded is the reason when the member
+	// left after a claim exceeded Consumer.Group.MaxPollInterval.
+	RebalanceReasonMaxPollIntervalExceeded = "max poll interval exceeded"
+)
+
+// Event is a state transition of a client, its brokers or its consumer
+// groups, sent to Config.EventHandler. The fields not concerning the Type are
+// zero.
+type Event struct {
+	Type EventType
+	// BrokerID and Addr are the broker of the broker events, and the new
+	// coordinator of EventCoordinatorChanged
+	BrokerID int32
+	Addr     string
+	// Topics are the topics of EventMetadataUpdated
+	Topics []string
+	// Group is the consumer group of the coordinator and rebalance events,
+	// Member and Generation the member ID and generation of
+	// EventRebalanceFinished
+	Group      string
+	Member     string
+	Generation int32
+	// Reason is the reason of EventRebalanceStarted
+	Reason string
+	// Err is the failure of the event, if any
+	Err error
+}
+
+// EventHandler receives the events of Sarama, see Config.EventHandler.
+type EventHandler interface {
+	// HandleEvent is called with each event, synchronously from the
+	// goroutine of the transition. The broker and client events are sent once
+	// their locks are released. It must not block.
+	HandleEvent(event Event)
+}
+
+// EventHandlerFunc is an EventHandler calling a function.
+type EventHandlerFunc func(event Event)
+
+// HandleEvent calls f(event).
+func (f EventHandlerFunc) HandleEvent(event Event) {
+	f(event)
+}
+
+// notify sends event to EventHandler, if set
+func (c *Config) notify(event Event) {
+	if c != nil && c.EventHandler != nil {
+		c.EventHandler.HandleEvent(event)
+	}
+}
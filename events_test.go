@@ -0,0 +1,189 @@
+package sarama
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingEventHandler struct {
+	lock   sync.Mutex
+	events []Event
+}
+
+func (h *recordingEventHandler) HandleEvent(event Event) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.events = append(h.events, event)
+}
+
+// ofType returns the events recorded of the given types, in order
+func (h *recordingEventHandler) ofType(types ...EventType) []Event {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	var events []Event
+	for _, event := range h.events {
+		for _, t := range types {
+			if event.Type == t {
+				events = append(events, event)
+			}
+		}
+	}
+	return events
+}
+
+func TestBrokerEvents(t *testing.T) {
+	mb := NewMockBroker(t, 1)
+	defer mb.Close()
+
+	handler := &recordingEventHandler{}
+	conf := NewTestConfig()
+	conf.EventHandler = handler
+	broker := NewBroker(mb.Addr())
+	broker.id = mb.BrokerID()
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	if connected, err := broker.Connected(); !connected || err != nil {
+		t.Fatal("Expected the broker to be connected", err)
+	}
+	safeClose(t, broker)
+
+	events := handler.ofType(EventBrokerConnected, EventBrokerDisconnected)
+	if len(events) != 2 || events[0].Type != EventBrokerConnected || events[1].Type != EventBrokerDisconnected {
+		t.Fatalf("Expected the broker to connect and disconnect, got %+v", events)
+	}
+	for _, event := range events {
+		if event.BrokerID != 1 || event.Addr != mb.Addr() || event.Err != nil {
+			t.Errorf("Unexpected event %+v", event)
+		}
+	}
+}
+
+func TestBrokerAuthFailedEvent(t *testing.T) {
+	mb := NewMockBroker(t, 1)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"SaslHandshakeRequest": NewMockSaslHandshakeResponse(t).SetError(ErrUnsupportedSASLMechanism),
+	})
+
+	handler := &recordingEventHandler{}
+	conf := NewTestConfig()
+	conf.EventHandler = handler
+	conf.Net.SASL.Enable = true
+	conf.Net.SASL.User = "user"
+	conf.Net.SASL.Password = "password"
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	if connected, err := broker.Connected(); connected || err == nil {
+		t.Fatal("Expected the authentication to fail")
+	}
+
+	events := handler.ofType(EventBrokerConnected, EventBrokerAuthFailed)
+	if len(events) != 1 || events[0].Type != EventBrokerAuthFailed || events[0].Err == nil {
+		t.Errorf("Expected the authentication failure only, got %+v", events)
+	}
+}
+
+func TestClientEvents(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "my_group", seedBroker),
+	})
+
+	handler := &recordingEventHandler{}
+	conf := NewTestConfig()
+	conf.EventHandler = handler
+	client, err := NewClient([]string{seedBroker.Addr()}, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	if _, err := client.Coordinator("my_group"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RefreshCoordinator("my_group"); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata := handler.ofType(EventMetadataUpdated)
+	if len(metadata) == 0 || len(metadata[0].Topics) != 1 || metadata[0].Topics[0] != "my_topic" || metadata[0].Err != nil {
+		t.Errorf("Expected the metadata of my_topic to be updated, got %+v", metadata)
+	}
+	coordinator := handler.ofType(EventCoordinatorChanged)
+	if len(coordinator) != 1 {
+		t.Fatalf("Expected the coordinator to change once, got %+v", coordinator)
+	}
+	if event := coordinator[0]; event.Group != "my_group" || event.BrokerID != seedBroker.BrokerID() || event.Addr != seedBroker.Addr() {
+		t.Errorf("Unexpected coordinator event %+v", event)
+	}
+}
+
+func TestConsumerGroupRebalanceEvents(t *testing.T) {
+	broker := NewMockBroker(t, 0)
+	defer broker.Close()
+	handlers := newConsumerGroupTestHandlers(t, broker, 7)
+	broker.SetHandlerByMap(handlers)
+
+	events := &recordingEventHandler{}
+	config := newConsumerGroupTestConfig()
+	config.Metadata.RefreshFrequency = 20 * time.Millisecond
+	config.EventHandler = events
+	group, err := NewConsumerGroup([]string{broker.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	pattern := regexp.MustCompile(`^my-`)
+	handler := newTestConsumerGroupHandler()
+	done := consumeInBackground(func() error {
+		return group.ConsumePattern(context.Background(), pattern, handler)
+	})
+	handler.waitClaim(t)
+
+	// a new matching topic ends the session
+	broker.SetHandlerByMap(withHandlers(handlers, map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("my-topic", 0, broker.BrokerID()).
+			SetLeader("my-new-topic", 0, broker.BrokerID()),
+	}))
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done = consumeInBackground(func() error {
+		return group.ConsumePattern(ctx, pattern, handler)
+	})
+	handler.waitClaim(t)
+	cancel()
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+
+	rebalances := events.ofType(EventRebalanceStarted, EventRebalanceFinished)
+	if len(rebalances) != 4 {
+		t.Fatalf("Expected two rebalances, got %+v", rebalances)
+	}
+	for i, reason := range []string{RebalanceReasonInitialJoin, RebalanceReasonTopicsChanged} {
+		started, finished := rebalances[2*i], rebalances[2*i+1]
+		if started.Type != EventRebalanceStarted || started.Group != "my-group" || started.Reason != reason {
+			t.Errorf("Expected the rebalance to start with %q, got %+v", reason, started)
+		}
+		if finished.Type != EventRebalanceFinished || finished.Err != nil || finished.Member == "" {
+			t.Errorf("Expected the rebalance to finish, got %+v", finished)
+		}
+	}
+}
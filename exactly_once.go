@@ -0,0 +1,554 @@
+package sarama
+
+import (
+	"fmt"
+	"time"
+)
+
+// ExactlyOnceTransform returns the messages to produce for msg, consumed by
+// the handler returned by NewExactlyOnceHandler. They are produced in the
+// transaction committing the offset of msg, so that the consumers reading
+// with ReadCommitted see either all of them once or none of them. A message
+// may be transformed again when the transaction it was part of is aborted.
+type ExactlyOnceTransform func(msg *ConsumerMessage) ([]*ProducerMessage, error)
+
+// ExactlyOnceConfig configures the handler returned by NewExactlyOnceHandler.
+type ExactlyOnceConfig struct {
+	// TransactionalID prefixes the transactional IDs of the handler, one per
+	// consumed partition, such as "my-app-my-topic-0" for "my-app". It must
+	// be the same for all the members of the group and must not be used by
+	// the handlers of other groups.
+	TransactionalID string
+
+	// TransactionTimeout is how long the transaction coordinator waits for a
+	// transaction to end before aborting it (default 1 minute).
+	TransactionTimeout time.Duration
+
+	// MaxMessages is the maximum number of consumed messages per
+	// transaction. The transactions also end when no message is waiting
+	// (default 500).
+	MaxMessages int
+}
+
+// NewExactlyOnceHandler returns a ConsumerGroupHandler of the consumer group
+// groupID running consume-transform-produce loops with exactly-once
+// semantics: the messages returned by transform for the messages of each
+// claim are produced with client, along with the offsets of the consumed
+// messages, in transactions which are committed or aborted as a whole.
+//
+// Each claim uses its own transactional ID, so that the member the partition
+// is assigned to after a rebalance fences the transactions of the former one,
+// which fail with ErrProducerFenced. The errors end the claim, and thus the
+// session, after aborting the ongoing transaction when the producer was not
+// fenced, and are returned on the Errors channel of the group. The next
+// session consumes again from the last committed transaction.
+//
+// The offsets are only committed by the transactions: the handler does not
+// mark them in the session. It requires Version >= V0_11_0_0, and the
+// messages are produced with the Producer settings of the config of client,
+// RequiredAcks being WaitForAll.
+func NewExactlyOnceHandler(client Client, groupID string, conf ExactlyOnceConfig, transform ExactlyOnceTransform) (ConsumerGroupHandler, error) {
+	switch {
+	case !client.Config().Version.IsAtLeast(V0_11_0_0):
+		return nil, ConfigurationError("exactly-once handlers require Version >= V0_11_0_0")
+	case groupID == "":
+		return nil, ConfigurationError("exactly-once handlers require a consumer group")
+	case conf.TransactionalID == "":
+		return nil, ConfigurationError("ExactlyOnceConfig.TransactionalID must not be empty")
+	case conf.TransactionTimeout < 0:
+		return nil, ConfigurationError("ExactlyOnceConfig.TransactionTimeout must be >= 0")
+	case conf.MaxMessages < 0:
+		return nil, ConfigurationError("ExactlyOnceConfig.MaxMessages must be >= 0")
+	case transform == nil:
+		return nil, ConfigurationError("exactly-once handlers require a transform")
+	}
+	if conf.TransactionTimeout == 0 {
+		conf.TransactionTimeout = time.Minute
+	}
+	if conf.MaxMessages == 0 {
+		conf.MaxMessages = 500
+	}
+	return &exactlyOnceHandler{client: client, groupID: groupID, conf: conf, transform: transform}, nil
+}
+
+type exactlyOnceHandler struct {
+	client    Client
+	groupID   string
+	conf      ExactlyOnceConfig
+	transform ExactlyOnceTransform
+}
+
+func (h *exactlyOnceHandler) Setup(ConsumerGroupSession) error   { return nil }
+func (h *exactlyOnceHandler) Cleanup(ConsumerGroupSession) error { return nil }
+
+func (h *exactlyOnceHandler) ConsumeClaim(_ ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	txn := &claimTransaction{
+		exactlyOnceHandler: h,
+		transactionalID:    fmt.Sprintf("%s-%s-%d", h.conf.TransactionalID, claim.Topic(), claim.Partition()),
+		topic:              claim.Topic(),
+		partition:          claim.Partition(),
+		sequences:          make(map[string]map[int32]int32),
+		partitioners:       make(map[string]Partitioner),
+	}
+	if err := txn.initProducerID(); err != nil {
+		return err
+	}
+
+	for {
+		msgs, ok := h.nextMessages(claim)
+		if len(msgs) > 0 {
+			if err := txn.run(msgs); err != nil {
+				return err
+			}
+		}
+		if !ok {
+			return nil
+		}
+	}
+}
+
+// nextMessages returns the messages waiting on the claim, at least one
+// unless it is closed, and whether it is still open
+func (h *exactlyOnceHandler) nextMessages(claim ConsumerGroupClaim) ([]*ConsumerMessage, bool) {
+	msg, ok := <-claim.Messages()
+	if !ok {
+		return nil, false
+	}
+	msgs := []*ConsumerMessage{msg}
+	for len(msgs) < h.conf.MaxMessages {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return msgs, false
+			}
+			msgs = append(msgs, msg)
+		default:
+			return msgs, true
+		}
+	}
+	return msgs, true
+}
+
+// claimTransaction is the transactional producer of a claim
+type claimTransaction struct {
+	*exactlyOnceHandler
+	transactionalID string
+	topic           string
+	partition       int32
+
+	coordinator   *Broker
+	producerID    int64
+	producerEpoch int16
+
+	// ongoing is set once the transaction started, partitions being the
+	// partitions added to it
+	ongoing    bool
+	partitions map[string][]int32
+	// sequences are the sequence numbers of the next batches by partition
+	sequences    map[string]map[int32]int32
+	partitioners map[string]Partitioner
+}
+
+// retry calls fn until it succeeds, fails with an error which is not
+// retriable or Producer.Retry.Max retries were made, the transaction
+// coordinator being looked up again before each retry
+func (t *claimTransaction) retry(fn func() error) error {
+	conf := t.client.Config()
+	for retries := 0; ; retries++ {
+		err := fn()
+		if err == nil || !IsRetriable(err) || retries >= conf.Producer.Retry.Max {
+			return err
+		}
+		t.coordinator = nil
+		time.Sleep(conf.Producer.Retry.Backoff)
+	}
+}
+
+// transactionCoordinator returns the coordinator of the transactional ID
+func (t *claimTransaction) transactionCoordinator() (*Broker, error) {
+	if t.coordinator != nil {
+		return t.coordinator, nil
+	}
+
+	broker, err := t.client.LeastLoadedBroker()
+	if err != nil {
+		return nil, err
+	}
+	res, err := broker.FindCoordinator(&FindCoordinatorRequest{
+		Version:         1,
+		CoordinatorKey:  t.transactionalID,
+		CoordinatorType: CoordinatorTransaction,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Err != ErrNoError {
+		return nil, res.Err
+	}
+	if t.coordinator, err = t.client.Broker(res.Coordinator.ID()); err != nil {
+		return nil, err
+	}
+	return t.coordinator, nil
+}
+
+// initProducerID gets the producer ID and epoch of the transactional ID,
+// which fences the former producers using it and aborts their transaction
+func (t *claimTransaction) initProducerID() error {
+	return t.retry(func() error {
+		coordinator, err := t.transactionCoordinator()
+		if err != nil {
+			return err
+		}
+		res, err := coordinator.InitProducerID(&InitProducerIDRequest{
+			TransactionalID:    &t.transactionalID,
+			TransactionTimeout: t.conf.TransactionTimeout,
+		})
+		if err != nil {
+			return err
+		}
+		if res.Err != ErrNoError {
+			return res.Err
+		}
+		t.producerID, t.producerEpoch = res.ProducerID, res.ProducerEpoch
+		logEntry(LogComponentProducer, LogLevelInfo, "exactlyonce/producer initialized", groupField(t.groupID),
+			topicField(t.topic), partitionField(t.partition),
+			LogField{Key: "producer_id", Value: t.producerID}, LogField{Key: "producer_epoch", Value: t.producerEpoch})
+		return nil
+	})
+}
+
+// run processes msgs in a transaction, which is aborted when it fails unless
+// the producer was fenced
+func (t *claimTransaction) run(msgs []*ConsumerMessage) error {
+	t.partitions = make(map[string][]int32)
+	err := t.process(msgs)
+	if err == nil {
+		return t.end(true)
+	}
+
+	if t.ongoing && !isFencedProducer(err) {
+		if abortErr := t.end(false); abortErr != nil {
+			logEntry(LogComponentProducer, LogLevelWarn, "exactlyonce/abort failed", groupField(t.groupID),
+				topicField(t.topic), partitionField(t.partition), errorField(abortErr))
+		}
+	}
+	return err
+}
+
+func (t *claimTransaction) process(msgs []*ConsumerMessage) error {
+	conf := t.client.Config()
+
+	var produced []*ProducerMessage
+	for _, msg := range msgs {
+		out, err := t.transform(msg)
+		if err != nil {
+			return err
+		}
+		for _, m := range out {
+			if err := m.serialize(conf); err != nil {
+				return err
+			}
+			if err := t.assignPartition(m); err != nil {
+				return err
+			}
+		}
+		produced = append(produced, out...)
+	}
+
+	// the offsets are added first, which starts the transaction even when
+	// nothing is produced
+	if err := t.addOffsets(); err != nil {
+		return err
+	}
+	if err := t.addPartitions(produced); err != nil {
+		return err
+	}
+	if err := t.produce(produced); err != nil {
+		return err
+	}
+	return t.commitOffset(msgs[len(msgs)-1].Offset + 1)
+}
+
+func (t *claimTransaction) assignPartition(msg *ProducerMessage) error {
+	partitioner := t.partitioners[msg.Topic]
+	if partitioner == nil {
+		partitioner = t.client.Config().Producer.Partitioner(msg.Topic)
+		t.partitioners[msg.Topic] = partitioner
+	}
+
+	partitions, err := t.client.Partitions(msg.Topic)
+	if err != nil {
+		return err
+	}
+	if len(partitions) == 0 {
+		return ErrLeaderNotAvailable
+	}
+	choice, err := partitioner.Partition(msg, int32(len(partitions)))
+	if err != nil {
+		return err
+	}
+	if choice < 0 || choice >= int32(len(partitions)) {
+		return ErrInvalidPartition
+	}
+	msg.Partition = partitions[choice]
+	return nil
+}
+
+func (t *claimTransaction) addOffsets() error {
+	return t.retry(func() error {
+		coordinator, err := t.transactionCoordinator()
+		if err != nil {
+			return err
+		}
+		res, err := coordinator.AddOffsetsToTxn(&AddOffsetsToTxnRequest{
+			TransactionalID: t.transactionalID,
+			ProducerID:      t.producerID,
+			ProducerEpoch:   t.producerEpoch,
+			GroupID:         t.groupID,
+		})
+		if err != nil {
+			return err
+		}
+		if res.Err != ErrNoError {
+			return res.Err
+		}
+		t.ongoing = true
+		return nil
+	})
+}
+
+// addPartitions adds the partitions of msgs to the transaction
+func (t *claimTransaction) addPartitions(msgs []*ProducerMessage) error {
+	added := make(map[string][]int32)
+	for _, msg := range msgs {
+		if !int32SliceContains(t.partitions[msg.Topic], msg.Partition) && !int32SliceContains(added[msg.Topic], msg.Partition) {
+			added[msg.Topic] = append(added[msg.Topic], msg.Partition)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	return t.retry(func() error {
+		coordinator, err := t.transactionCoordinator()
+		if err != nil {
+			return err
+		}
+		res, err := coordinator.AddPartitionsToTxn(&AddPartitionsToTxnRequest{
+			TransactionalID: t.transactionalID,
+			ProducerID:      t.producerID,
+			ProducerEpoch:   t.producerEpoch,
+			TopicPartitions: added,
+		})
+		if err != nil {
+			return err
+		}
+		for _, partitions := range res.Errors {
+			for _, partition := range partitions {
+				if partition.Err != ErrNoError {
+					return partition.Err
+				}
+			}
+		}
+		for topic, partitions := range added {
+			t.partitions[topic] = append(t.partitions[topic], partitions...)
+		}
+		return nil
+	})
+}
+
+// produce sends the transactional batches of msgs to the leaders of their
+// partitions, the batches failing with retriable errors being sent again
+// with the same sequence numbers
+func (t *claimTransaction) produce(msgs []*ProducerMessage) error {
+	batches := make(map[string]map[int32]*RecordBatch)
+	for _, msg := range msgs {
+		if batches[msg.Topic] == nil {
+			batches[msg.Topic] = make(map[int32]*RecordBatch)
+		}
+		batch := batches[msg.Topic][msg.Partition]
+		if batch == nil {
+			batch = t.newBatch(msg)
+			batches[msg.Topic][msg.Partition] = batch
+		}
+		if err := addBatchRecord(batch, msg); err != nil {
+			return err
+		}
+	}
+
+	return t.retry(func() error {
+		requests := make(map[*Broker]*ProduceRequest)
+		for topic, partitions := range batches {
+			for partition, batch := range partitions {
+				leader, err := t.client.Leader(topic, partition)
+				if err != nil {
+					return err
+				}
+				request := requests[leader]
+				if request == nil {
+					request = t.newProduceRequest()
+					requests[leader] = request
+				}
+				request.AddBatch(topic, partition, batch)
+			}
+		}
+
+		var retriable error
+		for leader, request := range requests {
+			res, err := leader.Produce(request)
+			if err != nil {
+				return err
+			}
+			for topic, partitions := range request.records {
+				for partition := range partitions {
+					block := res.GetBlock(topic, partition)
+					if block == nil {
+						return ErrIncompleteResponse
+					}
+					switch {
+					case block.Err == ErrNoError, block.Err == ErrDuplicateSequenceNumber:
+						t.sequences[topic][partition] += int32(len(batches[topic][partition].Records))
+						delete(batches[topic], partition)
+					case IsRetriable(block.Err):
+						retriable = block.Err
+						_ = t.client.RefreshMetadata(topic)
+					default:
+						return block.Err
+					}
+				}
+			}
+		}
+		return retriable
+	})
+}
+
+func (t *claimTransaction) newProduceRequest() *ProduceRequest {
+	conf := t.client.Config()
+	request := &ProduceRequest{
+		TransactionalID: &t.transactionalID,
+		RequiredAcks:    WaitForAll,
+		Timeout:         int32(conf.Producer.Timeout / time.Millisecond),
+		Version:         3,
+	}
+	if conf.Producer.Compression == CompressionZSTD && conf.Version.IsAtLeast(V2_1_0_0) {
+		request.Version = 7
+	}
+	return request
+}
+
+// newBatch returns the transactional batch of the partition of msg
+func (t *claimTransaction) newBatch(msg *ProducerMessage) *RecordBatch {
+	conf := t.client.Config()
+	if t.sequences[msg.Topic] == nil {
+		t.sequences[msg.Topic] = make(map[int32]int32)
+	}
+	timestamp := msg.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	return &RecordBatch{
+		FirstTimestamp:   timestamp.Truncate(time.Millisecond),
+		Version:          2,
+		Codec:            conf.Producer.Compression,
+		CompressionLevel: conf.Producer.CompressionLevel,
+		ProducerID:       t.producerID,
+		ProducerEpoch:    t.producerEpoch,
+		FirstSequence:    t.sequences[msg.Topic][msg.Partition],
+		IsTransactional:  true,
+
+		compressionConcurrency: conf.Producer.CompressionConcurrency,
+	}
+}
+
+// addBatchRecord adds the record of msg to batch
+func addBatchRecord(batch *RecordBatch, msg *ProducerMessage) error {
+	var err error
+	rec := &Record{OffsetDelta: int64(len(batch.Records))}
+	if msg.Key != nil {
+		if rec.Key, err = msg.Key.Encode(); err != nil {
+			return err
+		}
+	}
+	if msg.Value != nil {
+		if rec.Value, err = msg.Value.Encode(); err != nil {
+			return err
+		}
+	}
+	if !msg.Timestamp.IsZero() {
+		rec.TimestampDelta = msg.Timestamp.Truncate(time.Millisecond).Sub(batch.FirstTimestamp)
+	}
+	for i := range msg.Headers {
+		rec.Headers = append(rec.Headers, &msg.Headers[i])
+	}
+	batch.addRecord(rec)
+	batch.LastOffsetDelta = int32(len(batch.Records) - 1)
+	return nil
+}
+
+// commitOffset commits the offset of the claim in the transaction
+func (t *claimTransaction) commitOffset(offset int64) error {
+	return t.retry(func() error {
+		coordinator, err := t.client.Coordinator(t.groupID)
+		if err != nil {
+			return err
+		}
+		res, err := coordinator.TxnOffsetCommit(&TxnOffsetCommitRequest{
+			TransactionalID: t.transactionalID,
+			GroupID:         t.groupID,
+			ProducerID:      t.producerID,
+			ProducerEpoch:   t.producerEpoch,
+			Topics: map[string][]*PartitionOffsetMetadata{
+				t.topic: {{Partition: t.partition, Offset: offset}},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		for _, partitions := range res.Topics {
+			for _, partition := range partitions {
+				if partition.Err == ErrNotCoordinatorForConsumer || partition.Err == ErrConsumerCoordinatorNotAvailable {
+					_ = t.client.RefreshCoordinator(t.groupID)
+				}
+				if partition.Err != ErrNoError {
+					return partition.Err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// end commits or aborts the ongoing transaction
+func (t *claimTransaction) end(commit bool) error {
+	err := t.retry(func() error {
+		coordinator, err := t.transactionCoordinator()
+		if err != nil {
+			return err
+		}
+		res, err := coordinator.EndTxn(&EndTxnRequest{
+			TransactionalID:   t.transactionalID,
+			ProducerID:        t.producerID,
+			ProducerEpoch:     t.producerEpoch,
+			TransactionResult: commit,
+		})
+		if err != nil {
+			return err
+		}
+		if res.Err != ErrNoError {
+			return res.Err
+		}
+		return nil
+	})
+	t.ongoing, t.partitions = false, nil
+	return err
+}
+
+// isFencedProducer returns whether err means that a newer producer uses the
+// transactional ID, in which case the transaction can not be aborted
+func isFencedProducer(err error) bool {
+	switch err {
+	case ErrProducerFenced, ErrInvalidProducerEpoch, ErrTransactionCoordinatorFenced:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package sarama
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newExactlyOnceTestHandlers returns the handlers of a MockBroker consuming
+// the messages 5 and 6 of my-topic/0 for my-group, leading out-topic/0 and
+// coordinating the transactions of txn
+func newExactlyOnceTestHandlers(t *testing.T, broker *MockBroker, txn *MockTransactionCoordinator, produce MockResponse) map[string]MockResponse {
+	return withHandlers(newConsumerGroupTestHandlers(t, broker, 7), map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("my-topic", 0, broker.BrokerID()).
+			SetLeader("out-topic", 0, broker.BrokerID()),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "my-group", broker).
+			SetCoordinator(CoordinatorTransaction, "my-app-my-topic-0", broker),
+		"FetchRequest": NewMockFetchResponse(t, 1).
+			SetVersion(7).
+			SetMessage("my-topic", 0, 5, StringEncoder("hello")).
+			SetMessage("my-topic", 0, 6, StringEncoder("world")),
+		"ProduceRequest":            produce,
+		"InitProducerIDRequest":     txn,
+		"AddPartitionsToTxnRequest": txn,
+		"AddOffsetsToTxnRequest":    txn,
+		"TxnOffsetCommitRequest":    txn,
+		"EndTxnRequest":             txn,
+	})
+}
+
+func newExactlyOnceTestHandler(t *testing.T, client Client) ConsumerGroupHandler {
+	handler, err := NewExactlyOnceHandler(client, "my-group", ExactlyOnceConfig{TransactionalID: "my-app"},
+		func(msg *ConsumerMessage) ([]*ProducerMessage, error) {
+			return []*ProducerMessage{{Topic: "out-topic", Value: StringEncoder(strings.ToUpper(string(msg.Value)))}}, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handler
+}
+
+func TestExactlyOnceHandler(t *testing.T) {
+	broker := NewMockBroker(t, 0)
+	defer broker.Close()
+	txn := NewMockTransactionCoordinator(t)
+	broker.SetHandlerByMap(newExactlyOnceTestHandlers(t, broker, txn, NewMockProduceResponse(t).SetVersion(3)))
+
+	config := newConsumerGroupTestConfig()
+	client, err := NewClient([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+	group, err := NewConsumerGroupFromClient("my-group", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := consumeInBackground(func() error {
+		return group.Consume(ctx, []string{"my-topic"}, newExactlyOnceTestHandler(t, client))
+	})
+
+	// the offset after the message 6 is committed by a transaction
+	deadline := time.Now().Add(5 * time.Second)
+	for txn.CommittedOffsets("my-group")["my-topic"][0] != 7 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the offset 7 to be committed, got %v", txn.CommittedOffsets("my-group"))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+
+	state, ok := txn.Transaction("my-app-my-topic-0")
+	if !ok || state.State != "CompleteCommit" || state.Commits == 0 || state.Aborts != 0 {
+		t.Errorf("Expected the transactions to be committed, got %+v", state)
+	}
+
+	var values []string
+	var sequence int32
+	for _, rr := range broker.History() {
+		req, ok := rr.Request.(*ProduceRequest)
+		if !ok {
+			continue
+		}
+		if req.TransactionalID == nil || *req.TransactionalID != "my-app-my-topic-0" || req.RequiredAcks != WaitForAll {
+			t.Errorf("Expected a transactional request acknowledged by all the replicas, got %+v", req)
+		}
+		batch := req.records["out-topic"][0].RecordBatch
+		if !batch.IsTransactional || batch.ProducerID != state.ProducerID || batch.FirstSequence != sequence {
+			t.Errorf("Expected the transactional batch of the sequence %d of producer %d, got %+v", sequence, state.ProducerID, batch)
+		}
+		sequence += int32(len(batch.Records))
+		for _, record := range batch.Records {
+			values = append(values, string(record.Value))
+		}
+	}
+	if strings.Join(values, " ") != "HELLO WORLD" {
+		t.Errorf("Expected the transformed messages to be produced once, got %v", values)
+	}
+	for _, rr := range broker.History() {
+		if _, ok := rr.Request.(*OffsetCommitRequest); ok {
+			t.Error("Expected the offsets to be committed by the transactions only")
+		}
+	}
+}
+
+func TestExactlyOnceHandlerFenced(t *testing.T) {
+	broker := NewMockBroker(t, 0)
+	defer broker.Close()
+	txn := NewMockTransactionCoordinator(t).SetError("EndTxnRequest", ErrProducerFenced)
+	broker.SetHandlerByMap(newExactlyOnceTestHandlers(t, broker, txn, NewMockProduceResponse(t).SetVersion(3)))
+
+	config := newConsumerGroupTestConfig()
+	client, err := NewClient([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+	group, err := NewConsumerGroupFromClient("my-group", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	done := consumeInBackground(func() error {
+		return group.Consume(context.Background(), []string{"my-topic"}, newExactlyOnceTestHandler(t, client))
+	})
+	select {
+	case err := <-group.Errors():
+		if !errors.Is(err, ErrProducerFenced) {
+			t.Errorf("Expected ErrProducerFenced, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the fenced producer to be reported")
+	}
+	// the claim ends the session
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+
+	// the fenced producer does not abort the transaction it no longer owns
+	if state, _ := txn.Transaction("my-app-my-topic-0"); state.State != "Ongoing" || state.Aborts != 0 {
+		t.Errorf("Expected the transaction to be left to the new producer, got %+v", state)
+	}
+	if offsets := txn.CommittedOffsets("my-group"); len(offsets) != 0 {
+		t.Errorf("Expected no offset to be committed, got %v", offsets)
+	}
+}
+
+func TestExactlyOnceHandlerAbort(t *testing.T) {
+	broker := NewMockBroker(t, 0)
+	defer broker.Close()
+	txn := NewMockTransactionCoordinator(t)
+	broker.SetHandlerByMap(newExactlyOnceTestHandlers(t, broker, txn,
+		NewMockProduceResponse(t).SetVersion(3).SetError("out-topic", 0, ErrMessageSizeTooLarge)))
+
+	config := newConsumerGroupTestConfig()
+	client, err := NewClient([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+	group, err := NewConsumerGroupFromClient("my-group", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	done := consumeInBackground(func() error {
+		return group.Consume(context.Background(), []string{"my-topic"}, newExactlyOnceTestHandler(t, client))
+	})
+	select {
+	case err := <-group.Errors():
+		if !errors.Is(err, ErrMessageSizeTooLarge) {
+			t.Errorf("Expected ErrMessageSizeTooLarge, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the failed transaction to be reported")
+	}
+	if err := waitConsumed(t, done); err != nil {
+		t.Fatal(err)
+	}
+
+	if state, _ := txn.Transaction("my-app-my-topic-0"); state.State != "CompleteAbort" || state.Aborts != 1 || state.Commits != 0 {
+		t.Errorf("Expected the transaction to be aborted, got %+v", state)
+	}
+	if offsets := txn.CommittedOffsets("my-group"); len(offsets) != 0 {
+		t.Errorf("Expected no offset to be committed, got %v", offsets)
+	}
+}
+
+func TestNewExactlyOnceHandlerValidation(t *testing.T) {
+	transform := func(*ConsumerMessage) ([]*ProducerMessage, error) { return nil, nil }
+	for _, tt := range []struct {
+		name      string
+		version   KafkaVersion
+		conf      ExactlyOnceConfig
+		transform ExactlyOnceTransform
+		err       string
+	}{
+		{"Version", V0_10_2_0, ExactlyOnceConfig{TransactionalID: "my-app"}, transform, "exactly-once handlers require Version >= V0_11_0_0"},
+		{"TransactionalID", V2_0_0_0, ExactlyOnceConfig{}, transform, "ExactlyOnceConfig.TransactionalID must not be empty"},
+		{"TransactionTimeout", V2_0_0_0, ExactlyOnceConfig{TransactionalID: "my-app", TransactionTimeout: -1}, transform, "ExactlyOnceConfig.TransactionTimeout must be >= 0"},
+		{"MaxMessages", V2_0_0_0, ExactlyOnceConfig{TransactionalID: "my-app", MaxMessages: -1}, transform, "ExactlyOnceConfig.MaxMessages must be >= 0"},
+		{"Transform", V2_0_0_0, ExactlyOnceConfig{TransactionalID: "my-app"}, nil, "exactly-once handlers require a transform"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewTestConfig()
+			config.Version = tt.version
+			client := &nopCloserClient{Client: &client{conf: config}}
+			_, err := NewExactlyOnceHandler(client, "my-group", tt.conf, tt.transform)
+			if err == nil || err.Error() != ConfigurationError(tt.err).Error() {
+				t.Errorf("Expected %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
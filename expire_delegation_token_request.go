@@ -0,0 +1,65 @@
+package sarama
+
+import "time"
+
+// ExpireDelegationTokenRequest expires a delegation token, or changes the time it
+// expires at.
+type ExpireDelegationTokenRequest struct {
+	Version int16
+	// HMAC identifies the token
+	HMAC []byte
+	// ExpiryPeriod sets the expiry time of the token to the given period from
+	// now, -1 expiring the token immediately
+	ExpiryPeriod time.Duration
+}
+
+func (r *ExpireDelegationTokenRequest) encode(pe packetEncoder) error {
+	if err := pe.putBytes(r.HMAC); err != nil {
+		return err
+	}
+
+	period := int64(-1)
+	if r.ExpiryPeriod >= 0 {
+		period = int64(r.ExpiryPeriod / time.Millisecond)
+	}
+	pe.putInt64(period)
+	return nil
+}
+
+func (r *ExpireDelegationTokenRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.HMAC, err = pd.getBytes(); err != nil {
+		return err
+	}
+
+	period, err := pd.getInt64()
+	if err != nil {
+		return err
+	}
+	r.ExpiryPeriod = -1
+	if period >= 0 {
+		r.ExpiryPeriod = time.Duration(period) * time.Millisecond
+	}
+	return nil
+}
+
+func (r *ExpireDelegationTokenRequest) key() int16 {
+	return 40
+}
+
+func (r *ExpireDelegationTokenRequest) version() int16 {
+	return r.Version
+}
+
+func (r *ExpireDelegationTokenRequest) headerVersion() int16 {
+	return 1
+}
+
+func (r *ExpireDelegationTokenRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V2_0_0_0
+	default:
+		return V1_1_0_0
+	}
+}
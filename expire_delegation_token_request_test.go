@@ -0,0 +1,16 @@
+package sarama
+
+import "testing"
+
+var expireDelegationTokenRequestNow = []byte{
+	0, 0, 0, 4, 'h', 'm', 'a', 'c',
+	255, 255, 255, 255, 255, 255, 255, 255, // expire immediately
+}
+
+func TestExpireDelegationTokenRequest(t *testing.T) {
+	request := &ExpireDelegationTokenRequest{
+		HMAC:         []byte("hmac"),
+		ExpiryPeriod: -1,
+	}
+	testRequest(t, "expire now", request, expireDelegationTokenRequestNow)
+}
@@ -0,0 +1,67 @@
+package sarama
+
+import "time"
+
+// ExpireDelegationTokenResponse is the response to a
+// ExpireDelegationTokenRequest.
+type ExpireDelegationTokenResponse struct {
+	Version    int16
+	Err        KError
+	ExpiryTime time.Time
+	// ThrottleTime is applied before sending the response from version 1
+	ThrottleTime time.Duration
+}
+
+func (r *ExpireDelegationTokenResponse) encode(pe packetEncoder) error {
+	pe.putInt16(int16(r.Err))
+	if err := (Timestamp{&r.ExpiryTime}).encode(pe); err != nil {
+		return err
+	}
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	return nil
+}
+
+func (r *ExpireDelegationTokenResponse) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
+
+	if err := (Timestamp{&r.ExpiryTime}).decode(pd); err != nil {
+		return err
+	}
+
+	throttleTime, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+	return nil
+}
+
+func (r *ExpireDelegationTokenResponse) key() int16 {
+	return 40
+}
+
+func (r *ExpireDelegationTokenResponse) version() int16 {
+	return r.Version
+}
+
+func (r *ExpireDelegationTokenResponse) headerVersion() int16 {
+	return 0
+}
+
+func (r *ExpireDelegationTokenResponse) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V2_0_0_0
+	default:
+		return V1_1_0_0
+	}
+}
+
+func (r *ExpireDelegationTokenResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
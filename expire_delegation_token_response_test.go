@@ -0,0 +1,16 @@
+package sarama
+
+import "testing"
+
+var expireDelegationTokenResponseNotFound = []byte{
+	0, 62, // ErrDelegationTokenNotFound
+	255, 255, 255, 255, 255, 255, 255, 255, // no expiry timestamp
+	0, 0, 0, 0, // throttle time
+}
+
+func TestExpireDelegationTokenResponse(t *testing.T) {
+	response := &ExpireDelegationTokenResponse{
+		Err: ErrDelegationTokenNotFound,
+	}
+	testResponse(t, "not found", response, expireDelegationTokenResponseNotFound)
+}
@@ -4,6 +4,7 @@ type fetchRequestBlock struct {
 	Version            int16
 	currentLeaderEpoch int32
 	fetchOffset        int64
+	lastFetchedEpoch   int32
 	logStartOffset     int64
 	maxBytes           int32
 }
@@ -14,10 +15,16 @@ func (b *fetchRequestBlock) encode(pe packetEncoder, version int16) error {
 		pe.putInt32(b.currentLeaderEpoch)
 	}
 	pe.putInt64(b.fetchOffset)
+	if b.Version >= 12 {
+		pe.putInt32(b.lastFetchedEpoch)
+	}
 	if b.Version >= 5 {
 		pe.putInt64(b.logStartOffset)
 	}
 	pe.putInt32(b.maxBytes)
+	if b.Version >= 12 {
+		pe.putEmptyTaggedFieldArray()
+	}
 	return nil
 }
 
@@ -31,6 +38,11 @@ func (b *fetchRequestBlock) decode(pd packetDecoder, version int16) (err error)
 	if b.fetchOffset, err = pd.getInt64(); err != nil {
 		return err
 	}
+	if b.Version >= 12 {
+		if b.lastFetchedEpoch, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
 	if b.Version >= 5 {
 		if b.logStartOffset, err = pd.getInt64(); err != nil {
 			return err
@@ -39,12 +51,21 @@ func (b *fetchRequestBlock) decode(pd packetDecoder, version int16) (err error)
 	if b.maxBytes, err = pd.getInt32(); err != nil {
 		return err
 	}
+	if b.Version >= 12 {
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // FetchRequest (API key 1) will fetch Kafka messages. Version 3 introduced the MaxBytes field. See
 // https://issues.apache.org/jira/browse/KAFKA-2063 for a discussion of the issues leading up to that.  The KIP is at
 // https://cwiki.apache.org/confluence/display/KAFKA/KIP-74%3A+Add+Fetch+Response+Size+Limit+in+Bytes
+//
+// From version 13 the topics are identified by their IDs rather than their
+// names (KIP-516), which must be set in TopicIDs for all the topics of the
+// request.
 type FetchRequest struct {
 	MaxWaitTime  int32
 	MinBytes     int32
@@ -56,6 +77,9 @@ type FetchRequest struct {
 	blocks       map[string]map[int32]*fetchRequestBlock
 	forgotten    map[string][]int32
 	RackID       string
+	// TopicIDs maps the names of the topics to their IDs, only used from
+	// Version 13
+	TopicIDs map[string]Uuid
 }
 
 type IsolationLevel int8
@@ -66,6 +90,8 @@ const (
 )
 
 func (r *FetchRequest) encode(pe packetEncoder) (err error) {
+	isFlexible := r.Version >= 12
+
 	pe.putInt32(-1) // replica ID is always -1 for clients
 	pe.putInt32(r.MaxWaitTime)
 	pe.putInt32(r.MinBytes)
@@ -79,17 +105,18 @@ func (r *FetchRequest) encode(pe packetEncoder) (err error) {
 		pe.putInt32(r.SessionID)
 		pe.putInt32(r.SessionEpoch)
 	}
-	err = pe.putArrayLength(len(r.blocks))
-	if err != nil {
+	if isFlexible {
+		pe.putCompactArrayLength(len(r.blocks))
+	} else if err = pe.putArrayLength(len(r.blocks)); err != nil {
 		return err
 	}
 	for topic, blocks := range r.blocks {
-		err = pe.putString(topic)
-		if err != nil {
+		if err = r.encodeTopic(pe, topic); err != nil {
 			return err
 		}
-		err = pe.putArrayLength(len(blocks))
-		if err != nil {
+		if isFlexible {
+			pe.putCompactArrayLength(len(blocks))
+		} else if err = pe.putArrayLength(len(blocks)); err != nil {
 			return err
 		}
 		for partition, block := range blocks {
@@ -99,38 +126,68 @@ func (r *FetchRequest) encode(pe packetEncoder) (err error) {
 				return err
 			}
 		}
+		if isFlexible {
+			pe.putEmptyTaggedFieldArray()
+		}
 	}
 	if r.Version >= 7 {
-		err = pe.putArrayLength(len(r.forgotten))
-		if err != nil {
+		if isFlexible {
+			pe.putCompactArrayLength(len(r.forgotten))
+		} else if err = pe.putArrayLength(len(r.forgotten)); err != nil {
 			return err
 		}
 		for topic, partitions := range r.forgotten {
-			err = pe.putString(topic)
-			if err != nil {
+			if err = r.encodeTopic(pe, topic); err != nil {
 				return err
 			}
-			err = pe.putArrayLength(len(partitions))
+			if isFlexible {
+				err = pe.putCompactInt32Array(partitions)
+			} else {
+				err = pe.putInt32Array(partitions)
+			}
 			if err != nil {
 				return err
 			}
-			for _, partition := range partitions {
-				pe.putInt32(partition)
+			if isFlexible {
+				pe.putEmptyTaggedFieldArray()
 			}
 		}
 	}
 	if r.Version >= 11 {
-		err = pe.putString(r.RackID)
+		if isFlexible {
+			err = pe.putCompactString(r.RackID)
+		} else {
+			err = pe.putString(r.RackID)
+		}
 		if err != nil {
 			return err
 		}
 	}
+	if isFlexible {
+		pe.putEmptyTaggedFieldArray()
+	}
 
 	return nil
 }
 
+func (r *FetchRequest) encodeTopic(pe packetEncoder, topic string) error {
+	switch {
+	case r.Version >= 13:
+		topicID, ok := r.TopicIDs[topic]
+		if !ok {
+			return PacketEncodingError{"missing the ID of topic " + topic}
+		}
+		return pe.putRawBytes(topicID[:])
+	case r.Version >= 12:
+		return pe.putCompactString(topic)
+	default:
+		return pe.putString(topic)
+	}
+}
+
 func (r *FetchRequest) decode(pd packetDecoder, version int16) (err error) {
 	r.Version = version
+	isFlexible := r.Version >= 12
 
 	if _, err = pd.getInt32(); err != nil {
 		return err
@@ -163,20 +220,19 @@ func (r *FetchRequest) decode(pd packetDecoder, version int16) (err error) {
 			return err
 		}
 	}
-	topicCount, err := pd.getArrayLength()
+	topicCount, err := r.decodeArrayLength(pd)
 	if err != nil {
 		return err
 	}
-	if topicCount == 0 {
-		return nil
+	if topicCount > 0 {
+		r.blocks = make(map[string]map[int32]*fetchRequestBlock)
 	}
-	r.blocks = make(map[string]map[int32]*fetchRequestBlock)
 	for i := 0; i < topicCount; i++ {
-		topic, err := pd.getString()
+		topic, err := r.decodeTopic(pd)
 		if err != nil {
 			return err
 		}
-		partitionCount, err := pd.getArrayLength()
+		partitionCount, err := r.decodeArrayLength(pd)
 		if err != nil {
 			return err
 		}
@@ -192,45 +248,99 @@ func (r *FetchRequest) decode(pd packetDecoder, version int16) (err error) {
 			}
 			r.blocks[topic][partition] = fetchBlock
 		}
+		if isFlexible {
+			if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+		}
 	}
 
 	if r.Version >= 7 {
-		forgottenCount, err := pd.getArrayLength()
+		forgottenCount, err := r.decodeArrayLength(pd)
 		if err != nil {
 			return err
 		}
 		r.forgotten = make(map[string][]int32)
 		for i := 0; i < forgottenCount; i++ {
-			topic, err := pd.getString()
+			topic, err := r.decodeTopic(pd)
 			if err != nil {
 				return err
 			}
-			partitionCount, err := pd.getArrayLength()
+			var partitions []int32
+			if isFlexible {
+				partitions, err = pd.getCompactInt32Array()
+			} else {
+				partitions, err = pd.getInt32Array()
+			}
 			if err != nil {
 				return err
 			}
-			r.forgotten[topic] = make([]int32, partitionCount)
-
-			for j := 0; j < partitionCount; j++ {
-				partition, err := pd.getInt32()
-				if err != nil {
+			if partitions == nil {
+				partitions = []int32{}
+			}
+			r.forgotten[topic] = partitions
+			if isFlexible {
+				if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
 					return err
 				}
-				r.forgotten[topic][j] = partition
 			}
 		}
 	}
 
 	if r.Version >= 11 {
-		r.RackID, err = pd.getString()
+		if isFlexible {
+			r.RackID, err = pd.getCompactString()
+		} else {
+			r.RackID, err = pd.getString()
+		}
 		if err != nil {
 			return err
 		}
 	}
+	if isFlexible {
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
+func (r *FetchRequest) decodeArrayLength(pd packetDecoder) (n int, err error) {
+	if r.Version >= 12 {
+		n, err = pd.getCompactArrayLength()
+	} else {
+		n, err = pd.getArrayLength()
+	}
+	if n == -1 {
+		n = 0
+	}
+	return n, err
+}
+
+// decodeTopic returns the name of the topic, or the string of its ID from
+// version 13, in which case the ID is also set in TopicIDs
+func (r *FetchRequest) decodeTopic(pd packetDecoder) (string, error) {
+	switch {
+	case r.Version >= 13:
+		raw, err := pd.getRawBytes(16)
+		if err != nil {
+			return "", err
+		}
+		var topicID Uuid
+		copy(topicID[:], raw)
+		if r.TopicIDs == nil {
+			r.TopicIDs = make(map[string]Uuid)
+		}
+		r.TopicIDs[topicID.String()] = topicID
+		return topicID.String(), nil
+	case r.Version >= 12:
+		return pd.getCompactString()
+	default:
+		return pd.getString()
+	}
+}
+
 func (r *FetchRequest) key() int16 {
 	return 1
 }
@@ -240,6 +350,9 @@ func (r *FetchRequest) version() int16 {
 }
 
 func (r *FetchRequest) headerVersion() int16 {
+	if r.Version >= 12 {
+		return 2
+	}
 	return 1
 }
 
@@ -265,6 +378,10 @@ func (r *FetchRequest) requiredVersion() KafkaVersion {
 		return V2_1_0_0
 	case 11:
 		return V2_3_0_0
+	case 12:
+		return V2_7_0_0
+	case 13:
+		return V3_1_0_0
 	default:
 		return MaxVersion
 	}
@@ -290,6 +407,9 @@ func (r *FetchRequest) AddBlock(topic string, partitionID int32, fetchOffset int
 	if r.Version >= 9 {
 		tmp.currentLeaderEpoch = int32(-1)
 	}
+	if r.Version >= 12 {
+		tmp.lastFetchedEpoch = int32(-1)
+	}
 
 	r.blocks[topic][partitionID] = tmp
 }
@@ -48,6 +48,51 @@ var (
 		0x00, 0x00, 0x00, 0x00,
 		0x00, 0x06, 'r', 'a', 'c', 'k', '0', '1', // rackID
 	}
+
+	fetchRequestOneBlockV12 = []byte{
+		0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0xFF,
+		0x01,
+		0x00, 0x00, 0x00, 0xAA, // sessionID
+		0x00, 0x00, 0x00, 0xEE, // sessionEpoch
+		0x02,
+		0x06, 't', 'o', 'p', 'i', 'c',
+		0x02,
+		0x00, 0x00, 0x00, 0x12, // partitionID
+		0xFF, 0xFF, 0xFF, 0xFF, // currentLeaderEpoch
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x34, // fetchOffset
+		0xFF, 0xFF, 0xFF, 0xFF, // lastFetchedEpoch
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // logStartOffset
+		0x00, 0x00, 0x00, 0x56, // maxBytes
+		0x00,                               // partition tagged fields
+		0x00,                               // topic tagged fields
+		0x01,                               // forgotten topics
+		0x07, 'r', 'a', 'c', 'k', '0', '1', // rackID
+		0x00, // tagged fields
+	}
+
+	fetchRequestOneBlockV13 = []byte{
+		0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0xFF,
+		0x01,
+		0x00, 0x00, 0x00, 0xAA, // sessionID
+		0x00, 0x00, 0x00, 0xEE, // sessionEpoch
+		0x02,
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, // topicID
+		0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F, 0x10,
+		0x02,
+		0x00, 0x00, 0x00, 0x12, // partitionID
+		0xFF, 0xFF, 0xFF, 0xFF, // currentLeaderEpoch
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x34, // fetchOffset
+		0xFF, 0xFF, 0xFF, 0xFF, // lastFetchedEpoch
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // logStartOffset
+		0x00, 0x00, 0x00, 0x56, // maxBytes
+		0x00,                               // partition tagged fields
+		0x00,                               // topic tagged fields
+		0x01,                               // forgotten topics
+		0x07, 'r', 'a', 'c', 'k', '0', '1', // rackID
+		0x00, // tagged fields
+	}
 )
 
 func TestFetchRequest(t *testing.T) {
@@ -91,4 +136,40 @@ func TestFetchRequest(t *testing.T) {
 		request.RackID = "rack01"
 		testRequest(t, "one block v11 rackid", request, fetchRequestOneBlockV11)
 	})
+
+	t.Run("one block v12", func(t *testing.T) {
+		request := new(FetchRequest)
+		request.Version = 12
+		request.MaxBytes = 0xFF
+		request.Isolation = ReadCommitted
+		request.SessionID = 0xAA
+		request.SessionEpoch = 0xEE
+		request.AddBlock("topic", 0x12, 0x34, 0x56)
+		request.RackID = "rack01"
+		testRequest(t, "one block v12", request, fetchRequestOneBlockV12)
+	})
+
+	t.Run("one block v13 topic ID", func(t *testing.T) {
+		topicID := Uuid{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+		request := new(FetchRequest)
+		request.Version = 13
+		request.MaxBytes = 0xFF
+		request.Isolation = ReadCommitted
+		request.SessionID = 0xAA
+		request.SessionEpoch = 0xEE
+		// decoded topics are named after their ID
+		request.AddBlock(topicID.String(), 0x12, 0x34, 0x56)
+		request.TopicIDs = map[string]Uuid{topicID.String(): topicID}
+		request.RackID = "rack01"
+		testRequest(t, "one block v13 topic ID", request, fetchRequestOneBlockV13)
+	})
+
+	t.Run("v13 missing topic ID", func(t *testing.T) {
+		request := new(FetchRequest)
+		request.Version = 13
+		request.AddBlock("topic", 0x12, 0x34, 0x56)
+		if _, err := encode(request, nil); err == nil {
+			t.Error("Expected an error encoding a topic without ID")
+		}
+	})
 }
@@ -29,6 +29,89 @@ func (t *AbortedTransaction) encode(pe packetEncoder) (err error) {
 	return nil
 }
 
+// The tags of the tagged fields of the partitions of the version 12 response
+const (
+	fetchResponseDivergingEpochTag = 0
+	fetchResponseCurrentLeaderTag  = 1
+	fetchResponseSnapshotIDTag     = 2
+)
+
+// EpochEndOffset is the end offset of a leader epoch. It is returned as the
+// DivergingEpoch of a partition when the log of the replica has diverged from
+// the one of the leader.
+type EpochEndOffset struct {
+	Epoch     int32
+	EndOffset int64
+}
+
+func (e *EpochEndOffset) encode(pe packetEncoder) error {
+	pe.putInt32(e.Epoch)
+	pe.putInt64(e.EndOffset)
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (e *EpochEndOffset) decode(pd packetDecoder) (err error) {
+	if e.Epoch, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if e.EndOffset, err = pd.getInt64(); err != nil {
+		return err
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+// LeaderIDAndEpoch is the current leader of a partition, returned when the
+// broker is not the leader anymore (KIP-951).
+type LeaderIDAndEpoch struct {
+	LeaderID    int32
+	LeaderEpoch int32
+}
+
+func (l *LeaderIDAndEpoch) encode(pe packetEncoder) error {
+	pe.putInt32(l.LeaderID)
+	pe.putInt32(l.LeaderEpoch)
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (l *LeaderIDAndEpoch) decode(pd packetDecoder) (err error) {
+	if l.LeaderID, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if l.LeaderEpoch, err = pd.getInt32(); err != nil {
+		return err
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+// SnapshotID identifies the snapshot to fetch instead of the log of a
+// partition of the KRaft metadata topic.
+type SnapshotID struct {
+	EndOffset int64
+	Epoch     int32
+}
+
+func (s *SnapshotID) encode(pe packetEncoder) error {
+	pe.putInt64(s.EndOffset)
+	pe.putInt32(s.Epoch)
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (s *SnapshotID) decode(pd packetDecoder) (err error) {
+	if s.EndOffset, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if s.Epoch, err = pd.getInt32(); err != nil {
+		return err
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
 type FetchResponseBlock struct {
 	Err                  KError
 	HighWaterMarkOffset  int64
@@ -39,9 +122,20 @@ type FetchResponseBlock struct {
 	Records              *Records // deprecated: use FetchResponseBlock.RecordsSet
 	RecordsSet           []*Records
 	Partial              bool
+
+	// The tagged fields of Version >= 12, nil when not returned
+	DivergingEpoch *EpochEndOffset
+	CurrentLeader  *LeaderIDAndEpoch
+	SnapshotID     *SnapshotID
+
+	// recordsSize is the size of the records in the response, for the metrics
+	recordsSize int32
+	// rawRecords are the records left to decode by decodeRecords when the
+	// decoding of the response is deferred, see FetchResponse.deferRecords
+	rawRecords []byte
 }
 
-func (b *FetchResponseBlock) decode(pd packetDecoder, version int16) (err error) {
+func (b *FetchResponseBlock) decode(pd packetDecoder, version int16, deferRecords bool) (err error) {
 	tmp, err := pd.getInt16()
 	if err != nil {
 		return err
@@ -66,8 +160,18 @@ func (b *FetchResponseBlock) decode(pd packetDecoder, version int16) (err error)
 			}
 		}
 
-		numTransact, err := pd.getArrayLength()
-		if err != nil {
+		var numTransact int
+		if version >= 12 {
+			// compact nullable array, 0 is null
+			n, err := pd.getUVarint()
+			if err != nil {
+				return err
+			}
+			if n > uint64(pd.remaining()) {
+				return ErrInsufficientData
+			}
+			numTransact = int(n) - 1
+		} else if numTransact, err = pd.getArrayLength(); err != nil {
 			return err
 		}
 
@@ -80,6 +184,11 @@ func (b *FetchResponseBlock) decode(pd packetDecoder, version int16) (err error)
 			if err = transact.decode(pd); err != nil {
 				return err
 			}
+			if version >= 12 {
+				if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+					return err
+				}
+			}
 			b.AbortedTransactions[i] = transact
 		}
 	}
@@ -93,15 +202,49 @@ func (b *FetchResponseBlock) decode(pd packetDecoder, version int16) (err error)
 		b.PreferredReadReplica = -1
 	}
 
-	recordsSize, err := pd.getInt32()
-	if err != nil {
+	var recordsSize int32
+	if version >= 12 {
+		// compact nullable records, 0 is null
+		n, err := pd.getUVarint()
+		if err != nil {
+			return err
+		}
+		if n > uint64(pd.remaining())+1 {
+			return ErrInsufficientData
+		}
+		if n > 0 {
+			recordsSize = int32(n - 1)
+		}
+	} else if recordsSize, err = pd.getInt32(); err != nil {
 		return err
 	}
+	b.recordsSize = recordsSize
 
-	recordsDecoder, err := pd.getSubset(int(recordsSize))
+	b.rawRecords, err = pd.getRawBytes(int(recordsSize))
 	if err != nil {
 		return err
 	}
+	if !deferRecords {
+		if err := b.decodeRecords(); err != nil {
+			return err
+		}
+	}
+
+	if version >= 12 {
+		return b.decodeTaggedFields(pd)
+	}
+	return nil
+}
+
+// decodeRecords decodes the records of the block left to decode, so that
+// the consumer decodes (and decompresses) the records of each partition on
+// its own goroutine rather than all of them with the response.
+func (b *FetchResponseBlock) decodeRecords() error {
+	if b.rawRecords == nil {
+		return nil
+	}
+	recordsDecoder := &realDecoder{raw: b.rawRecords}
+	b.rawRecords = nil
 
 	b.RecordsSet = []*Records{}
 
@@ -145,10 +288,30 @@ func (b *FetchResponseBlock) decode(pd packetDecoder, version int16) (err error)
 			break
 		}
 	}
-
 	return nil
 }
 
+func (b *FetchResponseBlock) decodeTaggedFields(pd packetDecoder) error {
+	b.DivergingEpoch = nil
+	b.CurrentLeader = nil
+	b.SnapshotID = nil
+
+	return getTaggedFields(pd, func(tag uint64, field packetDecoder) error {
+		switch tag {
+		case fetchResponseDivergingEpochTag:
+			b.DivergingEpoch = new(EpochEndOffset)
+			return b.DivergingEpoch.decode(field)
+		case fetchResponseCurrentLeaderTag:
+			b.CurrentLeader = new(LeaderIDAndEpoch)
+			return b.CurrentLeader.decode(field)
+		case fetchResponseSnapshotIDTag:
+			b.SnapshotID = new(SnapshotID)
+			return b.SnapshotID.decode(field)
+		}
+		return nil
+	})
+}
+
 func (b *FetchResponseBlock) numRecords() (int, error) {
 	sum := 0
 
@@ -188,13 +351,18 @@ func (b *FetchResponseBlock) encode(pe packetEncoder, version int16) (err error)
 			pe.putInt64(b.LogStartOffset)
 		}
 
-		if err = pe.putArrayLength(len(b.AbortedTransactions)); err != nil {
+		if version >= 12 {
+			pe.putCompactArrayLength(len(b.AbortedTransactions))
+		} else if err = pe.putArrayLength(len(b.AbortedTransactions)); err != nil {
 			return err
 		}
 		for _, transact := range b.AbortedTransactions {
 			if err = transact.encode(pe); err != nil {
 				return err
 			}
+			if version >= 12 {
+				pe.putEmptyTaggedFieldArray()
+			}
 		}
 	}
 
@@ -202,6 +370,22 @@ func (b *FetchResponseBlock) encode(pe packetEncoder, version int16) (err error)
 		pe.putInt32(b.PreferredReadReplica)
 	}
 
+	if version >= 12 {
+		// the size of compact records is a varint, so they are encoded first
+		var buf []byte
+		for _, records := range b.RecordsSet {
+			raw, err := encode(records, nil)
+			if err != nil {
+				return err
+			}
+			buf = append(buf, raw...)
+		}
+		if err = pe.putCompactBytes(buf); err != nil {
+			return err
+		}
+		return b.encodeTaggedFields(pe)
+	}
+
 	pe.push(&lengthField{})
 	for _, records := range b.RecordsSet {
 		err = records.encode(pe)
@@ -212,6 +396,37 @@ func (b *FetchResponseBlock) encode(pe packetEncoder, version int16) (err error)
 	return pe.pop()
 }
 
+func (b *FetchResponseBlock) encodeTaggedFields(pe packetEncoder) error {
+	numTags := 0
+	if b.DivergingEpoch != nil {
+		numTags++
+	}
+	if b.CurrentLeader != nil {
+		numTags++
+	}
+	if b.SnapshotID != nil {
+		numTags++
+	}
+	pe.putUVarint(uint64(numTags))
+
+	if b.DivergingEpoch != nil {
+		if err := putTaggedField(pe, fetchResponseDivergingEpochTag, b.DivergingEpoch); err != nil {
+			return err
+		}
+	}
+	if b.CurrentLeader != nil {
+		if err := putTaggedField(pe, fetchResponseCurrentLeaderTag, b.CurrentLeader); err != nil {
+			return err
+		}
+	}
+	if b.SnapshotID != nil {
+		if err := putTaggedField(pe, fetchResponseSnapshotIDTag, b.SnapshotID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (b *FetchResponseBlock) getAbortedTransactions() []*AbortedTransaction {
 	// I can't find any doc that guarantee the field `fetchResponse.AbortedTransactions` is ordered
 	// plus Java implementation use a PriorityQueue based on `FirstOffset`. I guess we have to order it ourself
@@ -231,10 +446,23 @@ type FetchResponse struct {
 	Version       int16
 	LogAppendTime bool
 	Timestamp     time.Time
+	// TopicIDs maps the names of the topics of Blocks to their IDs, only
+	// used from Version 13. The topics are decoded with the names set here
+	// beforehand, as done by Broker.Fetch from the request, and named after
+	// the string of their ID otherwise.
+	TopicIDs map[string]Uuid
+
+	// buffer is the pooled buffer the response was read into, which its
+	// records reference, see Consumer.Fetch.PooledBuffers
+	buffer *pooledBuffer
+	// deferRecords leaves the records of the blocks to decode by their
+	// consumers, see FetchResponseBlock.decodeRecords
+	deferRecords bool
 }
 
 func (r *FetchResponse) decode(pd packetDecoder, version int16) (err error) {
 	r.Version = version
+	isFlexible := r.Version >= 12
 
 	if r.Version >= 1 {
 		throttle, err := pd.getInt32()
@@ -255,19 +483,46 @@ func (r *FetchResponse) decode(pd packetDecoder, version int16) (err error) {
 		}
 	}
 
-	numTopics, err := pd.getArrayLength()
+	numTopics, err := r.decodeArrayLength(pd)
 	if err != nil {
 		return err
 	}
 
+	var topicNames map[Uuid]string
+	if r.Version >= 13 {
+		topicNames = make(map[Uuid]string, len(r.TopicIDs))
+		for name, topicID := range r.TopicIDs {
+			topicNames[topicID] = name
+		}
+		r.TopicIDs = make(map[string]Uuid, numTopics)
+	}
+
 	r.Blocks = make(map[string]map[int32]*FetchResponseBlock, numTopics)
 	for i := 0; i < numTopics; i++ {
-		name, err := pd.getString()
+		var name string
+		switch {
+		case r.Version >= 13:
+			raw, err := pd.getRawBytes(16)
+			if err != nil {
+				return err
+			}
+			var topicID Uuid
+			copy(topicID[:], raw)
+			var ok bool
+			if name, ok = topicNames[topicID]; !ok {
+				name = topicID.String()
+			}
+			r.TopicIDs[name] = topicID
+		case isFlexible:
+			name, err = pd.getCompactString()
+		default:
+			name, err = pd.getString()
+		}
 		if err != nil {
 			return err
 		}
 
-		numBlocks, err := pd.getArrayLength()
+		numBlocks, err := r.decodeArrayLength(pd)
 		if err != nil {
 			return err
 		}
@@ -281,18 +536,44 @@ func (r *FetchResponse) decode(pd packetDecoder, version int16) (err error) {
 			}
 
 			block := new(FetchResponseBlock)
-			err = block.decode(pd, version)
+			err = block.decode(pd, version, r.deferRecords)
 			if err != nil {
 				return err
 			}
 			r.Blocks[name][id] = block
 		}
+
+		if isFlexible {
+			if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if isFlexible {
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+func (r *FetchResponse) decodeArrayLength(pd packetDecoder) (n int, err error) {
+	if r.Version >= 12 {
+		n, err = pd.getCompactArrayLength()
+	} else {
+		n, err = pd.getArrayLength()
+	}
+	if n == -1 {
+		n = 0
+	}
+	return n, err
+}
+
 func (r *FetchResponse) encode(pe packetEncoder) (err error) {
+	isFlexible := r.Version >= 12
+
 	if r.Version >= 1 {
 		pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
 	}
@@ -302,19 +583,32 @@ func (r *FetchResponse) encode(pe packetEncoder) (err error) {
 		pe.putInt32(r.SessionID)
 	}
 
-	err = pe.putArrayLength(len(r.Blocks))
-	if err != nil {
+	if isFlexible {
+		pe.putCompactArrayLength(len(r.Blocks))
+	} else if err = pe.putArrayLength(len(r.Blocks)); err != nil {
 		return err
 	}
 
 	for topic, partitions := range r.Blocks {
-		err = pe.putString(topic)
+		switch {
+		case r.Version >= 13:
+			topicID, ok := r.TopicIDs[topic]
+			if !ok {
+				return PacketEncodingError{"missing the ID of topic " + topic}
+			}
+			err = pe.putRawBytes(topicID[:])
+		case isFlexible:
+			err = pe.putCompactString(topic)
+		default:
+			err = pe.putString(topic)
+		}
 		if err != nil {
 			return err
 		}
 
-		err = pe.putArrayLength(len(partitions))
-		if err != nil {
+		if isFlexible {
+			pe.putCompactArrayLength(len(partitions))
+		} else if err = pe.putArrayLength(len(partitions)); err != nil {
 			return err
 		}
 
@@ -325,6 +619,14 @@ func (r *FetchResponse) encode(pe packetEncoder) (err error) {
 				return err
 			}
 		}
+
+		if isFlexible {
+			pe.putEmptyTaggedFieldArray()
+		}
+	}
+
+	if isFlexible {
+		pe.putEmptyTaggedFieldArray()
 	}
 	return nil
 }
@@ -338,6 +640,9 @@ func (r *FetchResponse) version() int16 {
 }
 
 func (r *FetchResponse) headerVersion() int16 {
+	if r.Version >= 12 {
+		return 1
+	}
 	return 0
 }
 
@@ -363,11 +668,19 @@ func (r *FetchResponse) requiredVersion() KafkaVersion {
 		return V2_1_0_0
 	case 11:
 		return V2_3_0_0
+	case 12:
+		return V2_7_0_0
+	case 13:
+		return V3_1_0_0
 	default:
 		return MaxVersion
 	}
 }
 
+func (r *FetchResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
+
 func (r *FetchResponse) GetBlock(topic string, partition int32) *FetchResponseBlock {
 	if r.Blocks == nil {
 		return nil
@@ -2,6 +2,7 @@ package sarama
 
 import (
 	"bytes"
+	"reflect"
 	"testing"
 )
 
@@ -513,3 +514,136 @@ func TestPreferredReplicaFetchResponseV11(t *testing.T) {
 		t.Error("Decoding produced incorrect message value.")
 	}
 }
+
+var fetchResponseV12 = []byte{
+	0x00, 0x00, 0x00, 0x00, // ThrottleTime
+	0x00, 0x00, // ErrorCode
+	0x00, 0x00, 0x00, 0xAC, // SessionID
+	0x02, // Number of Topics
+	0x06, 't', 'o', 'p', 'i', 'c',
+	0x02,                   // Number of Partitions
+	0x00, 0x00, 0x00, 0x05, // Partition
+	0x00, 0x00, // Error
+	0x00, 0x00, 0x00, 0x00, 0x10, 0x10, 0x10, 0x10, // High Watermark Offset
+	0x00, 0x00, 0x00, 0x00, 0x10, 0x10, 0x10, 0x09, // Last Stable Offset
+	0x00, 0x00, 0x00, 0x00, 0x01, 0x01, 0x01, 0x01, // Log Start Offset
+	0x00,                   // Null Aborted Transactions
+	0xFF, 0xFF, 0xFF, 0xFF, // Preferred Read Replica
+	0x01,       // No Records
+	0x02,       // Number of Tagged Fields
+	0x00, 0x0D, // DivergingEpoch
+	0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x00,
+	0x07, 0x01, 0xFF, // Unknown tag, skipped
+	0x00, // Topic Tagged Fields
+	0x00, // Tagged Fields
+}
+
+func TestFetchResponseV12(t *testing.T) {
+	response := FetchResponse{}
+	testVersionDecodable(t, "fetch response v12", &response, fetchResponseV12, 12)
+
+	if response.SessionID != 0x000000AC {
+		t.Error("Decoding produced incorrect session ID.")
+	}
+	block := response.GetBlock("topic", 5)
+	if block == nil {
+		t.Fatal("GetBlock didn't return block.")
+	}
+	if block.HighWaterMarkOffset != 0x10101010 || block.LastStableOffset != 0x10101009 || block.LogStartOffset != 0x01010101 {
+		t.Error("Decoding didn't produce correct offsets.")
+	}
+	if block.AbortedTransactions != nil {
+		t.Error("Decoding produced aborted transactions where there were none.")
+	}
+	if block.DivergingEpoch == nil || *block.DivergingEpoch != (EpochEndOffset{Epoch: 3, EndOffset: 12}) {
+		t.Errorf("Decoding produced incorrect diverging epoch %v.", block.DivergingEpoch)
+	}
+	if block.CurrentLeader != nil || block.SnapshotID != nil {
+		t.Error("Decoding produced tagged fields which were not set.")
+	}
+	if n, err := block.numRecords(); err != nil || n != 0 {
+		t.Errorf("Decoding produced %d records, %v", n, err)
+	}
+}
+
+func TestFetchResponseFlexibleVersions(t *testing.T) {
+	topicID := Uuid{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	for _, version := range []int16{12, 13} {
+		response := &FetchResponse{Version: version, SessionID: 0xAC}
+		if version >= 13 {
+			response.TopicIDs = map[string]Uuid{"topic": topicID}
+		}
+		response.AddRecord("topic", 5, nil, StringEncoder("foo"), 0x10)
+		block := response.GetBlock("topic", 5)
+		block.AbortedTransactions = []*AbortedTransaction{{ProducerID: 7, FirstOffset: 0x10}}
+		block.CurrentLeader = &LeaderIDAndEpoch{LeaderID: 2, LeaderEpoch: 4}
+		block.SnapshotID = &SnapshotID{EndOffset: 12, Epoch: 3}
+
+		raw, err := encode(response, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the topics of the request name the topics of the response
+		decoded := &FetchResponse{TopicIDs: response.TopicIDs}
+		testVersionDecodable(t, "flexible fetch response", decoded, raw, version)
+		if version >= 13 && decoded.TopicIDs["topic"] != topicID {
+			t.Errorf("v%d: expected the ID of the topic, got %v", version, decoded.TopicIDs)
+		}
+		got := decoded.GetBlock("topic", 5)
+		if got == nil {
+			t.Fatalf("v%d: GetBlock didn't return block.", version)
+		}
+		if !reflect.DeepEqual(got.AbortedTransactions, block.AbortedTransactions) {
+			t.Errorf("v%d: expected the aborted transactions %v, got %v", version, block.AbortedTransactions, got.AbortedTransactions)
+		}
+		if got.DivergingEpoch != nil || !reflect.DeepEqual(got.CurrentLeader, block.CurrentLeader) || !reflect.DeepEqual(got.SnapshotID, block.SnapshotID) {
+			t.Errorf("v%d: expected the tagged fields to round trip, got %+v", version, got)
+		}
+		if n, err := got.numRecords(); err != nil || n != 1 {
+			t.Errorf("v%d: expected 1 record, got %d, %v", version, n, err)
+		}
+	}
+}
+
+func TestFetchResponseV13UnknownTopicID(t *testing.T) {
+	topicID := Uuid{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	response := &FetchResponse{Version: 13, TopicIDs: map[string]Uuid{"topic": topicID}}
+	response.AddError("topic", 0, ErrUnknownTopicID)
+	raw, err := encode(response, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := &FetchResponse{}
+	testVersionDecodable(t, "fetch response v13", decoded, raw, 13)
+	if block := decoded.GetBlock(topicID.String(), 0); block == nil || block.Err != ErrUnknownTopicID {
+		t.Errorf("Expected the topic to be named after its ID, got %v", decoded.Blocks)
+	}
+}
+
+func TestFetchResponseDeferredRecords(t *testing.T) {
+	response := FetchResponse{deferRecords: true}
+	testVersionDecodable(t, "deferred records", &response, oneRecordFetchResponse, 4)
+
+	block := response.GetBlock("topic", 5)
+	if block == nil {
+		t.Fatal("GetBlock didn't return block.")
+	}
+	if block.RecordsSet != nil || len(block.rawRecords) == 0 {
+		t.Fatal("Expected the records to be left to decode")
+	}
+
+	if err := block.decodeRecords(); err != nil {
+		t.Fatal(err)
+	}
+	if block.rawRecords != nil {
+		t.Error("Expected the records to be decoded once")
+	}
+
+	expected := FetchResponse{}
+	testVersionDecodable(t, "records", &expected, oneRecordFetchResponse, 4)
+	if !reflect.DeepEqual(block, expected.GetBlock("topic", 5)) {
+		t.Errorf("Expected the deferred records to be decoded as %+v, got %+v", expected.GetBlock("topic", 5), block)
+	}
+}
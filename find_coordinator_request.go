@@ -11,27 +11,58 @@ type FindCoordinatorRequest struct {
 	Version         int16
 	CoordinatorKey  string
 	CoordinatorType CoordinatorType
+	// CoordinatorKeys replaces CoordinatorKey from Version 4 to find the
+	// coordinators of several keys at once (KIP-699)
+	CoordinatorKeys []string
 }
 
-func (f *FindCoordinatorRequest) encode(pe packetEncoder) error {
-	if err := pe.putString(f.CoordinatorKey); err != nil {
-		return err
+func (f *FindCoordinatorRequest) encode(pe packetEncoder) (err error) {
+	if f.Version < 4 {
+		if f.Version >= 3 {
+			err = pe.putCompactString(f.CoordinatorKey)
+		} else {
+			err = pe.putString(f.CoordinatorKey)
+		}
+		if err != nil {
+			return err
+		}
 	}
 
 	if f.Version >= 1 {
 		pe.putInt8(int8(f.CoordinatorType))
 	}
 
+	if f.Version >= 4 {
+		pe.putCompactArrayLength(len(f.CoordinatorKeys))
+		for _, key := range f.CoordinatorKeys {
+			if err := pe.putCompactString(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	if f.Version >= 3 {
+		pe.putEmptyTaggedFieldArray()
+	}
+
 	return nil
 }
 
 func (f *FindCoordinatorRequest) decode(pd packetDecoder, version int16) (err error) {
-	if f.CoordinatorKey, err = pd.getString(); err != nil {
-		return err
+	f.Version = version
+
+	if version < 4 {
+		if version >= 3 {
+			f.CoordinatorKey, err = pd.getCompactString()
+		} else {
+			f.CoordinatorKey, err = pd.getString()
+		}
+		if err != nil {
+			return err
+		}
 	}
 
 	if version >= 1 {
-		f.Version = version
 		coordinatorType, err := pd.getInt8()
 		if err != nil {
 			return err
@@ -40,6 +71,25 @@ func (f *FindCoordinatorRequest) decode(pd packetDecoder, version int16) (err er
 		f.CoordinatorType = CoordinatorType(coordinatorType)
 	}
 
+	if version >= 4 {
+		n, err := pd.getCompactArrayLength()
+		if err != nil {
+			return err
+		}
+		f.CoordinatorKeys = make([]string, n)
+		for i := range f.CoordinatorKeys {
+			if f.CoordinatorKeys[i], err = pd.getCompactString(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if version >= 3 {
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -52,11 +102,20 @@ func (f *FindCoordinatorRequest) version() int16 {
 }
 
 func (r *FindCoordinatorRequest) headerVersion() int16 {
+	if r.Version >= 3 {
+		return 2
+	}
 	return 1
 }
 
 func (f *FindCoordinatorRequest) requiredVersion() KafkaVersion {
 	switch f.Version {
+	case 4:
+		return V3_0_0_0
+	case 3:
+		return V2_4_0_0
+	case 2:
+		return V2_0_0_0
 	case 1:
 		return V0_11_0_0
 	default:
@@ -12,6 +12,20 @@ var (
 		0, 13, 't', 'r', 'a', 'n', 's', 'a', 'c', 't', 'i', 'o', 'n', 'i', 'd',
 		1,
 	}
+
+	findCoordinatorRequestV3 = []byte{
+		6, 'g', 'r', 'o', 'u', 'p', // CoordinatorKey
+		0, // CoordinatorType
+		0, // tagged fields
+	}
+
+	findCoordinatorRequestV4 = []byte{
+		0,           // CoordinatorType
+		3,           // CoordinatorKeys
+		3, 'g', '1', // CoordinatorKeys[0]
+		3, 'g', '2', // CoordinatorKeys[1]
+		0, // tagged fields
+	}
 )
 
 func TestFindCoordinatorRequest(t *testing.T) {
@@ -31,3 +45,19 @@ func TestFindCoordinatorRequest(t *testing.T) {
 
 	testRequest(t, "version 1 - transaction", req, findCoordinatorRequestTransaction)
 }
+
+func TestFindCoordinatorRequestFlexible(t *testing.T) {
+	req := &FindCoordinatorRequest{
+		Version:         3,
+		CoordinatorKey:  "group",
+		CoordinatorType: CoordinatorGroup,
+	}
+	testRequest(t, "version 3", req, findCoordinatorRequestV3)
+
+	req = &FindCoordinatorRequest{
+		Version:         4,
+		CoordinatorType: CoordinatorGroup,
+		CoordinatorKeys: []string{"g1", "g2"},
+	}
+	testRequest(t, "version 4", req, findCoordinatorRequestV4)
+}
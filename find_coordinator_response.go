@@ -1,6 +1,9 @@
 package sarama
 
 import (
+	"fmt"
+	"net"
+	"strconv"
 	"time"
 )
 
@@ -12,12 +15,59 @@ type FindCoordinatorResponse struct {
 	Err          KError
 	ErrMsg       *string
 	Coordinator  *Broker
+	// Coordinators replaces Err, ErrMsg and Coordinator from Version 4 with
+	// the coordinators of the keys of the request (KIP-699)
+	Coordinators []*FindCoordinatorResponseCoordinator
+}
+
+// FindCoordinatorResponseCoordinator is the coordinator of a key of a
+// FindCoordinatorRequest of Version 4 or later
+type FindCoordinatorResponseCoordinator struct {
+	Key         string
+	Coordinator *Broker
+	Err         KError
+	ErrMsg      *string
+}
+
+func (c *FindCoordinatorResponseCoordinator) decode(pd packetDecoder) (err error) {
+	if c.Key, err = pd.getCompactString(); err != nil {
+		return err
+	}
+	if c.Coordinator, err = decodeCoordinator(pd, true); err != nil {
+		return err
+	}
+	tmp, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	c.Err = KError(tmp)
+	if c.ErrMsg, err = pd.getCompactNullableString(); err != nil {
+		return err
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (c *FindCoordinatorResponseCoordinator) encode(pe packetEncoder) error {
+	if err := pe.putCompactString(c.Key); err != nil {
+		return err
+	}
+	if err := encodeCoordinator(pe, c.Coordinator, true); err != nil {
+		return err
+	}
+	pe.putInt16(int16(c.Err))
+	if err := pe.putNullableCompactString(c.ErrMsg); err != nil {
+		return err
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
 }
 
 func (f *FindCoordinatorResponse) decode(pd packetDecoder, version int16) (err error) {
-	if version >= 1 {
-		f.Version = version
+	f.Version = version
+	isFlexible := version >= 3
 
+	if version >= 1 {
 		throttleTime, err := pd.getInt32()
 		if err != nil {
 			return err
@@ -25,52 +75,138 @@ func (f *FindCoordinatorResponse) decode(pd packetDecoder, version int16) (err e
 		f.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
 	}
 
+	if version >= 4 {
+		n, err := pd.getCompactArrayLength()
+		if err != nil {
+			return err
+		}
+		f.Coordinators = make([]*FindCoordinatorResponseCoordinator, n)
+		for i := range f.Coordinators {
+			f.Coordinators[i] = new(FindCoordinatorResponseCoordinator)
+			if err := f.Coordinators[i].decode(pd); err != nil {
+				return err
+			}
+		}
+		_, err = pd.getEmptyTaggedFieldArray()
+		return err
+	}
+
 	tmp, err := pd.getInt16()
 	if err != nil {
 		return err
 	}
 	f.Err = KError(tmp)
 
-	if version >= 1 {
-		if f.ErrMsg, err = pd.getNullableString(); err != nil {
-			return err
-		}
+	if isFlexible {
+		f.ErrMsg, err = pd.getCompactNullableString()
+	} else if version >= 1 {
+		f.ErrMsg, err = pd.getNullableString()
 	}
-
-	coordinator := new(Broker)
-	// The version is hardcoded to 0, as version 1 of the Broker-decode
-	// contains the rack-field which is not present in the FindCoordinatorResponse.
-	if err := coordinator.decode(pd, 0); err != nil {
+	if err != nil {
 		return err
 	}
-	if coordinator.addr == ":0" {
-		return nil
+
+	if f.Coordinator, err = decodeCoordinator(pd, isFlexible); err != nil {
+		return err
 	}
-	f.Coordinator = coordinator
 
-	return nil
+	if isFlexible {
+		_, err = pd.getEmptyTaggedFieldArray()
+	}
+	return err
 }
 
 func (f *FindCoordinatorResponse) encode(pe packetEncoder) error {
+	isFlexible := f.Version >= 3
+
 	if f.Version >= 1 {
 		pe.putInt32(int32(f.ThrottleTime / time.Millisecond))
 	}
 
+	if f.Version >= 4 {
+		pe.putCompactArrayLength(len(f.Coordinators))
+		for _, coordinator := range f.Coordinators {
+			if err := coordinator.encode(pe); err != nil {
+				return err
+			}
+		}
+		pe.putEmptyTaggedFieldArray()
+		return nil
+	}
+
 	pe.putInt16(int16(f.Err))
 
-	if f.Version >= 1 {
-		if err := pe.putNullableString(f.ErrMsg); err != nil {
-			return err
+	var err error
+	if isFlexible {
+		err = pe.putNullableCompactString(f.ErrMsg)
+	} else if f.Version >= 1 {
+		err = pe.putNullableString(f.ErrMsg)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := encodeCoordinator(pe, f.Coordinator, isFlexible); err != nil {
+		return err
+	}
+
+	if isFlexible {
+		pe.putEmptyTaggedFieldArray()
+	}
+	return nil
+}
+
+// decodeCoordinator decodes the node ID, host and port of a coordinator,
+// which is nil when unset
+func decodeCoordinator(pd packetDecoder, isFlexible bool) (*Broker, error) {
+	coordinator := new(Broker)
+	if isFlexible {
+		id, err := pd.getInt32()
+		if err != nil {
+			return nil, err
+		}
+		host, err := pd.getCompactString()
+		if err != nil {
+			return nil, err
+		}
+		port, err := pd.getInt32()
+		if err != nil {
+			return nil, err
 		}
+		coordinator.id = id
+		coordinator.addr = net.JoinHostPort(host, fmt.Sprint(port))
+	} else if err := coordinator.decode(pd, 0); err != nil {
+		// The version is hardcoded to 0, as version 1 of the Broker-decode
+		// contains the rack-field which is not present in the FindCoordinatorResponse.
+		return nil, err
+	}
+	if coordinator.addr == ":0" {
+		return nil, nil
 	}
+	return coordinator, nil
+}
 
-	coordinator := f.Coordinator
+func encodeCoordinator(pe packetEncoder, coordinator *Broker, isFlexible bool) error {
 	if coordinator == nil {
 		coordinator = NoNode
 	}
-	if err := coordinator.encode(pe, 0); err != nil {
+	if !isFlexible {
+		return coordinator.encode(pe, 0)
+	}
+
+	host, portstr, err := net.SplitHostPort(coordinator.addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseInt(portstr, 10, 32)
+	if err != nil {
 		return err
 	}
+	pe.putInt32(coordinator.id)
+	if err := pe.putCompactString(host); err != nil {
+		return err
+	}
+	pe.putInt32(int32(port))
 	return nil
 }
 
@@ -83,14 +219,27 @@ func (f *FindCoordinatorResponse) version() int16 {
 }
 
 func (r *FindCoordinatorResponse) headerVersion() int16 {
+	if r.Version >= 3 {
+		return 1
+	}
 	return 0
 }
 
 func (f *FindCoordinatorResponse) requiredVersion() KafkaVersion {
 	switch f.Version {
+	case 4:
+		return V3_0_0_0
+	case 3:
+		return V2_4_0_0
+	case 2:
+		return V2_0_0_0
 	case 1:
 		return V0_11_0_0
 	default:
 		return V0_8_2_0
 	}
 }
+
+func (f *FindCoordinatorResponse) throttleTime() time.Duration {
+	return f.ThrottleTime
+}
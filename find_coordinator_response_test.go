@@ -77,6 +77,63 @@ func TestFindCoordinatorResponse(t *testing.T) {
 			0, 0, // Coordinator.Host: ""
 			255, 255, 255, 255, // Coordinator.Port: -1
 		},
+	}, {
+		desc: "version 3 - no error",
+		response: &FindCoordinatorResponse{
+			Version:      3,
+			ThrottleTime: 100 * time.Millisecond,
+			Err:          ErrNoError,
+			Coordinator: &Broker{
+				id:   7,
+				addr: "host:9092",
+			},
+		},
+		encoded: []byte{
+			0, 0, 0, 100, // ThrottleTime
+			0, 0, // Err
+			0,          // ErrMsg: null
+			0, 0, 0, 7, // Coordinator.ID
+			5, 'h', 'o', 's', 't', // Coordinator.Host
+			0, 0, 35, 132, // Coordinator.Port
+			0, // tagged fields
+		},
+	}, {
+		desc: "version 4 - batched",
+		response: &FindCoordinatorResponse{
+			Version:      4,
+			ThrottleTime: 100 * time.Millisecond,
+			Coordinators: []*FindCoordinatorResponseCoordinator{{
+				Key: "g1",
+				Coordinator: &Broker{
+					id:   7,
+					addr: "host:9092",
+				},
+			}, {
+				Key:         "g2",
+				Coordinator: NoNode,
+				Err:         ErrConsumerCoordinatorNotAvailable,
+				ErrMsg:      &errMsg,
+			}},
+		},
+		encoded: []byte{
+			0, 0, 0, 100, // ThrottleTime
+			3,           // Coordinators
+			3, 'g', '1', // Coordinators[0].Key
+			0, 0, 0, 7, // Coordinators[0].ID
+			5, 'h', 'o', 's', 't', // Coordinators[0].Host
+			0, 0, 35, 132, // Coordinators[0].Port
+			0, 0, // Coordinators[0].Err
+			0,           // Coordinators[0].ErrMsg: null
+			0,           // Coordinators[0] tagged fields
+			3, 'g', '2', // Coordinators[1].Key
+			255, 255, 255, 255, // Coordinators[1].ID: -1
+			1,                  // Coordinators[1].Host: ""
+			255, 255, 255, 255, // Coordinators[1].Port: -1
+			0, 15, // Coordinators[1].Err
+			7, 'k', 'a', 'b', 'o', 'o', 'm', // Coordinators[1].ErrMsg
+			0, // Coordinators[1] tagged fields
+			0, // tagged fields
+		},
 	}} {
 		testResponse(t, tc.desc, tc.response, tc.encoded)
 	}
@@ -0,0 +1,109 @@
+package sarama
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// fipsCipherSuites are the TLS 1.2 cipher suites Net.FIPS allows, whose key
+// exchange, cipher and hash are FIPS 140 approved
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// fipsCurves are the NIST curves Net.FIPS allows for the key exchanges
+var fipsCurves = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+
+// fipsSASLMechanisms are the SASL mechanisms Net.FIPS allows, GSSAPI relying
+// on the encryption types negotiated with the KDC and the registered
+// mechanisms on unknown primitives
+var fipsSASLMechanisms = map[SASLMechanism]bool{
+	SASLTypePlaintext:   true,
+	SASLTypeSCRAMSHA256: true,
+	SASLTypeSCRAMSHA512: true,
+	SASLTypeOAuth:       true,
+	SASLTypeAWSMSKIAM:   true,
+}
+
+// validateFIPS returns a ConfigurationError if the configuration would use
+// cryptography Net.FIPS does not allow
+func (c *Config) validateFIPS() error {
+	if !c.Net.TLS.Enable {
+		return ConfigurationError("Net.TLS.Enable must be true with Net.FIPS")
+	}
+	if err := validateFIPSTLSConfig("Net.TLS.Config", c.Net.TLS.Config); err != nil {
+		return err
+	}
+	if c.Net.Proxy.TLS.Enable {
+		if err := validateFIPSTLSConfig("Net.Proxy.TLS.Config", c.Net.Proxy.TLS.Config); err != nil {
+			return err
+		}
+	}
+	if c.Net.SASL.Enable && !fipsSASLMechanisms[c.Net.SASL.Mechanism] {
+		return ConfigurationError(fmt.Sprintf("Net.SASL.Mechanism %s is not allowed with Net.FIPS", c.Net.SASL.Mechanism))
+	}
+	return nil
+}
+
+func validateFIPSTLSConfig(name string, cfg *tls.Config) error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.MaxVersion != 0 && cfg.MaxVersion < tls.VersionTLS12 {
+		return ConfigurationError(name + ".MaxVersion must be at least TLS 1.2 with Net.FIPS")
+	}
+	// fipsTLSConfig caps the connections at TLS 1.2, whose cipher suites
+	// can be restricted
+	if cfg.MinVersion > tls.VersionTLS12 {
+		return ConfigurationError(name + ".MinVersion must be at most TLS 1.2 with Net.FIPS, which only negotiates TLS 1.2")
+	}
+	for _, suite := range cfg.CipherSuites {
+		if !uint16SliceContains(fipsCipherSuites, suite) {
+			return ConfigurationError(fmt.Sprintf("%s.CipherSuites contains the cipher suite 0x%04x, which is not allowed with Net.FIPS", name, suite))
+		}
+	}
+	for _, curve := range cfg.CurvePreferences {
+		if !curveSliceContains(fipsCurves, curve) {
+			return ConfigurationError(fmt.Sprintf("%s.CurvePreferences contains the curve %d, which is not allowed with Net.FIPS", name, curve))
+		}
+	}
+	return nil
+}
+
+// fipsTLSConfig returns a copy of cfg, which passed validateFIPSTLSConfig,
+// negotiating TLS 1.2 with the cipher suites and curves Net.FIPS allows
+func fipsTLSConfig(cfg *tls.Config) *tls.Config {
+	c := cfg.Clone()
+	if c.MinVersion < tls.VersionTLS12 {
+		c.MinVersion = tls.VersionTLS12
+	}
+	c.MaxVersion = tls.VersionTLS12
+	if len(c.CipherSuites) == 0 {
+		c.CipherSuites = fipsCipherSuites
+	}
+	if len(c.CurvePreferences) == 0 {
+		c.CurvePreferences = fipsCurves
+	}
+	return c
+}
+
+func uint16SliceContains(values []uint16, value uint16) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func curveSliceContains(curves []tls.CurveID, curve tls.CurveID) bool {
+	for _, c := range curves {
+		if c == curve {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package sarama
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+)
+
+func TestConfigValidateFIPS(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  func(*Config)
+		err  string
+	}{
+		{
+			"TLSDisabled",
+			func(cfg *Config) {
+				cfg.Net.TLS.Enable = false
+			},
+			"Net.TLS.Enable must be true with Net.FIPS",
+		},
+		{
+			"MaxVersion",
+			func(cfg *Config) {
+				cfg.Net.TLS.Config = &tls.Config{MaxVersion: tls.VersionTLS11}
+			},
+			"Net.TLS.Config.MaxVersion must be at least TLS 1.2 with Net.FIPS",
+		},
+		{
+			"MinVersion",
+			func(cfg *Config) {
+				cfg.Net.TLS.Config = &tls.Config{MinVersion: tls.VersionTLS13}
+			},
+			"Net.TLS.Config.MinVersion must be at most TLS 1.2 with Net.FIPS, which only negotiates TLS 1.2",
+		},
+		{
+			"CipherSuites",
+			func(cfg *Config) {
+				cfg.Net.TLS.Config = &tls.Config{CipherSuites: []uint16{
+					tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+					tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+				}}
+			},
+			"Net.TLS.Config.CipherSuites contains the cipher suite 0xcca8, which is not allowed with Net.FIPS",
+		},
+		{
+			"CurvePreferences",
+			func(cfg *Config) {
+				cfg.Net.TLS.Config = &tls.Config{CurvePreferences: []tls.CurveID{tls.X25519}}
+			},
+			"Net.TLS.Config.CurvePreferences contains the curve 29, which is not allowed with Net.FIPS",
+		},
+		{
+			"ProxyCipherSuites",
+			func(cfg *Config) {
+				cfg.Net.Proxy.Enable = true
+				cfg.Net.Proxy.Type = ProxyTypeHTTP
+				cfg.Net.Proxy.Address = "proxy:3128"
+				cfg.Net.Proxy.TLS.Enable = true
+				cfg.Net.Proxy.TLS.Config = &tls.Config{CipherSuites: []uint16{tls.TLS_RSA_WITH_AES_128_CBC_SHA}}
+			},
+			"Net.Proxy.TLS.Config.CipherSuites contains the cipher suite 0x002f, which is not allowed with Net.FIPS",
+		},
+		{
+			"GSSAPI",
+			func(cfg *Config) {
+				cfg.Net.SASL.Enable = true
+				cfg.Net.SASL.Mechanism = SASLTypeGSSAPI
+				cfg.Net.SASL.GSSAPI.ServiceName = "kafka"
+				cfg.Net.SASL.GSSAPI.AuthType = KRB5_USER_AUTH
+				cfg.Net.SASL.GSSAPI.Username = "sarama"
+				cfg.Net.SASL.GSSAPI.Password = "secret"
+				cfg.Net.SASL.GSSAPI.Realm = "EXAMPLE.COM"
+				cfg.Net.SASL.GSSAPI.KerberosConfigPath = "/etc/krb5.conf"
+			},
+			"Net.SASL.Mechanism GSSAPI is not allowed with Net.FIPS",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := NewTestConfig()
+			config.Net.FIPS = true
+			config.Net.TLS.Enable = true
+			test.cfg(config)
+			if err := config.Validate(); err == nil || string(err.(ConfigurationError)) != test.err {
+				t.Errorf("expected %q, got %v", test.err, err)
+			}
+		})
+	}
+
+	config := NewTestConfig()
+	config.Net.FIPS = true
+	config.Net.TLS.Enable = true
+	config.Net.TLS.Config = &tls.Config{CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}}
+	config.Net.SASL.Enable = true
+	config.Net.SASL.Mechanism = SASLTypeSCRAMSHA512
+	config.Net.SASL.User = "sarama"
+	config.Net.SASL.Password = "secret"
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBrokerFIPSTLSConfig(t *testing.T) {
+	conf := NewTestConfig()
+	conf.Net.FIPS = true
+	conf.Net.TLS.Enable = true
+	conf.Net.TLS.Config = &tls.Config{MinVersion: tls.VersionTLS10, CurvePreferences: []tls.CurveID{tls.CurveP384}}
+
+	cfg := NewBroker("kafka-1:9093").tlsConfig(conf)
+	if cfg.MinVersion != tls.VersionTLS12 || cfg.MaxVersion != tls.VersionTLS12 {
+		t.Errorf("expected TLS 1.2 only, got versions %x to %x", cfg.MinVersion, cfg.MaxVersion)
+	}
+	if !reflect.DeepEqual(cfg.CipherSuites, fipsCipherSuites) {
+		t.Errorf("expected the FIPS cipher suites, got %v", cfg.CipherSuites)
+	}
+	if !reflect.DeepEqual(cfg.CurvePreferences, []tls.CurveID{tls.CurveP384}) {
+		t.Errorf("expected the configured curves to be kept, got %v", cfg.CurvePreferences)
+	}
+	if cfg.ServerName != "kafka-1" {
+		t.Errorf("expected the server name kafka-1, got %s", cfg.ServerName)
+	}
+	if conf.Net.TLS.Config.MinVersion != tls.VersionTLS10 || conf.Net.TLS.Config.CipherSuites != nil {
+		t.Error("expected the configured TLS config to be left as is")
+	}
+}
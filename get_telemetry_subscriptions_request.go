@@ -0,0 +1,47 @@
+package sarama
+
+// GetTelemetrySubscriptionsRequest is sent by a client to get the metrics the
+// broker wants it to push with PushTelemetryRequest (KIP-714)
+type GetTelemetrySubscriptionsRequest struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	// ClientInstanceId is zero for the first request, the broker then
+	// generates the ID of the client in its response
+	ClientInstanceId Uuid
+}
+
+func (r *GetTelemetrySubscriptionsRequest) encode(pe packetEncoder) error {
+	if err := pe.putRawBytes(r.ClientInstanceId[:]); err != nil {
+		return err
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *GetTelemetrySubscriptionsRequest) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	clientInstanceID, err := pd.getRawBytes(len(r.ClientInstanceId))
+	if err != nil {
+		return err
+	}
+	copy(r.ClientInstanceId[:], clientInstanceID)
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *GetTelemetrySubscriptionsRequest) key() int16 {
+	return 71
+}
+
+func (r *GetTelemetrySubscriptionsRequest) version() int16 {
+	return r.Version
+}
+
+func (r *GetTelemetrySubscriptionsRequest) headerVersion() int16 {
+	return 2
+}
+
+func (r *GetTelemetrySubscriptionsRequest) requiredVersion() KafkaVersion {
+	return V3_7_0_0
+}
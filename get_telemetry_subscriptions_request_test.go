@@ -0,0 +1,26 @@
+package sarama
+
+import "testing"
+
+var (
+	getTelemetrySubscriptionsRequestNoID = []byte{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // ClientInstanceId
+		0, // empty tagged fields
+	}
+
+	getTelemetrySubscriptionsRequestID = []byte{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, // ClientInstanceId
+		0, // empty tagged fields
+	}
+)
+
+func TestGetTelemetrySubscriptionsRequest(t *testing.T) {
+	request := &GetTelemetrySubscriptionsRequest{Version: 0}
+	testRequest(t, "no client instance ID", request, getTelemetrySubscriptionsRequestNoID)
+
+	request = &GetTelemetrySubscriptionsRequest{
+		Version:          0,
+		ClientInstanceId: Uuid{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+	}
+	testRequest(t, "client instance ID", request, getTelemetrySubscriptionsRequestID)
+}
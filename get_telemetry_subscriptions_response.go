@@ -0,0 +1,141 @@
+package sarama
+
+import "time"
+
+// GetTelemetrySubscriptionsResponse is the response to a
+// GetTelemetrySubscriptionsRequest
+type GetTelemetrySubscriptionsResponse struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	ThrottleTime time.Duration
+	Err          KError
+	// ClientInstanceId is the ID of the client, generated by the broker when
+	// the request had none
+	ClientInstanceId Uuid
+	// SubscriptionId identifies the subscription in PushTelemetryRequest, it
+	// changes when the subscription changes
+	SubscriptionId int32
+	// AcceptedCompressionTypes are the compression codecs of the metrics the
+	// broker accepts, by order of preference. Uncompressed metrics are always
+	// accepted
+	AcceptedCompressionTypes []CompressionCodec
+	PushIntervalMs           int32
+	TelemetryMaxBytes        int32
+	// DeltaTemporality is whether the sums are pushed as the delta since the
+	// previous push instead of as cumulative values
+	DeltaTemporality bool
+	// RequestedMetrics are the prefixes of the names of the metrics to push:
+	// none when empty, all of them when it only holds an empty string
+	RequestedMetrics []string
+}
+
+func (r *GetTelemetrySubscriptionsResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	pe.putInt16(int16(r.Err))
+	if err := pe.putRawBytes(r.ClientInstanceId[:]); err != nil {
+		return err
+	}
+	pe.putInt32(r.SubscriptionId)
+	pe.putCompactArrayLength(len(r.AcceptedCompressionTypes))
+	for _, codec := range r.AcceptedCompressionTypes {
+		pe.putInt8(int8(codec))
+	}
+	pe.putInt32(r.PushIntervalMs)
+	pe.putInt32(r.TelemetryMaxBytes)
+	pe.putBool(r.DeltaTemporality)
+	pe.putCompactArrayLength(len(r.RequestedMetrics))
+	for _, metric := range r.RequestedMetrics {
+		if err := pe.putCompactString(metric); err != nil {
+			return err
+		}
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *GetTelemetrySubscriptionsResponse) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	throttleTime, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
+
+	clientInstanceID, err := pd.getRawBytes(len(r.ClientInstanceId))
+	if err != nil {
+		return err
+	}
+	copy(r.ClientInstanceId[:], clientInstanceID)
+	if r.SubscriptionId, err = pd.getInt32(); err != nil {
+		return err
+	}
+
+	n, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	r.AcceptedCompressionTypes = nil
+	if n > 0 {
+		r.AcceptedCompressionTypes = make([]CompressionCodec, n)
+		for i := range r.AcceptedCompressionTypes {
+			codec, err := pd.getInt8()
+			if err != nil {
+				return err
+			}
+			r.AcceptedCompressionTypes[i] = CompressionCodec(codec)
+		}
+	}
+
+	if r.PushIntervalMs, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if r.TelemetryMaxBytes, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if r.DeltaTemporality, err = pd.getBool(); err != nil {
+		return err
+	}
+
+	if n, err = pd.getCompactArrayLength(); err != nil {
+		return err
+	}
+	r.RequestedMetrics = nil
+	if n > 0 {
+		r.RequestedMetrics = make([]string, n)
+		for i := range r.RequestedMetrics {
+			if r.RequestedMetrics[i], err = pd.getCompactString(); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *GetTelemetrySubscriptionsResponse) key() int16 {
+	return 71
+}
+
+func (r *GetTelemetrySubscriptionsResponse) version() int16 {
+	return r.Version
+}
+
+func (r *GetTelemetrySubscriptionsResponse) headerVersion() int16 {
+	return 1
+}
+
+func (r *GetTelemetrySubscriptionsResponse) requiredVersion() KafkaVersion {
+	return V3_7_0_0
+}
+
+func (r *GetTelemetrySubscriptionsResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
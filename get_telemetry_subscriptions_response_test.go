@@ -0,0 +1,60 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	getTelemetrySubscriptionsResponseNoMetrics = []byte{
+		0, 0, 0, 0, // ThrottleTimeMs
+		0, 0, // ErrorCode
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, // ClientInstanceId
+		0, 0, 0, 0, // SubscriptionId
+		1,                // AcceptedCompressionTypes array, empty
+		0, 0, 0x75, 0x30, // PushIntervalMs
+		0, 0, 0, 0, // TelemetryMaxBytes
+		0, // DeltaTemporality
+		1, // RequestedMetrics array, empty
+		0, // empty tagged fields
+	}
+
+	getTelemetrySubscriptionsResponseMetrics = []byte{
+		0, 0, 0, 100, // ThrottleTimeMs
+		0, 0, // ErrorCode
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, // ClientInstanceId
+		0, 0, 0, 7, // SubscriptionId
+		3,    // AcceptedCompressionTypes array, length 2
+		4, 1, // ZSTD, GZIP
+		0, 0, 0x75, 0x30, // PushIntervalMs
+		0, 0, 0x40, 0, // TelemetryMaxBytes
+		1, // DeltaTemporality
+		3, // RequestedMetrics array, length 2
+		1, // empty string
+		0x11, 'o', 'r', 'g', '.', 'a', 'p', 'a', 'c', 'h', 'e', '.', 'k', 'a', 'f', 'k', 'a',
+		0, // empty tagged fields
+	}
+)
+
+func TestGetTelemetrySubscriptionsResponse(t *testing.T) {
+	id := Uuid{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	response := &GetTelemetrySubscriptionsResponse{
+		Version:          0,
+		ClientInstanceId: id,
+		PushIntervalMs:   30000,
+	}
+	testResponse(t, "no metrics", response, getTelemetrySubscriptionsResponseNoMetrics)
+
+	response = &GetTelemetrySubscriptionsResponse{
+		Version:                  0,
+		ThrottleTime:             100 * time.Millisecond,
+		ClientInstanceId:         id,
+		SubscriptionId:           7,
+		AcceptedCompressionTypes: []CompressionCodec{CompressionZSTD, CompressionGZIP},
+		PushIntervalMs:           30000,
+		TelemetryMaxBytes:        16384,
+		DeltaTemporality:         true,
+		RequestedMetrics:         []string{"", "org.apache.kafka"},
+	}
+	testResponse(t, "metrics", response, getTelemetrySubscriptionsResponseMetrics)
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/jcmturner/gofork/encoding/asn1"
 	"github.com/jcmturner/gokrb5/v8/asn1tools"
+	"github.com/jcmturner/gokrb5/v8/credentials"
 	"github.com/jcmturner/gokrb5/v8/gssapi"
 	"github.com/jcmturner/gokrb5/v8/iana/chksumtype"
 	"github.com/jcmturner/gokrb5/v8/iana/keyusage"
@@ -23,6 +24,7 @@ const (
 	GSS_API_GENERIC_TAG = 0x60
 	KRB5_USER_AUTH      = 1
 	KRB5_KEYTAB_AUTH    = 2
+	KRB5_CCACHE_AUTH    = 3
 	GSS_API_INITIAL     = 1
 	GSS_API_VERIFY      = 2
 	GSS_API_FINISH      = 3
@@ -37,6 +39,13 @@ type GSSAPIConfig struct {
 	Password           string
 	Realm              string
 	DisablePAFXFAST    bool
+	// CCachePath is the credentials cache used with KRB5_CCACHE_AUTH, for
+	// example one kept renewed by k5start or a kinit sidecar. It is loaded
+	// again for each authentication so that renewed tickets are picked up.
+	CCachePath string
+	// CCacheFunc returns the credentials cache used with KRB5_CCACHE_AUTH
+	// instead of loading CCachePath, for custom credential sources.
+	CCacheFunc func() (*credentials.CCache, error)
 }
 
 type GSSAPIKerberosAuth struct {
@@ -45,6 +54,9 @@ type GSSAPIKerberosAuth struct {
 	encKey                types.EncryptionKey
 	NewKerberosClientFunc func(config *GSSAPIConfig) (KerberosClient, error)
 	step                  int
+	// framed is true when the tokens are wrapped in SaslAuthenticate
+	// requests, which return the session lifetime to re-authenticate before
+	framed bool
 }
 
 type KerberosClient interface {
@@ -55,6 +67,31 @@ type KerberosClient interface {
 	Destroy()
 }
 
+// writeMessage sends the GSS-API token to kafka, wrapped in a SaslAuthenticate
+// request if the SASL v1 handshake was done
+func (krbAuth *GSSAPIKerberosAuth) writeMessage(broker *Broker, payload []byte) (int, error) {
+	if !krbAuth.framed {
+		return krbAuth.writePackage(broker, payload)
+	}
+	bytesWritten, err := broker.sendSaslAuthenticateRequest(broker.correlationID, payload)
+	if err != nil {
+		return bytesWritten, err
+	}
+	broker.correlationID++
+	return bytesWritten, nil
+}
+
+// readMessage reads the GSS-API token of kafka, wrapped in a SaslAuthenticate
+// response if the SASL v1 handshake was done
+func (krbAuth *GSSAPIKerberosAuth) readMessage(broker *Broker) ([]byte, int, error) {
+	if !krbAuth.framed {
+		return krbAuth.readPackage(broker)
+	}
+	res := &SaslAuthenticateResponse{}
+	bytesRead, err := broker.receiveSASLServerResponse(res, broker.correlationID-1)
+	return res.SaslAuthBytes, bytesRead, err
+}
+
 // writePackage appends length in big endian before the payload, and sends it to kafka
 func (krbAuth *GSSAPIKerberosAuth) writePackage(broker *Broker, payload []byte) (int, error) {
 	length := uint64(len(payload))
@@ -195,8 +232,18 @@ func (krbAuth *GSSAPIKerberosAuth) initSecContext(bytes []byte, kerberosClient K
 	return nil, nil
 }
 
-/* This does the handshake for authorization */
+// Authorize does the handshake for authorization. With the SASL v1 handshake the tokens are wrapped in SaslAuthenticate
+// requests, so that the session is re-authenticated with a new service ticket
+// before the broker expires it. A new client logs in for each authentication,
+// from the keytab, password or reloaded credentials cache.
 func (krbAuth *GSSAPIKerberosAuth) Authorize(broker *Broker) error {
+	krbAuth.framed = broker.conf.Net.SASL.Handshake && broker.conf.Net.SASL.Version == SASLHandshakeV1
+	if krbAuth.framed {
+		if err := broker.sendAndReceiveSASLHandshake(SASLTypeGSSAPI, SASLHandshakeV1); err != nil {
+			return err
+		}
+	}
+
 	kerberosClient, err := krbAuth.NewKerberosClientFunc(krbAuth.Config)
 	if err != nil {
 		Logger.Printf("Kerberos client error: %s", err)
@@ -231,22 +278,25 @@ func (krbAuth *GSSAPIKerberosAuth) Authorize(broker *Broker) error {
 			return err
 		}
 		requestTime := time.Now()
-		bytesWritten, err := krbAuth.writePackage(broker, packBytes)
+		bytesWritten, err := krbAuth.writeMessage(broker, packBytes)
 		if err != nil {
 			Logger.Printf("Error while performing GSSAPI Kerberos Authentication: %s\n", err)
 			return err
 		}
 		broker.updateOutgoingCommunicationMetrics(bytesWritten)
-		if krbAuth.step == GSS_API_VERIFY {
+		// every SaslAuthenticate request has a response, the last one being
+		// empty
+		if krbAuth.step == GSS_API_VERIFY || krbAuth.framed {
 			bytesRead := 0
-			receivedBytes, bytesRead, err = krbAuth.readPackage(broker)
+			receivedBytes, bytesRead, err = krbAuth.readMessage(broker)
 			requestLatency := time.Since(requestTime)
 			broker.updateIncomingCommunicationMetrics(bytesRead, requestLatency)
 			if err != nil {
 				Logger.Printf("Error while performing GSSAPI Kerberos Authentication: %s\n", err)
 				return err
 			}
-		} else if krbAuth.step == GSS_API_FINISH {
+		}
+		if krbAuth.step == GSS_API_FINISH {
 			return nil
 		}
 	}
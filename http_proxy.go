@@ -0,0 +1,90 @@
+package sarama
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// httpProxyDialer opens connections through an HTTP proxy with the CONNECT
+// method, over TLS when tlsConfig is not nil.
+type httpProxyDialer struct {
+	address   string
+	user      string
+	password  string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+	forward   *net.Dialer
+}
+
+func (d *httpProxyDialer) Dial(network, address string) (net.Conn, error) {
+	conn, err := d.forward.Dial(network, d.address)
+	if err != nil {
+		return nil, err
+	}
+
+	tunnel, err := d.connect(conn, address)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to connect to %s through HTTP proxy %s: %w", address, d.address, err)
+	}
+	return tunnel, nil
+}
+
+// connect asks the proxy to tunnel conn to address, bounding the exchange
+// with the proxy to the dial timeout
+func (d *httpProxyDialer) connect(conn net.Conn, address string) (net.Conn, error) {
+	if d.timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(d.timeout)); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.tlsConfig != nil {
+		tlsConn := tls.Client(conn, validServerNameTLS(d.address, d.tlsConfig))
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if d.user != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(d.user + ":" + d.password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("unexpected response %q", resp.Status)
+	}
+	// the body of a successful response is the tunnel itself and must not be
+	// read
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	if reader.Buffered() > 0 {
+		// the broker never speaks first, but do not lose what the proxy sent
+		return &bufConn{Conn: conn, buf: reader}, nil
+	}
+	return conn, nil
+}
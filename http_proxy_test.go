@@ -0,0 +1,122 @@
+package sarama
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newConnectProxy returns an HTTP handler tunneling CONNECT requests
+// authenticated with the given Proxy-Authorization to target, recording the
+// requested addresses
+func newConnectProxy(t *testing.T, authorization, target string, requested chan<- string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Proxy-Authorization") != authorization {
+			http.Error(w, "authentication required", http.StatusProxyAuthRequired)
+			return
+		}
+		requested <- r.Host
+
+		upstream, err := net.Dial("tcp", target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer upstream.Close()
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+
+		go func() {
+			_, _ = io.Copy(upstream, conn)
+			_ = upstream.Close()
+		}()
+		_, _ = io.Copy(conn, upstream)
+	})
+}
+
+func TestBrokerHTTPProxy(t *testing.T) {
+	for _, withTLS := range []bool{false, true} {
+		mb := NewMockBroker(t, 0)
+		mb.SetHandlerByMap(map[string]MockResponse{
+			"MetadataRequest": NewMockMetadataResponse(t),
+		})
+
+		requested := make(chan string, 1)
+		// "bastion:secret"
+		handler := newConnectProxy(t, "Basic YmFzdGlvbjpzZWNyZXQ=", mb.Addr(), requested)
+		var proxy *httptest.Server
+		if withTLS {
+			proxy = httptest.NewTLSServer(handler)
+		} else {
+			proxy = httptest.NewServer(handler)
+		}
+
+		conf := NewTestConfig()
+		conf.Net.Proxy.Enable = true
+		conf.Net.Proxy.Type = ProxyTypeHTTP
+		conf.Net.Proxy.Address = proxy.Listener.Addr().String()
+		conf.Net.Proxy.User = "bastion"
+		conf.Net.Proxy.Password = "secret"
+		if withTLS {
+			conf.Net.Proxy.TLS.Enable = true
+			conf.Net.Proxy.TLS.Config = &tls.Config{RootCAs: proxy.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs}
+			conf.Net.Proxy.TLS.Config.ServerName = "example.com"
+		}
+
+		broker := NewBroker("kafka-1.internal:9092")
+		if err := broker.Open(conf); err != nil {
+			t.Fatal(err)
+		}
+		if connected, err := broker.Connected(); !connected || err != nil {
+			t.Fatalf("TLS %v: expected the broker to be connected through the proxy, got %v", withTLS, err)
+		}
+		if addr := <-requested; addr != "kafka-1.internal:9092" {
+			t.Errorf("TLS %v: expected the proxy to be asked for kafka-1.internal:9092, got %q", withTLS, addr)
+		}
+		if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+			t.Errorf("TLS %v: expected a metadata response relayed by the proxy, got %v", withTLS, err)
+		}
+
+		safeClose(t, broker)
+		proxy.Close()
+		mb.Close()
+	}
+}
+
+func TestBrokerHTTPProxyAuthenticationFailure(t *testing.T) {
+	requested := make(chan string, 1)
+	proxy := httptest.NewServer(newConnectProxy(t, "Basic YmFzdGlvbjpzZWNyZXQ=", "127.0.0.1:0", requested))
+	defer proxy.Close()
+
+	conf := NewTestConfig()
+	conf.Net.Proxy.Enable = true
+	conf.Net.Proxy.Type = ProxyTypeHTTP
+	conf.Net.Proxy.Address = proxy.Listener.Addr().String()
+
+	broker := NewBroker("kafka-1.internal:9092")
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	_, err := broker.Connected()
+	if err == nil || !strings.Contains(err.Error(), "407") {
+		t.Fatalf("expected the proxy to reject the connection with a 407, got %v", err)
+	}
+	if errors.Is(err, ErrNotConnected) {
+		t.Errorf("expected the error of the proxy, got %v", err)
+	}
+}
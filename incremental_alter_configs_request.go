@@ -46,6 +46,9 @@ func (a *IncrementalAlterConfigsRequest) decode(pd packetDecoder, version int16)
 	if err != nil {
 		return err
 	}
+	if resourceCount == -1 {
+		resourceCount = 0
+	}
 
 	a.Resources = make([]*IncrementalAlterConfigsResource, resourceCount)
 	for i := range a.Resources {
@@ -35,6 +35,9 @@ func (a *IncrementalAlterConfigsResponse) decode(pd packetDecoder, version int16
 	if err != nil {
 		return err
 	}
+	if responseCount == -1 {
+		responseCount = 0
+	}
 
 	a.Resources = make([]*AlterConfigsResourceResponse, responseCount)
 
@@ -64,3 +67,7 @@ func (a *IncrementalAlterConfigsResponse) headerVersion() int16 {
 func (a *IncrementalAlterConfigsResponse) requiredVersion() KafkaVersion {
 	return V2_3_0_0
 }
+
+func (a *IncrementalAlterConfigsResponse) throttleTime() time.Duration {
+	return a.ThrottleTime
+}
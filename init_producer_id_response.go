@@ -57,3 +57,7 @@ func (i *InitProducerIDResponse) headerVersion() int16 {
 func (i *InitProducerIDResponse) requiredVersion() KafkaVersion {
 	return V0_11_0_0
 }
+
+func (i *InitProducerIDResponse) throttleTime() time.Duration {
+	return i.ThrottleTime
+}
@@ -0,0 +1,211 @@
+/*
+Package kafkatest runs a single node Kafka cluster in KRaft mode in a Docker
+container for the functional tests of the applications using Sarama, so that
+they do not have to copy the boilerplate to start it, connect to it, create
+scratch topics and tear it all down.
+
+	func TestProduce(t *testing.T) {
+		cluster := kafkatest.Start(t)
+		topic := cluster.CreateTopic(t, 3)
+		producer, err := sarama.NewSyncProducer(cluster.Addrs(), cluster.Config())
+		...
+	}
+
+The cluster is started with testcontainers, and its container is terminated
+by the cleanup of the test. Tests are skipped when Docker is not available.
+A cluster shared by the tests of a package is started by NewCluster in
+TestMain instead, and terminated by Close.
+
+It is a module of its own so that the dependencies of testcontainers are only
+required by the applications using it.
+
+NOTE: this package currently does not fall under the API stability
+guarantee of Sarama as it is still considered experimental.
+*/
+package kafkatest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/kafka"
+)
+
+// DefaultImage is the image of the container the cluster runs in.
+const DefaultImage = "confluentinc/confluent-local:7.5.0"
+
+// DefaultVersion is the version of Kafka shipped by DefaultImage.
+var DefaultVersion = sarama.V3_5_0_0
+
+type options struct {
+	image   string
+	version sarama.KafkaVersion
+	config  func(*sarama.Config)
+}
+
+// Option changes how NewCluster and Start run the cluster.
+type Option func(o *options)
+
+// WithImage runs the cluster in the given confluentinc/confluent-local image,
+// shipping the given version of Kafka, rather than in DefaultImage.
+func WithImage(image string, version sarama.KafkaVersion) Option {
+	return func(o *options) {
+		o.image = image
+		o.version = version
+	}
+}
+
+// WithConfig calls f on every Config returned by Cluster.Config, after it is
+// set up to connect to the cluster.
+func WithConfig(f func(*sarama.Config)) Option {
+	return func(o *options) {
+		o.config = f
+	}
+}
+
+// Cluster is a single node Kafka cluster running in a container.
+type Cluster struct {
+	container *kafka.KafkaContainer
+	addrs     []string
+	opts      options
+}
+
+// NewCluster starts a cluster and waits until its broker is ready. It must be
+// terminated by Close.
+func NewCluster(ctx context.Context, opts ...Option) (*Cluster, error) {
+	c := &Cluster{opts: options{image: DefaultImage, version: DefaultVersion}}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+
+	container, err := kafka.Run(ctx, c.opts.image, kafka.WithClusterID("kafkatest"))
+	if err != nil {
+		if container != nil {
+			_ = container.Terminate(ctx)
+		}
+		return nil, fmt.Errorf("kafkatest: failed to start the container: %w", err)
+	}
+	c.container = container
+
+	if c.addrs, err = container.Brokers(ctx); err != nil {
+		_ = container.Terminate(ctx)
+		return nil, fmt.Errorf("kafkatest: failed to get the address of the broker: %w", err)
+	}
+	return c, nil
+}
+
+// Start starts a cluster for the test t, which is skipped if Docker is not
+// available and fails if the cluster does not start. The cluster is
+// terminated by the cleanup of the test.
+func Start(t *testing.T, opts ...Option) *Cluster {
+	t.Helper()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	c, err := NewCluster(context.Background(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	return c
+}
+
+// Addrs returns the addresses to connect to the broker of the cluster.
+func (c *Cluster) Addrs() []string {
+	return append([]string(nil), c.addrs...)
+}
+
+// Config returns a new Config set up for the cluster: its version is the one
+// of Kafka in the container, and the SyncProducer requirements are met.
+func (c *Cluster) Config() *sarama.Config {
+	config := sarama.NewConfig()
+	config.Version = c.opts.version
+	config.ClientID = "kafkatest"
+	config.Producer.Return.Successes = true
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	if c.opts.config != nil {
+		c.opts.config(config)
+	}
+	return config
+}
+
+// NewClient returns a Client connected to the cluster with Config, which is
+// closed by the cleanup of the test t.
+func (c *Cluster) NewClient(t testing.TB) sarama.Client {
+	t.Helper()
+
+	client, err := sarama.NewClient(c.addrs, c.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return client
+}
+
+// CreateTopic creates a scratch topic with the given number of partitions,
+// named after the test t with a random suffix so that tests sharing the
+// cluster do not see each other's messages. It is deleted by the cleanup of
+// the test.
+func (c *Cluster) CreateTopic(t testing.TB, partitions int32) string {
+	t.Helper()
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatal(err)
+	}
+	topic := fmt.Sprintf("%s-%s", sanitizeTopic(t.Name()), hex.EncodeToString(suffix))
+
+	admin, err := sarama.NewClusterAdmin(c.addrs, c.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	if err := admin.CreateTopic(topic, &sarama.TopicDetail{NumPartitions: partitions, ReplicationFactor: 1}, false); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin, err := sarama.NewClusterAdmin(c.addrs, c.Config())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer admin.Close()
+		if err := admin.DeleteTopic(topic); err != nil {
+			t.Error(err)
+		}
+	})
+	return topic
+}
+
+// Close terminates the container of the cluster.
+func (c *Cluster) Close() error {
+	return c.container.Terminate(context.Background())
+}
+
+// sanitizeTopic replaces the characters of a test name which are not valid in
+// a topic name, and truncates it to leave room for the suffix.
+func sanitizeTopic(name string) string {
+	buf := []byte(name)
+	for i, b := range buf {
+		switch {
+		case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9', b == '.', b == '_', b == '-':
+		default:
+			buf[i] = '_'
+		}
+	}
+	if len(buf) > 200 {
+		buf = buf[:200]
+	}
+	return string(buf)
+}
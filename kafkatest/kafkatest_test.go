@@ -0,0 +1,39 @@
+package kafkatest
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestSanitizeTopic(t *testing.T) {
+	if name := sanitizeTopic("TestProduce/with keys#1"); name != "TestProduce_with_keys_1" {
+		t.Errorf("Unexpected topic name %q", name)
+	}
+}
+
+func TestCluster(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping the Kafka container in short mode")
+	}
+	cluster := Start(t)
+	topic := cluster.CreateTopic(t, 2)
+
+	producer, err := sarama.NewSyncProducer(cluster.Addrs(), cluster.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+	if _, _, err := producer.SendMessage(&sarama.ProducerMessage{Topic: topic, Partition: 0, Value: sarama.StringEncoder("hello")}); err != nil {
+		t.Fatal(err)
+	}
+
+	client := cluster.NewClient(t)
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(partitions) != 2 {
+		t.Errorf("Expected 2 partitions, got %d", len(partitions))
+	}
+}
@@ -3,6 +3,7 @@ package sarama
 import (
 	krb5client "github.com/jcmturner/gokrb5/v8/client"
 	krb5config "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
 	"github.com/jcmturner/gokrb5/v8/keytab"
 	"github.com/jcmturner/gokrb5/v8/types"
 )
@@ -32,7 +33,16 @@ func NewKerberosClient(config *GSSAPIConfig) (KerberosClient, error) {
 
 func createClient(config *GSSAPIConfig, cfg *krb5config.Config) (KerberosClient, error) {
 	var client *krb5client.Client
-	if config.AuthType == KRB5_KEYTAB_AUTH {
+	if config.AuthType == KRB5_CCACHE_AUTH {
+		ccache, err := loadCCache(config)
+		if err != nil {
+			return nil, err
+		}
+		client, err = krb5client.NewFromCCache(ccache, cfg, krb5client.DisablePAFXFAST(config.DisablePAFXFAST))
+		if err != nil {
+			return nil, err
+		}
+	} else if config.AuthType == KRB5_KEYTAB_AUTH {
 		kt, err := keytab.Load(config.KeyTabPath)
 		if err != nil {
 			return nil, err
@@ -44,3 +54,11 @@ func createClient(config *GSSAPIConfig, cfg *krb5config.Config) (KerberosClient,
 	}
 	return &KerberosGoKrb5Client{*client}, nil
 }
+
+// loadCCache returns the credentials cache of CCacheFunc, or loads CCachePath
+func loadCCache(config *GSSAPIConfig) (*credentials.CCache, error) {
+	if config.CCacheFunc != nil {
+		return config.CCacheFunc()
+	}
+	return credentials.LoadCCache(config.CCachePath)
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	krbcfg "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
 )
 
 /*
@@ -150,3 +151,25 @@ func TestCreateWithDisablePAFXFAST(t *testing.T) {
 		t.Errorf("Expected error:%s, got:%s.", err, expectedErr)
 	}
 }
+
+func TestCreateWithCCacheFunc(t *testing.T) {
+	kerberosConfig, err := krbcfg.NewFromString(krb5cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedErr := errors.New("ccache unavailable")
+	clientConfig := NewTestConfig()
+	clientConfig.Net.SASL.GSSAPI.AuthType = KRB5_CCACHE_AUTH
+	clientConfig.Net.SASL.GSSAPI.CCacheFunc = func() (*credentials.CCache, error) {
+		return nil, expectedErr
+	}
+	if _, err := createClient(&clientConfig.Net.SASL.GSSAPI, kerberosConfig); err != expectedErr {
+		t.Errorf("Expected error:%s, got:%s.", expectedErr, err)
+	}
+
+	clientConfig.Net.SASL.GSSAPI.CCacheFunc = nil
+	clientConfig.Net.SASL.GSSAPI.CCachePath = "nonexist.ccache"
+	if _, err := createClient(&clientConfig.Net.SASL.GSSAPI, kerberosConfig); err == nil {
+		t.Error("Expected an error for a missing credentials cache")
+	}
+}
@@ -1,30 +1,125 @@
 package sarama
 
+// MemberIdentity identifies a member leaving a group in a LeaveGroupRequest
+// of version 3 or higher. Static members are identified by their
+// GroupInstanceId, dynamic members by their MemberId.
+type MemberIdentity struct {
+	MemberId        string
+	GroupInstanceId *string
+}
+
+func (m *MemberIdentity) encode(pe packetEncoder, version int16) error {
+	if version >= 4 {
+		if err := pe.putCompactString(m.MemberId); err != nil {
+			return err
+		}
+		if err := pe.putNullableCompactString(m.GroupInstanceId); err != nil {
+			return err
+		}
+		pe.putEmptyTaggedFieldArray()
+		return nil
+	}
+
+	if err := pe.putString(m.MemberId); err != nil {
+		return err
+	}
+	return pe.putNullableString(m.GroupInstanceId)
+}
+
+func (m *MemberIdentity) decode(pd packetDecoder, version int16) (err error) {
+	if version >= 4 {
+		if m.MemberId, err = pd.getCompactString(); err != nil {
+			return err
+		}
+		if m.GroupInstanceId, err = pd.getCompactNullableString(); err != nil {
+			return err
+		}
+		_, err = pd.getEmptyTaggedFieldArray()
+		return err
+	}
+
+	if m.MemberId, err = pd.getString(); err != nil {
+		return err
+	}
+	m.GroupInstanceId, err = pd.getNullableString()
+	return err
+}
+
 type LeaveGroupRequest struct {
-	GroupId  string
+	Version int16
+	GroupId string
+	// MemberId is used by versions 0 to 2, later versions use Members
 	MemberId string
+	// Members is used from version 3 to make several members leave at once
+	Members []MemberIdentity
 }
 
 func (r *LeaveGroupRequest) encode(pe packetEncoder) error {
-	if err := pe.putString(r.GroupId); err != nil {
+	if r.Version >= 4 {
+		if err := pe.putCompactString(r.GroupId); err != nil {
+			return err
+		}
+	} else if err := pe.putString(r.GroupId); err != nil {
 		return err
 	}
-	if err := pe.putString(r.MemberId); err != nil {
+
+	if r.Version < 3 {
+		return pe.putString(r.MemberId)
+	}
+
+	if r.Version >= 4 {
+		pe.putCompactArrayLength(len(r.Members))
+	} else if err := pe.putArrayLength(len(r.Members)); err != nil {
 		return err
 	}
+	for i := range r.Members {
+		if err := r.Members[i].encode(pe, r.Version); err != nil {
+			return err
+		}
+	}
 
+	if r.Version >= 4 {
+		pe.putEmptyTaggedFieldArray()
+	}
 	return nil
 }
 
 func (r *LeaveGroupRequest) decode(pd packetDecoder, version int16) (err error) {
-	if r.GroupId, err = pd.getString(); err != nil {
-		return
+	r.Version = version
+	if r.Version >= 4 {
+		r.GroupId, err = pd.getCompactString()
+	} else {
+		r.GroupId, err = pd.getString()
+	}
+	if err != nil {
+		return err
 	}
-	if r.MemberId, err = pd.getString(); err != nil {
-		return
+
+	if r.Version < 3 {
+		r.MemberId, err = pd.getString()
+		return err
 	}
 
-	return nil
+	var n int
+	if r.Version >= 4 {
+		n, err = pd.getCompactArrayLength()
+	} else {
+		n, err = pd.getArrayLength()
+	}
+	if err != nil {
+		return err
+	}
+	r.Members = make([]MemberIdentity, n)
+	for i := range r.Members {
+		if err := r.Members[i].decode(pd, r.Version); err != nil {
+			return err
+		}
+	}
+
+	if r.Version >= 4 {
+		_, err = pd.getEmptyTaggedFieldArray()
+	}
+	return err
 }
 
 func (r *LeaveGroupRequest) key() int16 {
@@ -32,13 +127,25 @@ func (r *LeaveGroupRequest) key() int16 {
 }
 
 func (r *LeaveGroupRequest) version() int16 {
-	return 0
+	return r.Version
 }
 
 func (r *LeaveGroupRequest) headerVersion() int16 {
+	if r.Version >= 4 {
+		return 2
+	}
 	return 1
 }
 
 func (r *LeaveGroupRequest) requiredVersion() KafkaVersion {
-	return V0_9_0_0
+	switch r.Version {
+	case 1:
+		return V0_11_0_0
+	case 2:
+		return V2_0_0_0
+	case 3, 4:
+		return V2_4_0_0
+	default:
+		return V0_9_0_0
+	}
 }
@@ -2,14 +2,59 @@ package sarama
 
 import "testing"
 
-var basicLeaveGroupRequest = []byte{
-	0, 3, 'f', 'o', 'o',
-	0, 3, 'b', 'a', 'r',
-}
+var (
+	basicLeaveGroupRequest = []byte{
+		0, 3, 'f', 'o', 'o',
+		0, 3, 'b', 'a', 'r',
+	}
+
+	leaveGroupRequestV3 = []byte{
+		0, 3, 'f', 'o', 'o',
+		0, 0, 0, 2, // Members
+		0, 3, 'm', 'i', 'd', // MemberId
+		255, 255, // null GroupInstanceId
+		0, 0, // empty MemberId
+		0, 3, 'g', 'i', 'd', // GroupInstanceId
+	}
+
+	leaveGroupRequestV4 = []byte{
+		4, 'f', 'o', 'o',
+		3,                // Members
+		4, 'm', 'i', 'd', // MemberId
+		0,                // null GroupInstanceId
+		0,                // empty tagged fields
+		1,                // empty MemberId
+		4, 'g', 'i', 'd', // GroupInstanceId
+		0, // empty tagged fields
+		0, // empty tagged fields
+	}
+)
 
 func TestLeaveGroupRequest(t *testing.T) {
 	request := new(LeaveGroupRequest)
 	request.GroupId = "foo"
 	request.MemberId = "bar"
 	testRequest(t, "basic", request, basicLeaveGroupRequest)
+
+	request.Version = 1
+	testRequest(t, "v1", request, basicLeaveGroupRequest)
+
+	request.Version = 2
+	testRequest(t, "v2", request, basicLeaveGroupRequest)
+}
+
+func TestLeaveGroupRequestMembers(t *testing.T) {
+	groupInstanceID := "gid"
+	request := &LeaveGroupRequest{
+		Version: 3,
+		GroupId: "foo",
+		Members: []MemberIdentity{
+			{MemberId: "mid"},
+			{GroupInstanceId: &groupInstanceID},
+		},
+	}
+	testRequest(t, "v3", request, leaveGroupRequestV3)
+
+	request.Version = 4
+	testRequest(t, "v4", request, leaveGroupRequestV4)
 }
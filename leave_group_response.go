@@ -1,22 +1,137 @@
 package sarama
 
+import "time"
+
+// MemberResponse is the result of a member leaving a group in a
+// LeaveGroupResponse of version 3 or higher.
+type MemberResponse struct {
+	MemberId        string
+	GroupInstanceId *string
+	Err             KError
+}
+
+func (m *MemberResponse) encode(pe packetEncoder, version int16) error {
+	if version >= 4 {
+		if err := pe.putCompactString(m.MemberId); err != nil {
+			return err
+		}
+		if err := pe.putNullableCompactString(m.GroupInstanceId); err != nil {
+			return err
+		}
+	} else {
+		if err := pe.putString(m.MemberId); err != nil {
+			return err
+		}
+		if err := pe.putNullableString(m.GroupInstanceId); err != nil {
+			return err
+		}
+	}
+	pe.putInt16(int16(m.Err))
+
+	if version >= 4 {
+		pe.putEmptyTaggedFieldArray()
+	}
+	return nil
+}
+
+func (m *MemberResponse) decode(pd packetDecoder, version int16) (err error) {
+	if version >= 4 {
+		if m.MemberId, err = pd.getCompactString(); err != nil {
+			return err
+		}
+		if m.GroupInstanceId, err = pd.getCompactNullableString(); err != nil {
+			return err
+		}
+	} else {
+		if m.MemberId, err = pd.getString(); err != nil {
+			return err
+		}
+		if m.GroupInstanceId, err = pd.getNullableString(); err != nil {
+			return err
+		}
+	}
+
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	m.Err = KError(kerr)
+
+	if version >= 4 {
+		_, err = pd.getEmptyTaggedFieldArray()
+	}
+	return err
+}
+
 type LeaveGroupResponse struct {
-	Err KError
+	Version      int16
+	ThrottleTime int32
+	Err          KError
+	// Members holds the result of each member leaving the group from version 3
+	Members []MemberResponse
 }
 
 func (r *LeaveGroupResponse) encode(pe packetEncoder) error {
+	if r.Version >= 1 {
+		pe.putInt32(r.ThrottleTime)
+	}
 	pe.putInt16(int16(r.Err))
+
+	if r.Version >= 3 {
+		if r.Version >= 4 {
+			pe.putCompactArrayLength(len(r.Members))
+		} else if err := pe.putArrayLength(len(r.Members)); err != nil {
+			return err
+		}
+		for i := range r.Members {
+			if err := r.Members[i].encode(pe, r.Version); err != nil {
+				return err
+			}
+		}
+	}
+
+	if r.Version >= 4 {
+		pe.putEmptyTaggedFieldArray()
+	}
 	return nil
 }
 
 func (r *LeaveGroupResponse) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.Version >= 1 {
+		if r.ThrottleTime, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+
 	kerr, err := pd.getInt16()
 	if err != nil {
 		return err
 	}
 	r.Err = KError(kerr)
 
-	return nil
+	if r.Version >= 3 {
+		var n int
+		if r.Version >= 4 {
+			n, err = pd.getCompactArrayLength()
+		} else {
+			n, err = pd.getArrayLength()
+		}
+		if err != nil {
+			return err
+		}
+		r.Members = make([]MemberResponse, n)
+		for i := range r.Members {
+			if err := r.Members[i].decode(pd, r.Version); err != nil {
+				return err
+			}
+		}
+	}
+
+	if r.Version >= 4 {
+		_, err = pd.getEmptyTaggedFieldArray()
+	}
+	return err
 }
 
 func (r *LeaveGroupResponse) key() int16 {
@@ -24,13 +139,29 @@ func (r *LeaveGroupResponse) key() int16 {
 }
 
 func (r *LeaveGroupResponse) version() int16 {
-	return 0
+	return r.Version
 }
 
 func (r *LeaveGroupResponse) headerVersion() int16 {
+	if r.Version >= 4 {
+		return 1
+	}
 	return 0
 }
 
 func (r *LeaveGroupResponse) requiredVersion() KafkaVersion {
-	return V0_9_0_0
+	switch r.Version {
+	case 1:
+		return V0_11_0_0
+	case 2:
+		return V2_0_0_0
+	case 3, 4:
+		return V2_4_0_0
+	default:
+		return V0_9_0_0
+	}
+}
+
+func (r *LeaveGroupResponse) throttleTime() time.Duration {
+	return time.Duration(r.ThrottleTime) * time.Millisecond
 }
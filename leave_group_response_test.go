@@ -5,6 +5,38 @@ import "testing"
 var (
 	leaveGroupResponseNoError   = []byte{0x00, 0x00}
 	leaveGroupResponseWithError = []byte{0, 25}
+
+	leaveGroupResponseV1 = []byte{
+		0, 0, 0, 100, // ThrottleTime
+		0, 0, // Err
+	}
+
+	leaveGroupResponseV3 = []byte{
+		0, 0, 0, 100, // ThrottleTime
+		0, 0, // Err
+		0, 0, 0, 2, // Members
+		0, 3, 'm', 'i', 'd', // MemberId
+		255, 255, // null GroupInstanceId
+		0, 0, // Err
+		0, 0, // empty MemberId
+		0, 3, 'g', 'i', 'd', // GroupInstanceId
+		0, 25, // Err
+	}
+
+	leaveGroupResponseV4 = []byte{
+		0, 0, 0, 100, // ThrottleTime
+		0, 0, // Err
+		3,                // Members
+		4, 'm', 'i', 'd', // MemberId
+		0,    // null GroupInstanceId
+		0, 0, // Err
+		0,                // empty tagged fields
+		1,                // empty MemberId
+		4, 'g', 'i', 'd', // GroupInstanceId
+		0, 25, // Err
+		0, // empty tagged fields
+		0, // empty tagged fields
+	}
 )
 
 func TestLeaveGroupResponse(t *testing.T) {
@@ -21,4 +53,23 @@ func TestLeaveGroupResponse(t *testing.T) {
 	if response.Err != ErrUnknownMemberId {
 		t.Error("Decoding error failed: ErrUnknownMemberId expected but found", response.Err)
 	}
+
+	response = &LeaveGroupResponse{Version: 1, ThrottleTime: 100}
+	testResponse(t, "v1", response, leaveGroupResponseV1)
+}
+
+func TestLeaveGroupResponseMembers(t *testing.T) {
+	groupInstanceID := "gid"
+	response := &LeaveGroupResponse{
+		Version:      3,
+		ThrottleTime: 100,
+		Members: []MemberResponse{
+			{MemberId: "mid", Err: ErrNoError},
+			{GroupInstanceId: &groupInstanceID, Err: ErrUnknownMemberId},
+		},
+	}
+	testResponse(t, "v3", response, leaveGroupResponseV3)
+
+	response.Version = 4
+	testResponse(t, "v4", response, leaveGroupResponseV4)
 }
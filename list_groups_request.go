@@ -1,13 +1,70 @@
 package sarama
 
-type ListGroupsRequest struct{}
+type ListGroupsRequest struct {
+	Version int16
+	// StatesFilter only lists the groups in the given states, from version 4
+	StatesFilter []string
+	// TypesFilter only lists the groups of the given types, from version 5
+	TypesFilter []string
+}
+
+func (r *ListGroupsRequest) encodeFilter(pe packetEncoder, filter []string) error {
+	pe.putCompactArrayLength(len(filter))
+	for _, value := range filter {
+		if err := pe.putCompactString(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ListGroupsRequest) decodeFilter(pd packetDecoder) ([]string, error) {
+	n, err := pd.getCompactArrayLength()
+	if err != nil || n <= 0 {
+		return nil, err
+	}
+	filter := make([]string, n)
+	for i := range filter {
+		if filter[i], err = pd.getCompactString(); err != nil {
+			return nil, err
+		}
+	}
+	return filter, nil
+}
 
 func (r *ListGroupsRequest) encode(pe packetEncoder) error {
+	if r.Version >= 4 {
+		if err := r.encodeFilter(pe, r.StatesFilter); err != nil {
+			return err
+		}
+	}
+	if r.Version >= 5 {
+		if err := r.encodeFilter(pe, r.TypesFilter); err != nil {
+			return err
+		}
+	}
+	if r.Version >= 3 {
+		pe.putEmptyTaggedFieldArray()
+	}
 	return nil
 }
 
 func (r *ListGroupsRequest) decode(pd packetDecoder, version int16) (err error) {
-	return nil
+	r.Version = version
+	if r.Version >= 4 {
+		if r.StatesFilter, err = r.decodeFilter(pd); err != nil {
+			return err
+		}
+	}
+	if r.Version >= 5 {
+		if r.TypesFilter, err = r.decodeFilter(pd); err != nil {
+			return err
+		}
+	}
+	if r.Version >= 3 {
+		_, err = pd.getEmptyTaggedFieldArray()
+	}
+	return err
 }
 
 func (r *ListGroupsRequest) key() int16 {
@@ -15,13 +72,29 @@ func (r *ListGroupsRequest) key() int16 {
 }
 
 func (r *ListGroupsRequest) version() int16 {
-	return 0
+	return r.Version
 }
 
 func (r *ListGroupsRequest) headerVersion() int16 {
+	if r.Version >= 3 {
+		return 2
+	}
 	return 1
 }
 
 func (r *ListGroupsRequest) requiredVersion() KafkaVersion {
-	return V0_9_0_0
+	switch r.Version {
+	case 1:
+		return V0_11_0_0
+	case 2:
+		return V2_0_0_0
+	case 3:
+		return V2_4_0_0
+	case 4:
+		return V2_6_0_0
+	case 5:
+		return V3_8_0_0
+	default:
+		return V0_9_0_0
+	}
 }
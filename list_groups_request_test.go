@@ -2,6 +2,37 @@ package sarama
 
 import "testing"
 
+var (
+	listGroupsRequestV3 = []byte{
+		0, // empty tagged fields
+	}
+
+	listGroupsRequestV4 = []byte{
+		2,                          // StatesFilter
+		6, 'E', 'm', 'p', 't', 'y', // Empty
+		0, // empty tagged fields
+	}
+
+	listGroupsRequestV5 = []byte{
+		2,                          // StatesFilter
+		6, 'E', 'm', 'p', 't', 'y', // Empty
+		2,                                    // TypesFilter
+		8, 'c', 'l', 'a', 's', 's', 'i', 'c', // classic
+		0, // empty tagged fields
+	}
+)
+
 func TestListGroupsRequest(t *testing.T) {
 	testRequest(t, "ListGroupsRequest", &ListGroupsRequest{}, []byte{})
+	testRequest(t, "ListGroupsRequest v1", &ListGroupsRequest{Version: 1}, []byte{})
+	testRequest(t, "ListGroupsRequest v3", &ListGroupsRequest{Version: 3}, listGroupsRequestV3)
+	testRequest(t, "ListGroupsRequest v4", &ListGroupsRequest{
+		Version:      4,
+		StatesFilter: []string{GroupStateEmpty},
+	}, listGroupsRequestV4)
+	testRequest(t, "ListGroupsRequest v5", &ListGroupsRequest{
+		Version:      5,
+		StatesFilter: []string{GroupStateEmpty},
+		TypesFilter:  []string{GroupTypeClassic},
+	}, listGroupsRequestV5)
 }
@@ -1,29 +1,102 @@
 package sarama
 
+import "time"
+
+// The states of a group, as listed by a ListGroupsResponse of version 4 or
+// higher and used by the StatesFilter of a ListGroupsRequest.
+const (
+	GroupStatePreparingRebalance  = "PreparingRebalance"
+	GroupStateCompletingRebalance = "CompletingRebalance"
+	GroupStateStable              = "Stable"
+	GroupStateDead                = "Dead"
+	GroupStateEmpty               = "Empty"
+	GroupStateAssigning           = "Assigning"
+	GroupStateReconciling         = "Reconciling"
+)
+
+// The types of a group, as listed by a ListGroupsResponse of version 5 or
+// higher and used by the TypesFilter of a ListGroupsRequest.
+const (
+	GroupTypeClassic  = "classic"
+	GroupTypeConsumer = "consumer"
+)
+
 type ListGroupsResponse struct {
-	Err    KError
+	Version      int16
+	ThrottleTime int32
+	Err          KError
+	// Groups maps the ID of each group to its protocol type
 	Groups map[string]string
+	// GroupsData holds the state and type of each group, from version 4
+	GroupsData map[string]GroupData
+}
+
+// GroupData is the state and type of a group listed by a ListGroupsResponse.
+type GroupData struct {
+	// GroupState is set from version 4
+	GroupState string
+	// GroupType is set from version 5
+	GroupType string
 }
 
 func (r *ListGroupsResponse) encode(pe packetEncoder) error {
+	if r.Version >= 1 {
+		pe.putInt32(r.ThrottleTime)
+	}
 	pe.putInt16(int16(r.Err))
 
-	if err := pe.putArrayLength(len(r.Groups)); err != nil {
+	if r.Version >= 3 {
+		pe.putCompactArrayLength(len(r.Groups))
+	} else if err := pe.putArrayLength(len(r.Groups)); err != nil {
 		return err
 	}
 	for groupId, protocolType := range r.Groups {
-		if err := pe.putString(groupId); err != nil {
-			return err
+		if r.Version >= 3 {
+			if err := pe.putCompactString(groupId); err != nil {
+				return err
+			}
+			if err := pe.putCompactString(protocolType); err != nil {
+				return err
+			}
+		} else {
+			if err := pe.putString(groupId); err != nil {
+				return err
+			}
+			if err := pe.putString(protocolType); err != nil {
+				return err
+			}
 		}
-		if err := pe.putString(protocolType); err != nil {
-			return err
+
+		data := r.GroupsData[groupId]
+		if r.Version >= 4 {
+			if err := pe.putCompactString(data.GroupState); err != nil {
+				return err
+			}
+		}
+		if r.Version >= 5 {
+			if err := pe.putCompactString(data.GroupType); err != nil {
+				return err
+			}
+		}
+		if r.Version >= 3 {
+			pe.putEmptyTaggedFieldArray()
 		}
 	}
 
+	if r.Version >= 3 {
+		pe.putEmptyTaggedFieldArray()
+	}
 	return nil
 }
 
-func (r *ListGroupsResponse) decode(pd packetDecoder, version int16) error {
+func (r *ListGroupsResponse) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.Version >= 1 {
+		if r.ThrottleTime, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+
 	kerr, err := pd.getInt16()
 	if err != nil {
 		return err
@@ -31,29 +104,65 @@ func (r *ListGroupsResponse) decode(pd packetDecoder, version int16) error {
 
 	r.Err = KError(kerr)
 
-	n, err := pd.getArrayLength()
+	var n int
+	if r.Version >= 3 {
+		n, err = pd.getCompactArrayLength()
+	} else {
+		n, err = pd.getArrayLength()
+	}
 	if err != nil {
 		return err
 	}
-	if n == 0 {
-		return nil
-	}
 
-	r.Groups = make(map[string]string)
+	if n > 0 {
+		r.Groups = make(map[string]string, n)
+		if r.Version >= 4 {
+			r.GroupsData = make(map[string]GroupData, n)
+		}
+	}
 	for i := 0; i < n; i++ {
-		groupId, err := pd.getString()
-		if err != nil {
-			return err
+		var groupId, protocolType string
+		if r.Version >= 3 {
+			if groupId, err = pd.getCompactString(); err != nil {
+				return err
+			}
+			if protocolType, err = pd.getCompactString(); err != nil {
+				return err
+			}
+		} else {
+			if groupId, err = pd.getString(); err != nil {
+				return err
+			}
+			if protocolType, err = pd.getString(); err != nil {
+				return err
+			}
 		}
-		protocolType, err := pd.getString()
-		if err != nil {
-			return err
+		r.Groups[groupId] = protocolType
+
+		if r.Version >= 4 {
+			var data GroupData
+			if data.GroupState, err = pd.getCompactString(); err != nil {
+				return err
+			}
+			if r.Version >= 5 {
+				if data.GroupType, err = pd.getCompactString(); err != nil {
+					return err
+				}
+			}
+			r.GroupsData[groupId] = data
 		}
 
-		r.Groups[groupId] = protocolType
+		if r.Version >= 3 {
+			if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+		}
 	}
 
-	return nil
+	if r.Version >= 3 {
+		_, err = pd.getEmptyTaggedFieldArray()
+	}
+	return err
 }
 
 func (r *ListGroupsResponse) key() int16 {
@@ -61,13 +170,33 @@ func (r *ListGroupsResponse) key() int16 {
 }
 
 func (r *ListGroupsResponse) version() int16 {
-	return 0
+	return r.Version
 }
 
 func (r *ListGroupsResponse) headerVersion() int16 {
+	if r.Version >= 3 {
+		return 1
+	}
 	return 0
 }
 
 func (r *ListGroupsResponse) requiredVersion() KafkaVersion {
-	return V0_9_0_0
+	switch r.Version {
+	case 1:
+		return V0_11_0_0
+	case 2:
+		return V2_0_0_0
+	case 3:
+		return V2_4_0_0
+	case 4:
+		return V2_6_0_0
+	case 5:
+		return V3_8_0_0
+	default:
+		return V0_9_0_0
+	}
+}
+
+func (r *ListGroupsResponse) throttleTime() time.Duration {
+	return time.Duration(r.ThrottleTime) * time.Millisecond
 }
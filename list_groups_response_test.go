@@ -21,6 +21,37 @@ var (
 		0, 3, 'f', 'o', 'o', // group name
 		0, 8, 'c', 'o', 'n', 's', 'u', 'm', 'e', 'r', // protocol type
 	}
+
+	listGroupsResponseV1 = []byte{
+		0, 0, 0, 100, // throttle time
+		0, 0, // no error
+		0, 0, 0, 1, // 1 group
+		0, 3, 'f', 'o', 'o', // group name
+		0, 8, 'c', 'o', 'n', 's', 'u', 'm', 'e', 'r', // protocol type
+	}
+
+	listGroupsResponseV4 = []byte{
+		0, 0, 0, 100, // throttle time
+		0, 0, // no error
+		2,                // 1 group
+		4, 'f', 'o', 'o', // group name
+		9, 'c', 'o', 'n', 's', 'u', 'm', 'e', 'r', // protocol type
+		6, 'E', 'm', 'p', 't', 'y', // group state
+		0, // empty tagged fields
+		0, // empty tagged fields
+	}
+
+	listGroupsResponseV5 = []byte{
+		0, 0, 0, 100, // throttle time
+		0, 0, // no error
+		2,                // 1 group
+		4, 'f', 'o', 'o', // group name
+		9, 'c', 'o', 'n', 's', 'u', 'm', 'e', 'r', // protocol type
+		6, 'E', 'm', 'p', 't', 'y', // group state
+		8, 'c', 'l', 'a', 's', 's', 'i', 'c', // group type
+		0, // empty tagged fields
+		0, // empty tagged fields
+	}
 )
 
 func TestListGroupsResponse(t *testing.T) {
@@ -56,3 +87,28 @@ func TestListGroupsResponse(t *testing.T) {
 		t.Error("Expected foo group to use consumer protocol")
 	}
 }
+
+func TestListGroupsResponseVersions(t *testing.T) {
+	response := &ListGroupsResponse{
+		Version:      1,
+		ThrottleTime: 100,
+		Groups:       map[string]string{"foo": "consumer"},
+	}
+	testResponse(t, "v1", response, listGroupsResponseV1)
+
+	response = &ListGroupsResponse{
+		Version:      4,
+		ThrottleTime: 100,
+		Groups:       map[string]string{"foo": "consumer"},
+		GroupsData:   map[string]GroupData{"foo": {GroupState: GroupStateEmpty}},
+	}
+	testResponse(t, "v4", response, listGroupsResponseV4)
+
+	response = &ListGroupsResponse{
+		Version:      5,
+		ThrottleTime: 100,
+		Groups:       map[string]string{"foo": "consumer"},
+		GroupsData:   map[string]GroupData{"foo": {GroupState: GroupStateEmpty, GroupType: GroupTypeClassic}},
+	}
+	testResponse(t, "v5", response, listGroupsResponseV5)
+}
@@ -9,7 +9,12 @@ type ListPartitionReassignmentsRequest struct {
 func (r *ListPartitionReassignmentsRequest) encode(pe packetEncoder) error {
 	pe.putInt32(r.TimeoutMs)
 
-	pe.putCompactArrayLength(len(r.blocks))
+	// a null array lists all the ongoing reassignments
+	if r.blocks == nil {
+		pe.putCompactArrayLength(-1)
+	} else {
+		pe.putCompactArrayLength(len(r.blocks))
+	}
 
 	for topic, partitions := range r.blocks {
 		if err := pe.putCompactString(topic); err != nil {
@@ -35,12 +40,15 @@ func (r *ListPartitionReassignmentsRequest) decode(pd packetDecoder, version int
 		return err
 	}
 
-	topicCount, err := pd.getCompactArrayLength()
+	// 0 represents a null array
+	n, err := pd.getUVarint()
 	if err != nil {
 		return err
 	}
-	if topicCount > 0 {
-		r.blocks = make(map[string][]int32)
+	r.blocks = nil
+	if n > 0 {
+		topicCount := int(n) - 1
+		r.blocks = make(map[string][]int32, topicCount)
 		for i := 0; i < topicCount; i++ {
 			topic, err := pd.getCompactString()
 			if err != nil {
@@ -11,6 +11,12 @@ var listPartitionReassignmentsRequestOneBlock = []byte{
 	0, 0, // empty tagged fields
 }
 
+var listPartitionReassignmentsRequestAll = []byte{
+	0, 0, 39, 16, // timeout 10000
+	0, // null blocks
+	0, // empty tagged fields
+}
+
 func TestListPartitionReassignmentRequest(t *testing.T) {
 	var request *ListPartitionReassignmentsRequest = &ListPartitionReassignmentsRequest{
 		TimeoutMs: int32(10000),
@@ -24,4 +30,11 @@ func TestListPartitionReassignmentRequest(t *testing.T) {
 	request.AddBlock("topic2", []int32{1, 2})
 
 	testRequestWithoutByteComparison(t, "two blocks", request)
+
+	request = &ListPartitionReassignmentsRequest{
+		TimeoutMs: int32(10000),
+		Version:   int16(0),
+	}
+
+	testRequest(t, "all ongoing reassignments", request, listPartitionReassignmentsRequestAll)
 }
@@ -1,5 +1,7 @@
 package sarama
 
+import "time"
+
 type PartitionReplicaReassignmentsStatus struct {
 	Replicas         []int32
 	AddingReplicas   []int32
@@ -167,3 +169,7 @@ func (r *ListPartitionReassignmentsResponse) headerVersion() int16 {
 func (r *ListPartitionReassignmentsResponse) requiredVersion() KafkaVersion {
 	return V2_4_0_0
 }
+
+func (r *ListPartitionReassignmentsResponse) throttleTime() time.Duration {
+	return time.Duration(r.ThrottleTimeMs) * time.Millisecond
+}
@@ -0,0 +1,74 @@
+package sarama
+
+// ListTransactionsRequest is a request to list the transactions a broker is
+// the coordinator of (KIP-664)
+type ListTransactionsRequest struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	// StateFilters lists the transaction states to return, all states if empty
+	StateFilters []string
+	// ProducerIDFilters lists the producer IDs to return, all producers if empty
+	ProducerIDFilters []int64
+}
+
+func (r *ListTransactionsRequest) encode(pe packetEncoder) error {
+	pe.putCompactArrayLength(len(r.StateFilters))
+	for _, state := range r.StateFilters {
+		if err := pe.putCompactString(state); err != nil {
+			return err
+		}
+	}
+
+	pe.putCompactArrayLength(len(r.ProducerIDFilters))
+	for _, producerID := range r.ProducerIDFilters {
+		pe.putInt64(producerID)
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *ListTransactionsRequest) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	numStates, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	r.StateFilters = make([]string, numStates)
+	for i := range r.StateFilters {
+		if r.StateFilters[i], err = pd.getCompactString(); err != nil {
+			return err
+		}
+	}
+
+	numProducerIDs, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	r.ProducerIDFilters = make([]int64, numProducerIDs)
+	for i := range r.ProducerIDFilters {
+		if r.ProducerIDFilters[i], err = pd.getInt64(); err != nil {
+			return err
+		}
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *ListTransactionsRequest) key() int16 {
+	return 66
+}
+
+func (r *ListTransactionsRequest) version() int16 {
+	return r.Version
+}
+
+func (r *ListTransactionsRequest) headerVersion() int16 {
+	return 2
+}
+
+func (r *ListTransactionsRequest) requiredVersion() KafkaVersion {
+	return V3_0_0_0
+}
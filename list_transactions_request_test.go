@@ -0,0 +1,31 @@
+package sarama
+
+import "testing"
+
+var (
+	emptyListTransactionsRequest = []byte{
+		1, // StateFilters array, empty
+		1, // ProducerIDFilters array, empty
+		0, // empty tagged fields
+	}
+
+	filteredListTransactionsRequest = []byte{
+		2,                                    // StateFilters array, length 1
+		8, 'O', 'n', 'g', 'o', 'i', 'n', 'g', // Ongoing
+		2,                        // ProducerIDFilters array, length 1
+		0, 0, 0, 0, 0, 0, 3, 232, // 1000
+		0, // empty tagged fields
+	}
+)
+
+func TestListTransactionsRequest(t *testing.T) {
+	request := &ListTransactionsRequest{
+		StateFilters:      []string{},
+		ProducerIDFilters: []int64{},
+	}
+	testRequest(t, "no filters", request, emptyListTransactionsRequest)
+
+	request.StateFilters = []string{TransactionStateOngoing}
+	request.ProducerIDFilters = []int64{1000}
+	testRequest(t, "filters", request, filteredListTransactionsRequest)
+}
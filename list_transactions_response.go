@@ -0,0 +1,131 @@
+package sarama
+
+import "time"
+
+// The states of a transaction, as reported by its coordinator.
+const (
+	TransactionStateEmpty             = "Empty"
+	TransactionStateOngoing           = "Ongoing"
+	TransactionStatePrepareCommit     = "PrepareCommit"
+	TransactionStatePrepareAbort      = "PrepareAbort"
+	TransactionStateCompleteCommit    = "CompleteCommit"
+	TransactionStateCompleteAbort     = "CompleteAbort"
+	TransactionStateDead              = "Dead"
+	TransactionStatePrepareEpochFence = "PrepareEpochFence"
+)
+
+// ListTransactionsResponse is the response to a ListTransactionsRequest
+type ListTransactionsResponse struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	ThrottleTime time.Duration
+	ErrorCode    KError
+	// UnknownStateFilters lists the state filters unknown to the broker
+	UnknownStateFilters []string
+	TransactionStates   []ListTransactionsResponseState
+}
+
+// ListTransactionsResponseState is a transaction managed by the broker.
+type ListTransactionsResponseState struct {
+	TransactionalID  string
+	ProducerID       int64
+	TransactionState string
+}
+
+func (r *ListTransactionsResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	pe.putInt16(int16(r.ErrorCode))
+
+	pe.putCompactArrayLength(len(r.UnknownStateFilters))
+	for _, state := range r.UnknownStateFilters {
+		if err := pe.putCompactString(state); err != nil {
+			return err
+		}
+	}
+
+	pe.putCompactArrayLength(len(r.TransactionStates))
+	for _, state := range r.TransactionStates {
+		if err := pe.putCompactString(state.TransactionalID); err != nil {
+			return err
+		}
+		pe.putInt64(state.ProducerID)
+		if err := pe.putCompactString(state.TransactionState); err != nil {
+			return err
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *ListTransactionsResponse) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	throttleTime, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.ErrorCode = KError(kerr)
+
+	numUnknown, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	r.UnknownStateFilters = make([]string, numUnknown)
+	for i := range r.UnknownStateFilters {
+		if r.UnknownStateFilters[i], err = pd.getCompactString(); err != nil {
+			return err
+		}
+	}
+
+	numStates, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	r.TransactionStates = make([]ListTransactionsResponseState, numStates)
+	for i := range r.TransactionStates {
+		state := &r.TransactionStates[i]
+		if state.TransactionalID, err = pd.getCompactString(); err != nil {
+			return err
+		}
+		if state.ProducerID, err = pd.getInt64(); err != nil {
+			return err
+		}
+		if state.TransactionState, err = pd.getCompactString(); err != nil {
+			return err
+		}
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *ListTransactionsResponse) key() int16 {
+	return 66
+}
+
+func (r *ListTransactionsResponse) version() int16 {
+	return r.Version
+}
+
+func (r *ListTransactionsResponse) headerVersion() int16 {
+	return 1
+}
+
+func (r *ListTransactionsResponse) requiredVersion() KafkaVersion {
+	return V3_0_0_0
+}
+
+func (r *ListTransactionsResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
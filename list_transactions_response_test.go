@@ -0,0 +1,31 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var listTransactionsResponse = []byte{
+	0, 0, 0, 100, // ThrottleTime
+	0, 0, // ErrorCode
+	2,                // UnknownStateFilters array, length 1
+	4, 'f', 'o', 'o', // foo
+	2,                // TransactionStates array, length 1
+	4, 't', 'x', '1', // TransactionalID
+	0, 0, 0, 0, 0, 0, 3, 232, // ProducerID
+	8, 'O', 'n', 'g', 'o', 'i', 'n', 'g', // TransactionState
+	0, // empty tagged fields
+	0, // empty tagged fields
+}
+
+func TestListTransactionsResponse(t *testing.T) {
+	response := &ListTransactionsResponse{
+		ThrottleTime:        100 * time.Millisecond,
+		ErrorCode:           ErrNoError,
+		UnknownStateFilters: []string{"foo"},
+		TransactionStates: []ListTransactionsResponseState{
+			{TransactionalID: "tx1", ProducerID: 1000, TransactionState: TransactionStateOngoing},
+		},
+	}
+	testResponse(t, "one transaction", response, listTransactionsResponse)
+}
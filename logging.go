@@ -0,0 +1,219 @@
+package sarama
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// LogLevel is the level of an entry written to a StructuredLogger.
+type LogLevel int8
+
+const (
+	// LogLevelDebug is the level of the verbose entries, those written to
+	// DebugLogger.
+	LogLevelDebug LogLevel = iota
+	// LogLevelInfo is the level of the connection management and state change
+	// entries, those written to Logger.
+	LogLevelInfo
+	// LogLevelWarn is the level of the failures Sarama recovers from, e.g. by
+	// retrying or by reconnecting.
+	LogLevelWarn
+	// LogLevelError is the level of the failures reported to the application.
+	LogLevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "DEBUG"
+	case LogLevelInfo:
+		return "INFO"
+	case LogLevelWarn:
+		return "WARN"
+	case LogLevelError:
+		return "ERROR"
+	}
+	return "UNKNOWN"
+}
+
+// LogComponent is a subsystem of Sarama, whose entries are written from the
+// level set with SetLogLevel.
+type LogComponent int8
+
+const (
+	// LogComponentProducer is the component of the entries of the producers.
+	LogComponentProducer LogComponent = iota
+	// LogComponentConsumer is the component of the entries of the consumers.
+	LogComponentConsumer
+	// LogComponentGroup is the component of the entries of the consumer
+	// groups.
+	LogComponentGroup
+	// LogComponentAdmin is the component of the entries of the cluster admins.
+	LogComponentAdmin
+	// LogComponentNetwork is the component of the entries of the broker
+	// connections, including the protocol trace of Net.ProtocolTrace.
+	LogComponentNetwork
+
+	numLogComponents
+)
+
+func (c LogComponent) String() string {
+	switch c {
+	case LogComponentProducer:
+		return "producer"
+	case LogComponentConsumer:
+		return "consumer"
+	case LogComponentGroup:
+		return "group"
+	case LogComponentAdmin:
+		return "admin"
+	case LogComponentNetwork:
+		return "network"
+	}
+	return "unknown"
+}
+
+// logLevels are the levels of the components, LogLevelDebug by default
+var logLevels [numLogComponents]int32
+
+// SetLogLevel sets the level from which the entries of component are written,
+// the entries of lower levels being dropped before they are formatted
+// (default LogLevelDebug: all the entries). It can be called at any time.
+func SetLogLevel(component LogComponent, level LogLevel) {
+	if component >= 0 && component < numLogComponents {
+		atomic.StoreInt32(&logLevels[component], int32(level))
+	}
+}
+
+// logEnabled returns whether the entries of component at level are written
+func logEnabled(component LogComponent, level LogLevel) bool {
+	return level >= LogLevel(atomic.LoadInt32(&logLevels[component]))
+}
+
+// The keys of the fields of the entries written to a StructuredLogger.
+const (
+	// LogKeyComponent is the LogComponent of the entry, the first field of
+	// all the entries
+	LogKeyComponent = "component"
+	// LogKeyBroker is the ID of the broker, an int32, -1 for the seed brokers
+	LogKeyBroker = "broker"
+	// LogKeyAddr is the address of the broker
+	LogKeyAddr = "addr"
+	// LogKeyTopic and LogKeyPartition are the topic and partition, an int32
+	LogKeyTopic     = "topic"
+	LogKeyPartition = "partition"
+	// LogKeyCorrelationID is the correlation ID of the request, an int32
+	LogKeyCorrelationID = "correlation_id"
+	// LogKeyGroup, LogKeyMember and LogKeyGeneration are the ID of the
+	// consumer group, the ID of the member and the generation, an int32
+	LogKeyGroup      = "group"
+	LogKeyMember     = "member"
+	LogKeyGeneration = "generation"
+	// LogKeyError is the error, an error
+	LogKeyError = "error"
+)
+
+// LogField is a named value of an entry written to a StructuredLogger. The
+// fields common to many entries have one of the LogKey constants as Key.
+type LogField struct {
+	Key   string
+	Value interface{}
+}
+
+// StructuredLogger is the interface of the loggers receiving the entries of
+// Sarama with their fields, so that they can be filtered and correlated, e.g.
+// NewSlogLogger with Go 1.21 or higher. See SetStructuredLogger.
+type StructuredLogger interface {
+	Log(level LogLevel, msg string, fields ...LogField)
+}
+
+var structuredLogger StructuredLogger
+
+// SetStructuredLogger makes Sarama write its entries to l instead of Logger
+// and DebugLogger. The messages still written to Logger and DebugLogger
+// without fields are sent to l as well, at LogLevelInfo and LogLevelDebug,
+// as Logger and DebugLogger are replaced by adapters. Calling it with nil
+// restores the default loggers. Like those variables, it must be called
+// before using Sarama.
+func SetStructuredLogger(l StructuredLogger) {
+	structuredLogger = l
+	if l == nil {
+		Logger = log.New(io.Discard, "[Sarama] ", log.LstdFlags)
+		DebugLogger = &debugLogger{}
+		return
+	}
+	Logger = &levelLogger{logger: l, level: LogLevelInfo}
+	DebugLogger = &levelLogger{logger: l, level: LogLevelDebug}
+}
+
+// levelLogger is the StdLogger writing the messages to a StructuredLogger at
+// a given level
+type levelLogger struct {
+	logger StructuredLogger
+	level  LogLevel
+}
+
+func (l *levelLogger) Print(v ...interface{}) {
+	l.logger.Log(l.level, strings.TrimSuffix(fmt.Sprint(v...), "\n"))
+}
+
+func (l *levelLogger) Printf(format string, v ...interface{}) {
+	l.logger.Log(l.level, strings.TrimSuffix(fmt.Sprintf(format, v...), "\n"))
+}
+
+func (l *levelLogger) Println(v ...interface{}) {
+	l.logger.Log(l.level, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
+
+// logEntry writes an entry of component, if enabled at level, to the
+// structured logger if one is set, and otherwise to DebugLogger for
+// LogLevelDebug and Logger for the other levels, formatted as the message
+// followed by the fields as key=value
+func logEntry(component LogComponent, level LogLevel, msg string, fields ...LogField) {
+	if !logEnabled(component, level) {
+		return
+	}
+	fields = append([]LogField{{Key: LogKeyComponent, Value: component}}, fields...)
+	if l := structuredLogger; l != nil {
+		l.Log(level, msg, fields...)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(msg)
+	for _, field := range fields {
+		fmt.Fprintf(&b, " %s=%v", field.Key, field.Value)
+	}
+	if level == LogLevelDebug {
+		DebugLogger.Println(b.String())
+	} else {
+		Logger.Println(b.String())
+	}
+}
+
+func brokerField(id int32) LogField {
+	return LogField{Key: LogKeyBroker, Value: id}
+}
+
+func addrField(addr string) LogField {
+	return LogField{Key: LogKeyAddr, Value: addr}
+}
+
+func topicField(topic string) LogField {
+	return LogField{Key: LogKeyTopic, Value: topic}
+}
+
+func partitionField(partition int32) LogField {
+	return LogField{Key: LogKeyPartition, Value: partition}
+}
+
+func groupField(groupID string) LogField {
+	return LogField{Key: LogKeyGroup, Value: groupID}
+}
+
+func errorField(err error) LogField {
+	return LogField{Key: LogKeyError, Value: err}
+}
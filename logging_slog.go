@@ -0,0 +1,44 @@
+//go:build go1.21
+// +build go1.21
+
+package sarama
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlogLogger returns a StructuredLogger writing the entries of Sarama to l,
+// their fields becoming attributes, for SetStructuredLogger.
+func NewSlogLogger(l *slog.Logger) StructuredLogger {
+	return &slogLogger{logger: l}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l *slogLogger) Log(level LogLevel, msg string, fields ...LogField) {
+	ctx := context.Background()
+	lvl := slogLevel(level)
+	if !l.logger.Enabled(ctx, lvl) {
+		return
+	}
+	attrs := make([]slog.Attr, len(fields))
+	for i, field := range fields {
+		attrs[i] = slog.Any(field.Key, field.Value)
+	}
+	l.logger.LogAttrs(ctx, lvl, msg, attrs...)
+}
+
+func slogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelWarn:
+		return slog.LevelWarn
+	case LogLevelError:
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
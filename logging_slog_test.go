@@ -0,0 +1,31 @@
+//go:build go1.21
+// +build go1.21
+
+package sarama
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+
+	l.Log(LogLevelDebug, "broker connected", brokerField(1))
+	l.Log(LogLevelWarn, "consumer/broker abandoned subscription", brokerField(1), topicField("my_topic"), partitionField(0), errorField(errors.New("boom")))
+
+	want := "level=WARN msg=\"consumer/broker abandoned subscription\" broker=1 topic=my_topic partition=0 error=boom\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,163 @@
+package sarama
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type recordingLogger struct {
+	lock    sync.Mutex
+	entries []recordedEntry
+}
+
+type recordedEntry struct {
+	level  LogLevel
+	msg    string
+	fields []LogField
+}
+
+func (l *recordingLogger) Log(level LogLevel, msg string, fields ...LogField) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.entries = append(l.entries, recordedEntry{level: level, msg: msg, fields: fields})
+}
+
+func (l *recordingLogger) recorded() []recordedEntry {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]recordedEntry(nil), l.entries...)
+}
+
+func TestLogEntryDefaultLoggers(t *testing.T) {
+	stdLogger, stdDebugLogger := Logger, DebugLogger
+	defer func() { Logger, DebugLogger = stdLogger, stdDebugLogger }()
+
+	var info, debug bytes.Buffer
+	Logger = log.New(&info, "", 0)
+	DebugLogger = log.New(&debug, "", 0)
+
+	logEntry(LogComponentConsumer, LogLevelWarn, "consumer/broker abandoned subscription", brokerField(1), topicField("my_topic"), partitionField(0), errorField(errors.New("boom")))
+	logEntry(LogComponentNetwork, LogLevelDebug, "broker connected", brokerField(1), addrField("localhost:9092"))
+
+	if got, want := info.String(), "consumer/broker abandoned subscription component=consumer broker=1 topic=my_topic partition=0 error=boom\n"; got != want {
+		t.Errorf("Expected %q on Logger, got %q", want, got)
+	}
+	if got, want := debug.String(), "broker connected component=network broker=1 addr=localhost:9092\n"; got != want {
+		t.Errorf("Expected %q on DebugLogger, got %q", want, got)
+	}
+}
+
+func TestSetStructuredLogger(t *testing.T) {
+	stdLogger, stdDebugLogger := Logger, DebugLogger
+	defer func() {
+		SetStructuredLogger(nil)
+		Logger, DebugLogger = stdLogger, stdDebugLogger
+	}()
+
+	l := &recordingLogger{}
+	SetStructuredLogger(l)
+
+	logEntry(LogComponentProducer, LogLevelInfo, "producer/broker starting up", brokerField(2))
+	Logger.Printf("Producer shutting down.\n")
+	DebugLogger.Println("SASL authentication succeeded")
+
+	expected := []recordedEntry{
+		{LogLevelInfo, "producer/broker starting up", []LogField{{Key: LogKeyComponent, Value: LogComponentProducer}, {Key: LogKeyBroker, Value: int32(2)}}},
+		{LogLevelInfo, "Producer shutting down.", nil},
+		{LogLevelDebug, "SASL authentication succeeded", nil},
+	}
+	if entries := l.recorded(); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected entries %+v, got %+v", expected, entries)
+	}
+
+	SetStructuredLogger(nil)
+	if structuredLogger != nil {
+		t.Error("Expected the structured logger to be unset")
+	}
+	if _, ok := DebugLogger.(*debugLogger); !ok {
+		t.Errorf("Expected the default DebugLogger to be restored, got %T", DebugLogger)
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	defer func() {
+		SetStructuredLogger(nil)
+		SetLogLevel(LogComponentProducer, LogLevelDebug)
+		SetLogLevel(LogComponentConsumer, LogLevelDebug)
+	}()
+
+	l := &recordingLogger{}
+	SetStructuredLogger(l)
+	SetLogLevel(LogComponentProducer, LogLevelWarn)
+	SetLogLevel(LogComponentConsumer, LogLevelError)
+
+	logEntry(LogComponentProducer, LogLevelInfo, "producer/broker starting up")
+	logEntry(LogComponentProducer, LogLevelWarn, "producer/broker state change to [closing]")
+	logEntry(LogComponentConsumer, LogLevelWarn, "consumer/broker abandoned subscription")
+	logEntry(LogComponentNetwork, LogLevelDebug, "broker connected")
+
+	var msgs []string
+	for _, entry := range l.recorded() {
+		msgs = append(msgs, entry.msg)
+	}
+	expected := []string{"producer/broker state change to [closing]", "broker connected"}
+	if !reflect.DeepEqual(msgs, expected) {
+		t.Errorf("Expected entries %v, got %v", expected, msgs)
+	}
+}
+
+func TestProtocolTrace(t *testing.T) {
+	defer SetStructuredLogger(nil)
+
+	l := &recordingLogger{}
+	SetStructuredLogger(l)
+
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()).
+			SetLeader("other_topic", 0, seedBroker.BrokerID()),
+	})
+
+	conf := NewTestConfig()
+	conf.Net.ProtocolTrace.Enable = true
+	conf.Net.ProtocolTrace.Topics = []string{"my_topic"}
+	broker := NewBroker(seedBroker.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	for _, topic := range []string{"other_topic", "my_topic"} {
+		if _, err := broker.GetMetadata(&MetadataRequest{Topics: []string{topic}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var traced []recordedEntry
+	for _, entry := range l.recorded() {
+		if strings.HasPrefix(entry.msg, "broker/trace") {
+			traced = append(traced, entry)
+		}
+	}
+	if len(traced) != 2 || traced[0].msg != "broker/trace request" || traced[1].msg != "broker/trace response" {
+		t.Fatalf("Expected the request and response traces of my_topic, got %+v", traced)
+	}
+	fields := make(map[string]interface{})
+	for _, field := range traced[1].fields {
+		fields[field.Key] = field.Value
+	}
+	if fields["request"] != "MetadataRequest" || fields["api_key"] != int16(3) || fields[LogKeyCorrelationID] != int32(1) {
+		t.Errorf("Unexpected trace fields %v", fields)
+	}
+	if size, _ := fields["size"].(int); size <= 0 {
+		t.Errorf("Expected the size of the response, got %v", fields["size"])
+	}
+}
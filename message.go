@@ -33,13 +33,19 @@ const (
 type CompressionCodec int8
 
 func (cc CompressionCodec) String() string {
-	return []string{
-		"none",
-		"gzip",
-		"snappy",
-		"lz4",
-		"zstd",
-	}[int(cc)]
+	if cc >= CompressionNone && cc <= CompressionZSTD {
+		return []string{
+			"none",
+			"gzip",
+			"snappy",
+			"lz4",
+			"zstd",
+		}[int(cc)]
+	}
+	if c, ok := registeredCompressorOf(cc); ok {
+		return c.name
+	}
+	return fmt.Sprintf("unknown(%d)", int8(cc))
 }
 
 // Message is a kafka message type
@@ -53,8 +59,10 @@ type Message struct {
 	Version          int8             // v1 requires Kafka 0.10
 	Timestamp        time.Time        // the timestamp of the message (version 1+ only)
 
-	compressedCache []byte
-	compressedSize  int // used for computing the compression ratio metrics
+	compressedCache        []byte
+	compressedSize         int           // used for computing the compression ratio metrics
+	compressionTime        time.Duration // used for computing the compression time metrics
+	compressionConcurrency int           // see Producer.CompressionConcurrency
 }
 
 func (m *Message) encode(pe packetEncoder) error {
@@ -85,13 +93,17 @@ func (m *Message) encode(pe packetEncoder) error {
 		payload = m.compressedCache
 		m.compressedCache = nil
 	} else if m.Value != nil {
-		payload, err = compress(m.Codec, m.CompressionLevel, m.Value)
+		start := time.Now()
+		payload, err = compress(m.Codec, m.CompressionLevel, m.compressionConcurrency, m.Value)
 		if err != nil {
 			return err
 		}
 		m.compressedCache = payload
-		// Keep in mind the compressed payload size for metric gathering
+		// Keep in mind the compressed payload size and time for metric gathering
 		m.compressedSize = len(payload)
+		if m.Codec != CompressionNone {
+			m.compressionTime = time.Since(start)
+		}
 	}
 
 	if err = pe.putBytes(payload); err != nil {
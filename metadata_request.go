@@ -4,47 +4,109 @@ type MetadataRequest struct {
 	Version                int16
 	Topics                 []string
 	AllowAutoTopicCreation bool
+	// IncludeClusterAuthorizedOperations is only valid for Version 8 to 10
+	IncludeClusterAuthorizedOperations bool
+	// IncludeTopicAuthorizedOperations is only valid for Version >= 8
+	IncludeTopicAuthorizedOperations bool
 }
 
 func (r *MetadataRequest) encode(pe packetEncoder) error {
-	if r.Version < 0 || r.Version > 5 {
+	if r.Version < 0 || r.Version > 13 {
 		return PacketEncodingError{"invalid or unsupported MetadataRequest version field"}
 	}
+	isFlexible := r.Version >= 9
+
 	if r.Version == 0 || len(r.Topics) > 0 {
-		err := pe.putArrayLength(len(r.Topics))
-		if err != nil {
+		if isFlexible {
+			pe.putCompactArrayLength(len(r.Topics))
+		} else if err := pe.putArrayLength(len(r.Topics)); err != nil {
 			return err
 		}
 
 		for i := range r.Topics {
-			err = pe.putString(r.Topics[i])
-			if err != nil {
+			if r.Version >= 10 {
+				// topic IDs are not used for lookups yet, send the null UUID
+				var topicID Uuid
+				if err := pe.putRawBytes(topicID[:]); err != nil {
+					return err
+				}
+				if err := pe.putNullableCompactString(&r.Topics[i]); err != nil {
+					return err
+				}
+			} else if isFlexible {
+				if err := pe.putCompactString(r.Topics[i]); err != nil {
+					return err
+				}
+			} else if err := pe.putString(r.Topics[i]); err != nil {
 				return err
 			}
+			if isFlexible {
+				pe.putEmptyTaggedFieldArray()
+			}
 		}
+	} else if isFlexible {
+		pe.putCompactArrayLength(-1)
 	} else {
 		pe.putInt32(-1)
 	}
+
 	if r.Version > 3 {
 		pe.putBool(r.AllowAutoTopicCreation)
 	}
+	if r.Version >= 8 && r.Version <= 10 {
+		pe.putBool(r.IncludeClusterAuthorizedOperations)
+	}
+	if r.Version >= 8 {
+		pe.putBool(r.IncludeTopicAuthorizedOperations)
+	}
+	if isFlexible {
+		pe.putEmptyTaggedFieldArray()
+	}
 	return nil
 }
 
 func (r *MetadataRequest) decode(pd packetDecoder, version int16) error {
 	r.Version = version
-	size, err := pd.getInt32()
+	isFlexible := r.Version >= 9
+
+	var size int
+	var err error
+	if isFlexible {
+		size, err = pd.getCompactArrayLength()
+	} else {
+		size, err = pd.getArrayLength()
+	}
 	if err != nil {
 		return err
 	}
 	if size > 0 {
 		r.Topics = make([]string, size)
 		for i := range r.Topics {
-			topic, err := pd.getString()
-			if err != nil {
+			var topic string
+			if r.Version >= 10 {
+				if _, err := pd.getRawBytes(16); err != nil {
+					return err
+				}
+				name, err := pd.getCompactNullableString()
+				if err != nil {
+					return err
+				}
+				if name != nil {
+					topic = *name
+				}
+			} else if isFlexible {
+				if topic, err = pd.getCompactString(); err != nil {
+					return err
+				}
+			} else if topic, err = pd.getString(); err != nil {
 				return err
 			}
 			r.Topics[i] = topic
+			if isFlexible {
+				if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+					return err
+				}
+			}
 		}
 	}
 	if r.Version > 3 {
@@ -54,6 +116,21 @@ func (r *MetadataRequest) decode(pd packetDecoder, version int16) error {
 		}
 		r.AllowAutoTopicCreation = autoCreation
 	}
+	if r.Version >= 8 && r.Version <= 10 {
+		if r.IncludeClusterAuthorizedOperations, err = pd.getBool(); err != nil {
+			return err
+		}
+	}
+	if r.Version >= 8 {
+		if r.IncludeTopicAuthorizedOperations, err = pd.getBool(); err != nil {
+			return err
+		}
+	}
+	if isFlexible {
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -66,6 +143,9 @@ func (r *MetadataRequest) version() int16 {
 }
 
 func (r *MetadataRequest) headerVersion() int16 {
+	if r.Version >= 9 {
+		return 2
+	}
 	return 1
 }
 
@@ -79,7 +159,57 @@ func (r *MetadataRequest) requiredVersion() KafkaVersion {
 		return V0_11_0_0
 	case 5:
 		return V1_0_0_0
+	case 6:
+		return V2_0_0_0
+	case 7:
+		return V2_1_0_0
+	case 8:
+		return V2_3_0_0
+	case 9:
+		return V2_4_0_0
+	case 10:
+		return V2_8_0_0
+	case 11:
+		return V3_0_0_0
+	case 12:
+		return V3_1_0_0
+	case 13:
+		return V4_0_0_0
 	default:
 		return MinVersion
 	}
 }
+
+// NewMetadataRequest returns a MetadataRequest for the given topics, or for
+// all the topics when there are none, with the latest version supported by
+// brokers of the given version.
+func NewMetadataRequest(version KafkaVersion, topics []string) *MetadataRequest {
+	r := &MetadataRequest{Topics: topics}
+	switch {
+	case version.IsAtLeast(V4_0_0_0):
+		r.Version = 13
+	case version.IsAtLeast(V3_1_0_0):
+		r.Version = 12
+	case version.IsAtLeast(V3_0_0_0):
+		r.Version = 11
+	case version.IsAtLeast(V2_8_0_0):
+		r.Version = 10
+	case version.IsAtLeast(V2_4_0_0):
+		r.Version = 9
+	case version.IsAtLeast(V2_3_0_0):
+		r.Version = 8
+	case version.IsAtLeast(V2_1_0_0):
+		r.Version = 7
+	case version.IsAtLeast(V2_0_0_0):
+		r.Version = 6
+	case version.IsAtLeast(V1_0_0_0):
+		r.Version = 5
+	case version.IsAtLeast(V0_11_0_0):
+		r.Version = 4
+	case version.IsAtLeast(V0_10_1_0):
+		r.Version = 2
+	case version.IsAtLeast(V0_10_0_0):
+		r.Version = 1
+	}
+	return r
+}
@@ -60,6 +60,58 @@ var (
 	metadataRequestNoTopicsV5     = append(metadataRequestNoTopicsV1, byte(0))
 	metadataRequestAutoCreateV5   = append(metadataRequestOneTopicV3, byte(1))
 	metadataRequestNoAutoCreateV5 = append(metadataRequestOneTopicV3, byte(0))
+
+	// The v8 metadata request adds flags requesting the cluster and topic
+	// authorized operations in the response.
+
+	metadataRequestOneTopicV8 = append(metadataRequestOneTopicV3, []byte{
+		0x01,       // allow auto topic creation
+		0x00, 0x01, // include cluster / topic authorized operations
+	}...)
+
+	// The v9 metadata request is the first flexible version, using compact
+	// types and tagged fields.
+
+	metadataRequestNoTopicsV9 = []byte{
+		0x00,       // null topics array
+		0x00,       // allow auto topic creation
+		0x00, 0x00, // include cluster / topic authorized operations
+		0x00, // empty tagged fields
+	}
+
+	metadataRequestOneTopicV9 = []byte{
+		0x02,
+		0x07, 't', 'o', 'p', 'i', 'c', '1',
+		0x00,
+		0x01,
+		0x00, 0x01,
+		0x00,
+	}
+
+	// The v10 metadata request adds a topic ID to each requested topic and
+	// makes the topic name nullable.
+
+	metadataRequestOneTopicV10 = []byte{
+		0x02,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x07, 't', 'o', 'p', 'i', 'c', '1',
+		0x00,
+		0x01,
+		0x00, 0x01,
+		0x00,
+	}
+
+	metadataRequestOneTopicV11 = []byte{
+		0x02,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x07, 't', 'o', 'p', 'i', 'c', '1',
+		0x00,
+		0x01,
+		0x01,
+		0x00,
+	}
 )
 
 func TestMetadataRequestV0(t *testing.T) {
@@ -136,3 +188,68 @@ func TestMetadataRequestV5(t *testing.T) {
 	request.AllowAutoTopicCreation = false
 	testRequest(t, "one topic", request, metadataRequestNoAutoCreateV5)
 }
+
+func TestMetadataRequestV8(t *testing.T) {
+	request := new(MetadataRequest)
+	request.Version = 8
+	request.Topics = []string{"topic1"}
+	request.AllowAutoTopicCreation = true
+	request.IncludeTopicAuthorizedOperations = true
+	testRequest(t, "one topic", request, metadataRequestOneTopicV8)
+}
+
+func TestMetadataRequestV9(t *testing.T) {
+	request := new(MetadataRequest)
+	request.Version = 9
+	testRequest(t, "no topics", request, metadataRequestNoTopicsV9)
+
+	request.Topics = []string{"topic1"}
+	request.AllowAutoTopicCreation = true
+	request.IncludeTopicAuthorizedOperations = true
+	testRequest(t, "one topic", request, metadataRequestOneTopicV9)
+}
+
+func TestMetadataRequestV10(t *testing.T) {
+	request := new(MetadataRequest)
+	request.Version = 10
+	request.Topics = []string{"topic1"}
+	request.AllowAutoTopicCreation = true
+	request.IncludeTopicAuthorizedOperations = true
+	testRequest(t, "one topic", request, metadataRequestOneTopicV10)
+}
+
+func TestMetadataRequestV11(t *testing.T) {
+	for _, version := range []int16{11, 12, 13} {
+		request := new(MetadataRequest)
+		request.Version = version
+		request.Topics = []string{"topic1"}
+		request.AllowAutoTopicCreation = true
+		request.IncludeTopicAuthorizedOperations = true
+		testRequest(t, "one topic", request, metadataRequestOneTopicV11)
+	}
+}
+
+func TestNewMetadataRequest(t *testing.T) {
+	for _, tc := range []struct {
+		version KafkaVersion
+		want    int16
+	}{
+		{V0_8_2_0, 0},
+		{V0_10_0_0, 1},
+		{V0_11_0_0, 4},
+		{V1_0_0_0, 5},
+		{V2_1_0_0, 7},
+		{V2_4_0_0, 9},
+		{V2_8_0_0, 10},
+		{V3_1_0_0, 12},
+		{V4_0_0_0, 13},
+	} {
+		request := NewMetadataRequest(tc.version, []string{"topic1"})
+		if request.Version != tc.want {
+			t.Errorf("Expected version %d for Kafka %s, got %d", tc.want, tc.version, request.Version)
+		}
+		if !request.requiredVersion().IsAtLeast(MinVersion) || !tc.version.IsAtLeast(request.requiredVersion()) {
+			t.Errorf("Expected version %d to be supported by Kafka %s", request.Version, tc.version)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package sarama
 
+import "time"
+
 type PartitionMetadata struct {
 	Err             KError
 	ID              int32
 	Leader          int32
+	LeaderEpoch     int32 // Only valid for Version >= 7
 	Replicas        []int32
 	Isr             []int32
 	OfflineReplicas []int32
@@ -26,23 +29,36 @@ func (pm *PartitionMetadata) decode(pd packetDecoder, version int16) (err error)
 		return err
 	}
 
-	pm.Replicas, err = pd.getInt32Array()
+	if version >= 7 {
+		pm.LeaderEpoch, err = pd.getInt32()
+		if err != nil {
+			return err
+		}
+	}
+
+	pm.Replicas, err = getMetadataInt32Array(pd, version)
 	if err != nil {
 		return err
 	}
 
-	pm.Isr, err = pd.getInt32Array()
+	pm.Isr, err = getMetadataInt32Array(pd, version)
 	if err != nil {
 		return err
 	}
 
 	if version >= 5 {
-		pm.OfflineReplicas, err = pd.getInt32Array()
+		pm.OfflineReplicas, err = getMetadataInt32Array(pd, version)
 		if err != nil {
 			return err
 		}
 	}
 
+	if version >= 9 {
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -51,31 +67,56 @@ func (pm *PartitionMetadata) encode(pe packetEncoder, version int16) (err error)
 	pe.putInt32(pm.ID)
 	pe.putInt32(pm.Leader)
 
-	err = pe.putInt32Array(pm.Replicas)
+	if version >= 7 {
+		pe.putInt32(pm.LeaderEpoch)
+	}
+
+	err = putMetadataInt32Array(pe, pm.Replicas, version)
 	if err != nil {
 		return err
 	}
 
-	err = pe.putInt32Array(pm.Isr)
+	err = putMetadataInt32Array(pe, pm.Isr, version)
 	if err != nil {
 		return err
 	}
 
 	if version >= 5 {
-		err = pe.putInt32Array(pm.OfflineReplicas)
+		err = putMetadataInt32Array(pe, pm.OfflineReplicas, version)
 		if err != nil {
 			return err
 		}
 	}
 
+	if version >= 9 {
+		pe.putEmptyTaggedFieldArray()
+	}
+
 	return nil
 }
 
+// the metadata protocol switched to compact arrays in version 9
+func getMetadataInt32Array(pd packetDecoder, version int16) ([]int32, error) {
+	if version >= 9 {
+		return pd.getCompactInt32Array()
+	}
+	return pd.getInt32Array()
+}
+
+func putMetadataInt32Array(pe packetEncoder, in []int32, version int16) error {
+	if version >= 9 {
+		return pe.putCompactInt32Array(in)
+	}
+	return pe.putInt32Array(in)
+}
+
 type TopicMetadata struct {
-	Err        KError
-	Name       string
-	IsInternal bool // Only valid for Version >= 1
-	Partitions []*PartitionMetadata
+	Err                       KError
+	Name                      string
+	Uuid                      Uuid // Only valid for Version >= 10
+	IsInternal                bool // Only valid for Version >= 1
+	Partitions                []*PartitionMetadata
+	TopicAuthorizedOperations int32 // Only valid for Version >= 8
 }
 
 func (tm *TopicMetadata) decode(pd packetDecoder, version int16) (err error) {
@@ -85,7 +126,25 @@ func (tm *TopicMetadata) decode(pd packetDecoder, version int16) (err error) {
 	}
 	tm.Err = KError(tmp)
 
-	tm.Name, err = pd.getString()
+	switch {
+	case version >= 10:
+		name, err := pd.getCompactNullableString()
+		if err != nil {
+			return err
+		}
+		if name != nil {
+			tm.Name = *name
+		}
+		uuid, err := pd.getRawBytes(16)
+		if err != nil {
+			return err
+		}
+		copy(tm.Uuid[:], uuid)
+	case version >= 9:
+		tm.Name, err = pd.getCompactString()
+	default:
+		tm.Name, err = pd.getString()
+	}
 	if err != nil {
 		return err
 	}
@@ -97,10 +156,18 @@ func (tm *TopicMetadata) decode(pd packetDecoder, version int16) (err error) {
 		}
 	}
 
-	n, err := pd.getArrayLength()
+	var n int
+	if version >= 9 {
+		n, err = pd.getCompactArrayLength()
+	} else {
+		n, err = pd.getArrayLength()
+	}
 	if err != nil {
 		return err
 	}
+	if n < 0 {
+		n = 0
+	}
 	tm.Partitions = make([]*PartitionMetadata, n)
 	for i := 0; i < n; i++ {
 		tm.Partitions[i] = new(PartitionMetadata)
@@ -110,13 +177,37 @@ func (tm *TopicMetadata) decode(pd packetDecoder, version int16) (err error) {
 		}
 	}
 
+	if version >= 8 {
+		tm.TopicAuthorizedOperations, err = pd.getInt32()
+		if err != nil {
+			return err
+		}
+	}
+
+	if version >= 9 {
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func (tm *TopicMetadata) encode(pe packetEncoder, version int16) (err error) {
 	pe.putInt16(int16(tm.Err))
 
-	err = pe.putString(tm.Name)
+	switch {
+	case version >= 10:
+		err = pe.putNullableCompactString(&tm.Name)
+		if err != nil {
+			return err
+		}
+		err = pe.putRawBytes(tm.Uuid[:])
+	case version >= 9:
+		err = pe.putCompactString(tm.Name)
+	default:
+		err = pe.putString(tm.Name)
+	}
 	if err != nil {
 		return err
 	}
@@ -125,9 +216,13 @@ func (tm *TopicMetadata) encode(pe packetEncoder, version int16) (err error) {
 		pe.putBool(tm.IsInternal)
 	}
 
-	err = pe.putArrayLength(len(tm.Partitions))
-	if err != nil {
-		return err
+	if version >= 9 {
+		pe.putCompactArrayLength(len(tm.Partitions))
+	} else {
+		err = pe.putArrayLength(len(tm.Partitions))
+		if err != nil {
+			return err
+		}
 	}
 
 	for _, pm := range tm.Partitions {
@@ -137,6 +232,14 @@ func (tm *TopicMetadata) encode(pe packetEncoder, version int16) (err error) {
 		}
 	}
 
+	if version >= 8 {
+		pe.putInt32(tm.TopicAuthorizedOperations)
+	}
+
+	if version >= 9 {
+		pe.putEmptyTaggedFieldArray()
+	}
+
 	return nil
 }
 
@@ -147,6 +250,10 @@ type MetadataResponse struct {
 	ClusterID      *string
 	ControllerID   int32
 	Topics         []*TopicMetadata
+	// ClusterAuthorizedOperations is only valid for Version 8 to 10
+	ClusterAuthorizedOperations int32
+	// Err is the top-level error, only valid for Version >= 13
+	Err KError
 }
 
 func (r *MetadataResponse) decode(pd packetDecoder, version int16) (err error) {
@@ -159,10 +266,18 @@ func (r *MetadataResponse) decode(pd packetDecoder, version int16) (err error) {
 		}
 	}
 
-	n, err := pd.getArrayLength()
+	var n int
+	if version >= 9 {
+		n, err = pd.getCompactArrayLength()
+	} else {
+		n, err = pd.getArrayLength()
+	}
 	if err != nil {
 		return err
 	}
+	if n < 0 {
+		n = 0
+	}
 
 	r.Brokers = make([]*Broker, n)
 	for i := 0; i < n; i++ {
@@ -173,7 +288,12 @@ func (r *MetadataResponse) decode(pd packetDecoder, version int16) (err error) {
 		}
 	}
 
-	if version >= 2 {
+	if version >= 9 {
+		r.ClusterID, err = pd.getCompactNullableString()
+		if err != nil {
+			return err
+		}
+	} else if version >= 2 {
 		r.ClusterID, err = pd.getNullableString()
 		if err != nil {
 			return err
@@ -189,10 +309,17 @@ func (r *MetadataResponse) decode(pd packetDecoder, version int16) (err error) {
 		r.ControllerID = -1
 	}
 
-	n, err = pd.getArrayLength()
+	if version >= 9 {
+		n, err = pd.getCompactArrayLength()
+	} else {
+		n, err = pd.getArrayLength()
+	}
 	if err != nil {
 		return err
 	}
+	if n < 0 {
+		n = 0
+	}
 
 	r.Topics = make([]*TopicMetadata, n)
 	for i := 0; i < n; i++ {
@@ -203,17 +330,42 @@ func (r *MetadataResponse) decode(pd packetDecoder, version int16) (err error) {
 		}
 	}
 
+	if version >= 8 && version <= 10 {
+		r.ClusterAuthorizedOperations, err = pd.getInt32()
+		if err != nil {
+			return err
+		}
+	}
+
+	if version >= 13 {
+		kerr, err := pd.getInt16()
+		if err != nil {
+			return err
+		}
+		r.Err = KError(kerr)
+	}
+
+	if version >= 9 {
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (r *MetadataResponse) encode(pe packetEncoder) error {
+func (r *MetadataResponse) encode(pe packetEncoder) (err error) {
 	if r.Version >= 3 {
 		pe.putInt32(r.ThrottleTimeMs)
 	}
 
-	err := pe.putArrayLength(len(r.Brokers))
-	if err != nil {
-		return err
+	if r.Version >= 9 {
+		pe.putCompactArrayLength(len(r.Brokers))
+	} else {
+		err = pe.putArrayLength(len(r.Brokers))
+		if err != nil {
+			return err
+		}
 	}
 	for _, broker := range r.Brokers {
 		err = broker.encode(pe, r.Version)
@@ -222,8 +374,13 @@ func (r *MetadataResponse) encode(pe packetEncoder) error {
 		}
 	}
 
-	if r.Version >= 2 {
-		err := pe.putNullableString(r.ClusterID)
+	if r.Version >= 9 {
+		err = pe.putNullableCompactString(r.ClusterID)
+		if err != nil {
+			return err
+		}
+	} else if r.Version >= 2 {
+		err = pe.putNullableString(r.ClusterID)
 		if err != nil {
 			return err
 		}
@@ -233,9 +390,13 @@ func (r *MetadataResponse) encode(pe packetEncoder) error {
 		pe.putInt32(r.ControllerID)
 	}
 
-	err = pe.putArrayLength(len(r.Topics))
-	if err != nil {
-		return err
+	if r.Version >= 9 {
+		pe.putCompactArrayLength(len(r.Topics))
+	} else {
+		err = pe.putArrayLength(len(r.Topics))
+		if err != nil {
+			return err
+		}
 	}
 	for _, tm := range r.Topics {
 		err = tm.encode(pe, r.Version)
@@ -244,6 +405,18 @@ func (r *MetadataResponse) encode(pe packetEncoder) error {
 		}
 	}
 
+	if r.Version >= 8 && r.Version <= 10 {
+		pe.putInt32(r.ClusterAuthorizedOperations)
+	}
+
+	if r.Version >= 13 {
+		pe.putInt16(int16(r.Err))
+	}
+
+	if r.Version >= 9 {
+		pe.putEmptyTaggedFieldArray()
+	}
+
 	return nil
 }
 
@@ -256,6 +429,9 @@ func (r *MetadataResponse) version() int16 {
 }
 
 func (r *MetadataResponse) headerVersion() int16 {
+	if r.Version >= 9 {
+		return 1
+	}
 	return 0
 }
 
@@ -269,11 +445,31 @@ func (r *MetadataResponse) requiredVersion() KafkaVersion {
 		return V0_11_0_0
 	case 5:
 		return V1_0_0_0
+	case 6:
+		return V2_0_0_0
+	case 7:
+		return V2_1_0_0
+	case 8:
+		return V2_3_0_0
+	case 9:
+		return V2_4_0_0
+	case 10:
+		return V2_8_0_0
+	case 11:
+		return V3_0_0_0
+	case 12:
+		return V3_1_0_0
+	case 13:
+		return V4_0_0_0
 	default:
 		return MinVersion
 	}
 }
 
+func (r *MetadataResponse) throttleTime() time.Duration {
+	return time.Duration(r.ThrottleTimeMs) * time.Millisecond
+}
+
 // testing API
 
 func (r *MetadataResponse) AddBroker(addr string, id int32) {
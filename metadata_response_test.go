@@ -294,3 +294,57 @@ func TestMetadataResponseWithOfflineReplicasV5(t *testing.T) {
 		t.Error("Decoding produced", len(response.Topics[0].Partitions[0].OfflineReplicas), "should have been 1!")
 	}
 }
+
+func TestMetadataResponseV10(t *testing.T) {
+	rack := "rack0"
+	clusterID := "clusterId"
+	response := &MetadataResponse{
+		Version:        10,
+		ThrottleTimeMs: 5,
+		Brokers: []*Broker{
+			{id: 1, addr: "localhost:9092", rack: &rack},
+		},
+		ClusterID:    &clusterID,
+		ControllerID: 1,
+		Topics: []*TopicMetadata{
+			{
+				Name: "foo",
+				Uuid: Uuid{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+				Partitions: []*PartitionMetadata{
+					{
+						ID:              0,
+						Leader:          1,
+						LeaderEpoch:     3,
+						Replicas:        []int32{1},
+						Isr:             []int32{1},
+						OfflineReplicas: []int32{},
+					},
+				},
+				TopicAuthorizedOperations: 0x0df8,
+			},
+		},
+		ClusterAuthorizedOperations: 0x0ff0,
+	}
+
+	testResponse(t, "v10", response, nil)
+}
+
+func TestMetadataResponseV13(t *testing.T) {
+	response := &MetadataResponse{
+		Version:      13,
+		ControllerID: 1,
+		Brokers: []*Broker{
+			{id: 1, addr: "localhost:9092"},
+		},
+		Topics: []*TopicMetadata{
+			{
+				Name:       "foo",
+				Uuid:       Uuid{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+				Partitions: []*PartitionMetadata{},
+			},
+		},
+		Err: ErrClusterAuthorizationFailed,
+	}
+
+	testResponse(t, "v13", response, nil)
+}
@@ -2,6 +2,7 @@ package sarama
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/rcrowley/go-metrics"
@@ -34,10 +35,168 @@ func getMetricNameForTopic(name string, topic string) string {
 	return fmt.Sprintf(name+"-for-topic-%s", strings.Replace(topic, ".", "_", -1))
 }
 
-func getOrRegisterTopicMeter(name string, topic string, r metrics.Registry) metrics.Meter {
-	return metrics.GetOrRegisterMeter(getMetricNameForTopic(name, topic), r)
+// The keys of the labels of the metrics broken down by broker, topic,
+// partition, consumer group, API or compression codec.
+const (
+	// MetricLabelBroker is the ID of the broker
+	MetricLabelBroker = "broker"
+	// MetricLabelTopic is the topic
+	MetricLabelTopic = "topic"
+	// MetricLabelPartition is the partition, of the topic of MetricLabelTopic
+	MetricLabelPartition = "partition"
+	// MetricLabelGroup is the ID of the consumer group
+	MetricLabelGroup = "group"
+	// MetricLabelAPI is the API of the requests, such as "Produce" or
+	// "OffsetCommit"
+	MetricLabelAPI = "api"
+	// MetricLabelCodec is the compression codec, such as "zstd"
+	MetricLabelCodec = "codec"
+)
+
+// MetricLabel is a label of a metric, with one of the MetricLabel constants as
+// Key.
+type MetricLabel struct {
+	Key   string
+	Value string
+}
+
+// MetricMeter counts events, such as the requests sent or the bytes received,
+// to measure their rate. It is implemented by metrics.Meter.
+type MetricMeter interface {
+	Mark(n int64)
+}
+
+// MetricCounter is a value incremented and decremented, such as the number of
+// requests in flight. It is implemented by metrics.Counter.
+type MetricCounter interface {
+	Inc(n int64)
+	Dec(n int64)
+}
+
+// MetricHistogram records the distribution of values, such as the request
+// latencies in ms. It is implemented by metrics.Histogram.
+type MetricHistogram interface {
+	Update(v int64)
+}
+
+// MetricsRecorder is the interface of the metrics systems Sarama emits its
+// metrics to, see Config.MetricsRecorder. The metrics are listed in the
+// package documentation. Those of the whole client have no labels and those
+// broken down by broker, topic or consumer group have the corresponding
+// label, so that systems with native labels and histograms can be used
+// directly rather than exporting a flattened registry. NewGoMetricsRecorder
+// returns the default one, and an adapter for Prometheus would look like:
+//
+//	func (r *promRecorder) Histogram(name string, labels ...sarama.MetricLabel) sarama.MetricHistogram {
+//		observer := r.histograms(name, labels).With(promLabels(labels))
+//		return histogramFunc(func(v int64) { observer.Observe(float64(v)) })
+//	}
+//
+// where histograms returns the prometheus.HistogramVec of the name and label
+// keys, created on first use, e.g. "sarama_request_latency_in_ms" with the
+// label "broker". An adapter for OpenTelemetry would similarly record to the
+// Int64Counter, Int64UpDownCounter and Int64Histogram instruments of a
+// metric.Meter, with the labels as attributes.
+//
+// The methods are called when the clients, brokers and requests are set up
+// and must return the same metric for the same name and labels.
+type MetricsRecorder interface {
+	Meter(name string, labels ...MetricLabel) MetricMeter
+	Counter(name string, labels ...MetricLabel) MetricCounter
+	Histogram(name string, labels ...MetricLabel) MetricHistogram
+	// Unregister drops the metric of name and labels, such as those of a
+	// broker when its connection is closed.
+	Unregister(name string, labels ...MetricLabel)
+}
+
+// NewGoMetricsRecorder returns the MetricsRecorder registering the metrics in
+// r, the default one with Config.MetricRegistry. The labels are flattened in
+// the names as in the Java client: "request-rate-for-broker-1",
+// "record-send-rate-for-topic-my_topic", and for consumer groups
+// "consumer-group-join-total-my_group". The histograms use an exponentially
+// decaying reservoir.
+func NewGoMetricsRecorder(r metrics.Registry) MetricsRecorder {
+	return &goMetricsRecorder{registry: r}
+}
+
+type goMetricsRecorder struct {
+	registry metrics.Registry
+}
+
+func (r *goMetricsRecorder) Meter(name string, labels ...MetricLabel) MetricMeter {
+	return metrics.GetOrRegisterMeter(flattenMetricName(name, labels), r.registry)
+}
+
+func (r *goMetricsRecorder) Counter(name string, labels ...MetricLabel) MetricCounter {
+	return metrics.GetOrRegisterCounter(flattenMetricName(name, labels), r.registry)
+}
+
+func (r *goMetricsRecorder) Histogram(name string, labels ...MetricLabel) MetricHistogram {
+	return getOrRegisterHistogram(flattenMetricName(name, labels), r.registry)
+}
+
+func (r *goMetricsRecorder) Unregister(name string, labels ...MetricLabel) {
+	r.registry.Unregister(flattenMetricName(name, labels))
+}
+
+// flattenMetricName returns the go-metrics name of the metric of name and
+// labels
+func flattenMetricName(name string, labels []MetricLabel) string {
+	for _, label := range labels {
+		switch label.Key {
+		case MetricLabelGroup:
+			name += "-" + label.Value
+		case MetricLabelTopic:
+			name = getMetricNameForTopic(name, label.Value)
+		default:
+			name = fmt.Sprintf("%s-for-%s-%s", name, label.Key, label.Value)
+		}
+	}
+	return name
+}
+
+// metricsRecorder returns Config.MetricsRecorder, or the recorder of
+// MetricRegistry by default, nil if neither is set
+func (c *Config) metricsRecorder() MetricsRecorder {
+	if c.MetricsRecorder != nil {
+		return c.MetricsRecorder
+	}
+	if c.MetricRegistry == nil {
+		return nil
+	}
+	return NewGoMetricsRecorder(c.MetricRegistry)
+}
+
+func brokerLabel(id int32) MetricLabel {
+	return MetricLabel{Key: MetricLabelBroker, Value: strconv.Itoa(int(id))}
+}
+
+func topicLabel(topic string) MetricLabel {
+	return MetricLabel{Key: MetricLabelTopic, Value: topic}
+}
+
+func partitionLabel(partition int32) MetricLabel {
+	return MetricLabel{Key: MetricLabelPartition, Value: strconv.Itoa(int(partition))}
+}
+
+func groupLabel(groupID string) MetricLabel {
+	return MetricLabel{Key: MetricLabelGroup, Value: groupID}
+}
+
+func codecLabel(cc CompressionCodec) MetricLabel {
+	return MetricLabel{Key: MetricLabelCodec, Value: cc.String()}
+}
+
+func apiLabel(key int16) MetricLabel {
+	return MetricLabel{Key: MetricLabelAPI, Value: apiName(key)}
 }
 
-func getOrRegisterTopicHistogram(name string, topic string, r metrics.Registry) metrics.Histogram {
-	return getOrRegisterHistogram(getMetricNameForTopic(name, topic), r)
+// apiName returns the name of the API of key, such as "Produce", or the key
+// itself if unknown
+func apiName(key int16) string {
+	body := allocateBody(key, 0)
+	if body == nil {
+		return strconv.Itoa(int(key))
+	}
+	return strings.TrimSuffix(requestName(body), "Request")
 }
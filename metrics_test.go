@@ -1,6 +1,8 @@
 package sarama
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/rcrowley/go-metrics"
@@ -37,6 +39,152 @@ func TestGetMetricNameForBroker(t *testing.T) {
 	}
 }
 
+func TestGoMetricsRecorder(t *testing.T) {
+	metricRegistry := metrics.NewRegistry()
+	recorder := NewGoMetricsRecorder(metricRegistry)
+
+	recorder.Meter("request-rate", brokerLabel(1)).Mark(1)
+	recorder.Histogram("batch-size", topicLabel("my.topic")).Update(10)
+	recorder.Counter("consumer-group-join-total", groupLabel("my_group")).Inc(1)
+
+	for _, name := range []string{"request-rate-for-broker-1", "batch-size-for-topic-my_topic", "consumer-group-join-total-my_group"} {
+		if metricRegistry.Get(name) == nil {
+			t.Errorf("Expected metric %s to be registered", name)
+		}
+	}
+	if meter := recorder.Meter("request-rate", brokerLabel(1)); meter != metricRegistry.Get("request-rate-for-broker-1") {
+		t.Error("Expected the same meter for the same name and labels")
+	}
+
+	recorder.Unregister("request-rate", brokerLabel(1))
+	if metricRegistry.Get("request-rate-for-broker-1") != nil {
+		t.Error("Expected the meter to be unregistered")
+	}
+}
+
+type recordingMetrics struct {
+	lock         sync.Mutex
+	values       map[string]int64
+	unregistered map[string]bool
+}
+
+type recordedMetric struct {
+	metrics *recordingMetrics
+	key     string
+}
+
+func (m recordedMetric) add(n int64) {
+	m.metrics.lock.Lock()
+	defer m.metrics.lock.Unlock()
+	m.metrics.values[m.key] += n
+}
+
+func (m recordedMetric) Mark(n int64)   { m.add(n) }
+func (m recordedMetric) Inc(n int64)    { m.add(n) }
+func (m recordedMetric) Dec(n int64)    { m.add(-n) }
+func (m recordedMetric) Update(v int64) { m.add(1) }
+
+func metricKey(name string, labels []MetricLabel) string {
+	for _, label := range labels {
+		name += fmt.Sprintf(",%s=%s", label.Key, label.Value)
+	}
+	return name
+}
+
+func (r *recordingMetrics) metric(name string, labels []MetricLabel) recordedMetric {
+	key := metricKey(name, labels)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.values[key]; !ok {
+		r.values[key] = 0
+	}
+	return recordedMetric{metrics: r, key: key}
+}
+
+func (r *recordingMetrics) Meter(name string, labels ...MetricLabel) MetricMeter {
+	return r.metric(name, labels)
+}
+
+func (r *recordingMetrics) Counter(name string, labels ...MetricLabel) MetricCounter {
+	return r.metric(name, labels)
+}
+
+func (r *recordingMetrics) Histogram(name string, labels ...MetricLabel) MetricHistogram {
+	return r.metric(name, labels)
+}
+
+func (r *recordingMetrics) Unregister(name string, labels ...MetricLabel) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.unregistered[metricKey(name, labels)] = true
+}
+
+func TestMetricsRecorder(t *testing.T) {
+	mockBroker := NewMockBroker(t, 1)
+	defer mockBroker.Close()
+	mockBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(mockBroker.Addr(), mockBroker.BrokerID()),
+	})
+
+	recorder := &recordingMetrics{values: make(map[string]int64), unregistered: make(map[string]bool)}
+	conf := NewTestConfig()
+	conf.MetricsRecorder = recorder
+	broker := NewBroker(mockBroker.Addr())
+	broker.id = mockBroker.BrokerID()
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	safeClose(t, broker)
+
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	if recorder.values["request-rate"] != 1 || recorder.values["request-rate,broker=1"] != 1 {
+		t.Errorf("Expected one request for all the brokers and for broker 1, got %v", recorder.values)
+	}
+	if recorder.values["requests-in-flight,broker=1"] != 0 {
+		t.Errorf("Expected no request in flight, got %d", recorder.values["requests-in-flight,broker=1"])
+	}
+	if !recorder.unregistered["request-rate,broker=1"] || recorder.unregistered["request-rate"] {
+		t.Errorf("Expected the metrics of broker 1 to be unregistered, got %v", recorder.unregistered)
+	}
+	if len(conf.MetricRegistry.GetAll()) != 0 {
+		t.Errorf("Expected MetricRegistry to be unused, got %v", conf.MetricRegistry.GetAll())
+	}
+}
+
+func TestMetricsRecorderProduceRequest(t *testing.T) {
+	recorder := &recordingMetrics{values: make(map[string]int64), unregistered: make(map[string]bool)}
+	request := &ProduceRequest{Version: 3}
+	request.AddBatch("my_topic", 0, &RecordBatch{Version: 2, Records: []*Record{{Value: []byte("value")}}})
+	if _, err := encode(request, recorder); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"record-send-rate", "record-send-rate,topic=my_topic", "batch-size", "batch-size,topic=my_topic"} {
+		if recorder.values[key] != 1 {
+			t.Errorf("Expected %s to be recorded once, got %v", key, recorder.values)
+		}
+	}
+}
+
+func TestMetricsRecorderCompressionTime(t *testing.T) {
+	recorder := &recordingMetrics{values: make(map[string]int64), unregistered: make(map[string]bool)}
+	request := &ProduceRequest{Version: 3}
+	request.AddBatch("my_topic", 0, &RecordBatch{Version: 2, Codec: CompressionZSTD, Records: []*Record{{Value: []byte("value")}}})
+	request.AddBatch("my_topic", 1, &RecordBatch{Version: 2, Records: []*Record{{Value: []byte("value")}}})
+	if _, err := encode(request, recorder); err != nil {
+		t.Fatal(err)
+	}
+
+	if recorder.values["compression-time-in-us"] != 1 || recorder.values["compression-time-in-us,codec=zstd"] != 1 {
+		t.Errorf("Expected the compression of the zstd batch only to be recorded, got %v", recorder.values)
+	}
+}
+
 // Common type and functions for metric validation
 type metricValidator struct {
 	name      string
@@ -62,8 +62,10 @@ type MockBroker struct {
 	handler       requestHandlerFunc
 	notifier      RequestNotifierFunc
 	history       []RequestResponse
+	software      []*ApiVersionsRequest
 	lock          sync.Mutex
 	gssApiHandler GSSApiHandlerFunc
+	faults        []*mockFaultState
 }
 
 // RequestResponse represents a Request/Response pair processed by MockBroker.
@@ -78,6 +80,79 @@ func (b *MockBroker) SetLatency(latency time.Duration) {
 	b.latency = latency
 }
 
+// MockFault is a fault MockBroker injects when answering requests, in order to
+// test the handling of slow brokers, partial reads and connection resets. The
+// Delay fault can be combined with the TruncateAt or Reset ones.
+type MockFault struct {
+	// Request is the name of the type of the requests the fault applies to,
+	// such as "FetchRequest", every request matching it when empty
+	Request string
+	// Skip is the number of matching requests answered normally before the
+	// fault is injected
+	Skip int
+	// Times is the number of matching requests the fault is injected in, 0
+	// meaning every request after the skipped ones
+	Times int
+	// Delay delays the response, on top of the latency of the broker
+	Delay time.Duration
+	// TruncateAt is the number of bytes of the response frame written before
+	// the connection is closed, the whole response being written when 0
+	TruncateAt int
+	// Reset resets the connection instead of writing the response
+	Reset bool
+}
+
+type mockFaultState struct {
+	MockFault
+	skipped  int
+	injected int
+}
+
+// InjectFault schedules a fault to inject when answering the requests
+// matching it. Each fault counts the matching requests on its own, and when
+// several faults are due for a request the one injected first wins. The
+// ApiVersions requests the broker answers itself are never faulted.
+func (b *MockBroker) InjectFault(fault MockFault) {
+	b.lock.Lock()
+	b.faults = append(b.faults, &mockFaultState{MockFault: fault})
+	b.lock.Unlock()
+}
+
+// ClearFaults removes the faults injected with InjectFault, the following
+// requests being answered normally.
+func (b *MockBroker) ClearFaults() {
+	b.lock.Lock()
+	b.faults = nil
+	b.lock.Unlock()
+}
+
+// nextFault returns the fault to inject when answering the request, if any
+func (b *MockBroker) nextFault(req protocolBody) *MockFault {
+	reqTypeName := reflect.TypeOf(req).Elem().Name()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var fault *MockFault
+	for _, f := range b.faults {
+		if f.Request != "" && f.Request != reqTypeName {
+			continue
+		}
+		if f.Times > 0 && f.injected >= f.Times {
+			continue
+		}
+		if f.skipped < f.Skip {
+			f.skipped++
+			continue
+		}
+		if fault == nil {
+			f.injected++
+			fault = &f.MockFault
+		}
+	}
+	return fault
+}
+
 // SetHandlerByMap defines mapping of Request types to MockResponses. When a
 // request is received by the broker, it looks up the request type in the map
 // and uses the found MockResponse instance to generate an appropriate reply.
@@ -93,6 +168,18 @@ func (b *MockBroker) SetHandlerByMap(handlerMap map[string]MockResponse) {
 	})
 }
 
+// ClientSoftware returns the ApiVersions requests the clients identified
+// their software with when connecting to the broker (KIP-511). The broker
+// answers them itself, so they are neither passed to the handler nor recorded
+// in the History.
+func (b *MockBroker) ClientSoftware() []*ApiVersionsRequest {
+	b.lock.Lock()
+	software := make([]*ApiVersionsRequest, len(b.software))
+	copy(software, b.software)
+	b.lock.Unlock()
+	return software
+}
+
 // SetNotifier set a function that will get invoked whenever a request has been
 // processed successfully and will provide the number of bytes read and written
 func (b *MockBroker) SetNotifier(notifier RequestNotifierFunc) {
@@ -235,7 +322,7 @@ func (b *MockBroker) handleRequests(conn io.ReadWriteCloser, idx int, wg *sync.W
 
 	var bytesWritten int
 	var bytesRead int
-	for {
+	for first := true; ; first = false {
 		buffer, err := b.readToBytes(conn)
 		if err != nil {
 			Logger.Printf("*** mockbroker/%d/%d: invalid request: err=%+v, %+v", b.brokerID, idx, err, spew.Sdump(buffer))
@@ -257,11 +344,29 @@ func (b *MockBroker) handleRequests(conn io.ReadWriteCloser, idx int, wg *sync.W
 				time.Sleep(b.latency)
 			}
 
+			if apiVersions, ok := req.body.(*ApiVersionsRequest); ok && first && apiVersions.Version >= 3 {
+				if err := b.answerClientSoftware(conn, req.correlationID, apiVersions); err != nil {
+					b.serverError(err)
+					break
+				}
+				continue
+			}
+
 			b.lock.Lock()
 			res := b.handler(req)
 			b.history = append(b.history, RequestResponse{req.body, res})
 			b.lock.Unlock()
 
+			fault := b.nextFault(req.body)
+			if fault != nil && fault.Delay > 0 {
+				time.Sleep(fault.Delay)
+			}
+			if fault != nil && fault.Reset {
+				Logger.Printf("*** mockbroker/%d/%d: resetting the connection on %T", b.brokerID, idx, req.body)
+				b.resetConnection(conn)
+				break
+			}
+
 			if res == nil {
 				Logger.Printf("*** mockbroker/%d/%d: ignored %v", b.brokerID, idx, spew.Sdump(req))
 				continue
@@ -288,6 +393,14 @@ func (b *MockBroker) handleRequests(conn io.ReadWriteCloser, idx int, wg *sync.W
 			}
 
 			resHeader := b.encodeHeader(res.headerVersion(), req.correlationID, uint32(len(encodedRes)))
+			if fault != nil && fault.TruncateAt > 0 && fault.TruncateAt < len(resHeader)+len(encodedRes) {
+				Logger.Printf("*** mockbroker/%d/%d: truncating the response to %T after %d bytes", b.brokerID, idx, req.body, fault.TruncateAt)
+				frame := append(resHeader, encodedRes...)
+				if _, err = conn.Write(frame[:fault.TruncateAt]); err != nil {
+					b.serverError(err)
+				}
+				break
+			}
 			if _, err = conn.Write(resHeader); err != nil {
 				b.serverError(err)
 				break
@@ -323,6 +436,34 @@ func (b *MockBroker) handleRequests(conn io.ReadWriteCloser, idx int, wg *sync.W
 	Logger.Printf("*** mockbroker/%d/%d: connection closed, err=%v", b.BrokerID(), idx, err)
 }
 
+// resetConnection makes the closing of the connection reset it rather than
+// shut it down gracefully
+func (b *MockBroker) resetConnection(conn io.ReadWriteCloser) {
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		_ = tcpConn.SetLinger(0)
+	}
+}
+
+// answerClientSoftware replies to the ApiVersions request the clients send
+// first on their connections to identify their software, without notifying
+// the notifier as the clients do not count it in their metrics
+func (b *MockBroker) answerClientSoftware(conn io.Writer, correlationID int32, req *ApiVersionsRequest) error {
+	b.lock.Lock()
+	b.software = append(b.software, req)
+	b.lock.Unlock()
+
+	res := NewMockApiVersionsResponse(b.t).For(req)
+	encodedRes, err := encode(res, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(b.encodeHeader(res.headerVersion(), correlationID, uint32(len(encodedRes)))); err != nil {
+		return err
+	}
+	_, err = conn.Write(encodedRes)
+	return err
+}
+
 func (b *MockBroker) encodeHeader(headerVersion int16, correlationId int32, payloadLength uint32) []byte {
 	headerLength := uint32(8)
 
@@ -0,0 +1,848 @@
+package sarama
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// mockClusterMaxFetchRecords is the maximum number of records returned for a
+// partition in a fetch response of MockCluster
+const mockClusterMaxFetchRecords = 100
+
+// MockCluster is a set of MockBrokers answering the requests of the clients
+// from a shared model of a Kafka cluster, so that failover and rebalance logic
+// can be tested without a real cluster. The model has:
+//
+//   - topics whose partitions are replicated over the brokers, and whose leader
+//     can be moved with MoveLeader or by stopping brokers with StopBroker,
+//   - the records produced to the partitions, which can be fetched from their
+//     leader and whose offsets can be listed,
+//   - the coordinators of the consumer groups, elected among the running
+//     brokers and re-elected when they stop,
+//   - the members of the consumer groups, which join, sync and heartbeat
+//     following the classic group protocol, and the offsets they commit.
+//
+// A request sent to a broker which does not lead the partition or coordinate
+// the group fails the way it would on a real cluster, prompting the clients
+// to refresh their metadata or coordinator. A join of the group answers
+// right away: the first member joining a new generation is its leader and
+// receives the members known so far, the other members learning about the
+// rebalance from their heartbeats and joining the same generation.
+type MockCluster struct {
+	t            TestReporter
+	lock         sync.Mutex
+	brokers      map[int32]*MockBroker
+	addrs        map[int32]string
+	ids          []int32
+	controller   int32
+	topics       map[string][]*mockClusterPartition
+	coordinators map[string]int32
+	groups       map[string]*mockClusterGroup
+}
+
+type mockClusterPartition struct {
+	leader      int32
+	leaderEpoch int32
+	replicas    []int32
+	records     []*mockClusterRecord
+}
+
+type mockClusterRecord struct {
+	key, value []byte
+	headers    []*RecordHeader
+	timestamp  time.Time
+}
+
+type mockClusterGroup struct {
+	generation   int32
+	protocol     string
+	leader       string
+	members      map[string]*mockClusterMember
+	assignments  map[string][]byte
+	assigned     bool
+	offsets      map[string]map[int32]*OffsetFetchResponseBlock
+	nextMemberID int
+}
+
+type mockClusterMember struct {
+	protocols      []*GroupProtocol
+	generation     int32
+	sessionTimeout time.Duration
+	lastSeen       time.Time
+}
+
+// NewMockCluster starts a cluster of the given number of brokers, whose IDs
+// go from 1 to brokers. The first broker is the controller.
+func NewMockCluster(t TestReporter, brokers int) *MockCluster {
+	c := &MockCluster{
+		t:            t,
+		brokers:      make(map[int32]*MockBroker, brokers),
+		addrs:        make(map[int32]string, brokers),
+		topics:       make(map[string][]*mockClusterPartition),
+		coordinators: make(map[string]int32),
+		groups:       make(map[string]*mockClusterGroup),
+	}
+	for id := int32(1); id <= int32(brokers); id++ {
+		c.ids = append(c.ids, id)
+		c.startBroker(NewMockBroker(t, id))
+	}
+	c.controller = 1
+	return c
+}
+
+func (c *MockCluster) startBroker(broker *MockBroker) {
+	id := broker.BrokerID()
+	c.brokers[id] = broker
+	c.addrs[id] = broker.Addr()
+	broker.setHandler(func(req *request) encoderWithHeader {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		return c.handle(id, req)
+	})
+}
+
+// Addrs returns the addresses of the brokers of the cluster, running or not,
+// to bootstrap the clients with.
+func (c *MockCluster) Addrs() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	addrs := make([]string, 0, len(c.ids))
+	for _, id := range c.ids {
+		addrs = append(addrs, c.addrs[id])
+	}
+	return addrs
+}
+
+// Broker returns the MockBroker of the given ID, nil when it is stopped.
+func (c *MockCluster) Broker(id int32) *MockBroker {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.brokers[id]
+}
+
+// Controller returns the ID of the controller of the cluster.
+func (c *MockCluster) Controller() int32 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.controller
+}
+
+// CreateTopic creates a topic whose replicas are assigned over the brokers of
+// the cluster, the first replica of each partition leading it.
+func (c *MockCluster) CreateTopic(topic string, numPartitions int32, replicationFactor int16) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.topics[topic]; ok {
+		return ErrTopicAlreadyExists
+	}
+	brokers := make([]ReplicaAssignmentBroker, len(c.ids))
+	for i, id := range c.ids {
+		brokers[i].ID = id
+	}
+	assignment, err := AssignReplicas(brokers, numPartitions, replicationFactor, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	partitions := make([]*mockClusterPartition, numPartitions)
+	for i, replicas := range assignment {
+		partitions[i] = &mockClusterPartition{leader: -1, replicas: replicas}
+		c.electLeader(partitions[i], replicas[0])
+	}
+	c.topics[topic] = partitions
+	return nil
+}
+
+// Leader returns the ID of the leader of the partition, -1 when it has none
+// as all its replicas are stopped.
+func (c *MockCluster) Leader(topic string, partition int32) (int32, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	p, err := c.partition(topic, partition)
+	if err != nil {
+		return -1, err
+	}
+	return p.leader, nil
+}
+
+// MoveLeader makes the given replica of the partition its leader.
+func (c *MockCluster) MoveLeader(topic string, partition int32, brokerID int32) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	p, err := c.partition(topic, partition)
+	if err != nil {
+		return err
+	}
+	if !int32SliceContains(p.replicas, brokerID) {
+		return fmt.Errorf("%w: broker %d is not a replica of %s/%d", ErrReplicaNotAvailable, brokerID, topic, partition)
+	}
+	if c.brokers[brokerID] == nil {
+		return fmt.Errorf("%w: broker %d is stopped", ErrBrokerNotAvailable, brokerID)
+	}
+	c.electLeader(p, brokerID)
+	return nil
+}
+
+// HighWaterMark returns the offset of the next record produced to the partition.
+func (c *MockCluster) HighWaterMark(topic string, partition int32) (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	p, err := c.partition(topic, partition)
+	if err != nil {
+		return -1, err
+	}
+	return int64(len(p.records)), nil
+}
+
+func (c *MockCluster) partition(topic string, partition int32) (*mockClusterPartition, error) {
+	partitions, ok := c.topics[topic]
+	if !ok || partition < 0 || partition >= int32(len(partitions)) {
+		return nil, ErrUnknownTopicOrPartition
+	}
+	return partitions[partition], nil
+}
+
+func (c *MockCluster) electLeader(p *mockClusterPartition, leader int32) {
+	if p.leader != leader {
+		p.leader = leader
+		p.leaderEpoch++
+	}
+}
+
+// StopBroker stops a broker. The partitions it leads are led by their next
+// running replica, and the groups it coordinates are coordinated by another
+// running broker, their members and offsets being kept.
+func (c *MockCluster) StopBroker(id int32) {
+	c.lock.Lock()
+	broker := c.brokers[id]
+	delete(c.brokers, id)
+	for _, partitions := range c.topics {
+		for _, p := range partitions {
+			if p.leader == id {
+				c.electLeader(p, c.runningReplica(p))
+			}
+		}
+	}
+	for group, coordinator := range c.coordinators {
+		if coordinator == id {
+			delete(c.coordinators, group)
+		}
+	}
+	if c.controller == id {
+		c.controller = -1
+		if running := c.running(); len(running) > 0 {
+			c.controller = running[0]
+		}
+	}
+	c.lock.Unlock()
+
+	// the handlers of the broker lock the cluster, so the broker is closed
+	// once the cluster is unlocked
+	if broker != nil {
+		broker.Close()
+	}
+}
+
+// StartBroker restarts a stopped broker on its previous address. The
+// partitions without a leader are led by it again, the others keeping their
+// leader until it is moved.
+func (c *MockCluster) StartBroker(id int32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.brokers[id] != nil {
+		return
+	}
+	c.startBroker(NewMockBrokerAddr(c.t, id, c.addrs[id]))
+	for _, partitions := range c.topics {
+		for _, p := range partitions {
+			if p.leader == -1 && int32SliceContains(p.replicas, id) {
+				c.electLeader(p, id)
+			}
+		}
+	}
+	if c.controller == -1 {
+		c.controller = id
+	}
+}
+
+// running returns the sorted IDs of the running brokers
+func (c *MockCluster) running() []int32 {
+	var ids []int32
+	for _, id := range c.ids {
+		if c.brokers[id] != nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (c *MockCluster) runningReplica(p *mockClusterPartition) int32 {
+	for _, replica := range p.replicas {
+		if c.brokers[replica] != nil {
+			return replica
+		}
+	}
+	return -1
+}
+
+// Coordinator returns the ID of the broker coordinating the group, electing
+// one among the running brokers if needed.
+func (c *MockCluster) Coordinator(group string) int32 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.coordinator(group)
+}
+
+func (c *MockCluster) coordinator(key string) int32 {
+	if id, ok := c.coordinators[key]; ok {
+		return id
+	}
+	running := c.running()
+	if len(running) == 0 {
+		return -1
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	id := running[h.Sum32()%uint32(len(running))]
+	c.coordinators[key] = id
+	return id
+}
+
+// MoveCoordinator makes the given running broker coordinate the group.
+func (c *MockCluster) MoveCoordinator(group string, brokerID int32) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.brokers[brokerID] == nil {
+		return fmt.Errorf("%w: broker %d is stopped", ErrBrokerNotAvailable, brokerID)
+	}
+	c.coordinators[group] = brokerID
+	return nil
+}
+
+// GroupMembers returns the sorted IDs of the members of the group, and the
+// generation of the group.
+func (c *MockCluster) GroupMembers(group string) ([]string, int32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	g, ok := c.groups[group]
+	if !ok {
+		return nil, 0
+	}
+	c.expireMembers(g)
+	members := make([]string, 0, len(g.members))
+	for id := range g.members {
+		members = append(members, id)
+	}
+	sort.Strings(members)
+	return members, g.generation
+}
+
+// CommittedOffset returns the offset committed by the group for the
+// partition, -1 when it committed none.
+func (c *MockCluster) CommittedOffset(group, topic string, partition int32) int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if g, ok := c.groups[group]; ok {
+		if block, ok := g.offsets[topic][partition]; ok {
+			return block.Offset
+		}
+	}
+	return -1
+}
+
+// Close stops all the brokers of the cluster.
+func (c *MockCluster) Close() {
+	c.lock.Lock()
+	brokers := make([]*MockBroker, 0, len(c.brokers))
+	for _, broker := range c.brokers {
+		brokers = append(brokers, broker)
+	}
+	c.brokers = make(map[int32]*MockBroker)
+	c.lock.Unlock()
+
+	for _, broker := range brokers {
+		broker.Close()
+	}
+}
+
+// handle answers a request received by a broker, the cluster being locked
+func (c *MockCluster) handle(id int32, req *request) encoderWithHeader {
+	if c.brokers[id] == nil {
+		// the broker is being stopped
+		return nil
+	}
+
+	switch body := req.body.(type) {
+	case *ApiVersionsRequest:
+		return NewMockApiVersionsResponse(c.t).For(body)
+	case *MetadataRequest:
+		return c.metadata(body)
+	case *ProduceRequest:
+		return c.produce(id, body)
+	case *FetchRequest:
+		return c.fetch(id, body)
+	case *OffsetRequest:
+		return c.listOffsets(id, body)
+	case *FindCoordinatorRequest:
+		res := &FindCoordinatorResponse{Version: body.Version}
+		if body.Version >= 4 {
+			for _, key := range body.CoordinatorKeys {
+				result := &FindCoordinatorResponseCoordinator{Key: key}
+				if coordinator := c.coordinator(key); coordinator >= 0 {
+					result.Coordinator = &Broker{id: coordinator, addr: c.addrs[coordinator]}
+				} else {
+					result.Err = ErrConsumerCoordinatorNotAvailable
+				}
+				res.Coordinators = append(res.Coordinators, result)
+			}
+			return res
+		}
+		if coordinator := c.coordinator(body.CoordinatorKey); coordinator >= 0 {
+			res.Coordinator = &Broker{id: coordinator, addr: c.addrs[coordinator]}
+		} else {
+			res.Err = ErrConsumerCoordinatorNotAvailable
+		}
+		return res
+	case *ConsumerMetadataRequest:
+		res := &ConsumerMetadataResponse{}
+		if coordinator := c.coordinator(body.ConsumerGroup); coordinator >= 0 {
+			res.Coordinator = &Broker{id: coordinator, addr: c.addrs[coordinator]}
+		} else {
+			res.Err = ErrConsumerCoordinatorNotAvailable
+		}
+		return res
+	case *JoinGroupRequest:
+		if c.coordinator(body.GroupId) != id {
+			return &JoinGroupResponse{Version: body.Version, Err: ErrNotCoordinatorForConsumer, GenerationId: -1}
+		}
+		return c.joinGroup(body)
+	case *SyncGroupRequest:
+		if c.coordinator(body.GroupId) != id {
+			return &SyncGroupResponse{Err: ErrNotCoordinatorForConsumer}
+		}
+		return c.syncGroup(body)
+	case *HeartbeatRequest:
+		if c.coordinator(body.GroupId) != id {
+			return &HeartbeatResponse{Err: ErrNotCoordinatorForConsumer}
+		}
+		return &HeartbeatResponse{Err: c.heartbeat(body)}
+	case *LeaveGroupRequest:
+		if c.coordinator(body.GroupId) != id {
+			return &LeaveGroupResponse{Version: body.Version, Err: ErrNotCoordinatorForConsumer}
+		}
+		return c.leaveGroup(body)
+	case *OffsetCommitRequest:
+		return c.commitOffsets(id, body)
+	case *OffsetFetchRequest:
+		return c.fetchOffsets(id, body)
+	default:
+		Logger.Printf("*** mockcluster/%d: ignored %s", id, reflect.TypeOf(req.body).Elem().Name())
+		return nil
+	}
+}
+
+func (c *MockCluster) metadata(req *MetadataRequest) encoderWithHeader {
+	res := &MetadataResponse{Version: req.version(), ControllerID: c.controller}
+	for _, id := range c.running() {
+		res.AddBroker(c.addrs[id], id)
+	}
+
+	topics := req.Topics
+	if len(topics) == 0 {
+		for topic := range c.topics {
+			topics = append(topics, topic)
+		}
+		sort.Strings(topics)
+	}
+	for _, topic := range topics {
+		partitions, ok := c.topics[topic]
+		if !ok {
+			res.AddTopic(topic, ErrUnknownTopicOrPartition)
+			continue
+		}
+		for i, p := range partitions {
+			isr := []int32{}
+			offline := []int32{}
+			for _, replica := range p.replicas {
+				if c.brokers[replica] != nil {
+					isr = append(isr, replica)
+				} else {
+					offline = append(offline, replica)
+				}
+			}
+			kerr := ErrNoError
+			if p.leader == -1 {
+				kerr = ErrLeaderNotAvailable
+			}
+			res.AddTopicPartition(topic, int32(i), p.leader, p.replicas, isr, offline, kerr)
+			tm := res.Topics[len(res.Topics)-1]
+			tm.Partitions[len(tm.Partitions)-1].LeaderEpoch = p.leaderEpoch
+		}
+	}
+	return res
+}
+
+// leaderError returns the error of a request for the partition sent to the
+// given broker
+func (c *MockCluster) leaderError(id int32, topic string, partition int32) (*mockClusterPartition, KError) {
+	p, err := c.partition(topic, partition)
+	if err != nil {
+		return nil, ErrUnknownTopicOrPartition
+	}
+	if p.leader != id {
+		return nil, ErrNotLeaderForPartition
+	}
+	return p, ErrNoError
+}
+
+func (c *MockCluster) produce(id int32, req *ProduceRequest) encoderWithHeader {
+	res := &ProduceResponse{Version: req.Version}
+	for topic, partitions := range req.records {
+		for partition, records := range partitions {
+			p, kerr := c.leaderError(id, topic, partition)
+			res.AddTopicPartition(topic, partition, kerr)
+			if kerr != ErrNoError {
+				continue
+			}
+			res.Blocks[topic][partition].Offset = int64(len(p.records))
+			p.records = append(p.records, mockClusterRecords(records)...)
+		}
+	}
+	if req.RequiredAcks == NoResponse {
+		return nil
+	}
+	return res
+}
+
+// mockClusterRecords returns the records of a produce request
+func mockClusterRecords(records Records) []*mockClusterRecord {
+	var result []*mockClusterRecord
+	if records.MsgSet != nil {
+		for _, block := range records.MsgSet.Messages {
+			for _, msg := range block.Messages() {
+				result = append(result, &mockClusterRecord{
+					key:       msg.Msg.Key,
+					value:     msg.Msg.Value,
+					timestamp: msg.Msg.Timestamp,
+				})
+			}
+		}
+	}
+	if batch := records.RecordBatch; batch != nil {
+		for _, rec := range batch.Records {
+			result = append(result, &mockClusterRecord{
+				key:       rec.Key,
+				value:     rec.Value,
+				headers:   rec.Headers,
+				timestamp: batch.FirstTimestamp.Add(rec.TimestampDelta),
+			})
+		}
+	}
+	return result
+}
+
+func (c *MockCluster) fetch(id int32, req *FetchRequest) encoderWithHeader {
+	res := &FetchResponse{Version: req.Version}
+	for topic, partitions := range req.blocks {
+		for partition, block := range partitions {
+			p, kerr := c.leaderError(id, topic, partition)
+			if kerr == ErrNoError && (block.fetchOffset < 0 || block.fetchOffset > int64(len(p.records))) {
+				kerr = ErrOffsetOutOfRange
+			}
+			res.AddError(topic, partition, kerr)
+			if kerr != ErrNoError {
+				continue
+			}
+
+			for offset := block.fetchOffset; offset < int64(len(p.records)) && offset < block.fetchOffset+mockClusterMaxFetchRecords; offset++ {
+				rec := p.records[offset]
+				switch {
+				case req.Version >= 4:
+					res.AddRecordBatchWithTimestamp(topic, partition, ByteEncoder(rec.key), ByteEncoder(rec.value), offset, -1, false, rec.timestamp)
+					sets := res.GetBlock(topic, partition).RecordsSet
+					sets[len(sets)-1].RecordBatch.Records[0].Headers = rec.headers
+				case req.Version >= 2:
+					res.AddMessageWithTimestamp(topic, partition, ByteEncoder(rec.key), ByteEncoder(rec.value), offset, rec.timestamp, 1)
+				default:
+					res.AddMessage(topic, partition, ByteEncoder(rec.key), ByteEncoder(rec.value), offset)
+				}
+			}
+			frb := res.GetBlock(topic, partition)
+			frb.HighWaterMarkOffset = int64(len(p.records))
+			frb.LastStableOffset = frb.HighWaterMarkOffset
+		}
+	}
+	return res
+}
+
+func (c *MockCluster) listOffsets(id int32, req *OffsetRequest) encoderWithHeader {
+	res := &OffsetResponse{Version: req.Version}
+	for topic, partitions := range req.blocks {
+		for partition, block := range partitions {
+			p, kerr := c.leaderError(id, topic, partition)
+			if kerr != ErrNoError {
+				res.AddTopicPartition(topic, partition, -1)
+				res.Blocks[topic][partition].Err = kerr
+				continue
+			}
+
+			offset := int64(len(p.records))
+			switch block.time {
+			case OffsetOldest:
+				offset = 0
+			case OffsetNewest:
+			default:
+				for i, rec := range p.records {
+					if !rec.timestamp.Before(time.Unix(0, block.time*int64(time.Millisecond))) {
+						offset = int64(i)
+						break
+					}
+				}
+			}
+			res.AddTopicPartition(topic, partition, offset)
+			res.Blocks[topic][partition].LeaderEpoch = p.leaderEpoch
+		}
+	}
+	return res
+}
+
+// group returns the group, creating it if needed, after removing the members
+// whose session expired
+func (c *MockCluster) group(id string) *mockClusterGroup {
+	g, ok := c.groups[id]
+	if !ok {
+		g = &mockClusterGroup{
+			members: make(map[string]*mockClusterMember),
+			offsets: make(map[string]map[int32]*OffsetFetchResponseBlock),
+		}
+		c.groups[id] = g
+	}
+	c.expireMembers(g)
+	return g
+}
+
+func (c *MockCluster) expireMembers(g *mockClusterGroup) {
+	now := time.Now()
+	for id, member := range g.members {
+		if now.Sub(member.lastSeen) > member.sessionTimeout {
+			delete(g.members, id)
+			g.rebalance()
+		}
+	}
+}
+
+// rebalance starts a new generation of the group, which the members must join
+func (g *mockClusterGroup) rebalance() {
+	g.generation++
+	g.leader = ""
+	g.assignments = nil
+	g.assigned = false
+}
+
+// stable reports whether all the members joined the current generation
+func (g *mockClusterGroup) stable() bool {
+	for _, member := range g.members {
+		if member.generation != g.generation {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *MockCluster) joinGroup(req *JoinGroupRequest) encoderWithHeader {
+	g := c.group(req.GroupId)
+	res := &JoinGroupResponse{Version: req.Version, GenerationId: -1}
+
+	protocols := req.OrderedGroupProtocols
+	if len(protocols) == 0 {
+		for name, metadata := range req.GroupProtocols {
+			protocols = append(protocols, &GroupProtocol{Name: name, Metadata: metadata})
+		}
+	}
+
+	memberID := req.MemberId
+	member, ok := g.members[memberID]
+	switch {
+	case memberID == "":
+		g.nextMemberID++
+		memberID = fmt.Sprintf("%s-%d", req.GroupId, g.nextMemberID)
+		member = &mockClusterMember{}
+		g.members[memberID] = member
+		g.rebalance()
+	case !ok:
+		res.Err = ErrUnknownMemberId
+		return res
+	case g.stable() && !reflect.DeepEqual(member.protocols, protocols):
+		// the known members rejoining during a rebalance do not start another one
+		g.rebalance()
+	case member.generation == g.generation && g.leader != memberID:
+		// the member missed the sync of its generation
+		g.rebalance()
+	}
+
+	member.protocols = protocols
+	member.sessionTimeout = time.Duration(req.SessionTimeout) * time.Millisecond
+	member.lastSeen = time.Now()
+	member.generation = g.generation
+	if g.leader == "" {
+		g.leader = memberID
+		g.protocol = protocols[0].Name
+	}
+
+	res.GenerationId = g.generation
+	res.GroupProtocol = g.protocol
+	res.LeaderId = g.leader
+	res.MemberId = memberID
+	if memberID == g.leader {
+		res.Members = make(map[string][]byte, len(g.members))
+		for id, m := range g.members {
+			for _, protocol := range m.protocols {
+				if protocol.Name == g.protocol {
+					res.Members[id] = protocol.Metadata
+				}
+			}
+		}
+	}
+	return res
+}
+
+// memberError returns the error of a request of the member of the group in
+// the given generation
+func (g *mockClusterGroup) memberError(memberID string, generation int32) KError {
+	member, ok := g.members[memberID]
+	switch {
+	case !ok:
+		return ErrUnknownMemberId
+	case member.generation != g.generation:
+		return ErrRebalanceInProgress
+	case generation != g.generation:
+		return ErrIllegalGeneration
+	}
+	member.lastSeen = time.Now()
+	return ErrNoError
+}
+
+func (c *MockCluster) syncGroup(req *SyncGroupRequest) encoderWithHeader {
+	g := c.group(req.GroupId)
+	if kerr := g.memberError(req.MemberId, req.GenerationId); kerr != ErrNoError {
+		return &SyncGroupResponse{Err: kerr}
+	}
+	if req.MemberId == g.leader {
+		g.assignments = req.GroupAssignments
+		g.assigned = true
+	}
+	if !g.assigned {
+		// the leader did not sync its assignments yet
+		return &SyncGroupResponse{Err: ErrRebalanceInProgress}
+	}
+	return &SyncGroupResponse{MemberAssignment: g.assignments[req.MemberId]}
+}
+
+func (c *MockCluster) heartbeat(req *HeartbeatRequest) KError {
+	return c.group(req.GroupId).memberError(req.MemberId, req.GenerationId)
+}
+
+func (c *MockCluster) leaveGroup(req *LeaveGroupRequest) encoderWithHeader {
+	g := c.group(req.GroupId)
+	res := &LeaveGroupResponse{Version: req.Version}
+
+	leave := func(memberID string) KError {
+		if _, ok := g.members[memberID]; !ok {
+			return ErrUnknownMemberId
+		}
+		delete(g.members, memberID)
+		g.rebalance()
+		return ErrNoError
+	}
+	if req.Version < 3 {
+		res.Err = leave(req.MemberId)
+		return res
+	}
+	for _, member := range req.Members {
+		res.Members = append(res.Members, MemberResponse{
+			MemberId:        member.MemberId,
+			GroupInstanceId: member.GroupInstanceId,
+			Err:             leave(member.MemberId),
+		})
+	}
+	return res
+}
+
+func (c *MockCluster) commitOffsets(id int32, req *OffsetCommitRequest) encoderWithHeader {
+	res := &OffsetCommitResponse{Version: req.Version}
+	kerr := ErrNoError
+	if c.coordinator(req.ConsumerGroup) != id {
+		kerr = ErrNotCoordinatorForConsumer
+	}
+	g := c.group(req.ConsumerGroup)
+	if kerr == ErrNoError && req.Version >= 1 && req.ConsumerGroupGeneration >= 0 {
+		kerr = g.memberError(req.ConsumerID, req.ConsumerGroupGeneration)
+	}
+
+	for topic, partitions := range req.blocks {
+		for partition, block := range partitions {
+			res.AddError(topic, partition, kerr)
+			if kerr != ErrNoError {
+				continue
+			}
+			if g.offsets[topic] == nil {
+				g.offsets[topic] = make(map[int32]*OffsetFetchResponseBlock)
+			}
+			g.offsets[topic][partition] = &OffsetFetchResponseBlock{
+				Offset:      block.offset,
+				LeaderEpoch: block.committedLeaderEpoch,
+				Metadata:    block.metadata,
+				Err:         ErrNoError,
+			}
+		}
+	}
+	return res
+}
+
+func (c *MockCluster) fetchOffsets(id int32, req *OffsetFetchRequest) encoderWithHeader {
+	res := &OffsetFetchResponse{Version: req.Version}
+	if c.coordinator(req.ConsumerGroup) != id {
+		if req.Version >= 2 {
+			res.Err = ErrNotCoordinatorForConsumer
+		}
+		for topic, partitions := range req.partitions {
+			for _, partition := range partitions {
+				res.AddBlock(topic, partition, &OffsetFetchResponseBlock{Offset: -1, LeaderEpoch: -1, Err: ErrNotCoordinatorForConsumer})
+			}
+		}
+		return res
+	}
+
+	g := c.group(req.ConsumerGroup)
+	if req.partitions == nil {
+		for topic, partitions := range g.offsets {
+			for partition, block := range partitions {
+				res.AddBlock(topic, partition, block)
+			}
+		}
+		return res
+	}
+	for topic, partitions := range req.partitions {
+		for _, partition := range partitions {
+			block, ok := g.offsets[topic][partition]
+			if !ok {
+				block = &OffsetFetchResponseBlock{Offset: -1, LeaderEpoch: -1, Err: ErrNoError}
+			}
+			res.AddBlock(topic, partition, block)
+		}
+	}
+	return res
+}
@@ -0,0 +1,187 @@
+package sarama
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMockClusterProduceConsumeFailover(t *testing.T) {
+	cluster := NewMockCluster(t, 3)
+	defer cluster.Close()
+	if err := cluster.CreateTopic("my_topic", 2, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	config := NewTestConfig()
+	config.Version = V0_11_0_0
+	config.Producer.Return.Successes = true
+	config.Producer.Partitioner = NewManualPartitioner
+	config.Producer.Retry.Backoff = 10 * time.Millisecond
+	config.Metadata.Retry.Backoff = 10 * time.Millisecond
+	config.Consumer.Retry.Backoff = 10 * time.Millisecond
+	client, err := NewClient(cluster.Addrs(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	producer, err := NewSyncProducerFromClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, producer)
+
+	msg := &ProducerMessage{Topic: "my_topic", Partition: 0, Value: StringEncoder("before"), Headers: []RecordHeader{{Key: []byte("k"), Value: []byte("v")}}}
+	if _, offset, err := producer.SendMessage(msg); err != nil || offset != 0 {
+		t.Fatalf("Expected the message to be produced at offset 0, got %d %v", offset, err)
+	}
+
+	leader, _ := cluster.Leader("my_topic", 0)
+	cluster.StopBroker(leader)
+	newLeader, _ := cluster.Leader("my_topic", 0)
+	if newLeader == leader || newLeader == -1 {
+		t.Fatalf("Expected another replica to lead the partition, got %d", newLeader)
+	}
+
+	msg = &ProducerMessage{Topic: "my_topic", Partition: 0, Value: StringEncoder("after")}
+	if _, offset, err := producer.SendMessage(msg); err != nil || offset != 1 {
+		t.Fatalf("Expected the message to be produced at offset 1, got %d %v", offset, err)
+	}
+	if hwm, _ := cluster.HighWaterMark("my_topic", 0); hwm != 2 {
+		t.Errorf("Expected a high water mark of 2, got %d", hwm)
+	}
+
+	consumer, err := NewConsumerFromClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, consumer)
+	pc, err := consumer.ConsumePartition("my_topic", 0, OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, pc)
+
+	for i, expected := range []string{"before", "after"} {
+		select {
+		case msg := <-pc.Messages():
+			if msg.Offset != int64(i) || string(msg.Value) != expected {
+				t.Errorf("Unexpected message %d: %s", msg.Offset, msg.Value)
+			}
+			if i == 0 && (len(msg.Headers) != 1 || string(msg.Headers[0].Key) != "k") {
+				t.Errorf("Expected the headers to be consumed, got %v", msg.Headers)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out consuming the messages")
+		}
+	}
+}
+
+type mockClusterGroupHandler struct {
+	lock   sync.Mutex
+	claims map[string][]int32
+}
+
+func (h *mockClusterGroupHandler) Setup(sess ConsumerGroupSession) error {
+	h.lock.Lock()
+	h.claims = sess.Claims()
+	h.lock.Unlock()
+	return nil
+}
+
+func (h *mockClusterGroupHandler) Cleanup(ConsumerGroupSession) error { return nil }
+
+func (h *mockClusterGroupHandler) ConsumeClaim(sess ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		sess.MarkMessage(msg, "")
+	}
+	return nil
+}
+
+func (h *mockClusterGroupHandler) claimed() int {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return len(h.claims["my_topic"])
+}
+
+func TestMockClusterConsumerGroupRebalance(t *testing.T) {
+	cluster := NewMockCluster(t, 2)
+	defer cluster.Close()
+	if err := cluster.CreateTopic("my_topic", 2, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	config := NewTestConfig()
+	config.Version = V2_0_0_0
+	config.Producer.Return.Successes = true
+	config.Producer.Partitioner = NewManualPartitioner
+	config.Metadata.Retry.Backoff = 10 * time.Millisecond
+	config.Consumer.Offsets.Initial = OffsetOldest
+	config.Consumer.Offsets.AutoCommit.Interval = 10 * time.Millisecond
+	config.Consumer.Group.Heartbeat.Interval = 20 * time.Millisecond
+	config.Consumer.Group.Rebalance.Retry.Backoff = 10 * time.Millisecond
+
+	producer, err := NewSyncProducer(cluster.Addrs(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, producer)
+	for partition := int32(0); partition < 2; partition++ {
+		if _, _, err := producer.SendMessage(&ProducerMessage{Topic: "my_topic", Partition: partition, Value: StringEncoder("msg")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	consume := func(handler *mockClusterGroupHandler) {
+		group, err := NewConsumerGroup(cluster.Addrs(), "my_group", config)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { _ = group.Close() }()
+			for ctx.Err() == nil {
+				if err := group.Consume(ctx, []string{"my_topic"}, handler); err != nil {
+					return
+				}
+			}
+		}()
+	}
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(10 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %s", what)
+			}
+		}
+	}
+
+	first := &mockClusterGroupHandler{}
+	consume(first)
+	waitFor("the first member to claim both partitions", func() bool { return first.claimed() == 2 })
+
+	second := &mockClusterGroupHandler{}
+	consume(second)
+	waitFor("the members to share the partitions", func() bool { return first.claimed() == 1 && second.claimed() == 1 })
+	if members, _ := cluster.GroupMembers("my_group"); len(members) != 2 {
+		t.Errorf("Expected 2 members, got %v", members)
+	}
+
+	cluster.StopBroker(cluster.Coordinator("my_group"))
+	if _, _, err := producer.SendMessage(&ProducerMessage{Topic: "my_topic", Partition: 0, Value: StringEncoder("msg")}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the offsets to be committed to the new coordinator", func() bool {
+		return cluster.CommittedOffset("my_group", "my_topic", 0) == 2 && cluster.CommittedOffset("my_group", "my_topic", 1) == 1
+	})
+
+	cancel()
+	wg.Wait()
+}
@@ -3,6 +3,8 @@ package sarama
 import (
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 )
 
 // TestReporter has methods matching go's testing.T to avoid importing
@@ -68,31 +70,69 @@ func (mc *MockSequence) For(reqBody versionedDecoder) (res encoderWithHeader) {
 }
 
 type MockListGroupsResponse struct {
-	groups map[string]string
-	t      TestReporter
+	groups     map[string]string
+	groupsData map[string]GroupData
+	t          TestReporter
 }
 
 func NewMockListGroupsResponse(t TestReporter) *MockListGroupsResponse {
 	return &MockListGroupsResponse{
-		groups: make(map[string]string),
-		t:      t,
+		groups:     make(map[string]string),
+		groupsData: make(map[string]GroupData),
+		t:          t,
 	}
 }
 
 func (m *MockListGroupsResponse) For(reqBody versionedDecoder) encoderWithHeader {
 	request := reqBody.(*ListGroupsRequest)
-	_ = request
 	response := &ListGroupsResponse{
-		Groups: m.groups,
+		Version: request.Version,
+		Groups:  make(map[string]string),
+	}
+	if request.Version >= 4 {
+		response.GroupsData = make(map[string]GroupData)
+	}
+	for group, protocolType := range m.groups {
+		data := m.groupsData[group]
+		if !mockFilterMatches(request.StatesFilter, data.GroupState) ||
+			!mockFilterMatches(request.TypesFilter, data.GroupType) {
+			continue
+		}
+		response.Groups[group] = protocolType
+		if request.Version >= 4 {
+			response.GroupsData[group] = data
+		}
 	}
 	return response
 }
 
+// mockFilterMatches reports whether value matches a ListGroupsRequest filter,
+// an empty filter matching every value
+func mockFilterMatches(filter []string, value string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, f := range filter {
+		if f == value {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *MockListGroupsResponse) AddGroup(groupID, protocolType string) *MockListGroupsResponse {
 	m.groups[groupID] = protocolType
 	return m
 }
 
+// AddGroupWithState adds a group in the given state and of the given type,
+// which are returned from version 4 and 5 of the ListGroupsResponse.
+func (m *MockListGroupsResponse) AddGroupWithState(groupID, protocolType, state, groupType string) *MockListGroupsResponse {
+	m.groups[groupID] = protocolType
+	m.groupsData[groupID] = GroupData{GroupState: state, GroupType: groupType}
+	return m
+}
+
 type MockDescribeGroupsResponse struct {
 	groups map[string]*GroupDescription
 	t      TestReporter
@@ -133,16 +173,22 @@ func (m *MockDescribeGroupsResponse) For(reqBody versionedDecoder) encoderWithHe
 // MockMetadataResponse is a `MetadataResponse` builder.
 type MockMetadataResponse struct {
 	controllerID int32
+	clusterID    *string
+	clusterOps   int32
 	leaders      map[string]map[int32]int32
 	brokers      map[string]int32
+	racks        map[int32]string
+	topicIDs     map[string]Uuid
 	t            TestReporter
 }
 
 func NewMockMetadataResponse(t TestReporter) *MockMetadataResponse {
 	return &MockMetadataResponse{
-		leaders: make(map[string]map[int32]int32),
-		brokers: make(map[string]int32),
-		t:       t,
+		leaders:  make(map[string]map[int32]int32),
+		brokers:  make(map[string]int32),
+		racks:    make(map[int32]string),
+		topicIDs: make(map[string]Uuid),
+		t:        t,
 	}
 }
 
@@ -161,24 +207,57 @@ func (mmr *MockMetadataResponse) SetBroker(addr string, brokerID int32) *MockMet
 	return mmr
 }
 
+// SetRack sets the rack of a broker, which is returned from version 1 of the
+// metadata response.
+func (mmr *MockMetadataResponse) SetRack(brokerID int32, rack string) *MockMetadataResponse {
+	mmr.racks[brokerID] = rack
+	return mmr
+}
+
+// SetTopicID sets the ID of a topic, which is returned from version 10 of the
+// metadata response.
+func (mmr *MockMetadataResponse) SetTopicID(topic string, topicID Uuid) *MockMetadataResponse {
+	mmr.topicIDs[topic] = topicID
+	return mmr
+}
+
 func (mmr *MockMetadataResponse) SetController(brokerID int32) *MockMetadataResponse {
 	mmr.controllerID = brokerID
 	return mmr
 }
 
+func (mmr *MockMetadataResponse) SetClusterID(clusterID string) *MockMetadataResponse {
+	mmr.clusterID = &clusterID
+	return mmr
+}
+
+// SetClusterAuthorizedOperations sets the bit field of the cluster authorized
+// operations returned to the requests that include them.
+func (mmr *MockMetadataResponse) SetClusterAuthorizedOperations(operations int32) *MockMetadataResponse {
+	mmr.clusterOps = operations
+	return mmr
+}
+
 func (mmr *MockMetadataResponse) For(reqBody versionedDecoder) encoderWithHeader {
 	metadataRequest := reqBody.(*MetadataRequest)
 	metadataResponse := &MetadataResponse{
 		Version:      metadataRequest.version(),
 		ControllerID: mmr.controllerID,
+		ClusterID:    mmr.clusterID,
+	}
+	if metadataRequest.IncludeClusterAuthorizedOperations {
+		metadataResponse.ClusterAuthorizedOperations = mmr.clusterOps
 	}
 	for addr, brokerID := range mmr.brokers {
 		metadataResponse.AddBroker(addr, brokerID)
+		if rack, ok := mmr.racks[brokerID]; ok {
+			metadataResponse.Brokers[len(metadataResponse.Brokers)-1].rack = &rack
+		}
 	}
 
-	// Generate set of replicas
-	var replicas []int32
-	var offlineReplicas []int32
+	// Generate set of replicas, non-null for the compact arrays of version 9+
+	replicas := []int32{}
+	offlineReplicas := []int32{}
 	for _, brokerID := range mmr.brokers {
 		replicas = append(replicas, brokerID)
 	}
@@ -189,11 +268,16 @@ func (mmr *MockMetadataResponse) For(reqBody versionedDecoder) encoderWithHeader
 				metadataResponse.AddTopicPartition(topic, partition, brokerID, replicas, replicas, offlineReplicas, ErrNoError)
 			}
 		}
-		return metadataResponse
+	} else {
+		for _, topic := range metadataRequest.Topics {
+			for partition, brokerID := range mmr.leaders[topic] {
+				metadataResponse.AddTopicPartition(topic, partition, brokerID, replicas, replicas, offlineReplicas, ErrNoError)
+			}
+		}
 	}
-	for _, topic := range metadataRequest.Topics {
-		for partition, brokerID := range mmr.leaders[topic] {
-			metadataResponse.AddTopicPartition(topic, partition, brokerID, replicas, replicas, offlineReplicas, ErrNoError)
+	if metadataResponse.Version >= 10 {
+		for _, topic := range metadataResponse.Topics {
+			topic.Uuid = mmr.topicIDs[topic.Name]
 		}
 	}
 	return metadataResponse
@@ -201,15 +285,17 @@ func (mmr *MockMetadataResponse) For(reqBody versionedDecoder) encoderWithHeader
 
 // MockOffsetResponse is an `OffsetResponse` builder.
 type MockOffsetResponse struct {
-	offsets map[string]map[int32]map[int64]int64
-	t       TestReporter
-	version int16
+	offsets      map[string]map[int32]map[int64]int64
+	leaderEpochs map[string]map[int32]int32
+	t            TestReporter
+	version      int16
 }
 
 func NewMockOffsetResponse(t TestReporter) *MockOffsetResponse {
 	return &MockOffsetResponse{
-		offsets: make(map[string]map[int32]map[int64]int64),
-		t:       t,
+		offsets:      make(map[string]map[int32]map[int64]int64),
+		leaderEpochs: make(map[string]map[int32]int32),
+		t:            t,
 	}
 }
 
@@ -233,6 +319,17 @@ func (mor *MockOffsetResponse) SetOffset(topic string, partition int32, time, of
 	return mor
 }
 
+// SetLeaderEpoch sets the leader epoch returned for a partition from version 4
+func (mor *MockOffsetResponse) SetLeaderEpoch(topic string, partition int32, leaderEpoch int32) *MockOffsetResponse {
+	partitions := mor.leaderEpochs[topic]
+	if partitions == nil {
+		partitions = make(map[int32]int32)
+		mor.leaderEpochs[topic] = partitions
+	}
+	partitions[partition] = leaderEpoch
+	return mor
+}
+
 func (mor *MockOffsetResponse) For(reqBody versionedDecoder) encoderWithHeader {
 	offsetRequest := reqBody.(*OffsetRequest)
 	offsetResponse := &OffsetResponse{Version: mor.version}
@@ -240,6 +337,9 @@ func (mor *MockOffsetResponse) For(reqBody versionedDecoder) encoderWithHeader {
 		for partition, block := range partitions {
 			offset := mor.getOffset(topic, partition, block.time)
 			offsetResponse.AddTopicPartition(topic, partition, offset)
+			if leaderEpoch, ok := mor.leaderEpochs[topic][partition]; ok {
+				offsetResponse.Blocks[topic][partition].LeaderEpoch = leaderEpoch
+			}
 		}
 	}
 	return offsetResponse
@@ -265,6 +365,7 @@ func (mor *MockOffsetResponse) getOffset(topic string, partition int32, time int
 type MockFetchResponse struct {
 	messages       map[string]map[int32]map[int64]Encoder
 	highWaterMarks map[string]map[int32]int64
+	topicIDs       map[string]Uuid
 	t              TestReporter
 	batchSize      int
 	version        int16
@@ -274,11 +375,19 @@ func NewMockFetchResponse(t TestReporter, batchSize int) *MockFetchResponse {
 	return &MockFetchResponse{
 		messages:       make(map[string]map[int32]map[int64]Encoder),
 		highWaterMarks: make(map[string]map[int32]int64),
+		topicIDs:       make(map[string]Uuid),
 		t:              t,
 		batchSize:      batchSize,
 	}
 }
 
+// SetTopicID sets the ID of a topic, by which the topic is fetched from
+// version 13 of the fetch request.
+func (mfr *MockFetchResponse) SetTopicID(topic string, topicID Uuid) *MockFetchResponse {
+	mfr.topicIDs[topic] = topicID
+	return mfr
+}
+
 func (mfr *MockFetchResponse) SetVersion(version int16) *MockFetchResponse {
 	mfr.version = version
 	return mfr
@@ -314,7 +423,22 @@ func (mfr *MockFetchResponse) For(reqBody versionedDecoder) encoderWithHeader {
 	res := &FetchResponse{
 		Version: mfr.version,
 	}
-	for topic, partitions := range fetchRequest.blocks {
+	topicNames := make(map[Uuid]string, len(mfr.topicIDs))
+	for topic, topicID := range mfr.topicIDs {
+		topicNames[topicID] = topic
+	}
+	if fetchRequest.Version >= 13 {
+		res.TopicIDs = make(map[string]Uuid, len(fetchRequest.TopicIDs))
+	}
+	for requested, partitions := range fetchRequest.blocks {
+		// the topics fetched by ID are named after their ID when decoded
+		topic := requested
+		if topicID, ok := fetchRequest.TopicIDs[requested]; ok {
+			if name, ok := topicNames[topicID]; ok {
+				topic = name
+			}
+			res.TopicIDs[topic] = topicID
+		}
 		for partition, block := range partitions {
 			initialOffset := block.fetchOffset
 			offset := initialOffset
@@ -444,21 +568,34 @@ func (mr *MockFindCoordinatorResponse) SetError(coordinatorType CoordinatorType,
 
 func (mr *MockFindCoordinatorResponse) For(reqBody versionedDecoder) encoderWithHeader {
 	req := reqBody.(*FindCoordinatorRequest)
-	res := &FindCoordinatorResponse{}
+	res := &FindCoordinatorResponse{Version: req.Version}
+	if req.Version >= 4 {
+		for _, key := range req.CoordinatorKeys {
+			coordinator := &FindCoordinatorResponseCoordinator{Key: key}
+			coordinator.Coordinator, coordinator.Err = mr.coordinator(req.CoordinatorType, key)
+			res.Coordinators = append(res.Coordinators, coordinator)
+		}
+		return res
+	}
+	res.Coordinator, res.Err = mr.coordinator(req.CoordinatorType, req.CoordinatorKey)
+	return res
+}
+
+func (mr *MockFindCoordinatorResponse) coordinator(coordinatorType CoordinatorType, key string) (*Broker, KError) {
 	var v interface{}
-	switch req.CoordinatorType {
+	switch coordinatorType {
 	case CoordinatorGroup:
-		v = mr.groupCoordinators[req.CoordinatorKey]
+		v = mr.groupCoordinators[key]
 	case CoordinatorTransaction:
-		v = mr.transCoordinators[req.CoordinatorKey]
+		v = mr.transCoordinators[key]
 	}
 	switch v := v.(type) {
 	case *MockBroker:
-		res.Coordinator = &Broker{id: v.BrokerID(), addr: v.Addr()}
+		return &Broker{id: v.BrokerID(), addr: v.Addr()}, ErrNoError
 	case KError:
-		res.Err = v
+		return nil, v
 	}
-	return res
+	return nil, ErrNoError
 }
 
 // MockOffsetCommitResponse is a `OffsetCommitResponse` builder.
@@ -492,7 +629,7 @@ func (mr *MockOffsetCommitResponse) SetError(group, topic string, partition int3
 func (mr *MockOffsetCommitResponse) For(reqBody versionedDecoder) encoderWithHeader {
 	req := reqBody.(*OffsetCommitRequest)
 	group := req.ConsumerGroup
-	res := &OffsetCommitResponse{}
+	res := &OffsetCommitResponse{Version: req.Version}
 	for topic, partitions := range req.blocks {
 		for partition := range partitions {
 			res.AddError(topic, partition, mr.getError(group, topic, partition))
@@ -735,6 +872,31 @@ func (mr *MockListPartitionReassignmentsResponse) For(reqBody versionedDecoder)
 	return res
 }
 
+type MockElectLeadersResponse struct {
+	t TestReporter
+}
+
+func NewMockElectLeadersResponse(t TestReporter) *MockElectLeadersResponse {
+	return &MockElectLeadersResponse{t: t}
+}
+
+func (mr *MockElectLeadersResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*ElectLeadersRequest)
+	res := &ElectLeadersResponse{
+		Version:                req.Version,
+		ReplicaElectionResults: make(map[string]map[int32]*PartitionResult),
+	}
+
+	for topic, partitions := range req.TopicPartitions {
+		res.ReplicaElectionResults[topic] = make(map[int32]*PartitionResult)
+		for _, partition := range partitions {
+			res.ReplicaElectionResults[topic][partition] = &PartitionResult{ErrorCode: ErrNoError}
+		}
+	}
+
+	return res
+}
+
 type MockDeleteRecordsResponse struct {
 	t TestReporter
 }
@@ -750,8 +912,8 @@ func (mr *MockDeleteRecordsResponse) For(reqBody versionedDecoder) encoderWithHe
 
 	for topic, deleteRecordRequestTopic := range req.Topics {
 		partitions := make(map[int32]*DeleteRecordsResponsePartition)
-		for partition := range deleteRecordRequestTopic.PartitionOffsets {
-			partitions[partition] = &DeleteRecordsResponsePartition{Err: ErrNoError}
+		for partition, offset := range deleteRecordRequestTopic.PartitionOffsets {
+			partitions[partition] = &DeleteRecordsResponsePartition{LowWatermark: offset, Err: ErrNoError}
 		}
 		res.Topics[topic] = &DeleteRecordsResponseTopic{Partitions: partitions}
 	}
@@ -774,6 +936,8 @@ func (mr *MockDescribeConfigsResponse) For(reqBody versionedDecoder) encoderWith
 
 	includeSynonyms := req.Version > 0
 	includeSource := req.Version > 0
+	includeType := req.Version >= 3
+	includeDocumentation := includeType && req.IncludeDocumentation
 
 	for _, r := range req.Resources {
 		var configEntries []*ConfigEntry
@@ -823,6 +987,12 @@ func (mr *MockDescribeConfigsResponse) For(reqBody versionedDecoder) encoderWith
 					},
 				}
 			}
+			if includeType {
+				maxMessageBytes.Type = ConfigTypeInt
+			}
+			if includeDocumentation {
+				maxMessageBytes.Documentation = "The largest record batch size allowed by Kafka"
+			}
 			retentionMs := &ConfigEntry{
 				Name:      "retention.ms",
 				Value:     "5000",
@@ -838,6 +1008,9 @@ func (mr *MockDescribeConfigsResponse) For(reqBody versionedDecoder) encoderWith
 					},
 				}
 			}
+			if includeType {
+				retentionMs.Type = ConfigTypeLong
+			}
 			password := &ConfigEntry{
 				Name:      "password",
 				Value:     "12345",
@@ -845,6 +1018,9 @@ func (mr *MockDescribeConfigsResponse) For(reqBody versionedDecoder) encoderWith
 				Default:   false,
 				Sensitive: true,
 			}
+			if includeType {
+				password.Type = ConfigTypePassword
+			}
 			configEntries = append(
 				configEntries, maxMessageBytes, retentionMs, password)
 			res.Resources = append(res.Resources, &ResourceResponse{
@@ -926,24 +1102,83 @@ func (mr *MockAlterConfigsResponseWithErrorCode) For(reqBody versionedDecoder) e
 	return res
 }
 
-type MockCreateAclsResponse struct {
+type MockIncrementalAlterConfigsResponse struct {
+	t TestReporter
+}
+
+func NewMockIncrementalAlterConfigsResponse(t TestReporter) *MockIncrementalAlterConfigsResponse {
+	return &MockIncrementalAlterConfigsResponse{t: t}
+}
+
+func (mr *MockIncrementalAlterConfigsResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*IncrementalAlterConfigsRequest)
+	res := &IncrementalAlterConfigsResponse{}
+
+	for _, r := range req.Resources {
+		res.Resources = append(res.Resources, &AlterConfigsResourceResponse{
+			Name:     r.Name,
+			Type:     r.Type,
+			ErrorMsg: "",
+		})
+	}
+	return res
+}
+
+type MockIncrementalAlterConfigsResponseWithErrorCode struct {
 	t TestReporter
 }
 
+func NewMockIncrementalAlterConfigsResponseWithErrorCode(t TestReporter) *MockIncrementalAlterConfigsResponseWithErrorCode {
+	return &MockIncrementalAlterConfigsResponseWithErrorCode{t: t}
+}
+
+func (mr *MockIncrementalAlterConfigsResponseWithErrorCode) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*IncrementalAlterConfigsRequest)
+	res := &IncrementalAlterConfigsResponse{}
+
+	for _, r := range req.Resources {
+		res.Resources = append(res.Resources, &AlterConfigsResourceResponse{
+			Name:      r.Name,
+			Type:      r.Type,
+			ErrorCode: 83,
+			ErrorMsg:  "",
+		})
+	}
+	return res
+}
+
+type MockCreateAclsResponse struct {
+	t      TestReporter
+	errors map[string]KError
+}
+
 func NewMockCreateAclsResponse(t TestReporter) *MockCreateAclsResponse {
-	return &MockCreateAclsResponse{t: t}
+	return &MockCreateAclsResponse{t: t, errors: make(map[string]KError)}
 }
 
 func (mr *MockCreateAclsResponse) For(reqBody versionedDecoder) encoderWithHeader {
 	req := reqBody.(*CreateAclsRequest)
 	res := &CreateAclsResponse{}
 
-	for range req.AclCreations {
-		res.AclCreationResponses = append(res.AclCreationResponses, &AclCreationResponse{Err: ErrNoError})
+	for _, creation := range req.AclCreations {
+		response := &AclCreationResponse{Err: ErrNoError}
+		if kerr, ok := mr.errors[creation.ResourceName]; ok {
+			msg := "mock error"
+			response.Err = kerr
+			response.ErrMsg = &msg
+		}
+		res.AclCreationResponses = append(res.AclCreationResponses, response)
 	}
 	return res
 }
 
+// SetError sets the error returned for the creation of the ACLs bound to the
+// resource with the given name.
+func (mr *MockCreateAclsResponse) SetError(resourceName string, kerr KError) *MockCreateAclsResponse {
+	mr.errors[resourceName] = kerr
+	return mr
+}
+
 type MockListAclsResponse struct {
 	t TestReporter
 }
@@ -985,9 +1220,10 @@ func (mr *MockListAclsResponse) For(reqBody versionedDecoder) encoderWithHeader
 }
 
 type MockSaslAuthenticateResponse struct {
-	t             TestReporter
-	kerror        KError
-	saslAuthBytes []byte
+	t                 TestReporter
+	kerror            KError
+	saslAuthBytes     []byte
+	sessionLifetimeMs int64
 }
 
 func NewMockSaslAuthenticateResponse(t TestReporter) *MockSaslAuthenticateResponse {
@@ -995,9 +1231,12 @@ func NewMockSaslAuthenticateResponse(t TestReporter) *MockSaslAuthenticateRespon
 }
 
 func (msar *MockSaslAuthenticateResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*SaslAuthenticateRequest)
 	res := &SaslAuthenticateResponse{}
+	res.Version = req.Version
 	res.Err = msar.kerror
 	res.SaslAuthBytes = msar.saslAuthBytes
+	res.SessionLifetimeMs = msar.sessionLifetimeMs
 	return res
 }
 
@@ -1011,6 +1250,11 @@ func (msar *MockSaslAuthenticateResponse) SetAuthBytes(saslAuthBytes []byte) *Mo
 	return msar
 }
 
+func (msar *MockSaslAuthenticateResponse) SetSessionLifetimeMs(sessionLifetimeMs int64) *MockSaslAuthenticateResponse {
+	msar.sessionLifetimeMs = sessionLifetimeMs
+	return msar
+}
+
 type MockDeleteAclsResponse struct {
 	t TestReporter
 }
@@ -1083,30 +1327,29 @@ func (m *MockDeleteGroupsResponse) For(reqBody versionedDecoder) encoderWithHead
 }
 
 type MockDeleteOffsetResponse struct {
-	errorCode      KError
-	topic          string
-	partition      int32
-	errorPartition KError
+	errorCode KError
+	errors    map[string]map[int32]KError
 }
 
 func NewMockDeleteOffsetRequest(t TestReporter) *MockDeleteOffsetResponse {
-	return &MockDeleteOffsetResponse{}
+	return &MockDeleteOffsetResponse{errors: make(map[string]map[int32]KError)}
 }
 
+// SetDeletedOffset sets the top level error code and the error of the given
+// partition, it can be called once per deleted partition.
 func (m *MockDeleteOffsetResponse) SetDeletedOffset(errorCode KError, topic string, partition int32, errorPartition KError) *MockDeleteOffsetResponse {
 	m.errorCode = errorCode
-	m.topic = topic
-	m.partition = partition
-	m.errorPartition = errorPartition
+	if m.errors[topic] == nil {
+		m.errors[topic] = make(map[int32]KError)
+	}
+	m.errors[topic][partition] = errorPartition
 	return m
 }
 
 func (m *MockDeleteOffsetResponse) For(reqBody versionedDecoder) encoderWithHeader {
 	resp := &DeleteOffsetsResponse{
 		ErrorCode: m.errorCode,
-		Errors: map[string]map[int32]KError{
-			m.topic: {m.partition: m.errorPartition},
-		},
+		Errors:    m.errors,
 	}
 	return resp
 }
@@ -1187,7 +1430,8 @@ func (m *MockJoinGroupResponse) SetMember(id string, meta *ConsumerGroupMemberMe
 type MockLeaveGroupResponse struct {
 	t TestReporter
 
-	Err KError
+	Err        KError
+	MemberErrs map[string]KError
 }
 
 func NewMockLeaveGroupResponse(t TestReporter) *MockLeaveGroupResponse {
@@ -1195,8 +1439,28 @@ func NewMockLeaveGroupResponse(t TestReporter) *MockLeaveGroupResponse {
 }
 
 func (m *MockLeaveGroupResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*LeaveGroupRequest)
 	resp := &LeaveGroupResponse{
-		Err: m.Err,
+		Version: req.Version,
+		Err:     m.Err,
+	}
+	if req.Version >= 3 {
+		resp.Members = make([]MemberResponse, 0, len(req.Members))
+		for _, member := range req.Members {
+			id := member.MemberId
+			if member.GroupInstanceId != nil {
+				id = *member.GroupInstanceId
+			}
+			kerr, ok := m.MemberErrs[id]
+			if !ok {
+				kerr = ErrNoError
+			}
+			resp.Members = append(resp.Members, MemberResponse{
+				MemberId:        member.MemberId,
+				GroupInstanceId: member.GroupInstanceId,
+				Err:             kerr,
+			})
+		}
 	}
 	return resp
 }
@@ -1206,6 +1470,16 @@ func (m *MockLeaveGroupResponse) SetError(kerr KError) *MockLeaveGroupResponse {
 	return m
 }
 
+// SetMemberError sets the error returned for the member with the given group
+// instance ID, or member ID for dynamic members.
+func (m *MockLeaveGroupResponse) SetMemberError(id string, kerr KError) *MockLeaveGroupResponse {
+	if m.MemberErrs == nil {
+		m.MemberErrs = make(map[string]KError)
+	}
+	m.MemberErrs[id] = kerr
+	return m
+}
+
 type MockSyncGroupResponse struct {
 	t TestReporter
 
@@ -1250,7 +1524,7 @@ func NewMockHeartbeatResponse(t TestReporter) *MockHeartbeatResponse {
 }
 
 func (m *MockHeartbeatResponse) For(reqBody versionedDecoder) encoderWithHeader {
-	resp := &HeartbeatResponse{}
+	resp := &HeartbeatResponse{Err: m.Err}
 	return resp
 }
 
@@ -1300,3 +1574,844 @@ func (m *MockDescribeLogDirsResponse) For(reqBody versionedDecoder) encoderWithH
 	}
 	return resp
 }
+
+type MockDescribeClientQuotasResponse struct {
+	t       TestReporter
+	entries []DescribeClientQuotasEntry
+}
+
+func NewMockDescribeClientQuotasResponse(t TestReporter) *MockDescribeClientQuotasResponse {
+	return &MockDescribeClientQuotasResponse{t: t}
+}
+
+func (m *MockDescribeClientQuotasResponse) AddEntry(entity []QuotaEntityComponent, values map[string]float64) *MockDescribeClientQuotasResponse {
+	m.entries = append(m.entries, DescribeClientQuotasEntry{Entity: entity, Values: values})
+	return m
+}
+
+func (m *MockDescribeClientQuotasResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	return &DescribeClientQuotasResponse{
+		ErrorCode: ErrNoError,
+		Entries:   m.entries,
+	}
+}
+
+type MockAlterClientQuotasResponse struct {
+	t TestReporter
+}
+
+func NewMockAlterClientQuotasResponse(t TestReporter) *MockAlterClientQuotasResponse {
+	return &MockAlterClientQuotasResponse{t: t}
+}
+
+func (m *MockAlterClientQuotasResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*AlterClientQuotasRequest)
+	res := &AlterClientQuotasResponse{}
+	for _, entry := range req.Entries {
+		res.Entries = append(res.Entries, AlterClientQuotasEntryResponse{
+			ErrorCode: ErrNoError,
+			Entity:    entry.Entity,
+		})
+	}
+	return res
+}
+
+type MockDescribeUserScramCredentialsResponse struct {
+	t           TestReporter
+	credentials map[string][]*UserScramCredentialsResponseInfo
+}
+
+func NewMockDescribeUserScramCredentialsResponse(t TestReporter) *MockDescribeUserScramCredentialsResponse {
+	return &MockDescribeUserScramCredentialsResponse{t: t, credentials: make(map[string][]*UserScramCredentialsResponseInfo)}
+}
+
+func (m *MockDescribeUserScramCredentialsResponse) SetCredential(user string, mechanism ScramMechanismType, iterations int32) *MockDescribeUserScramCredentialsResponse {
+	m.credentials[user] = append(m.credentials[user], &UserScramCredentialsResponseInfo{
+		Mechanism:  mechanism,
+		Iterations: iterations,
+	})
+	return m
+}
+
+func (m *MockDescribeUserScramCredentialsResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*DescribeUserScramCredentialsRequest)
+	res := &DescribeUserScramCredentialsResponse{}
+	for _, user := range req.DescribeUsers {
+		result := &DescribeUserScramCredentialsResult{User: user.Name}
+		if infos, ok := m.credentials[user.Name]; ok {
+			result.CredentialInfos = infos
+		} else {
+			result.ErrorCode = ErrResourceNotFound
+		}
+		res.Results = append(res.Results, result)
+	}
+	return res
+}
+
+type MockAlterUserScramCredentialsResponse struct {
+	t TestReporter
+}
+
+func NewMockAlterUserScramCredentialsResponse(t TestReporter) *MockAlterUserScramCredentialsResponse {
+	return &MockAlterUserScramCredentialsResponse{t: t}
+}
+
+func (m *MockAlterUserScramCredentialsResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*AlterUserScramCredentialsRequest)
+	res := &AlterUserScramCredentialsResponse{}
+	for _, d := range req.Deletions {
+		res.Results = append(res.Results, &AlterUserScramCredentialsResult{User: d.Name})
+	}
+	for _, u := range req.Upsertions {
+		result := &AlterUserScramCredentialsResult{User: u.Name}
+		// mirror the validation of the brokers
+		if u.Iterations < scramMinIterations || len(u.Salt) == 0 {
+			result.ErrorCode = ErrUnacceptableCredential
+		}
+		res.Results = append(res.Results, result)
+	}
+	return res
+}
+
+// MockDelegationTokenResponse is a mock response builder for the delegation
+// token requests, keeping track of the tokens created, renewed and expired.
+type MockDelegationTokenResponse struct {
+	t      TestReporter
+	owner  KafkaPrincipal
+	tokens []*DelegationToken
+}
+
+func NewMockDelegationTokenResponse(t TestReporter, owner KafkaPrincipal) *MockDelegationTokenResponse {
+	return &MockDelegationTokenResponse{t: t, owner: owner}
+}
+
+func (m *MockDelegationTokenResponse) token(hmac []byte) *DelegationToken {
+	for _, token := range m.tokens {
+		if string(token.HMAC) == string(hmac) {
+			return token
+		}
+	}
+	return nil
+}
+
+func (m *MockDelegationTokenResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	now := time.Now().Truncate(time.Millisecond)
+	switch req := reqBody.(type) {
+	case *CreateDelegationTokenRequest:
+		maxLifetime := req.MaxLifetime
+		if maxLifetime < 0 {
+			maxLifetime = 7 * 24 * time.Hour
+		}
+		token := &DelegationToken{
+			Owner:      m.owner,
+			Renewers:   req.Renewers,
+			IssueTime:  now,
+			ExpiryTime: now.Add(24 * time.Hour),
+			MaxTime:    now.Add(maxLifetime),
+			TokenID:    fmt.Sprintf("token-%d", len(m.tokens)),
+			HMAC:       []byte(fmt.Sprintf("hmac-%d", len(m.tokens))),
+		}
+		m.tokens = append(m.tokens, token)
+		return &CreateDelegationTokenResponse{
+			Version:    req.Version,
+			Owner:      token.Owner,
+			IssueTime:  token.IssueTime,
+			ExpiryTime: token.ExpiryTime,
+			MaxTime:    token.MaxTime,
+			TokenID:    token.TokenID,
+			HMAC:       token.HMAC,
+		}
+	case *RenewDelegationTokenRequest:
+		token := m.token(req.HMAC)
+		if token == nil {
+			return &RenewDelegationTokenResponse{Version: req.Version, Err: ErrDelegationTokenNotFound}
+		}
+		renewPeriod := req.RenewPeriod
+		if renewPeriod < 0 {
+			renewPeriod = 24 * time.Hour
+		}
+		token.ExpiryTime = now.Add(renewPeriod)
+		if token.ExpiryTime.After(token.MaxTime) {
+			token.ExpiryTime = token.MaxTime
+		}
+		return &RenewDelegationTokenResponse{Version: req.Version, ExpiryTime: token.ExpiryTime}
+	case *ExpireDelegationTokenRequest:
+		token := m.token(req.HMAC)
+		if token == nil {
+			return &ExpireDelegationTokenResponse{Version: req.Version, Err: ErrDelegationTokenNotFound}
+		}
+		if req.ExpiryPeriod < 0 {
+			token.ExpiryTime = now
+		} else {
+			token.ExpiryTime = now.Add(req.ExpiryPeriod)
+		}
+		return &ExpireDelegationTokenResponse{Version: req.Version, ExpiryTime: token.ExpiryTime}
+	case *DescribeDelegationTokenRequest:
+		res := &DescribeDelegationTokenResponse{Version: req.Version, Tokens: []*DelegationToken{}}
+		for _, token := range m.tokens {
+			if !token.ExpiryTime.After(now) {
+				continue
+			}
+			if req.Owners != nil && !kafkaPrincipalsContain(req.Owners, token.Owner) {
+				continue
+			}
+			res.Tokens = append(res.Tokens, token)
+		}
+		return res
+	}
+	m.t.Errorf("unexpected request %T", reqBody)
+	return nil
+}
+
+func kafkaPrincipalsContain(principals []KafkaPrincipal, principal KafkaPrincipal) bool {
+	for _, p := range principals {
+		if p == principal {
+			return true
+		}
+	}
+	return false
+}
+
+// MockDescribeProducersResponse is a `DescribeProducersResponse` builder.
+type MockDescribeProducersResponse struct {
+	t         TestReporter
+	producers map[string]map[int32][]ProducerState
+}
+
+func NewMockDescribeProducersResponse(t TestReporter) *MockDescribeProducersResponse {
+	return &MockDescribeProducersResponse{t: t, producers: make(map[string]map[int32][]ProducerState)}
+}
+
+// AddProducer adds an active producer to a partition. Partitions the mock
+// knows nothing about are reported with ErrUnknownTopicOrPartition.
+func (m *MockDescribeProducersResponse) AddProducer(topic string, partition int32, producer ProducerState) *MockDescribeProducersResponse {
+	if m.producers[topic] == nil {
+		m.producers[topic] = make(map[int32][]ProducerState)
+	}
+	m.producers[topic][partition] = append(m.producers[topic][partition], producer)
+	return m
+}
+
+func (m *MockDescribeProducersResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*DescribeProducersRequest)
+	res := &DescribeProducersResponse{Version: req.Version}
+	for _, topic := range req.Topics {
+		rspTopic := DescribeProducersResponseTopic{Name: topic.Name}
+		for _, partition := range topic.PartitionIndexes {
+			rspPartition := DescribeProducersResponsePartition{PartitionIndex: partition}
+			producers, ok := m.producers[topic.Name][partition]
+			if !ok {
+				rspPartition.ErrorCode = ErrUnknownTopicOrPartition
+			}
+			rspPartition.ActiveProducers = append([]ProducerState{}, producers...)
+			rspTopic.Partitions = append(rspTopic.Partitions, rspPartition)
+		}
+		res.Topics = append(res.Topics, rspTopic)
+	}
+	return res
+}
+
+// MockListTransactionsResponse is a `ListTransactionsResponse` builder.
+type MockListTransactionsResponse struct {
+	t            TestReporter
+	transactions []ListTransactionsResponseState
+}
+
+func NewMockListTransactionsResponse(t TestReporter) *MockListTransactionsResponse {
+	return &MockListTransactionsResponse{t: t}
+}
+
+func (m *MockListTransactionsResponse) AddTransaction(transactionalID string, producerID int64, state string) *MockListTransactionsResponse {
+	m.transactions = append(m.transactions, ListTransactionsResponseState{
+		TransactionalID:  transactionalID,
+		ProducerID:       producerID,
+		TransactionState: state,
+	})
+	return m
+}
+
+func (m *MockListTransactionsResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*ListTransactionsRequest)
+	res := &ListTransactionsResponse{Version: req.Version, UnknownStateFilters: []string{}}
+	states := make(map[string]none, len(req.StateFilters))
+	for _, state := range req.StateFilters {
+		states[state] = none{}
+	}
+	producerIDs := make(map[int64]none, len(req.ProducerIDFilters))
+	for _, producerID := range req.ProducerIDFilters {
+		producerIDs[producerID] = none{}
+	}
+	for _, transaction := range m.transactions {
+		if _, ok := states[transaction.TransactionState]; len(states) > 0 && !ok {
+			continue
+		}
+		if _, ok := producerIDs[transaction.ProducerID]; len(producerIDs) > 0 && !ok {
+			continue
+		}
+		res.TransactionStates = append(res.TransactionStates, transaction)
+	}
+	return res
+}
+
+// MockDescribeTransactionsResponse is a `DescribeTransactionsResponse` builder.
+type MockDescribeTransactionsResponse struct {
+	t            TestReporter
+	transactions map[string]*TransactionDescription
+}
+
+func NewMockDescribeTransactionsResponse(t TestReporter) *MockDescribeTransactionsResponse {
+	return &MockDescribeTransactionsResponse{t: t, transactions: make(map[string]*TransactionDescription)}
+}
+
+// AddTransaction adds a transaction to describe. Transactions the mock knows
+// nothing about are reported with ErrTransactionalIDNotFound.
+func (m *MockDescribeTransactionsResponse) AddTransaction(transaction *TransactionDescription) *MockDescribeTransactionsResponse {
+	m.transactions[transaction.TransactionalID] = transaction
+	return m
+}
+
+func (m *MockDescribeTransactionsResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*DescribeTransactionsRequest)
+	res := &DescribeTransactionsResponse{Version: req.Version}
+	for _, transactionalID := range req.TransactionalIDs {
+		transaction, ok := m.transactions[transactionalID]
+		if !ok {
+			transaction = &TransactionDescription{
+				Err:                    ErrTransactionalIDNotFound,
+				TransactionalID:        transactionalID,
+				TransactionStartTimeMs: -1,
+				ProducerID:             -1,
+				ProducerEpoch:          -1,
+			}
+		}
+		res.TransactionStates = append(res.TransactionStates, transaction)
+	}
+	return res
+}
+
+// MockWriteTxnMarkersResponse is a `WriteTxnMarkersResponse` builder.
+type MockWriteTxnMarkersResponse struct {
+	t      TestReporter
+	errors map[string]map[int32]KError
+}
+
+func NewMockWriteTxnMarkersResponse(t TestReporter) *MockWriteTxnMarkersResponse {
+	return &MockWriteTxnMarkersResponse{t: t, errors: make(map[string]map[int32]KError)}
+}
+
+func (m *MockWriteTxnMarkersResponse) SetError(topic string, partition int32, kerror KError) *MockWriteTxnMarkersResponse {
+	if m.errors[topic] == nil {
+		m.errors[topic] = make(map[int32]KError)
+	}
+	m.errors[topic][partition] = kerror
+	return m
+}
+
+func (m *MockWriteTxnMarkersResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*WriteTxnMarkersRequest)
+	res := &WriteTxnMarkersResponse{Version: req.Version}
+	for _, marker := range req.Markers {
+		result := WritableTxnMarkerResult{ProducerID: marker.ProducerID}
+		for _, topic := range marker.Topics {
+			topicResult := WritableTxnMarkerTopicResult{Name: topic.Name}
+			for _, partition := range topic.PartitionIndexes {
+				topicResult.Partitions = append(topicResult.Partitions, WritableTxnMarkerPartitionResult{
+					PartitionIndex: partition,
+					ErrorCode:      m.errors[topic.Name][partition],
+				})
+			}
+			result.Topics = append(result.Topics, topicResult)
+		}
+		res.Markers = append(res.Markers, result)
+	}
+	return res
+}
+
+// MockApiVersionsResponse is an `ApiVersionsResponse` builder.
+type MockApiVersionsResponse struct {
+	t                      TestReporter
+	apiVersions            []*ApiVersionsResponseBlock
+	supportedFeatures      []SupportedFeatureKey
+	finalizedFeatures      []FinalizedFeatureKey
+	finalizedFeaturesEpoch int64
+}
+
+func NewMockApiVersionsResponse(t TestReporter) *MockApiVersionsResponse {
+	return &MockApiVersionsResponse{t: t, finalizedFeaturesEpoch: -1}
+}
+
+func (m *MockApiVersionsResponse) SetApiVersions(apiVersions []*ApiVersionsResponseBlock) *MockApiVersionsResponse {
+	m.apiVersions = apiVersions
+	return m
+}
+
+func (m *MockApiVersionsResponse) SetSupportedFeature(name string, minVersion, maxVersion int16) *MockApiVersionsResponse {
+	m.supportedFeatures = append(m.supportedFeatures, SupportedFeatureKey{Name: name, MinVersion: minVersion, MaxVersion: maxVersion})
+	return m
+}
+
+func (m *MockApiVersionsResponse) SetFinalizedFeature(name string, minVersionLevel, maxVersionLevel int16, epoch int64) *MockApiVersionsResponse {
+	m.finalizedFeatures = append(m.finalizedFeatures, FinalizedFeatureKey{Name: name, MinVersionLevel: minVersionLevel, MaxVersionLevel: maxVersionLevel})
+	m.finalizedFeaturesEpoch = epoch
+	return m
+}
+
+func (m *MockApiVersionsResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*ApiVersionsRequest)
+	res := &ApiVersionsResponse{
+		Version:                req.Version,
+		ApiVersions:            m.apiVersions,
+		FinalizedFeaturesEpoch: -1,
+	}
+	if req.Version >= 3 {
+		res.SupportedFeatures = m.supportedFeatures
+		res.FinalizedFeatures = m.finalizedFeatures
+		res.FinalizedFeaturesEpoch = m.finalizedFeaturesEpoch
+	}
+	return res
+}
+
+// MockUpdateFeaturesResponse is an `UpdateFeaturesResponse` builder.
+type MockUpdateFeaturesResponse struct {
+	t      TestReporter
+	errors map[string]KError
+}
+
+func NewMockUpdateFeaturesResponse(t TestReporter) *MockUpdateFeaturesResponse {
+	return &MockUpdateFeaturesResponse{t: t, errors: make(map[string]KError)}
+}
+
+func (m *MockUpdateFeaturesResponse) SetError(feature string, kerror KError) *MockUpdateFeaturesResponse {
+	m.errors[feature] = kerror
+	return m
+}
+
+func (m *MockUpdateFeaturesResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*UpdateFeaturesRequest)
+	res := &UpdateFeaturesResponse{Version: req.Version}
+	for _, update := range req.FeatureUpdates {
+		res.Results = append(res.Results, UpdatableFeatureResult{
+			Feature:   update.Feature,
+			ErrorCode: m.errors[update.Feature],
+		})
+	}
+	return res
+}
+
+// MockDescribeQuorumResponse is a `DescribeQuorumResponse` builder.
+type MockDescribeQuorumResponse struct {
+	t         TestReporter
+	partition DescribeQuorumResponsePartition
+}
+
+func NewMockDescribeQuorumResponse(t TestReporter) *MockDescribeQuorumResponse {
+	return &MockDescribeQuorumResponse{t: t}
+}
+
+func (m *MockDescribeQuorumResponse) SetLeader(leaderID, leaderEpoch int32, highWatermark int64) *MockDescribeQuorumResponse {
+	m.partition.LeaderID = leaderID
+	m.partition.LeaderEpoch = leaderEpoch
+	m.partition.HighWatermark = highWatermark
+	return m
+}
+
+func (m *MockDescribeQuorumResponse) AddVoter(replicaID int32, logEndOffset int64) *MockDescribeQuorumResponse {
+	m.partition.CurrentVoters = append(m.partition.CurrentVoters, ReplicaState{
+		ReplicaID:             replicaID,
+		LogEndOffset:          logEndOffset,
+		LastFetchTimestamp:    -1,
+		LastCaughtUpTimestamp: -1,
+	})
+	return m
+}
+
+func (m *MockDescribeQuorumResponse) AddObserver(replicaID int32, logEndOffset int64) *MockDescribeQuorumResponse {
+	m.partition.Observers = append(m.partition.Observers, ReplicaState{
+		ReplicaID:             replicaID,
+		LogEndOffset:          logEndOffset,
+		LastFetchTimestamp:    -1,
+		LastCaughtUpTimestamp: -1,
+	})
+	return m
+}
+
+func (m *MockDescribeQuorumResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*DescribeQuorumRequest)
+	res := &DescribeQuorumResponse{Version: req.Version}
+	for _, topic := range req.Topics {
+		rspTopic := DescribeQuorumResponseTopic{TopicName: topic.TopicName}
+		for _, partition := range topic.Partitions {
+			rspPartition := m.partition
+			rspPartition.PartitionIndex = partition
+			if topic.TopicName != kraftMetadataTopic || partition != 0 {
+				rspPartition = DescribeQuorumResponsePartition{PartitionIndex: partition, ErrorCode: ErrUnknownTopicOrPartition}
+			}
+			rspTopic.Partitions = append(rspTopic.Partitions, rspPartition)
+		}
+		res.Topics = append(res.Topics, rspTopic)
+	}
+	return res
+}
+
+// MockUnregisterBrokerResponse is an `UnregisterBrokerResponse` builder.
+type MockUnregisterBrokerResponse struct {
+	t       TestReporter
+	brokers map[int32]none
+}
+
+// NewMockUnregisterBrokerResponse returns a mock unregistering the given
+// brokers, other brokers being reported with ErrBrokerIDNotRegistered.
+func NewMockUnregisterBrokerResponse(t TestReporter, brokerIDs ...int32) *MockUnregisterBrokerResponse {
+	brokers := make(map[int32]none, len(brokerIDs))
+	for _, id := range brokerIDs {
+		brokers[id] = none{}
+	}
+	return &MockUnregisterBrokerResponse{t: t, brokers: brokers}
+}
+
+func (m *MockUnregisterBrokerResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*UnregisterBrokerRequest)
+	res := &UnregisterBrokerResponse{Version: req.Version}
+	if _, ok := m.brokers[req.BrokerID]; ok {
+		delete(m.brokers, req.BrokerID)
+	} else {
+		msg := fmt.Sprintf("Broker ID %d is not currently registered", req.BrokerID)
+		res.ErrorCode = ErrBrokerIDNotRegistered
+		res.ErrorMessage = &msg
+	}
+	return res
+}
+
+// MockGetTelemetrySubscriptionsResponse is a `GetTelemetrySubscriptionsResponse` builder.
+type MockGetTelemetrySubscriptionsResponse struct {
+	t   TestReporter
+	res GetTelemetrySubscriptionsResponse
+}
+
+// NewMockGetTelemetrySubscriptionsResponse returns a mock requesting no
+// metrics, generating the same client instance ID for all the clients.
+func NewMockGetTelemetrySubscriptionsResponse(t TestReporter) *MockGetTelemetrySubscriptionsResponse {
+	return &MockGetTelemetrySubscriptionsResponse{t: t, res: GetTelemetrySubscriptionsResponse{
+		ClientInstanceId: Uuid{15: 1},
+	}}
+}
+
+func (m *MockGetTelemetrySubscriptionsResponse) SetClientInstanceID(id Uuid) *MockGetTelemetrySubscriptionsResponse {
+	m.res.ClientInstanceId = id
+	return m
+}
+
+// SetSubscription requests the metrics whose names start with one of
+// requestedMetrics every pushInterval, all of them with an empty prefix.
+func (m *MockGetTelemetrySubscriptionsResponse) SetSubscription(subscriptionID int32, pushInterval time.Duration, requestedMetrics ...string) *MockGetTelemetrySubscriptionsResponse {
+	m.res.SubscriptionId = subscriptionID
+	m.res.PushIntervalMs = int32(pushInterval / time.Millisecond)
+	m.res.RequestedMetrics = requestedMetrics
+	return m
+}
+
+func (m *MockGetTelemetrySubscriptionsResponse) SetCompressionTypes(codecs ...CompressionCodec) *MockGetTelemetrySubscriptionsResponse {
+	m.res.AcceptedCompressionTypes = codecs
+	return m
+}
+
+func (m *MockGetTelemetrySubscriptionsResponse) SetDeltaTemporality(delta bool) *MockGetTelemetrySubscriptionsResponse {
+	m.res.DeltaTemporality = delta
+	return m
+}
+
+func (m *MockGetTelemetrySubscriptionsResponse) SetTelemetryMaxBytes(maxBytes int32) *MockGetTelemetrySubscriptionsResponse {
+	m.res.TelemetryMaxBytes = maxBytes
+	return m
+}
+
+func (m *MockGetTelemetrySubscriptionsResponse) SetError(kerr KError) *MockGetTelemetrySubscriptionsResponse {
+	m.res.Err = kerr
+	return m
+}
+
+func (m *MockGetTelemetrySubscriptionsResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*GetTelemetrySubscriptionsRequest)
+	res := m.res
+	res.Version = req.Version
+	if req.ClientInstanceId != (Uuid{}) {
+		res.ClientInstanceId = req.ClientInstanceId
+	}
+	return &res
+}
+
+// MockPushTelemetryResponse is a `PushTelemetryResponse` builder.
+type MockPushTelemetryResponse struct {
+	t   TestReporter
+	err KError
+}
+
+func NewMockPushTelemetryResponse(t TestReporter) *MockPushTelemetryResponse {
+	return &MockPushTelemetryResponse{t: t}
+}
+
+func (m *MockPushTelemetryResponse) SetError(kerr KError) *MockPushTelemetryResponse {
+	m.err = kerr
+	return m
+}
+
+func (m *MockPushTelemetryResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*PushTelemetryRequest)
+	return &PushTelemetryResponse{Version: req.Version, Err: m.err}
+}
+
+// MockTransactionState is the state of a transactional ID tracked by
+// MockTransactionCoordinator, named after the states of the Kafka
+// transaction coordinator: Empty, Ongoing, CompleteCommit or CompleteAbort.
+type MockTransactionState struct {
+	ProducerID    int64
+	ProducerEpoch int16
+	State         string
+	// Partitions and Groups are the partitions and consumer groups added to
+	// the ongoing transaction
+	Partitions map[string][]int32
+	Groups     []string
+	// Commits and Aborts count the transactions ended so far
+	Commits int
+	Aborts  int
+}
+
+type mockTransaction struct {
+	MockTransactionState
+	// offsets are the offsets committed in the ongoing transaction, by group
+	offsets map[string]map[string]map[int32]int64
+}
+
+// MockTransactionCoordinator is a builder of the responses of a transaction
+// coordinator, tracking the state of the transactions across the
+// `InitProducerIDRequest`, `AddPartitionsToTxnRequest`,
+// `AddOffsetsToTxnRequest`, `TxnOffsetCommitRequest` and `EndTxnRequest` it
+// answers, which should all be mapped to it. The requests not following the
+// protocol, such as adding partitions with a fenced epoch or ending a
+// transaction which is not ongoing, fail the way they would on a broker.
+type MockTransactionCoordinator struct {
+	t              TestReporter
+	lock           sync.Mutex
+	nextProducerID int64
+	transactions   map[string]*mockTransaction
+	committed      map[string]map[string]map[int32]int64
+	errors         map[string]KError
+}
+
+func NewMockTransactionCoordinator(t TestReporter) *MockTransactionCoordinator {
+	return &MockTransactionCoordinator{
+		t:              t,
+		nextProducerID: 1000,
+		transactions:   make(map[string]*mockTransaction),
+		committed:      make(map[string]map[string]map[int32]int64),
+		errors:         make(map[string]KError),
+	}
+}
+
+// SetError makes the coordinator answer the requests of the given type, such
+// as "EndTxnRequest", with kerror without changing the state of the
+// transactions. ErrNoError removes the error.
+func (m *MockTransactionCoordinator) SetError(request string, kerror KError) *MockTransactionCoordinator {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if kerror == ErrNoError {
+		delete(m.errors, request)
+	} else {
+		m.errors[request] = kerror
+	}
+	return m
+}
+
+// Transaction returns the state of the transactional ID, and whether a
+// producer ID was initialized for it.
+func (m *MockTransactionCoordinator) Transaction(transactionalID string) (MockTransactionState, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	txn, ok := m.transactions[transactionalID]
+	if !ok {
+		return MockTransactionState{}, false
+	}
+	state := txn.MockTransactionState
+	state.Partitions = make(map[string][]int32, len(txn.Partitions))
+	for topic, partitions := range txn.Partitions {
+		state.Partitions[topic] = append([]int32(nil), partitions...)
+	}
+	state.Groups = append([]string(nil), txn.Groups...)
+	return state, true
+}
+
+// CommittedOffsets returns the offsets of the group committed by the
+// transactions, by topic and partition.
+func (m *MockTransactionCoordinator) CommittedOffsets(group string) map[string]map[int32]int64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	offsets := make(map[string]map[int32]int64)
+	for topic, partitions := range m.committed[group] {
+		offsets[topic] = make(map[int32]int64, len(partitions))
+		for partition, offset := range partitions {
+			offsets[topic][partition] = offset
+		}
+	}
+	return offsets
+}
+
+func (m *MockTransactionCoordinator) For(reqBody versionedDecoder) encoderWithHeader {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	switch req := reqBody.(type) {
+	case *InitProducerIDRequest:
+		return m.initProducerID(req)
+	case *AddPartitionsToTxnRequest:
+		kerror := m.check("AddPartitionsToTxnRequest", req.TransactionalID, req.ProducerID, req.ProducerEpoch)
+		res := &AddPartitionsToTxnResponse{Errors: make(map[string][]*PartitionError)}
+		for topic, partitions := range req.TopicPartitions {
+			for _, partition := range partitions {
+				res.Errors[topic] = append(res.Errors[topic], &PartitionError{Partition: partition, Err: kerror})
+			}
+		}
+		if kerror == ErrNoError {
+			txn := m.begin(req.TransactionalID)
+			for topic, partitions := range req.TopicPartitions {
+				for _, partition := range partitions {
+					if !int32SliceContains(txn.Partitions[topic], partition) {
+						txn.Partitions[topic] = append(txn.Partitions[topic], partition)
+					}
+				}
+			}
+		}
+		return res
+	case *AddOffsetsToTxnRequest:
+		kerror := m.check("AddOffsetsToTxnRequest", req.TransactionalID, req.ProducerID, req.ProducerEpoch)
+		if kerror == ErrNoError {
+			txn := m.begin(req.TransactionalID)
+			if !stringSliceContains(txn.Groups, req.GroupID) {
+				txn.Groups = append(txn.Groups, req.GroupID)
+			}
+		}
+		return &AddOffsetsToTxnResponse{Err: kerror}
+	case *TxnOffsetCommitRequest:
+		kerror := m.check("TxnOffsetCommitRequest", req.TransactionalID, req.ProducerID, req.ProducerEpoch)
+		txn := m.transactions[req.TransactionalID]
+		if kerror == ErrNoError && !stringSliceContains(txn.Groups, req.GroupID) {
+			// the group must have been added with AddOffsetsToTxn first
+			kerror = ErrInvalidTxnState
+		}
+		res := &TxnOffsetCommitResponse{Topics: make(map[string][]*PartitionError)}
+		for topic, partitions := range req.Topics {
+			for _, partition := range partitions {
+				res.Topics[topic] = append(res.Topics[topic], &PartitionError{Partition: partition.Partition, Err: kerror})
+				if kerror != ErrNoError {
+					continue
+				}
+				if txn.offsets[req.GroupID] == nil {
+					txn.offsets[req.GroupID] = make(map[string]map[int32]int64)
+				}
+				if txn.offsets[req.GroupID][topic] == nil {
+					txn.offsets[req.GroupID][topic] = make(map[int32]int64)
+				}
+				txn.offsets[req.GroupID][topic][partition.Partition] = partition.Offset
+			}
+		}
+		return res
+	case *EndTxnRequest:
+		kerror := m.check("EndTxnRequest", req.TransactionalID, req.ProducerID, req.ProducerEpoch)
+		if kerror == ErrNoError && m.transactions[req.TransactionalID].State != "Ongoing" {
+			kerror = ErrInvalidTxnState
+		}
+		if kerror == ErrNoError {
+			m.end(m.transactions[req.TransactionalID], req.TransactionResult)
+		}
+		return &EndTxnResponse{Err: kerror}
+	default:
+		m.t.Errorf("MockTransactionCoordinator cannot answer %T", reqBody)
+		return nil
+	}
+}
+
+func (m *MockTransactionCoordinator) initProducerID(req *InitProducerIDRequest) encoderWithHeader {
+	if kerror, ok := m.errors["InitProducerIDRequest"]; ok {
+		return &InitProducerIDResponse{Err: kerror, ProducerID: -1, ProducerEpoch: -1}
+	}
+
+	if req.TransactionalID == nil {
+		// idempotent producers get a new producer ID every time
+		m.nextProducerID++
+		return &InitProducerIDResponse{ProducerID: m.nextProducerID - 1}
+	}
+
+	txn, ok := m.transactions[*req.TransactionalID]
+	if !ok {
+		txn = &mockTransaction{MockTransactionState: MockTransactionState{
+			ProducerID:    m.nextProducerID,
+			ProducerEpoch: -1,
+			State:         "Empty",
+		}}
+		m.nextProducerID++
+		m.transactions[*req.TransactionalID] = txn
+	}
+	// the transaction left ongoing by the fenced producer is aborted
+	if txn.State == "Ongoing" {
+		m.end(txn, false)
+	}
+	txn.ProducerEpoch++
+	return &InitProducerIDResponse{ProducerID: txn.ProducerID, ProducerEpoch: txn.ProducerEpoch}
+}
+
+// check returns the error of a request of the producer in a transaction
+func (m *MockTransactionCoordinator) check(request, transactionalID string, producerID int64, producerEpoch int16) KError {
+	if kerror, ok := m.errors[request]; ok {
+		return kerror
+	}
+	txn, ok := m.transactions[transactionalID]
+	switch {
+	case !ok || txn.ProducerID != producerID:
+		return ErrInvalidProducerIDMapping
+	case producerEpoch < txn.ProducerEpoch:
+		return ErrProducerFenced
+	case producerEpoch > txn.ProducerEpoch:
+		return ErrInvalidProducerEpoch
+	}
+	return ErrNoError
+}
+
+// begin starts the transaction if it is not ongoing yet
+func (m *MockTransactionCoordinator) begin(transactionalID string) *mockTransaction {
+	txn := m.transactions[transactionalID]
+	if txn.State != "Ongoing" {
+		txn.State = "Ongoing"
+		txn.Partitions = make(map[string][]int32)
+		txn.Groups = nil
+		txn.offsets = make(map[string]map[string]map[int32]int64)
+	}
+	return txn
+}
+
+// end commits or aborts the ongoing transaction, committing its offsets
+func (m *MockTransactionCoordinator) end(txn *mockTransaction, commit bool) {
+	if commit {
+		for group, topics := range txn.offsets {
+			if m.committed[group] == nil {
+				m.committed[group] = make(map[string]map[int32]int64)
+			}
+			for topic, partitions := range topics {
+				if m.committed[group][topic] == nil {
+					m.committed[group][topic] = make(map[int32]int64)
+				}
+				for partition, offset := range partitions {
+					m.committed[group][topic][partition] = offset
+				}
+			}
+		}
+		txn.State = "CompleteCommit"
+		txn.Commits++
+	} else {
+		txn.State = "CompleteAbort"
+		txn.Aborts++
+	}
+	txn.Partitions = nil
+	txn.Groups = nil
+	txn.offsets = nil
+}
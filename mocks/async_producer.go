@@ -21,6 +21,7 @@ type AsyncProducer struct {
 	successes    chan *sarama.ProducerMessage
 	errors       chan *sarama.ProducerError
 	lastOffset   int64
+	produced     []*sarama.ProducerMessage
 	*TopicConfig
 }
 
@@ -71,15 +72,19 @@ func NewAsyncProducer(t ErrorReporter, config *sarama.Config) *AsyncProducer {
 					mp.errors <- &sarama.ProducerError{Err: err, Msg: msg}
 				} else {
 					msg.Partition = partition
+					var errCheck error
 					if expectation.CheckFunction != nil {
-						err := expectation.CheckFunction(msg)
-						if err != nil {
-							mp.t.Errorf("Check function returned an error: %s", err.Error())
-							mp.errors <- &sarama.ProducerError{Err: err, Msg: msg}
+						errCheck = expectation.CheckFunction(msg)
+						if errCheck != nil {
+							mp.t.Errorf("Check function returned an error: %s", errCheck.Error())
+							mp.errors <- &sarama.ProducerError{Err: errCheck, Msg: msg}
 						}
 					}
 					if expectation.Result == errProduceSuccess {
 						mp.lastOffset++
+						if errCheck == nil {
+							mp.produced = append(mp.produced, msg)
+						}
 						if config.Producer.Return.Successes {
 							msg.Offset = mp.lastOffset
 							mp.successes <- msg
@@ -143,6 +148,15 @@ func (mp *AsyncProducer) Errors() <-chan *sarama.ProducerError {
 	return mp.errors
 }
 
+// Messages returns the messages the mock producer handled as produced
+// successfully so far, in the order they were received, so that a test can
+// inspect them once it is done producing.
+func (mp *AsyncProducer) Messages() []*sarama.ProducerMessage {
+	mp.l.Lock()
+	defer mp.l.Unlock()
+	return append([]*sarama.ProducerMessage(nil), mp.produced...)
+}
+
 ////////////////////////////////////////////////
 // Setting expectations
 ////////////////////////////////////////////////
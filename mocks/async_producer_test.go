@@ -172,3 +172,26 @@ func (brokePartitioner) Partition(msg *sarama.ProducerMessage, n int32) (int32,
 }
 
 func (brokePartitioner) RequiresConsistency() bool { return false }
+
+func TestProducerWithMatchersCapturesMessages(t *testing.T) {
+	trm := newTestReporterMock()
+	mp := NewAsyncProducer(trm, nil)
+	mp.ExpectInputWithMessageCheckerFunctionAndSucceed(MatchAll(MatchTopic("test"), MatchKey(Equals(nil))))
+	mp.ExpectInputWithMessageCheckerFunctionAndSucceed(MatchTopic("other"))
+	mp.ExpectInputAndFail(sarama.ErrOutOfBrokers)
+
+	first := &sarama.ProducerMessage{Topic: "test", Value: sarama.StringEncoder("first")}
+	mp.Input() <- first
+	mp.Input() <- &sarama.ProducerMessage{Topic: "test", Value: sarama.StringEncoder("second")}
+	mp.Input() <- &sarama.ProducerMessage{Topic: "test", Value: sarama.StringEncoder("third")}
+	if err := mp.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if len(trm.errors) != 1 || !strings.Contains(trm.errors[0], `Expected topic "other"`) {
+		t.Errorf("Expected to report a topic check error, got %v", trm.errors)
+	}
+	if messages := mp.Messages(); len(messages) != 1 || messages[0] != first {
+		t.Errorf("Expected only the first message to be captured, got %v", messages)
+	}
+}
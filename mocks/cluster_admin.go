@@ -0,0 +1,1151 @@
+package mocks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// AdminCallChecker is a function type to be set in the expectations of the
+// ClusterAdmin mock to check the arguments a method was called with, in the
+// order of the method signature.
+type AdminCallChecker func(args ...interface{}) error
+
+// AdminExpectation is an expected call to a method of the ClusterAdmin mock,
+// returned by ExpectCall.
+type AdminExpectation struct {
+	method string
+	check  AdminCallChecker
+	err    error
+}
+
+// WithCheck sets the function checking the arguments of the call. When it
+// returns an error, the error is reported and returned by the call.
+func (e *AdminExpectation) WithCheck(check AdminCallChecker) *AdminExpectation {
+	e.check = check
+	return e
+}
+
+// ReturnError makes the call return err, leaving the cluster unchanged.
+func (e *AdminExpectation) ReturnError(err error) *AdminExpectation {
+	e.err = err
+	return e
+}
+
+var errNotModelled = errors.New("The method is not modelled by the mock cluster admin")
+
+// ClusterAdmin implements sarama's ClusterAdmin interface for testing purposes.
+// It keeps an in-memory model of a cluster: its topics along with their
+// partitions and config overrides, the configs of its brokers, and its ACLs.
+// Topics are created, altered and deleted by the calls to the mock the way the
+// brokers would, so provisioning code can be checked against the resulting
+// state with ListTopics, DescribeConfig and ListAcls.
+//
+// Calls can also be intercepted with ExpectCall, in order to check their
+// arguments or make them fail. The expectations of a method are used by its
+// calls in the order they were set, calls without one being served by the
+// model. The methods outside of the model, such as the consumer group ones,
+// require an expectation and return zero values unless it sets an error.
+type ClusterAdmin struct {
+	l            sync.Mutex
+	t            ErrorReporter
+	brokers      []int32
+	topics       map[string]*sarama.TopicDetail
+	configs      map[sarama.ConfigResourceType]map[string]map[string]string
+	acls         []aclBinding
+	expectations map[string][]*AdminExpectation
+	closed       bool
+}
+
+type aclBinding struct {
+	resource sarama.Resource
+	acl      sarama.Acl
+}
+
+// NewClusterAdmin returns a new mock ClusterAdmin instance modelling an empty
+// cluster of a single broker with ID 0. The t argument should be the
+// *testing.T instance of your test method. An error will be written to it if
+// an expectation is violated.
+func NewClusterAdmin(t ErrorReporter) *ClusterAdmin {
+	return &ClusterAdmin{
+		t:            t,
+		brokers:      []int32{0},
+		topics:       make(map[string]*sarama.TopicDetail),
+		configs:      make(map[sarama.ConfigResourceType]map[string]map[string]string),
+		expectations: make(map[string][]*AdminExpectation),
+	}
+}
+
+// SetBrokers sets the IDs of the brokers of the cluster, over which the
+// replicas of the topics created afterwards are assigned.
+func (ca *ClusterAdmin) SetBrokers(ids ...int32) {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	ca.brokers = append([]int32(nil), ids...)
+	sort.Slice(ca.brokers, func(i, j int) bool { return ca.brokers[i] < ca.brokers[j] })
+}
+
+// ExpectCall sets an expectation on the next call to the given method of the
+// sarama.ClusterAdmin interface. It is reported on Close if it was not used.
+func (ca *ClusterAdmin) ExpectCall(method string) *AdminExpectation {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if _, ok := reflect.TypeOf((*sarama.ClusterAdmin)(nil)).Elem().MethodByName(method); !ok {
+		ca.t.Errorf("%s is not a method of the sarama.ClusterAdmin interface.", method)
+	}
+	expectation := &AdminExpectation{method: method}
+	ca.expectations[method] = append(ca.expectations[method], expectation)
+	return expectation
+}
+
+// intercept uses the next expectation of the method, if any, returning the
+// error of the call and whether an expectation was used. It must be called
+// with the lock held
+func (ca *ClusterAdmin) intercept(method string, args ...interface{}) (bool, error) {
+	expectations := ca.expectations[method]
+	if len(expectations) == 0 {
+		return false, nil
+	}
+	expectation := expectations[0]
+	ca.expectations[method] = expectations[1:]
+
+	if expectation.check != nil {
+		if err := expectation.check(args...); err != nil {
+			ca.t.Errorf("Check function of %s returned an error: %s", method, err.Error())
+			return true, err
+		}
+	}
+	return true, expectation.err
+}
+
+// unmodelled handles a call to a method outside of the model, which must be
+// expected
+func (ca *ClusterAdmin) unmodelled(method string, args ...interface{}) error {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if expected, err := ca.intercept(method, args...); expected {
+		return err
+	}
+	ca.t.Errorf("No expectation set on the mock cluster admin to handle the call to %s.", method)
+	return errNotModelled
+}
+
+func topicError(kerr sarama.KError, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	return &sarama.TopicError{Err: kerr, ErrMsg: &msg}
+}
+
+///////////////////////////////////////////////////
+// Topics
+///////////////////////////////////////////////////
+
+// CreateTopic implements the CreateTopic method from the sarama.ClusterAdmin
+// interface. The replicas are assigned over the brokers set with SetBrokers
+// unless the detail has a ReplicaAssignment, a partition count or replication
+// factor of -1 meaning 1.
+func (ca *ClusterAdmin) CreateTopic(topic string, detail *sarama.TopicDetail, validateOnly bool) error {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if expected, err := ca.intercept("CreateTopic", topic, detail, validateOnly); expected && err != nil {
+		return err
+	}
+	return ca.createTopic(topic, detail, validateOnly)
+}
+
+func (ca *ClusterAdmin) createTopic(topic string, detail *sarama.TopicDetail, validateOnly bool) error {
+	if topic == "" {
+		return sarama.ErrInvalidTopic
+	}
+	if detail == nil {
+		return errors.New("you must specify topic details")
+	}
+	if _, ok := ca.topics[topic]; ok {
+		return topicError(sarama.ErrTopicAlreadyExists, "Topic '%s' already exists.", topic)
+	}
+
+	created := &sarama.TopicDetail{ReplicaAssignment: make(map[int32][]int32)}
+	if len(detail.ReplicaAssignment) > 0 {
+		if detail.NumPartitions > 0 || detail.ReplicationFactor > 0 {
+			return topicError(sarama.ErrInvalidRequest,
+				"Both numPartitions or replicationFactor and replicasAssignments were set.")
+		}
+		for partition := int32(0); partition < int32(len(detail.ReplicaAssignment)); partition++ {
+			replicas, ok := detail.ReplicaAssignment[partition]
+			if !ok {
+				return topicError(sarama.ErrInvalidReplicaAssignment, "Partitions should be a consecutive 0-based integer sequence.")
+			}
+			if err := ca.checkReplicas(replicas, len(detail.ReplicaAssignment[0])); err != nil {
+				return err
+			}
+			created.ReplicaAssignment[partition] = append([]int32(nil), replicas...)
+		}
+		created.NumPartitions = int32(len(detail.ReplicaAssignment))
+		created.ReplicationFactor = int16(len(detail.ReplicaAssignment[0]))
+	} else {
+		created.NumPartitions, created.ReplicationFactor = detail.NumPartitions, detail.ReplicationFactor
+		if created.NumPartitions == -1 {
+			created.NumPartitions = 1
+		}
+		if created.ReplicationFactor == -1 {
+			created.ReplicationFactor = 1
+		}
+		assignment, err := sarama.AssignReplicas(ca.assignmentBrokers(), created.NumPartitions, created.ReplicationFactor, 0, 0)
+		if err != nil {
+			return err
+		}
+		for partition, replicas := range assignment {
+			created.ReplicaAssignment[int32(partition)] = replicas
+		}
+	}
+
+	if validateOnly {
+		return nil
+	}
+	ca.topics[topic] = created
+	for name, value := range detail.ConfigEntries {
+		if value != nil {
+			ca.resourceConfigs(sarama.TopicResource, topic)[name] = *value
+		}
+	}
+	return nil
+}
+
+// checkReplicas checks the replicas of a partition refer to distinct brokers
+// of the cluster, and that there are as many as the replication factor
+func (ca *ClusterAdmin) checkReplicas(replicas []int32, replicationFactor int) error {
+	if len(replicas) == 0 || len(replicas) != replicationFactor {
+		return topicError(sarama.ErrInvalidReplicaAssignment, "All partitions should have the same number of replicas.")
+	}
+	seen := make(map[int32]bool, len(replicas))
+	for _, replica := range replicas {
+		if seen[replica] {
+			return topicError(sarama.ErrInvalidReplicaAssignment, "Duplicate replica assignment found: %v", replicas)
+		}
+		seen[replica] = true
+		if !ca.isBroker(replica) {
+			return topicError(sarama.ErrInvalidReplicaAssignment, "Unknown broker %d in the replica assignment.", replica)
+		}
+	}
+	return nil
+}
+
+func (ca *ClusterAdmin) isBroker(id int32) bool {
+	for _, broker := range ca.brokers {
+		if broker == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (ca *ClusterAdmin) assignmentBrokers() []sarama.ReplicaAssignmentBroker {
+	brokers := make([]sarama.ReplicaAssignmentBroker, len(ca.brokers))
+	for i, id := range ca.brokers {
+		brokers[i].ID = id
+	}
+	return brokers
+}
+
+// CreateTopicAndWait implements the CreateTopicAndWait method from the
+// sarama.ClusterAdmin interface. The topic is ready as soon as it is created.
+func (ca *ClusterAdmin) CreateTopicAndWait(ctx context.Context, topic string, detail *sarama.TopicDetail) error {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if expected, err := ca.intercept("CreateTopicAndWait", ctx, topic, detail); expected && err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return ca.createTopic(topic, detail, false)
+}
+
+// ListTopics implements the ListTopics method from the sarama.ClusterAdmin
+// interface.
+func (ca *ClusterAdmin) ListTopics() (map[string]sarama.TopicDetail, error) {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if expected, err := ca.intercept("ListTopics"); expected && err != nil {
+		return nil, err
+	}
+
+	topics := make(map[string]sarama.TopicDetail, len(ca.topics))
+	for topic, detail := range ca.topics {
+		listed := sarama.TopicDetail{
+			NumPartitions:     detail.NumPartitions,
+			ReplicationFactor: detail.ReplicationFactor,
+			ReplicaAssignment: make(map[int32][]int32, len(detail.ReplicaAssignment)),
+			ConfigEntries:     make(map[string]*string),
+		}
+		for partition, replicas := range detail.ReplicaAssignment {
+			listed.ReplicaAssignment[partition] = append([]int32(nil), replicas...)
+		}
+		for name, value := range ca.configs[sarama.TopicResource][topic] {
+			value := value
+			listed.ConfigEntries[name] = &value
+		}
+		topics[topic] = listed
+	}
+	return topics, nil
+}
+
+// DescribeTopics implements the DescribeTopics method from the sarama.ClusterAdmin
+// interface, describing every topic when topics is empty. The first replica of
+// each partition is its leader and all of them are in sync.
+func (ca *ClusterAdmin) DescribeTopics(topics []string) ([]*sarama.TopicMetadata, error) {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if expected, err := ca.intercept("DescribeTopics", topics); expected && err != nil {
+		return nil, err
+	}
+
+	if len(topics) == 0 {
+		for topic := range ca.topics {
+			topics = append(topics, topic)
+		}
+		sort.Strings(topics)
+	}
+
+	metadata := make([]*sarama.TopicMetadata, 0, len(topics))
+	for _, topic := range topics {
+		detail, ok := ca.topics[topic]
+		if !ok {
+			metadata = append(metadata, &sarama.TopicMetadata{Name: topic, Err: sarama.ErrUnknownTopicOrPartition})
+			continue
+		}
+		tm := &sarama.TopicMetadata{Name: topic, Err: sarama.ErrNoError}
+		for partition := int32(0); partition < detail.NumPartitions; partition++ {
+			replicas := detail.ReplicaAssignment[partition]
+			tm.Partitions = append(tm.Partitions, &sarama.PartitionMetadata{
+				Err:      sarama.ErrNoError,
+				ID:       partition,
+				Leader:   replicas[0],
+				Replicas: append([]int32(nil), replicas...),
+				Isr:      append([]int32(nil), replicas...),
+			})
+		}
+		metadata = append(metadata, tm)
+	}
+	return metadata, nil
+}
+
+// DeleteTopic implements the DeleteTopic method from the sarama.ClusterAdmin
+// interface, deleting the config overrides of the topic along with it.
+func (ca *ClusterAdmin) DeleteTopic(topic string) error {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if expected, err := ca.intercept("DeleteTopic", topic); expected && err != nil {
+		return err
+	}
+	if topic == "" {
+		return sarama.ErrInvalidTopic
+	}
+	if _, ok := ca.topics[topic]; !ok {
+		return sarama.ErrUnknownTopicOrPartition
+	}
+	delete(ca.topics, topic)
+	delete(ca.configs[sarama.TopicResource], topic)
+	return nil
+}
+
+// CreatePartitions implements the CreatePartitions method from the
+// sarama.ClusterAdmin interface. The replicas of the new partitions are
+// assigned over the brokers unless assignment is set.
+func (ca *ClusterAdmin) CreatePartitions(topic string, count int32, assignment [][]int32, validateOnly bool) error {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if expected, err := ca.intercept("CreatePartitions", topic, count, assignment, validateOnly); expected && err != nil {
+		return err
+	}
+	return ca.createPartitions(topic, count, assignment, validateOnly)
+}
+
+func (ca *ClusterAdmin) createPartitions(topic string, count int32, assignment [][]int32, validateOnly bool) error {
+	if topic == "" {
+		return sarama.ErrInvalidTopic
+	}
+	detail, ok := ca.topics[topic]
+	if !ok {
+		msg := fmt.Sprintf("The topic '%s' does not exist.", topic)
+		return &sarama.TopicPartitionError{Err: sarama.ErrUnknownTopicOrPartition, ErrMsg: &msg}
+	}
+	if count <= detail.NumPartitions {
+		msg := fmt.Sprintf("Topic currently has %d partitions, which is higher than the requested %d.", detail.NumPartitions, count)
+		return &sarama.TopicPartitionError{Err: sarama.ErrInvalidPartitions, ErrMsg: &msg}
+	}
+
+	if assignment == nil {
+		var err error
+		assignment, err = sarama.AssignReplicas(ca.assignmentBrokers(), count-detail.NumPartitions, detail.ReplicationFactor, detail.NumPartitions, 0)
+		if err != nil {
+			return err
+		}
+	} else {
+		if int32(len(assignment)) != count-detail.NumPartitions {
+			msg := fmt.Sprintf("Increasing the number of partitions by %d but %d assignments provided.", count-detail.NumPartitions, len(assignment))
+			return &sarama.TopicPartitionError{Err: sarama.ErrInvalidReplicaAssignment, ErrMsg: &msg}
+		}
+		for _, replicas := range assignment {
+			if err := ca.checkReplicas(replicas, int(detail.ReplicationFactor)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if validateOnly {
+		return nil
+	}
+	for i, replicas := range assignment {
+		detail.ReplicaAssignment[detail.NumPartitions+int32(i)] = append([]int32(nil), replicas...)
+	}
+	detail.NumPartitions = count
+	return nil
+}
+
+// ApplyTopic implements the ApplyTopic method from the sarama.ClusterAdmin
+// interface.
+func (ca *ClusterAdmin) ApplyTopic(topic string, spec *sarama.TopicDetail, validateOnly bool) (*sarama.TopicChangeReport, error) {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if expected, err := ca.intercept("ApplyTopic", topic, spec, validateOnly); expected && err != nil {
+		return nil, err
+	}
+	if topic == "" {
+		return nil, sarama.ErrInvalidTopic
+	}
+	if spec == nil {
+		return nil, errors.New("you must specify topic details")
+	}
+
+	report := &sarama.TopicChangeReport{Topic: topic, ValidateOnly: validateOnly}
+	detail, ok := ca.topics[topic]
+	if !ok {
+		if err := ca.createTopic(topic, spec, validateOnly); err != nil {
+			return nil, err
+		}
+		report.Created = true
+		report.PartitionsTo = spec.NumPartitions
+		report.ReplicationFactor = spec.ReplicationFactor
+		return report, nil
+	}
+
+	report.PartitionsFrom = detail.NumPartitions
+	report.PartitionsTo = detail.NumPartitions
+	report.ReplicationFactor = detail.ReplicationFactor
+	report.ReplicationFactorDiffers = spec.ReplicationFactor > 0 && spec.ReplicationFactor != detail.ReplicationFactor
+	if spec.NumPartitions > 0 && spec.NumPartitions < detail.NumPartitions {
+		return nil, fmt.Errorf("%w: cannot decrease the partitions of %s from %d to %d",
+			sarama.ErrInvalidPartitions, topic, detail.NumPartitions, spec.NumPartitions)
+	}
+
+	current := ca.configs[sarama.TopicResource][topic]
+	for name, value := range spec.ConfigEntries {
+		if value == nil {
+			continue
+		}
+		oldValue, ok := current[name]
+		if ok && oldValue == *value {
+			continue
+		}
+		change := sarama.TopicConfigChange{Name: name, Operation: sarama.IncrementalAlterConfigsOperationSet, NewValue: value}
+		if ok {
+			change.OldValue = &oldValue
+		}
+		report.ConfigChanges = append(report.ConfigChanges, change)
+	}
+	for name, oldValue := range current {
+		if value, ok := spec.ConfigEntries[name]; ok && value != nil {
+			continue
+		}
+		oldValue := oldValue
+		report.ConfigChanges = append(report.ConfigChanges, sarama.TopicConfigChange{
+			Name:      name,
+			Operation: sarama.IncrementalAlterConfigsOperationDelete,
+			OldValue:  &oldValue,
+		})
+	}
+	sort.Slice(report.ConfigChanges, func(i, j int) bool {
+		return report.ConfigChanges[i].Name < report.ConfigChanges[j].Name
+	})
+
+	if spec.NumPartitions > detail.NumPartitions {
+		if err := ca.createPartitions(topic, spec.NumPartitions, nil, validateOnly); err != nil {
+			return nil, err
+		}
+		report.PartitionsTo = spec.NumPartitions
+	}
+	if !validateOnly {
+		for _, change := range report.ConfigChanges {
+			if change.NewValue == nil {
+				delete(current, change.Name)
+			} else {
+				ca.resourceConfigs(sarama.TopicResource, topic)[change.Name] = *change.NewValue
+			}
+		}
+	}
+	return report, nil
+}
+
+// CloneTopic implements the CloneTopic method from the sarama.ClusterAdmin
+// interface.
+func (ca *ClusterAdmin) CloneTopic(source, destination string, numPartitions int32, validateOnly bool) (*sarama.TopicDetail, error) {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if expected, err := ca.intercept("CloneTopic", source, destination, numPartitions, validateOnly); expected && err != nil {
+		return nil, err
+	}
+	if source == "" || destination == "" {
+		return nil, sarama.ErrInvalidTopic
+	}
+	sourceDetail, ok := ca.topics[source]
+	if !ok {
+		return nil, sarama.ErrUnknownTopicOrPartition
+	}
+
+	detail := &sarama.TopicDetail{
+		NumPartitions:     sourceDetail.NumPartitions,
+		ReplicationFactor: sourceDetail.ReplicationFactor,
+		ConfigEntries:     make(map[string]*string),
+	}
+	if numPartitions > 0 {
+		detail.NumPartitions = numPartitions
+	}
+	for name, value := range ca.configs[sarama.TopicResource][source] {
+		value := value
+		detail.ConfigEntries[name] = &value
+	}
+
+	if err := ca.createTopic(destination, detail, validateOnly); err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+///////////////////////////////////////////////////
+// Configs
+///////////////////////////////////////////////////
+
+// resourceConfigs returns the config overrides of a resource, creating them
+// if needed. It must be called with the lock held
+func (ca *ClusterAdmin) resourceConfigs(resourceType sarama.ConfigResourceType, name string) map[string]string {
+	if ca.configs[resourceType] == nil {
+		ca.configs[resourceType] = make(map[string]map[string]string)
+	}
+	if ca.configs[resourceType][name] == nil {
+		ca.configs[resourceType][name] = make(map[string]string)
+	}
+	return ca.configs[resourceType][name]
+}
+
+// checkResource checks the resource whose configs are described or altered
+// exists
+func (ca *ClusterAdmin) checkResource(resourceType sarama.ConfigResourceType, name string) error {
+	if resourceType != sarama.TopicResource {
+		return nil
+	}
+	if _, ok := ca.topics[name]; !ok {
+		return sarama.ErrUnknownTopicOrPartition
+	}
+	return nil
+}
+
+// configSource returns the source of the configs set on a resource
+func configSource(resourceType sarama.ConfigResourceType, name string) sarama.ConfigSource {
+	switch {
+	case resourceType == sarama.TopicResource:
+		return sarama.SourceTopic
+	case resourceType == sarama.BrokerLoggerResource:
+		return sarama.SourceDynamicBrokerLogger
+	case name == "":
+		return sarama.SourceDynamicDefaultBroker
+	default:
+		return sarama.SourceDynamicBroker
+	}
+}
+
+// DescribeConfig implements the DescribeConfig method from the sarama.ClusterAdmin
+// interface. Only the configs set on the resource are described, sorted by name.
+func (ca *ClusterAdmin) DescribeConfig(resource sarama.ConfigResource) ([]sarama.ConfigEntry, error) {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if expected, err := ca.intercept("DescribeConfig", resource); expected && err != nil {
+		return nil, err
+	}
+	if err := ca.checkResource(resource.Type, resource.Name); err != nil {
+		return nil, err
+	}
+
+	var entries []sarama.ConfigEntry
+	for name, value := range ca.configs[resource.Type][resource.Name] {
+		if len(resource.ConfigNames) > 0 && !containsString(resource.ConfigNames, name) {
+			continue
+		}
+		entries = append(entries, sarama.ConfigEntry{
+			Name:   name,
+			Value:  value,
+			Source: configSource(resource.Type, resource.Name),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// AlterConfig implements the AlterConfig method from the sarama.ClusterAdmin
+// interface. Like the brokers do, the configs of the resource missing from
+// entries are reset.
+func (ca *ClusterAdmin) AlterConfig(resourceType sarama.ConfigResourceType, name string, entries map[string]*string, validateOnly bool) error {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if expected, err := ca.intercept("AlterConfig", resourceType, name, entries, validateOnly); expected && err != nil {
+		return err
+	}
+	if err := ca.checkResource(resourceType, name); err != nil {
+		return err
+	}
+	if validateOnly {
+		return nil
+	}
+
+	configs := make(map[string]string, len(entries))
+	for config, value := range entries {
+		if value != nil {
+			configs[config] = *value
+		}
+	}
+	ca.resourceConfigs(resourceType, name)
+	ca.configs[resourceType][name] = configs
+	return nil
+}
+
+// IncrementalAlterConfig implements the IncrementalAlterConfig method from the
+// sarama.ClusterAdmin interface. The Append and Subtract operations treat the
+// configs as comma separated lists.
+func (ca *ClusterAdmin) IncrementalAlterConfig(resourceType sarama.ConfigResourceType, name string, entries map[string]sarama.IncrementalAlterConfigsEntry, validateOnly bool) error {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if expected, err := ca.intercept("IncrementalAlterConfig", resourceType, name, entries, validateOnly); expected && err != nil {
+		return err
+	}
+	if err := ca.checkResource(resourceType, name); err != nil {
+		return err
+	}
+
+	current := ca.configs[resourceType][name]
+	configs := make(map[string]string, len(current))
+	for config, value := range current {
+		configs[config] = value
+	}
+	for config, entry := range entries {
+		if entry.Operation != sarama.IncrementalAlterConfigsOperationDelete && entry.Value == nil {
+			return sarama.ErrInvalidConfig
+		}
+		switch entry.Operation {
+		case sarama.IncrementalAlterConfigsOperationSet:
+			configs[config] = *entry.Value
+		case sarama.IncrementalAlterConfigsOperationDelete:
+			delete(configs, config)
+		case sarama.IncrementalAlterConfigsOperationAppend:
+			values := splitList(configs[config])
+			for _, value := range splitList(*entry.Value) {
+				if !containsString(values, value) {
+					values = append(values, value)
+				}
+			}
+			configs[config] = strings.Join(values, ",")
+		case sarama.IncrementalAlterConfigsOperationSubtract:
+			var values []string
+			subtracted := splitList(*entry.Value)
+			for _, value := range splitList(configs[config]) {
+				if !containsString(subtracted, value) {
+					values = append(values, value)
+				}
+			}
+			configs[config] = strings.Join(values, ",")
+		default:
+			return sarama.ErrInvalidRequest
+		}
+	}
+
+	if validateOnly {
+		return nil
+	}
+	ca.resourceConfigs(resourceType, name)
+	ca.configs[resourceType][name] = configs
+	return nil
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+///////////////////////////////////////////////////
+// ACLs
+///////////////////////////////////////////////////
+
+// CreateACL implements the CreateACL method from the sarama.ClusterAdmin interface.
+func (ca *ClusterAdmin) CreateACL(resource sarama.Resource, acl sarama.Acl) error {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if expected, err := ca.intercept("CreateACL", resource, acl); expected && err != nil {
+		return err
+	}
+	return ca.createACL(resource, acl)
+}
+
+func (ca *ClusterAdmin) createACL(resource sarama.Resource, acl sarama.Acl) error {
+	switch resource.ResourceType {
+	case sarama.AclResourceUnknown, sarama.AclResourceAny:
+		return fmt.Errorf("%w: invalid resource type %s", sarama.ErrInvalidRequest, &resource.ResourceType)
+	}
+	switch resource.ResourcePatternType {
+	case sarama.AclPatternUnknown, sarama.AclPatternAny, sarama.AclPatternMatch:
+		return fmt.Errorf("%w: invalid resource pattern type %s", sarama.ErrInvalidRequest, &resource.ResourcePatternType)
+	}
+	switch acl.Operation {
+	case sarama.AclOperationUnknown, sarama.AclOperationAny:
+		return fmt.Errorf("%w: invalid operation %s", sarama.ErrInvalidRequest, &acl.Operation)
+	}
+	switch acl.PermissionType {
+	case sarama.AclPermissionUnknown, sarama.AclPermissionAny:
+		return fmt.Errorf("%w: invalid permission type %s", sarama.ErrInvalidRequest, &acl.PermissionType)
+	}
+
+	// creating an existing ACL succeeds without duplicating it
+	binding := aclBinding{resource: resource, acl: acl}
+	for _, existing := range ca.acls {
+		if existing == binding {
+			return nil
+		}
+	}
+	ca.acls = append(ca.acls, binding)
+	return nil
+}
+
+// CreateACLs implements the CreateACLs method from the sarama.ClusterAdmin interface.
+func (ca *ClusterAdmin) CreateACLs(bindings []sarama.AclCreation) ([]sarama.AclCreationResult, error) {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if expected, err := ca.intercept("CreateACLs", bindings); expected && err != nil {
+		return nil, err
+	}
+
+	results := make([]sarama.AclCreationResult, len(bindings))
+	for i, binding := range bindings {
+		results[i] = sarama.AclCreationResult{
+			AclCreation: binding,
+			Err:         ca.createACL(binding.Resource, binding.Acl),
+		}
+	}
+	return results, nil
+}
+
+// ListAcls implements the ListAcls method from the sarama.ClusterAdmin interface.
+func (ca *ClusterAdmin) ListAcls(filter sarama.AclFilter) ([]sarama.ResourceAcls, error) {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if expected, err := ca.intercept("ListAcls", filter); expected && err != nil {
+		return nil, err
+	}
+
+	var resourceAcls []sarama.ResourceAcls
+	indexes := make(map[sarama.Resource]int)
+	for _, binding := range ca.acls {
+		if !filter.Matches(binding.resource, binding.acl) {
+			continue
+		}
+		i, ok := indexes[binding.resource]
+		if !ok {
+			i = len(resourceAcls)
+			indexes[binding.resource] = i
+			resourceAcls = append(resourceAcls, sarama.ResourceAcls{Resource: binding.resource})
+		}
+		acl := binding.acl
+		resourceAcls[i].Acls = append(resourceAcls[i].Acls, &acl)
+	}
+	return resourceAcls, nil
+}
+
+// deleteACLs deletes the ACLs matching the filter, returning them
+func (ca *ClusterAdmin) deleteACLs(filter sarama.AclFilter, validateOnly bool) []sarama.MatchingAcl {
+	var matching []sarama.MatchingAcl
+	remaining := ca.acls[:0:0]
+	for _, binding := range ca.acls {
+		if !filter.Matches(binding.resource, binding.acl) {
+			remaining = append(remaining, binding)
+			continue
+		}
+		matching = append(matching, sarama.MatchingAcl{
+			Err:      sarama.ErrNoError,
+			Resource: binding.resource,
+			Acl:      binding.acl,
+		})
+	}
+	if !validateOnly {
+		ca.acls = remaining
+	}
+	return matching
+}
+
+// DeleteACL implements the DeleteACL method from the sarama.ClusterAdmin
+// interface. The matching ACLs are returned without being deleted when
+// validateOnly is set.
+func (ca *ClusterAdmin) DeleteACL(filter sarama.AclFilter, validateOnly bool) ([]sarama.MatchingAcl, error) {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if expected, err := ca.intercept("DeleteACL", filter, validateOnly); expected && err != nil {
+		return nil, err
+	}
+	return ca.deleteACLs(filter, validateOnly), nil
+}
+
+// DeleteACLs implements the DeleteACLs method from the sarama.ClusterAdmin interface.
+func (ca *ClusterAdmin) DeleteACLs(filters []sarama.AclFilter) ([]sarama.AclDeletionResult, error) {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if expected, err := ca.intercept("DeleteACLs", filters); expected && err != nil {
+		return nil, err
+	}
+
+	results := make([]sarama.AclDeletionResult, len(filters))
+	for i, filter := range filters {
+		results[i] = sarama.AclDeletionResult{
+			Filter:       filter,
+			MatchingAcls: ca.deleteACLs(filter, false),
+		}
+	}
+	return results, nil
+}
+
+///////////////////////////////////////////////////
+// Methods outside of the model
+///////////////////////////////////////////////////
+
+// AlterPartitionReassignments implements the AlterPartitionReassignments method
+// from the sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) AlterPartitionReassignments(topic string, assignment [][]int32) error {
+	return ca.unmodelled("AlterPartitionReassignments", topic, assignment)
+}
+
+// ListPartitionReassignments implements the ListPartitionReassignments method
+// from the sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) ListPartitionReassignments(topic string, partitions []int32) (map[string]map[int32]*sarama.PartitionReplicaReassignmentsStatus, error) {
+	return nil, ca.unmodelled("ListPartitionReassignments", topic, partitions)
+}
+
+// ListOngoingPartitionReassignments implements the ListOngoingPartitionReassignments
+// method from the sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) ListOngoingPartitionReassignments(partitions map[string][]int32) (map[string]map[int32]*sarama.PartitionReplicaReassignmentsStatus, error) {
+	return nil, ca.unmodelled("ListOngoingPartitionReassignments", partitions)
+}
+
+// WaitForReassignment implements the WaitForReassignment method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) WaitForReassignment(ctx context.Context, partitions map[string][]int32, progress func(*sarama.ReassignmentProgress)) error {
+	return ca.unmodelled("WaitForReassignment", ctx, partitions, progress)
+}
+
+// ElectLeaders implements the ElectLeaders method from the sarama.ClusterAdmin
+// interface. It requires an expectation.
+func (ca *ClusterAdmin) ElectLeaders(electionType sarama.ElectionType, partitions map[string][]int32) (map[string]map[int32]*sarama.PartitionResult, error) {
+	return nil, ca.unmodelled("ElectLeaders", electionType, partitions)
+}
+
+// DeleteRecords implements the DeleteRecords method from the sarama.ClusterAdmin
+// interface. It requires an expectation.
+func (ca *ClusterAdmin) DeleteRecords(topic string, partitionOffsets map[int32]int64) error {
+	return ca.unmodelled("DeleteRecords", topic, partitionOffsets)
+}
+
+// DeleteRecordsBatch implements the DeleteRecordsBatch method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) DeleteRecordsBatch(offsets map[string]map[int32]int64) (map[string]map[int32]*sarama.DeleteRecordsResult, error) {
+	return nil, ca.unmodelled("DeleteRecordsBatch", offsets)
+}
+
+// ListOffsets implements the ListOffsets method from the sarama.ClusterAdmin
+// interface. It requires an expectation.
+func (ca *ClusterAdmin) ListOffsets(times map[string]map[int32]int64, isolationLevel sarama.IsolationLevel) (map[string]map[int32]*sarama.ListOffsetsResult, error) {
+	return nil, ca.unmodelled("ListOffsets", times, isolationLevel)
+}
+
+// DescribeProducers implements the DescribeProducers method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) DescribeProducers(partitions map[string][]int32) (map[string]map[int32]*sarama.DescribeProducersResult, error) {
+	return nil, ca.unmodelled("DescribeProducers", partitions)
+}
+
+// ListTransactions implements the ListTransactions method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) ListTransactions(stateFilters []string, producerIDFilters []int64) ([]*sarama.TransactionListing, error) {
+	return nil, ca.unmodelled("ListTransactions", stateFilters, producerIDFilters)
+}
+
+// DescribeTransactions implements the DescribeTransactions method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) DescribeTransactions(transactionalIDs []string) ([]*sarama.TransactionDescription, error) {
+	return nil, ca.unmodelled("DescribeTransactions", transactionalIDs)
+}
+
+// AbortTransaction implements the AbortTransaction method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) AbortTransaction(spec sarama.AbortTransactionSpec) error {
+	return ca.unmodelled("AbortTransaction", spec)
+}
+
+// ListConsumerGroups implements the ListConsumerGroups method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) ListConsumerGroups() (map[string]string, error) {
+	return nil, ca.unmodelled("ListConsumerGroups")
+}
+
+// ListConsumerGroupsWithFilters implements the ListConsumerGroupsWithFilters
+// method from the sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) ListConsumerGroupsWithFilters(states []string, types []string) (map[string]*sarama.ConsumerGroupListing, error) {
+	return nil, ca.unmodelled("ListConsumerGroupsWithFilters", states, types)
+}
+
+// DescribeConsumerGroups implements the DescribeConsumerGroups method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) DescribeConsumerGroups(groups []string) ([]*sarama.GroupDescription, error) {
+	return nil, ca.unmodelled("DescribeConsumerGroups", groups)
+}
+
+// ListConsumerGroupOffsets implements the ListConsumerGroupOffsets method from
+// the sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) ListConsumerGroupOffsets(group string, topicPartitions map[string][]int32) (*sarama.OffsetFetchResponse, error) {
+	return nil, ca.unmodelled("ListConsumerGroupOffsets", group, topicPartitions)
+}
+
+// DeleteConsumerGroupOffset implements the DeleteConsumerGroupOffset method from
+// the sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) DeleteConsumerGroupOffset(group string, topic string, partition int32) error {
+	return ca.unmodelled("DeleteConsumerGroupOffset", group, topic, partition)
+}
+
+// DeleteConsumerGroupOffsets implements the DeleteConsumerGroupOffsets method
+// from the sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) DeleteConsumerGroupOffsets(group string, partitions map[string][]int32) (map[string]map[int32]sarama.KError, error) {
+	return nil, ca.unmodelled("DeleteConsumerGroupOffsets", group, partitions)
+}
+
+// AlterConsumerGroupOffsets implements the AlterConsumerGroupOffsets method from
+// the sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) AlterConsumerGroupOffsets(group string, offsets map[string]map[int32]int64) (map[string]map[int32]sarama.KError, error) {
+	return nil, ca.unmodelled("AlterConsumerGroupOffsets", group, offsets)
+}
+
+// ResetConsumerGroupOffsets implements the ResetConsumerGroupOffsets method from
+// the sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) ResetConsumerGroupOffsets(group string, times map[string]map[int32]int64) (map[string]map[int32]sarama.KError, error) {
+	return nil, ca.unmodelled("ResetConsumerGroupOffsets", group, times)
+}
+
+// DeleteConsumerGroup implements the DeleteConsumerGroup method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) DeleteConsumerGroup(group string) error {
+	return ca.unmodelled("DeleteConsumerGroup", group)
+}
+
+// RemoveMembersFromConsumerGroup implements the RemoveMembersFromConsumerGroup
+// method from the sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) RemoveMembersFromConsumerGroup(group string, groupInstanceIDs []string) (map[string]sarama.KError, error) {
+	return nil, ca.unmodelled("RemoveMembersFromConsumerGroup", group, groupInstanceIDs)
+}
+
+// RemoveAllMembersFromConsumerGroup implements the RemoveAllMembersFromConsumerGroup
+// method from the sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) RemoveAllMembersFromConsumerGroup(group string) (map[string]sarama.KError, error) {
+	return nil, ca.unmodelled("RemoveAllMembersFromConsumerGroup", group)
+}
+
+// DescribeCluster implements the DescribeCluster method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) DescribeCluster() ([]*sarama.Broker, int32, error) {
+	return nil, -1, ca.unmodelled("DescribeCluster")
+}
+
+// DescribeClusterDetails implements the DescribeClusterDetails method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) DescribeClusterDetails(includeAuthorizedOperations bool) (*sarama.ClusterDescription, error) {
+	return nil, ca.unmodelled("DescribeClusterDetails", includeAuthorizedOperations)
+}
+
+// DescribeFeatures implements the DescribeFeatures method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) DescribeFeatures() (*sarama.FeatureMetadata, error) {
+	return nil, ca.unmodelled("DescribeFeatures")
+}
+
+// UpdateFeatures implements the UpdateFeatures method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) UpdateFeatures(updates map[string]sarama.FeatureUpdate, validateOnly bool) (map[string]error, error) {
+	return nil, ca.unmodelled("UpdateFeatures", updates, validateOnly)
+}
+
+// DescribeQuorum implements the DescribeQuorum method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) DescribeQuorum() (*sarama.QuorumInfo, error) {
+	return nil, ca.unmodelled("DescribeQuorum")
+}
+
+// UnregisterBroker implements the UnregisterBroker method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) UnregisterBroker(brokerID int32) error {
+	return ca.unmodelled("UnregisterBroker", brokerID)
+}
+
+// DescribeLogDirs implements the DescribeLogDirs method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) DescribeLogDirs(brokers []int32) (map[int32][]sarama.DescribeLogDirsResponseDirMetadata, error) {
+	return nil, ca.unmodelled("DescribeLogDirs", brokers)
+}
+
+// DescribeUserScramCredentials implements the DescribeUserScramCredentials
+// method from the sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) DescribeUserScramCredentials(users []string) ([]*sarama.DescribeUserScramCredentialsResult, error) {
+	return nil, ca.unmodelled("DescribeUserScramCredentials", users)
+}
+
+// DeleteUserScramCredentials implements the DeleteUserScramCredentials method
+// from the sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) DeleteUserScramCredentials(delete []sarama.AlterUserScramCredentialsDelete) ([]*sarama.AlterUserScramCredentialsResult, error) {
+	return nil, ca.unmodelled("DeleteUserScramCredentials", delete)
+}
+
+// UpsertUserScramCredentials implements the UpsertUserScramCredentials method
+// from the sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) UpsertUserScramCredentials(upsert []sarama.AlterUserScramCredentialsUpsert) ([]*sarama.AlterUserScramCredentialsResult, error) {
+	return nil, ca.unmodelled("UpsertUserScramCredentials", upsert)
+}
+
+// AlterUserScramCredentials implements the AlterUserScramCredentials method
+// from the sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) AlterUserScramCredentials(upsert []sarama.AlterUserScramCredentialsUpsert, delete []sarama.AlterUserScramCredentialsDelete) ([]*sarama.AlterUserScramCredentialsResult, error) {
+	return nil, ca.unmodelled("AlterUserScramCredentials", upsert, delete)
+}
+
+// CreateDelegationToken implements the CreateDelegationToken method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) CreateDelegationToken(renewers []sarama.KafkaPrincipal, maxLifetime time.Duration) (*sarama.DelegationToken, error) {
+	return nil, ca.unmodelled("CreateDelegationToken", renewers, maxLifetime)
+}
+
+// RenewDelegationToken implements the RenewDelegationToken method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) RenewDelegationToken(hmac []byte, renewPeriod time.Duration) (time.Time, error) {
+	return time.Time{}, ca.unmodelled("RenewDelegationToken", hmac, renewPeriod)
+}
+
+// ExpireDelegationToken implements the ExpireDelegationToken method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) ExpireDelegationToken(hmac []byte, expiryPeriod time.Duration) (time.Time, error) {
+	return time.Time{}, ca.unmodelled("ExpireDelegationToken", hmac, expiryPeriod)
+}
+
+// DescribeDelegationToken implements the DescribeDelegationToken method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) DescribeDelegationToken(owners []sarama.KafkaPrincipal) ([]*sarama.DelegationToken, error) {
+	return nil, ca.unmodelled("DescribeDelegationToken", owners)
+}
+
+// DescribeClientQuotas implements the DescribeClientQuotas method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) DescribeClientQuotas(components []sarama.QuotaFilterComponent, strict bool) ([]sarama.DescribeClientQuotasEntry, error) {
+	return nil, ca.unmodelled("DescribeClientQuotas", components, strict)
+}
+
+// AlterClientQuotas implements the AlterClientQuotas method from the
+// sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) AlterClientQuotas(entity []sarama.QuotaEntityComponent, op sarama.ClientQuotasOp, validateOnly bool) error {
+	return ca.unmodelled("AlterClientQuotas", entity, op, validateOnly)
+}
+
+// AlterClientQuotasEntries implements the AlterClientQuotasEntries method from
+// the sarama.ClusterAdmin interface. It requires an expectation.
+func (ca *ClusterAdmin) AlterClientQuotasEntries(entries []sarama.AlterClientQuotasEntry, validateOnly bool) error {
+	return ca.unmodelled("AlterClientQuotasEntries", entries, validateOnly)
+}
+
+// Controller implements the Controller method from the sarama.ClusterAdmin
+// interface. It requires an expectation.
+func (ca *ClusterAdmin) Controller() (*sarama.Broker, error) {
+	return nil, ca.unmodelled("Controller")
+}
+
+///////////////////////////////////////////////////
+// Lifecycle
+///////////////////////////////////////////////////
+
+// WithContext implements the WithContext method from the sarama.ClusterAdmin
+// interface. The context is ignored and the mock itself is returned.
+func (ca *ClusterAdmin) WithContext(ctx context.Context) sarama.ClusterAdmin {
+	return ca
+}
+
+// WithOptions implements the WithOptions method from the sarama.ClusterAdmin
+// interface. The options are ignored and the mock itself is returned.
+func (ca *ClusterAdmin) WithOptions(options sarama.AdminOptions) sarama.ClusterAdmin {
+	return ca
+}
+
+// Close implements the Close method from the sarama.ClusterAdmin interface. It
+// reports the expectations which were not used.
+func (ca *ClusterAdmin) Close() error {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if ca.closed {
+		return nil
+	}
+	ca.closed = true
+
+	methods := make([]string, 0, len(ca.expectations))
+	for method := range ca.expectations {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		if n := len(ca.expectations[method]); n > 0 {
+			ca.t.Errorf("Expected %d more calls to %s.", n, method)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package mocks
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestMockClusterAdminImplementsClusterAdminInterface(t *testing.T) {
+	var ca interface{} = &ClusterAdmin{}
+	if _, ok := ca.(sarama.ClusterAdmin); !ok {
+		t.Error("The mock cluster admin should implement the sarama.ClusterAdmin interface.")
+	}
+}
+
+func TestClusterAdminTopics(t *testing.T) {
+	admin := NewClusterAdmin(t)
+	admin.SetBrokers(1, 2, 3)
+	retention := "1000"
+
+	if err := admin.CreateTopic("my_topic", &sarama.TopicDetail{
+		NumPartitions:     3,
+		ReplicationFactor: 2,
+		ConfigEntries:     map[string]*string{"retention.ms": &retention},
+	}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.CreateTopic("validated", &sarama.TopicDetail{NumPartitions: 1, ReplicationFactor: 1}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	var topicErr *sarama.TopicError
+	err := admin.CreateTopic("my_topic", &sarama.TopicDetail{NumPartitions: 1, ReplicationFactor: 1}, false)
+	if !errors.As(err, &topicErr) || topicErr.Err != sarama.ErrTopicAlreadyExists {
+		t.Errorf("Expected ErrTopicAlreadyExists, got %v", err)
+	}
+	err = admin.CreateTopic("other", &sarama.TopicDetail{NumPartitions: 1, ReplicationFactor: 4}, false)
+	if !errors.Is(err, sarama.ErrInvalidReplicationFactor) {
+		t.Errorf("Expected ErrInvalidReplicationFactor, got %v", err)
+	}
+
+	if err := admin.CreatePartitions("my_topic", 4, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	topics, err := admin.ListTopics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(topics) != 1 {
+		t.Fatalf("Expected only my_topic to be created, got %v", topics)
+	}
+	detail := topics["my_topic"]
+	if detail.NumPartitions != 4 || detail.ReplicationFactor != 2 || len(detail.ReplicaAssignment) != 4 {
+		t.Errorf("Unexpected topic detail %+v", detail)
+	}
+	if value := detail.ConfigEntries["retention.ms"]; value == nil || *value != retention {
+		t.Errorf("Expected the retention.ms config to be listed, got %v", detail.ConfigEntries)
+	}
+
+	metadata, err := admin.DescribeTopics([]string{"my_topic", "unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metadata) != 2 || len(metadata[0].Partitions) != 4 || metadata[1].Err != sarama.ErrUnknownTopicOrPartition {
+		t.Errorf("Unexpected topic metadata %+v", metadata)
+	}
+
+	if err := admin.DeleteTopic("my_topic"); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.DeleteTopic("my_topic"); !errors.Is(err, sarama.ErrUnknownTopicOrPartition) {
+		t.Errorf("Expected ErrUnknownTopicOrPartition, got %v", err)
+	}
+	if err := admin.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClusterAdminApplyTopic(t *testing.T) {
+	admin := NewClusterAdmin(t)
+	one, two := "1", "2"
+
+	report, err := admin.ApplyTopic("my_topic", &sarama.TopicDetail{
+		NumPartitions:     1,
+		ReplicationFactor: 1,
+		ConfigEntries:     map[string]*string{"a": &one, "b": &one},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Created {
+		t.Error("Expected the topic to be created")
+	}
+
+	report, err = admin.ApplyTopic("my_topic", &sarama.TopicDetail{
+		NumPartitions: 2,
+		ConfigEntries: map[string]*string{"a": &two},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.PartitionsFrom != 1 || report.PartitionsTo != 2 || len(report.ConfigChanges) != 2 ||
+		report.ConfigChanges[0].Operation != sarama.IncrementalAlterConfigsOperationSet ||
+		report.ConfigChanges[1].Operation != sarama.IncrementalAlterConfigsOperationDelete {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	entries, err := admin.DescribeConfig(sarama.ConfigResource{Type: sarama.TopicResource, Name: "my_topic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != "a" || entries[0].Value != two || entries[0].Source != sarama.SourceTopic {
+		t.Errorf("Unexpected configs %+v", entries)
+	}
+}
+
+func TestClusterAdminConfigs(t *testing.T) {
+	admin := NewClusterAdmin(t)
+	value := "a,b"
+	c := "c"
+
+	if err := admin.AlterConfig(sarama.BrokerResource, "0", map[string]*string{"list": &value}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.IncrementalAlterConfig(sarama.BrokerResource, "0", map[string]sarama.IncrementalAlterConfigsEntry{
+		"list": {Operation: sarama.IncrementalAlterConfigsOperationAppend, Value: &c},
+	}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.IncrementalAlterConfig(sarama.BrokerResource, "0", map[string]sarama.IncrementalAlterConfigsEntry{
+		"list": {Operation: sarama.IncrementalAlterConfigsOperationSubtract, Value: &value},
+	}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := admin.DescribeConfig(sarama.ConfigResource{Type: sarama.BrokerResource, Name: "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Value != "c" || entries[0].Source != sarama.SourceDynamicBroker {
+		t.Errorf("Unexpected configs %+v", entries)
+	}
+
+	if err := admin.AlterConfig(sarama.TopicResource, "unknown", nil, false); !errors.Is(err, sarama.ErrUnknownTopicOrPartition) {
+		t.Errorf("Expected ErrUnknownTopicOrPartition, got %v", err)
+	}
+}
+
+func TestClusterAdminAcls(t *testing.T) {
+	admin := NewClusterAdmin(t)
+	topic := sarama.Resource{ResourceType: sarama.AclResourceTopic, ResourceName: "my_topic", ResourcePatternType: sarama.AclPatternLiteral}
+	prefixed := sarama.Resource{ResourceType: sarama.AclResourceTopic, ResourceName: "my_", ResourcePatternType: sarama.AclPatternPrefixed}
+	read := sarama.Acl{Principal: "User:alice", Host: "*", Operation: sarama.AclOperationRead, PermissionType: sarama.AclPermissionAllow}
+	write := sarama.Acl{Principal: "User:alice", Host: "*", Operation: sarama.AclOperationWrite, PermissionType: sarama.AclPermissionAllow}
+
+	results, err := admin.CreateACLs([]sarama.AclCreation{
+		{Resource: topic, Acl: read},
+		{Resource: prefixed, Acl: write},
+		{Resource: topic, Acl: read},
+		{Resource: sarama.Resource{ResourceType: sarama.AclResourceAny}, Acl: read},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Err != nil || results[1].Err != nil || results[2].Err != nil || !errors.Is(results[3].Err, sarama.ErrInvalidRequest) {
+		t.Errorf("Unexpected results %+v", results)
+	}
+
+	name := "my_topic"
+	acls, err := admin.ListAcls(sarama.AclFilter{
+		ResourceType:              sarama.AclResourceTopic,
+		ResourceName:              &name,
+		ResourcePatternTypeFilter: sarama.AclPatternMatch,
+		Operation:                 sarama.AclOperationAny,
+		PermissionType:            sarama.AclPermissionAny,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acls) != 2 || len(acls[0].Acls) != 1 || len(acls[1].Acls) != 1 {
+		t.Errorf("Expected the literal and prefixed ACLs to match, got %+v", acls)
+	}
+
+	matching, err := admin.DeleteACL(sarama.AclFilter{
+		ResourceType:              sarama.AclResourceAny,
+		ResourcePatternTypeFilter: sarama.AclPatternAny,
+		Operation:                 sarama.AclOperationWrite,
+		PermissionType:            sarama.AclPermissionAny,
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matching) != 1 || matching[0].Resource != prefixed {
+		t.Errorf("Unexpected deleted ACLs %+v", matching)
+	}
+	if len(admin.acls) != 1 {
+		t.Errorf("Expected 1 ACL left, got %d", len(admin.acls))
+	}
+}
+
+func TestClusterAdminExpectations(t *testing.T) {
+	trm := newTestReporterMock()
+	admin := NewClusterAdmin(trm)
+
+	admin.ExpectCall("CreateTopic").WithCheck(func(args ...interface{}) error {
+		if args[0] != "my_topic" {
+			return errors.New("unexpected topic")
+		}
+		return nil
+	})
+	admin.ExpectCall("CreateTopic").ReturnError(sarama.ErrPolicyViolation)
+	admin.ExpectCall("DescribeConsumerGroups").ReturnError(sarama.ErrGroupAuthorizationFailed)
+	admin.ExpectCall("DeleteTopic")
+
+	if err := admin.CreateTopic("my_topic", &sarama.TopicDetail{NumPartitions: 1, ReplicationFactor: 1}, false); err != nil {
+		t.Error(err)
+	}
+	if err := admin.CreateTopic("other", &sarama.TopicDetail{NumPartitions: 1, ReplicationFactor: 1}, false); !errors.Is(err, sarama.ErrPolicyViolation) {
+		t.Errorf("Expected ErrPolicyViolation, got %v", err)
+	}
+	if _, err := admin.DescribeConsumerGroups([]string{"group"}); !errors.Is(err, sarama.ErrGroupAuthorizationFailed) {
+		t.Errorf("Expected ErrGroupAuthorizationFailed, got %v", err)
+	}
+	if len(trm.errors) != 0 {
+		t.Errorf("Expected no errors to be reported, got %v", trm.errors)
+	}
+
+	if _, err := admin.ListConsumerGroups(); err == nil {
+		t.Error("Expected the unexpected call to fail")
+	}
+	admin.ExpectCall("Unknown")
+	if err := admin.Close(); err != nil {
+		t.Error(err)
+	}
+	if len(trm.errors) != 4 {
+		t.Errorf("Expected the unexpected call, the unknown method and the unused expectations to be reported, got %v", trm.errors)
+	}
+}
@@ -112,6 +112,62 @@ func (c *Consumer) HighWaterMarks() map[string]map[int32]int64 {
 	return hwms
 }
 
+// Pause implements Consumer.
+func (c *Consumer) Pause(topicPartitions map[string][]int32) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	for topic, partitions := range topicPartitions {
+		for _, partition := range partitions {
+			if topicConsumers, ok := c.partitionConsumers[topic]; ok {
+				if partitionConsumer, ok := topicConsumers[partition]; ok {
+					partitionConsumer.Pause()
+				}
+			}
+		}
+	}
+}
+
+// Resume implements Consumer.
+func (c *Consumer) Resume(topicPartitions map[string][]int32) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	for topic, partitions := range topicPartitions {
+		for _, partition := range partitions {
+			if topicConsumers, ok := c.partitionConsumers[topic]; ok {
+				if partitionConsumer, ok := topicConsumers[partition]; ok {
+					partitionConsumer.Resume()
+				}
+			}
+		}
+	}
+}
+
+// PauseAll implements Consumer.
+func (c *Consumer) PauseAll() {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	for _, partitions := range c.partitionConsumers {
+		for _, partitionConsumer := range partitions {
+			partitionConsumer.Pause()
+		}
+	}
+}
+
+// ResumeAll implements Consumer.
+func (c *Consumer) ResumeAll() {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	for _, partitions := range c.partitionConsumers {
+		for _, partitionConsumer := range partitions {
+			partitionConsumer.Resume()
+		}
+	}
+}
+
 // Close implements the Close method from the sarama.Consumer interface. It will close
 // all registered PartitionConsumer instances.
 func (c *Consumer) Close() error {
@@ -190,6 +246,7 @@ type PartitionConsumer struct {
 	consumed                bool
 	errorsShouldBeDrained   bool
 	messagesShouldBeDrained bool
+	paused                  bool
 }
 
 ///////////////////////////////////////////////////
@@ -267,6 +324,30 @@ func (pc *PartitionConsumer) HighWaterMarkOffset() int64 {
 	return atomic.LoadInt64(&pc.highWaterMarkOffset) + 1
 }
 
+// Pause implements the Pause method from the sarama.PartitionConsumer interface.
+func (pc *PartitionConsumer) Pause() {
+	pc.l.Lock()
+	defer pc.l.Unlock()
+
+	pc.paused = true
+}
+
+// Resume implements the Resume method from the sarama.PartitionConsumer interface.
+func (pc *PartitionConsumer) Resume() {
+	pc.l.Lock()
+	defer pc.l.Unlock()
+
+	pc.paused = false
+}
+
+// IsPaused implements the IsPaused method from the sarama.PartitionConsumer interface.
+func (pc *PartitionConsumer) IsPaused() bool {
+	pc.l.Lock()
+	defer pc.l.Unlock()
+
+	return pc.paused
+}
+
 ///////////////////////////////////////////////////
 // Expectation API
 ///////////////////////////////////////////////////
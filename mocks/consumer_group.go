@@ -0,0 +1,724 @@
+package mocks
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// ConsumerGroup implements sarama's ConsumerGroup interface for testing purposes.
+// Each call to Consume or ConsumePattern runs the next session registered with
+// ExpectSession through the handler: Setup is called, then ConsumeClaim for each
+// claim of the session, delivering the messages yielded on it, and Cleanup once
+// the session ends. A session ends as a real one does on a rebalance: when
+// Rebalance is called on it, when one of the ConsumeClaim calls returns, when the
+// context is canceled or when the group is closed. Once all the sessions ran,
+// Consume blocks until a new session is expected, the context is canceled or the
+// group is closed.
+type ConsumerGroup struct {
+	l          sync.Mutex
+	t          ErrorReporter
+	config     *sarama.Config
+	sessions   []*ConsumerGroupSession
+	current    *ConsumerGroupSession
+	expected   chan struct{} // closed when a session is expected
+	generation int32
+	// offsets are the marked offsets, kept across the sessions, and
+	// nextOffsets the offsets of the next yielded messages
+	offsets     map[string]map[int32]markedOffset
+	nextOffsets map[string]map[int32]int64
+	paused      map[string]map[int32]bool
+	errors      chan error
+	closing     chan struct{}
+	closed      bool
+	running     sync.WaitGroup
+}
+
+type markedOffset struct {
+	offset   int64
+	metadata string
+}
+
+// NewConsumerGroup returns a new mock ConsumerGroup instance. The t argument should
+// be the *testing.T instance of your test method. An error will be written to it if
+// an expectation is violated. The config argument can be set to nil.
+func NewConsumerGroup(t ErrorReporter, config *sarama.Config) *ConsumerGroup {
+	if config == nil {
+		config = sarama.NewConfig()
+	}
+
+	return &ConsumerGroup{
+		t:           t,
+		config:      config,
+		expected:    make(chan struct{}),
+		offsets:     make(map[string]map[int32]markedOffset),
+		nextOffsets: make(map[string]map[int32]int64),
+		paused:      make(map[string]map[int32]bool),
+		errors:      make(chan error, config.ChannelBufferSize),
+		closing:     make(chan struct{}),
+	}
+}
+
+///////////////////////////////////////////////////
+// ConsumerGroup interface implementation
+///////////////////////////////////////////////////
+
+// Consume implements the Consume method from the sarama.ConsumerGroup interface.
+// It runs the next expected session, whose claims must be part of topics.
+func (cg *ConsumerGroup) Consume(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) error {
+	if len(topics) == 0 {
+		return fmt.Errorf("no topics provided")
+	}
+	return cg.consume(ctx, handler, func(topic string) bool {
+		for _, t := range topics {
+			if t == topic {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// ConsumePattern implements the ConsumePattern method from the sarama.ConsumerGroup
+// interface. It runs the next expected session, whose claims must match pattern.
+func (cg *ConsumerGroup) ConsumePattern(ctx context.Context, pattern *regexp.Regexp, handler sarama.ConsumerGroupHandler) error {
+	if pattern == nil {
+		return sarama.ConfigurationError("ConsumePattern requires a pattern")
+	}
+	return cg.consume(ctx, handler, pattern.MatchString)
+}
+
+func (cg *ConsumerGroup) consume(ctx context.Context, handler sarama.ConsumerGroupHandler, subscribed func(topic string) bool) error {
+	if handler == nil {
+		return sarama.ConfigurationError("ConsumerGroupHandler cannot be nil")
+	}
+
+	cg.l.Lock()
+	for len(cg.sessions) == 0 || cg.closed {
+		if cg.closed {
+			cg.l.Unlock()
+			return sarama.ErrClosedConsumerGroup
+		}
+		expected := cg.expected
+		cg.l.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-cg.closing:
+			return sarama.ErrClosedConsumerGroup
+		case <-expected:
+		}
+		cg.l.Lock()
+	}
+
+	sess := cg.sessions[0]
+	cg.sessions = cg.sessions[1:]
+	cg.generation++
+	sess.start(ctx, cg.generation)
+	for topic := range sess.claims {
+		if !subscribed(topic) {
+			cg.t.Errorf("The session claims %s, which is not part of the subscription.", topic)
+		}
+	}
+	cg.running.Add(1)
+	cg.l.Unlock()
+
+	defer cg.running.Done()
+	return sess.run(handler)
+}
+
+// Errors implements the Errors method from the sarama.ConsumerGroup interface.
+func (cg *ConsumerGroup) Errors() <-chan error {
+	return cg.errors
+}
+
+// Close implements the Close method from the sarama.ConsumerGroup interface. It
+// ends the running session and reports the expected sessions which did not run,
+// and the messages which were not marked on a session expecting it.
+func (cg *ConsumerGroup) Close() error {
+	cg.l.Lock()
+	if cg.closed {
+		cg.l.Unlock()
+		return sarama.ErrClosedConsumerGroup
+	}
+	cg.closed = true
+	close(cg.closing)
+	if len(cg.sessions) > 0 {
+		cg.t.Errorf("Expected %d more sessions of the consumer group.", len(cg.sessions))
+	}
+	cg.l.Unlock()
+
+	cg.running.Wait()
+	close(cg.errors)
+	return nil
+}
+
+// CloseWithContext implements the CloseWithContext method from the
+// sarama.ConsumerGroup interface.
+func (cg *ConsumerGroup) CloseWithContext(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- cg.Close()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pause implements the Pause method from the sarama.ConsumerGroup interface. The
+// yielded messages of the paused partitions are delivered once they are resumed.
+func (cg *ConsumerGroup) Pause(partitions map[string][]int32) {
+	cg.l.Lock()
+	defer cg.l.Unlock()
+
+	for topic, ps := range partitions {
+		for _, partition := range ps {
+			if cg.paused[topic] == nil {
+				cg.paused[topic] = make(map[int32]bool)
+			}
+			cg.paused[topic][partition] = true
+		}
+	}
+}
+
+// Resume implements the Resume method from the sarama.ConsumerGroup interface.
+func (cg *ConsumerGroup) Resume(partitions map[string][]int32) {
+	cg.l.Lock()
+	defer cg.l.Unlock()
+
+	for topic, ps := range partitions {
+		for _, partition := range ps {
+			delete(cg.paused[topic], partition)
+		}
+	}
+	cg.notifyClaims()
+}
+
+// PauseAll implements the PauseAll method from the sarama.ConsumerGroup interface.
+// It pauses the partitions claimed by the running session.
+func (cg *ConsumerGroup) PauseAll() {
+	cg.l.Lock()
+	defer cg.l.Unlock()
+
+	if cg.current == nil {
+		return
+	}
+	for topic, partitions := range cg.current.claims {
+		for partition := range partitions {
+			if cg.paused[topic] == nil {
+				cg.paused[topic] = make(map[int32]bool)
+			}
+			cg.paused[topic][partition] = true
+		}
+	}
+}
+
+// ResumeAll implements the ResumeAll method from the sarama.ConsumerGroup interface.
+func (cg *ConsumerGroup) ResumeAll() {
+	cg.l.Lock()
+	defer cg.l.Unlock()
+
+	cg.paused = make(map[string]map[int32]bool)
+	cg.notifyClaims()
+}
+
+// Paused implements the Paused method from the sarama.ConsumerGroup interface.
+func (cg *ConsumerGroup) Paused() map[string][]int32 {
+	cg.l.Lock()
+	defer cg.l.Unlock()
+
+	paused := make(map[string][]int32)
+	for topic, partitions := range cg.paused {
+		for partition := range partitions {
+			paused[topic] = append(paused[topic], partition)
+		}
+	}
+	return paused
+}
+
+///////////////////////////////////////////////////
+// Expectation API
+///////////////////////////////////////////////////
+
+// ExpectSession registers the session run by the next call to Consume or
+// ConsumePattern which has no session yet, with the given claims. The registered
+// ConsumerGroupSession is returned, so you can yield messages on its claims and
+// set expectations on it using method chaining. The sessions run in the order
+// they are registered, the next one simulating the session following a rebalance.
+func (cg *ConsumerGroup) ExpectSession(claims map[string][]int32) *ConsumerGroupSession {
+	cg.l.Lock()
+	defer cg.l.Unlock()
+
+	sess := &ConsumerGroupSession{
+		group:  cg,
+		claims: make(map[string]map[int32]*consumerGroupClaim, len(claims)),
+		ended:  make(chan struct{}),
+	}
+	for topic, partitions := range claims {
+		sess.claims[topic] = make(map[int32]*consumerGroupClaim, len(partitions))
+		for _, partition := range partitions {
+			sess.claims[topic][partition] = &consumerGroupClaim{
+				session:   sess,
+				topic:     topic,
+				partition: partition,
+				last:      -1,
+				messages:  make(chan *sarama.ConsumerMessage),
+				notify:    make(chan struct{}, 1),
+			}
+		}
+	}
+	cg.sessions = append(cg.sessions, sess)
+
+	close(cg.expected)
+	cg.expected = make(chan struct{})
+	return sess
+}
+
+// YieldError will yield an error on the Errors channel of the consumer group. By
+// default, the mock consumer group will not verify whether this error was consumed
+// from the Errors channel, because there are legitimate reasons for this not to
+// happen.
+func (cg *ConsumerGroup) YieldError(err error) {
+	cg.l.Lock()
+	closed := cg.closed
+	cg.l.Unlock()
+
+	if closed {
+		cg.t.Errorf("Error yielded on a closed consumer group: %v", err)
+		return
+	}
+	cg.errors <- err
+}
+
+// MarkedOffset returns the offset and metadata marked for topic/partition by the
+// sessions, -1 if none was marked.
+func (cg *ConsumerGroup) MarkedOffset(topic string, partition int32) (int64, string) {
+	cg.l.Lock()
+	defer cg.l.Unlock()
+
+	marked, ok := cg.offsets[topic][partition]
+	if !ok {
+		return -1, ""
+	}
+	return marked.offset, marked.metadata
+}
+
+// handleError reports err, from ConsumeClaim, as the consumer group does. The
+// lock must not be held
+func (cg *ConsumerGroup) handleError(err error) {
+	if !cg.config.Consumer.Return.Errors {
+		sarama.Logger.Println(err)
+		return
+	}
+	select {
+	case cg.errors <- err:
+	default:
+	}
+}
+
+// notifyClaims wakes up the claims of the running session, e.g. on resume. The
+// lock must be held
+func (cg *ConsumerGroup) notifyClaims() {
+	if cg.current == nil {
+		return
+	}
+	for _, partitions := range cg.current.claims {
+		for _, claim := range partitions {
+			claim.wakeUp()
+		}
+	}
+}
+
+// nextOffset returns the offset of the next message yielded on topic/partition. The
+// lock must be held
+func (cg *ConsumerGroup) nextOffset(topic string, partition int32) int64 {
+	if cg.nextOffsets[topic] == nil {
+		cg.nextOffsets[topic] = make(map[int32]int64)
+	}
+	offset := cg.nextOffsets[topic][partition]
+	cg.nextOffsets[topic][partition] = offset + 1
+	return offset
+}
+
+///////////////////////////////////////////////////
+// ConsumerGroupSession mock type
+///////////////////////////////////////////////////
+
+// ConsumerGroupSession implements sarama's ConsumerGroupSession interface for testing
+// purposes. It is registered with the ConsumerGroup's ExpectSession method, and passed
+// to the handler once the session runs. Use YieldMessage to specify the messages its
+// claims deliver, and Rebalance or RebalanceWhenMarked to end it.
+type ConsumerGroupSession struct {
+	group      *ConsumerGroup
+	claims     map[string]map[int32]*consumerGroupClaim
+	generation int32
+	ctx        context.Context
+	cancel     context.CancelFunc
+	ended      chan struct{}
+
+	rebalance           bool
+	rebalanceWhenMarked bool
+	expectMarked        bool
+}
+
+///////////////////////////////////////////////////
+// ConsumerGroupSession interface implementation
+///////////////////////////////////////////////////
+
+// Claims implements the Claims method from the sarama.ConsumerGroupSession interface.
+func (s *ConsumerGroupSession) Claims() map[string][]int32 {
+	claims := make(map[string][]int32, len(s.claims))
+	for topic, partitions := range s.claims {
+		for partition := range partitions {
+			claims[topic] = append(claims[topic], partition)
+		}
+		sort.Slice(claims[topic], func(i, j int) bool { return claims[topic][i] < claims[topic][j] })
+	}
+	return claims
+}
+
+// MemberID implements the MemberID method from the sarama.ConsumerGroupSession interface.
+func (s *ConsumerGroupSession) MemberID() string {
+	return s.group.config.ClientID + "-mock-member"
+}
+
+// GenerationID implements the GenerationID method from the sarama.ConsumerGroupSession
+// interface. The generation is incremented by each session.
+func (s *ConsumerGroupSession) GenerationID() int32 {
+	s.group.l.Lock()
+	defer s.group.l.Unlock()
+
+	return s.generation
+}
+
+// MarkOffset implements the MarkOffset method from the sarama.ConsumerGroupSession
+// interface. As for a real session, the offset of a partition only moves forward.
+func (s *ConsumerGroupSession) MarkOffset(topic string, partition int32, offset int64, metadata string) {
+	s.setOffset(topic, partition, offset, metadata, false)
+}
+
+// Commit implements the Commit method from the sarama.ConsumerGroupSession interface.
+// The marked offsets are committed right away by the mock.
+func (s *ConsumerGroupSession) Commit() {}
+
+// CommitAsync implements the CommitAsync method from the sarama.ConsumerGroupSession
+// interface, calling callback without errors.
+func (s *ConsumerGroupSession) CommitAsync(callback func(map[sarama.TopicPartitionID]error)) {
+	if callback != nil {
+		go callback(make(map[sarama.TopicPartitionID]error))
+	}
+}
+
+// CommitSync implements the CommitSync method from the sarama.ConsumerGroupSession
+// interface, returning no errors.
+func (s *ConsumerGroupSession) CommitSync(ctx context.Context) (map[sarama.TopicPartitionID]error, error) {
+	return make(map[sarama.TopicPartitionID]error), nil
+}
+
+// ResetOffset implements the ResetOffset method from the sarama.ConsumerGroupSession
+// interface.
+func (s *ConsumerGroupSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {
+	s.setOffset(topic, partition, offset, metadata, true)
+}
+
+// MarkMessage implements the MarkMessage method from the sarama.ConsumerGroupSession
+// interface.
+func (s *ConsumerGroupSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.MarkOffset(msg.Topic, msg.Partition, msg.Offset+1, metadata)
+}
+
+// Context implements the Context method from the sarama.ConsumerGroupSession interface.
+// It is done once the session ends.
+func (s *ConsumerGroupSession) Context() context.Context {
+	s.group.l.Lock()
+	defer s.group.l.Unlock()
+
+	if s.ctx == nil {
+		s.group.t.Errorf("Context called on a session which did not start.")
+		return context.Background()
+	}
+	return s.ctx
+}
+
+///////////////////////////////////////////////////
+// Expectation API
+///////////////////////////////////////////////////
+
+// YieldMessage will yield msg on the Messages channel of the claim of msg.Topic and
+// msg.Partition, which must be claimed by the session, once the session runs. As
+// with the mock PartitionConsumer, the offsets of the messages of a partition are
+// assigned in sequence from 0, across the sessions. By default, the mock consumer
+// group will not verify whether this message was consumed, you can call
+// ExpectMessagesMarked so it will verify that it was marked.
+func (s *ConsumerGroupSession) YieldMessage(msg *sarama.ConsumerMessage) *ConsumerGroupSession {
+	cg := s.group
+	cg.l.Lock()
+	defer cg.l.Unlock()
+
+	claim := s.claims[msg.Topic][msg.Partition]
+	if claim == nil {
+		cg.t.Errorf("Message yielded on %s/%d, which is not claimed by the session.", msg.Topic, msg.Partition)
+		return s
+	}
+	if s.isEnded() {
+		cg.t.Errorf("Message yielded on %s/%d after the session ended.", msg.Topic, msg.Partition)
+		return s
+	}
+
+	msg.Offset = cg.nextOffset(msg.Topic, msg.Partition)
+	claim.queue = append(claim.queue, msg)
+	claim.last = msg.Offset
+	claim.wakeUp()
+	return s
+}
+
+// Rebalance simulates a rebalance of the group, ending the session as soon as it
+// runs: the context of the session is canceled and the Messages channels of its
+// claims are closed. The next call to Consume runs the next expected session.
+func (s *ConsumerGroupSession) Rebalance() {
+	s.group.l.Lock()
+	defer s.group.l.Unlock()
+
+	s.rebalance = true
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// RebalanceWhenMarked makes the session end, as with Rebalance, once all the
+// messages yielded on it are marked. A session without messages yielded before
+// it runs ends right away.
+func (s *ConsumerGroupSession) RebalanceWhenMarked() *ConsumerGroupSession {
+	s.group.l.Lock()
+	defer s.group.l.Unlock()
+
+	s.rebalanceWhenMarked = true
+	return s
+}
+
+// ExpectMessagesMarked sets an expectation on the session that all the messages
+// yielded on it are marked before it ends. If this expectation is not met, an error
+// is reported to the error reporter once the session ends.
+func (s *ConsumerGroupSession) ExpectMessagesMarked() *ConsumerGroupSession {
+	s.group.l.Lock()
+	defer s.group.l.Unlock()
+
+	s.expectMarked = true
+	return s
+}
+
+// Ended returns a channel closed once the session ended and the handler's Cleanup
+// hook returned.
+func (s *ConsumerGroupSession) Ended() <-chan struct{} {
+	return s.ended
+}
+
+// start starts the session of generation. The lock must be held
+func (s *ConsumerGroupSession) start(ctx context.Context, generation int32) {
+	cg := s.group
+	s.generation = generation
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	cg.current = s
+	for topic, partitions := range s.claims {
+		for partition, claim := range partitions {
+			claim.initialOffset = cg.config.Consumer.Offsets.Initial
+			if marked, ok := cg.offsets[topic][partition]; ok {
+				claim.initialOffset = marked.offset
+			}
+		}
+	}
+	if s.rebalance || (s.rebalanceWhenMarked && s.allMarked()) {
+		s.cancel()
+	}
+}
+
+// run runs the session through handler until it ends
+func (s *ConsumerGroupSession) run(handler sarama.ConsumerGroupHandler) error {
+	cg := s.group
+	defer func() {
+		cg.l.Lock()
+		if s.expectMarked && !s.allMarked() {
+			cg.t.Errorf("Expected all the messages of generation %d to be marked.", s.generation)
+		}
+		cg.current = nil
+		cg.l.Unlock()
+		close(s.ended)
+	}()
+	go func() {
+		select {
+		case <-cg.closing:
+			s.cancel()
+		case <-s.ctx.Done():
+		}
+	}()
+
+	if err := handler.Setup(s); err != nil {
+		s.cancel()
+		_ = handler.Cleanup(s)
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for _, partitions := range s.claims {
+		for _, claim := range partitions {
+			wg.Add(2)
+			go func(claim *consumerGroupClaim) {
+				defer wg.Done()
+				claim.feed()
+			}(claim)
+			go func(claim *consumerGroupClaim) {
+				defer wg.Done()
+				// the session ends as soon as one of the claims is released
+				defer s.cancel()
+				if err := handler.ConsumeClaim(s, claim); err != nil {
+					cg.handleError(err)
+				}
+			}(claim)
+		}
+	}
+	<-s.ctx.Done()
+	wg.Wait()
+
+	return handler.Cleanup(s)
+}
+
+// setOffset marks offset for topic/partition, only forward unless reset. The lock
+// must not be held
+func (s *ConsumerGroupSession) setOffset(topic string, partition int32, offset int64, metadata string, reset bool) {
+	cg := s.group
+	cg.l.Lock()
+	defer cg.l.Unlock()
+
+	if s.claims[topic][partition] == nil {
+		cg.t.Errorf("Offset marked for %s/%d, which is not claimed by the session.", topic, partition)
+		return
+	}
+	if cg.offsets[topic] == nil {
+		cg.offsets[topic] = make(map[int32]markedOffset)
+	}
+	if marked, ok := cg.offsets[topic][partition]; ok && !reset && offset <= marked.offset {
+		return
+	}
+	cg.offsets[topic][partition] = markedOffset{offset: offset, metadata: metadata}
+
+	if s.rebalanceWhenMarked && s.cancel != nil && s.allMarked() {
+		s.cancel()
+	}
+}
+
+// allMarked returns whether all the messages yielded on the session are marked.
+// The lock must be held
+func (s *ConsumerGroupSession) allMarked() bool {
+	for topic, partitions := range s.claims {
+		for partition, claim := range partitions {
+			if claim.last < 0 {
+				continue
+			}
+			if marked, ok := s.group.offsets[topic][partition]; !ok || marked.offset <= claim.last {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isEnded returns whether the session ended. The lock must be held
+func (s *ConsumerGroupSession) isEnded() bool {
+	return s.ctx != nil && s.ctx.Err() != nil
+}
+
+///////////////////////////////////////////////////
+// ConsumerGroupClaim mock type
+///////////////////////////////////////////////////
+
+// consumerGroupClaim implements sarama's ConsumerGroupClaim interface for a
+// partition of a ConsumerGroupSession
+type consumerGroupClaim struct {
+	session       *ConsumerGroupSession
+	topic         string
+	partition     int32
+	initialOffset int64
+	messages      chan *sarama.ConsumerMessage
+	// queue holds the yielded messages to deliver, last is the offset of the
+	// last one, -1 if none, both guarded by the lock of the group
+	queue  []*sarama.ConsumerMessage
+	last   int64
+	notify chan struct{}
+}
+
+func (c *consumerGroupClaim) Topic() string {
+	return c.topic
+}
+
+func (c *consumerGroupClaim) Partition() int32 {
+	return c.partition
+}
+
+func (c *consumerGroupClaim) InitialOffset() int64 {
+	return c.initialOffset
+}
+
+// HighWaterMarkOffset returns the offset of the next message yielded on the
+// partition.
+func (c *consumerGroupClaim) HighWaterMarkOffset() int64 {
+	cg := c.session.group
+	cg.l.Lock()
+	defer cg.l.Unlock()
+
+	return cg.nextOffsets[c.topic][c.partition]
+}
+
+func (c *consumerGroupClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return c.messages
+}
+
+func (c *consumerGroupClaim) Context() context.Context {
+	return c.session.ctx
+}
+
+// feed delivers the yielded messages until the session ends, closing the
+// Messages channel then
+func (c *consumerGroupClaim) feed() {
+	defer close(c.messages)
+
+	cg := c.session.group
+	ctx := c.session.ctx
+	for {
+		cg.l.Lock()
+		if len(c.queue) == 0 || cg.paused[c.topic][c.partition] {
+			cg.l.Unlock()
+			select {
+			case <-c.notify:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		msg := c.queue[0]
+		c.queue = c.queue[1:]
+		cg.l.Unlock()
+
+		select {
+		case c.messages <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// wakeUp notifies feed of a new message or of a resume
+func (c *consumerGroupClaim) wakeUp() {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,231 @@
+package mocks
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+type testConsumerGroupHandler struct {
+	lock     sync.Mutex
+	setups   int
+	cleanups int
+	values   []string
+	mark     bool
+	setupErr error
+}
+
+func (h *testConsumerGroupHandler) Setup(sarama.ConsumerGroupSession) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.setups++
+	return h.setupErr
+}
+
+func (h *testConsumerGroupHandler) Cleanup(sarama.ConsumerGroupSession) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.cleanups++
+	return nil
+}
+
+func (h *testConsumerGroupHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		h.lock.Lock()
+		h.values = append(h.values, string(msg.Value))
+		h.lock.Unlock()
+		if h.mark {
+			sess.MarkMessage(msg, "")
+		}
+	}
+	return nil
+}
+
+func TestMockConsumerGroupImplementsConsumerGroupInterface(t *testing.T) {
+	var cg interface{} = &ConsumerGroup{}
+	if _, ok := cg.(sarama.ConsumerGroup); !ok {
+		t.Error("The mock consumer group should implement the sarama.ConsumerGroup interface.")
+	}
+
+	var sess interface{} = &ConsumerGroupSession{}
+	if _, ok := sess.(sarama.ConsumerGroupSession); !ok {
+		t.Error("The mock consumer group session should implement the sarama.ConsumerGroupSession interface.")
+	}
+}
+
+func TestConsumerGroupRunsSessions(t *testing.T) {
+	group := NewConsumerGroup(t, NewTestConfig())
+	group.ExpectSession(map[string][]int32{"test": {0, 1}}).
+		YieldMessage(&sarama.ConsumerMessage{Topic: "test", Partition: 0, Value: []byte("a")}).
+		YieldMessage(&sarama.ConsumerMessage{Topic: "test", Partition: 1, Value: []byte("b")}).
+		RebalanceWhenMarked().
+		ExpectMessagesMarked()
+	second := group.ExpectSession(map[string][]int32{"test": {0}}).
+		YieldMessage(&sarama.ConsumerMessage{Topic: "test", Partition: 0, Value: []byte("c")}).
+		RebalanceWhenMarked()
+
+	handler := &testConsumerGroupHandler{mark: true}
+	for i := 0; i < 2; i++ {
+		if err := group.Consume(context.Background(), []string{"test"}, handler); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-second.Ended()
+
+	if handler.setups != 2 || handler.cleanups != 2 {
+		t.Errorf("Expected 2 setups and cleanups, got %d and %d", handler.setups, handler.cleanups)
+	}
+	if len(handler.values) != 3 || handler.values[2] != "c" {
+		t.Errorf("Unexpected messages consumed: %v", handler.values)
+	}
+	if gen := second.GenerationID(); gen != 2 {
+		t.Errorf("Expected the second session to be generation 2, got %d", gen)
+	}
+	if offset, _ := group.MarkedOffset("test", 0); offset != 2 {
+		t.Errorf("Expected offset 2 to be marked for test/0, got %d", offset)
+	}
+	if offset, _ := group.MarkedOffset("test", 1); offset != 1 {
+		t.Errorf("Expected offset 1 to be marked for test/1, got %d", offset)
+	}
+
+	if err := group.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := group.Close(); err != sarama.ErrClosedConsumerGroup {
+		t.Error("Expected the second close to fail, got", err)
+	}
+}
+
+func TestConsumerGroupInitialOffsetIsMarkedOffset(t *testing.T) {
+	group := NewConsumerGroup(t, NewTestConfig())
+	group.ExpectSession(map[string][]int32{"test": {0}}).
+		YieldMessage(&sarama.ConsumerMessage{Topic: "test", Partition: 0}).
+		RebalanceWhenMarked()
+	group.ExpectSession(map[string][]int32{"test": {0}}).Rebalance()
+
+	var offsets []int64
+	handler := &claimFuncHandler{func(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+		offsets = append(offsets, claim.InitialOffset())
+		for msg := range claim.Messages() {
+			sess.MarkMessage(msg, "")
+		}
+		return nil
+	}}
+	for i := 0; i < 2; i++ {
+		if err := group.Consume(context.Background(), []string{"test"}, handler); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(offsets) != 2 || offsets[0] != sarama.OffsetNewest || offsets[1] != 1 {
+		t.Errorf("Expected the initial offsets to be Consumer.Offsets.Initial then the marked offset, got %v", offsets)
+	}
+
+	if err := group.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConsumerGroupReportsUnmarkedMessages(t *testing.T) {
+	trm := newTestReporterMock()
+	group := NewConsumerGroup(trm, NewTestConfig())
+	sess := group.ExpectSession(map[string][]int32{"test": {0}}).
+		YieldMessage(&sarama.ConsumerMessage{Topic: "test", Partition: 0}).
+		ExpectMessagesMarked()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := &claimFuncHandler{func(_ sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+		<-claim.Messages()
+		cancel()
+		return nil
+	}}
+	if err := group.Consume(ctx, []string{"test"}, handler); err != nil {
+		t.Fatal(err)
+	}
+	<-sess.Ended()
+
+	if err := group.Close(); err != nil {
+		t.Error(err)
+	}
+	if len(trm.errors) != 1 {
+		t.Errorf("Expected to report the unmarked message, got %v", trm.errors)
+	}
+}
+
+func TestConsumerGroupReportsUnclaimedPartitions(t *testing.T) {
+	trm := newTestReporterMock()
+	group := NewConsumerGroup(trm, NewTestConfig())
+	group.ExpectSession(map[string][]int32{"test": {0}}).
+		YieldMessage(&sarama.ConsumerMessage{Topic: "test", Partition: 1})
+	group.ExpectSession(map[string][]int32{"other": {0}})
+
+	if err := group.Close(); err != nil {
+		t.Error(err)
+	}
+	if len(trm.errors) != 2 {
+		t.Errorf("Expected to report the unclaimed partition and the sessions which did not run, got %v", trm.errors)
+	}
+}
+
+func TestConsumerGroupSetupError(t *testing.T) {
+	group := NewConsumerGroup(t, NewTestConfig())
+	group.ExpectSession(map[string][]int32{"test": {0}})
+
+	setupErr := errors.New("setup failed")
+	handler := &testConsumerGroupHandler{setupErr: setupErr}
+	if err := group.Consume(context.Background(), []string{"test"}, handler); err != setupErr {
+		t.Error("Expected the error of Setup, got", err)
+	}
+	if handler.cleanups != 1 {
+		t.Error("Expected Cleanup to be called after Setup failed")
+	}
+
+	if err := group.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConsumerGroupCloseEndsSession(t *testing.T) {
+	config := NewTestConfig()
+	config.Consumer.Return.Errors = true
+	group := NewConsumerGroup(t, config)
+	group.ExpectSession(map[string][]int32{"test": {0}})
+
+	claimErr := errors.New("claim failed")
+	started := make(chan struct{})
+	handler := &claimFuncHandler{func(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+		close(started)
+		<-sess.Context().Done()
+		return claimErr
+	}}
+	done := make(chan error)
+	go func() {
+		done <- group.Consume(context.Background(), []string{"test"}, handler)
+	}()
+	<-started
+
+	if err := group.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+	if err := <-group.Errors(); err != claimErr {
+		t.Error("Expected the error of ConsumeClaim, got", err)
+	}
+	if err := group.Consume(context.Background(), []string{"test"}, handler); err != sarama.ErrClosedConsumerGroup {
+		t.Error("Expected Consume to fail once closed, got", err)
+	}
+}
+
+type claimFuncHandler struct {
+	consumeClaim func(sarama.ConsumerGroupSession, sarama.ConsumerGroupClaim) error
+}
+
+func (h *claimFuncHandler) Setup(sarama.ConsumerGroupSession) error   { return nil }
+func (h *claimFuncHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+func (h *claimFuncHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	return h.consumeClaim(sess, claim)
+}
@@ -14,8 +14,10 @@ guarantee of Sarama as it is still considered experimental.
 package mocks
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/Shopify/sarama"
 )
@@ -50,6 +52,100 @@ func messageValueChecker(f ValueChecker) MessageChecker {
 	}
 }
 
+// MatchAll combines MessageCheckers into one, which checks the message with
+// each of them in turn and returns the first error.
+func MatchAll(checkers ...MessageChecker) MessageChecker {
+	return func(msg *sarama.ProducerMessage) error {
+		for _, check := range checkers {
+			if check == nil {
+				continue
+			}
+			if err := check(msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// MatchTopic returns a MessageChecker which checks the topic of the message.
+func MatchTopic(topic string) MessageChecker {
+	return func(msg *sarama.ProducerMessage) error {
+		if msg.Topic != topic {
+			return fmt.Errorf("Expected topic %q, got %q", topic, msg.Topic)
+		}
+		return nil
+	}
+}
+
+// MatchPartition returns a MessageChecker which checks the partition the
+// message was assigned by the partitioner.
+func MatchPartition(partition int32) MessageChecker {
+	return func(msg *sarama.ProducerMessage) error {
+		if msg.Partition != partition {
+			return fmt.Errorf("Expected partition %d, got %d", partition, msg.Partition)
+		}
+		return nil
+	}
+}
+
+// MatchKey returns a MessageChecker which calls f with the encoded key of the
+// message, nil if it has none.
+func MatchKey(f ValueChecker) MessageChecker {
+	return func(msg *sarama.ProducerMessage) error {
+		var key []byte
+		if msg.Key != nil {
+			var err error
+			if key, err = msg.Key.Encode(); err != nil {
+				return fmt.Errorf("Input message key encoding failed: %s", err.Error())
+			}
+		}
+		return f(key)
+	}
+}
+
+// MatchValue returns a MessageChecker which calls f with the encoded value of
+// the message, nil if it has none.
+func MatchValue(f ValueChecker) MessageChecker {
+	return func(msg *sarama.ProducerMessage) error {
+		if msg.Value == nil {
+			return f(nil)
+		}
+		return messageValueChecker(f)(msg)
+	}
+}
+
+// MatchHeader returns a MessageChecker which calls f with the value of the
+// first header of the message with the given key, and fails if there is none.
+func MatchHeader(key string, f ValueChecker) MessageChecker {
+	return func(msg *sarama.ProducerMessage) error {
+		for _, header := range msg.Headers {
+			if string(header.Key) == key {
+				return f(header.Value)
+			}
+		}
+		return fmt.Errorf("Expected header %q, got none", key)
+	}
+}
+
+// MatchTimestamp returns a MessageChecker which calls f with the timestamp of
+// the message.
+func MatchTimestamp(f func(time.Time) error) MessageChecker {
+	return func(msg *sarama.ProducerMessage) error {
+		return f(msg.Timestamp)
+	}
+}
+
+// Equals returns a ValueChecker which checks the value is equal to expected.
+func Equals(expected []byte) ValueChecker {
+	return func(val []byte) error {
+		if !bytes.Equal(val, expected) {
+			return fmt.Errorf("Expected %q, got %q", expected, val)
+		}
+		return nil
+	}
+}
+
 var (
 	errProduceSuccess              error = nil
 	errOutOfExpectations                 = errors.New("No more expectations set on mock")
@@ -15,6 +15,7 @@ type SyncProducer struct {
 	t            ErrorReporter
 	expectations []*producerExpectation
 	lastOffset   int64
+	produced     []*sarama.ProducerMessage
 
 	*TopicConfig
 	newPartitioner sarama.PartitionerConstructor
@@ -71,6 +72,7 @@ func (sp *SyncProducer) SendMessage(msg *sarama.ProducerMessage) (partition int3
 		if expectation.Result == errProduceSuccess {
 			sp.lastOffset++
 			msg.Offset = sp.lastOffset
+			sp.produced = append(sp.produced, msg)
 			return 0, msg.Offset, nil
 		}
 		return -1, -1, expectation.Result
@@ -111,6 +113,7 @@ func (sp *SyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
 			}
 			sp.lastOffset++
 			msgs[i].Offset = sp.lastOffset
+			sp.produced = append(sp.produced, msgs[i])
 		}
 		return nil
 	}
@@ -141,6 +144,15 @@ func (sp *SyncProducer) Close() error {
 	return nil
 }
 
+// Messages returns the messages the mock producer handled as produced
+// successfully so far, in the order they were sent, so that a test can
+// inspect them once it is done producing.
+func (sp *SyncProducer) Messages() []*sarama.ProducerMessage {
+	sp.l.Lock()
+	defer sp.l.Unlock()
+	return append([]*sarama.ProducerMessage(nil), sp.produced...)
+}
+
 ////////////////////////////////////////////////
 // Setting expectations
 ////////////////////////////////////////////////
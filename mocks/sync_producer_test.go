@@ -4,6 +4,7 @@ import (
 	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Shopify/sarama"
 )
@@ -255,3 +256,52 @@ func (f faultyEncoder) Encode() ([]byte, error) {
 func (f faultyEncoder) Length() int {
 	return len(f)
 }
+
+func TestSyncProducerWithMatchers(t *testing.T) {
+	trm := newTestReporterMock()
+
+	config := NewTestConfig()
+	config.Producer.Partitioner = sarama.NewManualPartitioner
+	sp := NewSyncProducer(trm, config)
+	sp.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(MatchAll(
+		MatchTopic("test"),
+		MatchPartition(3),
+		MatchKey(Equals([]byte("key"))),
+		MatchValue(Equals([]byte("value"))),
+		MatchHeader("trace", Equals([]byte("abc"))),
+		MatchTimestamp(func(ts time.Time) error {
+			if !ts.Equal(time.Unix(1600000000, 0)) {
+				return errors.New("unexpected timestamp")
+			}
+			return nil
+		}),
+	))
+	sp.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(MatchHeader("trace", Equals([]byte("abc"))))
+
+	msg := &sarama.ProducerMessage{
+		Topic:     "test",
+		Partition: 3,
+		Key:       sarama.StringEncoder("key"),
+		Value:     sarama.StringEncoder("value"),
+		Headers:   []sarama.RecordHeader{{Key: []byte("trace"), Value: []byte("abc")}},
+		Timestamp: time.Unix(1600000000, 0),
+	}
+	if _, _, err := sp.SendMessage(msg); err != nil {
+		t.Error("No error expected on first SendMessage call", err)
+	}
+	if _, _, err := sp.SendMessage(&sarama.ProducerMessage{Topic: "test"}); err == nil || !strings.HasPrefix(err.Error(), "Expected header") {
+		t.Error("Expected a missing header error, got", err)
+	}
+
+	if err := sp.Close(); err != nil {
+		t.Error(err)
+	}
+	if len(trm.errors) != 1 {
+		t.Errorf("Expected to report 1 error, got %v", trm.errors)
+	}
+
+	messages := sp.Messages()
+	if len(messages) != 1 || messages[0] != msg || messages[0].Offset != 1 {
+		t.Errorf("Expected the first message to be captured, got %v", messages)
+	}
+}
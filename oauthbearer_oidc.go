@@ -0,0 +1,199 @@
+package sarama
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDCTokenProviderConfig configures an OIDCTokenProvider.
+type OIDCTokenProviderConfig struct {
+	// TokenEndpoint is the URL of the token endpoint of the OIDC provider.
+	TokenEndpoint string
+	// ClientID and ClientSecret are the credentials of the client, sent with
+	// HTTP basic authentication.
+	ClientID     string
+	ClientSecret string
+	// Scopes are the scopes requested for the token, if any.
+	Scopes []string
+	// Extensions are the SASL extensions sent with the token, for example
+	// the logical cluster and identity pool of Confluent Cloud.
+	Extensions map[string]string
+	// RefreshWindowFactor is the fraction of the lifetime of a token after
+	// which a new one is requested, ahead of its expiry (default 0.8).
+	// Similar to `sasl.login.refresh.window.factor` in the JVM client.
+	RefreshWindowFactor float64
+	// Timeout bounds each request to the token endpoint (default 10s).
+	Timeout time.Duration
+	// HTTPClient sends the requests to the token endpoint, for example to
+	// customize TLS (default http.DefaultClient).
+	HTTPClient *http.Client
+}
+
+// OIDCTokenProvider is an AccessTokenProvider getting tokens from an OIDC
+// provider with the OAuth2 client credentials grant, as in KIP-768. Tokens are
+// cached and a new one is requested once RefreshWindowFactor of the lifetime
+// of the current one is elapsed. The current token keeps being used while it
+// is valid if the token endpoint fails.
+type OIDCTokenProvider struct {
+	conf OIDCTokenProviderConfig
+
+	lock      sync.Mutex
+	token     *AccessToken
+	refreshAt time.Time
+	expiresAt time.Time
+}
+
+// NewOIDCTokenProvider returns an OIDCTokenProvider with the given
+// configuration, tokens are only requested when needed.
+func NewOIDCTokenProvider(conf OIDCTokenProviderConfig) (*OIDCTokenProvider, error) {
+	if conf.TokenEndpoint == "" {
+		return nil, ConfigurationError("OIDCTokenProviderConfig.TokenEndpoint must be set")
+	}
+	if _, err := url.Parse(conf.TokenEndpoint); err != nil {
+		return nil, ConfigurationError(fmt.Sprintf("OIDCTokenProviderConfig.TokenEndpoint is not a valid URL: %v", err))
+	}
+	if conf.ClientID == "" {
+		return nil, ConfigurationError("OIDCTokenProviderConfig.ClientID must be set")
+	}
+	if conf.RefreshWindowFactor == 0 {
+		conf.RefreshWindowFactor = 0.8
+	}
+	if conf.RefreshWindowFactor < 0 || conf.RefreshWindowFactor > 1 {
+		return nil, ConfigurationError("OIDCTokenProviderConfig.RefreshWindowFactor must be between 0 and 1")
+	}
+	if conf.Timeout == 0 {
+		conf.Timeout = 10 * time.Second
+	}
+	if conf.HTTPClient == nil {
+		conf.HTTPClient = http.DefaultClient
+	}
+	return &OIDCTokenProvider{conf: conf}, nil
+}
+
+// Token implements AccessTokenProvider.
+func (p *OIDCTokenProvider) Token() (*AccessToken, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := time.Now()
+	if p.token != nil && now.Before(p.refreshAt) {
+		return p.token, nil
+	}
+
+	token, expiresIn, err := p.requestToken()
+	if err != nil {
+		if p.token != nil && now.Before(p.expiresAt) {
+			Logger.Printf("failed to refresh the OAuth token, using the current one until it expires: %v\n", err)
+			return p.token, nil
+		}
+		return nil, err
+	}
+
+	p.token = &AccessToken{Token: token, Extensions: p.conf.Extensions}
+	p.expiresAt = now.Add(expiresIn)
+	p.refreshAt = now.Add(time.Duration(float64(expiresIn) * p.conf.RefreshWindowFactor))
+	return p.token, nil
+}
+
+// oidcTokenResponse is the successful response of a token endpoint, RFC 6749
+// section 5.1
+type oidcTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// oidcErrorResponse is the error response of a token endpoint, RFC 6749
+// section 5.2
+type oidcErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// requestToken requests a token with the client credentials grant and
+// returns it with its lifetime
+func (p *OIDCTokenProvider) requestToken() (string, time.Duration, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(p.conf.Scopes) > 0 {
+		form.Set("scope", strings.Join(p.conf.Scopes, " "))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.conf.Timeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodPost, p.conf.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// the credentials are form encoded before being used for basic
+	// authentication, RFC 6749 section 2.3.1
+	req.SetBasicAuth(url.QueryEscape(p.conf.ClientID), url.QueryEscape(p.conf.ClientSecret))
+
+	resp, err := p.conf.HTTPClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to request an OAuth token: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read the OAuth token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp oidcErrorResponse
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+			return "", 0, fmt.Errorf("failed to request an OAuth token: %s: %s %s", resp.Status, errResp.Error, errResp.ErrorDescription)
+		}
+		return "", 0, fmt.Errorf("failed to request an OAuth token: %s", resp.Status)
+	}
+
+	var tokenResp oidcTokenResponse
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", 0, fmt.Errorf("failed to decode the OAuth token response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return "", 0, errors.New("the OAuth token response has no access_token")
+	}
+	if tokenResp.TokenType != "" && !strings.EqualFold(tokenResp.TokenType, "bearer") {
+		return "", 0, fmt.Errorf("the OAuth token response has an unsupported token_type %s", tokenResp.TokenType)
+	}
+	if tokenResp.ExpiresIn > 0 {
+		return tokenResp.AccessToken, time.Duration(tokenResp.ExpiresIn) * time.Second, nil
+	}
+	// expires_in is optional, fall back to the expiry of JWT tokens
+	if expiry, ok := jwtExpiry(tokenResp.AccessToken); ok {
+		return tokenResp.AccessToken, time.Until(expiry), nil
+	}
+	return "", 0, errors.New("the OAuth token response has no expires_in and the token has no exp claim")
+}
+
+// jwtExpiry returns the exp claim of a JWT, without verifying it
+func jwtExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
@@ -0,0 +1,151 @@
+package sarama
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestTokenEndpoint(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, request int32)) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := atomic.AddInt32(&requests, 1)
+		if r.Method != http.MethodPost {
+			t.Errorf("expected a POST request, got %s", r.Method)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		if grantType := r.PostForm.Get("grant_type"); grantType != "client_credentials" {
+			t.Errorf("expected the client_credentials grant, got %s", grantType)
+		}
+		if user, password, ok := r.BasicAuth(); !ok || user != "client%3A1" || password != "secret" {
+			t.Errorf("unexpected client credentials %s %s", user, password)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		handler(w, r, request)
+	}))
+	return server, &requests
+}
+
+func TestOIDCTokenProvider(t *testing.T) {
+	server, requests := newTestTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request, request int32) {
+		if scope := r.PostForm.Get("scope"); scope != "kafka profile" {
+			t.Errorf("expected the scopes to be requested, got %q", scope)
+		}
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, request)
+	})
+	defer server.Close()
+
+	provider, err := NewOIDCTokenProvider(OIDCTokenProviderConfig{
+		TokenEndpoint: server.URL,
+		ClientID:      "client:1",
+		ClientSecret:  "secret",
+		Scopes:        []string{"kafka", "profile"},
+		Extensions:    map[string]string{"logicalCluster": "lkc-1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		token, err := provider.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.Token != "token-1" || token.Extensions["logicalCluster"] != "lkc-1" {
+			t.Errorf("unexpected token %+v", token)
+		}
+	}
+	if atomic.LoadInt32(requests) != 1 {
+		t.Errorf("expected the token to be cached, got %d requests", atomic.LoadInt32(requests))
+	}
+
+	// the token is refreshed ahead of its expiry
+	provider.refreshAt = time.Now().Add(-time.Second)
+	token, err := provider.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Token != "token-2" {
+		t.Errorf("expected a refreshed token, got %s", token.Token)
+	}
+}
+
+func TestOIDCTokenProviderFailures(t *testing.T) {
+	var fail int32
+	server, _ := newTestTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request, request int32) {
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client","error_description":"unknown client"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"token","token_type":"Bearer","expires_in":3600}`)
+	})
+	defer server.Close()
+
+	provider, err := NewOIDCTokenProvider(OIDCTokenProviderConfig{
+		TokenEndpoint: server.URL,
+		ClientID:      "client:1",
+		ClientSecret:  "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Token(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the current token is used until it expires
+	atomic.StoreInt32(&fail, 1)
+	provider.refreshAt = time.Now().Add(-time.Second)
+	if token, err := provider.Token(); err != nil || token.Token != "token" {
+		t.Errorf("expected the current token, got %v %v", token, err)
+	}
+
+	provider.expiresAt = time.Now().Add(-time.Second)
+	if _, err := provider.Token(); err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Errorf("expected the error of the token endpoint, got %v", err)
+	}
+}
+
+func TestOIDCTokenProviderJWTExpiry(t *testing.T) {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":"client","exp":%d}`, time.Now().Add(time.Hour).Unix())))
+	jwt := "eyJhbGciOiJub25lIn0." + payload + ".signature"
+	server, _ := newTestTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request, request int32) {
+		fmt.Fprintf(w, `{"access_token":%q,"token_type":"bearer"}`, jwt)
+	})
+	defer server.Close()
+
+	provider, err := NewOIDCTokenProvider(OIDCTokenProviderConfig{
+		TokenEndpoint: server.URL,
+		ClientID:      "client:1",
+		ClientSecret:  "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if lifetime := time.Until(provider.expiresAt); lifetime < 59*time.Minute || lifetime > time.Hour {
+		t.Errorf("expected the token to expire in an hour, got %v", lifetime)
+	}
+}
+
+func TestNewOIDCTokenProviderValidation(t *testing.T) {
+	for _, conf := range []OIDCTokenProviderConfig{
+		{ClientID: "client"},
+		{TokenEndpoint: "https://idp.example.com/token"},
+		{TokenEndpoint: "https://idp.example.com/token", ClientID: "client", RefreshWindowFactor: 1.5},
+	} {
+		if _, err := NewOIDCTokenProvider(conf); err == nil {
+			t.Errorf("expected %+v to be rejected", conf)
+		}
+	}
+}
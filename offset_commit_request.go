@@ -13,9 +13,10 @@ const ReceiveTime int64 = -1
 const GroupGenerationUndefined = -1
 
 type offsetCommitRequestBlock struct {
-	offset    int64
-	timestamp int64
-	metadata  string
+	offset               int64
+	timestamp            int64
+	committedLeaderEpoch int32
+	metadata             string
 }
 
 func (b *offsetCommitRequestBlock) encode(pe packetEncoder, version int16) error {
@@ -25,7 +26,17 @@ func (b *offsetCommitRequestBlock) encode(pe packetEncoder, version int16) error
 	} else if b.timestamp != 0 {
 		Logger.Println("Non-zero timestamp specified for OffsetCommitRequest not v1, it will be ignored")
 	}
+	if version >= 6 {
+		pe.putInt32(b.committedLeaderEpoch)
+	}
 
+	if version >= 8 {
+		if err := pe.putCompactString(b.metadata); err != nil {
+			return err
+		}
+		pe.putEmptyTaggedFieldArray()
+		return nil
+	}
 	return pe.putString(b.metadata)
 }
 
@@ -38,15 +49,30 @@ func (b *offsetCommitRequestBlock) decode(pd packetDecoder, version int16) (err
 			return err
 		}
 	}
+	b.committedLeaderEpoch = -1
+	if version >= 6 {
+		if b.committedLeaderEpoch, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+
+	if version >= 8 {
+		if b.metadata, err = pd.getCompactString(); err != nil {
+			return err
+		}
+		_, err = pd.getEmptyTaggedFieldArray()
+		return err
+	}
 	b.metadata, err = pd.getString()
 	return err
 }
 
 type OffsetCommitRequest struct {
 	ConsumerGroup           string
-	ConsumerGroupGeneration int32  // v1 or later
-	ConsumerID              string // v1 or later
-	RetentionTime           int64  // v2 or later
+	ConsumerGroupGeneration int32   // v1 or later
+	ConsumerID              string  // v1 or later
+	GroupInstanceId         *string // v7 or later
+	RetentionTime           int64   // v2 to v4
 
 	// Version can be:
 	// - 0 (kafka 0.8.1 and later)
@@ -54,22 +80,27 @@ type OffsetCommitRequest struct {
 	// - 2 (kafka 0.9.0 and later)
 	// - 3 (kafka 0.11.0 and later)
 	// - 4 (kafka 2.0.0 and later)
+	// - 5 & 6 (kafka 2.1.0 and later)
+	// - 7 (kafka 2.3.0 and later)
+	// - 8 (kafka 2.4.0 and later)
+	// - 9 (kafka 3.7.0 and later, required by the consumer group protocol of KIP-848)
 	Version int16
 	blocks  map[string]map[int32]*offsetCommitRequestBlock
 }
 
 func (r *OffsetCommitRequest) encode(pe packetEncoder) error {
-	if r.Version < 0 || r.Version > 4 {
+	if r.Version < 0 || r.Version > 9 {
 		return PacketEncodingError{"invalid or unsupported OffsetCommitRequest version field"}
 	}
+	isFlexible := r.Version >= 8
 
-	if err := pe.putString(r.ConsumerGroup); err != nil {
+	if err := r.putString(pe, r.ConsumerGroup); err != nil {
 		return err
 	}
 
 	if r.Version >= 1 {
 		pe.putInt32(r.ConsumerGroupGeneration)
-		if err := pe.putString(r.ConsumerID); err != nil {
+		if err := r.putString(pe, r.ConsumerID); err != nil {
 			return err
 		}
 	} else {
@@ -81,20 +112,32 @@ func (r *OffsetCommitRequest) encode(pe packetEncoder) error {
 		}
 	}
 
-	if r.Version >= 2 {
+	if r.Version >= 7 {
+		if isFlexible {
+			if err := pe.putNullableCompactString(r.GroupInstanceId); err != nil {
+				return err
+			}
+		} else if err := pe.putNullableString(r.GroupInstanceId); err != nil {
+			return err
+		}
+	} else if r.GroupInstanceId != nil {
+		Logger.Println("Non-nil GroupInstanceId specified for OffsetCommitRequest version <7, it will be ignored")
+	}
+
+	if r.Version >= 2 && r.Version <= 4 {
 		pe.putInt64(r.RetentionTime)
 	} else if r.RetentionTime != 0 {
-		Logger.Println("Non-zero RetentionTime specified for OffsetCommitRequest version <2, it will be ignored")
+		Logger.Println("Non-zero RetentionTime specified for OffsetCommitRequest version <2 or >4, it will be ignored")
 	}
 
-	if err := pe.putArrayLength(len(r.blocks)); err != nil {
+	if err := r.putArrayLength(pe, len(r.blocks)); err != nil {
 		return err
 	}
 	for topic, partitions := range r.blocks {
-		if err := pe.putString(topic); err != nil {
+		if err := r.putString(pe, topic); err != nil {
 			return err
 		}
-		if err := pe.putArrayLength(len(partitions)); err != nil {
+		if err := r.putArrayLength(pe, len(partitions)); err != nil {
 			return err
 		}
 		for partition, block := range partitions {
@@ -103,14 +146,36 @@ func (r *OffsetCommitRequest) encode(pe packetEncoder) error {
 				return err
 			}
 		}
+		if isFlexible {
+			pe.putEmptyTaggedFieldArray()
+		}
+	}
+	if isFlexible {
+		pe.putEmptyTaggedFieldArray()
 	}
 	return nil
 }
 
+func (r *OffsetCommitRequest) putString(pe packetEncoder, in string) error {
+	if r.Version >= 8 {
+		return pe.putCompactString(in)
+	}
+	return pe.putString(in)
+}
+
+func (r *OffsetCommitRequest) putArrayLength(pe packetEncoder, in int) error {
+	if r.Version >= 8 {
+		pe.putCompactArrayLength(in)
+		return nil
+	}
+	return pe.putArrayLength(in)
+}
+
 func (r *OffsetCommitRequest) decode(pd packetDecoder, version int16) (err error) {
 	r.Version = version
+	isFlexible := r.Version >= 8
 
-	if r.ConsumerGroup, err = pd.getString(); err != nil {
+	if r.ConsumerGroup, err = r.getString(pd); err != nil {
 		return err
 	}
 
@@ -118,31 +183,45 @@ func (r *OffsetCommitRequest) decode(pd packetDecoder, version int16) (err error
 		if r.ConsumerGroupGeneration, err = pd.getInt32(); err != nil {
 			return err
 		}
-		if r.ConsumerID, err = pd.getString(); err != nil {
+		if r.ConsumerID, err = r.getString(pd); err != nil {
 			return err
 		}
 	}
 
-	if r.Version >= 2 {
+	if r.Version >= 7 {
+		if isFlexible {
+			r.GroupInstanceId, err = pd.getCompactNullableString()
+		} else {
+			r.GroupInstanceId, err = pd.getNullableString()
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.Version >= 2 && r.Version <= 4 {
 		if r.RetentionTime, err = pd.getInt64(); err != nil {
 			return err
 		}
 	}
 
-	topicCount, err := pd.getArrayLength()
+	topicCount, err := r.getArrayLength(pd)
 	if err != nil {
 		return err
 	}
 	if topicCount == 0 {
-		return nil
+		if isFlexible {
+			_, err = pd.getEmptyTaggedFieldArray()
+		}
+		return err
 	}
 	r.blocks = make(map[string]map[int32]*offsetCommitRequestBlock)
 	for i := 0; i < topicCount; i++ {
-		topic, err := pd.getString()
+		topic, err := r.getString(pd)
 		if err != nil {
 			return err
 		}
-		partitionCount, err := pd.getArrayLength()
+		partitionCount, err := r.getArrayLength(pd)
 		if err != nil {
 			return err
 		}
@@ -158,8 +237,30 @@ func (r *OffsetCommitRequest) decode(pd packetDecoder, version int16) (err error
 			}
 			r.blocks[topic][partition] = block
 		}
+		if isFlexible {
+			if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+		}
 	}
-	return nil
+	if isFlexible {
+		_, err = pd.getEmptyTaggedFieldArray()
+	}
+	return err
+}
+
+func (r *OffsetCommitRequest) getString(pd packetDecoder) (string, error) {
+	if r.Version >= 8 {
+		return pd.getCompactString()
+	}
+	return pd.getString()
+}
+
+func (r *OffsetCommitRequest) getArrayLength(pd packetDecoder) (int, error) {
+	if r.Version >= 8 {
+		return pd.getCompactArrayLength()
+	}
+	return pd.getArrayLength()
 }
 
 func (r *OffsetCommitRequest) key() int16 {
@@ -171,6 +272,9 @@ func (r *OffsetCommitRequest) version() int16 {
 }
 
 func (r *OffsetCommitRequest) headerVersion() int16 {
+	if r.Version >= 8 {
+		return 2
+	}
 	return 1
 }
 
@@ -184,12 +288,26 @@ func (r *OffsetCommitRequest) requiredVersion() KafkaVersion {
 		return V0_11_0_0
 	case 4:
 		return V2_0_0_0
+	case 5, 6:
+		return V2_1_0_0
+	case 7:
+		return V2_3_0_0
+	case 8:
+		return V2_4_0_0
+	case 9:
+		return V3_7_0_0
 	default:
 		return MinVersion
 	}
 }
 
 func (r *OffsetCommitRequest) AddBlock(topic string, partitionID int32, offset int64, timestamp int64, metadata string) {
+	r.AddBlockWithLeaderEpoch(topic, partitionID, offset, -1, timestamp, metadata)
+}
+
+// AddBlockWithLeaderEpoch adds an offset to commit along with the leader epoch
+// of the last consumed record, which is only sent for Version 6 and later.
+func (r *OffsetCommitRequest) AddBlockWithLeaderEpoch(topic string, partitionID int32, offset int64, leaderEpoch int32, timestamp int64, metadata string) {
 	if r.blocks == nil {
 		r.blocks = make(map[string]map[int32]*offsetCommitRequestBlock)
 	}
@@ -198,7 +316,7 @@ func (r *OffsetCommitRequest) AddBlock(topic string, partitionID int32, offset i
 		r.blocks[topic] = make(map[int32]*offsetCommitRequestBlock)
 	}
 
-	r.blocks[topic][partitionID] = &offsetCommitRequestBlock{offset, timestamp, metadata}
+	r.blocks[topic][partitionID] = &offsetCommitRequestBlock{offset, timestamp, leaderEpoch, metadata}
 }
 
 func (r *OffsetCommitRequest) Offset(topic string, partitionID int32) (int64, string, error) {
@@ -61,6 +61,62 @@ var (
 		0x00, 0x00, 0x00, 0x00, 0xDE, 0xAD, 0xBE, 0xEF,
 		0x00, 0x08, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a',
 	}
+
+	offsetCommitRequestOneBlockV5 = []byte{
+		0x00, 0x06, 'f', 'o', 'o', 'b', 'a', 'r',
+		0x00, 0x00, 0x11, 0x22,
+		0x00, 0x04, 'c', 'o', 'n', 's',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x52, 0x21,
+		0x00, 0x00, 0x00, 0x00, 0xDE, 0xAD, 0xBE, 0xEF,
+		0x00, 0x08, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a',
+	}
+
+	offsetCommitRequestOneBlockV6 = []byte{
+		0x00, 0x06, 'f', 'o', 'o', 'b', 'a', 'r',
+		0x00, 0x00, 0x11, 0x22,
+		0x00, 0x04, 'c', 'o', 'n', 's',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x52, 0x21,
+		0x00, 0x00, 0x00, 0x00, 0xDE, 0xAD, 0xBE, 0xEF,
+		0x00, 0x00, 0x00, 0x01, // CommittedLeaderEpoch
+		0x00, 0x08, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a',
+	}
+
+	offsetCommitRequestOneBlockV7 = []byte{
+		0x00, 0x06, 'f', 'o', 'o', 'b', 'a', 'r',
+		0x00, 0x00, 0x11, 0x22,
+		0x00, 0x04, 'c', 'o', 'n', 's',
+		0x00, 0x02, 'g', 'z', // GroupInstanceId
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x52, 0x21,
+		0x00, 0x00, 0x00, 0x00, 0xDE, 0xAD, 0xBE, 0xEF,
+		0x00, 0x00, 0x00, 0x01, // CommittedLeaderEpoch
+		0x00, 0x08, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a',
+	}
+
+	offsetCommitRequestOneBlockV8 = []byte{
+		0x07, 'f', 'o', 'o', 'b', 'a', 'r',
+		0x00, 0x00, 0x11, 0x22,
+		0x05, 'c', 'o', 'n', 's',
+		0x03, 'g', 'z', // GroupInstanceId
+		0x02,
+		0x06, 't', 'o', 'p', 'i', 'c',
+		0x02,
+		0x00, 0x00, 0x52, 0x21,
+		0x00, 0x00, 0x00, 0x00, 0xDE, 0xAD, 0xBE, 0xEF,
+		0x00, 0x00, 0x00, 0x01, // CommittedLeaderEpoch
+		0x09, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a',
+		0x00, // empty tagged fields
+		0x00, // empty tagged fields
+		0x00, // empty tagged fields
+	}
 )
 
 func TestOffsetCommitRequestV0(t *testing.T) {
@@ -99,3 +155,35 @@ func TestOffsetCommitRequestV2ToV4(t *testing.T) {
 		testRequest(t, fmt.Sprintf("one block v%d", version), request, offsetCommitRequestOneBlockV2)
 	}
 }
+
+func TestOffsetCommitRequestV5AndV6(t *testing.T) {
+	request := new(OffsetCommitRequest)
+	request.ConsumerGroup = "foobar"
+	request.ConsumerID = "cons"
+	request.ConsumerGroupGeneration = 0x1122
+	request.Version = 5
+	request.AddBlockWithLeaderEpoch("topic", 0x5221, 0xDEADBEEF, 1, 0, "metadata")
+	testRequestEncode(t, "one block v5", request, offsetCommitRequestOneBlockV5)
+
+	request.Version = 6
+	testRequest(t, "one block v6", request, offsetCommitRequestOneBlockV6)
+}
+
+func TestOffsetCommitRequestV7ToV9(t *testing.T) {
+	groupInstanceID := "gz"
+	for version := 7; version <= 9; version++ {
+		request := new(OffsetCommitRequest)
+		request.ConsumerGroup = "foobar"
+		request.ConsumerID = "cons"
+		request.ConsumerGroupGeneration = 0x1122
+		request.GroupInstanceId = &groupInstanceID
+		request.Version = int16(version)
+		request.AddBlockWithLeaderEpoch("topic", 0x5221, 0xDEADBEEF, 1, 0, "metadata")
+
+		expected := offsetCommitRequestOneBlockV7
+		if version >= 8 {
+			expected = offsetCommitRequestOneBlockV8
+		}
+		testRequest(t, fmt.Sprintf("one block v%d", version), request, expected)
+	}
+}
@@ -1,5 +1,7 @@
 package sarama
 
+import "time"
+
 type OffsetCommitResponse struct {
 	Version        int16
 	ThrottleTimeMs int32
@@ -19,29 +21,49 @@ func (r *OffsetCommitResponse) AddError(topic string, partition int32, kerror KE
 }
 
 func (r *OffsetCommitResponse) encode(pe packetEncoder) error {
+	isFlexible := r.Version >= 8
 	if r.Version >= 3 {
 		pe.putInt32(r.ThrottleTimeMs)
 	}
-	if err := pe.putArrayLength(len(r.Errors)); err != nil {
+	if isFlexible {
+		pe.putCompactArrayLength(len(r.Errors))
+	} else if err := pe.putArrayLength(len(r.Errors)); err != nil {
 		return err
 	}
 	for topic, partitions := range r.Errors {
-		if err := pe.putString(topic); err != nil {
-			return err
-		}
-		if err := pe.putArrayLength(len(partitions)); err != nil {
-			return err
+		if isFlexible {
+			if err := pe.putCompactString(topic); err != nil {
+				return err
+			}
+			pe.putCompactArrayLength(len(partitions))
+		} else {
+			if err := pe.putString(topic); err != nil {
+				return err
+			}
+			if err := pe.putArrayLength(len(partitions)); err != nil {
+				return err
+			}
 		}
 		for partition, kerror := range partitions {
 			pe.putInt32(partition)
 			pe.putInt16(int16(kerror))
+			if isFlexible {
+				pe.putEmptyTaggedFieldArray()
+			}
 		}
+		if isFlexible {
+			pe.putEmptyTaggedFieldArray()
+		}
+	}
+	if isFlexible {
+		pe.putEmptyTaggedFieldArray()
 	}
 	return nil
 }
 
 func (r *OffsetCommitResponse) decode(pd packetDecoder, version int16) (err error) {
 	r.Version = version
+	isFlexible := r.Version >= 8
 
 	if version >= 3 {
 		r.ThrottleTimeMs, err = pd.getInt32()
@@ -50,19 +72,37 @@ func (r *OffsetCommitResponse) decode(pd packetDecoder, version int16) (err erro
 		}
 	}
 
-	numTopics, err := pd.getArrayLength()
-	if err != nil || numTopics == 0 {
+	var numTopics int
+	if isFlexible {
+		numTopics, err = pd.getCompactArrayLength()
+	} else {
+		numTopics, err = pd.getArrayLength()
+	}
+	if err != nil {
+		return err
+	}
+	if numTopics == 0 {
+		if isFlexible {
+			_, err = pd.getEmptyTaggedFieldArray()
+		}
 		return err
 	}
 
 	r.Errors = make(map[string]map[int32]KError, numTopics)
 	for i := 0; i < numTopics; i++ {
-		name, err := pd.getString()
-		if err != nil {
-			return err
+		var name string
+		var numErrors int
+		if isFlexible {
+			if name, err = pd.getCompactString(); err != nil {
+				return err
+			}
+			numErrors, err = pd.getCompactArrayLength()
+		} else {
+			if name, err = pd.getString(); err != nil {
+				return err
+			}
+			numErrors, err = pd.getArrayLength()
 		}
-
-		numErrors, err := pd.getArrayLength()
 		if err != nil {
 			return err
 		}
@@ -80,10 +120,23 @@ func (r *OffsetCommitResponse) decode(pd packetDecoder, version int16) (err erro
 				return err
 			}
 			r.Errors[name][id] = KError(tmp)
+			if isFlexible {
+				if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+					return err
+				}
+			}
+		}
+		if isFlexible {
+			if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
 		}
 	}
 
-	return nil
+	if isFlexible {
+		_, err = pd.getEmptyTaggedFieldArray()
+	}
+	return err
 }
 
 func (r *OffsetCommitResponse) key() int16 {
@@ -95,6 +148,9 @@ func (r *OffsetCommitResponse) version() int16 {
 }
 
 func (r *OffsetCommitResponse) headerVersion() int16 {
+	if r.Version >= 8 {
+		return 1
+	}
 	return 0
 }
 
@@ -108,7 +164,19 @@ func (r *OffsetCommitResponse) requiredVersion() KafkaVersion {
 		return V0_11_0_0
 	case 4:
 		return V2_0_0_0
+	case 5, 6:
+		return V2_1_0_0
+	case 7:
+		return V2_3_0_0
+	case 8:
+		return V2_4_0_0
+	case 9:
+		return V3_7_0_0
 	default:
 		return MinVersion
 	}
 }
+
+func (r *OffsetCommitResponse) throttleTime() time.Duration {
+	return time.Duration(r.ThrottleTimeMs) * time.Millisecond
+}
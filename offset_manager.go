@@ -1,7 +1,10 @@
 package sarama
 
 import (
+	"context"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -23,6 +26,50 @@ type OffsetManager interface {
 	// Commit commits the offsets. This method can be used if AutoCommit.Enable is
 	// set to false.
 	Commit()
+
+	// CommitAsync commits the offsets in the background without blocking the
+	// caller. Once the commit completes, the callback (if not nil) is invoked
+	// with an entry for every partition that was part of the commit, holding
+	// nil on success or the error which prevented the offset from being
+	// committed. Commits are sent to the broker one at a time, each carrying
+	// the offsets marked at the time it is sent; those requested while one
+	// is in flight are coalesced into the next one.
+	CommitAsync(callback func(map[TopicPartitionID]error))
+
+	// CommitSync commits the offsets and blocks until the commit completes or
+	// ctx is done. It returns the outcome for every partition that was part of
+	// the commit, nil on success or the error which prevented the offset from
+	// being committed. If ctx is done first, ctx.Err() is returned and the
+	// commit carries on in the background.
+	CommitSync(ctx context.Context) (map[TopicPartitionID]error, error)
+}
+
+// TopicPartitionID identifies a single partition of a topic.
+type TopicPartitionID struct {
+	Topic     string
+	Partition int32
+}
+
+// OffsetCommitErrors holds the partitions whose offset could not be committed,
+// along with the reason.
+type OffsetCommitErrors map[TopicPartitionID]error
+
+func (errs OffsetCommitErrors) Error() string {
+	return fmt.Sprintf("kafka: failed to commit the offsets of %d partitions", len(errs))
+}
+
+// OffsetCommitFailure describes a commit of the OffsetManager which failed to
+// commit the offset of some partitions, as passed to
+// Consumer.Offsets.ErrorHandler.
+type OffsetCommitFailure struct {
+	Group string
+	// Errors holds the partitions whose offset could not be committed.
+	Errors OffsetCommitErrors
+	// Attempts is the number of commit requests sent, retries included.
+	Attempts int
+	// ConsecutiveFailures is the number of commits which failed in a row,
+	// this one included. It is reset by a successful commit.
+	ConsecutiveFailures int
 }
 
 type offsetManager struct {
@@ -33,10 +80,24 @@ type offsetManager struct {
 
 	memberID   string
 	generation int32
+	// protocol is the rebalance protocol of the group, with GroupProtocolConsumer
+	// the generation is the member epoch
+	protocol ConsumerGroupProtocol
 
 	broker     *Broker
 	brokerLock sync.RWMutex
 
+	// commitLock serializes the commit requests sent to the coordinator
+	commitLock sync.Mutex
+	// failures counts the commits which failed in a row
+	failures int32
+
+	// coalesceLock guards the callbacks queued for the next commit, while
+	// committing tells whether a goroutine is committing them
+	coalesceLock sync.Mutex
+	queued       []func(map[TopicPartitionID]error)
+	committing   bool
+
 	poms     map[string]map[int32]*partitionOffsetManager
 	pomsLock sync.RWMutex
 
@@ -48,10 +109,10 @@ type offsetManager struct {
 // NewOffsetManagerFromClient creates a new OffsetManager from the given client.
 // It is still necessary to call Close() on the underlying client when finished with the partition manager.
 func NewOffsetManagerFromClient(group string, client Client) (OffsetManager, error) {
-	return newOffsetManagerFromClient(group, "", GroupGenerationUndefined, client)
+	return newOffsetManagerFromClient(group, "", GroupGenerationUndefined, GroupProtocolClassic, client)
 }
 
-func newOffsetManagerFromClient(group, memberID string, generation int32, client Client) (*offsetManager, error) {
+func newOffsetManagerFromClient(group, memberID string, generation int32, protocol ConsumerGroupProtocol, client Client) (*offsetManager, error) {
 	// Check that we are not dealing with a closed Client before processing any other arguments
 	if client.Closed() {
 		return nil, ErrClosedClient
@@ -66,6 +127,7 @@ func newOffsetManagerFromClient(group, memberID string, generation int32, client
 
 		memberID:   memberID,
 		generation: generation,
+		protocol:   protocol,
 
 		closing: make(chan none),
 		closed:  make(chan none),
@@ -102,6 +164,14 @@ func (om *offsetManager) ManagePartition(topic string, partition int32) (Partiti
 }
 
 func (om *offsetManager) Close() error {
+	om.close(om.conf.Consumer.Offsets.AutoCommit.Enable)
+	return nil
+}
+
+// close stops the OffsetManager, committing the marked offsets one last time
+// when flush is set. It returns the error of every offset which could not be
+// committed.
+func (om *offsetManager) close(flush bool) (uncommitted OffsetCommitErrors) {
 	om.closeOnce.Do(func() {
 		// exit the mainLoop
 		close(om.closing)
@@ -113,13 +183,31 @@ func (om *offsetManager) Close() error {
 		om.asyncClosePOMs()
 
 		// flush one last time
-		if om.conf.Consumer.Offsets.AutoCommit.Enable {
+		if flush {
+			attempts := 0
 			for attempt := 0; attempt <= om.conf.Consumer.Offsets.Retry.Max; attempt++ {
-				om.flushToBroker()
+				errs := om.flushToBroker()
+				if errs != nil {
+					attempts++
+					om.recordCommit(errs, attempt > 0)
+				}
+				for id, err := range errs {
+					if err == nil {
+						delete(uncommitted, id)
+					} else {
+						if uncommitted == nil {
+							uncommitted = make(OffsetCommitErrors)
+						}
+						uncommitted[id] = err
+					}
+				}
 				if om.releasePOMs(false) == 0 {
 					break
 				}
 			}
+			if attempts > 0 {
+				om.reportCommit(uncommitted, attempts)
+			}
 		}
 
 		om.releasePOMs(true)
@@ -127,11 +215,14 @@ func (om *offsetManager) Close() error {
 		om.broker = nil
 		om.brokerLock.Unlock()
 	})
-	return nil
+	return
 }
 
 func (om *offsetManager) computeBackoff(retries int) time.Duration {
-	if om.conf.Metadata.Retry.BackoffFunc != nil {
+	if om.conf.Metadata.Retry.Policy != nil {
+		maxRetries := om.conf.Metadata.Retry.Max
+		return om.conf.Metadata.Retry.Policy.Backoff(maxRetries-retries+1, maxRetries)
+	} else if om.conf.Metadata.Retry.BackoffFunc != nil {
 		return om.conf.Metadata.Retry.BackoffFunc(retries, om.conf.Metadata.Retry.Max)
 	} else {
 		return om.conf.Metadata.Retry.Backoff
@@ -139,6 +230,10 @@ func (om *offsetManager) computeBackoff(retries int) time.Duration {
 }
 
 func (om *offsetManager) fetchInitialOffset(topic string, partition int32, retries int) (int64, string, error) {
+	if store := om.conf.Consumer.Offsets.Store; store != nil {
+		return store.FetchOffset(om.group, topic, partition)
+	}
+
 	broker, err := om.coordinator()
 	if err != nil {
 		if retries <= 0 {
@@ -242,79 +337,339 @@ func (om *offsetManager) mainLoop() {
 	}
 }
 
+// updateGeneration sets the generation sent with commits, which is the member
+// epoch with GroupProtocolConsumer.
+func (om *offsetManager) updateGeneration(generation int32) {
+	atomic.StoreInt32(&om.generation, generation)
+}
+
 func (om *offsetManager) Commit() {
-	om.flushToBroker()
-	om.releasePOMs(false)
+	done := make(chan none)
+	om.CommitAsync(func(map[TopicPartitionID]error) {
+		close(done)
+	})
+	<-done
+}
+
+// CommitAsync queues the callback for the next commit. The commits requested
+// while one is in flight are coalesced into a single one, which commits the
+// offsets marked in the meantime for all of them.
+func (om *offsetManager) CommitAsync(callback func(map[TopicPartitionID]error)) {
+	om.coalesceLock.Lock()
+	defer om.coalesceLock.Unlock()
+
+	om.queued = append(om.queued, callback)
+	if !om.committing {
+		om.committing = true
+		go withRecover(om.commitQueued)
+	}
+}
+
+// commitQueued commits the offsets for the queued callbacks until there are
+// none left.
+func (om *offsetManager) commitQueued() {
+	for {
+		om.coalesceLock.Lock()
+		callbacks := om.queued
+		om.queued = nil
+		if len(callbacks) == 0 {
+			om.committing = false
+			om.coalesceLock.Unlock()
+			return
+		}
+		om.coalesceLock.Unlock()
+
+		errs := om.commit()
+		om.releasePOMs(false)
+		for _, callback := range callbacks {
+			if callback == nil {
+				continue
+			}
+			outcome := make(map[TopicPartitionID]error, len(errs))
+			for id, err := range errs {
+				outcome[id] = err
+			}
+			callback(outcome)
+		}
+	}
+}
+
+func (om *offsetManager) CommitSync(ctx context.Context) (map[TopicPartitionID]error, error) {
+	done := make(chan map[TopicPartitionID]error, 1)
+	om.CommitAsync(func(errs map[TopicPartitionID]error) {
+		done <- errs
+	})
+
+	select {
+	case errs := <-done:
+		return errs, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// commit flushes the dirty offsets, retrying those which failed up to
+// Consumer.Offsets.Retry.CommitMax times, and returns the outcome for every
+// partition of the commit, or nil when there was nothing to commit.
+func (om *offsetManager) commit() map[TopicPartitionID]error {
+	errs := om.flushToBroker()
+	if errs == nil {
+		return nil
+	}
+	om.recordCommit(errs, false)
+
+	attempts := 1
+retry:
+	for ; attempts <= om.conf.Consumer.Offsets.Retry.CommitMax && commitFailed(errs); attempts++ {
+		select {
+		case <-time.After(om.computeCommitBackoff(attempts)):
+		case <-om.closing:
+			break retry
+		}
+		retried := om.flushToBroker()
+		if retried == nil {
+			break
+		}
+		om.recordCommit(retried, true)
+		for id, err := range retried {
+			errs[id] = err
+		}
+	}
+
+	uncommitted := make(OffsetCommitErrors)
+	for id, err := range errs {
+		if err != nil {
+			uncommitted[id] = err
+		}
+	}
+	om.reportCommit(uncommitted, attempts)
+	return errs
+}
+
+func (om *offsetManager) computeCommitBackoff(retries int) time.Duration {
+	retry := om.conf.Consumer.Offsets.Retry
+	if retry.Policy != nil {
+		return retry.Policy.Backoff(retries, retry.CommitMax)
+	}
+	return retry.Backoff
 }
 
-func (om *offsetManager) flushToBroker() {
-	req := om.constructRequest()
-	if req == nil {
+func commitFailed(errs map[TopicPartitionID]error) bool {
+	for _, err := range errs {
+		if err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// recordCommit updates the commit metrics of the group with the outcome of a
+// commit request
+func (om *offsetManager) recordCommit(errs map[TopicPartitionID]error, retry bool) {
+	recorder := om.conf.metricsRecorder()
+	if recorder == nil {
 		return
 	}
+	group := groupLabel(om.group)
+	recorder.Counter("consumer-group-commit-total", group).Inc(1)
+	if retry {
+		recorder.Counter("consumer-group-commit-retries", group).Inc(1)
+	}
+	if commitFailed(errs) {
+		recorder.Counter("consumer-group-commit-failed", group).Inc(1)
+	}
+}
 
-	broker, err := om.coordinator()
-	if err != nil {
-		om.handleError(err)
+// reportCommit counts the commits failing in a row, and passes the failed
+// ones to Consumer.Offsets.ErrorHandler
+func (om *offsetManager) reportCommit(uncommitted OffsetCommitErrors, attempts int) {
+	if len(uncommitted) == 0 {
+		atomic.StoreInt32(&om.failures, 0)
 		return
 	}
+	failures := atomic.AddInt32(&om.failures, 1)
+	if handler := om.conf.Consumer.Offsets.ErrorHandler; handler != nil {
+		handler(&OffsetCommitFailure{
+			Group:               om.group,
+			Errors:              uncommitted,
+			Attempts:            attempts,
+			ConsecutiveFailures: int(failures),
+		})
+	}
+}
 
-	resp, err := broker.CommitOffset(req)
-	if err != nil {
+// flushToBroker commits the dirty offsets and returns the outcome for every
+// partition of the requests, or nil when there was nothing to commit.
+func (om *offsetManager) flushToBroker() map[TopicPartitionID]error {
+	om.commitLock.Lock()
+	defer om.commitLock.Unlock()
+
+	requests := om.constructRequests()
+	if requests == nil {
+		return nil
+	}
+	if om.conf.Consumer.Offsets.Store != nil {
+		return om.flushToStore(requests[0])
+	}
+
+	errs := make(map[TopicPartitionID]error)
+	for i, req := range requests {
+		broker, err := om.coordinator()
+		if err == nil {
+			var resp *OffsetCommitResponse
+			if resp, err = broker.CommitOffset(req); err == nil {
+				for id, err := range om.handleResponse(broker, req, resp) {
+					errs[id] = err
+				}
+				continue
+			}
+			om.releaseCoordinator(broker)
+			_ = broker.Close()
+		}
+
+		// the coordinator cannot be reached, the remaining requests
+		// would fail the same
 		om.handleError(err)
-		om.releaseCoordinator(broker)
-		_ = broker.Close()
-		return
+		for _, req := range requests[i:] {
+			for id, err := range requestErrors(req, err) {
+				errs[id] = err
+			}
+		}
+		break
+	}
+	return errs
+}
+
+// flushToStore commits the offsets of the request to Consumer.Offsets.Store
+// rather than to the coordinator.
+func (om *offsetManager) flushToStore(req *OffsetCommitRequest) map[TopicPartitionID]error {
+	offsets := make(map[TopicPartitionID]CommittedOffset)
+	for topic, partitions := range req.blocks {
+		for partition, block := range partitions {
+			offsets[TopicPartitionID{Topic: topic, Partition: partition}] = CommittedOffset{Offset: block.offset, Metadata: block.metadata}
+		}
 	}
 
-	om.handleResponse(broker, req, resp)
+	err := om.conf.Consumer.Offsets.Store.CommitOffsets(om.group, offsets)
+	errs := make(map[TopicPartitionID]error, len(offsets))
+	for id, offset := range offsets {
+		errs[id] = err
+		pom := om.findPOM(id.Topic, id.Partition)
+		if pom == nil {
+			continue
+		}
+		if err != nil {
+			pom.handleError(err)
+		} else {
+			pom.updateCommitted(offset.Offset, offset.Metadata)
+		}
+	}
+	return errs
 }
 
-func (om *offsetManager) constructRequest() *OffsetCommitRequest {
-	var r *OffsetCommitRequest
+// requestErrors reports the same error for every partition of the request.
+func requestErrors(req *OffsetCommitRequest, err error) map[TopicPartitionID]error {
+	errs := make(map[TopicPartitionID]error)
+	for topic, partitions := range req.blocks {
+		for partition := range partitions {
+			errs[TopicPartitionID{Topic: topic, Partition: partition}] = err
+		}
+	}
+	return errs
+}
+
+// constructRequests returns the requests committing the dirty offsets, as
+// few as Consumer.Offsets.Batch allows, or nil when there is nothing to
+// commit. The caps are ignored when the offsets are committed to a store.
+func (om *offsetManager) constructRequests() []*OffsetCommitRequest {
 	var perPartitionTimestamp int64
-	if om.conf.Consumer.Offsets.Retention == 0 {
-		perPartitionTimestamp = ReceiveTime
-		r = &OffsetCommitRequest{
-			Version:                 1,
-			ConsumerGroup:           om.group,
-			ConsumerID:              om.memberID,
-			ConsumerGroupGeneration: om.generation,
+	generation := atomic.LoadInt32(&om.generation)
+	newRequest := func() *OffsetCommitRequest {
+		if om.protocol == GroupProtocolConsumer {
+			// members of KIP-848 groups must commit with v9, which no longer
+			// supports a retention time
+			return &OffsetCommitRequest{
+				Version:                 9,
+				ConsumerGroup:           om.group,
+				ConsumerID:              om.memberID,
+				ConsumerGroupGeneration: generation,
+			}
+		} else if om.conf.Consumer.Offsets.Retention == 0 {
+			perPartitionTimestamp = ReceiveTime
+			return &OffsetCommitRequest{
+				Version:                 1,
+				ConsumerGroup:           om.group,
+				ConsumerID:              om.memberID,
+				ConsumerGroupGeneration: generation,
+			}
 		}
-	} else {
-		r = &OffsetCommitRequest{
+		return &OffsetCommitRequest{
 			Version:                 2,
 			RetentionTime:           int64(om.conf.Consumer.Offsets.Retention / time.Millisecond),
 			ConsumerGroup:           om.group,
 			ConsumerID:              om.memberID,
-			ConsumerGroupGeneration: om.generation,
+			ConsumerGroupGeneration: generation,
 		}
 	}
 
+	batch := om.conf.Consumer.Offsets.Batch
+	if om.conf.Consumer.Offsets.Store != nil {
+		batch.MaxPartitions, batch.MaxBytes = 0, 0
+	}
+
 	om.pomsLock.RLock()
 	defer om.pomsLock.RUnlock()
 
-	for _, topicManagers := range om.poms {
+	var requests []*OffsetCommitRequest
+	var r *OffsetCommitRequest
+	var partitions, size int
+	for topic, topicManagers := range om.poms {
 		for _, pom := range topicManagers {
 			pom.lock.Lock()
 			if pom.dirty {
+				blockSize := offsetCommitBlockSize(pom.metadata)
+				if r == nil || r.blocks[topic] == nil {
+					blockSize += offsetCommitTopicSize(topic)
+				}
+				if r != nil && ((batch.MaxPartitions > 0 && partitions >= batch.MaxPartitions) ||
+					(batch.MaxBytes > 0 && size+blockSize > batch.MaxBytes)) {
+					r = nil
+					blockSize = offsetCommitBlockSize(pom.metadata) + offsetCommitTopicSize(topic)
+				}
+				if r == nil {
+					r = newRequest()
+					requests = append(requests, r)
+					partitions, size = 0, 0
+				}
 				r.AddBlock(pom.topic, pom.partition, pom.offset, perPartitionTimestamp, pom.metadata)
+				partitions++
+				size += blockSize
 			}
 			pom.lock.Unlock()
 		}
 	}
 
-	if len(r.blocks) > 0 {
-		return r
-	}
+	return requests
+}
 
-	return nil
+// offsetCommitBlockSize and offsetCommitTopicSize approximate the bytes a
+// partition and a topic add to an OffsetCommitRequest.
+func offsetCommitBlockSize(metadata string) int {
+	// partition, offset, timestamp or leader epoch, metadata
+	return 4 + 8 + 8 + 2 + len(metadata)
+}
+
+func offsetCommitTopicSize(topic string) int {
+	// name and partitions array length
+	return 2 + len(topic) + 4
 }
 
-func (om *offsetManager) handleResponse(broker *Broker, req *OffsetCommitRequest, resp *OffsetCommitResponse) {
+func (om *offsetManager) handleResponse(broker *Broker, req *OffsetCommitRequest, resp *OffsetCommitResponse) map[TopicPartitionID]error {
 	om.pomsLock.RLock()
 	defer om.pomsLock.RUnlock()
 
+	errs := make(map[TopicPartitionID]error)
+
 	for _, topicManagers := range om.poms {
 		for _, pom := range topicManagers {
 			if req.blocks[pom.topic] == nil || req.blocks[pom.topic][pom.partition] == nil {
@@ -324,15 +679,24 @@ func (om *offsetManager) handleResponse(broker *Broker, req *OffsetCommitRequest
 			var err KError
 			var ok bool
 
+			id := TopicPartitionID{Topic: pom.topic, Partition: pom.partition}
 			if resp.Errors[pom.topic] == nil {
 				pom.handleError(ErrIncompleteResponse)
+				errs[id] = ErrIncompleteResponse
 				continue
 			}
 			if err, ok = resp.Errors[pom.topic][pom.partition]; !ok {
 				pom.handleError(ErrIncompleteResponse)
+				errs[id] = ErrIncompleteResponse
 				continue
 			}
 
+			if err == ErrNoError {
+				errs[id] = nil
+			} else {
+				errs[id] = err
+			}
+
 			switch err {
 			case ErrNoError:
 				block := req.blocks[pom.topic][pom.partition]
@@ -344,7 +708,7 @@ func (om *offsetManager) handleResponse(broker *Broker, req *OffsetCommitRequest
 			case ErrOffsetMetadataTooLarge, ErrInvalidCommitOffsetSize:
 				// nothing we can do about this, just tell the user and carry on
 				pom.handleError(err)
-			case ErrOffsetsLoadInProgress:
+			case ErrOffsetsLoadInProgress, ErrStaleMemberEpoch:
 				// nothing wrong but we didn't commit, we'll get it next time round
 			case ErrUnknownTopicOrPartition:
 				// let the user know *and* try redispatching - if topic-auto-create is
@@ -359,6 +723,7 @@ func (om *offsetManager) handleResponse(broker *Broker, req *OffsetCommitRequest
 			}
 		}
 	}
+	return errs
 }
 
 func (om *offsetManager) handleError(err error) {
@@ -1,9 +1,13 @@
 package sarama
 
 import (
+	"context"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/rcrowley/go-metrics"
 )
 
 func initOffsetManagerWithBackoffFunc(t *testing.T, retention time.Duration,
@@ -227,6 +231,414 @@ func TestNewOffsetManagerOffsetsManualCommit(t *testing.T) {
 	safeClose(t, testClient)
 }
 
+func TestOffsetManagerCommitAsync(t *testing.T) {
+	config := NewTestConfig()
+	config.Consumer.Offsets.AutoCommit.Enable = false
+
+	om, testClient, broker, coordinator := initOffsetManagerWithBackoffFunc(t, 0, nil, config)
+	pom := initPartitionOffsetManager(t, om, coordinator, 5, "original_meta")
+
+	ocResponse := new(OffsetCommitResponse)
+	ocResponse.AddError("my_topic", 0, ErrOffsetMetadataTooLarge)
+	coordinator.Returns(ocResponse)
+
+	pom.MarkOffset(100, "modified_meta")
+
+	done := make(chan map[TopicPartitionID]error)
+	om.CommitAsync(func(errs map[TopicPartitionID]error) {
+		done <- errs
+	})
+
+	select {
+	case errs := <-done:
+		if len(errs) != 1 {
+			t.Fatalf("Expected the outcome of 1 partition, got %v", errs)
+		}
+		if err := errs[TopicPartitionID{Topic: "my_topic", Partition: 0}]; err != ErrOffsetMetadataTooLarge {
+			t.Errorf("Expected ErrOffsetMetadataTooLarge, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Commit callback was not invoked")
+	}
+
+	ocResponse = new(OffsetCommitResponse)
+	ocResponse.AddError("my_topic", 0, ErrNoError)
+	coordinator.Returns(ocResponse)
+
+	om.CommitAsync(func(errs map[TopicPartitionID]error) {
+		done <- errs
+	})
+
+	select {
+	case errs := <-done:
+		err, ok := errs[TopicPartitionID{Topic: "my_topic", Partition: 0}]
+		if !ok || err != nil {
+			t.Errorf("Expected a successful commit, got %v", errs)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Commit callback was not invoked")
+	}
+
+	// nothing left to commit
+	om.CommitAsync(func(errs map[TopicPartitionID]error) {
+		done <- errs
+	})
+	if errs := <-done; len(errs) != 0 {
+		t.Errorf("Expected no partitions to be committed, got %v", errs)
+	}
+
+	broker.Close()
+	coordinator.Close()
+
+	safeClose(t, om)
+	safeClose(t, pom)
+	safeClose(t, testClient)
+}
+
+func TestOffsetManagerCommitSync(t *testing.T) {
+	config := NewTestConfig()
+	config.Consumer.Offsets.AutoCommit.Enable = false
+
+	om, testClient, broker, coordinator := initOffsetManagerWithBackoffFunc(t, 0, nil, config)
+	pom := initPartitionOffsetManager(t, om, coordinator, 5, "original_meta")
+
+	ocResponse := new(OffsetCommitResponse)
+	ocResponse.AddError("my_topic", 0, ErrNoError)
+	coordinator.Returns(ocResponse)
+
+	pom.MarkOffset(100, "modified_meta")
+
+	errs, err := om.CommitSync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err, ok := errs[TopicPartitionID{Topic: "my_topic", Partition: 0}]; !ok || err != nil {
+		t.Errorf("Expected a successful commit, got %v", errs)
+	}
+
+	// the coordinator answers too late, the deadline must be honored
+	coordinator.SetLatency(500 * time.Millisecond)
+	coordinator.Returns(ocResponse)
+	pom.MarkOffset(101, "modified_meta")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := om.CommitSync(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// waits for the slow commit to complete, leaving nothing to commit
+	if errs, err := om.CommitSync(context.Background()); err != nil || len(errs) != 0 {
+		t.Errorf("Expected no partitions to be committed, got %v %v", errs, err)
+	}
+
+	broker.Close()
+	coordinator.Close()
+
+	safeClose(t, om)
+	safeClose(t, pom)
+	safeClose(t, testClient)
+}
+
+func TestOffsetManagerCloseUncommitted(t *testing.T) {
+	config := NewTestConfig()
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	config.Consumer.Offsets.Retry.Max = 0
+
+	om, testClient, broker, coordinator := initOffsetManagerWithBackoffFunc(t, 0, nil, config)
+	pom := initPartitionOffsetManager(t, om, coordinator, 5, "original_meta")
+
+	ocResponse := new(OffsetCommitResponse)
+	ocResponse.AddError("my_topic", 0, ErrOffsetMetadataTooLarge)
+	coordinator.Returns(ocResponse)
+
+	pom.MarkOffset(100, "modified_meta")
+
+	uncommitted := om.(*offsetManager).close(true)
+	if len(uncommitted) != 1 || uncommitted[TopicPartitionID{Topic: "my_topic", Partition: 0}] != ErrOffsetMetadataTooLarge {
+		t.Errorf("Expected the offset of my_topic/0 to be uncommitted, got %v", uncommitted)
+	}
+
+	broker.Close()
+	coordinator.Close()
+
+	safeClose(t, pom)
+	safeClose(t, testClient)
+}
+
+func TestOffsetManagerCommitRetryAndErrorHandler(t *testing.T) {
+	var failures []*OffsetCommitFailure
+	config := NewTestConfig()
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	config.Consumer.Offsets.Retry.CommitMax = 2
+	config.Consumer.Offsets.Retry.Backoff = time.Millisecond
+	config.Consumer.Offsets.ErrorHandler = func(failure *OffsetCommitFailure) {
+		failures = append(failures, failure)
+	}
+
+	om, testClient, broker, coordinator := initOffsetManagerWithBackoffFunc(t, 0, nil, config)
+	pom := initPartitionOffsetManager(t, om, coordinator, 5, "original_meta")
+
+	failed := new(OffsetCommitResponse)
+	failed.AddError("my_topic", 0, ErrOffsetMetadataTooLarge)
+	succeeded := new(OffsetCommitResponse)
+	succeeded.AddError("my_topic", 0, ErrNoError)
+
+	// the commit and its 2 retries fail
+	coordinator.Returns(failed)
+	coordinator.Returns(failed)
+	coordinator.Returns(failed)
+	pom.MarkOffset(100, "modified_meta")
+	om.Commit()
+
+	if len(failures) != 1 {
+		t.Fatalf("Expected the error handler to be called once, got %d", len(failures))
+	}
+	id := TopicPartitionID{Topic: "my_topic", Partition: 0}
+	if f := failures[0]; f.Group != "group" || f.Attempts != 3 || f.ConsecutiveFailures != 1 || f.Errors[id] != ErrOffsetMetadataTooLarge {
+		t.Errorf("Unexpected failure %+v", f)
+	}
+
+	// the retry of the next commit succeeds
+	coordinator.Returns(failed)
+	coordinator.Returns(succeeded)
+	om.Commit()
+
+	if len(failures) != 1 {
+		t.Errorf("Expected the error handler not to be called, got %d calls", len(failures))
+	}
+	if offset, _ := pom.NextOffset(); offset != 100 {
+		t.Errorf("Expected the offset 100 to be committed, got %d", offset)
+	}
+	if pom.(*partitionOffsetManager).dirty {
+		t.Error("Expected the offset to be committed")
+	}
+
+	for name, expected := range map[string]int64{
+		"consumer-group-commit-total-group":   5,
+		"consumer-group-commit-retries-group": 3,
+		"consumer-group-commit-failed-group":  4,
+	} {
+		if count := metrics.GetOrRegisterCounter(name, config.MetricRegistry).Count(); count != expected {
+			t.Errorf("Expected %s to be %d, got %d", name, expected, count)
+		}
+	}
+
+	broker.Close()
+	coordinator.Close()
+
+	safeClose(t, om)
+	safeClose(t, pom)
+	safeClose(t, testClient)
+}
+
+func TestOffsetManagerCommitBatches(t *testing.T) {
+	config := NewTestConfig()
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	config.Consumer.Offsets.Batch.MaxPartitions = 1
+
+	om, testClient, broker, coordinator := initOffsetManagerWithBackoffFunc(t, 0, nil, config)
+	pom := initPartitionOffsetManager(t, om, coordinator, 5, "original_meta")
+	fetchResponse := new(OffsetFetchResponse)
+	fetchResponse.AddBlock("my_topic", 1, &OffsetFetchResponseBlock{Err: ErrNoError, Offset: 5})
+	coordinator.Returns(fetchResponse)
+	other, err := om.ManagePartition("my_topic", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ocResponse := new(OffsetCommitResponse)
+	ocResponse.AddError("my_topic", 0, ErrNoError)
+	ocResponse.AddError("my_topic", 1, ErrNoError)
+	coordinator.Returns(ocResponse)
+	coordinator.Returns(ocResponse)
+
+	pom.MarkOffset(100, "")
+	other.MarkOffset(200, "")
+	errs, err := om.CommitSync(context.Background())
+	if err != nil || len(errs) != 2 || errs[TopicPartitionID{Topic: "my_topic", Partition: 0}] != nil || errs[TopicPartitionID{Topic: "my_topic", Partition: 1}] != nil {
+		t.Errorf("Expected the commit of 2 partitions to succeed, got %v %v", errs, err)
+	}
+
+	var requests int
+	for _, rr := range coordinator.History() {
+		if req, ok := rr.Request.(*OffsetCommitRequest); ok {
+			requests++
+			if len(req.blocks["my_topic"]) != 1 {
+				t.Errorf("Expected 1 partition per request, got %d", len(req.blocks["my_topic"]))
+			}
+		}
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 commit requests, got %d", requests)
+	}
+
+	broker.Close()
+	coordinator.Close()
+
+	safeClose(t, om)
+	safeClose(t, pom)
+	safeClose(t, other)
+	safeClose(t, testClient)
+}
+
+func TestOffsetManagerCommitCoalesced(t *testing.T) {
+	config := NewTestConfig()
+	config.Consumer.Offsets.AutoCommit.Enable = false
+
+	om, testClient, broker, coordinator := initOffsetManagerWithBackoffFunc(t, 0, nil, config)
+	pom := initPartitionOffsetManager(t, om, coordinator, 5, "original_meta")
+
+	ocResponse := new(OffsetCommitResponse)
+	ocResponse.AddError("my_topic", 0, ErrNoError)
+	coordinator.Returns(ocResponse)
+	coordinator.Returns(ocResponse)
+	coordinator.SetLatency(100 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	pom.MarkOffset(100, "")
+	wg.Add(1)
+	om.CommitAsync(func(map[TopicPartitionID]error) {
+		wg.Done()
+	})
+
+	// requested while the first commit is in flight
+	time.Sleep(30 * time.Millisecond)
+	pom.MarkOffset(101, "")
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		om.CommitAsync(func(errs map[TopicPartitionID]error) {
+			if err, ok := errs[TopicPartitionID{Topic: "my_topic", Partition: 0}]; !ok || err != nil {
+				t.Errorf("Expected a successful commit, got %v", errs)
+			}
+			wg.Done()
+		})
+	}
+	wg.Wait()
+
+	var requests int
+	for _, rr := range coordinator.History() {
+		if _, ok := rr.Request.(*OffsetCommitRequest); ok {
+			requests++
+		}
+	}
+	if requests != 2 {
+		t.Errorf("Expected the commits to be coalesced into 2 requests, got %d", requests)
+	}
+	if offset, _ := pom.NextOffset(); offset != 101 || pom.(*partitionOffsetManager).dirty {
+		t.Errorf("Expected the offset 101 to be committed, got %d", offset)
+	}
+
+	broker.Close()
+	coordinator.Close()
+
+	safeClose(t, om)
+	safeClose(t, pom)
+	safeClose(t, testClient)
+}
+
+type testOffsetStore struct {
+	lock    sync.Mutex
+	offsets map[string]map[TopicPartitionID]CommittedOffset
+	err     error
+}
+
+func (s *testOffsetStore) FetchOffset(group, topic string, partition int32) (int64, string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if offset, ok := s.offsets[group][TopicPartitionID{Topic: topic, Partition: partition}]; ok {
+		return offset.Offset, offset.Metadata, nil
+	}
+	return -1, "", nil
+}
+
+func (s *testOffsetStore) CommitOffsets(group string, offsets map[TopicPartitionID]CommittedOffset) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if s.offsets[group] == nil {
+		s.offsets[group] = make(map[TopicPartitionID]CommittedOffset)
+	}
+	for id, offset := range offsets {
+		s.offsets[group][id] = offset
+	}
+	return nil
+}
+
+func TestOffsetManagerStore(t *testing.T) {
+	store := &testOffsetStore{offsets: map[string]map[TopicPartitionID]CommittedOffset{
+		"group": {{Topic: "my_topic", Partition: 0}: {Offset: 5, Metadata: "original_meta"}},
+	}}
+	config := NewTestConfig()
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	config.Consumer.Offsets.Initial = OffsetOldest
+	config.Consumer.Offsets.Store = store
+
+	broker := NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("my_topic", 0, broker.BrokerID()),
+	})
+	client, err := NewClient([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	om, err := NewOffsetManagerFromClient("group", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pom, err := om.ManagePartition("my_topic", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset, metadata := pom.NextOffset(); offset != 5 || metadata != "original_meta" {
+		t.Errorf("Expected the offset of the store, got %d %q", offset, metadata)
+	}
+	other, err := om.ManagePartition("my_topic", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset, _ := other.NextOffset(); offset != OffsetOldest {
+		t.Errorf("Expected the initial offset, got %d", offset)
+	}
+
+	pom.MarkOffset(100, "modified_meta")
+	other.MarkOffset(10, "")
+	errs, err := om.CommitSync(context.Background())
+	if err != nil || len(errs) != 2 || errs[TopicPartitionID{Topic: "my_topic", Partition: 0}] != nil {
+		t.Errorf("Expected the commit of 2 partitions to succeed, got %v %v", errs, err)
+	}
+	if offset := store.offsets["group"][TopicPartitionID{Topic: "my_topic", Partition: 1}]; offset.Offset != 10 {
+		t.Errorf("Expected the offset 10 to be stored, got %d", offset.Offset)
+	}
+
+	store.err = ErrOutOfBrokers
+	pom.MarkOffset(101, "")
+	errs, err = om.CommitSync(context.Background())
+	if err != nil || len(errs) != 1 || errs[TopicPartitionID{Topic: "my_topic", Partition: 0}] != ErrOutOfBrokers {
+		t.Errorf("Expected the commit to fail, got %v %v", errs, err)
+	}
+	if offset := store.offsets["group"][TopicPartitionID{Topic: "my_topic", Partition: 0}]; offset.Offset != 100 || offset.Metadata != "modified_meta" {
+		t.Errorf("Expected the offset 100 to be kept, got %+v", offset)
+	}
+
+	store.err = nil
+	om.Commit()
+	if offset := store.offsets["group"][TopicPartitionID{Topic: "my_topic", Partition: 0}]; offset.Offset != 101 {
+		t.Errorf("Expected the offset 101 to be committed, got %d", offset.Offset)
+	}
+
+	safeClose(t, om)
+	safeClose(t, pom)
+	safeClose(t, other)
+}
+
 // Test recovery from ErrNotCoordinatorForConsumer
 // on first fetchInitialOffset call
 func TestOffsetManagerFetchInitialFail(t *testing.T) {
@@ -1,20 +1,35 @@
 package sarama
 
 type offsetRequestBlock struct {
-	time       int64
-	maxOffsets int32 // Only used in version 0
+	// currentLeaderEpoch is only used in version 4+, -1 to skip the leader
+	// epoch validation
+	currentLeaderEpoch int32
+	time               int64
+	maxOffsets         int32 // Only used in version 0
 }
 
 func (b *offsetRequestBlock) encode(pe packetEncoder, version int16) error {
+	if version >= 4 {
+		pe.putInt32(b.currentLeaderEpoch)
+	}
+
 	pe.putInt64(b.time)
 	if version == 0 {
 		pe.putInt32(b.maxOffsets)
 	}
 
+	if version >= 6 {
+		pe.putEmptyTaggedFieldArray()
+	}
 	return nil
 }
 
 func (b *offsetRequestBlock) decode(pd packetDecoder, version int16) (err error) {
+	if version >= 4 {
+		if b.currentLeaderEpoch, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
 	if b.time, err = pd.getInt64(); err != nil {
 		return err
 	}
@@ -23,6 +38,11 @@ func (b *offsetRequestBlock) decode(pd packetDecoder, version int16) (err error)
 			return err
 		}
 	}
+	if version >= 6 {
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -46,25 +66,38 @@ func (r *OffsetRequest) encode(pe packetEncoder) error {
 		pe.putBool(r.IsolationLevel == ReadCommitted)
 	}
 
-	err := pe.putArrayLength(len(r.blocks))
-	if err != nil {
+	if r.Version >= 6 {
+		pe.putCompactArrayLength(len(r.blocks))
+	} else if err := pe.putArrayLength(len(r.blocks)); err != nil {
 		return err
 	}
 	for topic, partitions := range r.blocks {
-		err = pe.putString(topic)
-		if err != nil {
-			return err
-		}
-		err = pe.putArrayLength(len(partitions))
-		if err != nil {
-			return err
+		if r.Version >= 6 {
+			if err := pe.putCompactString(topic); err != nil {
+				return err
+			}
+			pe.putCompactArrayLength(len(partitions))
+		} else {
+			if err := pe.putString(topic); err != nil {
+				return err
+			}
+			if err := pe.putArrayLength(len(partitions)); err != nil {
+				return err
+			}
 		}
 		for partition, block := range partitions {
 			pe.putInt32(partition)
-			if err = block.encode(pe, r.Version); err != nil {
+			if err := block.encode(pe, r.Version); err != nil {
 				return err
 			}
 		}
+		if r.Version >= 6 {
+			pe.putEmptyTaggedFieldArray()
+		}
+	}
+
+	if r.Version >= 6 {
+		pe.putEmptyTaggedFieldArray()
 	}
 	return nil
 }
@@ -92,20 +125,32 @@ func (r *OffsetRequest) decode(pd packetDecoder, version int16) error {
 		}
 	}
 
-	blockCount, err := pd.getArrayLength()
+	var blockCount int
+	if r.Version >= 6 {
+		blockCount, err = pd.getCompactArrayLength()
+	} else {
+		blockCount, err = pd.getArrayLength()
+	}
 	if err != nil {
 		return err
 	}
-	if blockCount == 0 {
-		return nil
+	if blockCount > 0 {
+		r.blocks = make(map[string]map[int32]*offsetRequestBlock)
 	}
-	r.blocks = make(map[string]map[int32]*offsetRequestBlock)
 	for i := 0; i < blockCount; i++ {
-		topic, err := pd.getString()
-		if err != nil {
-			return err
+		var topic string
+		var partitionCount int
+		if r.Version >= 6 {
+			if topic, err = pd.getCompactString(); err != nil {
+				return err
+			}
+			partitionCount, err = pd.getCompactArrayLength()
+		} else {
+			if topic, err = pd.getString(); err != nil {
+				return err
+			}
+			partitionCount, err = pd.getArrayLength()
 		}
-		partitionCount, err := pd.getArrayLength()
 		if err != nil {
 			return err
 		}
@@ -121,6 +166,17 @@ func (r *OffsetRequest) decode(pd packetDecoder, version int16) error {
 			}
 			r.blocks[topic][partition] = block
 		}
+		if r.Version >= 6 {
+			if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if r.Version >= 6 {
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -134,6 +190,9 @@ func (r *OffsetRequest) version() int16 {
 }
 
 func (r *OffsetRequest) headerVersion() int16 {
+	if r.Version >= 6 {
+		return 2
+	}
 	return 1
 }
 
@@ -143,6 +202,16 @@ func (r *OffsetRequest) requiredVersion() KafkaVersion {
 		return V0_10_1_0
 	case 2:
 		return V0_11_0_0
+	case 3:
+		return V2_0_0_0
+	case 4:
+		return V2_1_0_0
+	case 5:
+		return V2_2_0_0
+	case 6:
+		return V2_5_0_0
+	case 7:
+		return V3_0_0_0
 	default:
 		return MinVersion
 	}
@@ -174,6 +243,19 @@ func (r *OffsetRequest) AddBlock(topic string, partitionID int32, time int64, ma
 	if r.Version == 0 {
 		tmp.maxOffsets = maxOffsets
 	}
+	if r.Version >= 4 {
+		tmp.currentLeaderEpoch = -1
+	}
 
 	r.blocks[topic][partitionID] = tmp
 }
+
+// AddBlockWithLeaderEpoch adds a partition to the request along with the
+// current leader epoch known by the client, used from version 4 by the brokers
+// to fence requests based on stale metadata.
+func (r *OffsetRequest) AddBlockWithLeaderEpoch(topic string, partitionID int32, time int64, currentLeaderEpoch int32) {
+	r.AddBlock(topic, partitionID, time, 1)
+	if r.Version >= 4 {
+		r.blocks[topic][partitionID].currentLeaderEpoch = currentLeaderEpoch
+	}
+}
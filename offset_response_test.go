@@ -35,6 +35,21 @@ var (
 		0x00, 0x00, 0x01, 0x58, 0x1A, 0xE6, 0x48, 0x86,
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x06,
 	}
+
+	normalOffsetResponseV6 = []byte{
+		0x00, 0x00, 0x00, 0x00, // throttle time
+		0x02,
+		0x02, 'z',
+		0x02,
+		0x00, 0x00, 0x00, 0x02,
+		0x00, 0x00,
+		0x00, 0x00, 0x01, 0x58, 0x1A, 0xE6, 0x48, 0x86,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x06,
+		0x00, 0x00, 0x00, 0x03, // leader epoch
+		0x00,
+		0x00,
+		0x00,
+	}
 )
 
 func TestEmptyOffsetResponse(t *testing.T) {
@@ -112,3 +127,11 @@ func TestNormalOffsetResponseV1(t *testing.T) {
 		t.Fatal("Decoding produced invalid offsets for topic z partition 2.")
 	}
 }
+
+func TestOffsetResponseV6(t *testing.T) {
+	response := &OffsetResponse{Version: 6}
+	response.Blocks = map[string]map[int32]*OffsetResponseBlock{
+		"z": {2: {Offsets: []int64{6}, Offset: 6, Timestamp: 1477920049286, LeaderEpoch: 3}},
+	}
+	testResponse(t, "normal", response, normalOffsetResponseV6)
+}
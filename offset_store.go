@@ -0,0 +1,35 @@
+package sarama
+
+// OffsetStore stores the offsets committed by the OffsetManager outside
+// Kafka, such as in a relational database, Redis or S3 checkpoints, see
+// Consumer.Offsets.Store. The PartitionOffsetManager semantics are kept:
+// offsets are marked or reset, flushed to the store by AutoCommit and the
+// Commit methods, and fetched from it when a partition starts being managed.
+// The consumer groups still coordinate their members through Kafka.
+//
+// Pipelines which must commit the offsets atomically with their own state can
+// do so in CommitOffsets, for instance by committing the database transaction
+// holding the state of the messages processed up to the offsets. The store
+// does not check the generation of the group members, so such pipelines must
+// commit before their partitions are revoked, from the Cleanup of their
+// ConsumerGroupHandler.
+//
+// The methods must be safe for concurrent use, as the offset managers of
+// several groups may share the store.
+type OffsetStore interface {
+	// FetchOffset returns the offset committed for the partition by the
+	// group and its metadata, or an offset of -1 when there is none, in
+	// which case the consumers start from Consumer.Offsets.Initial.
+	FetchOffset(group, topic string, partition int32) (offset int64, metadata string, err error)
+
+	// CommitOffsets stores the offsets of the group, all of them or none
+	// when it returns an error.
+	CommitOffsets(group string, offsets map[TopicPartitionID]CommittedOffset) error
+}
+
+// CommittedOffset is an offset committed to an OffsetStore, along with its
+// metadata.
+type CommittedOffset struct {
+	Offset   int64
+	Metadata string
+}
@@ -0,0 +1,209 @@
+package sarama
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// Option changes a Config as part of the ...WithOptions constructors, such as
+// NewClientWithOptions(brokers, WithVersion(V3_6_0_0), WithSASLPlain(user,
+// password)). It returns a ConfigurationError if its arguments are invalid.
+// The options are applied in order to the result of NewConfig, which is then
+// validated; With gives access to the fields no option sets.
+type Option func(c *Config) error
+
+// NewConfigWithOptions returns the result of NewConfig with the options
+// applied, or the first error of the options or of Validate.
+func NewConfigWithOptions(options ...Option) (*Config, error) {
+	c := NewConfig()
+	for _, option := range options {
+		if err := option(c); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewClientWithOptions is NewClient with the Config built by
+// NewConfigWithOptions.
+func NewClientWithOptions(addrs []string, options ...Option) (Client, error) {
+	c, err := NewConfigWithOptions(options...)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(addrs, c)
+}
+
+// NewAsyncProducerWithOptions is NewAsyncProducer with the Config built by
+// NewConfigWithOptions.
+func NewAsyncProducerWithOptions(addrs []string, options ...Option) (AsyncProducer, error) {
+	c, err := NewConfigWithOptions(options...)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncProducer(addrs, c)
+}
+
+// NewSyncProducerWithOptions is NewSyncProducer with the Config built by
+// NewConfigWithOptions, Producer.Return.Successes and Producer.Return.Errors
+// being enabled as the SyncProducer requires.
+func NewSyncProducerWithOptions(addrs []string, options ...Option) (SyncProducer, error) {
+	c, err := NewConfigWithOptions(append([]Option{With(func(c *Config) {
+		c.Producer.Return.Successes = true
+		c.Producer.Return.Errors = true
+	})}, options...)...)
+	if err != nil {
+		return nil, err
+	}
+	return NewSyncProducer(addrs, c)
+}
+
+// NewConsumerWithOptions is NewConsumer with the Config built by
+// NewConfigWithOptions.
+func NewConsumerWithOptions(addrs []string, options ...Option) (Consumer, error) {
+	c, err := NewConfigWithOptions(options...)
+	if err != nil {
+		return nil, err
+	}
+	return NewConsumer(addrs, c)
+}
+
+// NewConsumerGroupWithOptions is NewConsumerGroup with the Config built by
+// NewConfigWithOptions.
+func NewConsumerGroupWithOptions(addrs []string, groupID string, options ...Option) (ConsumerGroup, error) {
+	c, err := NewConfigWithOptions(options...)
+	if err != nil {
+		return nil, err
+	}
+	return NewConsumerGroup(addrs, groupID, c)
+}
+
+// With returns an Option calling fn with the Config, for the fields no other
+// option sets.
+func With(fn func(c *Config)) Option {
+	return func(c *Config) error {
+		fn(c)
+		return nil
+	}
+}
+
+// WithVersion sets Version, the version of Kafka the requests are sent for.
+func WithVersion(version KafkaVersion) Option {
+	return func(c *Config) error {
+		if !version.IsAtLeast(MinVersion) || !MaxVersion.IsAtLeast(version) {
+			return ConfigurationError(fmt.Sprintf("WithVersion: unsupported version %s", version))
+		}
+		c.Version = version
+		return nil
+	}
+}
+
+// WithClientID sets ClientID, the name of the application sent with every
+// request.
+func WithClientID(clientID string) Option {
+	return func(c *Config) error {
+		if clientID == "" {
+			return ConfigurationError("WithClientID: the client ID must not be empty")
+		}
+		c.ClientID = clientID
+		return nil
+	}
+}
+
+// WithRackID sets RackID, the rack the consumers fetch from the closest
+// replica in.
+func WithRackID(rackID string) Option {
+	return With(func(c *Config) { c.RackID = rackID })
+}
+
+// WithTLS enables TLS with the given configuration, nil for the default one.
+func WithTLS(cfg *tls.Config) Option {
+	return With(func(c *Config) {
+		c.Net.TLS.Enable = true
+		c.Net.TLS.Config = cfg
+	})
+}
+
+// WithSASLPlain enables the SASL/PLAIN mechanism with the given credentials.
+func WithSASLPlain(user, password string) Option {
+	return withSASLCredentials("WithSASLPlain", SASLTypePlaintext, user, password)
+}
+
+// WithSASLSCRAM enables the given SCRAM mechanism, SASLTypeSCRAMSHA256 or
+// SASLTypeSCRAMSHA512, with the given credentials.
+func WithSASLSCRAM(mechanism SASLMechanism, user, password string) Option {
+	if mechanism != SASLTypeSCRAMSHA256 && mechanism != SASLTypeSCRAMSHA512 {
+		return func(*Config) error {
+			return ConfigurationError(fmt.Sprintf("WithSASLSCRAM: %s is not a SCRAM mechanism", mechanism))
+		}
+	}
+	return withSASLCredentials("WithSASLSCRAM", mechanism, user, password)
+}
+
+// WithSASLOAuthBearer enables the SASL/OAUTHBEARER mechanism with the tokens
+// of the given provider.
+func WithSASLOAuthBearer(provider AccessTokenProvider) Option {
+	return func(c *Config) error {
+		if provider == nil {
+			return ConfigurationError("WithSASLOAuthBearer: the token provider must not be nil")
+		}
+		c.Net.SASL.Enable = true
+		c.Net.SASL.Mechanism = SASLTypeOAuth
+		c.Net.SASL.TokenProvider = provider
+		return nil
+	}
+}
+
+func withSASLCredentials(option string, mechanism SASLMechanism, user, password string) Option {
+	return func(c *Config) error {
+		if user == "" || password == "" {
+			return ConfigurationError(option + ": the user and password must not be empty")
+		}
+		c.Net.SASL.Enable = true
+		c.Net.SASL.Mechanism = mechanism
+		c.Net.SASL.User = user
+		c.Net.SASL.Password = password
+		return nil
+	}
+}
+
+// WithRequiredAcks sets Producer.RequiredAcks, the acknowledgements the
+// brokers wait for before answering the produce requests.
+func WithRequiredAcks(acks RequiredAcks) Option {
+	return With(func(c *Config) { c.Producer.RequiredAcks = acks })
+}
+
+// WithCompression sets Producer.Compression, the codec the produced messages
+// are compressed with.
+func WithCompression(codec CompressionCodec) Option {
+	return With(func(c *Config) { c.Producer.Compression = codec })
+}
+
+// WithIdempotence enables Producer.Idempotent along with the settings it
+// requires: WaitForAll acknowledgements and a single open request per
+// connection. It requires Kafka 0.11 or higher.
+func WithIdempotence() Option {
+	return With(func(c *Config) {
+		c.Producer.Idempotent = true
+		c.Producer.RequiredAcks = WaitForAll
+		c.Net.MaxOpenRequests = 1
+		if c.Producer.Retry.Max < 1 {
+			c.Producer.Retry.Max = 1
+		}
+	})
+}
+
+// WithInitialOffset sets Consumer.Offsets.Initial, OffsetOldest or
+// OffsetNewest, where the consumer groups start without a committed offset.
+func WithInitialOffset(offset int64) Option {
+	return func(c *Config) error {
+		if offset != OffsetOldest && offset != OffsetNewest {
+			return ConfigurationError("WithInitialOffset: the offset must be OffsetOldest or OffsetNewest")
+		}
+		c.Consumer.Offsets.Initial = offset
+		return nil
+	}
+}
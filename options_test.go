@@ -0,0 +1,85 @@
+package sarama
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestNewConfigWithOptions(t *testing.T) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS13}
+	config, err := NewConfigWithOptions(
+		WithVersion(V3_6_0_0),
+		WithClientID("billing"),
+		WithTLS(tlsConfig),
+		WithSASLSCRAM(SASLTypeSCRAMSHA512, "billing", "secret"),
+		WithIdempotence(),
+		WithInitialOffset(OffsetOldest),
+		With(func(c *Config) { c.Producer.Return.Successes = true }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Version != V3_6_0_0 || config.ClientID != "billing" {
+		t.Errorf("unexpected version %s and client ID %s", config.Version, config.ClientID)
+	}
+	if !config.Net.TLS.Enable || config.Net.TLS.Config != tlsConfig {
+		t.Error("expected TLS to be enabled with the given configuration")
+	}
+	if !config.Net.SASL.Enable || config.Net.SASL.Mechanism != SASLTypeSCRAMSHA512 || config.Net.SASL.User != "billing" {
+		t.Errorf("unexpected SASL settings %+v", config.Net.SASL)
+	}
+	if !config.Producer.Idempotent || config.Producer.RequiredAcks != WaitForAll || config.Net.MaxOpenRequests != 1 {
+		t.Error("expected the idempotent producer settings")
+	}
+	if config.Consumer.Offsets.Initial != OffsetOldest || !config.Producer.Return.Successes {
+		t.Error("expected the consumer and With options to be applied")
+	}
+}
+
+func TestNewConfigWithOptionsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		err     string
+	}{
+		{"Version", []Option{WithVersion(KafkaVersion{})}, "WithVersion: unsupported version 0.0.0.0"},
+		{"SASLPlain", []Option{WithSASLPlain("billing", "")}, "WithSASLPlain: the user and password must not be empty"},
+		{"SASLSCRAM", []Option{WithSASLSCRAM(SASLTypePlaintext, "billing", "secret")}, "WithSASLSCRAM: PLAIN is not a SCRAM mechanism"},
+		{"InitialOffset", []Option{WithInitialOffset(42)}, "WithInitialOffset: the offset must be OffsetOldest or OffsetNewest"},
+		// the options are valid on their own but not together
+		{"Validate", []Option{WithVersion(V0_10_2_0), WithIdempotence()}, "Idempotent producer requires Version >= V0_11_0_0"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := NewConfigWithOptions(test.options...)
+			if config != nil || err == nil || string(err.(ConfigurationError)) != test.err {
+				t.Errorf("expected %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestNewSyncProducerWithOptions(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+
+	// the options are applied after the defaults of the SyncProducer
+	producer, err := NewSyncProducerWithOptions([]string{seedBroker.Addr()},
+		WithClientID("billing"),
+		With(func(c *Config) { c.Producer.Return.Successes = false }),
+	)
+	if err == nil {
+		safeClose(t, producer)
+		t.Fatal("expected the producer to require Producer.Return.Successes")
+	}
+
+	producer, err = NewSyncProducerWithOptions([]string{seedBroker.Addr()}, WithClientID("billing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	safeClose(t, producer)
+}
@@ -5,8 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"math"
-
-	"github.com/rcrowley/go-metrics"
 )
 
 type prepEncoder struct {
@@ -206,6 +204,6 @@ func (pe *prepEncoder) pop() error {
 }
 
 // we do not record metrics during the prep encoder pass
-func (pe *prepEncoder) metricRegistry() metrics.Registry {
+func (pe *prepEncoder) metricsRecorder() MetricsRecorder {
 	return nil
 }
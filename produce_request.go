@@ -1,6 +1,6 @@
 package sarama
 
-import "github.com/rcrowley/go-metrics"
+import "time"
 
 // RequiredAcks is used in Produce Requests to tell the broker how many replica acknowledgements
 // it must see before responding. Any of the constants defined here are valid. On broker versions
@@ -28,8 +28,8 @@ type ProduceRequest struct {
 	records         map[string]map[int32]Records
 }
 
-func updateMsgSetMetrics(msgSet *MessageSet, compressionRatioMetric metrics.Histogram,
-	topicCompressionRatioMetric metrics.Histogram) int64 {
+func updateMsgSetMetrics(recorder MetricsRecorder, msgSet *MessageSet, compressionRatioMetric MetricHistogram,
+	topicCompressionRatioMetric MetricHistogram) int64 {
 	var topicRecordCount int64
 	for _, messageBlock := range msgSet.Messages {
 		// Is this a fake "message" wrapping real messages?
@@ -47,22 +47,34 @@ func updateMsgSetMetrics(msgSet *MessageSet, compressionRatioMetric metrics.Hist
 			intCompressionRatio := int64(100 * compressionRatio)
 			compressionRatioMetric.Update(intCompressionRatio)
 			topicCompressionRatioMetric.Update(intCompressionRatio)
+			updateCompressionTimeMetrics(recorder, messageBlock.Msg.Codec, messageBlock.Msg.compressionTime)
 		}
 	}
 	return topicRecordCount
 }
 
-func updateBatchMetrics(recordBatch *RecordBatch, compressionRatioMetric metrics.Histogram,
-	topicCompressionRatioMetric metrics.Histogram) int64 {
+func updateBatchMetrics(recorder MetricsRecorder, recordBatch *RecordBatch, compressionRatioMetric MetricHistogram,
+	topicCompressionRatioMetric MetricHistogram) int64 {
 	if recordBatch.compressedRecords != nil {
 		compressionRatio := int64(float64(recordBatch.recordsLen) / float64(len(recordBatch.compressedRecords)) * 100)
 		compressionRatioMetric.Update(compressionRatio)
 		topicCompressionRatioMetric.Update(compressionRatio)
+		updateCompressionTimeMetrics(recorder, recordBatch.Codec, recordBatch.compressionTime)
 	}
 
 	return int64(len(recordBatch.Records))
 }
 
+func updateCompressionTimeMetrics(recorder MetricsRecorder, codec CompressionCodec, compressionTime time.Duration) {
+	if codec == CompressionNone {
+		return
+	}
+	// Histogram do not support durations, record them in us as the compression of a batch is fast
+	compressionTimeInUs := compressionTime.Microseconds()
+	recorder.Histogram("compression-time-in-us").Update(compressionTimeInUs)
+	recorder.Histogram("compression-time-in-us", codecLabel(codec)).Update(compressionTimeInUs)
+}
+
 func (r *ProduceRequest) encode(pe packetEncoder) error {
 	if r.Version >= 3 {
 		if err := pe.putNullableString(r.TransactionalID); err != nil {
@@ -71,12 +83,12 @@ func (r *ProduceRequest) encode(pe packetEncoder) error {
 	}
 	pe.putInt16(int16(r.RequiredAcks))
 	pe.putInt32(r.Timeout)
-	metricRegistry := pe.metricRegistry()
-	var batchSizeMetric metrics.Histogram
-	var compressionRatioMetric metrics.Histogram
-	if metricRegistry != nil {
-		batchSizeMetric = getOrRegisterHistogram("batch-size", metricRegistry)
-		compressionRatioMetric = getOrRegisterHistogram("compression-ratio", metricRegistry)
+	recorder := pe.metricsRecorder()
+	var batchSizeMetric MetricHistogram
+	var compressionRatioMetric MetricHistogram
+	if recorder != nil {
+		batchSizeMetric = recorder.Histogram("batch-size")
+		compressionRatioMetric = recorder.Histogram("compression-ratio")
 	}
 	totalRecordCount := int64(0)
 
@@ -95,9 +107,9 @@ func (r *ProduceRequest) encode(pe packetEncoder) error {
 			return err
 		}
 		topicRecordCount := int64(0)
-		var topicCompressionRatioMetric metrics.Histogram
-		if metricRegistry != nil {
-			topicCompressionRatioMetric = getOrRegisterTopicHistogram("compression-ratio", topic, metricRegistry)
+		var topicCompressionRatioMetric MetricHistogram
+		if recorder != nil {
+			topicCompressionRatioMetric = recorder.Histogram("compression-ratio", topicLabel(topic))
 		}
 		for id, records := range partitions {
 			startOffset := pe.offset()
@@ -111,26 +123,26 @@ func (r *ProduceRequest) encode(pe packetEncoder) error {
 			if err != nil {
 				return err
 			}
-			if metricRegistry != nil {
+			if recorder != nil {
 				if r.Version >= 3 {
-					topicRecordCount += updateBatchMetrics(records.RecordBatch, compressionRatioMetric, topicCompressionRatioMetric)
+					topicRecordCount += updateBatchMetrics(recorder, records.RecordBatch, compressionRatioMetric, topicCompressionRatioMetric)
 				} else {
-					topicRecordCount += updateMsgSetMetrics(records.MsgSet, compressionRatioMetric, topicCompressionRatioMetric)
+					topicRecordCount += updateMsgSetMetrics(recorder, records.MsgSet, compressionRatioMetric, topicCompressionRatioMetric)
 				}
 				batchSize := int64(pe.offset() - startOffset)
 				batchSizeMetric.Update(batchSize)
-				getOrRegisterTopicHistogram("batch-size", topic, metricRegistry).Update(batchSize)
+				recorder.Histogram("batch-size", topicLabel(topic)).Update(batchSize)
 			}
 		}
 		if topicRecordCount > 0 {
-			getOrRegisterTopicMeter("record-send-rate", topic, metricRegistry).Mark(topicRecordCount)
-			getOrRegisterTopicHistogram("records-per-request", topic, metricRegistry).Update(topicRecordCount)
+			recorder.Meter("record-send-rate", topicLabel(topic)).Mark(topicRecordCount)
+			recorder.Histogram("records-per-request", topicLabel(topic)).Update(topicRecordCount)
 			totalRecordCount += topicRecordCount
 		}
 	}
 	if totalRecordCount > 0 {
-		metrics.GetOrRegisterMeter("record-send-rate", metricRegistry).Mark(totalRecordCount)
-		getOrRegisterHistogram("records-per-request", metricRegistry).Update(totalRecordCount)
+		recorder.Meter("record-send-rate").Mark(totalRecordCount)
+		recorder.Histogram("records-per-request").Update(totalRecordCount)
 	}
 
 	return nil
@@ -172,6 +184,9 @@ func (r *ProduceRequest) decode(pd packetDecoder, version int16) error {
 		if err != nil {
 			return err
 		}
+		if partitionCount == -1 {
+			partitionCount = 0
+		}
 		r.records[topic] = make(map[int32]Records)
 
 		for j := 0; j < partitionCount; j++ {
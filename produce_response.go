@@ -92,6 +92,9 @@ func (r *ProduceResponse) decode(pd packetDecoder, version int16) (err error) {
 	if err != nil {
 		return err
 	}
+	if numTopics == -1 {
+		numTopics = 0
+	}
 
 	r.Blocks = make(map[string]map[int32]*ProduceResponseBlock, numTopics)
 	for i := 0; i < numTopics; i++ {
@@ -104,6 +107,9 @@ func (r *ProduceResponse) decode(pd packetDecoder, version int16) (err error) {
 		if err != nil {
 			return err
 		}
+		if numBlocks == -1 {
+			numBlocks = 0
+		}
 
 		r.Blocks[name] = make(map[int32]*ProduceResponseBlock, numBlocks)
 
@@ -179,6 +185,10 @@ func (r *ProduceResponse) requiredVersion() KafkaVersion {
 	return MinVersion
 }
 
+func (r *ProduceResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
+
 func (r *ProduceResponse) GetBlock(topic string, partition int32) *ProduceResponseBlock {
 	if r.Blocks == nil {
 		return nil
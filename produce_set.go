@@ -13,7 +13,10 @@ type partitionSet struct {
 }
 
 type produceSet struct {
-	parent        *asyncProducer
+	parent *asyncProducer
+	// topic is the topic of the messages when they have overrides, see
+	// Config.producerBatch
+	topic         string
 	msgs          map[string]map[int32]*partitionSet
 	producerID    int64
 	producerEpoch int16
@@ -22,11 +25,12 @@ type produceSet struct {
 	bufferCount int
 }
 
-func newProduceSet(parent *asyncProducer) *produceSet {
+func newProduceSet(parent *asyncProducer, topic string) *produceSet {
 	pid, epoch := parent.txnmgr.getProducerID()
 	return &produceSet{
 		msgs:          make(map[string]map[int32]*partitionSet),
 		parent:        parent,
+		topic:         topic,
 		producerID:    pid,
 		producerEpoch: epoch,
 	}
@@ -72,6 +76,8 @@ func (ps *produceSet) add(msg *ProducerMessage) error {
 				CompressionLevel: ps.parent.conf.Producer.CompressionLevel,
 				ProducerID:       ps.producerID,
 				ProducerEpoch:    ps.producerEpoch,
+
+				compressionConcurrency: ps.parent.conf.Producer.CompressionConcurrency,
 			}
 			if ps.parent.conf.Producer.Idempotent {
 				batch.FirstSequence = msg.sequenceNumber
@@ -129,7 +135,7 @@ func (ps *produceSet) add(msg *ProducerMessage) error {
 
 func (ps *produceSet) buildRequest() *ProduceRequest {
 	req := &ProduceRequest{
-		RequiredAcks: ps.parent.conf.Producer.RequiredAcks,
+		RequiredAcks: ps.parent.conf.producerSettings(ps.topic).requiredAcks,
 		Timeout:      int32(ps.parent.conf.Producer.Timeout / time.Millisecond),
 	}
 	if ps.parent.conf.Version.IsAtLeast(V0_10_0_0) {
@@ -181,7 +187,7 @@ func (ps *produceSet) buildRequest() *ProduceRequest {
 						msg.Offset = int64(i)
 					}
 				}
-				payload, err := encode(set.recordsToSend.MsgSet, ps.parent.conf.MetricRegistry)
+				payload, err := encode(set.recordsToSend.MsgSet, ps.parent.conf.metricsRecorder())
 				if err != nil {
 					Logger.Println(err) // if this happens, it's basically our fault.
 					panic(err)
@@ -192,6 +198,8 @@ func (ps *produceSet) buildRequest() *ProduceRequest {
 					Key:              nil,
 					Value:            payload,
 					Set:              set.recordsToSend.MsgSet, // Provide the underlying message set for accurate metrics
+
+					compressionConcurrency: ps.parent.conf.Producer.CompressionConcurrency,
 				}
 				if ps.parent.conf.Version.IsAtLeast(V0_10_0_0) {
 					compMsg.Version = 1
@@ -232,6 +240,7 @@ func (ps *produceSet) wouldOverflow(msg *ProducerMessage) bool {
 	if ps.parent.conf.Version.IsAtLeast(V0_11_0_0) {
 		version = 2
 	}
+	settings := ps.parent.conf.producerSettings(ps.topic)
 
 	switch {
 	// Would we overflow our maximum possible size-on-the-wire? 10KiB is arbitrary overhead for safety.
@@ -239,10 +248,10 @@ func (ps *produceSet) wouldOverflow(msg *ProducerMessage) bool {
 		return true
 	// Would we overflow the size-limit of a message-batch for this partition?
 	case ps.msgs[msg.Topic] != nil && ps.msgs[msg.Topic][msg.Partition] != nil &&
-		ps.msgs[msg.Topic][msg.Partition].bufferBytes+msg.byteSize(version) >= ps.parent.conf.Producer.MaxMessageBytes:
+		ps.msgs[msg.Topic][msg.Partition].bufferBytes+msg.byteSize(version) >= settings.maxMessageBytes:
 		return true
 	// Would we overflow simply in number of messages?
-	case ps.parent.conf.Producer.Flush.MaxMessages > 0 && ps.bufferCount >= ps.parent.conf.Producer.Flush.MaxMessages:
+	case settings.flushMaxMessages > 0 && ps.bufferCount >= settings.flushMaxMessages:
 		return true
 	default:
 		return false
@@ -250,18 +259,20 @@ func (ps *produceSet) wouldOverflow(msg *ProducerMessage) bool {
 }
 
 func (ps *produceSet) readyToFlush() bool {
+	settings := ps.parent.conf.producerSettings(ps.topic)
+
 	switch {
 	// If we don't have any messages, nothing else matters
 	case ps.empty():
 		return false
 	// If all three config values are 0, we always flush as-fast-as-possible
-	case ps.parent.conf.Producer.Flush.Frequency == 0 && ps.parent.conf.Producer.Flush.Bytes == 0 && ps.parent.conf.Producer.Flush.Messages == 0:
+	case settings.flushFrequency == 0 && settings.flushBytes == 0 && settings.flushMessages == 0:
 		return true
 	// If we've passed the message trigger-point
-	case ps.parent.conf.Producer.Flush.Messages > 0 && ps.bufferCount >= ps.parent.conf.Producer.Flush.Messages:
+	case settings.flushMessages > 0 && ps.bufferCount >= settings.flushMessages:
 		return true
 	// If we've passed the byte trigger-point
-	case ps.parent.conf.Producer.Flush.Bytes > 0 && ps.bufferBytes >= ps.parent.conf.Producer.Flush.Bytes:
+	case settings.flushBytes > 0 && ps.bufferBytes >= settings.flushBytes:
 		return true
 	default:
 		return false
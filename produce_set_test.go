@@ -13,7 +13,7 @@ func makeProduceSet() (*asyncProducer, *produceSet) {
 		conf:   conf,
 		txnmgr: txnmgr,
 	}
-	return parent, newProduceSet(parent)
+	return parent, newProduceSet(parent, "")
 }
 
 func safeAddMessage(t *testing.T, ps *produceSet, msg *ProducerMessage) {
@@ -297,7 +297,7 @@ func TestProduceSetIdempotentRequestBuilding(t *testing.T) {
 			producerEpoch: pEpoch,
 		},
 	}
-	ps := newProduceSet(parent)
+	ps := newProduceSet(parent, "")
 
 	now := time.Now()
 	msg := &ProducerMessage{
@@ -371,7 +371,7 @@ func TestProduceSetIdempotentRequestBuilding(t *testing.T) {
 
 func TestProduceSetConsistentTimestamps(t *testing.T) {
 	parent, ps1 := makeProduceSet()
-	ps2 := newProduceSet(parent)
+	ps2 := newProduceSet(parent, "")
 	parent.conf.Producer.RequiredAcks = WaitForAll
 	parent.conf.Producer.Timeout = 10 * time.Second
 	parent.conf.Version = V0_11_0_0
@@ -425,3 +425,38 @@ func TestProduceSetConsistentTimestamps(t *testing.T) {
 		t.Errorf("Message timestamps do not match: %v, %v", time1, time2)
 	}
 }
+
+func TestProduceSetTopicOverrides(t *testing.T) {
+	parent, _ := makeProduceSet()
+	acks := NoResponse
+	overrides := ProducerTopicOverrides{RequiredAcks: &acks}
+	overrides.Flush.Messages = 2
+	parent.conf.Producer.RequiredAcks = WaitForAll
+	parent.conf.Producer.Flush.Messages = 10
+	parent.conf.Producer.TopicOverrides = map[string]ProducerTopicOverrides{"t1": overrides}
+
+	ps := newProduceSet(parent, parent.conf.producerBatch("t1"))
+	msg := &ProducerMessage{Topic: "t1", Value: StringEncoder(TestMessage)}
+	safeAddMessage(t, ps, msg)
+	if ps.readyToFlush() {
+		t.Error("Should not be ready to flush with 1 message")
+	}
+	safeAddMessage(t, ps, msg)
+	if !ps.readyToFlush() {
+		t.Error("Should be ready to flush with the 2 messages of the override")
+	}
+	if req := ps.buildRequest(); req.RequiredAcks != NoResponse {
+		t.Error("RequiredAcks of the override not set, got", req.RequiredAcks)
+	}
+
+	ps = newProduceSet(parent, parent.conf.producerBatch("t2"))
+	msg.Topic = "t2"
+	safeAddMessage(t, ps, msg)
+	safeAddMessage(t, ps, msg)
+	if ps.readyToFlush() {
+		t.Error("Should not be ready to flush without override")
+	}
+	if req := ps.buildRequest(); req.RequiredAcks != WaitForAll {
+		t.Error("RequiredAcks of the config not set, got", req.RequiredAcks)
+	}
+}
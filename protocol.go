@@ -0,0 +1,257 @@
+package sarama
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ProtocolBody is a request or a response of the Kafka protocol, such as
+// *MetadataRequest or *MetadataResponse. It is only implemented by the types
+// of this package, which EncodeRequest, DecodeRequest, EncodeResponse and
+// DecodeResponse turn into frames of the wire protocol and back.
+type ProtocolBody interface {
+	protocolBody
+}
+
+// ProtocolBodyVersion returns the API key of body, and the version of the API
+// it is encoded with.
+func ProtocolBodyVersion(body ProtocolBody) (key, version int16) {
+	return body.key(), body.version()
+}
+
+// EncodeRequest encodes a request frame, starting with its length, from the
+// correlation ID and client ID of its header and its body.
+func EncodeRequest(correlationID int32, clientID string, body ProtocolBody) ([]byte, error) {
+	return encode(&request{correlationID: correlationID, clientID: clientID, body: body}, nil)
+}
+
+// DecodeRequest decodes a request frame, starting with its length, returning
+// the correlation ID and client ID of its header and its body, whose API key
+// and version are read from the header.
+func DecodeRequest(frame []byte) (correlationID int32, clientID string, body ProtocolBody, err error) {
+	req, n, err := decodeRequest(bytes.NewReader(frame))
+	if err != nil {
+		return 0, "", nil, err
+	}
+	if n != len(frame) {
+		return 0, "", nil, PacketDecodingError{"invalid length"}
+	}
+	return req.correlationID, req.clientID, req.body, nil
+}
+
+// EncodeResponse encodes a response frame, starting with its length, from the
+// correlation ID of its header and its body.
+func EncodeResponse(correlationID int32, body ProtocolBody) ([]byte, error) {
+	return encode(&response{correlationID: correlationID, body: body}, nil)
+}
+
+// DecodeResponse decodes a response frame, starting with its length, to the
+// request of the given API key and version, as responses do not carry them.
+// It returns the correlation ID of its header and its body.
+func DecodeResponse(frame []byte, key, version int16) (correlationID int32, body ProtocolBody, err error) {
+	res := &response{body: allocateResponseBody(key, version)}
+	if res.body == nil {
+		return 0, nil, PacketDecodingError{fmt.Sprintf("unknown response key (%d)", key)}
+	}
+	if err := versionedDecode(frame, res, version); err != nil {
+		return 0, nil, err
+	}
+	return res.correlationID, res.body, nil
+}
+
+// DecodeRecords decodes the records of a partition, as sent in produce
+// requests and fetch responses: a sequence of record batches or legacy
+// message sets, whose records are decompressed. A trailing batch cut by the
+// size limit of a fetch is left out.
+func DecodeRecords(buf []byte) ([]*Records, error) {
+	var set []*Records
+	pd := &realDecoder{raw: buf}
+	for pd.remaining() > 0 {
+		records := &Records{}
+		if err := records.decode(pd); err != nil {
+			if err == ErrInsufficientData && len(set) > 0 {
+				break
+			}
+			return nil, err
+		}
+		if partial, err := records.isPartial(); err != nil || partial {
+			return set, err
+		}
+		set = append(set, records)
+	}
+	return set, nil
+}
+
+// EncodeRecords encodes a sequence of record batches or legacy message sets,
+// compressing their records according to their codec.
+func EncodeRecords(set []*Records) ([]byte, error) {
+	var buf []byte
+	for _, records := range set {
+		encoded, err := encode(records, nil)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, encoded...)
+	}
+	return buf, nil
+}
+
+// response is a response frame, whose encoding mirrors the one of request
+type response struct {
+	correlationID int32
+	body          protocolBody
+}
+
+func (r *response) encode(pe packetEncoder) error {
+	pe.push(&lengthField{})
+	pe.putInt32(r.correlationID)
+
+	if r.body.headerVersion() >= 1 {
+		pe.putUVarint(0)
+	}
+
+	if err := r.body.encode(pe); err != nil {
+		return err
+	}
+
+	return pe.pop()
+}
+
+func (r *response) decode(pd packetDecoder, version int16) (err error) {
+	if err := pd.push(&lengthField{}); err != nil {
+		return err
+	}
+
+	r.correlationID, err = pd.getInt32()
+	if err != nil {
+		return err
+	}
+
+	if r.body.headerVersion() >= 1 {
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
+	if err := r.body.decode(pd, version); err != nil {
+		return err
+	}
+
+	return pd.pop()
+}
+
+func allocateResponseBody(key, version int16) protocolBody {
+	switch key {
+	case 0:
+		return &ProduceResponse{Version: version}
+	case 1:
+		return &FetchResponse{Version: version}
+	case 2:
+		return &OffsetResponse{Version: version}
+	case 3:
+		return &MetadataResponse{Version: version}
+	case 8:
+		return &OffsetCommitResponse{Version: version}
+	case 9:
+		return &OffsetFetchResponse{Version: version}
+	case 10:
+		return &FindCoordinatorResponse{Version: version}
+	case 11:
+		return &JoinGroupResponse{Version: version}
+	case 12:
+		return &HeartbeatResponse{}
+	case 13:
+		return &LeaveGroupResponse{Version: version}
+	case 14:
+		return &SyncGroupResponse{}
+	case 15:
+		return &DescribeGroupsResponse{}
+	case 16:
+		return &ListGroupsResponse{Version: version}
+	case 17:
+		return &SaslHandshakeResponse{}
+	case 18:
+		return &ApiVersionsResponse{Version: version}
+	case 19:
+		return &CreateTopicsResponse{Version: version}
+	case 20:
+		return &DeleteTopicsResponse{Version: version}
+	case 21:
+		return &DeleteRecordsResponse{Version: version}
+	case 22:
+		return &InitProducerIDResponse{}
+	case 24:
+		return &AddPartitionsToTxnResponse{}
+	case 25:
+		return &AddOffsetsToTxnResponse{}
+	case 26:
+		return &EndTxnResponse{}
+	case 27:
+		return &WriteTxnMarkersResponse{Version: version}
+	case 28:
+		return &TxnOffsetCommitResponse{}
+	case 29:
+		return &DescribeAclsResponse{Version: version}
+	case 30:
+		return &CreateAclsResponse{}
+	case 31:
+		return &DeleteAclsResponse{Version: version}
+	case 32:
+		return &DescribeConfigsResponse{Version: version}
+	case 33:
+		return &AlterConfigsResponse{}
+	case 35:
+		return &DescribeLogDirsResponse{Version: version}
+	case 36:
+		return &SaslAuthenticateResponse{Version: version}
+	case 37:
+		return &CreatePartitionsResponse{}
+	case 38:
+		return &CreateDelegationTokenResponse{Version: version}
+	case 39:
+		return &RenewDelegationTokenResponse{Version: version}
+	case 40:
+		return &ExpireDelegationTokenResponse{Version: version}
+	case 41:
+		return &DescribeDelegationTokenResponse{Version: version}
+	case 42:
+		return &DeleteGroupsResponse{}
+	case 43:
+		return &ElectLeadersResponse{Version: version}
+	case 44:
+		return &IncrementalAlterConfigsResponse{}
+	case 45:
+		return &AlterPartitionReassignmentsResponse{Version: version}
+	case 46:
+		return &ListPartitionReassignmentsResponse{Version: version}
+	case 47:
+		return &DeleteOffsetsResponse{}
+	case 48:
+		return &DescribeClientQuotasResponse{}
+	case 49:
+		return &AlterClientQuotasResponse{}
+	case 50:
+		return &DescribeUserScramCredentialsResponse{Version: version}
+	case 51:
+		return &AlterUserScramCredentialsResponse{Version: version}
+	case 55:
+		return &DescribeQuorumResponse{Version: version}
+	case 57:
+		return &UpdateFeaturesResponse{Version: version}
+	case 61:
+		return &DescribeProducersResponse{Version: version}
+	case 64:
+		return &UnregisterBrokerResponse{Version: version}
+	case 65:
+		return &DescribeTransactionsResponse{Version: version}
+	case 66:
+		return &ListTransactionsResponse{Version: version}
+	case 68:
+		return &ConsumerGroupHeartbeatResponse{Version: version}
+	case 71:
+		return &GetTelemetrySubscriptionsResponse{Version: version}
+	case 72:
+		return &PushTelemetryResponse{Version: version}
+	}
+	return nil
+}
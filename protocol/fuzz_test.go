@@ -0,0 +1,61 @@
+//go:build go1.18
+// +build go1.18
+
+package protocol
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func FuzzDecodeRequest(f *testing.F) {
+	for _, req := range sampleRequests() {
+		frame, err := EncodeRequest(req)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(frame)
+	}
+
+	f.Fuzz(func(t *testing.T, frame []byte) {
+		req, err := DecodeRequest(frame)
+		if err != nil {
+			return
+		}
+		encoded, err := EncodeRequest(req)
+		if err != nil {
+			return
+		}
+		if _, err := DecodeRequest(encoded); err != nil {
+			t.Errorf("Failed to decode the re-encoded %s request: %v", req.APIName(), err)
+		}
+	})
+}
+
+func FuzzDecodeResponse(f *testing.F) {
+	requests := sampleRequests()
+	for i, res := range sampleResponses() {
+		frame, err := EncodeResponse(res)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(requests[i].APIKey(), requests[i].APIVersion(), frame)
+	}
+
+	f.Fuzz(func(t *testing.T, key, version int16, frame []byte) {
+		_, _, _ = sarama.DecodeResponse(frame, key, version)
+	})
+}
+
+func FuzzDecodeRecords(f *testing.F) {
+	buf, err := EncodeRecords([]*sarama.Records{{RecordBatch: sampleRecords()}})
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(buf)
+
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		_, _ = DecodeRecords(buf)
+	})
+}
@@ -0,0 +1,210 @@
+/*
+Package protocol encodes and decodes the frames of the Kafka wire protocol
+with the codecs of Sarama, for the tools sitting on the wire between clients
+and brokers, such as sniffers, proxies or replayers.
+
+A request frame carries the API key and version of its body, so it decodes on
+its own into a Request whose Body is one of the request types of Sarama, such
+as *sarama.MetadataRequest. A response frame does not: it decodes to the
+Request of the same correlation ID, which a Tracker keeps for each connection.
+
+	tracker := protocol.NewTracker()
+	req, err := protocol.ReadRequest(client)
+	...
+	tracker.Track(req)
+	frame, err := protocol.ReadFrame(broker, sarama.MaxResponseSize)
+	...
+	res, req, err := tracker.DecodeResponse(frame)
+
+The records of produce requests and fetch responses are decoded along with
+them. DecodeRecords decodes them on their own, for instance from a log segment.
+
+NOTE: this package currently does not fall under the API stability
+guarantee of Sarama as it is still considered experimental.
+*/
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// ErrUntrackedResponse is returned by Tracker.DecodeResponse when the
+// correlation ID of a response matches no tracked request.
+var ErrUntrackedResponse = errors.New("kafka: response to an untracked request")
+
+// Request is a request frame of the wire protocol.
+type Request struct {
+	CorrelationID int32
+	ClientID      string
+	Body          sarama.ProtocolBody
+}
+
+// APIKey returns the API key of the request, such as 3 for Metadata.
+func (r *Request) APIKey() int16 {
+	key, _ := sarama.ProtocolBodyVersion(r.Body)
+	return key
+}
+
+// APIVersion returns the version of the API the request is encoded with.
+func (r *Request) APIVersion() int16 {
+	_, version := sarama.ProtocolBodyVersion(r.Body)
+	return version
+}
+
+// APIName returns the name of the API of the request, such as "Metadata".
+func (r *Request) APIName() string {
+	name := strings.TrimPrefix(fmt.Sprintf("%T", r.Body), "*sarama.")
+	return strings.TrimSuffix(name, "Request")
+}
+
+// Response is a response frame of the wire protocol.
+type Response struct {
+	CorrelationID int32
+	Body          sarama.ProtocolBody
+}
+
+// ReadFrame reads a frame, starting with its length, which must not exceed
+// maxSize: sarama.MaxRequestSize for requests, sarama.MaxResponseSize for
+// responses.
+func ReadFrame(r io.Reader, maxSize int32) ([]byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	size := int32(binary.BigEndian.Uint32(length[:]))
+	if size <= 4 || size > maxSize {
+		return nil, sarama.PacketDecodingError{Info: fmt.Sprintf("message of length %d too large or too small", size)}
+	}
+
+	frame := make([]byte, 4+size)
+	copy(frame, length[:])
+	if _, err := io.ReadFull(r, frame[4:]); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// ReadRequest reads and decodes a request frame.
+func ReadRequest(r io.Reader) (*Request, error) {
+	frame, err := ReadFrame(r, sarama.MaxRequestSize)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeRequest(frame)
+}
+
+// DecodeRequest decodes a request frame, starting with its length.
+func DecodeRequest(frame []byte) (*Request, error) {
+	correlationID, clientID, body, err := sarama.DecodeRequest(frame)
+	if err != nil {
+		return nil, err
+	}
+	return &Request{CorrelationID: correlationID, ClientID: clientID, Body: body}, nil
+}
+
+// EncodeRequest encodes a request frame, starting with its length, with the
+// version of its body.
+func EncodeRequest(req *Request) ([]byte, error) {
+	return sarama.EncodeRequest(req.CorrelationID, req.ClientID, req.Body)
+}
+
+// ReadResponse reads and decodes the response frame to req.
+func ReadResponse(r io.Reader, req *Request) (*Response, error) {
+	frame, err := ReadFrame(r, sarama.MaxResponseSize)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeResponse(frame, req)
+}
+
+// DecodeResponse decodes the response frame to req, starting with its
+// length, with the API key and version of req.
+func DecodeResponse(frame []byte, req *Request) (*Response, error) {
+	correlationID, body, err := sarama.DecodeResponse(frame, req.APIKey(), req.APIVersion())
+	if err != nil {
+		return nil, err
+	}
+	return &Response{CorrelationID: correlationID, Body: body}, nil
+}
+
+// EncodeResponse encodes a response frame, starting with its length, with the
+// version of its body.
+func EncodeResponse(res *Response) ([]byte, error) {
+	return sarama.EncodeResponse(res.CorrelationID, res.Body)
+}
+
+// DecodeRecords decodes the records of a partition: a sequence of record
+// batches or legacy message sets, whose records are decompressed.
+func DecodeRecords(buf []byte) ([]*sarama.Records, error) {
+	return sarama.DecodeRecords(buf)
+}
+
+// EncodeRecords encodes the records of a partition, compressing them
+// according to the codec of their batch.
+func EncodeRecords(set []*sarama.Records) ([]byte, error) {
+	return sarama.EncodeRecords(set)
+}
+
+// Tracker keeps the requests sent on a connection until their response is
+// decoded. It is safe for concurrent use, so that the requests and responses
+// of a connection can be decoded by different goroutines.
+type Tracker struct {
+	lock     sync.Mutex
+	inflight map[int32]*Request
+}
+
+// NewTracker returns a Tracker for a new connection.
+func NewTracker() *Tracker {
+	return &Tracker{inflight: make(map[int32]*Request)}
+}
+
+// Track keeps req until its response is decoded. Produce requests which
+// require no acks are not kept, as the broker does not answer them.
+func (t *Tracker) Track(req *Request) {
+	if produce, ok := req.Body.(*sarama.ProduceRequest); ok && produce.RequiredAcks == sarama.NoResponse {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.inflight[req.CorrelationID] = req
+}
+
+// Pending returns the number of tracked requests still waiting for their
+// response.
+func (t *Tracker) Pending() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return len(t.inflight)
+}
+
+// DecodeResponse decodes a response frame to the tracked request of the same
+// correlation ID, which is returned along with the response and no longer
+// tracked.
+func (t *Tracker) DecodeResponse(frame []byte) (*Response, *Request, error) {
+	if len(frame) < 8 {
+		return nil, nil, sarama.PacketDecodingError{Info: "response frame too short"}
+	}
+	correlationID := int32(binary.BigEndian.Uint32(frame[4:]))
+
+	t.lock.Lock()
+	req, ok := t.inflight[correlationID]
+	delete(t.inflight, correlationID)
+	t.lock.Unlock()
+
+	if !ok {
+		return nil, nil, ErrUntrackedResponse
+	}
+	res, err := DecodeResponse(frame, req)
+	if err != nil {
+		return nil, req, err
+	}
+	return res, req, nil
+}
@@ -0,0 +1,153 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func sampleRecords() *sarama.RecordBatch {
+	return &sarama.RecordBatch{
+		Version:        2,
+		Codec:          sarama.CompressionGZIP,
+		FirstTimestamp: time.Unix(1600000000, 0),
+		MaxTimestamp:   time.Unix(1600000000, 0),
+		Records: []*sarama.Record{
+			{Key: []byte("key"), Value: []byte("value"), Headers: []*sarama.RecordHeader{{Key: []byte("h"), Value: []byte("v")}}},
+			{OffsetDelta: 1, Value: []byte("other")},
+		},
+	}
+}
+
+func sampleRequests() []*Request {
+	produce := &sarama.ProduceRequest{Version: 3, RequiredAcks: sarama.WaitForAll, Timeout: 1000}
+	produce.AddBatch("my_topic", 0, sampleRecords())
+	fetch := &sarama.FetchRequest{Version: 11, MaxWaitTime: 500, MinBytes: 1, MaxBytes: 1024, Isolation: sarama.ReadCommitted}
+	fetch.AddBlock("my_topic", 0, 42, 1024)
+
+	return []*Request{
+		{CorrelationID: 1, ClientID: "client", Body: &sarama.ApiVersionsRequest{Version: 3, ClientSoftwareName: "sarama", ClientSoftwareVersion: "1.0"}},
+		{CorrelationID: 2, ClientID: "client", Body: &sarama.MetadataRequest{Version: 9, Topics: []string{"my_topic"}}},
+		{CorrelationID: 3, ClientID: "client", Body: produce},
+		{CorrelationID: 4, ClientID: "client", Body: fetch},
+	}
+}
+
+func sampleResponses() []*Response {
+	metadata := &sarama.MetadataResponse{Version: 9, ControllerID: 1}
+	metadata.AddBroker("localhost:9092", 1)
+	metadata.AddTopicPartition("my_topic", 0, 1, []int32{1}, []int32{1}, []int32{}, sarama.ErrNoError)
+	produce := &sarama.ProduceResponse{Version: 3}
+	produce.AddTopicPartition("my_topic", 0, sarama.ErrNoError)
+	fetch := &sarama.FetchResponse{Version: 11}
+	fetch.AddRecordBatch("my_topic", 0, sarama.StringEncoder("key"), sarama.StringEncoder("value"), 42, -1, false)
+
+	return []*Response{
+		{CorrelationID: 1, Body: &sarama.ApiVersionsResponse{Version: 3, ApiVersions: []*sarama.ApiVersionsResponseBlock{{ApiKey: 3, MaxVersion: 9}}}},
+		{CorrelationID: 2, Body: metadata},
+		{CorrelationID: 3, Body: produce},
+		{CorrelationID: 4, Body: fetch},
+	}
+}
+
+func TestRequestRoundTrip(t *testing.T) {
+	var stream bytes.Buffer
+	for _, req := range sampleRequests() {
+		frame, err := EncodeRequest(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream.Write(frame)
+	}
+
+	for _, expected := range sampleRequests() {
+		req, err := ReadRequest(&stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.CorrelationID != expected.CorrelationID || req.ClientID != expected.ClientID ||
+			req.APIKey() != expected.APIKey() || req.APIVersion() != expected.APIVersion() {
+			t.Errorf("Unexpected header of %s request: %+v", expected.APIName(), req)
+		}
+		if produce, ok := req.Body.(*sarama.ProduceRequest); ok {
+			if produce.RequiredAcks != sarama.WaitForAll {
+				t.Errorf("Unexpected produce request %+v", produce)
+			}
+		}
+	}
+	if stream.Len() != 0 {
+		t.Errorf("Expected the stream to be read, %d bytes left", stream.Len())
+	}
+}
+
+func TestTrackerDecodeResponse(t *testing.T) {
+	tracker := NewTracker()
+	for _, req := range sampleRequests() {
+		tracker.Track(req)
+	}
+	tracker.Track(&Request{CorrelationID: 5, Body: &sarama.ProduceRequest{RequiredAcks: sarama.NoResponse}})
+	if tracker.Pending() != 4 {
+		t.Errorf("Expected 4 pending requests, got %d", tracker.Pending())
+	}
+
+	for _, expected := range sampleResponses() {
+		frame, err := EncodeResponse(expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, req, err := tracker.DecodeResponse(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.CorrelationID != expected.CorrelationID || res.CorrelationID != expected.CorrelationID {
+			t.Errorf("Expected the response to match request %d, got request %d", expected.CorrelationID, req.CorrelationID)
+		}
+		if reflect.TypeOf(res.Body) != reflect.TypeOf(expected.Body) {
+			t.Errorf("Expected a %T, got %T", expected.Body, res.Body)
+		}
+	}
+
+	fetch := sampleResponses()[3].Body.(*sarama.FetchResponse)
+	frame, err := EncodeResponse(&Response{CorrelationID: 4, Body: fetch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tracker.DecodeResponse(frame); !errors.Is(err, ErrUntrackedResponse) {
+		t.Errorf("Expected ErrUntrackedResponse, got %v", err)
+	}
+	if tracker.Pending() != 0 {
+		t.Errorf("Expected no pending requests, got %d", tracker.Pending())
+	}
+}
+
+func TestRecordsRoundTrip(t *testing.T) {
+	set := []*sarama.Records{{RecordBatch: sampleRecords()}, {RecordBatch: sampleRecords()}}
+	set[1].RecordBatch.FirstOffset = 2
+	buf, err := EncodeRecords(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeRecords(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[1].RecordBatch.FirstOffset != 2 || len(decoded[0].RecordBatch.Records) != 2 {
+		t.Fatalf("Unexpected records %+v", decoded)
+	}
+	if rec := decoded[0].RecordBatch.Records[0]; string(rec.Value) != "value" || string(rec.Headers[0].Key) != "h" {
+		t.Errorf("Unexpected record %+v", rec)
+	}
+
+	decoded, err = DecodeRecords(buf[:len(buf)-10])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 1 {
+		t.Errorf("Expected the truncated batch to be left out, got %d batches", len(decoded))
+	}
+}
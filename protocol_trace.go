@@ -0,0 +1,87 @@
+package sarama
+
+import "time"
+
+// tracesRequest returns whether req is traced by Net.ProtocolTrace
+func (b *Broker) tracesRequest(req protocolBody) bool {
+	trace := &b.conf.Net.ProtocolTrace
+	if !trace.Enable {
+		return false
+	}
+	if len(trace.Brokers) > 0 && !int32SliceContains(trace.Brokers, b.id) {
+		return false
+	}
+	return len(trace.Topics) == 0 || requestConcernsTopics(req, trace.Topics)
+}
+
+// requestConcernsTopics returns whether req is one of the requests of
+// Net.ProtocolTrace.Topics concerning one of topics
+func requestConcernsTopics(req protocolBody, topics []string) bool {
+	var concerned []string
+	switch r := req.(type) {
+	case *ProduceRequest:
+		for topic := range r.records {
+			concerned = append(concerned, topic)
+		}
+	case *FetchRequest:
+		for topic := range r.blocks {
+			concerned = append(concerned, topic)
+		}
+	case *MetadataRequest:
+		concerned = r.Topics
+	case *OffsetRequest:
+		for topic := range r.blocks {
+			concerned = append(concerned, topic)
+		}
+	case *OffsetCommitRequest:
+		for topic := range r.blocks {
+			concerned = append(concerned, topic)
+		}
+	case *OffsetFetchRequest:
+		for topic := range r.partitions {
+			concerned = append(concerned, topic)
+		}
+	}
+	for _, topic := range concerned {
+		if stringSliceContains(topics, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// traceFields returns the fields common to the trace entries of req
+func (b *Broker) traceFields(req protocolBody, correlationID int32) []LogField {
+	return []LogField{
+		brokerField(b.id),
+		addrField(b.addr),
+		{Key: "request", Value: requestName(req)},
+		{Key: "api_key", Value: req.key()},
+		{Key: "api_version", Value: req.version()},
+		{Key: LogKeyCorrelationID, Value: correlationID},
+	}
+}
+
+// traceRequest traces req, of size bytes, failing with err if not nil
+func (b *Broker) traceRequest(req protocolBody, correlationID int32, size int, err error) {
+	fields := append(b.traceFields(req, correlationID), LogField{Key: "size", Value: size})
+	if err != nil {
+		fields = append(fields, errorField(err))
+	}
+	logEntry(LogComponentNetwork, LogLevelInfo, "broker/trace request", fields...)
+}
+
+// traceResponse traces the response res to req, of size bytes, failing with
+// err if not nil
+func (b *Broker) traceResponse(req protocolBody, promise *responsePromise, size int, res protocolBody, err error) {
+	fields := append(b.traceFields(req, promise.correlationID),
+		LogField{Key: "size", Value: size},
+		LogField{Key: "latency", Value: time.Since(promise.requestTime)})
+	if throttled, ok := res.(throttleSupport); ok && err == nil && throttled.throttleTime() > 0 {
+		fields = append(fields, LogField{Key: "throttle", Value: throttled.throttleTime()})
+	}
+	if err != nil {
+		fields = append(fields, errorField(err))
+	}
+	logEntry(LogComponentNetwork, LogLevelInfo, "broker/trace response", fields...)
+}
@@ -0,0 +1,72 @@
+package sarama
+
+// PushTelemetryRequest is sent by a client to push its metrics to a broker, as
+// requested by the subscription of GetTelemetrySubscriptionsResponse (KIP-714)
+type PushTelemetryRequest struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	ClientInstanceId Uuid
+	SubscriptionId   int32
+	// Terminating is set on the last push, when the client is closed
+	Terminating     bool
+	CompressionType CompressionCodec
+	// Metrics is an OpenTelemetry MetricsData protobuf message, compressed
+	// with CompressionType
+	Metrics []byte
+}
+
+func (r *PushTelemetryRequest) encode(pe packetEncoder) error {
+	if err := pe.putRawBytes(r.ClientInstanceId[:]); err != nil {
+		return err
+	}
+	pe.putInt32(r.SubscriptionId)
+	pe.putBool(r.Terminating)
+	pe.putInt8(int8(r.CompressionType))
+	if err := pe.putCompactBytes(r.Metrics); err != nil {
+		return err
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *PushTelemetryRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	clientInstanceID, err := pd.getRawBytes(len(r.ClientInstanceId))
+	if err != nil {
+		return err
+	}
+	copy(r.ClientInstanceId[:], clientInstanceID)
+	if r.SubscriptionId, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if r.Terminating, err = pd.getBool(); err != nil {
+		return err
+	}
+	codec, err := pd.getInt8()
+	if err != nil {
+		return err
+	}
+	r.CompressionType = CompressionCodec(codec)
+	if r.Metrics, err = pd.getCompactBytes(); err != nil {
+		return err
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *PushTelemetryRequest) key() int16 {
+	return 72
+}
+
+func (r *PushTelemetryRequest) version() int16 {
+	return r.Version
+}
+
+func (r *PushTelemetryRequest) headerVersion() int16 {
+	return 2
+}
+
+func (r *PushTelemetryRequest) requiredVersion() KafkaVersion {
+	return V3_7_0_0
+}
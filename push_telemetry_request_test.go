@@ -0,0 +1,24 @@
+package sarama
+
+import "testing"
+
+var pushTelemetryRequest = []byte{
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, // ClientInstanceId
+	0, 0, 0, 7, // SubscriptionId
+	1,             // Terminating
+	1,             // CompressionType
+	4, 0x0a, 0, 0, // Metrics
+	0, // empty tagged fields
+}
+
+func TestPushTelemetryRequest(t *testing.T) {
+	request := &PushTelemetryRequest{
+		Version:          0,
+		ClientInstanceId: Uuid{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		SubscriptionId:   7,
+		Terminating:      true,
+		CompressionType:  CompressionGZIP,
+		Metrics:          []byte{0x0a, 0, 0},
+	}
+	testRequest(t, "terminating", request, pushTelemetryRequest)
+}
@@ -0,0 +1,57 @@
+package sarama
+
+import "time"
+
+// PushTelemetryResponse is the response to a PushTelemetryRequest
+type PushTelemetryResponse struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	ThrottleTime time.Duration
+	Err          KError
+}
+
+func (r *PushTelemetryResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	pe.putInt16(int16(r.Err))
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *PushTelemetryResponse) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	throttleTime, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *PushTelemetryResponse) key() int16 {
+	return 72
+}
+
+func (r *PushTelemetryResponse) version() int16 {
+	return r.Version
+}
+
+func (r *PushTelemetryResponse) headerVersion() int16 {
+	return 1
+}
+
+func (r *PushTelemetryResponse) requiredVersion() KafkaVersion {
+	return V3_7_0_0
+}
+
+func (r *PushTelemetryResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
@@ -0,0 +1,21 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var pushTelemetryResponse = []byte{
+	0, 0, 0, 100, // ThrottleTimeMs
+	0, 117, // ErrorCode
+	0, // empty tagged fields
+}
+
+func TestPushTelemetryResponse(t *testing.T) {
+	response := &PushTelemetryResponse{
+		Version:      0,
+		ThrottleTime: 100 * time.Millisecond,
+		Err:          ErrUnknownSubscriptionID,
+	}
+	testResponse(t, "unknown subscription", response, pushTelemetryResponse)
+}
@@ -19,3 +19,15 @@ const (
 	QuotaMatchDefault
 	QuotaMatchAny
 )
+
+// Quota configuration keys of ClientQuotasOp, the byte rates are in bytes per
+// second and the request percentage is relative to a single request handler
+// and network thread. Connection creation rates only apply to IP entities.
+// ref: https://github.com/apache/kafka/blob/trunk/clients/src/main/java/org/apache/kafka/common/config/internals/QuotaConfigs.java
+const (
+	QuotaProducerByteRate         = "producer_byte_rate"
+	QuotaConsumerByteRate         = "consumer_byte_rate"
+	QuotaRequestPercentage        = "request_percentage"
+	QuotaControllerMutationRate   = "controller_mutation_rate"
+	QuotaIPConnectionCreationRate = "connection_creation_rate"
+)
@@ -0,0 +1,120 @@
+package sarama
+
+type requestTargetKind int8
+
+const (
+	brokerTarget requestTargetKind = iota
+	anyBrokerTarget
+	controllerTarget
+	coordinatorTarget
+)
+
+// RequestTarget selects the broker Client.SendRequest sends a request to.
+type RequestTarget struct {
+	kind     requestTargetKind
+	brokerID int32
+	group    string
+}
+
+// BrokerTarget targets the broker with the given ID.
+func BrokerTarget(brokerID int32) RequestTarget {
+	return RequestTarget{kind: brokerTarget, brokerID: brokerID}
+}
+
+// AnyBrokerTarget targets any broker of the cluster, as for metadata requests.
+func AnyBrokerTarget() RequestTarget {
+	return RequestTarget{kind: anyBrokerTarget}
+}
+
+// ControllerTarget targets the controller of the cluster.
+func ControllerTarget() RequestTarget {
+	return RequestTarget{kind: controllerTarget}
+}
+
+// CoordinatorTarget targets the coordinator of the given consumer group.
+func CoordinatorTarget(group string) RequestTarget {
+	return RequestTarget{kind: coordinatorTarget, group: group}
+}
+
+// RawRequest is a request of any API, encoded by the caller, sent with
+// Client.SendRequest for the APIs Sarama does not wrap yet. Sarama writes the
+// request header, with the correlation ID and client ID, and waits for the
+// throttle time of the previous responses of the connection.
+type RawRequest struct {
+	// APIKey and APIVersion are the key and version of the request
+	APIKey     int16
+	APIVersion int16
+	// Flexible is whether APIVersion is a flexible version of the API, as
+	// introduced by KIP-482, whose request and response headers carry tagged
+	// fields.
+	Flexible bool
+	// Body is the encoded request, without the request header
+	Body []byte
+}
+
+// RawResponse is the response to a RawRequest.
+type RawResponse struct {
+	// Body is the encoded response, without the response header
+	Body []byte
+
+	request *RawRequest
+}
+
+func (r *RawRequest) encode(pe packetEncoder) error {
+	return pe.putRawBytes(r.Body)
+}
+
+func (r *RawRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.APIVersion = version
+	r.Body, err = pd.getRawBytes(pd.remaining())
+	return err
+}
+
+func (r *RawRequest) key() int16 {
+	return r.APIKey
+}
+
+func (r *RawRequest) version() int16 {
+	return r.APIVersion
+}
+
+func (r *RawRequest) headerVersion() int16 {
+	if r.Flexible {
+		return 2
+	}
+	return 1
+}
+
+func (r *RawRequest) requiredVersion() KafkaVersion {
+	return MinVersion
+}
+
+func (r *RawResponse) encode(pe packetEncoder) error {
+	return pe.putRawBytes(r.Body)
+}
+
+func (r *RawResponse) decode(pd packetDecoder, version int16) (err error) {
+	r.Body, err = pd.getRawBytes(pd.remaining())
+	return err
+}
+
+func (r *RawResponse) key() int16 {
+	return r.request.APIKey
+}
+
+func (r *RawResponse) version() int16 {
+	return r.request.APIVersion
+}
+
+func (r *RawResponse) headerVersion() int16 {
+	// the ApiVersions responses never have tagged fields in their header, so
+	// that brokers not supporting the request version can still be read
+	if r.request.Flexible && r.request.APIKey != 18 { // ApiVersions
+		return 1
+	}
+	return 0
+}
+
+func (r *RawResponse) requiredVersion() KafkaVersion {
+	return MinVersion
+}
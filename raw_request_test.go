@@ -0,0 +1,48 @@
+package sarama
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRawRequestHeaderVersions(t *testing.T) {
+	tests := []struct {
+		request  *RawRequest
+		header   int16
+		response int16
+	}{
+		{&RawRequest{APIKey: 3, APIVersion: 8}, 1, 0},
+		{&RawRequest{APIKey: 3, APIVersion: 9, Flexible: true}, 2, 1},
+		// the header of the ApiVersions responses is never flexible
+		{&RawRequest{APIKey: 18, APIVersion: 3, Flexible: true}, 2, 0},
+	}
+	for _, tt := range tests {
+		response := &RawResponse{request: tt.request}
+		if v := tt.request.headerVersion(); v != tt.header {
+			t.Errorf("%d/%d: expected request header version %d, got %d", tt.request.APIKey, tt.request.APIVersion, tt.header, v)
+		}
+		if v := response.headerVersion(); v != tt.response {
+			t.Errorf("%d/%d: expected response header version %d, got %d", tt.request.APIKey, tt.request.APIVersion, tt.response, v)
+		}
+	}
+}
+
+func TestRawRequestEncoding(t *testing.T) {
+	metadata := &MetadataRequest{Version: 1, Topics: []string{"foo"}}
+	body, err := encode(metadata, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the raw request is sent as the request it encodes
+	request := &RawRequest{APIKey: metadata.key(), APIVersion: metadata.version(), Body: body}
+	packet := testRequestEncode(t, "raw", request, body)
+	decoded, _, err := decodeRequest(bytes.NewReader(packet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.body, metadata) {
+		t.Errorf("expected %v, got %v", metadata, decoded.body)
+	}
+}
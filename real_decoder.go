@@ -6,13 +6,12 @@ import (
 )
 
 var (
-	errInvalidArrayLength      = PacketDecodingError{"invalid array length"}
-	errInvalidByteSliceLength  = PacketDecodingError{"invalid byteslice length"}
-	errInvalidStringLength     = PacketDecodingError{"invalid string length"}
-	errVarintOverflow          = PacketDecodingError{"varint overflow"}
-	errUVarintOverflow         = PacketDecodingError{"uvarint overflow"}
-	errInvalidBool             = PacketDecodingError{"invalid bool"}
-	errUnsupportedTaggedFields = PacketDecodingError{"non-empty tagged fields are not supported yet"}
+	errInvalidArrayLength     = PacketDecodingError{"invalid array length"}
+	errInvalidByteSliceLength = PacketDecodingError{"invalid byteslice length"}
+	errInvalidStringLength    = PacketDecodingError{"invalid string length"}
+	errVarintOverflow         = PacketDecodingError{"varint overflow"}
+	errUVarintOverflow        = PacketDecodingError{"uvarint overflow"}
+	errInvalidBool            = PacketDecodingError{"invalid bool"}
 )
 
 type realDecoder struct {
@@ -110,7 +109,9 @@ func (rd *realDecoder) getArrayLength() (int, error) {
 	}
 	tmp := int(int32(binary.BigEndian.Uint32(rd.raw[rd.off:])))
 	rd.off += 4
-	if tmp > rd.remaining() {
+	if tmp < -1 {
+		return -1, errInvalidArrayLength
+	} else if tmp > rd.remaining() {
 		rd.off = len(rd.raw)
 		return -1, ErrInsufficientData
 	} else if tmp > 2*math.MaxUint16 {
@@ -129,6 +130,11 @@ func (rd *realDecoder) getCompactArrayLength() (int, error) {
 		return 0, nil
 	}
 
+	if n-1 > uint64(rd.remaining()) {
+		rd.off = len(rd.raw)
+		return -1, ErrInsufficientData
+	}
+
 	return int(n) - 1, nil
 }
 
@@ -143,17 +149,34 @@ func (rd *realDecoder) getBool() (bool, error) {
 	return true, nil
 }
 
+// getEmptyTaggedFieldArray skips the tagged fields, which are all unknown to
+// the caller, and returns their number
 func (rd *realDecoder) getEmptyTaggedFieldArray() (int, error) {
 	tagCount, err := rd.getUVarint()
 	if err != nil {
 		return 0, err
 	}
+	if tagCount > uint64(rd.remaining()) {
+		rd.off = len(rd.raw)
+		return 0, ErrInsufficientData
+	}
 
-	if tagCount != 0 {
-		return 0, errUnsupportedTaggedFields
+	for i := uint64(0); i < tagCount; i++ {
+		if _, err := rd.getUVarint(); err != nil {
+			return 0, err
+		}
+		size, err := rd.getUVarint()
+		if err != nil {
+			return 0, err
+		}
+		if size > uint64(rd.remaining()) {
+			rd.off = len(rd.raw)
+			return 0, ErrInsufficientData
+		}
+		rd.off += int(size)
 	}
 
-	return 0, nil
+	return int(tagCount), nil
 }
 
 // collections
@@ -188,8 +211,13 @@ func (rd *realDecoder) getCompactBytes() ([]byte, error) {
 		return nil, err
 	}
 
-	length := int(n - 1)
-	return rd.getRawBytes(length)
+	if n == 0 {
+		return nil, errInvalidByteSliceLength
+	} else if n-1 > uint64(rd.remaining()) {
+		rd.off = len(rd.raw)
+		return nil, ErrInsufficientData
+	}
+	return rd.getRawBytes(int(n - 1))
 }
 
 func (rd *realDecoder) getStringLength() (int, error) {
@@ -239,11 +267,18 @@ func (rd *realDecoder) getCompactString() (string, error) {
 		return "", err
 	}
 
-	length := int(n - 1)
+	if n == 0 {
+		return "", errInvalidStringLength
+	} else if n-1 > uint64(rd.remaining()) {
+		rd.off = len(rd.raw)
+		return "", ErrInsufficientData
+	}
 
-	tmpStr := string(rd.raw[rd.off : rd.off+length])
-	rd.off += length
-	return tmpStr, nil
+	buf, err := rd.getRawBytes(int(n - 1))
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
 }
 
 func (rd *realDecoder) getCompactNullableString() (*string, error) {
@@ -252,15 +287,19 @@ func (rd *realDecoder) getCompactNullableString() (*string, error) {
 		return nil, err
 	}
 
-	length := int(n - 1)
+	if n == 0 {
+		return nil, nil
+	} else if n-1 > uint64(rd.remaining()) {
+		rd.off = len(rd.raw)
+		return nil, ErrInsufficientData
+	}
 
-	if length < 0 {
+	buf, err := rd.getRawBytes(int(n - 1))
+	if err != nil {
 		return nil, err
 	}
-
-	tmpStr := string(rd.raw[rd.off : rd.off+length])
-	rd.off += length
-	return &tmpStr, err
+	tmpStr := string(buf)
+	return &tmpStr, nil
 }
 
 func (rd *realDecoder) getCompactInt32Array() ([]int32, error) {
@@ -273,6 +312,11 @@ func (rd *realDecoder) getCompactInt32Array() ([]int32, error) {
 		return nil, nil
 	}
 
+	if n-1 > uint64(rd.remaining()/4) {
+		rd.off = len(rd.raw)
+		return nil, ErrInsufficientData
+	}
+
 	arrayLength := int(n) - 1
 
 	ret := make([]int32, arrayLength)
@@ -350,6 +394,11 @@ func (rd *realDecoder) getStringArray() ([]string, error) {
 	n := int(binary.BigEndian.Uint32(rd.raw[rd.off:]))
 	rd.off += 4
 
+	if rd.remaining() < 2*n {
+		rd.off = len(rd.raw)
+		return nil, ErrInsufficientData
+	}
+
 	if n == 0 {
 		return nil, nil
 	}
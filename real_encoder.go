@@ -4,15 +4,13 @@ import (
 	"encoding/binary"
 	"errors"
 	"math"
-
-	"github.com/rcrowley/go-metrics"
 )
 
 type realEncoder struct {
 	raw      []byte
 	off      int
 	stack    []pushEncoder
-	registry metrics.Registry
+	recorder MetricsRecorder
 }
 
 // primitives
@@ -214,6 +212,6 @@ func (re *realEncoder) pop() error {
 }
 
 // we do record metrics during the real encoder pass
-func (re *realEncoder) metricRegistry() metrics.Registry {
-	return re.registry
+func (re *realEncoder) metricsRecorder() MetricsRecorder {
+	return re.recorder
 }
@@ -47,8 +47,10 @@ type RecordBatch struct {
 	PartialTrailingRecord bool
 	IsTransactional       bool
 
-	compressedRecords []byte
-	recordsLen        int // uncompressed records size
+	compressedRecords      []byte
+	recordsLen             int           // uncompressed records size
+	compressionTime        time.Duration // time spent compressing the records
+	compressionConcurrency int           // see Producer.CompressionConcurrency
 }
 
 func (b *RecordBatch) LastOffset() int64 {
@@ -197,12 +199,16 @@ func (b *RecordBatch) decode(pd packetDecoder) (err error) {
 func (b *RecordBatch) encodeRecords(pe packetEncoder) error {
 	var raw []byte
 	var err error
-	if raw, err = encode(recordsArray(b.Records), pe.metricRegistry()); err != nil {
+	if raw, err = encode(recordsArray(b.Records), pe.metricsRecorder()); err != nil {
 		return err
 	}
 	b.recordsLen = len(raw)
 
-	b.compressedRecords, err = compress(b.Codec, b.CompressionLevel, raw)
+	start := time.Now()
+	b.compressedRecords, err = compress(b.Codec, b.CompressionLevel, b.compressionConcurrency, raw)
+	if b.Codec != CompressionNone {
+		b.compressionTime = time.Since(start)
+	}
 	return err
 }
 
@@ -0,0 +1,64 @@
+package sarama
+
+import "time"
+
+// RenewDelegationTokenRequest renews a delegation token, extending its expiry time.
+type RenewDelegationTokenRequest struct {
+	Version int16
+	// HMAC identifies the token
+	HMAC []byte
+	// RenewPeriod extends the expiry time of the token by the given period from
+	// now, -1 to use the renew period configured on the brokers
+	RenewPeriod time.Duration
+}
+
+func (r *RenewDelegationTokenRequest) encode(pe packetEncoder) error {
+	if err := pe.putBytes(r.HMAC); err != nil {
+		return err
+	}
+
+	period := int64(-1)
+	if r.RenewPeriod >= 0 {
+		period = int64(r.RenewPeriod / time.Millisecond)
+	}
+	pe.putInt64(period)
+	return nil
+}
+
+func (r *RenewDelegationTokenRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.HMAC, err = pd.getBytes(); err != nil {
+		return err
+	}
+
+	period, err := pd.getInt64()
+	if err != nil {
+		return err
+	}
+	r.RenewPeriod = -1
+	if period >= 0 {
+		r.RenewPeriod = time.Duration(period) * time.Millisecond
+	}
+	return nil
+}
+
+func (r *RenewDelegationTokenRequest) key() int16 {
+	return 39
+}
+
+func (r *RenewDelegationTokenRequest) version() int16 {
+	return r.Version
+}
+
+func (r *RenewDelegationTokenRequest) headerVersion() int16 {
+	return 1
+}
+
+func (r *RenewDelegationTokenRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V2_0_0_0
+	default:
+		return V1_1_0_0
+	}
+}
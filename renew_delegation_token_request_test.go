@@ -0,0 +1,20 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var renewDelegationTokenRequest = []byte{
+	0, 0, 0, 4, 'h', 'm', 'a', 'c',
+	0, 0, 0, 0, 0, 0, 0x03, 0xe8, // renew period 1000ms
+}
+
+func TestRenewDelegationTokenRequest(t *testing.T) {
+	request := &RenewDelegationTokenRequest{
+		Version:     1,
+		HMAC:        []byte("hmac"),
+		RenewPeriod: time.Second,
+	}
+	testRequest(t, "renew", request, renewDelegationTokenRequest)
+}
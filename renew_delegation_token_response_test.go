@@ -0,0 +1,20 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var renewDelegationTokenResponse = []byte{
+	0, 0, // no error
+	0, 0, 0, 0, 0, 0, 0x07, 0xd0, // expiry timestamp 2000
+	0, 0, 0, 0, // throttle time
+}
+
+func TestRenewDelegationTokenResponse(t *testing.T) {
+	response := &RenewDelegationTokenResponse{
+		Version:    1,
+		ExpiryTime: time.Unix(2, 0),
+	}
+	testResponse(t, "renew", response, renewDelegationTokenResponse)
+}
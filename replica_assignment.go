@@ -0,0 +1,160 @@
+package sarama
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ReplicaAssignmentBroker is a broker replicas can be assigned to by
+// AssignReplicas, along with its rack which is empty when unknown.
+type ReplicaAssignmentBroker struct {
+	ID   int32
+	Rack string
+}
+
+// AssignReplicas computes a balanced replica assignment of numPartitions
+// partitions with the given replication factor over the brokers, the same way
+// Kafka does when creating topics without an explicit assignment. When the
+// brokers have a rack, the replicas of each partition are spread over as many
+// racks as possible. Either all the brokers or none of them must have a rack.
+//
+// startPartition is the ID of the first partition to assign, which is the
+// current partition count when adding partitions to a topic. startIndex
+// selects the broker of the leader of the first partition and is usually
+// random so the leaders of several topics are spread over the cluster.
+func AssignReplicas(brokers []ReplicaAssignmentBroker, numPartitions int32, replicationFactor int16, startPartition int32, startIndex int) ([][]int32, error) {
+	if numPartitions <= 0 {
+		return nil, fmt.Errorf("%w: number of partitions must be larger than 0", ErrInvalidPartitions)
+	}
+	if replicationFactor <= 0 {
+		return nil, fmt.Errorf("%w: replication factor must be larger than 0", ErrInvalidReplicationFactor)
+	}
+	if int(replicationFactor) > len(brokers) {
+		return nil, fmt.Errorf("%w: replication factor %d larger than the %d available brokers",
+			ErrInvalidReplicationFactor, replicationFactor, len(brokers))
+	}
+
+	racks := make(map[int32]string, len(brokers))
+	withRack := 0
+	for _, broker := range brokers {
+		racks[broker.ID] = broker.Rack
+		if broker.Rack != "" {
+			withRack++
+		}
+	}
+	if withRack > 0 && withRack < len(brokers) {
+		return nil, fmt.Errorf("%w: not all brokers have rack information", ErrInvalidReplicaAssignment)
+	}
+	if startIndex < 0 {
+		startIndex = 0
+	}
+
+	if withRack == 0 {
+		ids := make([]int32, 0, len(brokers))
+		for _, broker := range brokers {
+			ids = append(ids, broker.ID)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		return assignReplicasRackUnaware(ids, numPartitions, replicationFactor, startPartition, startIndex), nil
+	}
+	return assignReplicasRackAware(racks, numPartitions, replicationFactor, startPartition, startIndex), nil
+}
+
+// replicaIndex returns the index of a follower replica, shifted from the
+// leader so the followers of consecutive partitions are spread over the brokers
+func replicaIndex(firstReplicaIndex, secondReplicaShift, index, numBrokers int) int {
+	shift := 1 + (secondReplicaShift+index)%(numBrokers-1)
+	return (firstReplicaIndex + shift) % numBrokers
+}
+
+func assignReplicasRackUnaware(brokers []int32, numPartitions int32, replicationFactor int16, startPartition int32, startIndex int) [][]int32 {
+	numBrokers := len(brokers)
+	assignment := make([][]int32, 0, numPartitions)
+	nextReplicaShift := startIndex
+	for partition := startPartition; partition < startPartition+numPartitions; partition++ {
+		if partition > 0 && int(partition)%numBrokers == 0 {
+			nextReplicaShift++
+		}
+		firstReplicaIndex := (int(partition) + startIndex) % numBrokers
+		replicas := []int32{brokers[firstReplicaIndex]}
+		for i := 0; i < int(replicationFactor)-1; i++ {
+			replicas = append(replicas, brokers[replicaIndex(firstReplicaIndex, nextReplicaShift, i, numBrokers)])
+		}
+		assignment = append(assignment, replicas)
+	}
+	return assignment
+}
+
+func assignReplicasRackAware(racks map[int32]string, numPartitions int32, replicationFactor int16, startPartition int32, startIndex int) [][]int32 {
+	brokers := rackAlternatedBrokers(racks)
+	numBrokers := len(brokers)
+	numRacks := 0
+	seenRacks := make(map[string]none)
+	for _, rack := range racks {
+		if _, ok := seenRacks[rack]; !ok {
+			seenRacks[rack] = none{}
+			numRacks++
+		}
+	}
+
+	assignment := make([][]int32, 0, numPartitions)
+	nextReplicaShift := startIndex
+	for partition := startPartition; partition < startPartition+numPartitions; partition++ {
+		if partition > 0 && int(partition)%numBrokers == 0 {
+			nextReplicaShift++
+		}
+		firstReplicaIndex := (int(partition) + startIndex) % numBrokers
+		leader := brokers[firstReplicaIndex]
+		replicas := []int32{leader}
+		racksWithReplicas := map[string]none{racks[leader]: {}}
+		brokersWithReplicas := map[int32]none{leader: {}}
+
+		k := 0
+		for i := 0; i < int(replicationFactor)-1; i++ {
+			for {
+				broker := brokers[replicaIndex(firstReplicaIndex, nextReplicaShift*numRacks, k, numBrokers)]
+				k++
+				rack := racks[broker]
+				// skip the racks and brokers that already have a replica,
+				// unless all of them do
+				if _, ok := racksWithReplicas[rack]; ok && len(racksWithReplicas) < numRacks {
+					continue
+				}
+				if _, ok := brokersWithReplicas[broker]; ok && len(brokersWithReplicas) < numBrokers {
+					continue
+				}
+				replicas = append(replicas, broker)
+				racksWithReplicas[rack] = none{}
+				brokersWithReplicas[broker] = none{}
+				break
+			}
+		}
+		assignment = append(assignment, replicas)
+	}
+	return assignment
+}
+
+// rackAlternatedBrokers orders the brokers so that consecutive brokers are on
+// different racks, picking the brokers of each rack in turn
+func rackAlternatedBrokers(racks map[int32]string) []int32 {
+	brokersByRack := make(map[string][]int32)
+	for broker, rack := range racks {
+		brokersByRack[rack] = append(brokersByRack[rack], broker)
+	}
+	rackNames := make([]string, 0, len(brokersByRack))
+	for rack, brokers := range brokersByRack {
+		sort.Slice(brokers, func(i, j int) bool { return brokers[i] < brokers[j] })
+		rackNames = append(rackNames, rack)
+	}
+	sort.Strings(rackNames)
+
+	alternated := make([]int32, 0, len(racks))
+	for i := 0; len(alternated) < len(racks); i++ {
+		for _, rack := range rackNames {
+			if i < len(brokersByRack[rack]) {
+				alternated = append(alternated, brokersByRack[rack][i])
+			}
+		}
+	}
+	return alternated
+}
@@ -0,0 +1,92 @@
+package sarama
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAssignReplicasRackUnaware(t *testing.T) {
+	brokers := []ReplicaAssignmentBroker{{ID: 0}, {ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+
+	assignment, err := AssignReplicas(brokers, 10, 3, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]int32{
+		{0, 1, 2}, {1, 2, 3}, {2, 3, 4}, {3, 4, 0}, {4, 0, 1},
+		{0, 2, 3}, {1, 3, 4}, {2, 4, 0}, {3, 0, 1}, {4, 1, 2},
+	}
+	if !reflect.DeepEqual(assignment, expected) {
+		t.Errorf("expected %v, got %v", expected, assignment)
+	}
+
+	// adding partitions continues the assignment of the existing ones
+	added, err := AssignReplicas(brokers, 5, 3, 5, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(added, expected[5:]) {
+		t.Errorf("expected %v, got %v", expected[5:], added)
+	}
+}
+
+func TestAssignReplicasRackAware(t *testing.T) {
+	brokers := []ReplicaAssignmentBroker{
+		{ID: 0, Rack: "rack1"},
+		{ID: 1, Rack: "rack3"},
+		{ID: 2, Rack: "rack3"},
+		{ID: 3, Rack: "rack2"},
+		{ID: 4, Rack: "rack2"},
+		{ID: 5, Rack: "rack1"},
+	}
+	racks := make(map[int32]string)
+	for _, broker := range brokers {
+		racks[broker.ID] = broker.Rack
+	}
+
+	if alternated := rackAlternatedBrokers(racks); !reflect.DeepEqual(alternated, []int32{0, 3, 1, 5, 4, 2}) {
+		t.Errorf("unexpected rack alternated brokers %v", alternated)
+	}
+
+	assignment, err := AssignReplicas(brokers, 6, 3, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaders := make(map[int32]int)
+	replicaCounts := make(map[int32]int)
+	for partition, replicas := range assignment {
+		if len(replicas) != 3 {
+			t.Fatalf("partition %d: expected 3 replicas, got %v", partition, replicas)
+		}
+		leaders[replicas[0]]++
+		seenRacks := make(map[string]none)
+		for _, replica := range replicas {
+			replicaCounts[replica]++
+			seenRacks[racks[replica]] = none{}
+		}
+		if len(seenRacks) != 3 {
+			t.Errorf("partition %d: replicas %v are not spread over all the racks", partition, replicas)
+		}
+	}
+	for _, broker := range brokers {
+		if leaders[broker.ID] != 1 || replicaCounts[broker.ID] != 3 {
+			t.Errorf("broker %d: unbalanced assignment %v", broker.ID, assignment)
+		}
+	}
+}
+
+func TestAssignReplicasErrors(t *testing.T) {
+	brokers := []ReplicaAssignmentBroker{{ID: 0, Rack: "rack1"}, {ID: 1}}
+
+	if _, err := AssignReplicas(brokers, 1, 2, 0, 0); !errors.Is(err, ErrInvalidReplicaAssignment) {
+		t.Errorf("expected ErrInvalidReplicaAssignment, got %v", err)
+	}
+	if _, err := AssignReplicas(brokers, 1, 3, 0, 0); !errors.Is(err, ErrInvalidReplicationFactor) {
+		t.Errorf("expected ErrInvalidReplicationFactor, got %v", err)
+	}
+	if _, err := AssignReplicas(brokers, 0, 1, 0, 0); !errors.Is(err, ErrInvalidPartitions) {
+		t.Errorf("expected ErrInvalidPartitions, got %v", err)
+	}
+}
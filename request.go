@@ -125,29 +125,29 @@ func allocateBody(key, version int16) protocolBody {
 	case 2:
 		return &OffsetRequest{Version: version}
 	case 3:
-		return &MetadataRequest{}
+		return &MetadataRequest{Version: version}
 	case 8:
 		return &OffsetCommitRequest{Version: version}
 	case 9:
 		return &OffsetFetchRequest{Version: version}
 	case 10:
-		return &FindCoordinatorRequest{}
+		return &FindCoordinatorRequest{Version: version}
 	case 11:
 		return &JoinGroupRequest{}
 	case 12:
 		return &HeartbeatRequest{}
 	case 13:
-		return &LeaveGroupRequest{}
+		return &LeaveGroupRequest{Version: version}
 	case 14:
 		return &SyncGroupRequest{}
 	case 15:
 		return &DescribeGroupsRequest{}
 	case 16:
-		return &ListGroupsRequest{}
+		return &ListGroupsRequest{Version: version}
 	case 17:
 		return &SaslHandshakeRequest{}
 	case 18:
-		return &ApiVersionsRequest{}
+		return &ApiVersionsRequest{Version: version}
 	case 19:
 		return &CreateTopicsRequest{}
 	case 20:
@@ -162,6 +162,8 @@ func allocateBody(key, version int16) protocolBody {
 		return &AddOffsetsToTxnRequest{}
 	case 26:
 		return &EndTxnRequest{}
+	case 27:
+		return &WriteTxnMarkersRequest{}
 	case 28:
 		return &TxnOffsetCommitRequest{}
 	case 29:
@@ -171,7 +173,7 @@ func allocateBody(key, version int16) protocolBody {
 	case 31:
 		return &DeleteAclsRequest{}
 	case 32:
-		return &DescribeConfigsRequest{}
+		return &DescribeConfigsRequest{Version: version}
 	case 33:
 		return &AlterConfigsRequest{}
 	case 35:
@@ -180,8 +182,18 @@ func allocateBody(key, version int16) protocolBody {
 		return &SaslAuthenticateRequest{}
 	case 37:
 		return &CreatePartitionsRequest{}
+	case 38:
+		return &CreateDelegationTokenRequest{}
+	case 39:
+		return &RenewDelegationTokenRequest{}
+	case 40:
+		return &ExpireDelegationTokenRequest{}
+	case 41:
+		return &DescribeDelegationTokenRequest{}
 	case 42:
 		return &DeleteGroupsRequest{}
+	case 43:
+		return &ElectLeadersRequest{Version: version}
 	case 44:
 		return &IncrementalAlterConfigsRequest{}
 	case 45:
@@ -198,6 +210,24 @@ func allocateBody(key, version int16) protocolBody {
 		return &DescribeUserScramCredentialsRequest{}
 	case 51:
 		return &AlterUserScramCredentialsRequest{}
+	case 55:
+		return &DescribeQuorumRequest{}
+	case 57:
+		return &UpdateFeaturesRequest{}
+	case 61:
+		return &DescribeProducersRequest{}
+	case 64:
+		return &UnregisterBrokerRequest{}
+	case 65:
+		return &DescribeTransactionsRequest{}
+	case 66:
+		return &ListTransactionsRequest{}
+	case 68:
+		return &ConsumerGroupHeartbeatRequest{Version: version}
+	case 71:
+		return &GetTelemetrySubscriptionsRequest{Version: version}
+	case 72:
+		return &PushTelemetryRequest{Version: version}
 	}
 	return nil
 }
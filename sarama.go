@@ -19,6 +19,9 @@ exactly with the protocol fields documented by Kafka at
 https://cwiki.apache.org/confluence/display/KAFKA/A+Guide+To+The+Kafka+Protocol
 
 Metrics are exposed through https://github.com/rcrowley/go-metrics library in a local registry.
+They can be emitted to another metrics system, such as Prometheus or OpenTelemetry, by setting
+Config.MetricsRecorder: the "-for-broker-<broker-id>", "-for-topic-<topic>" and "-<group-id>"
+suffixes below are then the broker, topic and group labels of the metrics.
 
 Broker related metrics:
 
@@ -43,10 +46,18 @@ Broker related metrics:
 	|                                              |            | for all brokers                                               |
 	| requests-in-flight-for-broker-<broker-id>    | counter    | The current number of in-flight requests awaiting a response  |
 	|                                              |            | for a given broker                                            |
+	| throttle-time-in-ms                          | histogram  | Distribution of the throttle time in ms of the responses for  |
+	|                                              |            | all brokers                                                   |
+	| throttle-time-in-ms-for-broker-<broker-id>   | histogram  | Distribution of the throttle time in ms of the responses for  |
+	|                                              |            | a given broker                                                |
 	+----------------------------------------------+------------+---------------------------------------------------------------+
 
 Note that we do not gather specific metrics for seed brokers but they are part of the "all brokers" metrics.
 
+The request latency is also broken down by API, such as Produce, Fetch or OffsetCommit, in the
+request-latency-in-ms-for-api-<api> and request-latency-in-ms-for-broker-<broker-id>-for-api-<api>
+histograms.
+
 Producer related metrics:
 
 	+-------------------------------------------+------------+--------------------------------------------------------------------------------------+
@@ -60,19 +71,38 @@ Producer related metrics:
 	| records-per-request-for-topic-<topic>     | histogram  | Distribution of the number of records sent per request for a given topic             |
 	| compression-ratio                         | histogram  | Distribution of the compression ratio times 100 of record batches for all topics     |
 	| compression-ratio-for-topic-<topic>       | histogram  | Distribution of the compression ratio times 100 of record batches for a given topic  |
+	| compression-time-in-us                    | histogram  | Distribution of the time in us spent compressing record batches for all codecs       |
+	| compression-time-in-us-for-codec-<codec>  | histogram  | Distribution of the time in us spent compressing record batches for a given codec    |
 	+-------------------------------------------+------------+--------------------------------------------------------------------------------------+
 
 Consumer related metrics:
 
-	+-------------------------------------------+------------+--------------------------------------------------------------------------------------+
-	| Name                                      | Type       | Description                                                                          |
-	+-------------------------------------------+------------+--------------------------------------------------------------------------------------+
-	| consumer-batch-size                       | histogram  | Distribution of the number of messages in a batch                                    |
-	| consumer-group-join-total-<GroupID>       | counter    | Total count of consumer group join attempts                                          |
-	| consumer-group-join-failed-<GroupID>      | counter    | Total count of consumer group join failures                                          |
-	| consumer-group-sync-total-<GroupID>       | counter    | Total count of consumer group sync attempts                                          |
-	| consumer-group-sync-failed-<GroupID>      | counter    | Total count of consumer group sync failures                                          |
-	+-------------------------------------------+------------+--------------------------------------------------------------------------------------+
+	+--------------------------------------------------+------------+-----------------------------------------------------------------------------+
+	| Name                                             | Type       | Description                                                                 |
+	+--------------------------------------------------+------------+-----------------------------------------------------------------------------+
+	| consumer-batch-size                              | histogram  | Distribution of the number of messages in a batch                           |
+	| consumer-fetch-latency-in-ms                     | histogram  | Distribution of the fetch request latency in ms                             |
+	| consumer-fetch-size                              | histogram  | Distribution of the number of bytes fetched per request for all topics      |
+	| consumer-fetch-size-for-topic-<topic>            | histogram  | Distribution of the number of bytes fetched per request for a given topic   |
+	| consumer-records-per-request                     | histogram  | Distribution of the number of records fetched per request for all topics    |
+	| consumer-records-per-request-for-topic-<topic>   | histogram  | Distribution of the number of records fetched per request for a given topic |
+	| consumer-bytes-consumed-rate                     | meter      | Bytes/second consumed from all topics                                       |
+	| consumer-bytes-consumed-rate-for-topic-<topic>   | meter      | Bytes/second consumed from a given topic                                    |
+	| consumer-records-consumed-rate                   | meter      | Records/second consumed from all topics                                     |
+	| consumer-records-consumed-rate-for-topic-<topic> | meter      | Records/second consumed from a given topic                                  |
+	| consumer-records-lag                             | histogram  | Distribution of the lag in records of the partitions after each fetch       |
+	| consumer-group-join-total-<GroupID>              | counter    | Total count of consumer group join attempts                                 |
+	| consumer-group-join-failed-<GroupID>             | counter    | Total count of consumer group join failures                                 |
+	| consumer-group-sync-total-<GroupID>              | counter    | Total count of consumer group sync attempts                                 |
+	| consumer-group-sync-failed-<GroupID>             | counter    | Total count of consumer group sync failures                                 |
+	| consumer-group-commit-total-<GroupID>            | counter    | Total count of offset commit requests, retries included                     |
+	| consumer-group-commit-retries-<GroupID>          | counter    | Total count of retried offset commit requests                               |
+	| consumer-group-commit-failed-<GroupID>           | counter    | Total count of offset commit requests failing for some partitions           |
+	+--------------------------------------------------+------------+-----------------------------------------------------------------------------+
+
+The maximum of the consumer-records-lag histograms is the records-lag-max of the Java client, also
+broken down by partition in the consumer-records-lag-for-topic-<topic>-for-partition-<partition>
+histograms, which are removed when the partition consumer is closed.
 
 */
 package sarama
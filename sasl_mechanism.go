@@ -0,0 +1,56 @@
+package sarama
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SASLMechanismClient is the client side of a SASL mechanism registered with
+// RegisterSASLMechanism. A new one is created for each authentication.
+type SASLMechanismClient interface {
+	// Step returns the message to send to the broker in response to its
+	// challenge, which is empty for the initial message. It is called with
+	// each response of the broker until Done returns true.
+	Step(challenge []byte) ([]byte, error)
+	// Done returns true once the exchange is complete.
+	Done() bool
+}
+
+// SASLMechanismFactory creates the SASLMechanismClient authenticating with the
+// broker at addr.
+type SASLMechanismFactory func(conf *Config, addr string) (SASLMechanismClient, error)
+
+var (
+	saslMechanismsLock sync.RWMutex
+	saslMechanisms     = make(map[SASLMechanism]SASLMechanismFactory)
+)
+
+// RegisterSASLMechanism makes a custom SASL mechanism available to be
+// selected by name with Net.SASL.Mechanism. Sarama sends the SaslHandshake
+// request and wraps the messages of the mechanism in SaslAuthenticate
+// requests, which requires Kafka 1.0 or later. It panics if the mechanism is
+// built into sarama or already registered.
+func RegisterSASLMechanism(mechanism SASLMechanism, factory SASLMechanismFactory) {
+	if factory == nil {
+		panic("sarama: RegisterSASLMechanism factory is nil")
+	}
+	switch mechanism {
+	case SASLTypeOAuth, SASLTypePlaintext, SASLTypeSCRAMSHA256, SASLTypeSCRAMSHA512, SASLTypeGSSAPI, SASLTypeAWSMSKIAM:
+		panic(fmt.Sprintf("sarama: SASL mechanism %s is built in", mechanism))
+	}
+
+	saslMechanismsLock.Lock()
+	defer saslMechanismsLock.Unlock()
+	if _, ok := saslMechanisms[mechanism]; ok {
+		panic(fmt.Sprintf("sarama: RegisterSASLMechanism called twice for %s", mechanism))
+	}
+	saslMechanisms[mechanism] = factory
+}
+
+// registeredSASLMechanism returns the factory of a registered mechanism
+func registeredSASLMechanism(mechanism SASLMechanism) (SASLMechanismFactory, bool) {
+	saslMechanismsLock.RLock()
+	defer saslMechanismsLock.RUnlock()
+	factory, ok := saslMechanisms[mechanism]
+	return factory, ok
+}
@@ -0,0 +1,98 @@
+package sarama
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// testTokenClient sends a token and expects the broker to return it
+// reversed
+type testTokenClient struct {
+	token []byte
+	sent  bool
+	done  bool
+}
+
+func (c *testTokenClient) Step(challenge []byte) ([]byte, error) {
+	if !c.sent {
+		c.sent = true
+		return c.token, nil
+	}
+	for i := range challenge {
+		if challenge[i] != c.token[len(c.token)-1-i] {
+			return nil, errors.New("unexpected challenge")
+		}
+	}
+	c.done = true
+	return nil, nil
+}
+
+func (c *testTokenClient) Done() bool {
+	return c.done
+}
+
+func TestRegisterSASLMechanism(t *testing.T) {
+	var addrs []string
+	RegisterSASLMechanism("X-TEST-TOKEN", func(conf *Config, addr string) (SASLMechanismClient, error) {
+		addrs = append(addrs, addr)
+		return &testTokenClient{token: []byte(conf.Net.SASL.Password)}, nil
+	})
+
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"SaslHandshakeRequest": NewMockSaslHandshakeResponse(t).
+			SetEnabledMechanisms([]string{"X-TEST-TOKEN"}),
+		"SaslAuthenticateRequest": NewMockSaslAuthenticateResponse(t).
+			SetAuthBytes([]byte("nekot")),
+	})
+
+	conf := NewTestConfig()
+	conf.Net.SASL.Enable = true
+	conf.Net.SASL.Mechanism = "X-TEST-TOKEN"
+	conf.Net.SASL.Password = "token"
+
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+	if connected, err := broker.Connected(); !connected || err != nil {
+		t.Fatalf("expected the broker to authenticate, got %v", err)
+	}
+
+	if len(addrs) != 1 || addrs[0] != mb.Addr() {
+		t.Errorf("expected a client for %s, got %v", mb.Addr(), addrs)
+	}
+	for _, rr := range mb.History() {
+		switch r := rr.Request.(type) {
+		case *SaslHandshakeRequest:
+			if r.Mechanism != "X-TEST-TOKEN" || r.Version != SASLHandshakeV1 {
+				t.Errorf("unexpected handshake %+v", r)
+			}
+		case *SaslAuthenticateRequest:
+			if !bytes.Equal(r.SaslAuthBytes, []byte("token")) {
+				t.Errorf("unexpected authentication message %q", r.SaslAuthBytes)
+			}
+		}
+	}
+}
+
+func TestRegisterSASLMechanismTwice(t *testing.T) {
+	factory := func(conf *Config, addr string) (SASLMechanismClient, error) {
+		return &testTokenClient{}, nil
+	}
+	RegisterSASLMechanism("X-TEST-TWICE", factory)
+
+	for _, mechanism := range []SASLMechanism{"X-TEST-TWICE", SASLTypeSCRAMSHA256} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected registering %s to panic", mechanism)
+				}
+			}()
+			RegisterSASLMechanism(mechanism, factory)
+		}()
+	}
+}
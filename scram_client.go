@@ -0,0 +1,198 @@
+package sarama
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NewSCRAMClient returns the built-in SCRAMClient of the given mechanism,
+// which is used when Net.SASL.SCRAMClientGeneratorFunc is not set. It salts
+// the password with PBKDF2, checks the server nonce extends the client one and
+// verifies the signature of the server.
+func NewSCRAMClient(mechanism ScramMechanismType) SCRAMClient {
+	return &scramClient{formatter: scramFormatter{mechanism: mechanism}}
+}
+
+// NewDelegationTokenSCRAMClient returns a SCRAMClient authenticating with a
+// delegation token, to be returned by Net.SASL.SCRAMClientGeneratorFunc along
+// with the matching SASL mechanism. The token ID is the Net.SASL.User and
+// the base64 encoded HMAC of the token the Net.SASL.Password:
+//
+//	config.Net.SASL.Mechanism = SASLTypeSCRAMSHA512
+//	config.Net.SASL.User = token.TokenID
+//	config.Net.SASL.Password = base64.StdEncoding.EncodeToString(token.HMAC)
+//	config.Net.SASL.SCRAMClientGeneratorFunc = func() SCRAMClient {
+//		return NewDelegationTokenSCRAMClient(SCRAM_MECHANISM_SHA_512)
+//	}
+func NewDelegationTokenSCRAMClient(mechanism ScramMechanismType) SCRAMClient {
+	return &scramClient{
+		formatter:  scramFormatter{mechanism: mechanism},
+		extensions: "tokenauth=true",
+	}
+}
+
+// scramMaxIterations is the highest iteration count accepted from the server,
+// which is the maximum Kafka allows for SCRAM credentials. It prevents a
+// hostile server from making the client salt the password for too long.
+const scramMaxIterations = 16384
+
+// scramClient implements the client side of the SCRAM exchange of RFC 5802,
+// with support for the extensions of the client first message
+type scramClient struct {
+	formatter  scramFormatter
+	extensions string
+
+	user     string
+	password string
+	authzID  string
+
+	// fixedNonce replaces the random nonce of each exchange in tests
+	fixedNonce string
+
+	// state of the exchange, reset by Begin
+	nonce           string
+	step            int
+	clientFirstBare string
+	serverSignature []byte
+	done            bool
+}
+
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	c.user = userName
+	c.password = password
+	c.authzID = authzID
+	c.nonce = c.fixedNonce
+	c.step = 0
+	c.clientFirstBare = ""
+	c.serverSignature = nil
+	c.done = false
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	defer func() { c.step++ }()
+	switch c.step {
+	case 0:
+		return c.clientFirstMessage()
+	case 1:
+		return c.clientFinalMessage(challenge)
+	case 2:
+		return "", c.verifyServerFinalMessage(challenge)
+	default:
+		return "", errors.New("kafka: SCRAM exchange already completed")
+	}
+}
+
+func (c *scramClient) Done() bool {
+	return c.done
+}
+
+func (c *scramClient) gs2Header() string {
+	if c.authzID == "" {
+		return "n,,"
+	}
+	return "n,a=" + scramEscape(c.authzID) + ","
+}
+
+func (c *scramClient) clientFirstMessage() (string, error) {
+	if c.nonce == "" {
+		nonce := make([]byte, 24)
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		c.nonce = base64.RawStdEncoding.EncodeToString(nonce)
+	}
+
+	c.clientFirstBare = "n=" + scramEscape(c.user) + ",r=" + c.nonce
+	if c.extensions != "" {
+		c.clientFirstBare += "," + c.extensions
+	}
+	return c.gs2Header() + c.clientFirstBare, nil
+}
+
+func (c *scramClient) clientFinalMessage(serverFirst string) (string, error) {
+	attributes := scramAttributes(serverFirst)
+	if e, ok := attributes['e']; ok {
+		return "", fmt.Errorf("kafka: SCRAM exchange failed: %s", e)
+	}
+
+	nonce := attributes['r']
+	if !strings.HasPrefix(nonce, c.nonce) {
+		return "", errors.New("kafka: SCRAM server nonce does not start with the client nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(attributes['s'])
+	if err != nil {
+		return "", fmt.Errorf("kafka: invalid SCRAM salt: %w", err)
+	}
+	iterations, err := strconv.Atoi(attributes['i'])
+	if err != nil || iterations <= 0 || iterations > scramMaxIterations {
+		return "", fmt.Errorf("kafka: invalid SCRAM iteration count %q", attributes['i'])
+	}
+
+	saltedPassword, err := c.formatter.saltedPassword([]byte(c.password), salt, iterations)
+	if err != nil {
+		return "", err
+	}
+	clientKey, err := c.formatter.hmac(saltedPassword, []byte("Client Key"))
+	if err != nil {
+		return "", err
+	}
+	storedKey, err := c.formatter.hash(clientKey)
+	if err != nil {
+		return "", err
+	}
+	serverKey, err := c.formatter.hmac(saltedPassword, []byte("Server Key"))
+	if err != nil {
+		return "", err
+	}
+
+	clientFinalWithoutProof := "c=" + base64.StdEncoding.EncodeToString([]byte(c.gs2Header())) + ",r=" + nonce
+	authMessage := []byte(c.clientFirstBare + "," + serverFirst + "," + clientFinalWithoutProof)
+
+	clientSignature, err := c.formatter.hmac(storedKey, authMessage)
+	if err != nil {
+		return "", err
+	}
+	if c.serverSignature, err = c.formatter.hmac(serverKey, authMessage); err != nil {
+		return "", err
+	}
+
+	proof := clientKey
+	c.formatter.xor(proof, clientSignature)
+	return clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+func (c *scramClient) verifyServerFinalMessage(serverFinal string) error {
+	attributes := scramAttributes(serverFinal)
+	if e, ok := attributes['e']; ok {
+		return fmt.Errorf("kafka: SCRAM exchange failed: %s", e)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(attributes['v'])
+	if err != nil || !hmac.Equal(signature, c.serverSignature) {
+		return errors.New("kafka: invalid SCRAM server signature")
+	}
+	c.done = true
+	return nil
+}
+
+// scramAttributes parses the attributes of a SCRAM message
+func scramAttributes(msg string) map[byte]string {
+	attributes := make(map[byte]string)
+	for _, attribute := range strings.Split(msg, ",") {
+		if len(attribute) >= 2 && attribute[1] == '=' {
+			attributes[attribute[0]] = attribute[2:]
+		}
+	}
+	return attributes
+}
+
+// scramEscape escapes the ',' and '=' of a SCRAM user name
+func scramEscape(name string) string {
+	return strings.NewReplacer("=", "=3D", ",", "=2C").Replace(name)
+}
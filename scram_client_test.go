@@ -0,0 +1,132 @@
+package sarama
+
+import (
+	"strings"
+	"testing"
+)
+
+// Test vector of RFC 7677 section 3
+func TestSCRAMClientSHA256(t *testing.T) {
+	client := &scramClient{
+		formatter:  scramFormatter{mechanism: SCRAM_MECHANISM_SHA_256},
+		fixedNonce: "rOprNGfwEbeRWgbNEkqO",
+	}
+	if err := client.Begin("user", "pencil", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		challenge, response string
+	}{
+		{"", "n,,n=user,r=rOprNGfwEbeRWgbNEkqO"},
+		{
+			"r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+			"c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+		},
+		{"v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=", ""},
+	}
+	for i, step := range steps {
+		if client.Done() {
+			t.Fatalf("step %d: unexpected end of the exchange", i)
+		}
+		response, err := client.Step(step.challenge)
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if response != step.response {
+			t.Errorf("step %d: expected %q, got %q", i, step.response, response)
+		}
+	}
+	if !client.Done() {
+		t.Error("expected the exchange to be done")
+	}
+}
+
+func TestSCRAMClientInvalidServerSignature(t *testing.T) {
+	client := &scramClient{
+		formatter:  scramFormatter{mechanism: SCRAM_MECHANISM_SHA_256},
+		fixedNonce: "rOprNGfwEbeRWgbNEkqO",
+	}
+	_ = client.Begin("user", "pencil", "")
+	_, _ = client.Step("")
+	_, _ = client.Step("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	if _, err := client.Step("v=AAAA"); err == nil {
+		t.Error("expected an invalid server signature error")
+	}
+	if client.Done() {
+		t.Error("expected the exchange not to be done")
+	}
+}
+
+func TestSCRAMClientBeginResets(t *testing.T) {
+	client := NewSCRAMClient(SCRAM_MECHANISM_SHA_256)
+	_ = client.Begin("user", "pencil", "")
+	first, _ := client.Step("")
+	_, _ = client.Step("r=" + strings.TrimPrefix(first, "n,,n=user,r=") + "server,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+
+	// the client is reused when re-authenticating
+	if err := client.Begin("user", "pencil", ""); err != nil {
+		t.Fatal(err)
+	}
+	if client.Done() {
+		t.Error("expected a new exchange not to be done")
+	}
+	second, err := client.Step("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first || !strings.HasPrefix(second, "n,,n=user,r=") {
+		t.Errorf("expected a client first message with a new nonce, got %q after %q", second, first)
+	}
+}
+
+func TestSCRAMClientIterationCount(t *testing.T) {
+	for _, iterations := range []string{"0", "-1", "16385", "1000000000", "many"} {
+		client := &scramClient{
+			formatter:  scramFormatter{mechanism: SCRAM_MECHANISM_SHA_256},
+			fixedNonce: "rOprNGfwEbeRWgbNEkqO",
+		}
+		_ = client.Begin("user", "pencil", "")
+		_, _ = client.Step("")
+		if _, err := client.Step("r=rOprNGfwEbeRWgbNEkqOserver,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=" + iterations); err == nil {
+			t.Errorf("expected the iteration count %s to be rejected", iterations)
+		}
+	}
+}
+
+func TestDelegationTokenSCRAMClient(t *testing.T) {
+	client := NewDelegationTokenSCRAMClient(SCRAM_MECHANISM_SHA_512)
+	if err := client.Begin("token-id", "aG1hYw==", ""); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := client.Step("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(msg, "n,,n=token-id,r=") || !strings.HasSuffix(msg, ",tokenauth=true") {
+		t.Errorf("unexpected client first message %q", msg)
+	}
+}
+
+func TestBrokerDefaultSCRAMClient(t *testing.T) {
+	for mechanism, expected := range map[SASLMechanism]ScramMechanismType{
+		SASLTypeSCRAMSHA256: SCRAM_MECHANISM_SHA_256,
+		SASLTypeSCRAMSHA512: SCRAM_MECHANISM_SHA_512,
+	} {
+		conf := NewTestConfig()
+		conf.Net.SASL.Enable = true
+		conf.Net.SASL.Mechanism = mechanism
+		conf.Net.SASL.User = "user"
+		conf.Net.SASL.Password = "pencil"
+		if err := conf.Validate(); err != nil {
+			t.Errorf("%s: expected the configuration without a SCRAM client to be valid, got %v", mechanism, err)
+		}
+
+		broker := NewBroker("localhost:9092")
+		broker.conf = conf
+		client, ok := broker.newSCRAMClient().(*scramClient)
+		if !ok || client.formatter.mechanism != expected {
+			t.Errorf("%s: expected the built-in SCRAM client, got %#v", mechanism, broker.newSCRAMClient())
+		}
+	}
+}
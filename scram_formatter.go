@@ -2,6 +2,7 @@ package sarama
 
 import (
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"crypto/sha512"
 	"hash"
@@ -29,6 +30,19 @@ func (s scramFormatter) mac(key []byte) (hash.Hash, error) {
 	return m, nil
 }
 
+func (s scramFormatter) hash(data []byte) ([]byte, error) {
+	switch s.mechanism {
+	case SCRAM_MECHANISM_SHA_256:
+		sum := sha256.Sum256(data)
+		return sum[:], nil
+	case SCRAM_MECHANISM_SHA_512:
+		sum := sha512.Sum512(data)
+		return sum[:], nil
+	default:
+		return nil, ErrUnknownScramMechanism
+	}
+}
+
 func (s scramFormatter) hmac(key []byte, extra []byte) ([]byte, error) {
 	mac, err := s.mac(key)
 	if err != nil {
@@ -76,3 +90,19 @@ func (s scramFormatter) saltedPassword(password []byte, salt []byte, iterations
 
 	return result, nil
 }
+
+// scramSaltLength is the length of the salts generated for the credentials
+// upserted without an explicit salt
+const scramSaltLength = 32
+
+// scramMinIterations is the minimum number of iterations accepted by the
+// brokers, used for the credentials upserted without explicit iterations
+const scramMinIterations = 4096
+
+func (s scramFormatter) salt() ([]byte, error) {
+	salt := make([]byte, scramSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
@@ -0,0 +1,91 @@
+package sarama
+
+import "encoding/binary"
+
+// Serializer serializes the keys or the values of the messages produced, such
+// as in the format of a schema registry, see Producer.KeySerializer and
+// Producer.ValueSerializer.
+type Serializer interface {
+	// Serialize returns the bytes of data, the non nil Key or Value of a
+	// message produced to topic, such as a type of the application with the
+	// record of a schema. It may add headers, such as the name of the schema.
+	Serialize(topic string, headers *[]RecordHeader, data Encoder) ([]byte, error)
+}
+
+// Deserializer deserializes the keys or the values of the messages consumed,
+// such as from the format of a schema registry, see Consumer.KeyDeserializer
+// and Consumer.ValueDeserializer.
+type Deserializer interface {
+	// Deserialize returns the value of data, the non nil Key or Value of a
+	// message consumed from topic. It must copy what it keeps of data and the
+	// headers with Consumer.Fetch.PooledBuffers.
+	Deserialize(topic string, headers []*RecordHeader, data []byte) (interface{}, error)
+}
+
+// The schema registry wire format frames the payloads with a magic byte and
+// the 4 bytes big endian ID of their schema.
+const (
+	schemaRegistryMagicByte  = 0
+	schemaRegistryHeaderSize = 5
+)
+
+// EncodeSchemaRegistryPayload returns payload framed in the wire format of
+// the Confluent schema registry, with the ID of its schema, for the
+// Serializers of Avro, Protobuf or JSON Schema records.
+func EncodeSchemaRegistryPayload(schemaID int32, payload []byte) []byte {
+	framed := make([]byte, schemaRegistryHeaderSize+len(payload))
+	framed[0] = schemaRegistryMagicByte
+	binary.BigEndian.PutUint32(framed[1:], uint32(schemaID))
+	copy(framed[schemaRegistryHeaderSize:], payload)
+	return framed
+}
+
+// DecodeSchemaRegistryPayload returns the ID of the schema and the payload of
+// data in the wire format of the Confluent schema registry, or
+// ErrInvalidSchemaRegistryPayload. The payload references data.
+func DecodeSchemaRegistryPayload(data []byte) (int32, []byte, error) {
+	if len(data) < schemaRegistryHeaderSize || data[0] != schemaRegistryMagicByte {
+		return 0, nil, ErrInvalidSchemaRegistryPayload
+	}
+	return int32(binary.BigEndian.Uint32(data[1:])), data[schemaRegistryHeaderSize:], nil
+}
+
+// serialize replaces the Key and the Value of msg with their serialization
+// by the Producer.KeySerializer and ValueSerializer, if any
+func (msg *ProducerMessage) serialize(conf *Config) error {
+	if serializer := conf.Producer.KeySerializer; serializer != nil && msg.Key != nil {
+		key, err := serializer.Serialize(msg.Topic, &msg.Headers, msg.Key)
+		if err != nil {
+			return err
+		}
+		msg.Key = ByteEncoder(key)
+	}
+	if serializer := conf.Producer.ValueSerializer; serializer != nil && msg.Value != nil {
+		value, err := serializer.Serialize(msg.Topic, &msg.Headers, msg.Value)
+		if err != nil {
+			return err
+		}
+		msg.Value = ByteEncoder(value)
+	}
+	return nil
+}
+
+// deserialize sets the DeserializedKey and DeserializedValue of msg with the
+// Consumer.KeyDeserializer and ValueDeserializer, if any
+func (msg *ConsumerMessage) deserialize(conf *Config) error {
+	if deserializer := conf.Consumer.KeyDeserializer; deserializer != nil && msg.Key != nil {
+		key, err := deserializer.Deserialize(msg.Topic, msg.Headers, msg.Key)
+		if err != nil {
+			return err
+		}
+		msg.DeserializedKey = key
+	}
+	if deserializer := conf.Consumer.ValueDeserializer; deserializer != nil && msg.Value != nil {
+		value, err := deserializer.Deserialize(msg.Topic, msg.Headers, msg.Value)
+		if err != nil {
+			return err
+		}
+		msg.DeserializedValue = value
+	}
+	return nil
+}
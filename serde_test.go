@@ -0,0 +1,138 @@
+package sarama
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// schemaSerde serializes the strings of a test schema in the schema registry
+// wire format
+type schemaSerde struct {
+	schemaID int32
+}
+
+func (s schemaSerde) Serialize(topic string, headers *[]RecordHeader, data Encoder) ([]byte, error) {
+	payload, err := data.Encode()
+	if err != nil {
+		return nil, err
+	}
+	*headers = append(*headers, RecordHeader{Key: []byte("schema"), Value: []byte(topic + "-value")})
+	return EncodeSchemaRegistryPayload(s.schemaID, payload), nil
+}
+
+func (s schemaSerde) Deserialize(topic string, headers []*RecordHeader, data []byte) (interface{}, error) {
+	schemaID, payload, err := DecodeSchemaRegistryPayload(data)
+	if err != nil {
+		return nil, err
+	}
+	if schemaID != s.schemaID {
+		return nil, errors.New("unknown schema")
+	}
+	return string(payload), nil
+}
+
+func TestSchemaRegistryPayload(t *testing.T) {
+	framed := EncodeSchemaRegistryPayload(258, []byte("payload"))
+	if string(framed[:5]) != "\x00\x00\x00\x01\x02" {
+		t.Errorf("Expected the magic byte and the schema ID, got %v", framed[:5])
+	}
+	schemaID, payload, err := DecodeSchemaRegistryPayload(framed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schemaID != 258 || string(payload) != "payload" {
+		t.Errorf("Expected the schema ID and the payload, got %d and %q", schemaID, payload)
+	}
+
+	for _, data := range [][]byte{nil, {0, 0, 0, 1}, {1, 0, 0, 0, 1, 'p'}} {
+		if _, _, err := DecodeSchemaRegistryPayload(data); !errors.Is(err, ErrInvalidSchemaRegistryPayload) {
+			t.Errorf("Expected %v to be invalid, got %v", data, err)
+		}
+	}
+}
+
+func TestSerializersAndDeserializers(t *testing.T) {
+	cluster := NewMockCluster(t, 1)
+	defer cluster.Close()
+	if err := cluster.CreateTopic("my_topic", 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	config := NewTestConfig()
+	config.Version = V0_11_0_0
+	config.Producer.Return.Successes = true
+	config.Producer.ValueSerializer = schemaSerde{schemaID: 1}
+	config.Consumer.Return.Errors = true
+	config.Consumer.ValueDeserializer = schemaSerde{schemaID: 1}
+
+	producer, err := NewSyncProducer(cluster.Addrs(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{"a", "b"} {
+		msg := &ProducerMessage{Topic: "my_topic", Key: StringEncoder("key"), Value: StringEncoder(value)}
+		if _, _, err := producer.SendMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+		if value, _ := msg.Value.Encode(); len(value) != 6 {
+			t.Errorf("Expected the value to be serialized, got %v", value)
+		}
+		if len(msg.Headers) != 1 {
+			t.Errorf("Expected the serializer to add a header, got %v", msg.Headers)
+		}
+	}
+	safeClose(t, producer)
+
+	consume := func(deserializer Deserializer) ([]*ConsumerMessage, []error) {
+		config.Consumer.ValueDeserializer = deserializer
+		consumer, err := NewConsumer(cluster.Addrs(), config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer safeClose(t, consumer)
+		pc, err := consumer.ConsumePartition("my_topic", 0, OffsetOldest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer safeClose(t, pc)
+
+		// the errors are returned before the messages failing to deserialize
+		var msgs []*ConsumerMessage
+		var errs []error
+		for len(msgs) < 2 {
+			select {
+			case msg := <-pc.Messages():
+				msgs = append(msgs, msg)
+			case err := <-pc.Errors():
+				errs = append(errs, err.Err)
+			}
+		}
+		for len(pc.Errors()) > 0 {
+			errs = append(errs, (<-pc.Errors()).Err)
+		}
+		return msgs, errs
+	}
+
+	msgs, errs := consume(schemaSerde{schemaID: 1})
+	if len(errs) != 0 {
+		t.Errorf("Expected no error, got %v", errs)
+	}
+	for i, msg := range msgs {
+		if msg.DeserializedValue != []string{"a", "b"}[i] || msg.DeserializedKey != nil {
+			t.Errorf("Expected the value to be deserialized, got %+v", msg)
+		}
+		if value, ok := msg.Header("schema"); !ok || string(value) != "my_topic-value" {
+			t.Errorf("Expected the header of the serializer, got %q", value)
+		}
+	}
+	msgs, errs = consume(schemaSerde{schemaID: 2})
+	for i, msg := range msgs {
+		if msg.DeserializedValue != nil || len(msg.Value) != 6 {
+			t.Errorf("Expected the message failing to deserialize to be returned as is, got %+v", msg)
+		}
+		if expected := fmt.Sprintf("deserializing the message at offset %d: unknown schema", i); len(errs) != 2 || errs[i].Error() != expected {
+			t.Errorf("Expected the error of the message, got %v", errs)
+		}
+	}
+}
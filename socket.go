@@ -0,0 +1,38 @@
+package sarama
+
+import "net"
+
+// tuneSocket applies Net.Socket to a connection opened by the dialer of
+// getDialer, which is left as is unless it is a TCP connection
+func (c *Config) tuneSocket(conn net.Conn) error {
+	tcp, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+
+	if c.Net.Socket.SendBuffer > 0 {
+		if err := tcp.SetWriteBuffer(c.Net.Socket.SendBuffer); err != nil {
+			return err
+		}
+	}
+	if c.Net.Socket.ReceiveBuffer > 0 {
+		if err := tcp.SetReadBuffer(c.Net.Socket.ReceiveBuffer); err != nil {
+			return err
+		}
+	}
+	if c.Net.Socket.KeepAliveInterval <= 0 && c.Net.Socket.KeepAliveCount <= 0 && c.Net.Socket.UserTimeout <= 0 {
+		return nil
+	}
+
+	raw, err := tcp.SyscallConn()
+	if err != nil {
+		return err
+	}
+	controlErr := raw.Control(func(fd uintptr) {
+		err = setTCPOptions(fd, c)
+	})
+	if controlErr != nil {
+		return controlErr
+	}
+	return err
+}
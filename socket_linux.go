@@ -0,0 +1,37 @@
+//go:build linux
+// +build linux
+
+package sarama
+
+import (
+	"syscall"
+	"time"
+)
+
+// tcpUserTimeout is TCP_USER_TIMEOUT, which the syscall package lacks
+const tcpUserTimeout = 0x12
+
+const tcpOptionsSupported = true
+
+// setTCPOptions sets the options of Net.Socket that the net package does not
+// expose on the socket of a connection. The keep-alive interval is set once
+// the connection is opened, as the net package sets it to KeepAlive then.
+func setTCPOptions(fd uintptr, c *Config) error {
+	if interval := c.Net.Socket.KeepAliveInterval; interval > 0 {
+		seconds := int((interval + time.Second - 1) / time.Second)
+		if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL, seconds); err != nil {
+			return err
+		}
+	}
+	if count := c.Net.Socket.KeepAliveCount; count > 0 {
+		if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT, count); err != nil {
+			return err
+		}
+	}
+	if timeout := c.Net.Socket.UserTimeout; timeout > 0 {
+		if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, tcpUserTimeout, int(timeout/time.Millisecond)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+//go:build linux
+// +build linux
+
+package sarama
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestConfigDialTunesSocket(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	conf := NewTestConfig()
+	conf.Net.KeepAlive = 30 * time.Second
+	conf.Net.Socket.SendBuffer = 64 << 10
+	conf.Net.Socket.ReceiveBuffer = 128 << 10
+	conf.Net.Socket.KeepAliveInterval = 1500 * time.Millisecond
+	conf.Net.Socket.KeepAliveCount = 3
+	conf.Net.Socket.UserTimeout = 20 * time.Second
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := conf.dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	raw, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := []struct {
+		name     string
+		level    int
+		option   int
+		expected int
+	}{
+		// the kernel doubles the buffer sizes it is given
+		{"SO_SNDBUF", syscall.SOL_SOCKET, syscall.SO_SNDBUF, 2 * conf.Net.Socket.SendBuffer},
+		{"SO_RCVBUF", syscall.SOL_SOCKET, syscall.SO_RCVBUF, 2 * conf.Net.Socket.ReceiveBuffer},
+		{"TCP_KEEPIDLE", syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE, 30},
+		{"TCP_KEEPINTVL", syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL, 2},
+		{"TCP_KEEPCNT", syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT, 3},
+		{"TCP_USER_TIMEOUT", syscall.IPPROTO_TCP, tcpUserTimeout, 20000},
+	}
+	err = raw.Control(func(fd uintptr) {
+		for _, o := range options {
+			value, err := syscall.GetsockoptInt(int(fd), o.level, o.option)
+			if err != nil {
+				t.Errorf("%s: %v", o.name, err)
+			} else if value != o.expected {
+				t.Errorf("%s: expected %d, got %d", o.name, o.expected, value)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package sarama
+
+const tcpOptionsSupported = false
+
+// setTCPOptions is never called as Config.Validate rejects the options it
+// sets on the platforms other than Linux
+func setTCPOptions(fd uintptr, c *Config) error {
+	return nil
+}
@@ -0,0 +1,52 @@
+package sarama
+
+// The flexible versions of the requests and responses (KIP-482) end their
+// structures with tagged fields: a count followed by the fields, made of their
+// tag, size and value. Fields which are not set are omitted, and decoders skip
+// the tags they do not know, so that brokers can add fields without bumping
+// the version.
+
+// putTaggedField writes a tagged field, made of its tag, size and value
+func putTaggedField(pe packetEncoder, tag uint64, value encoder) error {
+	buf, err := encode(value, nil)
+	if err != nil {
+		return err
+	}
+	pe.putUVarint(tag)
+	pe.putUVarint(uint64(len(buf)))
+	return pe.putRawBytes(buf)
+}
+
+// getTaggedFields reads the tagged fields and calls f with the decoder of the
+// value of every field, so that it can decode the ones it knows. The other
+// fields are skipped.
+func getTaggedFields(pd packetDecoder, f func(tag uint64, field packetDecoder) error) error {
+	numTags, err := pd.getUVarint()
+	if err != nil {
+		return err
+	}
+	if numTags > uint64(pd.remaining()) {
+		return ErrInsufficientData
+	}
+	for i := uint64(0); i < numTags; i++ {
+		tag, err := pd.getUVarint()
+		if err != nil {
+			return err
+		}
+		size, err := pd.getUVarint()
+		if err != nil {
+			return err
+		}
+		if size > uint64(pd.remaining()) {
+			return ErrInsufficientData
+		}
+		field, err := pd.getSubset(int(size))
+		if err != nil {
+			return err
+		}
+		if err := f(tag, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package sarama
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGetEmptyTaggedFieldArraySkipsUnknownTags(t *testing.T) {
+	rd := &realDecoder{raw: []byte{
+		0x02,             // two tagged fields
+		0x00, 0x02, 1, 2, // tag 0, two bytes
+		0x05, 0x00, // tag 5, empty
+		0x2A, // next field
+	}}
+	n, err := rd.getEmptyTaggedFieldArray()
+	if err != nil || n != 2 {
+		t.Fatalf("Expected to skip 2 tagged fields, got %d, %v", n, err)
+	}
+	if next, err := rd.getInt8(); err != nil || next != 0x2A {
+		t.Errorf("Expected to decode the field following the tagged fields, got %d, %v", next, err)
+	}
+
+	rd = &realDecoder{raw: []byte{0x01, 0x00, 0x05, 1, 2}}
+	if _, err := rd.getEmptyTaggedFieldArray(); !errors.Is(err, ErrInsufficientData) {
+		t.Errorf("Expected ErrInsufficientData for a truncated field, got %v", err)
+	}
+}
+
+func TestTaggedFields(t *testing.T) {
+	pe := &prepEncoder{}
+	encodeFields := func(pe packetEncoder) error {
+		pe.putUVarint(2)
+		if err := putTaggedField(pe, 1, &EpochEndOffset{Epoch: 3, EndOffset: 12}); err != nil {
+			return err
+		}
+		return putTaggedField(pe, 9, finalizedFeaturesEpoch(7))
+	}
+	if err := encodeFields(pe); err != nil {
+		t.Fatal(err)
+	}
+	re := &realEncoder{raw: make([]byte, pe.length)}
+	if err := encodeFields(re); err != nil {
+		t.Fatal(err)
+	}
+
+	var got EpochEndOffset
+	var tags []uint64
+	err := getTaggedFields(&realDecoder{raw: re.raw}, func(tag uint64, field packetDecoder) error {
+		tags = append(tags, tag)
+		if tag == 1 {
+			return got.decode(field)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags[0] != 1 || tags[1] != 9 {
+		t.Errorf("Expected the tags 1 and 9, got %v", tags)
+	}
+	if got != (EpochEndOffset{Epoch: 3, EndOffset: 12}) {
+		t.Errorf("Expected the value of tag 1 to be decoded, got %+v", got)
+	}
+}
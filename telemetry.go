@@ -0,0 +1,372 @@
+package sarama
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// telemetryRetryBackoff is the time to wait before getting the subscription
+// again when it failed or when the broker requested no metrics without a push
+// interval
+var telemetryRetryBackoff = 30 * time.Second
+
+// telemetryDisabled is returned instead of the time to wait for the next
+// step when the brokers refuse the telemetry of the client
+const telemetryDisabled time.Duration = -1
+
+// telemetryMetrics maps the names of the metrics of MetricRegistry pushed by
+// the telemetry to the names they are pushed as. The meters are pushed as
+// "<name>.rate" and "<name>.total", the histograms as "<name>.avg" and
+// "<name>.max", the counters and gauges as "<name>". The metrics of a single
+// broker, topic or group are kept local.
+var telemetryMetrics = map[string]string{
+	"incoming-byte-rate":             "org.apache.kafka.client.incoming.byte",
+	"outgoing-byte-rate":             "org.apache.kafka.client.outgoing.byte",
+	"request-rate":                   "org.apache.kafka.client.request",
+	"response-rate":                  "org.apache.kafka.client.response",
+	"request-size":                   "org.apache.kafka.client.request.size",
+	"response-size":                  "org.apache.kafka.client.response.size",
+	"request-latency-in-ms":          "org.apache.kafka.client.request.latency",
+	"requests-in-flight":             "org.apache.kafka.client.requests.in.flight",
+	"throttle-time-in-ms":            "org.apache.kafka.client.throttle.time",
+	"record-send-rate":               "org.apache.kafka.producer.record.send",
+	"batch-size":                     "org.apache.kafka.producer.batch.size",
+	"records-per-request":            "org.apache.kafka.producer.records.per.request",
+	"compression-ratio":              "org.apache.kafka.producer.compression.ratio",
+	"consumer-batch-size":            "org.apache.kafka.consumer.batch.size",
+	"consumer-fetch-latency-in-ms":   "org.apache.kafka.consumer.fetch.manager.fetch.latency",
+	"consumer-fetch-size":            "org.apache.kafka.consumer.fetch.manager.fetch.size",
+	"consumer-records-per-request":   "org.apache.kafka.consumer.fetch.manager.records.per.request",
+	"consumer-bytes-consumed-rate":   "org.apache.kafka.consumer.fetch.manager.bytes.consumed",
+	"consumer-records-consumed-rate": "org.apache.kafka.consumer.fetch.manager.records.consumed",
+	"consumer-records-lag":           "org.apache.kafka.consumer.fetch.manager.records.lag",
+}
+
+// clientTelemetry pushes the metrics of a client to the brokers as requested
+// by their subscription (KIP-714), see Config.Telemetry
+type clientTelemetry struct {
+	client *client
+	done   chan none
+
+	instanceID Uuid
+	// subscription is nil until the client subscribed, and when the
+	// subscription must be fetched again
+	subscription *GetTelemetrySubscriptionsResponse
+	// start is the start of the cumulative sums, lastPush the one of the
+	// delta sums
+	start, lastPush time.Time
+	// lastTotals are the totals of the meters at lastPush
+	lastTotals map[string]int64
+}
+
+func newClientTelemetry(client *client) *clientTelemetry {
+	now := time.Now()
+	return &clientTelemetry{
+		client:     client,
+		done:       make(chan none),
+		start:      now,
+		lastPush:   now,
+		lastTotals: make(map[string]int64),
+	}
+}
+
+// run subscribes and pushes the metrics until the client is closed, pushing
+// them a last time then
+func (t *clientTelemetry) run() {
+	defer close(t.done)
+
+	timer := time.NewTimer(t.subscribe())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			var wait time.Duration
+			if t.subscription == nil {
+				wait = t.subscribe()
+			} else {
+				wait = t.push(false)
+			}
+			if wait == telemetryDisabled {
+				return
+			}
+			timer.Reset(wait)
+		case <-t.client.closer:
+			if t.subscription != nil {
+				t.push(true)
+			}
+			return
+		}
+	}
+}
+
+// subscribe gets the subscription of the client and returns the time to wait
+// before the first push, or before subscribing again when no metric is
+// requested
+func (t *clientTelemetry) subscribe() time.Duration {
+	broker, err := t.client.LeastLoadedBroker()
+	if err != nil {
+		Logger.Printf("client/telemetry failed to get the subscription: %v\n", err)
+		return telemetryRetryBackoff
+	}
+	res, err := broker.GetTelemetrySubscriptions(&GetTelemetrySubscriptionsRequest{ClientInstanceId: t.instanceID})
+	if err == nil && res.Err != ErrNoError {
+		err = res.Err
+	}
+	switch err {
+	case nil:
+	case ErrUnsupportedVersion, ErrInvalidRequest:
+		Logger.Printf("client/telemetry disabled as broker #%d refused the subscription: %v\n", broker.ID(), err)
+		return telemetryDisabled
+	default:
+		Logger.Printf("client/telemetry failed to get the subscription from broker #%d: %v\n", broker.ID(), err)
+		return telemetryRetryBackoff
+	}
+
+	t.instanceID = res.ClientInstanceId
+	interval := time.Duration(res.PushIntervalMs) * time.Millisecond
+	if interval <= 0 {
+		interval = telemetryRetryBackoff
+	}
+	if len(res.RequestedMetrics) == 0 {
+		// the subscription may request metrics later on
+		t.subscription = nil
+		return interval
+	}
+	DebugLogger.Printf("client/telemetry subscription %d of client %s requests %v every %s\n",
+		res.SubscriptionId, t.instanceID, res.RequestedMetrics, interval)
+
+	t.subscription = res
+	// the clients starting together must not push together
+	return time.Duration((0.5 + rand.Float64()) * float64(interval))
+}
+
+// push pushes the requested metrics and returns the time to wait before the
+// next push, or 0 to subscribe again
+func (t *clientTelemetry) push(terminating bool) time.Duration {
+	sub := t.subscription
+	interval := time.Duration(sub.PushIntervalMs) * time.Millisecond
+	if interval <= 0 {
+		interval = telemetryRetryBackoff
+	}
+
+	codec, payload := t.collect(time.Now())
+	if sub.TelemetryMaxBytes > 0 && len(payload) > int(sub.TelemetryMaxBytes) {
+		Logger.Printf("client/telemetry dropped metrics of %d bytes, larger than the %d bytes accepted\n", len(payload), sub.TelemetryMaxBytes)
+		return interval
+	}
+
+	broker, err := t.client.LeastLoadedBroker()
+	if err != nil {
+		Logger.Printf("client/telemetry failed to push the metrics: %v\n", err)
+		return interval
+	}
+	res, err := broker.PushTelemetry(&PushTelemetryRequest{
+		ClientInstanceId: t.instanceID,
+		SubscriptionId:   sub.SubscriptionId,
+		Terminating:      terminating,
+		CompressionType:  codec,
+		Metrics:          payload,
+	})
+	if err == nil && res.Err != ErrNoError {
+		err = res.Err
+	}
+	switch err {
+	case nil:
+		return interval
+	case ErrUnknownSubscriptionID, ErrUnsupportedCompressionType:
+		// the subscription changed
+		t.subscription = nil
+		return 0
+	case ErrUnsupportedVersion, ErrInvalidRequest, ErrInvalidRecord:
+		Logger.Printf("client/telemetry disabled as broker #%d refused the metrics: %v\n", broker.ID(), err)
+		return telemetryDisabled
+	default:
+		Logger.Printf("client/telemetry failed to push the metrics to broker #%d: %v\n", broker.ID(), err)
+		return interval
+	}
+}
+
+// collect returns the requested metrics at now, encoded and compressed with
+// the first of the accepted compression codecs Sarama supports
+func (t *clientTelemetry) collect(now time.Time) (CompressionCodec, []byte) {
+	sub := t.subscription
+	start := t.start
+	if sub.DeltaTemporality {
+		start = t.lastPush
+	}
+	points := collectTelemetryPoints(t.client.conf.MetricRegistry, sub.RequestedMetrics)
+	for i := range points {
+		if !points[i].sum {
+			continue
+		}
+		total := points[i].total
+		if sub.DeltaTemporality {
+			points[i].total -= t.lastTotals[points[i].name]
+		}
+		t.lastTotals[points[i].name] = total
+	}
+	t.lastPush = now
+
+	payload := encodeTelemetryMetrics(points, start, now, sub.DeltaTemporality, t.client.conf.ClientSoftwareVersion)
+	for _, codec := range sub.AcceptedCompressionTypes {
+		if codec <= CompressionNone || codec > CompressionZSTD {
+			continue
+		}
+		if compressed, err := compress(codec, CompressionLevelDefault, 0, payload); err == nil {
+			return codec, compressed
+		}
+	}
+	return CompressionNone, payload
+}
+
+// telemetryPoint is the value of a metric pushed by the telemetry: a
+// monotonic sum of total, or a gauge of value
+type telemetryPoint struct {
+	name  string
+	sum   bool
+	total int64
+	value float64
+}
+
+// collectTelemetryPoints returns the points of the metrics of registry whose
+// name starts with one of requested, all of them when it holds an empty
+// string, sorted by name
+func collectTelemetryPoints(registry metrics.Registry, requested []string) []telemetryPoint {
+	if registry == nil {
+		return nil
+	}
+	var points []telemetryPoint
+	add := func(point telemetryPoint) {
+		for _, prefix := range requested {
+			if strings.HasPrefix(point.name, prefix) {
+				points = append(points, point)
+				return
+			}
+		}
+	}
+	registry.Each(func(name string, metric interface{}) {
+		pushed, ok := telemetryMetrics[name]
+		if !ok {
+			return
+		}
+		switch m := metric.(type) {
+		case metrics.Meter:
+			snapshot := m.Snapshot()
+			add(telemetryPoint{name: pushed + ".rate", value: snapshot.Rate1()})
+			add(telemetryPoint{name: pushed + ".total", sum: true, total: snapshot.Count()})
+		case metrics.Histogram:
+			snapshot := m.Snapshot()
+			add(telemetryPoint{name: pushed + ".avg", value: snapshot.Mean()})
+			add(telemetryPoint{name: pushed + ".max", value: float64(snapshot.Max())})
+		case metrics.Counter:
+			add(telemetryPoint{name: pushed, value: float64(m.Count())})
+		case metrics.Gauge:
+			add(telemetryPoint{name: pushed, value: float64(m.Value())})
+		case metrics.GaugeFloat64:
+			add(telemetryPoint{name: pushed, value: m.Value()})
+		}
+	})
+	sort.Slice(points, func(i, j int) bool { return points[i].name < points[j].name })
+	return points
+}
+
+// The OpenTelemetry aggregation temporalities of the sums
+const (
+	otlpTemporalityDelta      = 1
+	otlpTemporalityCumulative = 2
+)
+
+// encodeTelemetryMetrics encodes points as the OpenTelemetry MetricsData
+// protobuf message pushed to the brokers, the sums starting at start
+func encodeTelemetryMetrics(points []telemetryPoint, start, now time.Time, delta bool, version string) []byte {
+	temporality := uint64(otlpTemporalityCumulative)
+	if delta {
+		temporality = otlpTemporalityDelta
+	}
+	var data protoWriter
+	data.message(1, func(resourceMetrics *protoWriter) { // MetricsData.resource_metrics
+		resourceMetrics.message(2, func(scopeMetrics *protoWriter) { // ResourceMetrics.scope_metrics
+			scopeMetrics.message(1, func(scope *protoWriter) { // ScopeMetrics.scope
+				scope.string(1, "sarama")
+				scope.string(2, version)
+			})
+			for _, point := range points {
+				point := point
+				scopeMetrics.message(2, func(metric *protoWriter) { // ScopeMetrics.metrics
+					metric.string(1, point.name)
+					if !point.sum {
+						metric.message(5, func(gauge *protoWriter) { // Metric.gauge
+							gauge.message(1, func(dataPoint *protoWriter) {
+								dataPoint.fixed64(3, uint64(now.UnixNano()))
+								dataPoint.fixed64(4, math.Float64bits(point.value)) // as_double
+							})
+						})
+						return
+					}
+					metric.message(7, func(sum *protoWriter) { // Metric.sum
+						sum.message(1, func(dataPoint *protoWriter) {
+							dataPoint.fixed64(2, uint64(start.UnixNano()))
+							dataPoint.fixed64(3, uint64(now.UnixNano()))
+							dataPoint.fixed64(6, uint64(point.total)) // as_int
+						})
+						sum.varint(2, temporality)
+						sum.varint(3, 1) // is_monotonic
+					})
+				})
+			}
+		})
+	})
+	return data.buf
+}
+
+// protoWriter writes the fields of a protobuf message
+type protoWriter struct {
+	buf []byte
+}
+
+func (w *protoWriter) tag(field int, wireType uint64) {
+	w.rawVarint(uint64(field)<<3 | wireType)
+}
+
+func (w *protoWriter) rawVarint(v uint64) {
+	for v >= 0x80 {
+		w.buf = append(w.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	w.buf = append(w.buf, byte(v))
+}
+
+func (w *protoWriter) varint(field int, v uint64) {
+	w.tag(field, 0)
+	w.rawVarint(v)
+}
+
+func (w *protoWriter) fixed64(field int, v uint64) {
+	w.tag(field, 1)
+	for i := 0; i < 8; i++ {
+		w.buf = append(w.buf, byte(v>>(8*i)))
+	}
+}
+
+func (w *protoWriter) bytes(field int, b []byte) {
+	w.tag(field, 2)
+	w.rawVarint(uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *protoWriter) string(field int, s string) {
+	w.bytes(field, []byte(s))
+}
+
+// message writes the embedded message written by f
+func (w *protoWriter) message(field int, f func(*protoWriter)) {
+	var embedded protoWriter
+	f(&embedded)
+	w.bytes(field, embedded.buf)
+}
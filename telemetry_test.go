@@ -0,0 +1,222 @@
+package sarama
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// protoMessage returns the fields of a protobuf message, by number, the
+// varints being returned as their value and the other fields as their bytes
+func protoMessage(t *testing.T, b []byte) map[int][][]byte {
+	t.Helper()
+	fields := make(map[int][][]byte)
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatal("invalid protobuf tag")
+		}
+		b = b[n:]
+		field, wireType := int(tag>>3), tag&7
+		var value []byte
+		switch wireType {
+		case 0:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				t.Fatal("invalid protobuf varint")
+			}
+			value, b = []byte{byte(v)}, b[n:]
+		case 1:
+			value, b = b[:8], b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || len(b) < n+int(l) {
+				t.Fatal("invalid protobuf length")
+			}
+			value, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			t.Fatalf("unexpected protobuf wire type %d", wireType)
+		}
+		fields[field] = append(fields[field], value)
+	}
+	return fields
+}
+
+// telemetrySums decodes the MetricsData payload and returns the values of the
+// sums and the names of the gauges
+func telemetrySums(t *testing.T, payload []byte) (map[string]int64, []string) {
+	t.Helper()
+	sums := make(map[string]int64)
+	var gauges []string
+	for _, resourceMetrics := range protoMessage(t, payload)[1] {
+		for _, scopeMetrics := range protoMessage(t, resourceMetrics)[2] {
+			for _, metric := range protoMessage(t, scopeMetrics)[2] {
+				fields := protoMessage(t, metric)
+				name := string(fields[1][0])
+				if sum, ok := fields[7]; ok {
+					point := protoMessage(t, protoMessage(t, sum[0])[1][0])
+					sums[name] = int64(binary.LittleEndian.Uint64(point[6][0]))
+				} else {
+					gauges = append(gauges, name)
+				}
+			}
+		}
+	}
+	return sums, gauges
+}
+
+func TestTelemetryCollect(t *testing.T) {
+	registry := metrics.NewRegistry()
+	meter := metrics.GetOrRegisterMeter("request-rate", registry)
+	metrics.GetOrRegisterMeter("request-rate-for-broker-1", registry).Mark(1)
+	metrics.GetOrRegisterHistogram("batch-size", registry, metrics.NewUniformSample(10)).Update(3)
+
+	conf := NewTestConfig()
+	conf.MetricRegistry = registry
+	telemetry := newClientTelemetry(&client{conf: conf})
+	telemetry.subscription = &GetTelemetrySubscriptionsResponse{
+		RequestedMetrics: []string{"org.apache.kafka.client."},
+		DeltaTemporality: true,
+	}
+
+	meter.Mark(5)
+	codec, payload := telemetry.collect(time.Now())
+	if codec != CompressionNone {
+		t.Errorf("Expected uncompressed metrics, got %s", codec)
+	}
+	sums, gauges := telemetrySums(t, payload)
+	if len(sums) != 1 || sums["org.apache.kafka.client.request.total"] != 5 {
+		t.Errorf("Unexpected sums %v", sums)
+	}
+	if len(gauges) != 1 || gauges[0] != "org.apache.kafka.client.request.rate" {
+		t.Errorf("Unexpected gauges %v", gauges)
+	}
+
+	meter.Mark(3)
+	_, payload = telemetry.collect(time.Now())
+	if sums, _ = telemetrySums(t, payload); sums["org.apache.kafka.client.request.total"] != 3 {
+		t.Errorf("Expected the delta since the last push, got %v", sums)
+	}
+
+	telemetry.subscription.RequestedMetrics = []string{""}
+	telemetry.subscription.DeltaTemporality = false
+	_, payload = telemetry.collect(time.Now())
+	if sums, gauges = telemetrySums(t, payload); sums["org.apache.kafka.client.request.total"] != 8 || len(gauges) != 3 {
+		t.Errorf("Expected all the metrics with the cumulative sums, got %v and %v", sums, gauges)
+	}
+}
+
+func TestClientTelemetry(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"GetTelemetrySubscriptionsRequest": NewMockGetTelemetrySubscriptionsResponse(t).
+			SetSubscription(7, 10*time.Millisecond, "org.apache.kafka.client.request").
+			SetCompressionTypes(CompressionCodec(42), CompressionGZIP),
+		"PushTelemetryRequest": NewMockPushTelemetryResponse(t),
+	})
+
+	conf := NewTestConfig()
+	conf.Version = V3_7_0_0
+	conf.Telemetry.Enable = true
+	client, err := NewClient([]string{seedBroker.Addr()}, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pushes := func() []*PushTelemetryRequest {
+		var pushes []*PushTelemetryRequest
+		for _, rr := range seedBroker.History() {
+			if req, ok := rr.Request.(*PushTelemetryRequest); ok {
+				pushes = append(pushes, req)
+			}
+		}
+		return pushes
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(pushes()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	safeClose(t, client)
+
+	all := pushes()
+	if len(all) < 3 {
+		t.Fatalf("Expected the metrics to be pushed at least 3 times, got %d", len(all))
+	}
+	for i, req := range all {
+		if req.ClientInstanceId != (Uuid{15: 1}) || req.SubscriptionId != 7 || req.CompressionType != CompressionGZIP {
+			t.Errorf("Unexpected push %+v", req)
+		}
+		if terminating := i == len(all)-1; req.Terminating != terminating {
+			t.Errorf("Expected push %d to be terminating: %t", i, terminating)
+		}
+	}
+	payload, err := decompress(CompressionGZIP, all[0].Metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sums, _ := telemetrySums(t, payload)
+	if _, ok := sums["org.apache.kafka.client.request.total"]; !ok || len(sums) != 1 {
+		t.Errorf("Expected the requested metrics only, got %v", sums)
+	}
+}
+
+func TestClientTelemetryUnknownSubscription(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"GetTelemetrySubscriptionsRequest": NewMockGetTelemetrySubscriptionsResponse(t).
+			SetSubscription(7, 10*time.Millisecond, ""),
+		"PushTelemetryRequest": NewMockPushTelemetryResponse(t).SetError(ErrUnknownSubscriptionID),
+	})
+
+	conf := NewTestConfig()
+	conf.Version = V3_7_0_0
+	conf.Telemetry.Enable = true
+	client, err := NewClient([]string{seedBroker.Addr()}, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subscriptions := func() []*GetTelemetrySubscriptionsRequest {
+		var subscriptions []*GetTelemetrySubscriptionsRequest
+		for _, rr := range seedBroker.History() {
+			if req, ok := rr.Request.(*GetTelemetrySubscriptionsRequest); ok {
+				subscriptions = append(subscriptions, req)
+			}
+		}
+		return subscriptions
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(subscriptions()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	safeClose(t, client)
+
+	all := subscriptions()
+	if len(all) < 2 {
+		t.Fatalf("Expected the client to subscribe again, got %d subscriptions", len(all))
+	}
+	if all[0].ClientInstanceId != (Uuid{}) || all[1].ClientInstanceId != (Uuid{15: 1}) {
+		t.Errorf("Expected the client instance ID generated by the broker to be kept, got %v and %v",
+			all[0].ClientInstanceId, all[1].ClientInstanceId)
+	}
+}
+
+func TestClientTelemetryDisabledBefore37(t *testing.T) {
+	conf := NewTestConfig()
+	conf.Telemetry.Enable = true
+	found := false
+	for _, warning := range conf.Warnings() {
+		found = found || warning.Field == "Telemetry.Enable"
+	}
+	if !found {
+		t.Error("Expected a warning as Version is lower than 3.7")
+	}
+}
@@ -0,0 +1,98 @@
+package sarama
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertificateProvider provides the client certificate presented to the
+// brokers for mutual TLS, see Net.TLS.CertificateProvider. It must be safe for
+// concurrent use and keep returning the same *tls.Certificate until it is
+// rotated, as a different certificate makes the brokers reconnect.
+type CertificateProvider interface {
+	Certificate() (*tls.Certificate, error)
+}
+
+// CertificateProviderFunc adapts a function to the CertificateProvider
+// interface.
+type CertificateProviderFunc func() (*tls.Certificate, error)
+
+// Certificate calls f().
+func (f CertificateProviderFunc) Certificate() (*tls.Certificate, error) {
+	return f()
+}
+
+// FileCertificateProvider is a CertificateProvider loading a PEM encoded
+// certificate and key from files, which it loads again once they are
+// modified, for example when they are renewed by cert-manager or Vault agent.
+type FileCertificateProvider struct {
+	certFile, keyFile string
+	// CheckInterval is how often the files are checked for modifications
+	// when the certificate is requested (default 1 minute)
+	CheckInterval time.Duration
+
+	lock        sync.Mutex
+	certificate *tls.Certificate
+	modTimes    [2]time.Time
+	checked     time.Time
+}
+
+// NewFileCertificateProvider loads the certificate and key from the given
+// files and returns a FileCertificateProvider watching them.
+func NewFileCertificateProvider(certFile, keyFile string) (*FileCertificateProvider, error) {
+	p := &FileCertificateProvider{
+		certFile:      certFile,
+		keyFile:       keyFile,
+		CheckInterval: time.Minute,
+	}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Certificate implements CertificateProvider. The previous certificate is
+// kept if the modified files cannot be loaded, as they may be partly written.
+func (p *FileCertificateProvider) Certificate() (*tls.Certificate, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if time.Since(p.checked) >= p.CheckInterval {
+		if err := p.load(); err != nil {
+			Logger.Printf("failed to reload the client certificate: %v\n", err)
+		}
+	}
+	return p.certificate, nil
+}
+
+// load loads the certificate if the files were modified since the last load,
+// the lock must be held unless called from the constructor
+func (p *FileCertificateProvider) load() error {
+	p.checked = time.Now()
+
+	var modTimes [2]time.Time
+	for i, file := range []string{p.certFile, p.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	if p.certificate != nil && modTimes == p.modTimes {
+		return nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(p.certFile, p.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load the client certificate from %s and %s: %w", p.certFile, p.keyFile, err)
+	}
+	if p.certificate != nil {
+		Logger.Printf("reloaded the client certificate from %s\n", p.certFile)
+	}
+	p.certificate = &certificate
+	p.modTimes = modTimes
+	return nil
+}
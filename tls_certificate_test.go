@@ -0,0 +1,212 @@
+package sarama
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "ca"},
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+// issue returns a certificate valid for 127.0.0.1, or for the given DNS names
+// only when there are some
+func (ca *testCA) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage, dnsNames ...string) *tls.Certificate {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	if len(dnsNames) == 0 {
+		template.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1)}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func writeCertificate(t *testing.T, certificate *tls.Certificate, certFile, keyFile string, modTime time.Time) {
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(certificate.PrivateKey.(*rsa.PrivateKey)),
+	})
+	for file, data := range map[string][]byte{certFile: certPEM, keyFile: keyPEM} {
+		if err := ioutil.WriteFile(file, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFileCertificateProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sarama")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+
+	ca := newTestCA(t)
+	first := ca.issue(t, "client-1", x509.ExtKeyUsageClientAuth)
+	writeCertificate(t, first, certFile, keyFile, time.Now().Add(-time.Minute))
+
+	provider, err := NewFileCertificateProvider(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.CheckInterval = 0
+
+	loaded, err := provider.Certificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := provider.Certificate()
+	if again != loaded {
+		t.Error("expected the same certificate while the files are not modified")
+	}
+
+	second := ca.issue(t, "client-2", x509.ExtKeyUsageClientAuth)
+	writeCertificate(t, second, certFile, keyFile, time.Now())
+	rotated, err := provider.Certificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated == loaded || string(rotated.Certificate[0]) != string(second.Certificate[0]) {
+		t.Error("expected the rotated certificate to be loaded")
+	}
+
+	// a partly written certificate does not replace the current one
+	if err := ioutil.WriteFile(certFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(certFile, time.Now().Add(time.Minute), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if current, err := provider.Certificate(); err != nil || current != rotated {
+		t.Errorf("expected the previous certificate to be kept, got %v", err)
+	}
+
+	if _, err := NewFileCertificateProvider(filepath.Join(dir, "missing.crt"), keyFile); err == nil {
+		t.Error("expected an error for a missing certificate")
+	}
+}
+
+func TestBrokerCertificateProviderRotation(t *testing.T) {
+	ca := newTestCA(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	var lock sync.Mutex
+	var clients []string
+	serverConfig := &tls.Config{
+		Certificates: []tls.Certificate{*ca.issue(t, "host", x509.ExtKeyUsageServerAuth)},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+		MinVersion:   tls.VersionTLS12,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			lock.Lock()
+			clients = append(clients, cert.Subject.CommonName)
+			lock.Unlock()
+			return nil
+		},
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := NewMockBrokerListener(t, 0, listener)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t),
+	})
+
+	var current atomic.Value
+	current.Store(ca.issue(t, "client-1", x509.ExtKeyUsageClientAuth))
+
+	conf := NewTestConfig()
+	conf.Net.TLS.Enable = true
+	conf.Net.TLS.Config = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	conf.Net.TLS.CertificateProvider = CertificateProviderFunc(func() (*tls.Certificate, error) {
+		return current.Load().(*tls.Certificate), nil
+	})
+
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	for i := 0; i < 2; i++ {
+		if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	current.Store(ca.issue(t, "client-2", x509.ExtKeyUsageClientAuth))
+	for i := 0; i < 2; i++ {
+		if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(clients) != 2 || clients[0] != "client-1" || clients[1] != "client-2" {
+		t.Errorf("expected a connection with each certificate, got %v", clients)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	brokerList = flag.String("brokers", os.Getenv("KAFKA_PEERS"), "The comma separated list of brokers in the Kafka cluster. You can also set the KAFKA_PEERS environment variable")
+	output     = flag.String("output", "", "REQUIRED: the file to write the recorded traffic to")
+	verbose    = flag.Bool("verbose", false, "Turn on sarama logging to stderr")
+
+	logger = log.New(os.Stderr, "", log.LstdFlags)
+)
+
+func main() {
+	flag.Parse()
+
+	if *brokerList == "" {
+		printUsageErrorAndExit("no -brokers specified. Alternatively, set the KAFKA_PEERS environment variable")
+	}
+
+	if *output == "" {
+		printUsageErrorAndExit("no -output specified")
+	}
+
+	if *verbose {
+		sarama.Logger = logger
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		printErrorAndExit(73, "Failed to create the output file: %s", err)
+	}
+	defer file.Close()
+
+	recorder, err := sarama.NewTrafficRecorder(strings.Split(*brokerList, ","), file)
+	if err != nil {
+		printErrorAndExit(69, "Failed to start the recorder: %s", err)
+	}
+	fmt.Fprintf(os.Stderr, "Recording to %s, bootstrap the clients with -brokers=%s\n", *output, strings.Join(recorder.Addrs(), ","))
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	if err := recorder.Close(); err != nil {
+		printErrorAndExit(74, "Failed to write the recorded traffic: %s", err)
+	}
+}
+
+func printErrorAndExit(code int, format string, values ...interface{}) {
+	fmt.Fprintf(os.Stderr, "ERROR: %s\n", fmt.Sprintf(format, values...))
+	fmt.Fprintln(os.Stderr)
+	os.Exit(code)
+}
+
+func printUsageErrorAndExit(message string) {
+	fmt.Fprintln(os.Stderr, "ERROR:", message)
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Available command line options:")
+	flag.PrintDefaults()
+	os.Exit(64)
+}
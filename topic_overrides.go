@@ -0,0 +1,182 @@
+package sarama
+
+import (
+	"fmt"
+	"time"
+)
+
+// ProducerTopicOverrides overrides settings of Config.Producer for the
+// messages of a topic, see Producer.TopicOverrides. The zero fields keep the
+// value of Config.Producer.
+type ProducerTopicOverrides struct {
+	// MaxMessageBytes overrides Producer.MaxMessageBytes.
+	MaxMessageBytes int
+	// RequiredAcks overrides Producer.RequiredAcks when not nil.
+	RequiredAcks *RequiredAcks
+	// Flush overrides the fields of Producer.Flush.
+	Flush struct {
+		Bytes       int
+		Messages    int
+		Frequency   time.Duration
+		MaxMessages int
+	}
+}
+
+// ConsumerTopicOverrides overrides settings of Config.Consumer for the
+// partitions of a topic, see Consumer.TopicOverrides. The zero fields keep the
+// value of Config.Consumer.
+type ConsumerTopicOverrides struct {
+	// Fetch overrides the fields of Consumer.Fetch.
+	Fetch struct {
+		Min     int32
+		Default int32
+		Max     int32
+	}
+	// IsolationLevel overrides Consumer.IsolationLevel when not nil.
+	IsolationLevel *IsolationLevel
+}
+
+// producerSettings are the Producer settings which can be overridden per topic
+type producerSettings struct {
+	maxMessageBytes  int
+	requiredAcks     RequiredAcks
+	flushBytes       int
+	flushMessages    int
+	flushFrequency   time.Duration
+	flushMaxMessages int
+}
+
+// consumerSettings are the Consumer settings which can be overridden per topic
+type consumerSettings struct {
+	fetchMin       int32
+	fetchDefault   int32
+	fetchMax       int32
+	isolationLevel IsolationLevel
+}
+
+// producerSettings returns the Producer settings of the messages of topic, ""
+// for those of the topics without overrides
+func (c *Config) producerSettings(topic string) producerSettings {
+	s := producerSettings{
+		maxMessageBytes:  c.Producer.MaxMessageBytes,
+		requiredAcks:     c.Producer.RequiredAcks,
+		flushBytes:       c.Producer.Flush.Bytes,
+		flushMessages:    c.Producer.Flush.Messages,
+		flushFrequency:   c.Producer.Flush.Frequency,
+		flushMaxMessages: c.Producer.Flush.MaxMessages,
+	}
+	o, ok := c.Producer.TopicOverrides[topic]
+	if !ok {
+		return s
+	}
+	if o.MaxMessageBytes != 0 {
+		s.maxMessageBytes = o.MaxMessageBytes
+	}
+	if o.RequiredAcks != nil {
+		s.requiredAcks = *o.RequiredAcks
+	}
+	if o.Flush.Bytes != 0 {
+		s.flushBytes = o.Flush.Bytes
+	}
+	if o.Flush.Messages != 0 {
+		s.flushMessages = o.Flush.Messages
+	}
+	if o.Flush.Frequency != 0 {
+		s.flushFrequency = o.Flush.Frequency
+	}
+	if o.Flush.MaxMessages != 0 {
+		s.flushMaxMessages = o.Flush.MaxMessages
+	}
+	return s
+}
+
+// consumerSettings returns the Consumer settings of the partitions of topic,
+// "" for those of the topics without overrides
+func (c *Config) consumerSettings(topic string) consumerSettings {
+	s := consumerSettings{
+		fetchMin:       c.Consumer.Fetch.Min,
+		fetchDefault:   c.Consumer.Fetch.Default,
+		fetchMax:       c.Consumer.Fetch.Max,
+		isolationLevel: c.Consumer.IsolationLevel,
+	}
+	o, ok := c.Consumer.TopicOverrides[topic]
+	if !ok {
+		return s
+	}
+	if o.Fetch.Min != 0 {
+		s.fetchMin = o.Fetch.Min
+	}
+	if o.Fetch.Default != 0 {
+		s.fetchDefault = o.Fetch.Default
+	}
+	if o.Fetch.Max != 0 {
+		s.fetchMax = o.Fetch.Max
+	}
+	if o.IsolationLevel != nil {
+		s.isolationLevel = *o.IsolationLevel
+	}
+	return s
+}
+
+// producerBatch returns the topic whose messages are batched apart from the
+// others by the broker producers: topic itself if it has overrides since the
+// settings apply to whole requests, and "" otherwise
+func (c *Config) producerBatch(topic string) string {
+	if _, ok := c.Producer.TopicOverrides[topic]; ok {
+		return topic
+	}
+	return ""
+}
+
+// consumerBatch returns the topic whose partitions are fetched apart from the
+// others by the broker consumers, as producerBatch
+func (c *Config) consumerBatch(topic string) string {
+	if _, ok := c.Consumer.TopicOverrides[topic]; ok {
+		return topic
+	}
+	return ""
+}
+
+// validateTopicOverrides validates Producer.TopicOverrides and
+// Consumer.TopicOverrides along with the settings they result in
+func (c *Config) validateTopicOverrides() error {
+	for topic, o := range c.Producer.TopicOverrides {
+		prefix := fmt.Sprintf("Producer.TopicOverrides[%s]", topic)
+		s := c.producerSettings(topic)
+		switch {
+		case o.MaxMessageBytes < 0:
+			return ConfigurationError(prefix + ".MaxMessageBytes must be >= 0")
+		case o.RequiredAcks != nil && *o.RequiredAcks < -1:
+			return ConfigurationError(prefix + ".RequiredAcks must be >= -1")
+		case o.Flush.Bytes < 0:
+			return ConfigurationError(prefix + ".Flush.Bytes must be >= 0")
+		case o.Flush.Messages < 0:
+			return ConfigurationError(prefix + ".Flush.Messages must be >= 0")
+		case o.Flush.Frequency < 0:
+			return ConfigurationError(prefix + ".Flush.Frequency must be >= 0")
+		case o.Flush.MaxMessages < 0:
+			return ConfigurationError(prefix + ".Flush.MaxMessages must be >= 0")
+		case s.flushMaxMessages > 0 && s.flushMaxMessages < s.flushMessages:
+			return ConfigurationError(prefix + ".Flush.MaxMessages must be >= Flush.Messages when set")
+		case c.Producer.Idempotent && s.requiredAcks != WaitForAll:
+			return ConfigurationError(prefix + ".RequiredAcks must be WaitForAll for the idempotent producer")
+		}
+	}
+
+	for topic, o := range c.Consumer.TopicOverrides {
+		prefix := fmt.Sprintf("Consumer.TopicOverrides[%s]", topic)
+		switch {
+		case o.Fetch.Min < 0:
+			return ConfigurationError(prefix + ".Fetch.Min must be >= 0")
+		case o.Fetch.Default < 0:
+			return ConfigurationError(prefix + ".Fetch.Default must be >= 0")
+		case o.Fetch.Max < 0:
+			return ConfigurationError(prefix + ".Fetch.Max must be >= 0")
+		case o.IsolationLevel != nil && *o.IsolationLevel != ReadUncommitted && *o.IsolationLevel != ReadCommitted:
+			return ConfigurationError(prefix + ".IsolationLevel must be ReadUncommitted or ReadCommitted")
+		case o.IsolationLevel != nil && *o.IsolationLevel == ReadCommitted && !c.Version.IsAtLeast(V0_11_0_0):
+			return ConfigurationError(prefix + ": ReadCommitted requires Version >= V0_11_0_0")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package sarama
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SpanKind is the kind of a Span started by Sarama, as in OpenTelemetry.
+type SpanKind int8
+
+const (
+	// SpanKindClient is the kind of the spans of the requests sent to the
+	// brokers, see Tracing.Requests.
+	SpanKindClient SpanKind = iota
+	// SpanKindProducer is the kind of the spans of the produced messages, from
+	// the input of the producer to their success or failure.
+	SpanKindProducer
+	// SpanKindConsumer is the kind of the spans of the consumed messages, as
+	// they are delivered to the application.
+	SpanKindConsumer
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindClient:
+		return "client"
+	case SpanKindProducer:
+		return "producer"
+	case SpanKindConsumer:
+		return "consumer"
+	}
+	return "unknown"
+}
+
+// The keys of the attributes of the spans, those of the messaging semantic
+// conventions of OpenTelemetry.
+const (
+	// SpanKeySystem is "kafka" for all the spans
+	SpanKeySystem = "messaging.system"
+	// SpanKeyDestination is the topic of the message
+	SpanKeyDestination = "messaging.destination.name"
+	// SpanKeyPartition and SpanKeyOffset are the partition, an int32, and the
+	// offset, an int64, of the message
+	SpanKeyPartition = "messaging.kafka.destination.partition"
+	SpanKeyOffset    = "messaging.kafka.message.offset"
+	// SpanKeyBroker is the ID of the broker of a request, an int32
+	SpanKeyBroker = "messaging.kafka.broker.id"
+	// SpanKeyAPIKey and SpanKeyAPIVersion are the API key and version of a
+	// request, int16s
+	SpanKeyAPIKey     = "messaging.kafka.api.key"
+	SpanKeyAPIVersion = "messaging.kafka.api.version"
+)
+
+// SpanAttribute is a named value of a Span, with one of the SpanKey constants
+// as Key.
+type SpanAttribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Span is a span started by a Tracer, ended once by Sarama.
+type Span interface {
+	SetAttributes(attrs ...SpanAttribute)
+	// RecordError records the failure of the operation of the span.
+	RecordError(err error)
+	End()
+}
+
+// HeaderCarrier gives access to the headers of a message to a Tracer, to
+// inject and extract the context of the spans, e.g. as a W3C traceparent
+// header. It has the methods of the TextMapCarrier of OpenTelemetry.
+type HeaderCarrier interface {
+	Get(key string) string
+	Set(key, value string)
+	Keys() []string
+}
+
+// Tracer starts the spans of Sarama and propagates their context in the
+// headers of the messages, see Config.Tracing. It is typically an adapter of
+// an OpenTelemetry trace.Tracer and propagation.TextMapPropagator:
+//
+//	func (t *otelTracer) Start(ctx context.Context, name string, kind sarama.SpanKind, attrs ...sarama.SpanAttribute) (context.Context, sarama.Span) {
+//		ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(otelKind(kind)), trace.WithAttributes(otelAttributes(attrs)...))
+//		return ctx, &otelSpan{span}
+//	}
+//
+//	func (t *otelTracer) Inject(ctx context.Context, carrier sarama.HeaderCarrier) {
+//		propagation.TraceContext{}.Inject(ctx, carrier)
+//	}
+//
+//	func (t *otelTracer) Extract(ctx context.Context, carrier sarama.HeaderCarrier) context.Context {
+//		return propagation.TraceContext{}.Extract(ctx, carrier)
+//	}
+type Tracer interface {
+	// Start starts a span of kind as a child of the span of ctx, if any,
+	// returning the context of the new span.
+	Start(ctx context.Context, name string, kind SpanKind, attrs ...SpanAttribute) (context.Context, Span)
+	// Inject writes the span context of ctx to carrier.
+	Inject(ctx context.Context, carrier HeaderCarrier)
+	// Extract returns ctx with the span context read from carrier.
+	Extract(ctx context.Context, carrier HeaderCarrier) context.Context
+}
+
+// ProducerMessageCarrier returns the HeaderCarrier of the headers of msg.
+func ProducerMessageCarrier(msg *ProducerMessage) HeaderCarrier {
+	return producerMessageCarrier{msg}
+}
+
+type producerMessageCarrier struct {
+	msg *ProducerMessage
+}
+
+func (c producerMessageCarrier) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c producerMessageCarrier) Set(key, value string) {
+	for i, h := range c.msg.Headers {
+		if string(h.Key) == key {
+			c.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.msg.Headers = append(c.msg.Headers, RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+func (c producerMessageCarrier) Keys() []string {
+	keys := make([]string, len(c.msg.Headers))
+	for i, h := range c.msg.Headers {
+		keys[i] = string(h.Key)
+	}
+	return keys
+}
+
+// ConsumerMessageCarrier returns the HeaderCarrier of the headers of msg.
+func ConsumerMessageCarrier(msg *ConsumerMessage) HeaderCarrier {
+	return consumerMessageCarrier{msg}
+}
+
+type consumerMessageCarrier struct {
+	msg *ConsumerMessage
+}
+
+func (c consumerMessageCarrier) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c consumerMessageCarrier) Set(key, value string) {
+	for _, h := range c.msg.Headers {
+		if h != nil && string(h.Key) == key {
+			h.Value = []byte(value)
+			return
+		}
+	}
+	c.msg.Headers = append(c.msg.Headers, &RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+func (c consumerMessageCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers))
+	for _, h := range c.msg.Headers {
+		if h != nil {
+			keys = append(keys, string(h.Key))
+		}
+	}
+	return keys
+}
+
+// startProducerSpan starts the span of msg, entering the producer, and injects
+// its context in the headers if the version supports them
+func (p *asyncProducer) startProducerSpan(msg *ProducerMessage) {
+	tracer := p.conf.Tracing.Tracer
+	ctx := msg.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, msg.span = tracer.Start(ctx, msg.Topic+" publish", SpanKindProducer,
+		SpanAttribute{Key: SpanKeySystem, Value: "kafka"},
+		SpanAttribute{Key: SpanKeyDestination, Value: msg.Topic})
+	if p.conf.Version.IsAtLeast(V0_11_0_0) {
+		tracer.Inject(ctx, ProducerMessageCarrier(msg))
+	}
+}
+
+// endProducerSpan ends the span of msg, if any, failed with err if not nil
+func endProducerSpan(msg *ProducerMessage, err error) {
+	span := msg.span
+	if span == nil {
+		return
+	}
+	msg.span = nil
+	if err != nil {
+		span.RecordError(err)
+	} else {
+		span.SetAttributes(
+			SpanAttribute{Key: SpanKeyPartition, Value: msg.Partition},
+			SpanAttribute{Key: SpanKeyOffset, Value: msg.Offset})
+	}
+	span.End()
+}
+
+// traceConsumerMessage records the span of msg, delivered to the application,
+// as a child of the span context of its headers, and sets msg.Context
+func traceConsumerMessage(tracer Tracer, msg *ConsumerMessage) {
+	ctx := tracer.Extract(context.Background(), ConsumerMessageCarrier(msg))
+	ctx, span := tracer.Start(ctx, msg.Topic+" receive", SpanKindConsumer,
+		SpanAttribute{Key: SpanKeySystem, Value: "kafka"},
+		SpanAttribute{Key: SpanKeyDestination, Value: msg.Topic},
+		SpanAttribute{Key: SpanKeyPartition, Value: msg.Partition},
+		SpanAttribute{Key: SpanKeyOffset, Value: msg.Offset})
+	span.End()
+	msg.Context = ctx
+}
+
+// startRequestSpan starts the span of req, sent to b opened with conf, if
+// Tracing.Requests is set, and returns nil otherwise or if b is not open
+func (b *Broker) startRequestSpan(conf *Config, req protocolBody) Span {
+	if conf == nil {
+		return nil
+	}
+	tracing := &conf.Tracing
+	if tracing.Tracer == nil || !tracing.Requests {
+		return nil
+	}
+	_, span := tracing.Tracer.Start(context.Background(), requestName(req), SpanKindClient,
+		SpanAttribute{Key: SpanKeySystem, Value: "kafka"},
+		SpanAttribute{Key: SpanKeyBroker, Value: b.id},
+		SpanAttribute{Key: SpanKeyAPIKey, Value: req.key()},
+		SpanAttribute{Key: SpanKeyAPIVersion, Value: req.version()})
+	return span
+}
+
+// requestName returns the name of the type of req, such as "FetchRequest"
+func requestName(req protocolBody) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", req), "*sarama.")
+}
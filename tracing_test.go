@@ -0,0 +1,246 @@
+package sarama
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+type fakeTracer struct {
+	lock  sync.Mutex
+	spans []*fakeSpan
+}
+
+type fakeSpan struct {
+	tracer *fakeTracer
+	id     int
+	name   string
+	kind   SpanKind
+	parent string
+	attrs  map[string]interface{}
+	err    error
+	ended  bool
+}
+
+type fakeSpanContextKey struct{}
+
+func (s *fakeSpan) traceparent() string {
+	return fmt.Sprintf("00-%032x-%016x-01", 1, s.id)
+}
+
+func (s *fakeSpan) SetAttributes(attrs ...SpanAttribute) {
+	s.tracer.lock.Lock()
+	defer s.tracer.lock.Unlock()
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *fakeSpan) RecordError(err error) {
+	s.tracer.lock.Lock()
+	defer s.tracer.lock.Unlock()
+	s.err = err
+}
+
+func (s *fakeSpan) End() {
+	s.tracer.lock.Lock()
+	defer s.tracer.lock.Unlock()
+	s.ended = true
+}
+
+func (t *fakeTracer) Start(ctx context.Context, name string, kind SpanKind, attrs ...SpanAttribute) (context.Context, Span) {
+	t.lock.Lock()
+	span := &fakeSpan{tracer: t, id: len(t.spans) + 1, name: name, kind: kind, attrs: make(map[string]interface{})}
+	if parent, ok := ctx.Value(fakeSpanContextKey{}).(string); ok {
+		span.parent = parent
+	}
+	t.spans = append(t.spans, span)
+	t.lock.Unlock()
+	span.SetAttributes(attrs...)
+	return context.WithValue(ctx, fakeSpanContextKey{}, span.traceparent()), span
+}
+
+func (t *fakeTracer) Inject(ctx context.Context, carrier HeaderCarrier) {
+	if traceparent, ok := ctx.Value(fakeSpanContextKey{}).(string); ok {
+		carrier.Set("traceparent", traceparent)
+	}
+}
+
+func (t *fakeTracer) Extract(ctx context.Context, carrier HeaderCarrier) context.Context {
+	if traceparent := carrier.Get("traceparent"); traceparent != "" {
+		return context.WithValue(ctx, fakeSpanContextKey{}, traceparent)
+	}
+	return ctx
+}
+
+func (t *fakeTracer) spansNamed(name string) []*fakeSpan {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var spans []*fakeSpan
+	for _, span := range t.spans {
+		if span.name == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func TestProducerTracing(t *testing.T) {
+	broker := NewMockBroker(t, 1)
+	defer broker.Close()
+
+	metadataResponse := &MetadataResponse{Version: 4, ControllerID: 1}
+	metadataResponse.AddBroker(broker.Addr(), broker.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, broker.BrokerID(), nil, nil, nil, ErrNoError)
+	broker.Returns(metadataResponse)
+
+	tracer := &fakeTracer{}
+	config := NewTestConfig()
+	config.Version = V0_11_0_0
+	config.Producer.Return.Successes = true
+	config.Tracing.Tracer = tracer
+	producer, err := NewAsyncProducer([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent := context.WithValue(context.Background(), fakeSpanContextKey{}, "parent")
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage), Context: parent}
+
+	prodSuccess := &ProduceResponse{Version: 3}
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	broker.Returns(prodSuccess)
+
+	var msg *ProducerMessage
+	select {
+	case pErr := <-producer.Errors():
+		t.Fatal(pErr.Err)
+	case msg = <-producer.Successes():
+	}
+	closeProducer(t, producer)
+
+	spans := tracer.spansNamed("my_topic publish")
+	if len(spans) != 1 {
+		t.Fatalf("Expected one producer span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.kind != SpanKindProducer || span.parent != "parent" || !span.ended || span.err != nil {
+		t.Errorf("Unexpected producer span %+v", span)
+	}
+	if span.attrs[SpanKeyDestination] != "my_topic" || span.attrs[SpanKeyPartition] != int32(0) {
+		t.Errorf("Unexpected producer span attributes %v", span.attrs)
+	}
+	if got := ProducerMessageCarrier(msg).Get("traceparent"); got != span.traceparent() {
+		t.Errorf("Expected the traceparent header %q, got %q", span.traceparent(), got)
+	}
+	if msg.span != nil {
+		t.Error("Expected the span to be released with the message")
+	}
+}
+
+func TestProducerTracingError(t *testing.T) {
+	tracer := &fakeTracer{}
+	config := NewTestConfig()
+	config.Tracing.Tracer = tracer
+	producer := &asyncProducer{conf: config, errors: make(chan *ProducerError, 1)}
+	msg := &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	producer.startProducerSpan(msg)
+	producer.inFlight.Add(1)
+	producer.returnError(msg, ErrMessageSizeTooLarge)
+
+	if pErr := <-producer.errors; pErr.Err != ErrMessageSizeTooLarge {
+		t.Errorf("Expected ErrMessageSizeTooLarge, got %v", pErr.Err)
+	}
+	if span := tracer.spansNamed("my_topic publish")[0]; !span.ended || span.err != ErrMessageSizeTooLarge {
+		t.Errorf("Expected the span to end with the error, got %+v", span)
+	}
+	if ProducerMessageCarrier(msg).Get("traceparent") != "" {
+		t.Error("Expected no header to be injected before Kafka 0.11")
+	}
+}
+
+func TestConsumerTracing(t *testing.T) {
+	tracer := &fakeTracer{}
+	config := NewTestConfig()
+	config.Tracing.Tracer = tracer
+	child := &partitionConsumer{conf: config}
+
+	msg := &ConsumerMessage{
+		Topic:     "my_topic",
+		Partition: 1,
+		Offset:    42,
+		Headers:   []*RecordHeader{{Key: []byte("traceparent"), Value: []byte("parent")}},
+	}
+	child.prepareMessage(msg)
+
+	spans := tracer.spansNamed("my_topic receive")
+	if len(spans) != 1 {
+		t.Fatalf("Expected one consumer span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.kind != SpanKindConsumer || span.parent != "parent" || !span.ended {
+		t.Errorf("Unexpected consumer span %+v", span)
+	}
+	if span.attrs[SpanKeyPartition] != int32(1) || span.attrs[SpanKeyOffset] != int64(42) {
+		t.Errorf("Unexpected consumer span attributes %v", span.attrs)
+	}
+	if msg.Context == nil || msg.Context.Value(fakeSpanContextKey{}) != span.traceparent() {
+		t.Error("Expected the context of the span to be set on the message")
+	}
+}
+
+func TestRequestTracing(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+
+	tracer := &fakeTracer{}
+	conf := NewTestConfig()
+	conf.Tracing.Tracer = tracer
+	conf.Tracing.Requests = true
+	broker := NewBroker(seedBroker.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	if _, err := broker.GetMetadata(&MetadataRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := tracer.spansNamed("MetadataRequest")
+	if len(spans) != 1 {
+		t.Fatalf("Expected one request span, got %d", len(spans))
+	}
+	if span := spans[0]; span.kind != SpanKindClient || !span.ended || span.err != nil || span.attrs[SpanKeyAPIKey] != int16(3) {
+		t.Errorf("Unexpected request span %+v", span)
+	}
+}
+
+func TestHeaderCarriers(t *testing.T) {
+	pMsg := &ProducerMessage{Headers: []RecordHeader{{Key: []byte("a"), Value: []byte("1")}}}
+	carrier := ProducerMessageCarrier(pMsg)
+	carrier.Set("a", "2")
+	carrier.Set("b", "3")
+	if len(pMsg.Headers) != 2 || carrier.Get("a") != "2" || carrier.Get("b") != "3" || carrier.Get("c") != "" {
+		t.Errorf("Unexpected producer headers %+v", pMsg.Headers)
+	}
+	if keys := carrier.Keys(); len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("Unexpected producer header keys %v", keys)
+	}
+
+	cMsg := &ConsumerMessage{Headers: []*RecordHeader{{Key: []byte("a"), Value: []byte("1")}, nil}}
+	carrier = ConsumerMessageCarrier(cMsg)
+	carrier.Set("a", "2")
+	carrier.Set("b", "3")
+	if len(cMsg.Headers) != 3 || carrier.Get("a") != "2" || carrier.Get("b") != "3" {
+		t.Errorf("Unexpected consumer headers %+v", cMsg.Headers)
+	}
+	if keys := carrier.Keys(); len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("Unexpected consumer header keys %v", keys)
+	}
+}
@@ -0,0 +1,470 @@
+package sarama
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// TrafficRecord is a request sent to a broker and its response, as recorded
+// by a TrafficRecorder and replayed by a TrafficReplayer. Records are written
+// as JSON lines, the frames being encoded in base64.
+type TrafficRecord struct {
+	// Broker is the address of the broker the request was sent to.
+	Broker string `json:"broker"`
+	// Bootstrap tells whether the client was given Broker to bootstrap from,
+	// rather than learning it from a metadata response.
+	Bootstrap bool `json:"bootstrap,omitempty"`
+	// Conn numbers the connections of the clients, so that the requests of
+	// a connection can be told apart from the ones of the others.
+	Conn       int       `json:"conn"`
+	Time       time.Time `json:"time"`
+	APIKey     int16     `json:"api_key"`
+	APIVersion int16     `json:"api_version"`
+	// Request and Response are the frames sent on the wire, starting with
+	// their length. The response is the one of the broker, before the
+	// addresses of the brokers it contains are rewritten to the ones of the
+	// recorder. It is nil for the produce requests requiring no acks.
+	Request  []byte `json:"request"`
+	Response []byte `json:"response,omitempty"`
+}
+
+// ReadTrafficRecords reads the records written by a TrafficRecorder.
+func ReadTrafficRecords(r io.Reader) ([]*TrafficRecord, error) {
+	var records []*TrafficRecord
+	decoder := json.NewDecoder(r)
+	for {
+		record := new(TrafficRecord)
+		if err := decoder.Decode(record); err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+// TrafficRecorder is a proxy sitting between clients and a cluster, which
+// records the requests of the clients and the responses of the brokers, so
+// that the traffic of an incident can be turned into a regression test by a
+// TrafficReplayer.
+//
+// The recorder listens on a local address for each of the brokers. It
+// rewrites the addresses of the brokers in the metadata and find coordinator
+// responses to its own, so that the clients bootstrapped from Addrs connect
+// to every broker through it. Frames which do not decode, such as the tokens
+// of SASL v0 authentication, are forwarded without being recorded; TLS is
+// not supported.
+type TrafficRecorder struct {
+	lock      sync.Mutex
+	w         io.Writer
+	err       error
+	bootstrap []string
+	listeners map[string]*trafficListener
+	conns     int
+	closing   chan none
+	wg        sync.WaitGroup
+}
+
+type trafficListener struct {
+	upstream  string
+	bootstrap bool
+	listener  net.Listener
+}
+
+// NewTrafficRecorder starts a recorder for the cluster of the given bootstrap
+// addresses, writing its records to w.
+func NewTrafficRecorder(addrs []string, w io.Writer) (*TrafficRecorder, error) {
+	r := &TrafficRecorder{
+		w:         w,
+		listeners: make(map[string]*trafficListener),
+		closing:   make(chan none),
+	}
+	for _, addr := range addrs {
+		l, err := r.listen(addr, true)
+		if err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+		r.bootstrap = append(r.bootstrap, l.listener.Addr().String())
+	}
+	return r, nil
+}
+
+// Addrs returns the addresses of the recorder to bootstrap the clients with,
+// in the order of the addresses of the cluster given to NewTrafficRecorder.
+func (r *TrafficRecorder) Addrs() []string {
+	return append([]string(nil), r.bootstrap...)
+}
+
+// Err returns the first error met writing the records, after which no more
+// records are written.
+func (r *TrafficRecorder) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
+
+// Close stops listening, closes the connections and waits for the pending
+// records to be written.
+func (r *TrafficRecorder) Close() error {
+	r.lock.Lock()
+	select {
+	case <-r.closing:
+	default:
+		close(r.closing)
+	}
+	for _, l := range r.listeners {
+		_ = l.listener.Close()
+	}
+	r.lock.Unlock()
+
+	r.wg.Wait()
+	return r.Err()
+}
+
+// listen returns the listener proxying to the broker of the given address,
+// starting it if need be.
+func (r *TrafficRecorder) listen(upstream string, bootstrap bool) (*trafficListener, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if l, ok := r.listeners[upstream]; ok {
+		return l, nil
+	}
+	select {
+	case <-r.closing:
+		return nil, ErrClosedClient
+	default:
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	l := &trafficListener{upstream: upstream, bootstrap: bootstrap, listener: listener}
+	r.listeners[upstream] = l
+	Logger.Printf("traffic-recorder/%s listening on %s\n", upstream, listener.Addr())
+
+	r.wg.Add(1)
+	go r.accept(l)
+	return l, nil
+}
+
+func (r *TrafficRecorder) accept(l *trafficListener) {
+	defer r.wg.Done()
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			return
+		}
+		r.lock.Lock()
+		r.conns++
+		idx := r.conns
+		r.lock.Unlock()
+
+		r.wg.Add(1)
+		go r.proxy(l, conn, idx)
+	}
+}
+
+// proxy forwards the frames of a client connection to the broker and back,
+// recording them as they go.
+func (r *TrafficRecorder) proxy(l *trafficListener, client net.Conn, idx int) {
+	defer r.wg.Done()
+	defer client.Close()
+
+	broker, err := net.Dial("tcp", l.upstream)
+	if err != nil {
+		Logger.Printf("traffic-recorder/%s failed to connect: %v\n", l.upstream, err)
+		return
+	}
+	defer broker.Close()
+
+	done := make(chan none)
+	defer close(done)
+	go func() {
+		select {
+		case <-r.closing:
+		case <-done:
+		}
+		_ = client.Close()
+		_ = broker.Close()
+	}()
+
+	var lock sync.Mutex
+	inflight := make(map[int32]*TrafficRecord)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer broker.Close()
+		reader := bufio.NewReader(client)
+		for {
+			frame, err := readTrafficFrame(reader, MaxRequestSize)
+			if err != nil {
+				return
+			}
+			if correlationID, _, body, err := DecodeRequest(frame); err == nil {
+				key, version := ProtocolBodyVersion(body)
+				record := &TrafficRecord{
+					Broker:     l.upstream,
+					Bootstrap:  l.bootstrap,
+					Conn:       idx,
+					Time:       time.Now(),
+					APIKey:     key,
+					APIVersion: version,
+					Request:    frame,
+				}
+				if produce, ok := body.(*ProduceRequest); ok && produce.RequiredAcks == NoResponse {
+					r.write(record)
+				} else {
+					lock.Lock()
+					inflight[correlationID] = record
+					lock.Unlock()
+				}
+			}
+			if _, err := broker.Write(frame); err != nil {
+				return
+			}
+		}
+	}()
+
+	reader := bufio.NewReader(broker)
+	for {
+		frame, err := readTrafficFrame(reader, MaxResponseSize)
+		if err != nil {
+			return
+		}
+		correlationID := int32(binary.BigEndian.Uint32(frame[4:]))
+		lock.Lock()
+		record := inflight[correlationID]
+		delete(inflight, correlationID)
+		lock.Unlock()
+
+		forwarded := frame
+		if record != nil {
+			record.Response = frame
+			r.write(record)
+			if forwarded, err = r.rewrite(record); err != nil {
+				Logger.Printf("traffic-recorder/%s failed to rewrite a response: %v\n", l.upstream, err)
+				return
+			}
+		}
+		if _, err := client.Write(forwarded); err != nil {
+			return
+		}
+	}
+}
+
+// rewrite returns the response frame of the record, with the addresses of
+// the brokers it contains replaced by the ones of the recorder.
+func (r *TrafficRecorder) rewrite(record *TrafficRecord) ([]byte, error) {
+	if record.APIKey != (&MetadataRequest{}).key() && record.APIKey != (&FindCoordinatorRequest{}).key() {
+		return record.Response, nil
+	}
+	correlationID, body, err := DecodeResponse(record.Response, record.APIKey, record.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	err = rewriteBrokerAddrs(body, func(addr string) (string, error) {
+		l, err := r.listen(addr, false)
+		if err != nil {
+			return "", err
+		}
+		return l.listener.Addr().String(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return EncodeResponse(correlationID, body)
+}
+
+func (r *TrafficRecorder) write(record *TrafficRecord) {
+	buf, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.err != nil {
+		return
+	}
+	if _, err := r.w.Write(append(buf, '\n')); err != nil {
+		r.err = err
+	}
+}
+
+// TrafficReplayer replays the records of a TrafficRecorder with a MockBroker
+// for each of the recorded brokers. The requests sent to a broker are
+// answered with the responses it recorded for their API, in order, the last
+// one being repeated once they are exhausted. The addresses of the brokers in
+// the metadata and find coordinator responses are rewritten to the ones of
+// the mock brokers.
+//
+// The clients must be configured as the recorded ones, the versions of their
+// requests deciding the ones of the responses.
+type TrafficReplayer struct {
+	t         TestReporter
+	lock      sync.Mutex
+	brokers   map[string]*MockBroker
+	bootstrap []string
+	responses map[string]map[int16][]ProtocolBody
+}
+
+// NewTrafficReplayer starts the mock brokers replaying the records.
+func NewTrafficReplayer(t TestReporter, records []*TrafficRecord) *TrafficReplayer {
+	r := &TrafficReplayer{
+		t:         t,
+		brokers:   make(map[string]*MockBroker),
+		responses: make(map[string]map[int16][]ProtocolBody),
+	}
+
+	for _, record := range records {
+		r.startBroker(record.Broker, -1)
+		if record.Bootstrap && !r.isBootstrap(record.Broker) {
+			r.bootstrap = append(r.bootstrap, record.Broker)
+		}
+		if record.Response == nil {
+			continue
+		}
+		_, body, err := DecodeResponse(record.Response, record.APIKey, record.APIVersion)
+		if err != nil {
+			t.Errorf("Failed to decode the recorded response to %s: %v", record.Broker, err)
+			continue
+		}
+		if metadata, ok := body.(*MetadataResponse); ok {
+			for _, broker := range metadata.Brokers {
+				r.startBroker(broker.addr, broker.id)
+			}
+		}
+		if coordinator, ok := body.(*FindCoordinatorResponse); ok && coordinator.Coordinator != nil {
+			r.startBroker(coordinator.Coordinator.addr, coordinator.Coordinator.id)
+		}
+		if r.responses[record.Broker] == nil {
+			r.responses[record.Broker] = make(map[int16][]ProtocolBody)
+		}
+		r.responses[record.Broker][record.APIKey] = append(r.responses[record.Broker][record.APIKey], body)
+	}
+
+	for _, responses := range r.responses {
+		for _, bodies := range responses {
+			for _, body := range bodies {
+				_ = rewriteBrokerAddrs(body, func(addr string) (string, error) {
+					return r.brokers[addr].Addr(), nil
+				})
+			}
+		}
+	}
+	for upstream, broker := range r.brokers {
+		upstream := upstream
+		broker.setHandler(func(req *request) encoderWithHeader {
+			return r.handle(upstream, req)
+		})
+	}
+	return r
+}
+
+func (r *TrafficReplayer) isBootstrap(upstream string) bool {
+	for _, addr := range r.bootstrap {
+		if addr == upstream {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *TrafficReplayer) startBroker(upstream string, id int32) {
+	if _, ok := r.brokers[upstream]; ok {
+		return
+	}
+	if id < 0 {
+		id = int32(len(r.brokers))
+	}
+	r.brokers[upstream] = NewMockBroker(r.t, id)
+}
+
+func (r *TrafficReplayer) handle(upstream string, req *request) encoderWithHeader {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := req.body.key()
+	bodies := r.responses[upstream][key]
+	if len(bodies) == 0 {
+		r.t.Errorf("No recorded response of %s to %T", upstream, req.body)
+		return nil
+	}
+	if len(bodies) > 1 {
+		r.responses[upstream][key] = bodies[1:]
+	}
+	return bodies[0]
+}
+
+// Addrs returns the addresses of the mock brokers standing for the brokers
+// the recorded clients were bootstrapped from.
+func (r *TrafficReplayer) Addrs() []string {
+	addrs := make([]string, 0, len(r.bootstrap))
+	for _, upstream := range r.bootstrap {
+		addrs = append(addrs, r.brokers[upstream].Addr())
+	}
+	return addrs
+}
+
+// Broker returns the mock broker standing for the recorded broker of the
+// given address, nil if there is none.
+func (r *TrafficReplayer) Broker(upstream string) *MockBroker {
+	return r.brokers[upstream]
+}
+
+// Close closes the mock brokers.
+func (r *TrafficReplayer) Close() {
+	for _, broker := range r.brokers {
+		broker.Close()
+	}
+}
+
+// rewriteBrokerAddrs replaces the addresses of the brokers of the metadata
+// and find coordinator responses.
+func rewriteBrokerAddrs(body ProtocolBody, rewrite func(addr string) (string, error)) error {
+	var brokers []*Broker
+	switch res := body.(type) {
+	case *MetadataResponse:
+		brokers = res.Brokers
+	case *FindCoordinatorResponse:
+		if res.Coordinator != nil {
+			brokers = []*Broker{res.Coordinator}
+		}
+	}
+	for _, broker := range brokers {
+		addr, err := rewrite(broker.addr)
+		if err != nil {
+			return err
+		}
+		broker.addr = addr
+	}
+	return nil
+}
+
+func readTrafficFrame(r io.Reader, maxSize int32) ([]byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	size := int32(binary.BigEndian.Uint32(length[:]))
+	if size <= 4 || size > maxSize {
+		return nil, PacketDecodingError{fmt.Sprintf("message of length %d too large or too small", size)}
+	}
+
+	frame := make([]byte, 4+size)
+	copy(frame, length[:])
+	if _, err := io.ReadFull(r, frame[4:]); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
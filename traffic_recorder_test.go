@@ -0,0 +1,84 @@
+package sarama
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTrafficRecorderReplay(t *testing.T) {
+	cluster := NewMockCluster(t, 2)
+	defer cluster.Close()
+	if err := cluster.CreateTopic("my_topic", 2, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	config := NewTestConfig()
+	config.Version = V0_11_0_0
+	config.Producer.Return.Successes = true
+	config.Producer.Partitioner = NewManualPartitioner
+	config.Metadata.Retry.Backoff = 10 * time.Millisecond
+
+	produce := func(addrs []string) []int64 {
+		producer, err := NewSyncProducer(addrs, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer safeClose(t, producer)
+
+		var offsets []int64
+		for i, value := range []string{"a", "b", "c"} {
+			msg := &ProducerMessage{Topic: "my_topic", Partition: int32(i % 2), Value: StringEncoder(value)}
+			_, offset, err := producer.SendMessage(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			offsets = append(offsets, offset)
+		}
+		return offsets
+	}
+
+	var buf bytes.Buffer
+	recorder, err := NewTrafficRecorder(cluster.Addrs()[:1], &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := produce(recorder.Addrs())
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ReadTrafficRecords(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var produced int
+	for _, record := range records {
+		if record.Broker != cluster.Addrs()[0] && record.Broker != cluster.Addrs()[1] {
+			t.Errorf("Expected the record of a broker of the cluster, got %s", record.Broker)
+		}
+		if record.Bootstrap != (record.Broker == cluster.Addrs()[0]) {
+			t.Errorf("Unexpected bootstrap flag of %s", record.Broker)
+		}
+		if record.APIKey == 0 {
+			produced++
+		}
+	}
+	if produced != 3 {
+		t.Errorf("Expected 3 produce requests to be recorded, got %d", produced)
+	}
+
+	cluster.Close()
+	replayer := NewTrafficReplayer(t, records)
+	defer replayer.Close()
+	if len(replayer.Addrs()) != 1 {
+		t.Fatalf("Expected 1 bootstrap address, got %v", replayer.Addrs())
+	}
+	replayed := produce(replayer.Addrs())
+	for i := range recorded {
+		if replayed[i] != recorded[i] {
+			t.Errorf("Expected the replayed offsets %v to match the recorded ones %v", replayed, recorded)
+			break
+		}
+	}
+}
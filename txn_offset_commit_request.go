@@ -56,6 +56,9 @@ func (t *TxnOffsetCommitRequest) decode(pd packetDecoder, version int16) (err er
 	if err != nil {
 		return err
 	}
+	if n == -1 {
+		n = 0
+	}
 
 	t.Topics = make(map[string][]*PartitionOffsetMetadata)
 	for i := 0; i < n; i++ {
@@ -68,6 +71,9 @@ func (t *TxnOffsetCommitRequest) decode(pd packetDecoder, version int16) (err er
 		if err != nil {
 			return err
 		}
+		if m == -1 {
+			m = 0
+		}
 
 		t.Topics[topic] = make([]*PartitionOffsetMetadata, m)
 
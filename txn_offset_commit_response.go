@@ -43,6 +43,9 @@ func (t *TxnOffsetCommitResponse) decode(pd packetDecoder, version int16) (err e
 	if err != nil {
 		return err
 	}
+	if n == -1 {
+		n = 0
+	}
 
 	t.Topics = make(map[string][]*PartitionError)
 
@@ -56,6 +59,9 @@ func (t *TxnOffsetCommitResponse) decode(pd packetDecoder, version int16) (err e
 		if err != nil {
 			return err
 		}
+		if m == -1 {
+			m = 0
+		}
 
 		t.Topics[topic] = make([]*PartitionError, m)
 
@@ -85,3 +91,7 @@ func (a *TxnOffsetCommitResponse) headerVersion() int16 {
 func (a *TxnOffsetCommitResponse) requiredVersion() KafkaVersion {
 	return V0_11_0_0
 }
+
+func (a *TxnOffsetCommitResponse) throttleTime() time.Duration {
+	return a.ThrottleTime
+}
@@ -0,0 +1,41 @@
+package sarama
+
+// UnregisterBrokerRequest is a request to remove the registration of a broker
+// from a KRaft cluster (KIP-500)
+type UnregisterBrokerRequest struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	BrokerID int32
+}
+
+func (r *UnregisterBrokerRequest) encode(pe packetEncoder) error {
+	pe.putInt32(r.BrokerID)
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *UnregisterBrokerRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.BrokerID, err = pd.getInt32(); err != nil {
+		return err
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *UnregisterBrokerRequest) key() int16 {
+	return 64
+}
+
+func (r *UnregisterBrokerRequest) version() int16 {
+	return r.Version
+}
+
+func (r *UnregisterBrokerRequest) headerVersion() int16 {
+	return 2
+}
+
+func (r *UnregisterBrokerRequest) requiredVersion() KafkaVersion {
+	return V3_0_0_0
+}
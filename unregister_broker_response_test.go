@@ -0,0 +1,31 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	unregisterBrokerResponse = []byte{
+		0, 0, 0, 100, // ThrottleTime
+		0, 0, // ErrorCode
+		0, // ErrorMessage
+		0, // empty tagged fields
+	}
+
+	unregisterBrokerResponseError = []byte{
+		0, 0, 0, 0, // ThrottleTime
+		0, 35, // ErrorCode
+		4, 'b', 'a', 'd', // ErrorMessage
+		0, // empty tagged fields
+	}
+)
+
+func TestUnregisterBrokerResponse(t *testing.T) {
+	response := &UnregisterBrokerResponse{ThrottleTime: 100 * time.Millisecond}
+	testResponse(t, "no error", response, unregisterBrokerResponse)
+
+	msg := "bad"
+	response = &UnregisterBrokerResponse{ErrorCode: ErrUnsupportedVersion, ErrorMessage: &msg}
+	testResponse(t, "error", response, unregisterBrokerResponseError)
+}
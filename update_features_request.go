@@ -0,0 +1,136 @@
+package sarama
+
+import "time"
+
+// FeatureUpgradeType is the kind of change of the finalized version of a
+// feature requested by an UpdateFeaturesRequest.
+type FeatureUpgradeType int8
+
+const (
+	// FeatureUpgradeUnknown is not a valid upgrade type
+	FeatureUpgradeUnknown FeatureUpgradeType = iota
+	// FeatureUpgrade only allows raising the finalized version
+	FeatureUpgrade
+	// FeatureSafeDowngrade allows lowering the finalized version when no
+	// metadata is lost
+	FeatureSafeDowngrade
+	// FeatureUnsafeDowngrade allows lowering the finalized version even when
+	// metadata is lost
+	FeatureUnsafeDowngrade
+)
+
+// UpdateFeaturesRequest is a request to update the finalized versions of some
+// cluster wide features (KIP-584)
+type UpdateFeaturesRequest struct {
+	// Version 0 and 1 are supported
+	Version int16
+
+	Timeout        time.Duration
+	FeatureUpdates []FeatureUpdateKey
+	// ValidateOnly is only used from version 1
+	ValidateOnly bool
+}
+
+// FeatureUpdateKey is the update of the finalized version of a feature, a
+// MaxVersionLevel lower than 1 deleting the feature.
+type FeatureUpdateKey struct {
+	Feature         string
+	MaxVersionLevel int16
+	// AllowDowngrade is only used in version 0
+	AllowDowngrade bool
+	// UpgradeType is only used from version 1
+	UpgradeType FeatureUpgradeType
+}
+
+func (r *UpdateFeaturesRequest) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.Timeout / time.Millisecond))
+
+	pe.putCompactArrayLength(len(r.FeatureUpdates))
+	for _, update := range r.FeatureUpdates {
+		if err := pe.putCompactString(update.Feature); err != nil {
+			return err
+		}
+		pe.putInt16(update.MaxVersionLevel)
+		if r.Version == 0 {
+			pe.putBool(update.AllowDowngrade)
+		} else {
+			pe.putInt8(int8(update.UpgradeType))
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+
+	if r.Version >= 1 {
+		pe.putBool(r.ValidateOnly)
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *UpdateFeaturesRequest) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	timeout, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.Timeout = time.Duration(timeout) * time.Millisecond
+
+	n, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	r.FeatureUpdates = make([]FeatureUpdateKey, n)
+	for i := range r.FeatureUpdates {
+		update := &r.FeatureUpdates[i]
+		if update.Feature, err = pd.getCompactString(); err != nil {
+			return err
+		}
+		if update.MaxVersionLevel, err = pd.getInt16(); err != nil {
+			return err
+		}
+		if version == 0 {
+			if update.AllowDowngrade, err = pd.getBool(); err != nil {
+				return err
+			}
+		} else {
+			upgradeType, err := pd.getInt8()
+			if err != nil {
+				return err
+			}
+			update.UpgradeType = FeatureUpgradeType(upgradeType)
+		}
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
+	if version >= 1 {
+		if r.ValidateOnly, err = pd.getBool(); err != nil {
+			return err
+		}
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *UpdateFeaturesRequest) key() int16 {
+	return 57
+}
+
+func (r *UpdateFeaturesRequest) version() int16 {
+	return r.Version
+}
+
+func (r *UpdateFeaturesRequest) headerVersion() int16 {
+	return 2
+}
+
+func (r *UpdateFeaturesRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V3_3_0_0
+	default:
+		return V2_7_0_0
+	}
+}
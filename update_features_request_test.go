@@ -0,0 +1,50 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	updateFeaturesRequestV0 = []byte{
+		0, 0, 0x75, 0x30, // Timeout
+		2, // FeatureUpdates array, length 1
+		0x11, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a', '.', 'v', 'e', 'r', 's', 'i', 'o', 'n',
+		0, 14, // MaxVersionLevel
+		1, // AllowDowngrade
+		0, // empty tagged fields
+		0, // empty tagged fields
+	}
+
+	updateFeaturesRequestV1 = []byte{
+		0, 0, 0x75, 0x30, // Timeout
+		2, // FeatureUpdates array, length 1
+		0x11, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a', '.', 'v', 'e', 'r', 's', 'i', 'o', 'n',
+		0, 14, // MaxVersionLevel
+		2, // UpgradeType: safe downgrade
+		0, // empty tagged fields
+		1, // ValidateOnly
+		0, // empty tagged fields
+	}
+)
+
+func TestUpdateFeaturesRequest(t *testing.T) {
+	request := &UpdateFeaturesRequest{
+		Version: 0,
+		Timeout: 30 * time.Second,
+		FeatureUpdates: []FeatureUpdateKey{
+			{Feature: "metadata.version", MaxVersionLevel: 14, AllowDowngrade: true},
+		},
+	}
+	testRequest(t, "v0", request, updateFeaturesRequestV0)
+
+	request = &UpdateFeaturesRequest{
+		Version: 1,
+		Timeout: 30 * time.Second,
+		FeatureUpdates: []FeatureUpdateKey{
+			{Feature: "metadata.version", MaxVersionLevel: 14, UpgradeType: FeatureSafeDowngrade},
+		},
+		ValidateOnly: true,
+	}
+	testRequest(t, "v1", request, updateFeaturesRequestV1)
+}
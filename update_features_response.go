@@ -0,0 +1,112 @@
+package sarama
+
+import "time"
+
+// UpdateFeaturesResponse is the response to an UpdateFeaturesRequest
+type UpdateFeaturesResponse struct {
+	Version int16
+
+	ThrottleTime time.Duration
+	ErrorCode    KError
+	ErrorMessage *string
+	Results      []UpdatableFeatureResult
+}
+
+// UpdatableFeatureResult is the result of the update of a feature.
+type UpdatableFeatureResult struct {
+	Feature      string
+	ErrorCode    KError
+	ErrorMessage *string
+}
+
+func (r *UpdateFeaturesResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	pe.putInt16(int16(r.ErrorCode))
+	if err := pe.putNullableCompactString(r.ErrorMessage); err != nil {
+		return err
+	}
+
+	pe.putCompactArrayLength(len(r.Results))
+	for _, result := range r.Results {
+		if err := pe.putCompactString(result.Feature); err != nil {
+			return err
+		}
+		pe.putInt16(int16(result.ErrorCode))
+		if err := pe.putNullableCompactString(result.ErrorMessage); err != nil {
+			return err
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *UpdateFeaturesResponse) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	throttleTime, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttleTime) * time.Millisecond
+
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.ErrorCode = KError(kerr)
+	if r.ErrorMessage, err = pd.getCompactNullableString(); err != nil {
+		return err
+	}
+
+	n, err := pd.getCompactArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Results = make([]UpdatableFeatureResult, n)
+	for i := range r.Results {
+		result := &r.Results[i]
+		if result.Feature, err = pd.getCompactString(); err != nil {
+			return err
+		}
+		kerr, err := pd.getInt16()
+		if err != nil {
+			return err
+		}
+		result.ErrorCode = KError(kerr)
+		if result.ErrorMessage, err = pd.getCompactNullableString(); err != nil {
+			return err
+		}
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *UpdateFeaturesResponse) key() int16 {
+	return 57
+}
+
+func (r *UpdateFeaturesResponse) version() int16 {
+	return r.Version
+}
+
+func (r *UpdateFeaturesResponse) headerVersion() int16 {
+	return 1
+}
+
+func (r *UpdateFeaturesResponse) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 1:
+		return V3_3_0_0
+	default:
+		return V2_7_0_0
+	}
+}
+
+func (r *UpdateFeaturesResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
@@ -0,0 +1,31 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var updateFeaturesResponse = []byte{
+	0, 0, 0, 100, // ThrottleTime
+	0, 0, // ErrorCode
+	0, // ErrorMessage
+	2, // Results array, length 1
+	0x11, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a', '.', 'v', 'e', 'r', 's', 'i', 'o', 'n',
+	0, 42, // ErrorCode
+	4, 'b', 'a', 'd', // ErrorMessage
+	0, // empty tagged fields
+	0, // empty tagged fields
+}
+
+func TestUpdateFeaturesResponse(t *testing.T) {
+	msg := "bad"
+	response := &UpdateFeaturesResponse{
+		Version:      1,
+		ThrottleTime: 100 * time.Millisecond,
+		ErrorCode:    ErrNoError,
+		Results: []UpdatableFeatureResult{
+			{Feature: "metadata.version", ErrorCode: ErrInvalidRequest, ErrorMessage: &msg},
+		},
+	}
+	testResponse(t, "one feature", response, updateFeaturesResponse)
+}
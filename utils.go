@@ -2,6 +2,7 @@ package sarama
 
 import (
 	"bufio"
+	"encoding/base64"
 	"fmt"
 	"net"
 	"regexp"
@@ -24,6 +25,15 @@ func (slice int32Slice) Swap(i, j int) {
 	slice[i], slice[j] = slice[j], slice[i]
 }
 
+func int32SliceContains(slice []int32, value int32) bool {
+	for _, entry := range slice {
+		if entry == value {
+			return true
+		}
+	}
+	return false
+}
+
 func dupInt32Slice(input []int32) []int32 {
 	ret := make([]int32, 0, len(input))
 	ret = append(ret, input...)
@@ -89,6 +99,16 @@ func (b ByteEncoder) Length() int {
 	return len(b)
 }
 
+// Uuid is a 128-bit identifier as used by Kafka for topic IDs and similar
+// protocol fields. The zero value is the "null" UUID.
+type Uuid [16]byte
+
+// String returns the URL-safe base64 representation used by the Kafka
+// tooling, e.g. `kafka-topics.sh --describe`.
+func (u Uuid) String() string {
+	return base64.RawURLEncoding.EncodeToString(u[:])
+}
+
 // bufConn wraps a net.Conn with a buffer for reads to reduce the number of
 // reads that trigger syscalls.
 type bufConn struct {
@@ -164,6 +184,17 @@ var (
 	V2_6_0_0  = newKafkaVersion(2, 6, 0, 0)
 	V2_7_0_0  = newKafkaVersion(2, 7, 0, 0)
 	V2_8_0_0  = newKafkaVersion(2, 8, 0, 0)
+	V3_0_0_0  = newKafkaVersion(3, 0, 0, 0)
+	V3_1_0_0  = newKafkaVersion(3, 1, 0, 0)
+	V3_2_0_0  = newKafkaVersion(3, 2, 0, 0)
+	V3_3_0_0  = newKafkaVersion(3, 3, 0, 0)
+	V3_4_0_0  = newKafkaVersion(3, 4, 0, 0)
+	V3_5_0_0  = newKafkaVersion(3, 5, 0, 0)
+	V3_6_0_0  = newKafkaVersion(3, 6, 0, 0)
+	V3_7_0_0  = newKafkaVersion(3, 7, 0, 0)
+	V3_8_0_0  = newKafkaVersion(3, 8, 0, 0)
+	V3_9_0_0  = newKafkaVersion(3, 9, 0, 0)
+	V4_0_0_0  = newKafkaVersion(4, 0, 0, 0)
 
 	SupportedVersions = []KafkaVersion{
 		V0_8_2_0,
@@ -193,9 +224,20 @@ var (
 		V2_6_0_0,
 		V2_7_0_0,
 		V2_8_0_0,
+		V3_0_0_0,
+		V3_1_0_0,
+		V3_2_0_0,
+		V3_3_0_0,
+		V3_4_0_0,
+		V3_5_0_0,
+		V3_6_0_0,
+		V3_7_0_0,
+		V3_8_0_0,
+		V3_9_0_0,
+		V4_0_0_0,
 	}
 	MinVersion     = V0_8_2_0
-	MaxVersion     = V2_8_0_0
+	MaxVersion     = V4_0_0_0
 	DefaultVersion = V1_0_0_0
 )
 
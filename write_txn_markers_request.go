@@ -0,0 +1,115 @@
+package sarama
+
+// WriteTxnMarkersRequest is sent by transaction coordinators to the leaders of
+// the partitions of a transaction to write its commit or abort markers. It can
+// also be used by administrators to abort hanging transactions.
+type WriteTxnMarkersRequest struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	Markers []WritableTxnMarker
+}
+
+// WritableTxnMarker is a transaction marker to write to some partitions.
+type WritableTxnMarker struct {
+	ProducerID    int64
+	ProducerEpoch int16
+	// TransactionResult is true to commit the transaction, false to abort it
+	TransactionResult bool
+	Topics            []WritableTxnMarkerTopic
+	CoordinatorEpoch  int32
+}
+
+// WritableTxnMarkerTopic is the set of partitions of a topic to write a
+// marker to.
+type WritableTxnMarkerTopic struct {
+	Name             string
+	PartitionIndexes []int32
+}
+
+func (r *WriteTxnMarkersRequest) encode(pe packetEncoder) error {
+	if err := pe.putArrayLength(len(r.Markers)); err != nil {
+		return err
+	}
+	for _, marker := range r.Markers {
+		pe.putInt64(marker.ProducerID)
+		pe.putInt16(marker.ProducerEpoch)
+		pe.putBool(marker.TransactionResult)
+		if err := pe.putArrayLength(len(marker.Topics)); err != nil {
+			return err
+		}
+		for _, topic := range marker.Topics {
+			if err := pe.putString(topic.Name); err != nil {
+				return err
+			}
+			if err := pe.putInt32Array(topic.PartitionIndexes); err != nil {
+				return err
+			}
+		}
+		pe.putInt32(marker.CoordinatorEpoch)
+	}
+	return nil
+}
+
+func (r *WriteTxnMarkersRequest) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	numMarkers, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if numMarkers == -1 {
+		numMarkers = 0
+	}
+
+	r.Markers = make([]WritableTxnMarker, numMarkers)
+	for i := range r.Markers {
+		marker := &r.Markers[i]
+		if marker.ProducerID, err = pd.getInt64(); err != nil {
+			return err
+		}
+		if marker.ProducerEpoch, err = pd.getInt16(); err != nil {
+			return err
+		}
+		if marker.TransactionResult, err = pd.getBool(); err != nil {
+			return err
+		}
+
+		numTopics, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		if numTopics == -1 {
+			numTopics = 0
+		}
+		marker.Topics = make([]WritableTxnMarkerTopic, numTopics)
+		for j := range marker.Topics {
+			if marker.Topics[j].Name, err = pd.getString(); err != nil {
+				return err
+			}
+			if marker.Topics[j].PartitionIndexes, err = pd.getInt32Array(); err != nil {
+				return err
+			}
+		}
+
+		if marker.CoordinatorEpoch, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *WriteTxnMarkersRequest) key() int16 {
+	return 27
+}
+
+func (r *WriteTxnMarkersRequest) version() int16 {
+	return r.Version
+}
+
+func (r *WriteTxnMarkersRequest) headerVersion() int16 {
+	return 1
+}
+
+func (r *WriteTxnMarkersRequest) requiredVersion() KafkaVersion {
+	return V0_11_0_0
+}
@@ -0,0 +1,29 @@
+package sarama
+
+import "testing"
+
+var writeTxnMarkersRequest = []byte{
+	0, 0, 0, 1, // Markers array, length 1
+	0, 0, 0, 0, 0, 0, 3, 232, // ProducerID
+	0, 5, // ProducerEpoch
+	0,          // TransactionResult: abort
+	0, 0, 0, 1, // Topics array, length 1
+	0, 3, 'f', 'o', 'o', // Name
+	0, 0, 0, 1, 0, 0, 0, 2, // PartitionIndexes [2]
+	0, 0, 0, 7, // CoordinatorEpoch
+}
+
+func TestWriteTxnMarkersRequest(t *testing.T) {
+	request := &WriteTxnMarkersRequest{
+		Markers: []WritableTxnMarker{{
+			ProducerID:        1000,
+			ProducerEpoch:     5,
+			TransactionResult: false,
+			Topics: []WritableTxnMarkerTopic{
+				{Name: "foo", PartitionIndexes: []int32{2}},
+			},
+			CoordinatorEpoch: 7,
+		}},
+	}
+	testRequest(t, "abort marker", request, writeTxnMarkersRequest)
+}
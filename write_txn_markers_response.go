@@ -0,0 +1,124 @@
+package sarama
+
+// WriteTxnMarkersResponse is the response to a WriteTxnMarkersRequest
+type WriteTxnMarkersResponse struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	Markers []WritableTxnMarkerResult
+}
+
+// WritableTxnMarkerResult is the result of writing the markers of a producer.
+type WritableTxnMarkerResult struct {
+	ProducerID int64
+	Topics     []WritableTxnMarkerTopicResult
+}
+
+// WritableTxnMarkerTopicResult is the result of writing the markers of a
+// producer to the partitions of a topic.
+type WritableTxnMarkerTopicResult struct {
+	Name       string
+	Partitions []WritableTxnMarkerPartitionResult
+}
+
+// WritableTxnMarkerPartitionResult is the result of writing the marker of a
+// producer to a partition.
+type WritableTxnMarkerPartitionResult struct {
+	PartitionIndex int32
+	ErrorCode      KError
+}
+
+func (r *WriteTxnMarkersResponse) encode(pe packetEncoder) error {
+	if err := pe.putArrayLength(len(r.Markers)); err != nil {
+		return err
+	}
+	for _, marker := range r.Markers {
+		pe.putInt64(marker.ProducerID)
+		if err := pe.putArrayLength(len(marker.Topics)); err != nil {
+			return err
+		}
+		for _, topic := range marker.Topics {
+			if err := pe.putString(topic.Name); err != nil {
+				return err
+			}
+			if err := pe.putArrayLength(len(topic.Partitions)); err != nil {
+				return err
+			}
+			for _, partition := range topic.Partitions {
+				pe.putInt32(partition.PartitionIndex)
+				pe.putInt16(int16(partition.ErrorCode))
+			}
+		}
+	}
+	return nil
+}
+
+func (r *WriteTxnMarkersResponse) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	numMarkers, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if numMarkers == -1 {
+		numMarkers = 0
+	}
+
+	r.Markers = make([]WritableTxnMarkerResult, numMarkers)
+	for i := range r.Markers {
+		marker := &r.Markers[i]
+		if marker.ProducerID, err = pd.getInt64(); err != nil {
+			return err
+		}
+
+		numTopics, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		if numTopics == -1 {
+			numTopics = 0
+		}
+		marker.Topics = make([]WritableTxnMarkerTopicResult, numTopics)
+		for j := range marker.Topics {
+			topic := &marker.Topics[j]
+			if topic.Name, err = pd.getString(); err != nil {
+				return err
+			}
+
+			numPartitions, err := pd.getArrayLength()
+			if err != nil {
+				return err
+			}
+			if numPartitions == -1 {
+				numPartitions = 0
+			}
+			topic.Partitions = make([]WritableTxnMarkerPartitionResult, numPartitions)
+			for k := range topic.Partitions {
+				if topic.Partitions[k].PartitionIndex, err = pd.getInt32(); err != nil {
+					return err
+				}
+				kerr, err := pd.getInt16()
+				if err != nil {
+					return err
+				}
+				topic.Partitions[k].ErrorCode = KError(kerr)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *WriteTxnMarkersResponse) key() int16 {
+	return 27
+}
+
+func (r *WriteTxnMarkersResponse) version() int16 {
+	return r.Version
+}
+
+func (r *WriteTxnMarkersResponse) headerVersion() int16 {
+	return 0
+}
+
+func (r *WriteTxnMarkersResponse) requiredVersion() KafkaVersion {
+	return V0_11_0_0
+}
@@ -0,0 +1,28 @@
+package sarama
+
+import "testing"
+
+var writeTxnMarkersResponse = []byte{
+	0, 0, 0, 1, // Markers array, length 1
+	0, 0, 0, 0, 0, 0, 3, 232, // ProducerID
+	0, 0, 0, 1, // Topics array, length 1
+	0, 3, 'f', 'o', 'o', // Name
+	0, 0, 0, 1, // Partitions array, length 1
+	0, 0, 0, 2, // PartitionIndex
+	0, 47, // ErrorCode
+}
+
+func TestWriteTxnMarkersResponse(t *testing.T) {
+	response := &WriteTxnMarkersResponse{
+		Markers: []WritableTxnMarkerResult{{
+			ProducerID: 1000,
+			Topics: []WritableTxnMarkerTopicResult{{
+				Name: "foo",
+				Partitions: []WritableTxnMarkerPartitionResult{
+					{PartitionIndex: 2, ErrorCode: ErrInvalidProducerEpoch},
+				},
+			}},
+		}},
+	}
+	testResponse(t, "invalid producer epoch", response, writeTxnMarkersResponse)
+}
@@ -4,15 +4,26 @@ import (
 	"github.com/klauspost/compress/zstd"
 )
 
-var (
-	zstdDec, _ = zstd.NewReader(nil)
-	zstdEnc, _ = zstd.NewWriter(nil, zstd.WithZeroFrames(true))
-)
+var zstdDec, _ = zstd.NewReader(nil)
 
 func zstdDecompress(dst, src []byte) ([]byte, error) {
 	return zstdDec.DecodeAll(src, dst)
 }
 
-func zstdCompress(dst, src []byte) ([]byte, error) {
-	return zstdEnc.EncodeAll(src, dst), nil
+// zstdCompress compresses src at level with one of the pooled encoders of the
+// level, each encoding a single batch at a time
+func zstdCompress(level, concurrency int, dst, src []byte) ([]byte, error) {
+	pool := encoderPoolOf(encoderPoolKey{CompressionZSTD, level, concurrency}, func() (interface{}, error) {
+		opts := []zstd.EOption{zstd.WithZeroFrames(true), zstd.WithEncoderConcurrency(1)}
+		if level != CompressionLevelDefault {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		return zstd.NewWriter(nil, opts...)
+	})
+	encoder, err := pool.get()
+	if err != nil {
+		return nil, err
+	}
+	defer pool.put(encoder)
+	return encoder.(*zstd.Encoder).EncodeAll(src, dst), nil
 }